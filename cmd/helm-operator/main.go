@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
@@ -18,10 +26,13 @@ import (
 
 	"github.com/fluxcd/flux/pkg/checkpoint"
 	fluxhelm "github.com/fluxcd/helm-operator/pkg"
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
 	"github.com/fluxcd/helm-operator/pkg/chartsync"
 	clientset "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned"
 	ifinformers "github.com/fluxcd/helm-operator/pkg/client/informers/externalversions"
 	daemonhttp "github.com/fluxcd/helm-operator/pkg/http/daemon"
+	"github.com/fluxcd/helm-operator/pkg/http/gitwebhook"
+	"github.com/fluxcd/helm-operator/pkg/http/webhook"
 	"github.com/fluxcd/helm-operator/pkg/operator"
 	"github.com/fluxcd/helm-operator/pkg/release"
 	"github.com/fluxcd/helm-operator/pkg/status"
@@ -40,6 +51,13 @@ var (
 	master     *string
 	namespace  *string
 
+	allowTargetNamespaces *[]string
+	denyTargetNamespaces  *[]string
+	releaseSelector       *string
+
+	kubeAPIQPS   *float32
+	kubeAPIBurst *int
+
 	workers *int
 
 	tillerIP        *string
@@ -56,13 +74,74 @@ var (
 	chartsSyncInterval   *time.Duration
 	statusUpdateInterval *time.Duration
 	logReleaseDiffs      *bool
+	trackResources       *bool
+	establishCRDs        *bool
+	releaseDiffsFormat   *string
+	releaseDiffsContext  *int
+	stalledThreshold     *int64
+	skipSchemaValidation *bool
+	maxConcurrentHelmOps *int
+	releaseNameStrategy  *string
+	failureBackoff       *time.Duration
+	failureBackoffMax    *time.Duration
+	sourceRequeueDelay   *time.Duration
+	chartCacheMaxAge     *time.Duration
+	urlChartRefresh      *time.Duration
+	chartCacheMaxSize    *string
+	chartMaxSize         *string
 	updateDependencies   *bool
-
-	gitTimeout      *time.Duration
-	gitPollInterval *time.Duration
-	gitDefaultRef   *string
+	updateDepsTimeout    *time.Duration
+	dryRunReleasePrefix  *string
+	skipDryRun           *bool
+	dryRunTimeout        *time.Duration
+	dryRunNamespace      *string
+	dryRunClientSide     *bool
+	releaseDefaultsFile  *string
+	divergedSpecRetries  *int
+	dryRunOnly           *bool
+	upgradeOnDigest      *bool
+	pendingRecovery      *string
+	healthStaleness      *time.Duration
+	watchValuesSources   *bool
+	allowRenderRelease   *bool
+	allowCrossNSSources  *bool
+	releaseTimeout       *time.Duration
+	shutdownGracePeriod  *time.Duration
+
+	leaderElection              *bool
+	leaderElectionNamespace     *string
+	leaderElectionLeaseName     *string
+	leaderElectionLeaseDuration *time.Duration
+	leaderElectionRenewDeadline *time.Duration
+	leaderElectionRetryPeriod   *time.Duration
+
+	gitTimeout           *time.Duration
+	gitPollInterval      *time.Duration
+	gitDefaultRef        *string
+	gitMirrorSyncWorkers *int
+	gitBreakerThreshold  *int
+	gitBreakerCooldown   *time.Duration
+	gitSSHConfigDir      *string
+	gitCredentialsDir    *string
+
+	chartRepoProxy       *string
+	chartRepoCAFile      *string
+	chartRepoCredentials *string
+
+	chartRepoCredentialsDefault *bool
+
+	azureManagedIdentity   *bool
+	awsOperatorCredentials *bool
+	gcpOperatorCredentials *bool
 
 	listenAddr *string
+
+	webhookListenAddr *string
+	webhookTLSCert    *string
+	webhookTLSKey     *string
+
+	gitWebhookListenAddr *string
+	gitWebhookSecretFile *string
 )
 
 const (
@@ -90,11 +169,23 @@ func init() {
 	kubeconfig = fs.String("kubeconfig", "", "path to a kubeconfig; required if out-of-cluster")
 	master = fs.String("master", "", "address of the Kubernetes API server; overrides any value in kubeconfig; required if out-of-cluster")
 	namespace = fs.String("allow-namespace", "", "if set, this limits the scope to a single namespace; if not specified, all namespaces will be watched")
+	allowTargetNamespaces = fs.StringSlice("allow-target-namespaces", nil, "if set, the namespaces (or glob patterns) releases may target; others are refused")
+	denyTargetNamespaces = fs.StringSlice("deny-target-namespaces", nil, "namespaces (or glob patterns) releases may not target, even when allowed")
+	releaseSelector = fs.String("release-selector", "", "if set, only HelmReleases with labels matching this label selector are reconciled, e.g. 'shard=a'")
+	kubeAPIQPS = fs.Float32("kube-api-qps", 0, "maximum queries per second to the Kubernetes API server; if not set, the client default of 5 is used")
+	kubeAPIBurst = fs.Int("kube-api-burst", 0, "maximum burst of queries to the Kubernetes API server; if not set, the client default of 10 is used")
 
 	workers = fs.Int("workers", 2, "amount of workers processing releases")
 
 	listenAddr = fs.StringP("listen", "l", ":3030", "Listen address where /metrics and API will be served")
 
+	webhookListenAddr = fs.String("webhook-listen", "", "if set, listen address where the validating admission webhook for HelmReleases will be served over HTTPS")
+	webhookTLSCert = fs.String("webhook-tls-cert-path", "/etc/fluxd/webhook/tls.crt", "path to certificate file used to serve the admission webhook")
+	webhookTLSKey = fs.String("webhook-tls-key-path", "/etc/fluxd/webhook/tls.key", "path to private key file used to serve the admission webhook")
+
+	gitWebhookListenAddr = fs.String("git-webhook-listen", "", "if set, listen address where push webhooks of GitHub, GitLab and Bitbucket are received, to sync the git mirrors of the repositories pushed to")
+	gitWebhookSecretFile = fs.String("git-webhook-secret-file", "", "path to a file with the secret the git push webhooks are signed with; required with --git-webhook-listen")
+
 	tillerIP = fs.String("tiller-ip", "", "Tiller IP address; required if run out-of-cluster")
 	tillerPort = fs.String("tiller-port", "", "Tiller port; required if run out-of-cluster")
 	tillerNamespace = fs.String("tiller-namespace", "kube-system", "Tiller namespace")
@@ -109,11 +200,63 @@ func init() {
 	chartsSyncInterval = fs.Duration("charts-sync-interval", 3*time.Minute, "period on which to reconcile the Helm releases with HelmRelease resources")
 	statusUpdateInterval = fs.Duration("status-update-interval", 10*time.Second, "period on which to update the Helm release status in HelmRelease resources")
 	logReleaseDiffs = fs.Bool("log-release-diffs", false, "log the diff when a chart release diverges; potentially insecure")
+	releaseDiffsFormat = fs.String("log-release-diffs-format", chartsync.DiffFormatCmp, "format of the logged release diffs; 'cmp' or 'unified'")
+	releaseDiffsContext = fs.Int("log-release-diffs-context", 0, "number of unchanged lines around the changes of the logged release diffs; 0 keeps all lines of 'cmp' diffs, and three of 'unified' diffs")
+	trackResources = fs.Bool("track-release-resources", false, "annotate the resources of releases with the HelmRelease they belong to as part of the release, and log the resources that lack the annotation when a release has diverged")
+	establishCRDs = fs.Bool("establish-crds", false, "create the CRDs in the crds/ directory of charts before their release, and wait for them to be established, so that custom resources of the release can be created")
+	stalledThreshold = fs.Int64("stalled-threshold", 3, "number of consecutive times a release has to fail with the same reason before its Stalled condition is set; 0 disables the condition")
 	updateDependencies = fs.Bool("update-chart-deps", true, "update chart dependencies before installing/upgrading a release")
+	updateDepsTimeout = fs.Duration("update-chart-deps-timeout", 2*time.Minute, "duration after which updating chart dependencies times out; can be overridden per HelmRelease")
+	failureBackoff = fs.Duration("failure-backoff", 30*time.Second, "delay before a release that failed is attempted again, doubled with every consecutive failure; 0 disables the backoff")
+	failureBackoffMax = fs.Duration("failure-backoff-max", 15*time.Minute, "maximum delay before a release that failed is attempted again")
+	sourceRequeueDelay = fs.Duration("source-requeue-delay", 10*time.Second, "delay before a release of which the chart source is not ready yet (e.g. a git repo not mirrored yet) is attempted again")
+	healthStaleness = fs.Duration("health-staleness-window", 15*time.Minute, "duration without a completed release reconciliation after which /healthz reports unhealthy; 0 disables the check")
+	watchValuesSources = fs.Bool("watch-values-sources", false, "watch the config maps and secrets HelmReleases take values from, and upgrade the releases when their values change")
+	releaseDefaultsFile = fs.String("release-defaults-file", "", "path to a YAML file with the timeout, upgrade and rollback settings HelmReleases inherit unless they set them themselves")
+	releaseTimeout = fs.Duration("release-timeout", time.Duration(helmfluxv1.DefaultTimeout)*time.Second, "install or upgrade timeout for HelmReleases that do not specify one")
+	shutdownGracePeriod = fs.Duration("shutdown-grace-period", 25*time.Second, "duration to wait for in-flight installs, upgrades and rollbacks to finish on shutdown")
+	leaderElection = fs.Bool("leader-election", false, "elect a leader among the replicas of the operator, so that only one of them reconciles releases while the others stand by")
+	leaderElectionNamespace = fs.String("leader-election-namespace", "", "namespace of the config map holding the leader election lease; if not set, the namespace the operator runs in is used")
+	leaderElectionLeaseName = fs.String("leader-election-lease-name", "helm-operator-leader", "name of the config map holding the leader election lease; operators sharding releases with --release-selector need a lease each")
+	leaderElectionLeaseDuration = fs.Duration("leader-election-lease-duration", chartsync.DefaultLeaseDuration, "duration standby replicas wait before taking over a lease that is not renewed")
+	leaderElectionRenewDeadline = fs.Duration("leader-election-renew-deadline", chartsync.DefaultRenewDeadline, "duration the leader tries to renew its lease for before it steps down")
+	leaderElectionRetryPeriod = fs.Duration("leader-election-retry-period", chartsync.DefaultRetryPeriod, "interval at which the leader election lease is acquired or renewed")
+	allowRenderRelease = fs.Bool("allow-render-release", false, "allow rendering the manifests of releases through the HTTP API; the manifests may contain secrets")
+	allowCrossNSSources = fs.Bool("allow-cross-namespace-source-refs", false, "allow HelmReleases to refer to source objects in other namespaces than their own with sourceRef")
+	dryRunReleasePrefix = fs.String("dry-run-release-prefix", release.DefaultDryRunReleasePrefix, "prefix of the release names used for dry runs; release names with this prefix are refused")
+	skipDryRun = fs.Bool("skip-dry-run", false, "decide to upgrade releases on changes to the HelmRelease, the chart revision and the values alone, rather than on the outcome of a dry run")
+	pendingRecovery = fs.String("pending-release-recovery", chartsync.PendingRecoveryNone, "how releases with an install or upgrade pending for longer than their timeout (e.g. because the operator was stopped) are recovered; 'none', 'rollback' to the last deployed revision, or 'delete' to install them again")
+	upgradeOnDigest = fs.Bool("upgrade-on-chart-digest-change", false, "upgrade a release when the digest of its chart archive differs from the one last released, even if the chart version did not change")
+	dryRunOnly = fs.Bool("dry-run-only", false, "never install, upgrade, roll back or delete releases; only perform the dry runs, and report what would have been done in the conditions of HelmReleases and as events")
+	divergedSpecRetries = fs.Int("diverged-spec-retries", 0, "number of times a release is reconciled again right away against the newer spec when its HelmRelease changes before it is upgraded; 0 skips the upgrade until the next reconciliation")
+	dryRunTimeout = fs.Duration("dry-run-timeout", chartsync.DefaultDryRunTimeout, "duration after which the dry run to determine if a release should be upgraded is given up on")
+	dryRunNamespace = fs.String("dry-run-namespace", "", "namespace to do the dry run to determine if a release should be upgraded in when it is denied in the target namespace of the release")
+	dryRunClientSide = fs.Bool("dry-run-client-side-fallback", false, "compare the values and chart of a release without a dry run by Tiller when the dry run is denied, in the target namespace and in the --dry-run-namespace")
+	skipSchemaValidation = fs.Bool("skip-schema-validation", false, "do not validate the values of releases against the JSON schema (values.schema.json) of their chart")
+	maxConcurrentHelmOps = fs.Int("max-concurrent-helm-ops", 0, "maximum number of Helm installs, upgrades, rollbacks and deletions to run at once across all releases; 0 does not limit them")
+	releaseNameStrategy = fs.String("release-name-strategy", string(helmfluxv1.ReleaseNameStrategyDefault), "how release names are derived from HelmReleases; 'default', or 'namespaced' to prefix configured release names with the namespace of the HelmRelease")
 
 	gitTimeout = fs.Duration("git-timeout", 20*time.Second, "duration after which git operations time out")
 	gitPollInterval = fs.Duration("git-poll-interval", 5*time.Minute, "period on which to poll git chart sources for changes")
 	gitDefaultRef = fs.String("git-default-ref", "master", "ref to clone chart from if ref is unspecified in a HelmRelease")
+	gitMirrorSyncWorkers = fs.Int("git-mirror-sync-workers", 4, "number of git mirrors that are refreshed from their upstream concurrently")
+	gitBreakerThreshold = fs.Int("git-mirror-breaker-threshold", 5, "number of consecutive times refreshing a git mirror has to fail before it is not used for the --git-mirror-breaker-cooldown; 0 disables the circuit breaker")
+	gitBreakerCooldown = fs.Duration("git-mirror-breaker-cooldown", 5*time.Minute, "duration for which a git mirror is not used once its circuit breaker opened")
+	gitSSHConfigDir = fs.String("git-ssh-config-dir", "/var/fluxd/ssh", "directory to write the SSH keys of git chart sources with an sshSecretRef to, with the SSH config including them")
+	gitCredentialsDir = fs.String("git-credentials-dir", "/var/fluxd/git", "directory to write the GitHub App installation tokens of git chart sources with a githubAppSecretRef to, for the git credential helper")
+
+	chartRepoProxy = fs.String("chart-repo-proxy", "", "URL of the HTTP(S) proxy to download charts from Helm repos through; defaults to the proxy from the environment")
+	chartRepoCAFile = fs.String("chart-repo-ca-file", "", "path to a PEM encoded CA bundle to trust for Helm repos, in addition to the system CAs")
+	chartRepoCredentials = fs.String("chart-repo-credentials-file", "", "path to a netrc file with the credentials for Helm repos, by host, used for repos that have none in repositories.yaml")
+	chartRepoCredentialsDefault = fs.Bool("chart-repo-credentials-default", false, "use the default entry of the --chart-repo-credentials-file for hosts without a machine entry of their own")
+	chartCacheMaxAge = fs.Duration("chart-cache-max-age", 0, "duration after which charts from Helm repos that have not been used are evicted from the chart cache; 0 disables the eviction by age")
+	urlChartRefresh = fs.Duration("url-chart-refresh-interval", 10*time.Minute, "interval at which chart archives from URLs without a sha256 are downloaded again to pick up changes; 0 downloads them once")
+	chartCacheMaxSize = fs.String("chart-cache-max-size", "", "size (e.g. 1Gi) of the chart cache beyond which the least recently used charts are evicted from it; empty disables the eviction by size")
+	chartMaxSize = fs.String("chart-max-size", "", "size (e.g. 100Mi) beyond which the download of a chart from a Helm repo is aborted; empty means no limit")
+
+	awsOperatorCredentials = fs.Bool("aws-operator-credentials", false, "allow charts in S3 buckets to be fetched with the AWS credentials of the operator (environment or IAM role of its service account) when their bucket secret has none; never used with a custom endpoint")
+	gcpOperatorCredentials = fs.Bool("gcp-operator-credentials", false, "allow charts in GCS buckets to be fetched with the Google credentials of the operator (GOOGLE_APPLICATION_CREDENTIALS or its workload identity) when their bucket secret has none")
+	azureManagedIdentity = fs.Bool("azure-managed-identity", false, "allow the bucket secrets of charts in Azure Blob Storage to ask for the managed identity of the operator; it is only used for <account>.blob.core.windows.net")
 }
 
 func main() {
@@ -157,11 +300,122 @@ func main() {
 
 	mainLogger := log.With(logger, "component", "helm-operator")
 
+	if !chartsync.ValidDiffFormat(*releaseDiffsFormat) {
+		mainLogger.Log("error", fmt.Sprintf("invalid release diffs format: %q", *releaseDiffsFormat))
+		os.Exit(1)
+	}
+	if !chartsync.ValidPendingRecovery(*pendingRecovery) {
+		mainLogger.Log("error", fmt.Sprintf("invalid pending release recovery: %q", *pendingRecovery))
+		os.Exit(1)
+	}
+	for _, patterns := range [][]string{*allowTargetNamespaces, *denyTargetNamespaces} {
+		if err := chartsync.ValidNamespacePatterns(patterns); err != nil {
+			mainLogger.Log("error", err.Error())
+			os.Exit(1)
+		}
+	}
+	var selector labels.Selector
+	if *releaseSelector != "" {
+		s, err := labels.Parse(*releaseSelector)
+		if err != nil {
+			mainLogger.Log("error", fmt.Sprintf("invalid release selector %q: %s", *releaseSelector, err))
+			os.Exit(1)
+		}
+		selector = s
+	}
+	var gitWebhookSecret []byte
+	if *gitWebhookListenAddr != "" {
+		if *gitWebhookSecretFile == "" {
+			mainLogger.Log("error", "--git-webhook-secret-file is required with --git-webhook-listen")
+			os.Exit(1)
+		}
+		b, err := ioutil.ReadFile(*gitWebhookSecretFile)
+		if err != nil {
+			mainLogger.Log("error", fmt.Sprintf("unable to read git webhook secret: %v", err))
+			os.Exit(1)
+		}
+		if gitWebhookSecret = []byte(strings.TrimRight(string(b), "\r\n")); len(gitWebhookSecret) == 0 {
+			mainLogger.Log("error", "the git webhook secret is empty")
+			os.Exit(1)
+		}
+	}
+	// operators sharing the HelmReleases publish their release
+	// selector on the config map of the lease, with or without leader
+	// election
+	var leaseNamespace, identity string
+	if *leaderElection || *releaseSelector != "" {
+		leaseNamespace = *leaderElectionNamespace
+		if leaseNamespace == "" {
+			leaseNamespace = *namespace
+		}
+		if leaseNamespace == "" {
+			b, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+			if err != nil {
+				mainLogger.Log("error", "unable to determine the namespace of the leader election lease, set --leader-election-namespace")
+				os.Exit(1)
+			}
+			leaseNamespace = strings.TrimSpace(string(b))
+		}
+	}
+	if *leaderElection {
+		if *leaderElectionRenewDeadline >= *leaderElectionLeaseDuration {
+			mainLogger.Log("error", "the leader election renew deadline must be shorter than the lease duration")
+			os.Exit(1)
+		}
+		hostname, err := os.Hostname()
+		if err != nil {
+			mainLogger.Log("error", fmt.Sprintf("unable to determine the leader election identity: %v", err))
+			os.Exit(1)
+		}
+		identity = hostname
+	}
+	switch helmfluxv1.ReleaseNameStrategy(*releaseNameStrategy) {
+	case helmfluxv1.ReleaseNameStrategyDefault, helmfluxv1.ReleaseNameStrategyNamespaced:
+	default:
+		mainLogger.Log("error", fmt.Sprintf("invalid release name strategy: %q", *releaseNameStrategy))
+		os.Exit(1)
+	}
+	var releaseDefaults chartsync.ReleaseDefaults
+	if *releaseDefaultsFile != "" {
+		d, err := chartsync.ReadReleaseDefaults(*releaseDefaultsFile)
+		if err != nil {
+			mainLogger.Log("error", err.Error())
+			os.Exit(1)
+		}
+		releaseDefaults = d
+	}
+	var chartCacheSize int64
+	if *chartCacheMaxSize != "" {
+		q, err := resource.ParseQuantity(*chartCacheMaxSize)
+		if err != nil {
+			mainLogger.Log("error", fmt.Sprintf("invalid chart cache max size: %q", *chartCacheMaxSize))
+			os.Exit(1)
+		}
+		chartCacheSize = q.Value()
+	}
+	var chartSize int64
+	if *chartMaxSize != "" {
+		q, err := resource.ParseQuantity(*chartMaxSize)
+		if err != nil {
+			mainLogger.Log("error", fmt.Sprintf("invalid chart max size: %q", *chartMaxSize))
+			os.Exit(1)
+		}
+		chartSize = q.Value()
+	}
+
 	cfg, err := clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
 	if err != nil {
 		mainLogger.Log("error", fmt.Sprintf("error building kubeconfig: %v", err))
 		os.Exit(1)
 	}
+	// The rate limits apply to all clients built from the config,
+	// including the one used to tunnel to Tiller.
+	if *kubeAPIQPS > 0 {
+		cfg.QPS = *kubeAPIQPS
+	}
+	if *kubeAPIBurst > 0 {
+		cfg.Burst = *kubeAPIBurst
+	}
 
 	kubeClient, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
@@ -175,6 +429,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		mainLogger.Log("error", fmt.Sprintf("error building dynamic client: %v", err))
+		os.Exit(1)
+	}
+
 	helmClient := fluxhelm.ClientSetup(log.With(logger, "component", "helm"), kubeClient, fluxhelm.TillerOptions{
 		Host:        *tillerIP,
 		Port:        *tillerPort,
@@ -188,8 +448,13 @@ func main() {
 	})
 
 	// setup shared informer for HelmReleases
+	// with a release selector, only the HelmReleases matching it are
+	// watched and cached
 	nsOpt := ifinformers.WithNamespace(*namespace)
-	ifInformerFactory := ifinformers.NewSharedInformerFactoryWithOptions(ifClient, *chartsSyncInterval, nsOpt)
+	selectorOpt := ifinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+		opts.LabelSelector = *releaseSelector
+	})
+	ifInformerFactory := ifinformers.NewSharedInformerFactoryWithOptions(ifClient, *chartsSyncInterval, nsOpt, selectorOpt)
 	hrInformer := ifInformerFactory.Helm().V1().HelmReleases()
 
 	// setup workqueue for HelmReleases
@@ -197,18 +462,77 @@ func main() {
 
 	// release instance is needed during the sync of git chart changes
 	// and during the sync of HelmRelease changes
-	rel := release.New(log.With(logger, "component", "release"), helmClient)
+	rel := release.New(log.With(logger, "component", "release"), helmClient, kubeClient.Discovery(), helmfluxv1.ReleaseNameStrategy(*releaseNameStrategy))
 	chartSync := chartsync.New(
 		log.With(logger, "component", "chartsync"),
-		chartsync.Clients{KubeClient: *kubeClient, IfClient: *ifClient, HrLister: hrInformer.Lister()},
+		chartsync.Clients{KubeClient: *kubeClient, IfClient: *ifClient, HrLister: hrInformer.Lister(), DynamicClient: dynamicClient},
 		rel,
 		queue,
 		chartsync.Config{
-			LogDiffs:        *logReleaseDiffs,
-			UpdateDeps:      *updateDependencies,
-			GitTimeout:      *gitTimeout,
-			GitPollInterval: *gitPollInterval,
-			GitDefaultRef:   *gitDefaultRef,
+			LogDiffs:         *logReleaseDiffs,
+			DiffFormat:       *releaseDiffsFormat,
+			DiffContextLines: *releaseDiffsContext,
+			StalledThreshold: *stalledThreshold,
+			UpdateDeps:       *updateDependencies,
+			GitTimeout:       *gitTimeout,
+			GitPollInterval:  *gitPollInterval,
+			GitDefaultRef:    *gitDefaultRef,
+
+			DryRunReleasePrefix:   *dryRunReleasePrefix,
+			SkipDryRun:            *skipDryRun,
+			DryRunTimeout:         *dryRunTimeout,
+			DryRunNamespace:       *dryRunNamespace,
+			DivergedSpecRetries:   *divergedSpecRetries,
+			DryRunOnly:            *dryRunOnly,
+			UpgradeOnChartDigest:  *upgradeOnDigest,
+			PendingRecovery:       *pendingRecovery,
+			SkipSchemaValidation:  *skipSchemaValidation,
+			HealthStalenessWindow: *healthStaleness,
+			ChartRepoProxy:        *chartRepoProxy,
+			ChartRepoCAFile:       *chartRepoCAFile,
+			MirrorSyncWorkers:     *gitMirrorSyncWorkers,
+			MaxConcurrentHelmOps:  *maxConcurrentHelmOps,
+			FailureBackoff:        *failureBackoff,
+			FailureBackoffMax:     *failureBackoffMax,
+			SourceRequeueDelay:    *sourceRequeueDelay,
+			TrackResources:        *trackResources,
+			EstablishCRDs:         *establishCRDs,
+			ChartCacheMaxAge:      *chartCacheMaxAge,
+			ChartCacheMaxSize:     chartCacheSize,
+			ChartMaxSize:          chartSize,
+
+			ChartRepoCredentialsFile: *chartRepoCredentials,
+			DryRunClientSideFallback: *dryRunClientSide,
+			MirrorBreakerThreshold:   *gitBreakerThreshold,
+			MirrorBreakerCooldown:    *gitBreakerCooldown,
+			GitSSHConfigDir:          *gitSSHConfigDir,
+			GitCredentialsDir:        *gitCredentialsDir,
+			TillerNamespace:          *tillerNamespace,
+
+			ChartRepoCredentialsDefault: *chartRepoCredentialsDefault,
+
+			URLChartRefreshInterval: *urlChartRefresh,
+
+			AllowCrossNamespaceSourceRefs: *allowCrossNSSources,
+
+			AzureManagedIdentity:   *azureManagedIdentity,
+			AWSOperatorCredentials: *awsOperatorCredentials,
+			GCPOperatorCredentials: *gcpOperatorCredentials,
+
+			DependencyUpdateTimeout: *updateDepsTimeout,
+			AllowRenderRelease:      *allowRenderRelease,
+			AllowedTargetNamespaces: *allowTargetNamespaces,
+			DeniedTargetNamespaces:  *denyTargetNamespaces,
+			ReleaseSelector:         selector,
+			ReleaseTimeout:          *releaseTimeout,
+			ReleaseDefaults:         releaseDefaults,
+			LeaderElection: chartsync.LeaderElection{
+				LeaseNamespace: leaseNamespace,
+				LeaseName:      *leaderElectionLeaseName,
+				LeaseDuration:  *leaderElectionLeaseDuration,
+				RenewDeadline:  *leaderElectionRenewDeadline,
+				RetryPeriod:    *leaderElectionRetryPeriod,
+			},
 		},
 		*namespace,
 	)
@@ -218,34 +542,86 @@ func main() {
 	// _before_ starting it or else the cache sync seems to hang at
 	// random
 	opr := operator.New(log.With(logger, "component", "operator"), *logReleaseDiffs, kubeClient, hrInformer, queue, chartSync)
+	cacheSyncs := []cache.InformerSynced{hrInformer.Informer().HasSynced}
+	if *watchValuesSources {
+		kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, *chartsSyncInterval, kubeinformers.WithNamespace(*namespace))
+		cmInformer := kubeInformerFactory.Core().V1().ConfigMaps()
+		secretInformer := kubeInformerFactory.Core().V1().Secrets()
+		if err := opr.WatchValuesSources(hrInformer.Informer(), cmInformer, secretInformer); err != nil {
+			mainLogger.Log("error", fmt.Sprintf("error setting up watches for values sources: %v", err))
+			os.Exit(1)
+		}
+		go kubeInformerFactory.Start(shutdown)
+		cacheSyncs = append(cacheSyncs, cmInformer.Informer().HasSynced, secretInformer.Informer().HasSynced)
+	}
 	go ifInformerFactory.Start(shutdown)
 
 	// wait for the caches to be synced before starting _any_ workers
 	mainLogger.Log("info", "waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(shutdown, hrInformer.Informer().HasSynced); !ok {
+	if ok := cache.WaitForCacheSync(shutdown, cacheSyncs...); !ok {
 		mainLogger.Log("error", "failed to wait for caches to sync")
 		os.Exit(1)
 	}
 	mainLogger.Log("info", "informer caches synced")
 
-	// start operator
-	go opr.Run(*workers, shutdown, shutdownWg)
-
-	// start git sync loop
-	go chartSync.Run(shutdown, errc, shutdownWg)
-
 	// the status updater, to keep track of the release status for
 	// every HelmRelease
-	statusUpdater := status.New(ifClient, hrInformer.Lister(), helmClient)
-	go statusUpdater.Loop(shutdown, *statusUpdateInterval, log.With(logger, "component", "statusupdater"))
+	statusUpdater := status.New(ifClient, hrInformer.Lister(), helmClient, helmfluxv1.ReleaseNameStrategy(*releaseNameStrategy))
+	lead := func() {
+		// start operator
+		go opr.Run(*workers, shutdown, shutdownWg)
+
+		// start git sync loop
+		go chartSync.Run(shutdown, errc, shutdownWg)
+
+		go statusUpdater.Loop(shutdown, *statusUpdateInterval, log.With(logger, "component", "statusupdater"))
+
+		// start git push webhook receiver; standby replicas have no
+		// mirrors to sync
+		if *gitWebhookListenAddr != "" {
+			go gitwebhook.ListenAndServe(*gitWebhookListenAddr, gitWebhookSecret, chartSync, log.With(logger, "component", "gitwebhook"), shutdown)
+		}
+	}
+
+	// with leader election, only the leader reconciles; the lease is
+	// released once the in-flight releases finished on shutdown
+	electionCtx, stopElection := context.WithCancel(context.Background())
+	if *leaderElection {
+		mainLogger.Log("info", "standing by until elected leader", "lease", leaseNamespace+"/"+*leaderElectionLeaseName, "identity", identity)
+		if err := chartSync.RunLeaderElection(electionCtx, identity, lead, errc); err != nil {
+			mainLogger.Log("error", fmt.Sprintf("error setting up leader election: %v", err))
+			os.Exit(1)
+		}
+	} else {
+		lead()
+	}
 
 	// start HTTP server
 	go daemonhttp.ListenAndServe(*listenAddr, chartSync, log.With(logger, "component", "daemonhttp"), shutdown)
 
+	// start admission webhook server
+	if *webhookListenAddr != "" {
+		go webhook.ListenAndServeTLS(*webhookListenAddr, *webhookTLSCert, *webhookTLSKey, log.With(logger, "component", "webhook"), shutdown)
+	}
+
 	checkpoint.CheckForUpdates(product, version, nil, log.With(logger, "component", "checkpoint"))
 
 	shutdownErr := <-errc
 	logger.Log("exiting...", shutdownErr)
 	close(shutdown)
+
+	// give in-flight releases the chance to finish, so they are not
+	// left pending, unless another replica may have taken over them
+	if chartsync.IsLeadershipLost(shutdownErr) {
+		mainLogger.Log("warning", "lost leadership, exiting without waiting for in-flight releases")
+		os.Exit(1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownGracePeriod)
+	if err := chartSync.Shutdown(ctx); err != nil {
+		mainLogger.Log("warning", "in-flight releases did not finish within the shutdown grace period", "err", err)
+	}
+	cancel()
+	stopElection()
+
 	shutdownWg.Wait()
 }
@@ -3,6 +3,8 @@ module github.com/fluxcd/helm-operator
 go 1.12
 
 require (
+	github.com/Masterminds/semver v1.4.2
+	github.com/evanphx/json-patch v4.1.0+incompatible
 	github.com/fluxcd/flux v1.15.0
 	github.com/ghodss/yaml v1.0.0
 	github.com/go-kit/kit v0.9.0
@@ -11,10 +13,13 @@ require (
 	github.com/gorilla/mux v1.7.1
 	github.com/instrumenta/kubeval v0.0.0-20190804145309-805845b47dfc
 	github.com/ncabatoff/go-seq v0.0.0-20180805175032-b08ef85ed833
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/prometheus/client_golang v1.1.0
 	github.com/shurcooL/httpfs v0.0.0-20190707220628-8d4bc4ba7749
 	github.com/spf13/pflag v1.0.3
 	github.com/stretchr/testify v1.3.0
+	github.com/xeipuuv/gojsonschema v0.0.0-20180816142147-da425ebb7609
+	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
 	k8s.io/api v0.0.0-20190313235455-40a48860b5ab
 	k8s.io/apimachinery v0.0.0-20190404173353-6a84e37a896d
 	k8s.io/client-go v11.0.0+incompatible
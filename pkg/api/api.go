@@ -1,7 +1,39 @@
 package api
 
+import "errors"
+
+// ErrRenderReleaseDisabled is returned by RenderRelease and
+// DiffRelease when the server does not allow rendering releases.
+var ErrRenderReleaseDisabled = errors.New("rendering releases is disabled")
+
+// ReleaseDiff is the difference between the current release of a
+// HelmRelease and the release it would result in if released now, as
+// unified diffs; a diff is empty if there is no difference.
+type ReleaseDiff struct {
+	// Revision is the revision of the current release, zero if there
+	// is none.
+	Revision int32 `json:"revision"`
+	// Values is the diff of the values.
+	Values string `json:"values,omitempty"`
+	// Chart is the diff of the chart.
+	Chart string `json:"chart,omitempty"`
+	// Manifests is the diff of the rendered manifests, including
+	// those of the hooks.
+	Manifests string `json:"manifests,omitempty"`
+}
+
+// Changed returns if releasing the HelmRelease would change the
+// release.
+func (d ReleaseDiff) Changed() bool {
+	return d.Values != "" || d.Chart != "" || d.Manifests != ""
+}
+
 // Server is the interface that must be satisfied in order to serve
 // HTTP API requests.
 type Server interface {
 	SyncMirrors()
+	Healthy() error
+	Ready() error
+	RenderRelease(namespace, name string) (string, error)
+	DiffRelease(namespace, name string) (ReleaseDiff, error)
 }
@@ -2,7 +2,13 @@ package v1
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/ghodss/yaml"
 	"k8s.io/api/core/v1"
@@ -31,12 +37,59 @@ func (hr HelmRelease) ResourceID() resource.ID {
 	return resource.MakeID(hr.Namespace, "HelmRelease", hr.Name)
 }
 
+// ReleaseNameStrategy determines how the name of the release of a
+// HelmRelease is derived.
+type ReleaseNameStrategy string
+
+const (
+	// ReleaseNameStrategyDefault uses the configured release name
+	// as is, or generates one from the namespace and name.
+	ReleaseNameStrategyDefault ReleaseNameStrategy = "default"
+	// ReleaseNameStrategyNamespaced prefixes configured release
+	// names with the namespace of the HelmRelease, so that the
+	// releases of HelmReleases in different namespaces can not
+	// collide.
+	ReleaseNameStrategyNamespaced ReleaseNameStrategy = "namespaced"
+)
+
+// ConflictStrategy determines what is done when the release of a
+// HelmRelease turns out to belong to something else.
+type ConflictStrategy string
+
+const (
+	// ConflictStrategySkip leaves the release alone.
+	ConflictStrategySkip ConflictStrategy = "skip"
+	// ConflictStrategyAdopt takes ownership of the release, and
+	// upgrades it as if it always belonged to the HelmRelease.
+	ConflictStrategyAdopt ConflictStrategy = "adopt"
+	// ConflictStrategyFail leaves the release alone, and records a
+	// warning event for the HelmRelease.
+	ConflictStrategyFail ConflictStrategy = "fail"
+)
+
+// DefaultTimeout is the timeout in seconds of installs, upgrades,
+// rollbacks, tests and deletions that do not set one.
+const DefaultTimeout int64 = 300
+
+// ReconcileRequestAnnotation requests a HelmRelease to be reconciled
+// right away, whenever its value (e.g. the current time) changes.
+const ReconcileRequestAnnotation = "helm.fluxcd.io/reconcile-at"
+
 // ReleaseName returns the configured release name, or constructs and
 // returns one based on the namespace and name of the HelmRelease.
 // When the HelmRelease's metadata.namespace and spec.targetNamespace
 // differ, both are used in the generated name.
 // This name is used for naming and operating on the release in Helm.
 func (hr HelmRelease) ReleaseName() string {
+	return hr.ReleaseNameWith(ReleaseNameStrategyDefault)
+}
+
+// ReleaseNameWith returns the release name following the given
+// strategy; see ReleaseName for the default strategy. A release that
+// was named following another strategy, as recorded in the status,
+// keeps its name, so that switching strategies does not rename
+// existing releases.
+func (hr HelmRelease) ReleaseNameWith(strategy ReleaseNameStrategy) string {
 	if hr.Spec.ReleaseName == "" {
 		namespace := hr.GetDefaultedNamespace()
 		targetNamespace := hr.GetTargetNamespace()
@@ -48,6 +101,13 @@ func (hr HelmRelease) ReleaseName() string {
 		return fmt.Sprintf("%s-%s", targetNamespace, hr.Name)
 	}
 
+	namespaced := fmt.Sprintf("%s-%s", hr.GetDefaultedNamespace(), hr.Spec.ReleaseName)
+	if released := hr.Status.ReleaseName; released == hr.Spec.ReleaseName || released == namespaced {
+		return released
+	}
+	if strategy == ReleaseNameStrategyNamespaced {
+		return namespaced
+	}
 	return hr.Spec.ReleaseName
 }
 
@@ -69,6 +129,36 @@ func (hr HelmRelease) GetTargetNamespace() string {
 	return hr.Spec.TargetNamespace
 }
 
+// MaintenanceWindow is a recurring time range in which a release may
+// be upgraded.
+type MaintenanceWindow struct {
+	// The days of the week the window opens on (e.g. `Sat`),
+	// defaults to every day
+	// +optional
+	Days []string `json:"days,omitempty"`
+	// The time of day the window opens, as `HH:MM`
+	Start string `json:"start"`
+	// The time of day the window closes, as `HH:MM`; a window that
+	// closes before it opens spans midnight
+	End string `json:"end"`
+	// The time zone of the start and end times (e.g.
+	// `Europe/Amsterdam`), defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ValuesOverride overrides a single value, as with `helm --set` or
+// `helm --set-string`. Only one of its fields may be set.
+type ValuesOverride struct {
+	// A `key=value` entry, of which the value is coerced to the type
+	// it looks like
+	// +optional
+	Set string `json:"set,omitempty"`
+	// A `key=value` entry, of which the value is always a string
+	// +optional
+	SetString string `json:"setString,omitempty"`
+}
+
 // ValuesFromSource represents a source of values.
 // Only one of its fields may be set.
 type ValuesFromSource struct {
@@ -78,12 +168,52 @@ type ValuesFromSource struct {
 	// Selects a key of a Secret.
 	// +optional
 	SecretKeyRef *v1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// Selects several keys of a ConfigMap.
+	// +optional
+	ConfigMapKeysRef *ObjectKeysSelector `json:"configMapKeysRef,omitempty"`
+	// Selects several keys of a Secret.
+	// +optional
+	SecretKeysRef *ObjectKeysSelector `json:"secretKeysRef,omitempty"`
 	// Selects an URL.
 	// +optional
 	ExternalSourceRef *ExternalSourceSelector `json:"externalSourceRef,omitempty"`
 	// Selects a file from git source helm chart.
 	// +optional
 	ChartFileRef *ChartFileSelector `json:"chartFileRef,omitempty"`
+	// Takes only the values at this dot-separated path of the
+	// selected values, e.g. `database`.
+	// +optional
+	SourcePath string `json:"sourcePath,omitempty"`
+	// Places the taken values at this dot-separated path of the
+	// merged values, rather than at their root.
+	// +optional
+	TargetPath string `json:"targetPath,omitempty"`
+	// Keep the values of the selected Secret out of the values
+	// checksum and redact them from the logged diffs. Only for
+	// secretKeyRef and secretKeysRef.
+	// +optional
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// ObjectKeysSelector selects several keys of a ConfigMap or Secret,
+// of which the values are merged in the order of the keys or, if no
+// keys are given, of all keys in lexical order.
+type ObjectKeysSelector struct {
+	// Name of the object, in the namespace of the HelmRelease
+	Name string `json:"name"`
+	// +optional
+	Keys []KeySelector `json:"keys,omitempty"`
+	// Do not fail if the object does not exist
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
+}
+
+// KeySelector selects a key of an ObjectKeysSelector.
+type KeySelector struct {
+	Key string `json:"key"`
+	// Do not fail if the key does not exist
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
 }
 
 type ChartFileSelector struct {
@@ -106,33 +236,327 @@ type ChartSource struct {
 	*GitChartSource
 	// +optional
 	*RepoChartSource
+	// +optional
+	*ConfigMapChartSource
+	// +optional
+	*SourceRefChartSource
+	// +optional
+	*URLChartSource
+}
+
+// The kinds of source objects a SourceRefChartSource can refer to.
+const (
+	SourceKindGitRepository  = "GitRepository"
+	SourceKindHelmRepository = "HelmRepository"
+)
+
+// DefaultSourceAPIVersion is the API version of the source objects
+// a SourceRefChartSource refers to, when it does not specify one.
+const DefaultSourceAPIVersion = "source.toolkit.fluxcd.io/v1beta1"
+
+// SourceRefChartSource is a chart provided by a source object of the
+// Flux source-controller (a GitRepository or HelmRepository), rather
+// than by a source given inline.
+type SourceRefChartSource struct {
+	SourceRef ChartSourceReference `json:"sourceRef"`
+}
+
+// ChartSourceReference refers to a source object, and the chart it
+// provides.
+type ChartSourceReference struct {
+	// The API version of the source object, defaults to
+	// source.toolkit.fluxcd.io/v1beta1
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+	// The kind of the source object, GitRepository or HelmRepository
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// The namespace of the source object, defaults to the namespace
+	// of the HelmRelease
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// The path of the chart in the artifact of a GitRepository
+	// +optional
+	Path string `json:"path,omitempty"`
+	// The name of the chart in a HelmRepository
+	// +optional
+	Chart string `json:"chart,omitempty"`
+	// The version of the chart in a HelmRepository, or a semver range
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// APIVersionOrDefault returns the API version of the source object,
+// or the default if not set.
+func (r ChartSourceReference) APIVersionOrDefault() string {
+	if r.APIVersion == "" {
+		return DefaultSourceAPIVersion
+	}
+	return r.APIVersion
+}
+
+// NamespaceOrDefault returns the namespace of the source object, or
+// the given namespace of the HelmRelease if not set.
+func (r ChartSourceReference) NamespaceOrDefault(namespace string) string {
+	if r.Namespace == "" {
+		return namespace
+	}
+	return r.Namespace
+}
+
+// URLChartSource is a packaged chart (a `.tgz` archive) at a HTTP(S)
+// URL, for charts published to artifact stores without the index of
+// a Helm repo.
+type URLChartSource struct {
+	// The URL of the chart archive
+	URL string `json:"url"`
+	// The (hex encoded) SHA256 checksum the chart archive must have
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// DefaultConfigMapChartKey is the key of the chart archive in the
+// ConfigMap of a ConfigMapChartSource that does not specify one.
+const DefaultConfigMapChartKey = "chart.tgz"
+
+// ConfigMapChartSource is a chart archive embedded in a ConfigMap,
+// for small charts that do not warrant a Helm or Git repo.
+type ConfigMapChartSource struct {
+	ConfigMap ConfigMapChartRef `json:"configMap"`
+}
+
+// ConfigMapChartRef refers to a chart archive in a ConfigMap in the
+// namespace of the HelmRelease.
+type ConfigMapChartRef struct {
+	Name string `json:"name"`
+	// The key of the chart archive in the binary data (or data, when
+	// base64 encoded) of the ConfigMap, defaults to `chart.tgz`
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// KeyOrDefault returns the key of the chart archive in the
+// ConfigMap, or the default key if not specified.
+func (r ConfigMapChartRef) KeyOrDefault() string {
+	if r.Key == "" {
+		return DefaultConfigMapChartKey
+	}
+	return r.Key
 }
 
 type GitChartSource struct {
 	GitURL string `json:"git"`
-	Ref    string `json:"ref"`
-	Path   string `json:"path"`
+	// The branch to follow, or a semver range of tags to follow the
+	// highest matching tag of, as `semver:<range>`
+	Ref  string `json:"ref"`
+	Path string `json:"path"`
+	// Pin the chart to this tag, rather than following the head of
+	// the ref
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// Pin the chart to this commit, rather than following the head
+	// of the ref; takes precedence over the tag
+	// +optional
+	Commit string `json:"commit,omitempty"`
 	// Do not run 'dep' update (assume requirements.yaml is already fulfilled)
 	// +optional
 	SkipDepUpdate bool `json:"skipDepUpdate,omitempty"`
+	// Timeout in seconds for the 'dep' update, defaults to the
+	// timeout configured for the operator
+	// +optional
+	DepUpdateTimeout *int64 `json:"depUpdateTimeout,omitempty"`
+	// Credentials for the Helm repos the dependencies of the chart
+	// are fetched from by the 'dep' update
+	// +optional
+	DependencyRepos []DependencyRepo `json:"dependencyRepos,omitempty"`
+	// Initialise and update the submodules of the git repo
+	// +optional
+	RecurseSubmodules bool `json:"recurseSubmodules,omitempty"`
+	// Values files, relative to the root of the git repo, that are
+	// merged in order before the other values
+	// +optional
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+	// A secret with the SSH private key (`identity`), and optionally
+	// the `known_hosts`, to mirror the git repo with, rather than the
+	// identity of the operator
+	// +optional
+	SSHSecretRef *v1.LocalObjectReference `json:"sshSecretRef,omitempty"`
+	// A secret with the `appID`, `installationID` and `privateKey` of
+	// a GitHub App to mirror the git repo over HTTPS with, using
+	// installation tokens
+	// +optional
+	GitHubAppSecretRef *v1.LocalObjectReference `json:"githubAppSecretRef,omitempty"`
+}
+
+// DependencyRepo refers to the credentials for a Helm repo the
+// dependencies of a chart from git are fetched from.
+type DependencyRepo struct {
+	// The URL of the repo, as in the requirements of the chart
+	URL string `json:"url"`
+	// A secret with the `username` and `password` for the repo
+	SecretRef v1.LocalObjectReference `json:"secretRef"`
+}
+
+// ValuesFilePath returns the path of the given values file relative
+// to the chart, as chart files are read.
+func (s GitChartSource) ValuesFilePath(file string) (string, error) {
+	path, err := filepath.Rel(strings.TrimLeft(s.Path, "/"), strings.TrimLeft(file, "/"))
+	if err != nil {
+		return "", fmt.Errorf("values file %s can not be read relative to the chart: %s", file, err)
+	}
+	return path, nil
+}
+
+// Paths returns the paths in the git repo the release depends on:
+// the path of the chart, and the paths of the values files.
+func (s GitChartSource) Paths() []string {
+	paths := []string{s.Path}
+	for _, file := range s.ValuesFiles {
+		paths = append(paths, strings.TrimLeft(file, "/"))
+	}
+	return paths
+}
+
+// GetDepUpdateTimeout returns the timeout for the 'dep' update of the
+// chart, or the given default if not set.
+func (s GitChartSource) GetDepUpdateTimeout(defaultTimeout time.Duration) time.Duration {
+	if s.DepUpdateTimeout == nil {
+		return defaultTimeout
+	}
+	return time.Duration(*s.DepUpdateTimeout) * time.Second
 }
 
 // RefOrDefault returns the configured ref of the chart source. If the chart source
-// does not specify a ref, the provided default is used instead.
+// does not specify a ref, the provided default is used instead. A
+// pinned commit or tag takes precedence over the ref.
 func (s GitChartSource) RefOrDefault(defaultGitRef string) string {
+	if s.Commit != "" {
+		return s.Commit
+	}
+	if s.Tag != "" {
+		return "refs/tags/" + s.Tag
+	}
 	if s.Ref == "" {
 		return defaultGitRef
 	}
 	return s.Ref
 }
 
+// SemverRefPrefix prefixes the ref of a GitChartSource that is a
+// semver range of tags, e.g. `semver:~1.2`.
+const SemverRefPrefix = "semver:"
+
+// SemverRange returns the semver range of tags the chart source
+// follows, if its ref is one and it is not pinned to a tag or commit.
+func (s GitChartSource) SemverRange() (string, bool) {
+	if s.Commit != "" || s.Tag != "" || !strings.HasPrefix(s.Ref, SemverRefPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(s.Ref, SemverRefPrefix)), true
+}
+
+// IsSSH returns if the git repo is cloned over SSH, i.e. if the GitURL
+// has the `ssh` scheme or is in the scp-like `[user@]host:path` form.
+func (s GitChartSource) IsSSH() bool {
+	u := strings.ToLower(s.GitURL)
+	if i := strings.Index(u, "://"); i >= 0 {
+		scheme := u[:i]
+		return scheme == "ssh" || scheme == "git+ssh" || scheme == "ssh+git"
+	}
+	i := strings.Index(u, ":")
+	return i > 0 && !strings.Contains(u[:i], "/")
+}
+
+// hostnamePattern matches (DNS) hostnames.
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// SSHHost returns the host the git repo is cloned from over SSH,
+// without the user and port. The GitURL may not contain whitespace or
+// control characters, and the host has to be a hostname or an IP
+// address, as it ends up in an SSH config.
+func (s GitChartSource) SSHHost() (string, error) {
+	if strings.IndexFunc(s.GitURL, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return "", fmt.Errorf("git URL %q contains whitespace or control characters", s.GitURL)
+	}
+	var host string
+	if strings.Contains(s.GitURL, "://") {
+		u, err := url.Parse(s.GitURL)
+		if err != nil {
+			return "", err
+		}
+		host = u.Hostname()
+	} else {
+		// scp-like syntax, e.g. git@github.com:org/repo.git
+		i := strings.Index(s.GitURL, ":")
+		if i < 1 {
+			return "", fmt.Errorf("no host in git URL %q", s.GitURL)
+		}
+		host = s.GitURL[:i]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+	}
+	if net.ParseIP(host) == nil && !hostnamePattern.MatchString(host) {
+		return "", fmt.Errorf("invalid host %q in git URL", host)
+	}
+	return host, nil
+}
+
+// IsHTTPS returns if the git repo is cloned over HTTPS, i.e. if the
+// GitURL has the `https` scheme.
+func (s GitChartSource) IsHTTPS() bool {
+	return strings.HasPrefix(strings.ToLower(s.GitURL), "https://")
+}
+
 type RepoChartSource struct {
 	RepoURL string `json:"repository"`
 	Name    string `json:"name"`
 	Version string `json:"version"`
+	// The path of a chart within the fetched chart to release
+	// instead, e.g. a subchart of an umbrella chart
+	// +optional
+	Subpath string `json:"subpath,omitempty"`
 	// An authentication secret for accessing the chart repo
 	// +optional
 	ChartPullSecret *v1.LocalObjectReference `json:"chartPullSecret,omitempty"`
+	// Selects a key of a Secret holding the PEM encoded CA
+	// certificate(s) to trust for the chart repo
+	// +optional
+	CASecretRef *v1.SecretKeySelector `json:"caSecretRef,omitempty"`
+	// A secret with the client certificate (`tls.crt`, `tls.key`,
+	// and optionally `ca.crt`) to present to the chart repo
+	// +optional
+	CertSecretRef *v1.LocalObjectReference `json:"certSecretRef,omitempty"`
+	// Authenticate to the chart repo with a bearer token fetched
+	// from an OAuth2 token endpoint
+	// +optional
+	TokenAuth *RepoTokenAuth `json:"tokenAuth,omitempty"`
+	// A secret with the credentials to log in to the OCI registry
+	// the chart is pulled from, as a `.dockerconfigjson` (e.g. an
+	// image pull secret) or a `username` and `password`
+	// +optional
+	RegistrySecretRef *v1.LocalObjectReference `json:"registrySecretRef,omitempty"`
+	// A secret with the credentials for the bucket the chart repo
+	// is kept in: an `accessKeyID` and `secretAccessKey` (and
+	// optionally a `sessionToken`, `region` and `endpoint`) for S3,
+	// a `serviceAccountKey` for GCS, or a `sasToken` (or
+	// `managedIdentity`/`clientID`, and an `endpoint`) for Azure
+	// +optional
+	BucketSecretRef *v1.LocalObjectReference `json:"bucketSecretRef,omitempty"`
+}
+
+// RepoTokenAuth configures the OAuth2 token endpoint to fetch a
+// bearer token for a chart repo from, with the client credentials
+// grant.
+type RepoTokenAuth struct {
+	// The URL of the token endpoint
+	TokenURL string `json:"tokenURL"`
+	// A secret with the `clientID` and `clientSecret` to request
+	// the token with
+	ClientSecretRef v1.LocalObjectReference `json:"clientSecretRef"`
+	// The scopes to request the token for
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // CleanRepoURL returns the RepoURL but ensures it ends with a trailing slash
@@ -141,22 +565,265 @@ func (s RepoChartSource) CleanRepoURL() string {
 	return cleanURL + "/"
 }
 
+// IsOCI returns if the chart is pulled from an OCI registry rather
+// than downloaded from a Helm repo, i.e. if the RepoURL has the
+// `oci` scheme.
+func (s RepoChartSource) IsOCI() bool {
+	return strings.HasPrefix(strings.ToLower(s.RepoURL), "oci://")
+}
+
+// IsBucket returns if the chart repo is kept in the bucket of a cloud
+// storage service, i.e. if the RepoURL has the `s3`, `gs` or `azblob`
+// scheme.
+func (s RepoChartSource) IsBucket() bool {
+	u := strings.ToLower(s.RepoURL)
+	return strings.HasPrefix(u, "s3://") || strings.HasPrefix(u, "gs://") || strings.HasPrefix(u, "azblob://")
+}
+
+// Rollback configures the rollback of failed upgrades. The booleans
+// are pointers, so that a HelmRelease that sets them to false can be
+// told apart from one that inherits the release defaults.
 type Rollback struct {
-	Enable       bool   `json:"enable,omitempty"`
-	Force        bool   `json:"force,omitempty"`
-	Recreate     bool   `json:"recreate,omitempty"`
-	DisableHooks bool   `json:"disableHooks,omitempty"`
+	Enable       *bool  `json:"enable,omitempty"`
+	Force        *bool  `json:"force,omitempty"`
+	Recreate     *bool  `json:"recreate,omitempty"`
+	DisableHooks *bool  `json:"disableHooks,omitempty"`
 	Timeout      *int64 `json:"timeout,omitempty"`
-	Wait         bool   `json:"wait,omitempty"`
+	Wait         *bool  `json:"wait,omitempty"`
+	// Do not roll back upgrades that failed with any of these
+	// reasons (of the Released condition)
+	// +optional
+	DisableOnReasons []string `json:"disableOnReasons,omitempty"`
+	// The number of times a failed upgrade of the same generation is
+	// attempted again after it was rolled back, negative for no limit
+	// (defaults to 5)
+	// +optional
+	MaxRetries *int64 `json:"maxRetries,omitempty"`
+}
+
+// DisabledFor returns if rollbacks are disabled for upgrades that
+// failed with the given reason.
+func (r Rollback) DisabledFor(reason string) bool {
+	for _, disabled := range r.DisableOnReasons {
+		if disabled == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled returns if failed upgrades are rolled back.
+func (r Rollback) Enabled() bool {
+	return r.Enable != nil && *r.Enable
+}
+
+// GetForce returns if resources are replaced on rollback.
+func (r Rollback) GetForce() bool {
+	return r.Force != nil && *r.Force
+}
+
+// GetRecreate returns if pods are recreated on rollback.
+func (r Rollback) GetRecreate() bool {
+	return r.Recreate != nil && *r.Recreate
+}
+
+// GetDisableHooks returns if the hooks of the chart are not run on
+// rollback.
+func (r Rollback) GetDisableHooks() bool {
+	return r.DisableHooks != nil && *r.DisableHooks
+}
+
+// GetWait returns if a rollback waits for the resources to be ready.
+func (r Rollback) GetWait() bool {
+	return r.Wait != nil && *r.Wait
 }
 
 func (r Rollback) GetTimeout() int64 {
 	if r.Timeout == nil {
-		return 300
+		return DefaultTimeout
 	}
 	return *r.Timeout
 }
 
+// GetMaxRetries returns the number of times a failed upgrade is
+// attempted again after it was rolled back (defaults to 5).
+func (r Rollback) GetMaxRetries() int64 {
+	if r.MaxRetries == nil {
+		return 5
+	}
+	return *r.MaxRetries
+}
+
+// Test configures the tests run after the release of a chart.
+type Test struct {
+	// Run the tests of the chart after a successful install or
+	// upgrade
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+	// Do not roll back the release when the tests fail, only report
+	// the failure
+	// +optional
+	IgnoreFailures bool `json:"ignoreFailures,omitempty"`
+	// Timeout in seconds for the tests to complete
+	// +optional
+	Timeout *int64 `json:"timeout,omitempty"`
+}
+
+// GetTimeout returns the timeout of the tests (defaults to 300s)
+func (t Test) GetTimeout() int64 {
+	if t.Timeout == nil {
+		return DefaultTimeout
+	}
+	return *t.Timeout
+}
+
+// Uninstall configures the deletion of a release.
+type Uninstall struct {
+	// Keep the history of the release, rather than purging it, so
+	// that the release name remains in use
+	// +optional
+	KeepHistory bool `json:"keepHistory,omitempty"`
+	// Do not run the hooks of the chart on deletion
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+	// Timeout in seconds for the deletion to complete
+	// +optional
+	Timeout *int64 `json:"timeout,omitempty"`
+}
+
+// GetTimeout returns the timeout of the deletion (defaults to 300s)
+func (u Uninstall) GetTimeout() int64 {
+	if u.Timeout == nil {
+		return DefaultTimeout
+	}
+	return *u.Timeout
+}
+
+// Verify configures the verification of the provenance of a chart.
+type Verify struct {
+	// Selects a key of a Secret holding the (GPG) keyring to verify
+	// the provenance file of the chart against
+	KeyringSecretRef v1.SecretKeySelector `json:"keyringSecretRef"`
+}
+
+// Upgrade configures the upgrade of a release. The booleans are
+// pointers, so that a HelmRelease that sets them to false can be told
+// apart from one that inherits the release defaults.
+type Upgrade struct {
+	// Upgrade the release once it is installed (defaults to true);
+	// when false, the release is installed but never upgraded
+	// +optional
+	Enable *bool `json:"enable,omitempty"`
+	// Force resource updates through replacement (delete/recreate),
+	// allows recovery from changes to immutable fields
+	// +optional
+	Force *bool `json:"force,omitempty"`
+	// Merge the values onto the values of the current release,
+	// rather than replacing them
+	// +optional
+	ReuseValues *bool `json:"reuseValues,omitempty"`
+	// Reset the values to the values of the chart, takes precedence
+	// over ReuseValues
+	// +optional
+	ResetValues *bool `json:"resetValues,omitempty"`
+	// Delete the resources newly created by a failed upgrade
+	// +optional
+	CleanupOnFail *bool `json:"cleanupOnFail,omitempty"`
+	// Restart the pods of the release by recreating them, even when
+	// their spec did not change
+	// +optional
+	RecreatePods *bool `json:"recreatePods,omitempty"`
+	// Do not run the hooks of the chart on upgrade
+	// +optional
+	DisableHooks *bool `json:"disableHooks,omitempty"`
+	// Decide to upgrade on changes to the HelmRelease, the chart
+	// revision and the values alone, rather than on the outcome of
+	// a dry run
+	// +optional
+	SkipDryRun *bool `json:"skipDryRun,omitempty"`
+}
+
+// Enabled returns if the release should be upgraded (defaults to
+// true).
+func (u Upgrade) Enabled() bool {
+	return u.Enable == nil || *u.Enable
+}
+
+// GetForce returns if resources are replaced when they can not be updated on upgrade.
+func (u Upgrade) GetForce() bool {
+	return u.Force != nil && *u.Force
+}
+
+// GetReuseValues returns if the values are merged onto those of the current release.
+func (u Upgrade) GetReuseValues() bool {
+	return u.ReuseValues != nil && *u.ReuseValues
+}
+
+// GetResetValues returns if the values are reset to those of the chart on upgrade.
+func (u Upgrade) GetResetValues() bool {
+	return u.ResetValues != nil && *u.ResetValues
+}
+
+// GetCleanupOnFail returns if the resources created by a failed upgrade are deleted.
+func (u Upgrade) GetCleanupOnFail() bool {
+	return u.CleanupOnFail != nil && *u.CleanupOnFail
+}
+
+// GetRecreatePods returns if the pods of the release are recreated on upgrade.
+func (u Upgrade) GetRecreatePods() bool {
+	return u.RecreatePods != nil && *u.RecreatePods
+}
+
+// GetDisableHooks returns if the hooks of the chart are not run on upgrade.
+func (u Upgrade) GetDisableHooks() bool {
+	return u.DisableHooks != nil && *u.DisableHooks
+}
+
+// GetSkipDryRun returns if upgrades are decided on without a dry run.
+func (u Upgrade) GetSkipDryRun() bool {
+	return u.SkipDryRun != nil && *u.SkipDryRun
+}
+
+// PostRenderer passes the manifests rendered from the chart through
+// a transformation before they are applied.
+// Only one of its fields may be set.
+type PostRenderer struct {
+	// Kustomize patches to apply to the rendered manifests
+	// +optional
+	Kustomize *KustomizePostRenderer `json:"kustomize,omitempty"`
+}
+
+// KustomizePostRenderer patches rendered manifests in the way
+// the `patches` of a kustomization do.
+type KustomizePostRenderer struct {
+	Patches []KustomizePatch `json:"patches,omitempty"`
+}
+
+type KustomizePatch struct {
+	// A strategic merge patch, or a list of JSON 6902 patch operations,
+	// in YAML
+	Patch string `json:"patch"`
+	// Select the resources to patch, defaults to the resource
+	// identified by the (strategic merge) patch
+	// +optional
+	Target *PatchTarget `json:"target,omitempty"`
+}
+
+// PatchTarget selects resources by their group, version, kind,
+// name and namespace; fields which are empty match anything.
+type PatchTarget struct {
+	// +optional
+	Group string `json:"group,omitempty"`
+	// +optional
+	Version string `json:"version,omitempty"`
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// +optional
+	Name string `json:"name,omitempty"`
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
 // HelmReleaseSpec is the spec for a HelmRelease resource
 type HelmReleaseSpec struct {
 	ChartSource      `json:"chart"`
@@ -164,34 +831,187 @@ type HelmReleaseSpec struct {
 	ValueFileSecrets []v1.LocalObjectReference `json:"valueFileSecrets,omitempty"`
 	ValuesFrom       []ValuesFromSource        `json:"valuesFrom,omitempty"`
 	HelmValues       `json:",inline"`
+	// Run the values through a Go template before they are merged,
+	// with the metadata of the HelmRelease
+	// +optional
+	ValuesTemplate bool `json:"valuesTemplate,omitempty"`
+	// Overrides of single values, merged after all other values
+	// +optional
+	ValuesOverrides []ValuesOverride `json:"valuesOverrides,omitempty"`
+	// Dot-separated paths of values that are left out when comparing
+	// the values of the release with the desired values
+	// +optional
+	IgnoreValues []string `json:"ignoreValues,omitempty"`
 	// Override the target namespace, defaults to metadata.namespace
 	// +optional
 	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// The service account (in metadata.namespace) the rendered
+	// resources of the release are reviewed against before it is
+	// installed or upgraded. The review is advisory: Tiller applies
+	// the release with its own credentials
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 	// Install or upgrade timeout in seconds
 	// +optional
 	Timeout *int64 `json:"timeout,omitempty"`
+	// The number of revisions of the release that are kept, the
+	// deployed one included; older revisions are deleted from the
+	// storage of Tiller after an install or upgrade (0, the default,
+	// keeps them all)
+	// +optional
+	MaxHistory *int64 `json:"maxHistory,omitempty"`
+	// Deprecated: use Upgrade.ResetValues instead
 	// Reset values on helm upgrade
 	// +optional
 	ResetValues bool `json:"resetValues,omitempty"`
+	// Deprecated: use Upgrade.Force instead
 	// Force resource update through delete/recreate, allows recovery from a failed state
 	// +optional
 	ForceUpgrade bool `json:"forceUpgrade,omitempty"`
+	// Do not install the CRDs of the chart (`crd-install` hooks)
+	// +optional
+	SkipCRDs bool `json:"skipCRDs,omitempty"`
+	// Do not run the hooks of the chart on install and upgrade
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+	// Do not validate the values against the JSON schema
+	// (`values.schema.json`) of the chart
+	// +optional
+	SkipSchemaValidation bool `json:"skipSchemaValidation,omitempty"`
+	// Verify the provenance of the chart before installing or
+	// upgrading, only supported for charts from Helm repos
+	// +optional
+	Verify *Verify `json:"verify,omitempty"`
+	// Compare the resources of the release with their live state in
+	// the cluster, and apply them again when they drifted
+	// +optional
+	DriftDetection bool `json:"driftDetection,omitempty"`
+	// The resources drift detection considers, selected the way the
+	// targets of patches are; without any, it considers all
+	// +optional
+	DriftDetectionInclude []PatchTarget `json:"driftDetectionInclude,omitempty"`
+	// The resources drift detection leaves alone, e.g. because other
+	// controllers change them
+	// +optional
+	DriftDetectionExclude []PatchTarget `json:"driftDetectionExclude,omitempty"`
+	// Upgrade the release only while one of these windows is open
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// Install the release while none of the maintenance windows is
+	// open, rather than waiting for one to open
+	// +optional
+	InstallOutsideMaintenanceWindows bool `json:"installOutsideMaintenanceWindows,omitempty"`
+	// Configure upgrade options
+	// +optional
+	Upgrade Upgrade `json:"upgrade,omitempty"`
 	// Enable rollback and configure options
 	// +optional
 	Rollback Rollback `json:"rollback,omitempty"`
+	// Run the tests of the chart after the release and configure
+	// options
+	// +optional
+	Test Test `json:"test,omitempty"`
+	// Configure the deletion of the release
+	// +optional
+	Uninstall Uninstall `json:"uninstall,omitempty"`
+	// HelmReleases (as `namespace/name`, or `name` for the same
+	// namespace) that must be released before this one is
+	// installed or upgraded
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// What to do when the release belongs to something else than
+	// this HelmRelease: skip (the default), adopt, or fail
+	// +optional
+	ConflictStrategy ConflictStrategy `json:"conflictStrategy,omitempty"`
+	// Post-renderers to pass the rendered manifests through before
+	// they are applied, in the order given
+	// +optional
+	PostRenderers []PostRenderer `json:"postRenderers,omitempty"`
+	// Labels to add to all resources of the release
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// Create the target namespace if it does not exist
+	// +optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+	// Labels and annotations for the target namespace, when it is
+	// created
+	// +optional
+	NamespaceMetadata NamespaceMetadata `json:"namespaceMetadata,omitempty"`
+	// Log the reconciliation of this HelmRelease verbosely, with the
+	// rendered manifests and the diffs of the release
+	// +optional
+	Debug bool `json:"debug,omitempty"`
+}
+
+type NamespaceMetadata struct {
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // GetTimeout returns the install or upgrade timeout (defaults to 300s)
 func (hr HelmRelease) GetTimeout() int64 {
 	if hr.Spec.Timeout == nil {
-		return 300
+		return DefaultTimeout
 	}
 	return *hr.Spec.Timeout
 }
 
+// GetMaxHistory returns the number of revisions of the release that
+// are kept (defaults to 0, all of them).
+func (hr HelmRelease) GetMaxHistory() int64 {
+	if hr.Spec.MaxHistory == nil {
+		return 0
+	}
+	return *hr.Spec.MaxHistory
+}
+
+// GetTimeoutOr returns the install or upgrade timeout, or the given
+// default if not set.
+func (hr HelmRelease) GetTimeoutOr(defaultTimeout time.Duration) time.Duration {
+	if hr.Spec.Timeout == nil {
+		return defaultTimeout
+	}
+	return time.Duration(*hr.Spec.Timeout) * time.Second
+}
+
+// ReconcileRequested returns the value of the reconcile request
+// annotation, and if the request has not been handled yet.
+func (hr HelmRelease) ReconcileRequested() (string, bool) {
+	requestedAt := hr.Annotations[ReconcileRequestAnnotation]
+	return requestedAt, requestedAt != "" && requestedAt != hr.Status.LastHandledReconcileAt
+}
+
+// GetConflictStrategy returns the configured conflict strategy,
+// defaulting to skip if not set.
+func (hr HelmRelease) GetConflictStrategy() ConflictStrategy {
+	if hr.Spec.ConflictStrategy == "" {
+		return ConflictStrategySkip
+	}
+	return hr.Spec.ConflictStrategy
+}
+
+// GetDependsOn returns the HelmReleases this HelmRelease depends on
+// as `namespace/name` keys, defaulting the namespace of a dependency
+// to the namespace of the HelmRelease if not set.
+func (hr HelmRelease) GetDependsOn() []string {
+	var deps []string
+	for _, dep := range hr.Spec.DependsOn {
+		if !strings.Contains(dep, "/") {
+			dep = hr.GetDefaultedNamespace() + "/" + dep
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
 // GetValuesFromSources maintains backwards compatibility with
-// ValueFileSecrets by merging them into the ValuesFrom array.
-func (hr HelmRelease) GetValuesFromSources() []ValuesFromSource {
+// ValueFileSecrets by merging them into the ValuesFrom array. The
+// values files of a git chart source are prepended as chart files;
+// if one of them can not be, the other sources are returned with the
+// error.
+func (hr HelmRelease) GetValuesFromSources() ([]ValuesFromSource, error) {
 	valuesFrom := hr.Spec.ValuesFrom
 	// Maintain backwards compatibility with ValueFileSecrets
 	if hr.Spec.ValueFileSecrets != nil {
@@ -202,7 +1022,18 @@ func (hr HelmRelease) GetValuesFromSources() []ValuesFromSource {
 		}
 		valuesFrom = append(secretKeyRefs, valuesFrom...)
 	}
-	return valuesFrom
+	if hr.Spec.GitChartSource != nil && len(hr.Spec.GitChartSource.ValuesFiles) > 0 {
+		var chartFileRefs []ValuesFromSource
+		for _, file := range hr.Spec.GitChartSource.ValuesFiles {
+			path, err := hr.Spec.GitChartSource.ValuesFilePath(file)
+			if err != nil {
+				return valuesFrom, err
+			}
+			chartFileRefs = append(chartFileRefs, ValuesFromSource{ChartFileRef: &ChartFileSelector{Path: path}})
+		}
+		valuesFrom = append(chartFileRefs, valuesFrom...)
+	}
+	return valuesFrom, nil
 }
 
 type HelmReleaseStatus struct {
@@ -227,6 +1058,80 @@ type HelmReleaseStatus struct {
 	// +optional
 	Revision string `json:"revision,omitempty"`
 
+	// LastSuccessfulRevision is the revision of the Helm release
+	// that was last installed or upgraded successfully.
+	// +optional
+	LastSuccessfulRevision int32 `json:"lastSuccessfulRevision,omitempty"`
+
+	// PreviousRevision is the revision of the Helm release that was
+	// successfully installed or upgraded before the last successful
+	// one.
+	// +optional
+	PreviousRevision int32 `json:"previousRevision,omitempty"`
+
+	// LastReconcileTime is the time the Helm release was last
+	// installed or upgraded successfully.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// HelmVersion is the version of Helm (Tiller) that last
+	// installed or upgraded the Helm release successfully.
+	// +optional
+	HelmVersion string `json:"helmVersion,omitempty"`
+
+	// ChartDigest is the SHA256 digest of the chart archive that was
+	// last installed or upgraded successfully, for charts that are
+	// fetched as an archive.
+	// +optional
+	ChartDigest string `json:"chartDigest,omitempty"`
+
+	// DryRunMode is how the last dry run to determine if the release
+	// should be upgraded was done: Server, Namespace (in the dry run
+	// namespace of the operator), or ClientSide.
+	// +optional
+	DryRunMode string `json:"dryRunMode,omitempty"`
+
+	// LastHandledReconcileAt is the value of the reconcile request
+	// annotation that was last handled.
+	// +optional
+	LastHandledReconcileAt string `json:"lastHandledReconcileAt,omitempty"`
+
+	// Failures is the number of consecutive times the release has
+	// failed with the same reason.
+	// +optional
+	Failures int64 `json:"failures,omitempty"`
+
+	// BackoffDelay is the delay before the release is attempted
+	// again after failing.
+	// +optional
+	BackoffDelay *metav1.Duration `json:"backoffDelay,omitempty"`
+
+	// RollbackCount is the number of consecutive failed upgrades of
+	// RollbackGeneration, RollbackRevision and RollbackValuesChecksum
+	// that were rolled back.
+	// +optional
+	RollbackCount int64 `json:"rollbackCount,omitempty"`
+
+	// RollbackGeneration is the generation of the HelmRelease of
+	// which the failed upgrades were counted in RollbackCount.
+	// +optional
+	RollbackGeneration int64 `json:"rollbackGeneration,omitempty"`
+
+	// RollbackRevision is the chart revision of which the failed
+	// upgrades were counted in RollbackCount.
+	// +optional
+	RollbackRevision string `json:"rollbackRevision,omitempty"`
+
+	// RollbackValuesChecksum is the checksum of the values of which
+	// the failed upgrades were counted in RollbackCount.
+	// +optional
+	RollbackValuesChecksum string `json:"rollbackValuesChecksum,omitempty"`
+
+	// History records the outcomes of the most recent actions taken
+	// on the release, oldest first.
+	// +optional
+	History []HelmReleaseHistoryEntry `json:"history,omitempty"`
+
 	// Conditions contains observations of the resource's state, e.g.,
 	// has the chart which it refers to been fetched.
 	// +optional
@@ -235,6 +1140,36 @@ type HelmReleaseStatus struct {
 	Conditions []HelmReleaseCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
+// HelmReleaseAction is an action taken on the release of a
+// HelmRelease.
+type HelmReleaseAction string
+
+const (
+	HelmReleaseActionInstall  HelmReleaseAction = "install"
+	HelmReleaseActionUpgrade  HelmReleaseAction = "upgrade"
+	HelmReleaseActionRollback HelmReleaseAction = "rollback"
+	// HelmReleaseActionAdopt means a release that did not belong to
+	// the HelmRelease was adopted, see ConflictStrategyAdopt
+	HelmReleaseActionAdopt HelmReleaseAction = "adopt"
+	// HelmReleaseActionSkip means an install, upgrade or rollback
+	// was skipped, e.g. because it was deferred
+	HelmReleaseActionSkip HelmReleaseAction = "skip"
+)
+
+// HelmReleaseHistoryEntry records the outcome of an action taken on
+// the release of a HelmRelease.
+type HelmReleaseHistoryEntry struct {
+	Action HelmReleaseAction `json:"action"`
+	// The reason of the outcome, as given in the condition it set
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// The Git hash or chart version the action was taken with
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// The time the action was taken, or last repeated
+	Time metav1.Time `json:"time"`
+}
+
 type HelmReleaseCondition struct {
 	Type   HelmReleaseConditionType `json:"type"`
 	Status v1.ConditionStatus       `json:"status"`
@@ -246,6 +1181,11 @@ type HelmReleaseCondition struct {
 	Reason string `json:"reason,omitempty"`
 	// +optional
 	Message string `json:"message,omitempty"`
+	// ErrorCategory is the category of the error a failed install or
+	// upgrade gave: network, auth, validation, template, timeout, or
+	// unknown
+	// +optional
+	ErrorCategory string `json:"errorCategory,omitempty"`
 }
 
 type HelmReleaseConditionType string
@@ -260,6 +1200,27 @@ const (
 	// RolledBack means the chart to which the HelmRelease refers
 	// has been rolled back
 	HelmReleaseRolledBack HelmReleaseConditionType = "RolledBack"
+	// Tested means the tests of the chart have been run against the
+	// release, after it was installed or upgraded
+	HelmReleaseTested HelmReleaseConditionType = "Tested"
+	// Deleted means the chart release of the HelmRelease has been
+	// deleted, as the HelmRelease is being deleted
+	HelmReleaseDeleted HelmReleaseConditionType = "Deleted"
+	// Ready means the chart has been fetched and released, as
+	// specified in the current generation of the HelmRelease, and
+	// has not been rolled back since
+	HelmReleaseReady HelmReleaseConditionType = "Ready"
+	// Stalled means the release has failed with the same reason a
+	// number of consecutive times, and is not expected to recover
+	// without intervention
+	HelmReleaseStalled HelmReleaseConditionType = "Stalled"
+	// DriftCorrected means the resources of the release that drifted
+	// from its manifests in the cluster have been applied again
+	HelmReleaseDriftCorrected HelmReleaseConditionType = "DriftCorrected"
+	// PendingRecovered means the release, which was stuck with an
+	// install or upgrade pending, has been rolled back or deleted so
+	// it could be released again
+	HelmReleasePendingRecovered HelmReleaseConditionType = "PendingRecovered"
 )
 
 // FluxHelmValues embeds chartutil.Values so we can implement deepcopy on map[string]interface{}
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestHelmValues(t *testing.T) {
@@ -69,6 +71,23 @@ func TestRefOrDefault(t *testing.T) {
 			potentialDefault: "dev",
 			expected:         "dev",
 		},
+		{
+			chartSource: GitChartSource{
+				Ref: "master",
+				Tag: "v1.0.0",
+			},
+			potentialDefault: "dev",
+			expected:         "refs/tags/v1.0.0",
+		},
+		{
+			chartSource: GitChartSource{
+				Ref:    "master",
+				Tag:    "v1.0.0",
+				Commit: "4f8a0b1",
+			},
+			potentialDefault: "dev",
+			expected:         "4f8a0b1",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -76,3 +95,112 @@ func TestRefOrDefault(t *testing.T) {
 		assert.Equal(t, tc.expected, got)
 	}
 }
+
+func TestReleaseNameWith(t *testing.T) {
+	testCases := []struct {
+		hr       HelmRelease
+		strategy ReleaseNameStrategy
+		expected string
+	}{
+		{
+			hr:       HelmRelease{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "podinfo"}},
+			strategy: ReleaseNameStrategyNamespaced,
+			expected: "team-podinfo",
+		},
+		{
+			hr: HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "podinfo"},
+				Spec:       HelmReleaseSpec{ReleaseName: "frontend"},
+			},
+			strategy: ReleaseNameStrategyDefault,
+			expected: "frontend",
+		},
+		{
+			hr: HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "podinfo"},
+				Spec:       HelmReleaseSpec{ReleaseName: "frontend"},
+			},
+			strategy: ReleaseNameStrategyNamespaced,
+			expected: "team-frontend",
+		},
+		{
+			// Released before the strategy was switched
+			hr: HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "podinfo"},
+				Spec:       HelmReleaseSpec{ReleaseName: "frontend"},
+				Status:     HelmReleaseStatus{ReleaseName: "frontend"},
+			},
+			strategy: ReleaseNameStrategyNamespaced,
+			expected: "frontend",
+		},
+		{
+			hr: HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "podinfo"},
+				Spec:       HelmReleaseSpec{ReleaseName: "frontend"},
+				Status:     HelmReleaseStatus{ReleaseName: "team-frontend"},
+			},
+			strategy: ReleaseNameStrategyDefault,
+			expected: "team-frontend",
+		},
+		{
+			// Changing the release name does rename it
+			hr: HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "podinfo"},
+				Spec:       HelmReleaseSpec{ReleaseName: "web"},
+				Status:     HelmReleaseStatus{ReleaseName: "frontend"},
+			},
+			strategy: ReleaseNameStrategyNamespaced,
+			expected: "team-web",
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.hr.ReleaseNameWith(tc.strategy))
+	}
+}
+
+func TestReconcileRequested(t *testing.T) {
+	hr := HelmRelease{}
+	_, requested := hr.ReconcileRequested()
+	assert.False(t, requested)
+
+	hr.Annotations = map[string]string{ReconcileRequestAnnotation: "1571212800"}
+	requestedAt, requested := hr.ReconcileRequested()
+	assert.True(t, requested)
+	assert.Equal(t, "1571212800", requestedAt)
+
+	hr.Status.LastHandledReconcileAt = requestedAt
+	_, requested = hr.ReconcileRequested()
+	assert.False(t, requested)
+}
+
+func TestGetValuesFromSources(t *testing.T) {
+	hr := HelmRelease{
+		Spec: HelmReleaseSpec{
+			ChartSource: ChartSource{
+				GitChartSource: &GitChartSource{
+					Path:        "charts/app",
+					ValuesFiles: []string{"environments/values-prod.yaml", "/charts/app/values-extra.yaml"},
+				},
+			},
+			ValueFileSecrets: []v1.LocalObjectReference{{Name: "secret"}},
+			ValuesFrom:       []ValuesFromSource{{ChartFileRef: &ChartFileSelector{Path: "values-dev.yaml"}}},
+		},
+	}
+
+	sources, err := hr.GetValuesFromSources()
+	assert.NoError(t, err)
+	assert.Len(t, sources, 4)
+	assert.Equal(t, "../../environments/values-prod.yaml", sources[0].ChartFileRef.Path)
+	assert.Equal(t, "values-extra.yaml", sources[1].ChartFileRef.Path)
+	assert.Equal(t, "secret", sources[2].SecretKeyRef.Name)
+	assert.Equal(t, "values-dev.yaml", sources[3].ChartFileRef.Path)
+	assert.Equal(t, []string{"charts/app", "environments/values-prod.yaml", "charts/app/values-extra.yaml"}, hr.Spec.GitChartSource.Paths())
+
+	// A values file that can not be read relative to the chart is
+	// an error, the other sources are still returned
+	hr.Spec.GitChartSource.Path = "../charts/app"
+	sources, err = hr.GetValuesFromSources()
+	assert.Error(t, err)
+	assert.Len(t, sources, 2)
+}
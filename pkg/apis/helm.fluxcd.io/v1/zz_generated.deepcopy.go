@@ -22,6 +22,7 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -52,13 +53,28 @@ func (in *ChartSource) DeepCopyInto(out *ChartSource) {
 	if in.GitChartSource != nil {
 		in, out := &in.GitChartSource, &out.GitChartSource
 		*out = new(GitChartSource)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.RepoChartSource != nil {
 		in, out := &in.RepoChartSource, &out.RepoChartSource
 		*out = new(RepoChartSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ConfigMapChartSource != nil {
+		in, out := &in.ConfigMapChartSource, &out.ConfigMapChartSource
+		*out = new(ConfigMapChartSource)
+		**out = **in
+	}
+	if in.SourceRefChartSource != nil {
+		in, out := &in.SourceRefChartSource, &out.SourceRefChartSource
+		*out = new(SourceRefChartSource)
+		**out = **in
+	}
+	if in.URLChartSource != nil {
+		in, out := &in.URLChartSource, &out.URLChartSource
+		*out = new(URLChartSource)
+		**out = **in
+	}
 	return
 }
 
@@ -72,6 +88,72 @@ func (in *ChartSource) DeepCopy() *ChartSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChartSourceReference) DeepCopyInto(out *ChartSourceReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartSourceReference.
+func (in *ChartSourceReference) DeepCopy() *ChartSourceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ChartSourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapChartRef) DeepCopyInto(out *ConfigMapChartRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapChartRef.
+func (in *ConfigMapChartRef) DeepCopy() *ConfigMapChartRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapChartRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapChartSource) DeepCopyInto(out *ConfigMapChartSource) {
+	*out = *in
+	out.ConfigMap = in.ConfigMap
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapChartSource.
+func (in *ConfigMapChartSource) DeepCopy() *ConfigMapChartSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapChartSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyRepo) DeepCopyInto(out *DependencyRepo) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyRepo.
+func (in *DependencyRepo) DeepCopy() *DependencyRepo {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyRepo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalSourceSelector) DeepCopyInto(out *ExternalSourceSelector) {
 	*out = *in
@@ -96,6 +178,31 @@ func (in *ExternalSourceSelector) DeepCopy() *ExternalSourceSelector {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitChartSource) DeepCopyInto(out *GitChartSource) {
 	*out = *in
+	if in.DepUpdateTimeout != nil {
+		in, out := &in.DepUpdateTimeout, &out.DepUpdateTimeout
+		*out = new(int64)
+		**out = **in
+	}
+	if in.DependencyRepos != nil {
+		in, out := &in.DependencyRepos, &out.DependencyRepos
+		*out = make([]DependencyRepo, len(*in))
+		copy(*out, *in)
+	}
+	if in.ValuesFiles != nil {
+		in, out := &in.ValuesFiles, &out.ValuesFiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SSHSecretRef != nil {
+		in, out := &in.SSHSecretRef, &out.SSHSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.GitHubAppSecretRef != nil {
+		in, out := &in.GitHubAppSecretRef, &out.GitHubAppSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
 	return
 }
 
@@ -155,6 +262,23 @@ func (in *HelmReleaseCondition) DeepCopy() *HelmReleaseCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmReleaseHistoryEntry) DeepCopyInto(out *HelmReleaseHistoryEntry) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmReleaseHistoryEntry.
+func (in *HelmReleaseHistoryEntry) DeepCopy() *HelmReleaseHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmReleaseHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmReleaseList) DeepCopyInto(out *HelmReleaseList) {
 	*out = *in
@@ -205,12 +329,72 @@ func (in *HelmReleaseSpec) DeepCopyInto(out *HelmReleaseSpec) {
 		}
 	}
 	in.HelmValues.DeepCopyInto(&out.HelmValues)
+	if in.ValuesOverrides != nil {
+		in, out := &in.ValuesOverrides, &out.ValuesOverrides
+		*out = make([]ValuesOverride, len(*in))
+		copy(*out, *in)
+	}
+	if in.IgnoreValues != nil {
+		in, out := &in.IgnoreValues, &out.IgnoreValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Timeout != nil {
 		in, out := &in.Timeout, &out.Timeout
 		*out = new(int64)
 		**out = **in
 	}
+	if in.MaxHistory != nil {
+		in, out := &in.MaxHistory, &out.MaxHistory
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(Verify)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DriftDetectionInclude != nil {
+		in, out := &in.DriftDetectionInclude, &out.DriftDetectionInclude
+		*out = make([]PatchTarget, len(*in))
+		copy(*out, *in)
+	}
+	if in.DriftDetectionExclude != nil {
+		in, out := &in.DriftDetectionExclude, &out.DriftDetectionExclude
+		*out = make([]PatchTarget, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
 	in.Rollback.DeepCopyInto(&out.Rollback)
+	in.Test.DeepCopyInto(&out.Test)
+	in.Uninstall.DeepCopyInto(&out.Uninstall)
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PostRenderers != nil {
+		in, out := &in.PostRenderers, &out.PostRenderers
+		*out = make([]PostRenderer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CommonLabels != nil {
+		in, out := &in.CommonLabels, &out.CommonLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.NamespaceMetadata.DeepCopyInto(&out.NamespaceMetadata)
 	return
 }
 
@@ -227,6 +411,22 @@ func (in *HelmReleaseSpec) DeepCopy() *HelmReleaseSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmReleaseStatus) DeepCopyInto(out *HelmReleaseStatus) {
 	*out = *in
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.BackoffDelay != nil {
+		in, out := &in.BackoffDelay, &out.BackoffDelay
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]HelmReleaseHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]HelmReleaseCondition, len(*in))
@@ -247,6 +447,187 @@ func (in *HelmReleaseStatus) DeepCopy() *HelmReleaseStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeySelector) DeepCopyInto(out *KeySelector) {
+	*out = *in
+	if in.Optional != nil {
+		in, out := &in.Optional, &out.Optional
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeySelector.
+func (in *KeySelector) DeepCopy() *KeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(KeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizePatch) DeepCopyInto(out *KustomizePatch) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(PatchTarget)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizePatch.
+func (in *KustomizePatch) DeepCopy() *KustomizePatch {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizePatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizePostRenderer) DeepCopyInto(out *KustomizePostRenderer) {
+	*out = *in
+	if in.Patches != nil {
+		in, out := &in.Patches, &out.Patches
+		*out = make([]KustomizePatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizePostRenderer.
+func (in *KustomizePostRenderer) DeepCopy() *KustomizePostRenderer {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizePostRenderer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceMetadata) DeepCopyInto(out *NamespaceMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceMetadata.
+func (in *NamespaceMetadata) DeepCopy() *NamespaceMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectKeysSelector) DeepCopyInto(out *ObjectKeysSelector) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]KeySelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Optional != nil {
+		in, out := &in.Optional, &out.Optional
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectKeysSelector.
+func (in *ObjectKeysSelector) DeepCopy() *ObjectKeysSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectKeysSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatchTarget) DeepCopyInto(out *PatchTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatchTarget.
+func (in *PatchTarget) DeepCopy() *PatchTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PatchTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostRenderer) DeepCopyInto(out *PostRenderer) {
+	*out = *in
+	if in.Kustomize != nil {
+		in, out := &in.Kustomize, &out.Kustomize
+		*out = new(KustomizePostRenderer)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostRenderer.
+func (in *PostRenderer) DeepCopy() *PostRenderer {
+	if in == nil {
+		return nil
+	}
+	out := new(PostRenderer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoChartSource) DeepCopyInto(out *RepoChartSource) {
 	*out = *in
@@ -255,6 +636,31 @@ func (in *RepoChartSource) DeepCopyInto(out *RepoChartSource) {
 		*out = new(corev1.LocalObjectReference)
 		**out = **in
 	}
+	if in.CASecretRef != nil {
+		in, out := &in.CASecretRef, &out.CASecretRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CertSecretRef != nil {
+		in, out := &in.CertSecretRef, &out.CertSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.TokenAuth != nil {
+		in, out := &in.TokenAuth, &out.TokenAuth
+		*out = new(RepoTokenAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RegistrySecretRef != nil {
+		in, out := &in.RegistrySecretRef, &out.RegistrySecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.BucketSecretRef != nil {
+		in, out := &in.BucketSecretRef, &out.BucketSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
 	return
 }
 
@@ -268,14 +674,71 @@ func (in *RepoChartSource) DeepCopy() *RepoChartSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepoTokenAuth) DeepCopyInto(out *RepoTokenAuth) {
+	*out = *in
+	out.ClientSecretRef = in.ClientSecretRef
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoTokenAuth.
+func (in *RepoTokenAuth) DeepCopy() *RepoTokenAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(RepoTokenAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Rollback) DeepCopyInto(out *Rollback) {
 	*out = *in
+	if in.Enable != nil {
+		in, out := &in.Enable, &out.Enable
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Force != nil {
+		in, out := &in.Force, &out.Force
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Recreate != nil {
+		in, out := &in.Recreate, &out.Recreate
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DisableHooks != nil {
+		in, out := &in.DisableHooks, &out.DisableHooks
+		*out = new(bool)
+		**out = **in
+	}
 	if in.Timeout != nil {
 		in, out := &in.Timeout, &out.Timeout
 		*out = new(int64)
 		**out = **in
 	}
+	if in.Wait != nil {
+		in, out := &in.Wait, &out.Wait
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DisableOnReasons != nil {
+		in, out := &in.DisableOnReasons, &out.DisableOnReasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int64)
+		**out = **in
+	}
 	return
 }
 
@@ -289,6 +752,137 @@ func (in *Rollback) DeepCopy() *Rollback {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceRefChartSource) DeepCopyInto(out *SourceRefChartSource) {
+	*out = *in
+	out.SourceRef = in.SourceRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceRefChartSource.
+func (in *SourceRefChartSource) DeepCopy() *SourceRefChartSource {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceRefChartSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Test) DeepCopyInto(out *Test) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Test.
+func (in *Test) DeepCopy() *Test {
+	if in == nil {
+		return nil
+	}
+	out := new(Test)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *URLChartSource) DeepCopyInto(out *URLChartSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new URLChartSource.
+func (in *URLChartSource) DeepCopy() *URLChartSource {
+	if in == nil {
+		return nil
+	}
+	out := new(URLChartSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Uninstall) DeepCopyInto(out *Uninstall) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Uninstall.
+func (in *Uninstall) DeepCopy() *Uninstall {
+	if in == nil {
+		return nil
+	}
+	out := new(Uninstall)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Upgrade) DeepCopyInto(out *Upgrade) {
+	*out = *in
+	if in.Enable != nil {
+		in, out := &in.Enable, &out.Enable
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Force != nil {
+		in, out := &in.Force, &out.Force
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ReuseValues != nil {
+		in, out := &in.ReuseValues, &out.ReuseValues
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ResetValues != nil {
+		in, out := &in.ResetValues, &out.ResetValues
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CleanupOnFail != nil {
+		in, out := &in.CleanupOnFail, &out.CleanupOnFail
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RecreatePods != nil {
+		in, out := &in.RecreatePods, &out.RecreatePods
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DisableHooks != nil {
+		in, out := &in.DisableHooks, &out.DisableHooks
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SkipDryRun != nil {
+		in, out := &in.SkipDryRun, &out.SkipDryRun
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Upgrade.
+func (in *Upgrade) DeepCopy() *Upgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(Upgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValuesFromSource) DeepCopyInto(out *ValuesFromSource) {
 	*out = *in
@@ -302,6 +896,16 @@ func (in *ValuesFromSource) DeepCopyInto(out *ValuesFromSource) {
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ConfigMapKeysRef != nil {
+		in, out := &in.ConfigMapKeysRef, &out.ConfigMapKeysRef
+		*out = new(ObjectKeysSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeysRef != nil {
+		in, out := &in.SecretKeysRef, &out.SecretKeysRef
+		*out = new(ObjectKeysSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ExternalSourceRef != nil {
 		in, out := &in.ExternalSourceRef, &out.ExternalSourceRef
 		*out = new(ExternalSourceSelector)
@@ -324,3 +928,36 @@ func (in *ValuesFromSource) DeepCopy() *ValuesFromSource {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesOverride) DeepCopyInto(out *ValuesOverride) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesOverride.
+func (in *ValuesOverride) DeepCopy() *ValuesOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Verify) DeepCopyInto(out *Verify) {
+	*out = *in
+	in.KeyringSecretRef.DeepCopyInto(&out.KeyringSecretRef)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Verify.
+func (in *Verify) DeepCopy() *Verify {
+	if in == nil {
+		return nil
+	}
+	out := new(Verify)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,203 @@
+package chartsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// The keys of the values in a secret with the credentials for an
+// Azure Blob Storage container.
+const (
+	azureSASTokenKey        = "sasToken"
+	azureManagedIdentityKey = "managedIdentity"
+	azureClientIDKey        = "clientID"
+	azureEndpointKey        = "endpoint"
+)
+
+const (
+	// azureStorageResource is the resource tokens for Azure Storage
+	// are requested for.
+	azureStorageResource = "https://storage.azure.com/"
+	// azureStorageVersion is the version of the Blob service API
+	// requests are made with; requests authorized with a token need
+	// one.
+	azureStorageVersion = "2019-12-12"
+	// azureBlobHostSuffix is the suffix of the hosts of the Blob
+	// service of storage accounts in the Azure public cloud, the
+	// only hosts tokens of managed identities are sent to.
+	azureBlobHostSuffix = ".blob.core.windows.net"
+)
+
+// azureIMDSEndpoint is the endpoint of the Azure Instance Metadata
+// Service that hands out the tokens of managed identities.
+var azureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// azureTokens caches the token sources of managed identities by their
+// client ID, so that tokens are reused until they expire.
+var azureTokens = struct {
+	mu      sync.Mutex
+	sources map[string]oauth2.TokenSource
+}{sources: make(map[string]oauth2.TokenSource)}
+
+// azblobGetter gets the blobs of Azure Blob Storage containers, as
+// `azblob://<account>/<container>/<blob>` URLs, with the SAS token of
+// the bucket secret, or a token for the managed identity of the
+// operator if the secret asks for it and the operator allows it.
+// Without either, requests are anonymous, which suits containers with
+// public access.
+type azblobGetter struct {
+	client   *http.Client
+	sasToken string
+	tokens   oauth2.TokenSource
+	endpoint string
+}
+
+// newAzblobGetter returns an azblobGetter with the given client,
+// configured with the given bucket secret data, if any. The managed
+// identity of the operator is only used if allowed, and never with a
+// custom endpoint, as its tokens would be handed to whoever runs it.
+func newAzblobGetter(client *http.Client, bucket map[string][]byte, allowManagedIdentity bool) (*azblobGetter, error) {
+	g := &azblobGetter{
+		client:   client,
+		sasToken: strings.TrimPrefix(strings.TrimSpace(string(bucket[azureSASTokenKey])), "?"),
+		endpoint: strings.TrimRight(strings.TrimSpace(string(bucket[azureEndpointKey])), "/"),
+	}
+	if g.endpoint != "" {
+		if u, err := url.Parse(g.endpoint); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid Azure Blob Storage endpoint %q", g.endpoint)
+		}
+	}
+
+	clientID := strings.TrimSpace(string(bucket[azureClientIDKey]))
+	managedIdentity, _ := strconv.ParseBool(strings.TrimSpace(string(bucket[azureManagedIdentityKey])))
+	if g.sasToken != "" || (!managedIdentity && clientID == "") {
+		return g, nil
+	}
+	if !allowManagedIdentity {
+		return nil, fmt.Errorf("the use of the managed identity of the operator for Azure Blob Storage is not enabled (--azure-managed-identity)")
+	}
+	if g.endpoint != "" {
+		return nil, fmt.Errorf("the managed identity of the operator cannot be used with a custom Azure Blob Storage endpoint")
+	}
+	azureTokens.mu.Lock()
+	defer azureTokens.mu.Unlock()
+	if g.tokens = azureTokens.sources[clientID]; g.tokens == nil {
+		g.tokens = oauth2.ReuseTokenSource(nil, managedIdentityTokenSource{clientID: clientID})
+		azureTokens.sources[clientID] = g.tokens
+	}
+	return g, nil
+}
+
+// Get gets the blob at the given URL.
+func (g *azblobGetter) Get(href string) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	return buf, g.download(href, buf, 0)
+}
+
+// download gets the blob at the given URL and writes it to the given
+// writer, aborting once it exceeds the maximum size if that is above
+// zero.
+func (g *azblobGetter) download(href string, w io.Writer, maxSize int64) error {
+	u, err := url.Parse(href)
+	if err != nil {
+		return err
+	}
+	segments := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Host == "" || len(segments) < 2 || segments[0] == "" || segments[len(segments)-1] == "" {
+		return fmt.Errorf("invalid Azure Blob Storage URL %q, expected azblob://<account>/<container>/<blob>", href)
+	}
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	endpoint := g.endpoint
+	if endpoint == "" {
+		endpoint = "https://" + u.Host + azureBlobHostSuffix
+	}
+	blobURL := endpoint + "/" + strings.Join(segments, "/")
+	if g.sasToken != "" {
+		blobURL += "?" + g.sasToken
+	}
+	req, err := http.NewRequest("GET", blobURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", azureStorageVersion)
+	if g.tokens != nil {
+		if !strings.HasSuffix(req.URL.Hostname(), azureBlobHostSuffix) {
+			return fmt.Errorf("refusing to send token for managed identity to %q, which is not an Azure Blob Storage host", req.URL.Hostname())
+		}
+		token, err := g.tokens.Token()
+		if err != nil {
+			return fmt.Errorf("unable to fetch token for managed identity: %s", err)
+		}
+		token.SetAuthHeader(req)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		// The SAS token is in the URL of the request
+		if uerr, ok := err.(*url.Error); ok {
+			uerr.URL = href
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to fetch %s : %s", href, resp.Status)
+	}
+	return copyBody(w, resp, href, maxSize)
+}
+
+// managedIdentityTokenSource fetches the tokens for Azure Storage of
+// the managed identity of the operator (or the user-assigned one with
+// the client ID, if given) from the Azure Instance Metadata Service.
+type managedIdentityTokenSource struct {
+	clientID string
+}
+
+func (s managedIdentityTokenSource) Token() (*oauth2.Token, error) {
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureStorageResource}}
+	if s.clientID != "" {
+		query.Set("client_id", s.clientID)
+	}
+	req, err := http.NewRequest("GET", azureIMDSEndpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	// The metadata service is never reached through a proxy
+	client := &http.Client{Transport: &http.Transport{}, Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Azure Instance Metadata Service returned %s", resp.Status)
+	}
+	// The expiry is a number in a string
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+		TokenType   string      `json:"token_type"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("unable to parse token of Azure Instance Metadata Service: %s", err)
+	}
+	expiresIn, _ := token.ExpiresIn.Int64()
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Now().Add(time.Duration(expiresIn) * time.Second),
+	}, nil
+}
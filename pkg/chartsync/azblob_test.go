@@ -0,0 +1,129 @@
+package chartsync
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/helm/pkg/repo"
+)
+
+func Test_azblobGetter(t *testing.T) {
+	index := `apiVersion: v1
+entries:
+  podinfo:
+  - name: podinfo
+    version: 1.0.0
+    urls:
+    - podinfo-1.0.0.tgz
+`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "s3cr3t" || r.Header.Get("x-ms-version") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/devstoreaccount1/charts/stable/index.yaml":
+			w.Write([]byte(index))
+		case "/devstoreaccount1/charts/stable/podinfo-1.0.0.tgz":
+			w.Write([]byte("chart"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	opts := downloadOptions{
+		Bucket: map[string][]byte{
+			azureSASTokenKey: []byte("?sv=2019-12-12&sp=r&sig=s3cr3t"),
+			azureEndpointKey: []byte(srv.URL + "/devstoreaccount1/"),
+		},
+	}
+	getters := opts.providers(nil, "", "")
+	chartURL, err := repo.FindChartInAuthRepoURL("azblob://devstoreaccount1/charts/stable/", "", "", "podinfo", "1.0.0", "", "", "", getters)
+	assert.NoError(t, err)
+	assert.Equal(t, "azblob://devstoreaccount1/charts/stable/podinfo-1.0.0.tgz", chartURL)
+
+	dir, err := ioutil.TempDir("", "azblob")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	newGetter, err := getters.ByScheme("azblob")
+	assert.NoError(t, err)
+	g, err := newGetter(chartURL, "", "", "")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "podinfo-1.0.0.tgz")
+	assert.NoError(t, downloadTo(g, chartURL, path, 0))
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "chart", string(b))
+
+	// The SAS token is kept out of errors
+	err = downloadTo(g, "azblob://devstoreaccount1/charts/stable/missing.tgz", path, 0)
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "s3cr3t")
+	}
+	_, err = newAzblobGetter(http.DefaultClient, map[string][]byte{azureEndpointKey: []byte("devstoreaccount1")}, false)
+	assert.Error(t, err)
+}
+
+func Test_azblobGetter_managedIdentity(t *testing.T) {
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Header.Get("Metadata") != "true" || q.Get("resource") != azureStorageResource || q.Get("client_id") != "c1ient" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "t0k3n", "expires_in": "3599", "token_type": "Bearer"}`)
+	}))
+	defer imds.Close()
+	defer func(endpoint string) { azureIMDSEndpoint = endpoint }(azureIMDSEndpoint)
+	azureIMDSEndpoint = imds.URL
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer storage.Close()
+	storageURL, _ := url.Parse(storage.URL)
+	// Requests for the public cloud end up at the storage server
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, "examplecharts.blob.core.windows.net", r.URL.Host)
+		r.URL.Scheme, r.URL.Host = storageURL.Scheme, storageURL.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	bucket := map[string][]byte{azureClientIDKey: []byte("c1ient")}
+	_, err := newAzblobGetter(client, bucket, false)
+	assert.Error(t, err)
+	g, err := newAzblobGetter(client, bucket, true)
+	assert.NoError(t, err)
+	b, err := g.Get("azblob://examplecharts/charts/index.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer t0k3n", b.String())
+	token, err := g.tokens.Token()
+	assert.NoError(t, err)
+	assert.True(t, token.Valid())
+
+	// Tokens are never sent to a custom endpoint
+	_, err = newAzblobGetter(client, map[string][]byte{
+		azureClientIDKey: []byte("c1ient"),
+		azureEndpointKey: []byte(storage.URL),
+	}, true)
+	assert.Error(t, err)
+
+	// Without credentials, requests are anonymous
+	g, err = newAzblobGetter(http.DefaultClient, map[string][]byte{azureEndpointKey: []byte(storage.URL)}, true)
+	assert.NoError(t, err)
+	b, err = g.Get("azblob://examplecharts/charts/index.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "", b.String())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
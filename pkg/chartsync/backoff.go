@@ -0,0 +1,193 @@
+package chartsync
+
+import (
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// failureBackoff is the backoff of a release that failed to
+// reconcile.
+type failureBackoff struct {
+	// failures is the number of consecutive reconciliations that
+	// failed.
+	failures int
+	// delay is the current delay before the next attempt.
+	delay time.Duration
+	// until is the time before which the release is not attempted.
+	until time.Time
+	// generation is the generation of the HelmRelease that failed;
+	// a new generation is attempted right away.
+	generation int64
+	// failed records that the ongoing reconciliation failed.
+	failed bool
+}
+
+// waitReasons are the reasons of Released conditions that signal a
+// release waits for something, rather than that it failed; these
+// requeue the release themselves.
+var waitReasons = map[string]bool{
+	ReasonDependencyNotReady:   true,
+	ReasonNamespaceTerminating: true,
+	ReasonSourceNotReady:       true,
+}
+
+// backoffDelay returns the delay after the given number of
+// consecutive failures: the base delay doubled for every failure
+// after the first, capped by the maximum delay.
+func backoffDelay(failures int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// backingOff returns the time left before the given HelmRelease is
+// attempted again, or zero if it can be attempted now.
+func (chs *ChartChangeSync) backingOff(hr helmfluxv1.HelmRelease) time.Duration {
+	if chs.config.FailureBackoff <= 0 {
+		return 0
+	}
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return 0
+	}
+	chs.backoffMu.Lock()
+	defer chs.backoffMu.Unlock()
+	b, ok := chs.backoffs[key]
+	if !ok {
+		return 0
+	}
+	if b.generation != hr.Generation {
+		// Start over, but keep the entry so that the delay is
+		// cleared from the status once the attempt concludes.
+		*b = failureBackoff{generation: hr.Generation}
+		return 0
+	}
+	return time.Until(b.until)
+}
+
+// recordOutcome records if the condition set during a
+// reconciliation of the given HelmRelease signals a failure, or a
+// success which resets the backoff.
+func (chs *ChartChangeSync) recordOutcome(hr helmfluxv1.HelmRelease, typ helmfluxv1.HelmReleaseConditionType, st v1.ConditionStatus, reason string) {
+	if chs.config.FailureBackoff <= 0 {
+		return
+	}
+	if typ != helmfluxv1.HelmReleaseChartFetched && typ != helmfluxv1.HelmReleaseReleased {
+		return
+	}
+	failed := st == v1.ConditionFalse
+	if failed && waitReasons[reason] {
+		return
+	}
+	if !failed && (typ != helmfluxv1.HelmReleaseReleased || st != v1.ConditionTrue) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return
+	}
+	chs.backoffMu.Lock()
+	defer chs.backoffMu.Unlock()
+	b, ok := chs.backoffs[key]
+	if !failed {
+		if ok {
+			b.failures, b.delay, b.failed = 0, 0, false
+		}
+		return
+	}
+	if !ok {
+		b = &failureBackoff{}
+		chs.backoffs[key] = b
+	}
+	b.failed = true
+}
+
+// updateBackoff concludes a reconciliation of the given HelmRelease:
+// if it failed, it increases the delay before the next attempt and
+// schedules it; if it succeeded after failing, it clears the delay.
+// The delay is recorded in the status of the HelmRelease.
+func (chs *ChartChangeSync) updateBackoff(hr helmfluxv1.HelmRelease) {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return
+	}
+	delay, changed := chs.concludeBackoff(key, hr.Generation)
+	if !changed {
+		return
+	}
+	chs.setBackoffDelay(hr, delay)
+	if delay > 0 {
+		chs.logger.Log("info", "backing off failing release", "resource", hr.ResourceID().String(), "delay", delay)
+		chs.releaseQueue.AddAfter(key, delay)
+	}
+}
+
+// concludeBackoff updates the backoff of the release with the given
+// key to the outcome of its reconciliation, returning the delay
+// before the next attempt and if it changed.
+func (chs *ChartChangeSync) concludeBackoff(key string, generation int64) (time.Duration, bool) {
+	chs.backoffMu.Lock()
+	defer chs.backoffMu.Unlock()
+	b, ok := chs.backoffs[key]
+	if !ok {
+		return 0, false
+	}
+	if !b.failed {
+		if b.failures > 0 {
+			return b.delay, false
+		}
+		delete(chs.backoffs, key)
+		return 0, true
+	}
+	b.failed = false
+	b.failures++
+	b.delay = backoffDelay(b.failures, chs.config.FailureBackoff, chs.config.FailureBackoffMax)
+	b.until = time.Now().Add(b.delay)
+	b.generation = generation
+	return b.delay, true
+}
+
+// setBackoffDelay records the given backoff delay in the status of
+// the HelmRelease.
+func (chs *ChartChangeSync) setBackoffDelay(hr helmfluxv1.HelmRelease, delay time.Duration) {
+	hrClient := chs.statusClient(hr)
+	if err := status.SetBackoffDelay(hrClient, hr, delay); err != nil {
+		chs.logger.Log("warning", "could not update the backoff delay", "resource", hr.ResourceID().String(), "err", err)
+	}
+}
+
+// sourceWaitDelay counts another time the chart source of the
+// HelmRelease with the given key was not ready, and returns the delay
+// before it is examined again: the SourceRequeueDelay, doubled every
+// consecutive time, capped by the FailureBackoffMax.
+func (chs *ChartChangeSync) sourceWaitDelay(key string) time.Duration {
+	chs.backoffMu.Lock()
+	defer chs.backoffMu.Unlock()
+	if chs.sourceWaits == nil {
+		chs.sourceWaits = make(map[string]int)
+	}
+	chs.sourceWaits[key]++
+	return backoffDelay(chs.sourceWaits[key], chs.config.SourceRequeueDelay, chs.config.FailureBackoffMax)
+}
+
+// sourceReady resets the delay before the chart source of the given
+// HelmRelease is examined again once it is not ready.
+func (chs *ChartChangeSync) sourceReady(hr helmfluxv1.HelmRelease) {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return
+	}
+	chs.backoffMu.Lock()
+	defer chs.backoffMu.Unlock()
+	delete(chs.sourceWaits, key)
+}
@@ -0,0 +1,81 @@
+package chartsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoffDelay(1, 30*time.Second, 5*time.Minute))
+	assert.Equal(t, 2*time.Minute, backoffDelay(3, 30*time.Second, 5*time.Minute))
+	assert.Equal(t, 5*time.Minute, backoffDelay(5, 30*time.Second, 5*time.Minute))
+	assert.Equal(t, 5*time.Minute, backoffDelay(100, 30*time.Second, 5*time.Minute))
+}
+
+func TestFailureBackoff(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "podinfo", Generation: 1}}
+	chs := &ChartChangeSync{
+		config:   Config{FailureBackoff: time.Minute, FailureBackoffMax: 3 * time.Minute},
+		backoffs: make(map[string]*failureBackoff),
+	}
+	conclude := func(st v1.ConditionStatus, reason string) (time.Duration, bool) {
+		chs.recordOutcome(hr, helmfluxv1.HelmReleaseReleased, st, reason)
+		return chs.concludeBackoff("default/podinfo", hr.Generation)
+	}
+
+	// Waiting on dependencies is not a failure
+	_, changed := conclude(v1.ConditionFalse, ReasonDependencyNotReady)
+	assert.False(t, changed)
+	assert.Zero(t, chs.backingOff(hr))
+
+	// Nor is waiting on the chart source
+	chs.recordOutcome(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonSourceNotReady)
+	_, changed = chs.concludeBackoff("default/podinfo", hr.Generation)
+	assert.False(t, changed)
+	assert.Zero(t, chs.backingOff(hr))
+
+	delay, changed := conclude(v1.ConditionFalse, ReasonInstallFailed)
+	assert.True(t, changed)
+	assert.Equal(t, time.Minute, delay)
+	assert.True(t, chs.backingOff(hr) > 0)
+
+	conclude(v1.ConditionFalse, ReasonInstallFailed)
+	delay, _ = conclude(v1.ConditionFalse, ReasonInstallFailed)
+	assert.Equal(t, 3*time.Minute, delay)
+
+	// A reconciliation without outcome leaves the backoff as is
+	_, changed = chs.concludeBackoff("default/podinfo", hr.Generation)
+	assert.False(t, changed)
+	assert.True(t, chs.backingOff(hr) > 0)
+
+	// A new generation is attempted right away
+	next := hr
+	next.Generation = 2
+	assert.Zero(t, chs.backingOff(next))
+
+	conclude(v1.ConditionFalse, ReasonInstallFailed)
+	delay, changed = conclude(v1.ConditionTrue, ReasonSuccess)
+	assert.True(t, changed)
+	assert.Zero(t, delay)
+	assert.Zero(t, chs.backingOff(hr))
+}
+
+func TestSourceWaitDelay(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "podinfo"}}
+	chs := &ChartChangeSync{config: Config{SourceRequeueDelay: 10 * time.Second, FailureBackoffMax: 30 * time.Second}}
+
+	assert.Equal(t, 10*time.Second, chs.sourceWaitDelay("default/podinfo"))
+	assert.Equal(t, 20*time.Second, chs.sourceWaitDelay("default/podinfo"))
+	assert.Equal(t, 30*time.Second, chs.sourceWaitDelay("default/podinfo"))
+	assert.Equal(t, 10*time.Second, chs.sourceWaitDelay("default/other"))
+
+	// Once the chart is fetched, waiting starts over
+	chs.sourceReady(hr)
+	assert.Equal(t, 10*time.Second, chs.sourceWaitDelay("default/podinfo"))
+}
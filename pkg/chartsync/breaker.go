@@ -0,0 +1,129 @@
+package chartsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/flux/pkg/git"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// defaultMirrorBreakerCooldown is the default duration for which a
+// git mirror is not used once its circuit breaker opened.
+const defaultMirrorBreakerCooldown = 5 * time.Minute
+
+// mirrorBreaker is the circuit breaker of a git mirror, which opens
+// once refreshing the mirror failed a number of consecutive times.
+type mirrorBreaker struct {
+	failures    int
+	err         error
+	lastFailure time.Time
+	openUntil   time.Time
+}
+
+// message describes the open breaker of the given mirror.
+func (b mirrorBreaker) message(mirror string) string {
+	return fmt.Sprintf("git mirror %s failed to refresh %d consecutive times, not using it until %s: %s",
+		mirror, b.failures, b.openUntil.Format(time.RFC3339), b.err)
+}
+
+// openMirrorBreaker returns the breaker of the given mirror and true
+// if it is open at the given time. Once the cooldown has passed, the
+// mirror is used again until it fails another time.
+func (chs *ChartChangeSync) openMirrorBreaker(mirror string, now time.Time) (mirrorBreaker, bool) {
+	chs.mirrorBreakersMu.Lock()
+	defer chs.mirrorBreakersMu.Unlock()
+	b, ok := chs.mirrorBreakers[mirror]
+	if !ok || !now.Before(b.openUntil) {
+		return mirrorBreaker{}, false
+	}
+	return *b, true
+}
+
+// recordMirrorRefresh records the outcome of refreshing the given
+// mirror at the given time. It returns the breaker of the mirror and
+// true if it opened, and true as the third value if it closed again.
+func (chs *ChartChangeSync) recordMirrorRefresh(mirror string, err error, now time.Time) (mirrorBreaker, bool, bool) {
+	threshold := chs.config.MirrorBreakerThreshold
+	if threshold <= 0 {
+		return mirrorBreaker{}, false, false
+	}
+
+	chs.mirrorBreakersMu.Lock()
+	defer chs.mirrorBreakersMu.Unlock()
+	b, ok := chs.mirrorBreakers[mirror]
+	if err == nil {
+		if !ok {
+			return mirrorBreaker{}, false, false
+		}
+		delete(chs.mirrorBreakers, mirror)
+		return mirrorBreaker{}, false, b.failures >= threshold
+	}
+
+	if !ok {
+		if chs.mirrorBreakers == nil {
+			chs.mirrorBreakers = make(map[string]*mirrorBreaker)
+		}
+		b = &mirrorBreaker{}
+		chs.mirrorBreakers[mirror] = b
+	}
+	b.failures++
+	b.err = err
+	b.lastFailure = now
+	if b.failures < threshold {
+		return *b, false, false
+	}
+	b.openUntil = now.Add(chs.config.MirrorBreakerCooldown)
+	return *b, true, false
+}
+
+// recordMirrorStatus records the given status of the given mirror, as
+// seen at the given time when looking for the chart of a HelmRelease,
+// like recordMirrorRefresh. As the status of a mirror is seen for
+// every HelmRelease that uses it, a failure seen within the source
+// requeue delay of the last one does not count again, unless it
+// reopens the breaker. A mirror that is still being cloned has not
+// failed.
+func (chs *ChartChangeSync) recordMirrorStatus(mirror string, status git.GitRepoStatus, err error, now time.Time) (mirrorBreaker, bool, bool) {
+	if status == git.RepoReady {
+		return chs.recordMirrorRefresh(mirror, nil, now)
+	}
+	if err == nil || err == git.ErrNotCloned || err == git.ErrClonedOnly {
+		return mirrorBreaker{}, false, false
+	}
+	chs.mirrorBreakersMu.Lock()
+	b, ok := chs.mirrorBreakers[mirror]
+	seen := ok && b.failures < chs.config.MirrorBreakerThreshold && now.Sub(b.lastFailure) < chs.config.SourceRequeueDelay
+	chs.mirrorBreakersMu.Unlock()
+	if seen {
+		return mirrorBreaker{}, false, false
+	}
+	return chs.recordMirrorRefresh(mirror, err, now)
+}
+
+// mirrorBreakerChanged marks the chart of every HelmRelease that uses
+// the given mirror as not fetched when its breaker opened, and
+// schedules them to be examined again when it closed.
+func (chs *ChartChangeSync) mirrorBreakerChanged(mirror string, b mirrorBreaker, opened bool) {
+	resources, err := chs.getCustomResourcesForMirror(mirror)
+	if err != nil {
+		chs.logger.Log("warning", "failed to get custom resources", "err", err)
+		return
+	}
+	if opened {
+		chs.logger.Log("warning", "circuit breaker of git mirror opened", "repo", mirror, "failures", b.failures, "until", b.openUntil.Format(time.RFC3339))
+		for _, hr := range resources {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonDownloadFailed, b.message(mirror))
+		}
+		return
+	}
+	chs.logger.Log("info", "circuit breaker of git mirror closed", "repo", mirror)
+	for _, hr := range resources {
+		if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil && chs.releaseQueue != nil {
+			chs.releaseQueue.AddRateLimited(cacheKey)
+		}
+	}
+}
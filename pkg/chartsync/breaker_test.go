@@ -0,0 +1,100 @@
+package chartsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/flux/pkg/git"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+func TestMirrorBreaker(t *testing.T) {
+	const mirror = "ssh://git@github.com/fluxcd/charts"
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{
+			GitChartSource: &helmfluxv1.GitChartSource{GitURL: mirror, Ref: "master", Path: "charts/podinfo"},
+		}},
+	}
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&hr)
+
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		release:  release.New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		ifClient: ifClient,
+		hrLister: iflister.NewHelmReleaseLister(indexer),
+		config:   Config{MirrorBreakerThreshold: 2, MirrorBreakerCooldown: time.Minute},
+	}
+	now := time.Now()
+	fetchErr := errors.New("remote: Repository not found")
+
+	_, opened, closed := chs.recordMirrorRefresh(mirror, fetchErr, now)
+	assert.False(t, opened || closed)
+	_, open := chs.openMirrorBreaker(mirror, now)
+	assert.False(t, open)
+
+	b, opened, closed := chs.recordMirrorRefresh(mirror, fetchErr, now)
+	assert.True(t, opened)
+	assert.False(t, closed)
+	chs.mirrorBreakerChanged(mirror, b, opened)
+	cond := status.GetCondition(srv.get().Status, helmfluxv1.HelmReleaseChartFetched)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, v1.ConditionFalse, cond.Status)
+		assert.Equal(t, ReasonDownloadFailed, cond.Reason)
+		assert.Contains(t, cond.Message, "failed to refresh 2 consecutive times")
+		assert.Contains(t, cond.Message, "remote: Repository not found")
+	}
+
+	// The chart is not waited for while the breaker is open
+	_, _, _, ok := chs.fetchChart(hr, true)
+	assert.False(t, ok)
+	// ...and the clones are not left locked
+	chs.clonesMu.Lock()
+	chs.clonesMu.Unlock()
+	_, open = chs.openMirrorBreaker(mirror, now.Add(30*time.Second))
+	assert.True(t, open)
+
+	// After the cooldown the mirror is refreshed again, and a
+	// success closes the breaker
+	_, open = chs.openMirrorBreaker(mirror, now.Add(time.Minute))
+	assert.False(t, open)
+	_, opened, closed = chs.recordMirrorRefresh(mirror, nil, now.Add(time.Minute))
+	assert.False(t, opened)
+	assert.True(t, closed)
+	_, _, closed = chs.recordMirrorRefresh(mirror, nil, now.Add(time.Minute))
+	assert.False(t, closed)
+
+	// The same failure seen for several HelmReleases counts once
+	chs.config.SourceRequeueDelay = 10 * time.Second
+	_, opened, _ = chs.recordMirrorStatus(mirror, git.RepoNew, fetchErr, now)
+	assert.False(t, opened)
+	_, opened, _ = chs.recordMirrorStatus(mirror, git.RepoNew, fetchErr, now.Add(time.Second))
+	assert.False(t, opened)
+	_, opened, _ = chs.recordMirrorStatus(mirror, git.RepoNew, git.ErrNotCloned, now.Add(20*time.Second))
+	assert.False(t, opened)
+	_, opened, _ = chs.recordMirrorStatus(mirror, git.RepoNew, fetchErr, now.Add(20*time.Second))
+	assert.True(t, opened)
+	_, _, closed = chs.recordMirrorStatus(mirror, git.RepoReady, nil, now.Add(2*time.Minute))
+	assert.True(t, closed)
+
+	// A zero threshold disables the breaker
+	chs.config.MirrorBreakerThreshold = 0
+	for i := 0; i < 3; i++ {
+		_, opened, _ = chs.recordMirrorRefresh(mirror, fetchErr, now)
+		assert.False(t, opened)
+	}
+}
@@ -0,0 +1,182 @@
+package chartsync
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// chartCacheEvictionInterval is the period on which charts are
+// evicted from the chart cache.
+const chartCacheEvictionInterval = 5 * time.Minute
+
+// chartCache keeps track of the charts in the chart cache that are
+// in use, so that they are not evicted from under an install or
+// upgrade, and of the chart last used by each HelmRelease, which are
+// kept.
+type chartCache struct {
+	mu    sync.Mutex
+	inUse map[string]int
+	used  map[string]string
+}
+
+// acquire marks the chart at the given path as in use by the
+// HelmRelease with the given key, until it is released.
+func (c *chartCache) acquire(key, path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inUse == nil {
+		c.inUse = make(map[string]int)
+		c.used = make(map[string]string)
+	}
+	c.inUse[path]++
+	c.used[key] = path
+	// The modification time tells when the chart was last used
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// release marks the chart at the given path as no longer in use by
+// the caller of acquire.
+func (c *chartCache) release(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inUse[path] <= 1 {
+		delete(c.inUse, path)
+		return
+	}
+	c.inUse[path]--
+}
+
+// cachedChart is a chart archive in the chart cache.
+type cachedChart struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// evict removes the charts from the cache in the given directory
+// that have not been used for longer than the given maximum age, and
+// then the least recently used ones until the cache no longer exceeds
+// the given maximum size; a zero maximum disables either. Charts in
+// use, or last used by one of the HelmReleases with the given keys,
+// are never removed. It returns the paths of the removed charts.
+func (c *chartCache) evict(base string, maxAge time.Duration, maxSize int64, keys []string, now time.Time) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keep := make(map[string]bool, len(c.inUse)+len(keys))
+	for path := range c.inUse {
+		keep[path] = true
+	}
+	current := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if path, ok := c.used[key]; ok {
+			keep[path] = true
+			current[key] = path
+		}
+	}
+	c.used = current
+
+	charts, size := cachedCharts(base)
+	sort.Slice(charts, func(i, j int) bool { return charts[i].modTime.Before(charts[j].modTime) })
+
+	var evicted []string
+	for _, chart := range charts {
+		if keep[chart.path] {
+			continue
+		}
+		expired := maxAge > 0 && now.Sub(chart.modTime) > maxAge
+		oversized := maxSize > 0 && size > maxSize
+		if !expired && !oversized {
+			continue
+		}
+		if err := os.Remove(chart.path); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		os.Remove(chart.path + ".prov")
+		// Remove the directory of the repo once it is empty
+		os.Remove(filepath.Dir(chart.path))
+		size -= chart.size
+		evicted = append(evicted, chart.path)
+	}
+	return evicted
+}
+
+// cachedCharts returns the chart archives in the chart cache in the
+// given directory, and their total size including their provenance
+// files. Only the directories named after a repo URL, as done by
+// makeChartPath and makeConfigMapChartPath, are considered, as the
+// cache may share a directory with other files.
+func cachedCharts(base string) ([]cachedChart, int64) {
+	repos, err := ioutil.ReadDir(base)
+	if err != nil {
+		return nil, 0
+	}
+
+	var charts []cachedChart
+	var total int64
+	for _, repo := range repos {
+		if !repo.IsDir() {
+			continue
+		}
+		if url, err := base64.URLEncoding.DecodeString(repo.Name()); err != nil || !strings.Contains(string(url), "://") {
+			continue
+		}
+		repoPath := filepath.Join(base, repo.Name())
+		files, err := ioutil.ReadDir(repoPath)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			total += f.Size()
+			if !strings.HasSuffix(f.Name(), ".tgz") {
+				continue
+			}
+			charts = append(charts, cachedChart{
+				path:    filepath.Join(repoPath, f.Name()),
+				size:    f.Size() + fileSize(filepath.Join(repoPath, f.Name()+".prov")),
+				modTime: f.ModTime(),
+			})
+		}
+	}
+	return charts, total
+}
+
+// fileSize returns the size of the file at the given path, or zero
+// if it does not exist.
+func fileSize(path string) int64 {
+	if stat, err := os.Stat(path); err == nil {
+		return stat.Size()
+	}
+	return 0
+}
+
+// evictCharts evicts charts from the chart cache, if configured to.
+func (chs *ChartChangeSync) evictCharts() {
+	hrs, err := chs.selectedReleases()
+	if err != nil {
+		chs.logger.Log("warning", "unable to list HelmReleases to evict charts", "err", err)
+		return
+	}
+	var keys []string
+	for _, hr := range hrs {
+		if key, err := cache.MetaNamespaceKeyFunc(hr); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	evicted := chs.charts.evict(chs.config.ChartCache, chs.config.ChartCacheMaxAge, chs.config.ChartCacheMaxSize, keys, time.Now())
+	if len(evicted) > 0 {
+		chs.logger.Log("info", "evicted charts from the chart cache", "charts", len(evicted))
+	}
+}
@@ -0,0 +1,81 @@
+package chartsync
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChartCacheEvict(t *testing.T) {
+	base, err := ioutil.TempDir("", "chart-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	now := time.Now()
+	repo := filepath.Join(base, base64.URLEncoding.EncodeToString([]byte("https://charts.example.com/")))
+	other := filepath.Join(base, "other")
+	for _, dir := range []string{repo, other} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path string, size int, age time.Duration) string {
+		if err := ioutil.WriteFile(path, make([]byte, size), 0640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	oldest := write(filepath.Join(repo, "podinfo-3.0.0.tgz"), 100, 3*time.Hour)
+	write(oldest+".prov", 10, 3*time.Hour)
+	old := write(filepath.Join(repo, "podinfo-3.1.0.tgz"), 100, 2*time.Hour)
+	current := write(filepath.Join(repo, "podinfo-3.2.0.tgz"), 100, 3*time.Hour)
+	inUse := write(filepath.Join(repo, "redis-10.0.0.tgz"), 100, 3*time.Hour)
+	recent := write(filepath.Join(repo, "redis-10.1.0.tgz"), 100, time.Minute)
+	unrelated := write(filepath.Join(other, "unrelated.tgz"), 1000, 3*time.Hour)
+
+	c := &chartCache{}
+	c.acquire("default/podinfo", current)
+	c.release(current)
+	c.acquire("default/redis", inUse)
+	// Acquiring marks the chart as used just now
+	os.Chtimes(current, now.Add(-3*time.Hour), now.Add(-3*time.Hour))
+	os.Chtimes(inUse, now.Add(-3*time.Hour), now.Add(-3*time.Hour))
+
+	// Without a maximum, nothing is evicted
+	assert.Empty(t, c.evict(base, 0, 0, []string{"default/podinfo"}, now))
+
+	// By age
+	evicted := c.evict(base, 150*time.Minute, 0, []string{"default/podinfo"}, now)
+	assert.Equal(t, []string{oldest}, evicted)
+	assert.False(t, exists(oldest+".prov"))
+
+	// By size, least recently used first; the chart in use is kept
+	// even though its HelmRelease is gone
+	evicted = c.evict(base, 0, 300, []string{"default/podinfo"}, now)
+	assert.Equal(t, []string{old}, evicted)
+	assert.True(t, exists(current))
+	assert.True(t, exists(inUse))
+	assert.True(t, exists(recent))
+	assert.True(t, exists(unrelated))
+
+	// Once released, the chart of the deleted HelmRelease is evicted
+	c.release(inUse)
+	evicted = c.evict(base, time.Hour, 0, []string{"default/podinfo"}, now)
+	assert.Equal(t, []string{inUse}, evicted)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
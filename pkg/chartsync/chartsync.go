@@ -15,10 +15,11 @@ reconciled:
    is also checked, by doing a dry-run release and comparing the result
    to the release.
 
- 2. The chart has changed in git, meaning the release is out of
-   date. The ChartChangeSync responds to new git commits by looking up
-   each chart that makes use of the mirror that has new commits,
-   replacing the clone for that chart, and scheduling a new release.
+ 2. The chart (or one of its values files) has changed in git,
+   meaning the release is out of date. The ChartChangeSync responds
+   to new git commits by looking up each chart that makes use of the
+   mirror that has new commits, replacing the clone for that chart,
+   and scheduling a new release.
 
 1a.) and 1b.) run on the same schedule, and 2.) is run when a git
 mirror reports it has fetched from upstream _and_ (upon checking) the
@@ -39,6 +40,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -50,8 +52,11 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/helm/pkg/chartutil"
 	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
 	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
 
@@ -66,82 +71,426 @@ import (
 
 const (
 	// condition change reasons
-	ReasonGitNotReady      = "GitRepoNotCloned"
-	ReasonDownloadFailed   = "RepoFetchFailed"
-	ReasonDownloaded       = "RepoChartInCache"
-	ReasonInstallFailed    = "HelmInstallFailed"
-	ReasonDependencyFailed = "UpdateDependencyFailed"
-	ReasonUpgradeFailed    = "HelmUpgradeFailed"
-	ReasonRollbackFailed   = "HelmRollbackFailed"
-	ReasonCloned           = "GitRepoCloned"
-	ReasonSuccess          = "HelmSuccess"
+	ReasonGitNotReady              = "GitRepoNotCloned"
+	ReasonDownloadFailed           = "RepoFetchFailed"
+	ReasonDownloadTLSFailed        = "RepoFetchTLSFailed"
+	ReasonVerificationFailed       = "ChartVerificationFailed"
+	ReasonDownloaded               = "RepoChartInCache"
+	ReasonInstallFailed            = "HelmInstallFailed"
+	ReasonDependencyFailed         = "UpdateDependencyFailed"
+	ReasonUpgradeFailed            = "HelmUpgradeFailed"
+	ReasonRollbackFailed           = "HelmRollbackFailed"
+	ReasonCloned                   = "GitRepoCloned"
+	ReasonSuccess                  = "HelmSuccess"
+	ReasonDependencyNotReady       = "DependencyNotReady"
+	ReasonDependencyCycle          = "DependencyCycle"
+	ReasonReleaseNameInvalid       = "ReleaseNameInvalid"
+	ReasonDeleteFailed             = "HelmDeleteFailed"
+	ReasonSubmodulesFailed         = "GitSubmodulesFailed"
+	ReasonNamespaceFailed          = "TargetNamespaceFailed"
+	ReasonNamespaceTerminating     = "TargetNamespaceTerminating"
+	ReasonTimeout                  = "HelmTimeout"
+	ReasonTestFailed               = "HelmTestFailed"
+	ReasonUnauthorized             = "ServiceAccountUnauthorized"
+	ReasonReleaseDisappeared       = "ReleaseDisappeared"
+	ReasonValuesInvalid            = "ValuesInvalid"
+	ReasonRollbackSkipped          = "HelmRollbackSkipped"
+	ReasonReleaseConflict          = "ReleaseNameConflict"
+	ReasonDeferredOutsideWindow    = "DeferredOutsideWindow"
+	ReasonMaintenanceWindowInvalid = "MaintenanceWindowInvalid"
+	ReasonDriftCorrected           = "DriftCorrected"
+	ReasonDriftCorrectionFailed    = "DriftCorrectionFailed"
+	ReasonConfigMapChartLoaded     = "ConfigMapChartLoaded"
+	ReasonConfigMapChartFailed     = "ConfigMapChartFailed"
+	ReasonNetworkFailed            = "HelmNetworkFailed"
+	ReasonForbidden                = "HelmForbidden"
+	ReasonValidationFailed         = "HelmValidationFailed"
+	ReasonTemplateFailed           = "ChartTemplateFailed"
+	ReasonCRDsNotEstablished       = "CRDsNotEstablished"
+	ReasonNamespaceDenied          = "TargetNamespaceDenied"
+	ReasonSubchartNotFound         = "SubchartNotFound"
+	ReasonSourceNotReady           = "ChartSourceNotReady"
+	ReasonValuesPathFailed         = "ValuesPathFailed"
+	ReasonSourceRefFailed          = "SourceRefFailed"
+	ReasonSourceRefChartFetched    = "SourceRefChartFetched"
+	ReasonDryRunOnly               = "DryRunOnly"
+	ReasonPendingRecovered         = "PendingReleaseRecovered"
+	ReasonPendingRecoveryFailed    = "PendingRecoveryFailed"
+	ReasonUpgradeDisabled          = "UpgradeDisabled"
+	ReasonValuesTemplateFailed     = "ValuesTemplateFailed"
+	ReasonUpgradeRetriesExhausted  = status.ReasonUpgradeRetriesExhausted
+	ReasonGitTagNotFound           = "GitTagNotFound"
+	ReasonGitSSHKeyFailed          = "GitSSHKeyFailed"
+	ReasonGitHubAppFailed          = "GitHubAppFailed"
+	ReasonReleaseAdopted           = "ReleaseAdopted"
+	ReasonAdoptFailed              = "ReleaseAdoptFailed"
+)
+
+const (
+	// dependencyRequeueDelay is the delay after which a HelmRelease
+	// waiting on its dependencies is examined again.
+	dependencyRequeueDelay = 30 * time.Second
+	// deleteRetryDelay is the delay after which the deletion of the
+	// release of a HelmRelease that is being deleted is retried.
+	deleteRetryDelay = 30 * time.Second
+	// namespaceRequeueDelay is the delay after which a HelmRelease
+	// that can not be released to its target namespace is examined
+	// again.
+	namespaceRequeueDelay = 30 * time.Second
+	// defaultDependencyUpdateTimeout is the default duration after
+	// which updating the dependencies of a chart is aborted.
+	defaultDependencyUpdateTimeout = 2 * time.Minute
+	// defaultSourceRequeueDelay is the default delay after which a
+	// HelmRelease of which the chart source is not ready yet is
+	// examined again.
+	defaultSourceRequeueDelay = 10 * time.Second
+	// defaultReleaseTimeout is the default duration after which an
+	// install or upgrade times out.
+	defaultReleaseTimeout = time.Duration(helmfluxv1.DefaultTimeout) * time.Second
+	// DefaultDryRunTimeout is the default duration after which the
+	// dry run to determine if a release should be upgraded is given
+	// up on.
+	DefaultDryRunTimeout = 5 * time.Minute
 )
 
 type Clients struct {
 	KubeClient kubernetes.Clientset
 	IfClient   ifclientset.Clientset
 	HrLister   iflister.HelmReleaseLister
+	// DynamicClient reads the source objects HelmReleases may refer
+	// to for their chart
+	DynamicClient dynamic.Interface
 }
 
 type Config struct {
-	ChartCache      string
-	LogDiffs        bool
-	UpdateDeps      bool
-	GitTimeout      time.Duration
-	GitPollInterval time.Duration
-	GitDefaultRef   string
+	ChartCache          string
+	LogDiffs            bool
+	UpdateDeps          bool
+	GitTimeout          time.Duration
+	GitPollInterval     time.Duration
+	GitDefaultRef       string
+	DryRunReleasePrefix string
+	// DependencyUpdateTimeout is the duration after which updating
+	// the dependencies of a chart from git is aborted.
+	DependencyUpdateTimeout time.Duration
+	// ReleaseTimeout is the install or upgrade timeout for
+	// HelmReleases that do not specify one.
+	ReleaseTimeout time.Duration
+	// DryRunTimeout is the duration after which the dry run to
+	// determine if a release should be upgraded is given up on, so
+	// that a stalled dry run does not hold up the release.
+	DryRunTimeout time.Duration
+	// DryRunNamespace is the namespace the dry run to determine if a
+	// release should be upgraded is done in when it is denied in the
+	// target namespace of the release; empty disables this fallback.
+	DryRunNamespace string
+	// DryRunClientSideFallback compares the values and the chart of
+	// a release without a dry run by Tiller when the dry run is
+	// denied, in the target namespace and in the DryRunNamespace.
+	DryRunClientSideFallback bool
+	// DivergedSpecRetries is the number of times a release is
+	// examined again right away when the spec of its HelmRelease
+	// diverges before it is upgraded; zero skips the upgrade until
+	// the next reconciliation.
+	DivergedSpecRetries int
+	// DryRunOnly keeps the operator from installing, upgrading,
+	// rolling back and deleting releases; it only performs the dry
+	// runs, and reports what it would have done in the conditions
+	// of the HelmReleases.
+	DryRunOnly bool
+	// UpgradeOnChartDigest upgrades a release when the digest of its
+	// chart archive differs from the one last released, even if the
+	// version of the chart did not change, e.g. because a chart
+	// version was overwritten in its repo.
+	UpgradeOnChartDigest bool
+	// PendingRecovery is how releases of which an install or upgrade
+	// has been pending for longer than the release timeout are
+	// recovered: PendingRecoveryNone (the default) leaves them be,
+	// PendingRecoveryRollback rolls them back to the last deployed
+	// revision, and PendingRecoveryDelete deletes them.
+	PendingRecovery string
+	// AllowRenderRelease allows the manifests of releases to be
+	// rendered through the API.
+	AllowRenderRelease bool
+	// AllowCrossNamespaceSourceRefs allows HelmReleases to refer to
+	// source objects in other namespaces than their own.
+	AllowCrossNamespaceSourceRefs bool
+	// AllowedTargetNamespaces are the patterns (as path.Match) of
+	// the namespaces releases may target; if empty, all namespaces
+	// that are not denied may be targeted.
+	AllowedTargetNamespaces []string
+	// DeniedTargetNamespaces are the patterns of the namespaces
+	// releases may not target, even when allowed.
+	DeniedTargetNamespaces []string
+	// HealthStalenessWindow is the duration without a completed
+	// reconciliation after which we are considered unhealthy; zero
+	// disables the check.
+	HealthStalenessWindow time.Duration
+	// ChartRepoProxy is the URL of the proxy to download charts
+	// from repositories through, defaults to the proxy from the
+	// environment.
+	ChartRepoProxy string
+	// ChartRepoCAFile is the path to a CA bundle trusted for chart
+	// repositories in addition to the system CAs.
+	ChartRepoCAFile string
+	// ChartRepoCredentialsFile is the path to a netrc file with the
+	// credentials for chart repositories, by host, for repositories
+	// that have none in repositories.yaml and do not authenticate
+	// with a token.
+	ChartRepoCredentialsFile string
+	// ChartRepoCredentialsDefault allows the credentials of the
+	// `default` entry of the ChartRepoCredentialsFile to be used for
+	// hosts without an entry of their own.
+	ChartRepoCredentialsDefault bool
+	// MirrorSyncWorkers is the number of git mirrors that are
+	// refreshed concurrently.
+	MirrorSyncWorkers int
+	// MirrorBreakerThreshold is the number of consecutive times
+	// refreshing a git mirror has to fail before it is not used for
+	// the MirrorBreakerCooldown; zero disables the circuit breaker.
+	MirrorBreakerThreshold int
+	// MirrorBreakerCooldown is the duration for which a git mirror
+	// is not used once its circuit breaker opened.
+	MirrorBreakerCooldown time.Duration
+	// GitSSHConfigDir is the directory the SSH keys of git chart
+	// sources that refer to one in a secret are written to, with the
+	// SSH config that makes them used for their mirrors; the SSH
+	// config of the operator has to include the `*.conf` files in it.
+	GitSSHConfigDir string
+	// GitCredentialsDir is the directory the GitHub App installation
+	// tokens of git chart sources are written to, for the credential
+	// helper of the operator to hand to git.
+	GitCredentialsDir string
+	// TillerNamespace is the namespace of the release storage of
+	// Tiller, in which the revisions of releases that exceed their
+	// maxHistory are deleted.
+	TillerNamespace string
+	// SkipDryRun decides if a release should be upgraded on changes
+	// to the HelmRelease, the chart revision and the values alone,
+	// rather than on the outcome of a dry run.
+	SkipDryRun bool
+	// DiffFormat is the format of the logged diffs, DiffFormatCmp
+	// (the default) or DiffFormatUnified.
+	DiffFormat string
+	// DiffContextLines is the number of unchanged lines around the
+	// changes of the logged diffs; zero keeps all lines of go-cmp
+	// diffs, and three lines of unified diffs.
+	DiffContextLines int
+	// StalledThreshold is the number of consecutive times a release
+	// has to fail with the same reason before it is marked as
+	// stalled; zero disables the Stalled condition.
+	StalledThreshold int64
+	// SkipSchemaValidation disables the validation of the values
+	// against the JSON schema of the chart for all HelmReleases.
+	SkipSchemaValidation bool
+	// MaxConcurrentHelmOps is the number of Helm operations that
+	// mutate the cluster (installs, upgrades, rollbacks and
+	// deletions) allowed to run at once across all releases; zero
+	// does not bound them. Dry runs are not bounded.
+	MaxConcurrentHelmOps int
+	// FailureBackoff is the delay before a release that failed is
+	// attempted again, which doubles with every consecutive failure;
+	// zero disables the backoff.
+	FailureBackoff time.Duration
+	// FailureBackoffMax caps the delay before a release that failed
+	// is attempted again.
+	FailureBackoffMax time.Duration
+	// SourceRequeueDelay is the delay after which a release of which
+	// the chart source is not ready yet (e.g. a git repo that has not
+	// been mirrored yet) is attempted again.
+	SourceRequeueDelay time.Duration
+	// TrackResources adds the antecedent annotation to the resources
+	// of releases as rendered, and reports the resources that lack
+	// it when a release has diverged.
+	TrackResources bool
+	// EstablishCRDs creates the CRDs in the crds/ directories of
+	// charts that do not exist yet before their release, and waits
+	// for them to become established.
+	EstablishCRDs bool
+	// ChartCacheMaxAge is the duration after which charts from Helm
+	// repos that have not been used are evicted from the chart
+	// cache; zero disables the eviction by age.
+	ChartCacheMaxAge time.Duration
+	// URLChartRefreshInterval is the interval at which chart archives
+	// from URLs without a checksum are downloaded again, to pick up
+	// changes to them; zero downloads them once.
+	URLChartRefreshInterval time.Duration
+	// ChartCacheMaxSize is the size in bytes of the chart cache
+	// beyond which the least recently used charts are evicted from
+	// it; zero disables the eviction by size.
+	ChartCacheMaxSize int64
+	// ChartMaxSize is the size in bytes beyond which the download of
+	// a chart from a Helm repo is aborted; zero means no limit.
+	ChartMaxSize int64
+	// AzureManagedIdentity allows the bucket secrets of chart
+	// sources to ask for the managed identity of the operator for
+	// Azure Blob Storage.
+	AzureManagedIdentity bool
+	// AWSOperatorCredentials allows charts in S3 buckets to be
+	// fetched with the AWS credentials of the operator, from its
+	// environment or the IAM role of its service account, when their
+	// bucket secret has none.
+	AWSOperatorCredentials bool
+	// GCPOperatorCredentials allows charts in GCS buckets to be
+	// fetched with the Google credentials of the operator, from
+	// GOOGLE_APPLICATION_CREDENTIALS or its workload identity, when
+	// their bucket secret has none.
+	GCPOperatorCredentials bool
+	// ReleaseDefaults are the settings HelmReleases inherit unless
+	// they set them themselves.
+	ReleaseDefaults ReleaseDefaults
+	// ReleaseSelector selects the HelmReleases by their labels that
+	// are reconciled, so that several operators can share the
+	// HelmReleases of a cluster; nil selects all of them.
+	ReleaseSelector labels.Selector
+	// LeaderElection configures the election of the replica that
+	// reconciles releases, for RunLeaderElection.
+	LeaderElection LeaderElection
 }
 
 func (c Config) WithDefaults() Config {
 	if c.ChartCache == "" {
 		c.ChartCache = "/tmp"
 	}
+	if c.DryRunReleasePrefix == "" {
+		c.DryRunReleasePrefix = release.DefaultDryRunReleasePrefix
+	}
+	if c.DependencyUpdateTimeout == 0 {
+		c.DependencyUpdateTimeout = defaultDependencyUpdateTimeout
+	}
+	if c.ReleaseTimeout == 0 {
+		c.ReleaseTimeout = defaultReleaseTimeout
+	}
+	if c.DryRunTimeout <= 0 {
+		c.DryRunTimeout = DefaultDryRunTimeout
+	}
+	if c.SourceRequeueDelay <= 0 {
+		c.SourceRequeueDelay = defaultSourceRequeueDelay
+	}
+	if c.DiffFormat == "" {
+		c.DiffFormat = DiffFormatCmp
+	}
+	if c.MirrorSyncWorkers <= 0 {
+		c.MirrorSyncWorkers = defaultMirrorSyncWorkers
+	}
+	if c.MirrorBreakerCooldown <= 0 {
+		c.MirrorBreakerCooldown = defaultMirrorBreakerCooldown
+	}
+	if c.GitSSHConfigDir == "" {
+		c.GitSSHConfigDir = defaultGitSSHConfigDir
+	}
+	if c.GitCredentialsDir == "" {
+		c.GitCredentialsDir = defaultGitCredentialsDir
+	}
 	return c
 }
 
 // clone puts a local git clone together with its state (head
 // revision), so we can keep track of when it needs to be updated.
 type clone struct {
-	export *git.Export
-	remote string
-	ref    string
-	head   string
+	export     *git.Export
+	remote     string
+	ref        string
+	head       string
+	submodules bool
 }
 
 // ReleaseQueue is an add-only workqueue.RateLimitingInterface
 type ReleaseQueue interface {
 	AddRateLimited(item interface{})
+	AddAfter(item interface{}, duration time.Duration)
 }
 
 type ChartChangeSync struct {
-	logger       log.Logger
-	kubeClient   kubernetes.Clientset
-	ifClient     ifclientset.Clientset
-	hrLister     iflister.HelmReleaseLister
-	release      *release.Release
-	releaseQueue ReleaseQueue
-	config       Config
+	logger        log.Logger
+	kubeClient    kubernetes.Clientset
+	ifClient      ifclientset.Clientset
+	hrLister      iflister.HelmReleaseLister
+	dynamicClient dynamic.Interface
+	release       *release.Release
+	releaseQueue  ReleaseQueue
+	config        Config
 
 	mirrors *git.Mirrors
 
 	clonesMu sync.Mutex
 	clones   map[string]clone
 
+	reconciledMu sync.Mutex
+	reconciled   map[string]reconcileInputs
+
+	reconcilingMu sync.Mutex
+	reconciling   map[string]*helmfluxv1.HelmRelease
+
+	statusBatchesMu sync.Mutex
+	statusBatches   map[string]*status.Batch
+
+	mirrorBreakersMu sync.Mutex
+	mirrorBreakers   map[string]*mirrorBreaker
+
+	healthMu           sync.Mutex
+	lastReconcile      time.Time
+	mirrorSyncFailures int
+	mirrorSyncErr      error
+	standby            bool
+
+	shutdownMu   sync.Mutex
+	shuttingDown bool
+	inflight     sync.WaitGroup
+
+	helmOps helmOps
+
+	repoTokens      repoTokens
+	githubAppTokens githubAppTokens
+
+	charts chartCache
+
+	backoffMu   sync.Mutex
+	backoffs    map[string]*failureBackoff
+	sourceWaits map[string]int
+
+	resultsMu sync.Mutex
+	results   map[string]*ReconcileResult
+
+	depHomesMu sync.Mutex
+	depHomes   map[string]*dependencyHelmHome
+
+	driftBackoffsMu sync.Mutex
+	driftBackoffs   map[string]*driftBackoff
+
+	// pushes signals the repositories pushed to, of which the mirrors
+	// are to be synced, are queued
+	pushes   chan struct{}
+	pushedMu sync.Mutex
+	pushed   map[string]bool
+
 	namespace string
+
+	recorder record.EventRecorder
 }
 
 func New(logger log.Logger, clients Clients, release *release.Release, releaseQueue ReleaseQueue, config Config, namespace string) *ChartChangeSync {
 	return &ChartChangeSync{
-		logger:       logger,
-		kubeClient:   clients.KubeClient,
-		ifClient:     clients.IfClient,
-		hrLister:     clients.HrLister,
-		release:      release,
-		releaseQueue: releaseQueue,
-		config:       config.WithDefaults(),
-		mirrors:      git.NewMirrors(),
-		clones:       make(map[string]clone),
-		namespace:    namespace,
+		logger:        logger,
+		kubeClient:    clients.KubeClient,
+		ifClient:      clients.IfClient,
+		hrLister:      clients.HrLister,
+		dynamicClient: clients.DynamicClient,
+		release:       release,
+		releaseQueue:  releaseQueue,
+		config:        config.WithDefaults(),
+		mirrors:       git.NewMirrors(),
+		clones:        make(map[string]clone),
+		reconciled:    make(map[string]reconcileInputs),
+		reconciling:   make(map[string]*helmfluxv1.HelmRelease),
+		helmOps:       newHelmOps(config.MaxConcurrentHelmOps),
+		backoffs:      make(map[string]*failureBackoff),
+		pushes:        make(chan struct{}, 1),
+		namespace:     namespace,
+		recorder:      newEventRecorder(&clients.KubeClient),
+		// NB: start counting from now, so we have a full window to
+		// get to the first reconciliation
+		lastReconcile: time.Now(),
 	}
 }
 
@@ -159,8 +508,45 @@ func (chs *ChartChangeSync) Run(stopCh <-chan struct{}, errc chan error, wg *syn
 			wg.Done()
 		}()
 
+		// A nil channel never fires, which leaves the chart cache as
+		// is when no eviction is configured.
+		var evictCharts <-chan time.Time
+		if chs.config.ChartCacheMaxAge > 0 || chs.config.ChartCacheMaxSize > 0 {
+			ticker := time.NewTicker(chartCacheEvictionInterval)
+			defer ticker.Stop()
+			evictCharts = ticker.C
+		}
+		lagTicker := time.NewTicker(reconcileLagInterval)
+		defer lagTicker.Stop()
+		var lagging map[laggingRelease]bool
+		// The HelmReleases no operator selects are only looked for
+		// when sharing them with other operators.
+		var findUnselected <-chan time.Time
+		if chs.config.ReleaseSelector != nil {
+			chs.publishSelector()
+			ticker := time.NewTicker(unselectedReleasesInterval)
+			defer ticker.Stop()
+			findUnselected = ticker.C
+		}
+		// The SSH keys of HelmReleases that are gone are removed on
+		// the poll interval of git chart sources.
+		var pruneSSHConfigs <-chan time.Time
+		if chs.config.GitPollInterval > 0 {
+			ticker := time.NewTicker(chs.config.GitPollInterval)
+			defer ticker.Stop()
+			pruneSSHConfigs = ticker.C
+		}
+
 		for {
 			select {
+			case <-evictCharts:
+				chs.evictCharts()
+			case <-lagTicker.C:
+				lagging = chs.recordReconcileLag(lagging)
+			case <-findUnselected:
+				chs.reportUnselectedReleases()
+			case <-pruneSSHConfigs:
+				chs.pruneUnusedSSHConfigs()
 			case mirrorsChanged := <-chs.mirrors.Changes():
 				for mirror := range mirrorsChanged {
 					resources, err := chs.getCustomResourcesForMirror(mirror)
@@ -197,11 +583,27 @@ func (chs *ChartChangeSync) Run(stopCh <-chan struct{}, errc chan error, wg *syn
 					// makes use of the mirror
 					for _, hr := range resources {
 						ref := hr.Spec.ChartSource.GitChartSource.RefOrDefault(chs.config.GitDefaultRef)
-						path := hr.Spec.ChartSource.GitChartSource.Path
-						releaseName := hr.ReleaseName()
+						paths := hr.Spec.ChartSource.GitChartSource.Paths()
+						releaseName := chs.release.ReleaseName(hr)
 
+						// A semver range of tags follows the highest
+						// matching tag, which may have been added by
+						// the fetch that signalled the change.
 						ctx, cancel := context.WithTimeout(context.Background(), helmop.GitOperationTimeout)
-						refHead, err := repo.Revision(ctx, ref)
+						tag, err := resolveSemverTag(ctx, repo.Dir(), hr.Spec.ChartSource.GitChartSource)
+						cancel()
+						if err != nil {
+							chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonGitTagNotFound, err.Error())
+							chs.logger.Log("warning", "could not resolve semver range of tags while checking for changes", "resource", hr.ResourceID().String(), "repo", mirror, "ref", ref, "err", err)
+							continue
+						}
+						headRef := ref
+						if tag != "" {
+							headRef = "refs/tags/" + tag
+						}
+
+						ctx, cancel = context.WithTimeout(context.Background(), helmop.GitOperationTimeout)
+						refHead, err := repo.Revision(ctx, headRef)
 						cancel()
 						if err != nil {
 							chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonGitNotReady, "problem cloning from local git mirror: "+err.Error())
@@ -217,7 +619,7 @@ func (chs *ChartChangeSync) Run(stopCh <-chan struct{}, errc chan error, wg *syn
 
 						if ok { // found clone
 							ctx, cancel := context.WithTimeout(context.Background(), helmop.GitOperationTimeout)
-							commits, err := repo.CommitsBetween(ctx, cloneForChart.head, refHead, path)
+							commits, err := repo.CommitsBetween(ctx, cloneForChart.head, refHead, paths...)
 							cancel()
 							if err != nil {
 								chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonGitNotReady, "problem cloning from local git mirror: "+err.Error())
@@ -236,7 +638,28 @@ func (chs *ChartChangeSync) Run(stopCh <-chan struct{}, errc chan error, wg *syn
 								chs.logger.Log("warning", "could not clone from mirror while checking for changes", "resource", hr.ResourceID().String(), "repo", mirror, "ref", ref, "err", err)
 								continue
 							}
-							newCloneForChart := clone{remote: mirror, ref: ref, head: refHead, export: newClone}
+							submodules := hr.Spec.ChartSource.GitChartSource.RecurseSubmodules
+							if submodules {
+								// Relative submodules resolve against
+								// the remote of the mirror, so that they
+								// are fetched with its credentials, if any.
+								remote, err := chs.gitRemote(hr)
+								if err != nil {
+									newClone.Clean()
+									chs.gitCredentialsFailed(hr, err)
+									continue
+								}
+								ctx, cancel := context.WithTimeout(context.Background(), chs.config.GitTimeout)
+								err = updateSubmodules(ctx, newClone.Dir(), remote.URL)
+								cancel()
+								if err != nil {
+									newClone.Clean()
+									chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonSubmodulesFailed, "problem updating git submodules: "+err.Error())
+									chs.logger.Log("warning", "could not update submodules of clone", "resource", hr.ResourceID().String(), "repo", mirror, "ref", ref, "err", err)
+									continue
+								}
+							}
+							newCloneForChart := clone{remote: mirror, ref: ref, head: refHead, export: newClone, submodules: submodules}
 							chs.clonesMu.Lock()
 							chs.clones[releaseName] = newCloneForChart
 							chs.clonesMu.Unlock()
@@ -249,7 +672,7 @@ func (chs *ChartChangeSync) Run(stopCh <-chan struct{}, errc chan error, wg *syn
 							if err != nil {
 								continue
 							}
-							chs.logger.Log("info", "enqueing release upgrade due to change in git chart source", "resource", hr.ResourceID().String())
+							chs.logger.Log("info", "enqueing release upgrade due to change in git chart source", "resource", hr.ResourceID().String(), "ref", headRef)
 							chs.releaseQueue.AddRateLimited(cacheKey)
 						}
 					}
@@ -260,186 +683,609 @@ func (chs *ChartChangeSync) Run(stopCh <-chan struct{}, errc chan error, wg *syn
 			}
 		}
 	}()
-}
 
-func mirrorName(chartSource *helmfluxv1.GitChartSource) string {
-	return chartSource.GitURL // TODO(michael) this will not always be the case; e.g., per namespace, per auth
+	// The mirrors of the repositories pushed to are synced apart
+	// from the loop above, which handles the changes the syncs bring
+	// in.
+	wg.Add(1)
+	go func() {
+		defer runtime.HandleCrash()
+		defer wg.Done()
+		for {
+			select {
+			case <-chs.pushes:
+				chs.syncPushedMirrors()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
 }
 
 // maybeMirror starts mirroring the repo needed by a HelmRelease,
-// if necessary
-func (chs *ChartChangeSync) maybeMirror(hr helmfluxv1.HelmRelease) {
+// if necessary. It returns false if the credentials of the repo could
+// not be set up, which it records in the ChartFetched condition.
+func (chs *ChartChangeSync) maybeMirror(hr helmfluxv1.HelmRelease) bool {
 	chartSource := hr.Spec.ChartSource.GitChartSource
 	if chartSource != nil {
+		remote, err := chs.gitRemote(hr)
+		if err != nil {
+			chs.gitCredentialsFailed(hr, err)
+			return false
+		}
 		if ok := chs.mirrors.Mirror(
-			mirrorName(chartSource),
-			git.Remote{chartSource.GitURL}, git.Timeout(chs.config.GitTimeout), git.PollInterval(chs.config.GitPollInterval), git.ReadOnly,
+			mirrorName(hr.Namespace, chartSource),
+			remote, git.Timeout(chs.config.GitTimeout), git.PollInterval(chs.config.GitPollInterval), git.ReadOnly,
 		); !ok {
 			chs.logger.Log("info", "started mirroring repo", "repo", chartSource.GitURL)
 		}
 	}
+	return true
 }
 
 // CompareValuesChecksum recalculates the checksum of the values
 // and compares it to the last recorded checksum.
 func (chs *ChartChangeSync) CompareValuesChecksum(hr helmfluxv1.HelmRelease) bool {
-	chartPath, ok := "", false
-	if hr.Spec.ChartSource.GitChartSource != nil {
-		// We need to hold the lock until have compared the values,
-		// so that the clone doesn't get swapped out from under us.
-		chs.clonesMu.Lock()
-		defer chs.clonesMu.Unlock()
-		chartPath, _, ok = chs.getGitChartSource(hr)
-		if !ok {
-			return false
-		}
-	} else if hr.Spec.ChartSource.RepoChartSource != nil {
-		chartPath, _, ok = chs.getRepoChartSource(hr)
-		if !ok {
-			return false
-		}
-	}
-
-	values, err := release.Values(chs.kubeClient.CoreV1(), hr.Namespace, chartPath, hr.GetValuesFromSources(), hr.Spec.Values)
-	if err != nil {
+	chartPath, _, done, ok := chs.fetchChart(hr, true)
+	if !ok {
 		return false
 	}
+	defer done()
 
-	strValues, err := values.YAML()
+	checksum, err := chs.valuesChecksum(hr, chartPath)
 	if err != nil {
 		return false
 	}
 
-	return hr.Status.ValuesChecksum == release.ValuesChecksum([]byte(strValues))
+	return chs.matchesRecordedChecksum(hr, chartPath, checksum)
 }
 
 // ReconcileReleaseDef asks the ChartChangeSync to examine the release
 // associated with a HelmRelease, and install or upgrade the
-// release if the chart it refers to has changed.
+// release if the chart it refers to has changed. Only one
+// reconciliation runs for a HelmRelease at a time; when asked while
+// one runs, the HelmRelease is reconciled again once it finished.
 func (chs *ChartChangeSync) ReconcileReleaseDef(hr helmfluxv1.HelmRelease) {
+	chs.reconcileInto(hr, nil)
+}
+
+// reconcileInto is ReconcileReleaseDef, collecting the outcome of the
+// reconciliation into the given result if not nil.
+func (chs *ChartChangeSync) reconcileInto(hr helmfluxv1.HelmRelease, result *ReconcileResult) {
+	if hr.DeletionTimestamp == nil && !chs.Selected(hr) {
+		chs.logger.Log("info", "HelmRelease does not match the release selector, skipping", "resource", hr.ResourceID().String())
+		result.skip(ReasonNotSelected)
+		return
+	}
+	chs.reconcileCoalesced(hr, result, chs.reconcileDivergedSpec)
+}
+
+// reconcileDivergedSpec reconciles the given HelmRelease. If the spec
+// of the HelmRelease diverges before it is upgraded, the release is
+// examined again right away against the newer spec, as many times as
+// allowed.
+func (chs *ChartChangeSync) reconcileDivergedSpec(hr helmfluxv1.HelmRelease) {
+	chs.retryDivergedSpec(hr, chs.reconcileReleaseDef)
+}
+
+// retryDivergedSpec calls reconcile with the given HelmRelease, and
+// again with the newer one it returns, until it returns nil. Once
+// the retries are exhausted, reconcile is told not to retry.
+func (chs *ChartChangeSync) retryDivergedSpec(hr helmfluxv1.HelmRelease, reconcile func(helmfluxv1.HelmRelease, bool) *helmfluxv1.HelmRelease) {
+	for retries := 0; ; retries++ {
+		diverged := reconcile(hr, retries < chs.config.DivergedSpecRetries)
+		if diverged == nil {
+			return
+		}
+		chs.logger.Log("info", "HelmRelease spec has diverged since we calculated if we should upgrade, reconciling again", "resource", hr.ResourceID().String(), "retry", retries+1)
+		hr = *diverged
+	}
+}
+
+// reconcileReleaseDef examines and releases the given HelmRelease. It
+// returns the newer HelmRelease if the spec diverged before it was
+// upgraded and it may be retried, and nil otherwise.
+func (chs *ChartChangeSync) reconcileReleaseDef(hr helmfluxv1.HelmRelease, retry bool) (diverged *helmfluxv1.HelmRelease) {
+	// Do not start anything we may not be able to finish.
+	if !chs.beginReconcile() {
+		chs.logger.Log("info", "shutting down, skipping release", "resource", hr.ResourceID().String())
+		chs.collectSkip(hr, ReasonShuttingDown)
+		return
+	}
+	defer chs.endReconcile()
+	defer chs.batchStatusUpdates(hr)()
+	defer chs.recordReconcile()
 	defer chs.updateObservedGeneration(hr)
 
-	releaseName := hr.ReleaseName()
+	hr = chs.WithReleaseDefaults(hr)
+
+	// A requested reconciliation is not held back by the backoff, and
+	// is recorded as handled once done, so that it is not repeated.
+	requestedAt, requested := hr.ReconcileRequested()
+	if requested {
+		chs.logger.Log("info", "reconciliation requested", "resource", hr.ResourceID().String(), "requested-at", requestedAt)
+		defer chs.recordReconcileRequest(hr, requestedAt)
+	}
+
+	// A HelmRelease that is being deleted is only waiting for us to
+	// delete its release before it is removed.
+	if hr.DeletionTimestamp != nil {
+		chs.finalizeRelease(hr)
+		return
+	}
+	if err := chs.ensureFinalizer(hr); err != nil {
+		chs.logger.Log("warning", "unable to add finalizer", "resource", hr.ResourceID().String(), "err", err)
+		return
+	}
+
+	// Do not attempt a release that failed before its backoff delay
+	// passed; it has been requeued for when it did.
+	if wait := chs.backingOff(hr); wait > 0 && !requested {
+		chs.logger.Log("info", "backing off failing release", "resource", hr.ResourceID().String(), "retry-in", wait.Round(time.Second))
+		chs.collectSkip(hr, ReasonBackingOff)
+		return
+	}
+	defer chs.updateBackoff(hr)
+
+	releaseName := chs.release.ReleaseName(hr)
+
+	// Names with the dry-run prefix are reserved for the releases we
+	// use to determine if a release should be upgraded.
+	if strings.HasPrefix(releaseName, chs.config.DryRunReleasePrefix) {
+		msg := fmt.Sprintf("release name '%s' uses the prefix reserved for dry runs (%s)", releaseName, chs.config.DryRunReleasePrefix)
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonReleaseNameInvalid, msg)
+		chs.logger.Log("warning", msg, "resource", hr.ResourceID().String())
+		return
+	}
+
+	// Keep releases out of the namespaces they may not target.
+	if msg := targetNamespaceDenied(hr.GetTargetNamespace(), chs.config.AllowedTargetNamespaces, chs.config.DeniedTargetNamespaces); msg != "" {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonNamespaceDenied, msg)
+		chs.logger.Log("warning", msg, "resource", hr.ResourceID().String())
+		return
+	}
+
+	// Only one HelmRelease can manage a release; leave the release
+	// to the one that claimed its name first.
+	if msg, err := chs.releaseConflict(hr); err != nil {
+		chs.logger.Log("warning", "unable to check for release name conflicts", "resource", hr.ResourceID().String(), "err", err)
+		return
+	} else if msg != "" {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonReleaseConflict, msg)
+		chs.logger.Log("warning", msg, "resource", hr.ResourceID().String())
+		return
+	}
+
+	// Wait for the HelmReleases this release depends on to be
+	// released before installing or upgrading it.
+	if reason, msg := chs.checkDependencies(hr); reason != "" {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, reason, msg)
+		chs.logger.Log("info", "skipping release", "resource", hr.ResourceID().String(), "reason", msg)
+		if reason == ReasonDependencyNotReady {
+			if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil {
+				chs.releaseQueue.AddAfter(cacheKey, dependencyRequeueDelay)
+			}
+		}
+		return
+	}
+
+	// Make sure the target namespace exists, and is not on its way
+	// out, before installing or upgrading to it.
+	if reason, msg := ensureTargetNamespace(chs.kubeClient.CoreV1().Namespaces(), hr); reason != "" {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, reason, msg)
+		chs.logger.Log("warning", "skipping release", "resource", hr.ResourceID().String(), "reason", msg)
+		if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil {
+			chs.releaseQueue.AddAfter(cacheKey, namespaceRequeueDelay)
+		}
+		return
+	}
 
 	// Attempt to retrieve an upgradable release, in case no release
 	// or error is returned, install it.
 	rel, err := chs.release.GetUpgradableRelease(releaseName)
+	recovered := err != nil && chs.recoverPending(hr, releaseName, err)
+	if recovered {
+		rel, err = chs.release.GetUpgradableRelease(releaseName)
+	}
 	if err != nil {
 		chs.logger.Log("warning", "unable to proceed with release", "resource", hr.ResourceID().String(), "release", releaseName, "err", err)
 		return
 	}
+	debug := chs.debugLogger(hr)
+	debug.Log("debug", "examining release", "release", releaseName, "exists", rel != nil, "generation", hr.Generation)
 
-	opts := release.InstallOptions{DryRun: false}
+	opts := chs.installOptions(hr, false)
 
-	chartPath, chartRevision, ok := "", "", false
-	if hr.Spec.ChartSource.GitChartSource != nil {
-		// We need to hold the lock until after we're done releasing
-		// the chart, so that the clone doesn't get swapped out from
-		// under us. TODO(michael) consider having a lock per clone.
-		chs.clonesMu.Lock()
-		defer chs.clonesMu.Unlock()
-		chartPath, chartRevision, ok = chs.getGitChartSource(hr)
-		if !ok {
+	// Hold on to the chart until after we're done releasing it.
+	chartPath, chartRevision, done, ok := chs.fetchChart(hr, true)
+	if !ok {
+		return
+	}
+	defer done()
+	reason, msg := chartFetchedMessage(hr, chartPath)
+	chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionTrue, reason, msg)
+	chs.sourceReady(hr)
+
+	debug.Log("debug", "chart is ready", "release", releaseName, "chart", chartPath, "revision", chartRevision)
+
+	if rel == nil {
+		if !hr.Spec.InstallOutsideMaintenanceWindows && chs.deferredOutsideWindow(hr, "install") {
 			return
 		}
-		chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionTrue, ReasonCloned, "successfully cloned git repo")
-	} else if hr.Spec.ChartSource.RepoChartSource != nil {
-		chartPath, chartRevision, ok = chs.getRepoChartSource(hr)
-		if !ok {
+		// Tell a release that was deleted by other means apart from
+		// one that was never installed, so the tampering is noticed.
+		deleted, err := chs.release.GetRelease(releaseName)
+		if err != nil {
+			chs.logger.Log("warning", "unable to proceed with release", "resource", hr.ResourceID().String(), "release", releaseName, "err", err)
 			return
 		}
-		chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionTrue, ReasonDownloaded, "chart fetched: "+filepath.Base(chartPath))
-	}
-
-	if rel == nil {
-		_, checksum, err := chs.release.Install(chartPath, releaseName, hr, release.InstallAction, opts, &chs.kubeClient)
+		// A pending release we deleted ourselves did not disappear
+		disappeared := !recovered && releaseDisappeared(hr, releaseName, deleted)
+		if disappeared {
+			msg := fmt.Sprintf("release '%s' was deleted by other means, installing it again", releaseName)
+			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonReleaseDisappeared, msg)
+			chs.logger.Log("warning", msg, "resource", hr.ResourceID().String())
+			// A release that was not purged keeps its name in use
+			opts.ReuseName = deleted != nil
+		}
+		if !chs.authorized(hr, chartPath) || !chs.manifestNamespacesAllowed(hr, chartPath) {
+			return
+		}
+		// Without a release to compare with, the dry run is only
+		// there to tell if the install would succeed.
+		if chs.config.DryRunOnly {
+			tempRelName := release.DryRunReleaseName(chs.config.DryRunReleasePrefix, hr)
+			if _, _, err := chs.install(chartPath, tempRelName, hr, release.InstallAction, chs.installOptions(hr, true)); err != nil {
+				reason := failureReason(err, ReasonInstallFailed)
+				chs.setFailureCondition(hr, helmfluxv1.HelmReleaseReleased, reason, "dry-run only: install would fail: "+err.Error(), err)
+				chs.logger.Log("warning", "dry run of install failed", "resource", hr.ResourceID().String(), "err", err)
+				return
+			}
+		}
+		if chs.dryRunOnly(hr, helmfluxv1.HelmReleaseReleased, fmt.Sprintf("install release '%s'", releaseName)) {
+			return
+		}
+		installed, checksum, err := chs.install(chartPath, releaseName, hr, release.InstallAction, opts)
 		if err != nil {
-			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonInstallFailed, err.Error())
+			reason := failureReason(err, ReasonInstallFailed)
+			chs.setFailureCondition(hr, helmfluxv1.HelmReleaseReleased, reason, err.Error(), err)
+			chs.recordAction(hr, helmfluxv1.HelmReleaseActionInstall, reason, chartRevision)
 			chs.logger.Log("warning", "failed to install chart", "resource", hr.ResourceID().String(), "err", err)
 			return
 		}
-		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, ReasonSuccess, "helm install succeeded")
-		if err = status.SetReleaseRevision(chs.ifClient.HelmV1().HelmReleases(hr.Namespace), hr, chartRevision); err != nil {
+		debug.Log("debug", "installed release", "release", releaseName, "version", installed.GetVersion(), "manifest", chs.debugManifest(hr, chartPath, installed.GetManifest()))
+		chs.recordReconciled(hr, reconcileInputs{
+			generation:     hr.Generation,
+			chartRevision:  chartRevision,
+			valuesChecksum: checksum,
+			releaseVersion: installed.GetVersion(),
+		})
+		chs.pruneHistory(hr, releaseName)
+		msg := "helm install succeeded"
+		if disappeared {
+			msg += " (the release had been deleted by other means)"
+		}
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, ReasonSuccess, msg)
+		chs.recordAction(hr, helmfluxv1.HelmReleaseActionInstall, ReasonSuccess, chartRevision)
+		if err = status.SetReleaseRevision(chs.statusClient(hr), hr, chartRevision); err != nil {
 			chs.logger.Log("warning", "could not update the release revision", "resource", hr.ResourceID().String(), "err", err)
 		}
-		if err = status.SetValuesChecksum(chs.ifClient.HelmV1().HelmReleases(hr.Namespace), hr, checksum); err != nil {
+		if err = status.SetLastSuccessfulRevision(chs.statusClient(hr), hr, installed.GetVersion()); err != nil {
+			chs.logger.Log("warning", "could not update the last successful revision", "resource", hr.ResourceID().String(), "err", err)
+		}
+		chs.recordHelmVersion(hr)
+		chs.recordChartDigest(hr, chartPath)
+		if err = status.SetValuesChecksum(chs.statusClient(hr), hr, checksum); err != nil {
 			chs.logger.Log("warning", "could not update the values checksum", "namespace", hr.Namespace, "resource", hr.Name, "err", err)
 		}
+		chs.testRelease(hr, releaseName)
 		return
 	}
 
 	if !chs.release.OwnedByHelmRelease(rel, hr) {
 		msg := fmt.Sprintf("release '%s' does not belong to HelmRelease", releaseName)
-		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonUpgradeFailed, msg)
-		chs.logger.Log("warning", msg+", this may be an indication that multiple HelmReleases with the same release name exist", "resource", hr.ResourceID().String())
-		return
+		switch hr.GetConflictStrategy() {
+		case helmfluxv1.ConflictStrategyAdopt:
+			if chs.dryRunOnly(hr, helmfluxv1.HelmReleaseReleased, fmt.Sprintf("adopt release '%s'", releaseName)) {
+				return
+			}
+			revision := rel.GetChart().GetMetadata().GetVersion()
+			if err := chs.release.Adopt(rel, hr); err != nil {
+				msg := fmt.Sprintf("failed to adopt release '%s': %s", releaseName, err)
+				chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonAdoptFailed, msg)
+				chs.recordEvent(hr, v1.EventTypeWarning, ReasonAdoptFailed, msg)
+				chs.recordAction(hr, helmfluxv1.HelmReleaseActionAdopt, ReasonAdoptFailed, revision)
+				chs.logger.Log("warning", "failed to adopt release", "resource", hr.ResourceID().String(), "release", releaseName, "err", err)
+				return
+			}
+			chs.recordEvent(hr, v1.EventTypeNormal, ReasonReleaseAdopted, fmt.Sprintf("adopted release '%s'", releaseName))
+			chs.recordAction(hr, helmfluxv1.HelmReleaseActionAdopt, ReasonSuccess, revision)
+			chs.logger.Log("info", "adopted release", "resource", hr.ResourceID().String(), "release", releaseName)
+		case helmfluxv1.ConflictStrategyFail:
+			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonReleaseConflict, msg)
+			chs.recordEvent(hr, v1.EventTypeWarning, ReasonReleaseConflict, msg)
+			chs.logger.Log("warning", msg, "resource", hr.ResourceID().String())
+			return
+		default:
+			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonReleaseConflict, msg)
+			chs.logger.Log("warning", msg+", this may be an indication that multiple HelmReleases with the same release name exist", "resource", hr.ResourceID().String())
+			return
+		}
 	}
 
-	changed, err := chs.shouldUpgrade(chartPath, rel, hr)
+	// A release that is not to be upgraded is left as it is, apart
+	// from correcting its drift if asked to.
+	var changed bool
+	switch {
+	case !hr.Spec.Upgrade.Enabled():
+		chs.upgradeDisabled(hr, releaseName, rel)
+	case chs.config.SkipDryRun || hr.Spec.Upgrade.GetSkipDryRun():
+		changed, err = chs.changedSinceReconcile(hr, chartPath, chartRevision, rel)
+	default:
+		changed, err = chs.shouldUpgrade(chartPath, chartRevision, rel, hr)
+	}
 	if err != nil {
+		if release.IsValuesInvalid(err) {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonValuesInvalid, err.Error())
+		}
+		if release.IsValuesTemplate(err) {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonValuesTemplateFailed, err.Error())
+		}
 		chs.logger.Log("warning", "unable to determine if release has changed", "resource", hr.ResourceID().String(), "err", err)
 		return
 	}
+	debug.Log("debug", "examined release", "release", releaseName, "changed", changed)
 	if changed {
+		if chs.upgradeRetriesExhausted(hr, releaseName, chartPath, chartRevision) {
+			return
+		}
+		if chs.deferredOutsideWindow(hr, "upgrade") {
+			return
+		}
 		cHr, err := chs.ifClient.HelmV1().HelmReleases(hr.Namespace).Get(hr.Name, metav1.GetOptions{})
 		if err != nil {
 			chs.logger.Log("warning", "failed to retrieve HelmRelease scheduled for upgrade", "resource", hr.ResourceID().String(), "err", err)
 			return
 		}
-		if diff := cmp.Diff(hr.Spec, cHr.Spec); diff != "" {
+		if diff := cmp.Diff(hr.Spec, chs.WithReleaseDefaults(*cHr).Spec); diff != "" {
+			if retry {
+				return cHr
+			}
 			chs.logger.Log("warning", "HelmRelease spec has diverged since we calculated if we should upgrade, skipping upgrade", "resource", hr.ResourceID().String())
 			return
 		}
-		_, checksum, err := chs.release.Install(chartPath, releaseName, hr, release.UpgradeAction, opts, &chs.kubeClient)
+		if !chs.authorized(hr, chartPath) || !chs.manifestNamespacesAllowed(hr, chartPath) {
+			return
+		}
+		if chs.dryRunOnly(hr, helmfluxv1.HelmReleaseReleased, fmt.Sprintf("upgrade release '%s'", releaseName)) {
+			return
+		}
+		upgraded, checksum, err := chs.install(chartPath, releaseName, hr, release.UpgradeAction, opts)
 		if err != nil {
-			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonUpgradeFailed, err.Error())
-			if err = status.SetValuesChecksum(chs.ifClient.HelmV1().HelmReleases(hr.Namespace), hr, checksum); err != nil {
+			msg := err.Error()
+			if opts.CleanupOnFail {
+				msg += chs.cleanupOutcome(hr, rel)
+			}
+			reason := failureReason(err, ReasonUpgradeFailed)
+			chs.setFailureCondition(hr, helmfluxv1.HelmReleaseReleased, reason, msg, err)
+			chs.recordAction(hr, helmfluxv1.HelmReleaseActionUpgrade, reason, chartRevision)
+			if err := status.SetValuesChecksum(chs.statusClient(hr), hr, checksum); err != nil {
 				chs.logger.Log("warning", "could not update the values checksum", "namespace", hr.Namespace, "resource", hr.Name, "err", err)
 			}
 			chs.logger.Log("warning", "failed to upgrade chart", "resource", hr.ResourceID().String(), "err", err)
-			chs.RollbackRelease(hr)
+			if why := rollbackSkipped(hr, err, reason); why != "" {
+				chs.setCondition(hr, helmfluxv1.HelmReleaseRolledBack, v1.ConditionFalse, ReasonRollbackSkipped, "rollback skipped: "+why)
+				chs.recordAction(hr, helmfluxv1.HelmReleaseActionSkip, ReasonRollbackSkipped, hr.Status.Revision)
+				chs.logger.Log("info", "rollback skipped", "resource", hr.ResourceID().String(), "why", why)
+				return
+			}
+			chs.RollbackRelease(hr, chartRevision, checksum)
 			return
 		}
-		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, ReasonSuccess, "helm upgrade succeeded")
-		if err = status.SetReleaseRevision(chs.ifClient.HelmV1().HelmReleases(hr.Namespace), hr, chartRevision); err != nil {
+		debug.Log("debug", "upgraded release", "release", releaseName, "version", upgraded.GetVersion(), "manifest", chs.debugManifest(hr, chartPath, upgraded.GetManifest()))
+		chs.recordReconciled(hr, reconcileInputs{
+			generation:     hr.Generation,
+			chartRevision:  chartRevision,
+			valuesChecksum: checksum,
+			releaseVersion: upgraded.GetVersion(),
+		})
+		chs.pruneHistory(hr, releaseName)
+		msg := "helm upgrade succeeded"
+		if opts.Force {
+			msg += " (resources were replaced by force)"
+		}
+		if opts.RecreatePods {
+			msg += " (pods were recreated)"
+		}
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, ReasonSuccess, msg)
+		chs.recordAction(hr, helmfluxv1.HelmReleaseActionUpgrade, ReasonSuccess, chartRevision)
+		if err = status.SetReleaseRevision(chs.statusClient(hr), hr, chartRevision); err != nil {
 			chs.logger.Log("warning", "could not update the release revision", "resource", hr.ResourceID().String(), "err", err)
 		}
-		if err = status.SetValuesChecksum(chs.ifClient.HelmV1().HelmReleases(hr.Namespace), hr, checksum); err != nil {
+		if err = status.SetLastSuccessfulRevision(chs.statusClient(hr), hr, upgraded.GetVersion()); err != nil {
+			chs.logger.Log("warning", "could not update the last successful revision", "resource", hr.ResourceID().String(), "err", err)
+		}
+		chs.recordHelmVersion(hr)
+		chs.recordChartDigest(hr, chartPath)
+		if err = status.SetValuesChecksum(chs.statusClient(hr), hr, checksum); err != nil {
 			chs.logger.Log("warning", "could not update the values checksum", "namespace", hr.Namespace, "resource", hr.Name, "err", err)
 		}
+		if !chs.testRelease(hr, releaseName) {
+			chs.rollbackRelease(hr, chartRevision, checksum, chs.release.RollbackFailedTests)
+			return
+		}
+		if err = status.ResetRollbackCount(chs.statusClient(hr), hr); err != nil {
+			chs.logger.Log("warning", "could not reset the rollback count", "resource", hr.ResourceID().String(), "err", err)
+		}
 		return
 	}
+
+	if hr.Spec.DriftDetection && !chs.config.DryRunOnly {
+		chs.correctDrift(hr, rel)
+	}
+	return nil
 }
 
-// RollbackRelease rolls back a helm release
-func (chs *ChartChangeSync) RollbackRelease(hr helmfluxv1.HelmRelease) {
+// upgradeRetriesExhausted returns true, and records so, if the
+// upgrades of the current generation of the given HelmRelease to the
+// given chart revision and its current values failed and were rolled
+// back more often than they may be attempted again. A requested
+// reconciliation attempts the upgrade once more.
+func (chs *ChartChangeSync) upgradeRetriesExhausted(hr helmfluxv1.HelmRelease, releaseName, chartPath, chartRevision string) bool {
+	if _, requested := hr.ReconcileRequested(); requested || hr.Status.RollbackCount == 0 {
+		return false
+	}
+	checksum, err := chs.valuesChecksum(hr, chartPath)
+	if err != nil || !status.UpgradeRetriesExhausted(hr, chartRevision, checksum) {
+		return false
+	}
+	msg := fmt.Sprintf("upgrade of release '%s' failed and was rolled back %d times, not attempting it again until the HelmRelease, its chart or its values change",
+		releaseName, hr.Status.RollbackCount)
+	chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonUpgradeRetriesExhausted, msg)
+	chs.logger.Log("warning", msg, "resource", hr.ResourceID().String())
+	return true
+}
+
+// upgradeDisabled records that the release of the given HelmRelease
+// is not upgraded because upgrades are disabled for it. The release
+// counts as released as long as it is deployed.
+func (chs *ChartChangeSync) upgradeDisabled(hr helmfluxv1.HelmRelease, releaseName string, rel *hapi_release.Release) {
+	st := v1.ConditionTrue
+	if rel.GetInfo().GetStatus().GetCode() != hapi_release.Status_DEPLOYED {
+		st = v1.ConditionUnknown
+	}
+	msg := fmt.Sprintf("upgrades are disabled, release '%s' is left at revision %d", releaseName, rel.GetVersion())
+	chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, st, ReasonUpgradeDisabled, msg)
+	chs.logger.Log("info", "upgrades are disabled, skipping upgrade", "resource", hr.ResourceID().String(), "release", releaseName)
+}
+
+// authorized returns if the service account of the HelmRelease, if
+// it has one, is allowed to apply the resources of its release, and
+// records why in the Released condition if it is not.
+func (chs *ChartChangeSync) authorized(hr helmfluxv1.HelmRelease, chartPath string) bool {
+	if hr.Spec.ServiceAccountName == "" {
+		return true
+	}
+	if err := chs.authorizeRelease(hr, chartPath); err != nil {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonUnauthorized, err.Error())
+		chs.logger.Log("warning", "release not authorized", "resource", hr.ResourceID().String(), "serviceAccount", hr.Spec.ServiceAccountName, "err", err)
+		return false
+	}
+	return true
+}
+
+// failureReason returns the reason for the failure of an install
+// or upgrade with the given error, which is the given reason unless
+// the error falls into a more specific category.
+func failureReason(err error, reason string) string {
+	if release.IsCRDsNotEstablished(err) {
+		return ReasonCRDsNotEstablished
+	}
+	switch release.Classify(err) {
+	case release.ErrorCategoryTimeout:
+		return ReasonTimeout
+	case release.ErrorCategoryValidation:
+		if release.IsValuesInvalid(err) {
+			return ReasonValuesInvalid
+		}
+		if release.IsValuesPath(err) {
+			return ReasonValuesPathFailed
+		}
+		if release.IsValuesTemplate(err) {
+			return ReasonValuesTemplateFailed
+		}
+		return ReasonValidationFailed
+	case release.ErrorCategoryTemplate:
+		return ReasonTemplateFailed
+	case release.ErrorCategoryAuth:
+		return ReasonForbidden
+	case release.ErrorCategoryNetwork:
+		return ReasonNetworkFailed
+	}
+	return reason
+}
+
+// RollbackRelease rolls back a helm release of which the upgrade to
+// the given chart revision and values checksum failed.
+func (chs *ChartChangeSync) RollbackRelease(hr helmfluxv1.HelmRelease, chartRevision, valuesChecksum string) {
+	chs.rollbackRelease(hr, chartRevision, valuesChecksum, chs.release.Rollback)
+}
+
+// rollbackRelease rolls back the helm release of the HelmRelease
+// with the given rollback, if rollbacks are enabled, and counts the
+// rollback for the upgrade to the given chart revision and values
+// checksum.
+func (chs *ChartChangeSync) rollbackRelease(hr helmfluxv1.HelmRelease, chartRevision, valuesChecksum string, rollback func(string, helmfluxv1.HelmRelease) (*hapi_release.Release, error)) {
 	defer chs.updateObservedGeneration(hr)
 
-	if !hr.Spec.Rollback.Enable {
+	if !hr.Spec.Rollback.Enabled() {
 		return
 	}
 
-	releaseName := hr.ReleaseName()
-	_, err := chs.release.Rollback(releaseName, hr)
+	releaseName := chs.release.ReleaseName(hr)
+	if chs.dryRunOnly(hr, helmfluxv1.HelmReleaseRolledBack, fmt.Sprintf("roll back release '%s'", releaseName)) {
+		return
+	}
+	chs.helmOps.acquire()
+	_, err := rollback(releaseName, hr)
+	chs.helmOps.done()
+	if err := status.IncrementRollbackCount(chs.statusClient(hr), hr, chartRevision, valuesChecksum); err != nil {
+		chs.logger.Log("warning", "could not update the rollback count", "resource", hr.ResourceID().String(), "err", err)
+	}
 	if err != nil {
 		chs.logger.Log("warning", "unable to rollback chart release", "resource", hr.ResourceID().String(), "release", releaseName, "err", err)
 		chs.setCondition(hr, helmfluxv1.HelmReleaseRolledBack, v1.ConditionFalse, ReasonRollbackFailed, err.Error())
+		chs.recordAction(hr, helmfluxv1.HelmReleaseActionRollback, ReasonRollbackFailed, hr.Status.Revision)
+		return
 	}
 	chs.setCondition(hr, helmfluxv1.HelmReleaseRolledBack, v1.ConditionTrue, ReasonSuccess, "helm rollback succeeded")
+	chs.recordAction(hr, helmfluxv1.HelmReleaseActionRollback, ReasonSuccess, hr.Status.Revision)
+}
+
+// testRelease runs the tests of the helm release of the HelmRelease,
+// if enabled, and records the result in the Tested condition. It
+// returns false if the tests failed and the failure should not be
+// ignored.
+func (chs *ChartChangeSync) testRelease(hr helmfluxv1.HelmRelease, releaseName string) bool {
+	if !hr.Spec.Test.Enable {
+		return true
+	}
+	if err := chs.release.Test(releaseName, hr); err != nil {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseTested, v1.ConditionFalse, ReasonTestFailed, err.Error())
+		chs.logger.Log("warning", "release tests failed", "resource", hr.ResourceID().String(), "release", releaseName, "err", err)
+		return hr.Spec.Test.IgnoreFailures
+	}
+	chs.setCondition(hr, helmfluxv1.HelmReleaseTested, v1.ConditionTrue, ReasonSuccess, "helm test succeeded")
+	return true
 }
 
 // DeleteRelease deletes the helm release associated with a
 // HelmRelease. This exists mainly so that the operator code can
 // call it when it is handling a resource deletion.
 func (chs *ChartChangeSync) DeleteRelease(hr helmfluxv1.HelmRelease) {
-	// FIXME(michael): these may need to stop mirroring a repo.
-	name := hr.ReleaseName()
-	err := chs.release.Delete(name)
+	name := chs.release.ReleaseName(hr)
+	var err error
+	if !chs.dryRunOnly(hr, helmfluxv1.HelmReleaseDeleted, fmt.Sprintf("delete release '%s'", name)) {
+		err = chs.deleteRelease(name, hr)
+	}
 	if err != nil {
+		msg := fmt.Sprintf("failed to delete release '%s': %s", name, err)
+		chs.setCondition(hr, helmfluxv1.HelmReleaseDeleted, v1.ConditionFalse, ReasonDeleteFailed, msg)
+		chs.recordEvent(hr, v1.EventTypeWarning, ReasonDeleteFailed, msg)
 		chs.logger.Log("warning", "chart release not deleted", "resource", hr.ResourceID().String(), "release", name, "err", err)
 	}
+	chs.removeClone(hr)
+	chs.forgetReconciled(hr)
+	if hr.Spec.GitChartSource != nil && hr.Spec.GitChartSource.SSHSecretRef != nil {
+		chs.pruneUnusedSSHConfigs()
+	}
+}
 
-	// Remove the clone we may have for this HelmRelease
+// removeClone removes the clone we may have for the given
+// HelmRelease.
+func (chs *ChartChangeSync) removeClone(hr helmfluxv1.HelmRelease) {
+	// FIXME(michael): these may need to stop mirroring a repo.
+	name := chs.release.ReleaseName(hr)
 	chs.clonesMu.Lock()
 	cloneForChart, ok := chs.clones[name]
 	if ok {
@@ -451,12 +1297,12 @@ func (chs *ChartChangeSync) DeleteRelease(hr helmfluxv1.HelmRelease) {
 	chs.clonesMu.Unlock()
 }
 
-// SyncMirrors instructs all mirrors to refresh from their upstream.
+// SyncMirrors instructs all mirrors to refresh from their upstream,
+// concurrently.
 func (chs *ChartChangeSync) SyncMirrors() {
 	chs.logger.Log("info", "starting mirror sync")
-	for _, err := range chs.mirrors.RefreshAll(chs.config.GitTimeout) {
-		chs.logger.Log("error", fmt.Sprintf("failure while syncing mirror: %s", err))
-	}
+	errs := chs.syncMirrors()
+	chs.recordMirrorSync(errs)
 	chs.logger.Log("info", "finished syncing mirrors")
 }
 
@@ -464,7 +1310,7 @@ func (chs *ChartChangeSync) SyncMirrors() {
 // use of the given mirror from the lister.
 func (chs *ChartChangeSync) getCustomResourcesForMirror(mirror string) ([]helmfluxv1.HelmRelease, error) {
 	var hrs []helmfluxv1.HelmRelease
-	list, err := chs.hrLister.List(labels.Everything())
+	list, err := chs.selectedReleases()
 	if err != nil {
 		return nil, err
 	}
@@ -473,7 +1319,7 @@ func (chs *ChartChangeSync) getCustomResourcesForMirror(mirror string) ([]helmfl
 		if hr.Spec.GitChartSource == nil {
 			continue
 		}
-		if mirror != mirrorName(hr.Spec.GitChartSource) {
+		if mirror != mirrorName(hr.Namespace, hr.Spec.GitChartSource) {
 			continue
 		}
 		hrs = append(hrs, *hr)
@@ -481,34 +1327,202 @@ func (chs *ChartChangeSync) getCustomResourcesForMirror(mirror string) ([]helmfl
 	return hrs, nil
 }
 
-// setCondition saves the status of a condition.
+// setCondition saves the status of a condition, and of the aggregate
+// conditions derived from it.
 func (chs *ChartChangeSync) setCondition(hr helmfluxv1.HelmRelease, typ helmfluxv1.HelmReleaseConditionType, st v1.ConditionStatus, reason, message string) error {
-	hrClient := chs.ifClient.HelmV1().HelmReleases(hr.Namespace)
+	hrClient := chs.statusClient(hr)
 	condition := status.NewCondition(typ, st, reason, message)
-	return status.SetCondition(hrClient, hr, condition)
+	chs.recordOutcome(hr, typ, st, reason)
+	chs.collectCondition(hr, typ, st, reason, message)
+	return status.SetCondition(hrClient, hr, condition, chs.config.StalledThreshold)
+}
+
+// setFailureCondition sets the condition of the given type to false
+// with the given reason and message, and the category of the given
+// error the failure was caused by.
+func (chs *ChartChangeSync) setFailureCondition(hr helmfluxv1.HelmRelease, typ helmfluxv1.HelmReleaseConditionType, reason, message string, err error) error {
+	hrClient := chs.statusClient(hr)
+	condition := status.NewCondition(typ, v1.ConditionFalse, reason, message)
+	condition.ErrorCategory = string(release.Classify(err))
+	chs.recordOutcome(hr, typ, v1.ConditionFalse, reason)
+	chs.collectCondition(hr, typ, v1.ConditionFalse, reason, message)
+	return status.SetCondition(hrClient, hr, condition, chs.config.StalledThreshold)
+}
+
+// sourceNotReady marks the chart of the given HelmRelease as not
+// fetched because its source is not ready yet, and schedules it to be
+// examined again, rather than waiting for the next resync: shortly at
+// first, backing off while the source stays not ready.
+func (chs *ChartChangeSync) sourceNotReady(hr helmfluxv1.HelmRelease, msg string) {
+	chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonSourceNotReady, msg)
+	if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil && chs.releaseQueue != nil {
+		chs.releaseQueue.AddAfter(cacheKey, chs.sourceWaitDelay(cacheKey))
+	}
+}
+
+// recordEvent records an event of the given type for the given
+// HelmRelease, if there is an event recorder.
+func (chs *ChartChangeSync) recordEvent(hr helmfluxv1.HelmRelease, eventType, reason, message string) {
+	if chs.recorder != nil {
+		chs.recorder.Event(&hr, eventType, reason, message)
+	}
+}
+
+// recordAction records the outcome of the given action on the
+// release of the HelmRelease in its history.
+func (chs *ChartChangeSync) recordAction(hr helmfluxv1.HelmRelease, action helmfluxv1.HelmReleaseAction, reason, revision string) {
+	chs.collectAction(hr, action, reason)
+	hrClient := chs.statusClient(hr)
+	if err := status.AppendHistory(hrClient, hr, action, reason, revision); err != nil {
+		chs.logger.Log("warning", "could not update the history", "resource", hr.ResourceID().String(), "err", err)
+	}
+}
+
+// recordHelmVersion records the version of Helm that released the
+// given HelmRelease in its status.
+func (chs *ChartChangeSync) recordHelmVersion(hr helmfluxv1.HelmRelease) {
+	version, err := chs.release.HelmVersion()
+	if err != nil {
+		chs.logger.Log("warning", "could not get the Helm version", "resource", hr.ResourceID().String(), "err", err)
+		return
+	}
+	if err := status.SetHelmVersion(chs.statusClient(hr), hr, version); err != nil {
+		chs.logger.Log("warning", "could not update the Helm version", "resource", hr.ResourceID().String(), "err", err)
+	}
+}
+
+// recordReconcileRequest records the given value of the reconcile
+// request annotation of the given HelmRelease as handled.
+func (chs *ChartChangeSync) recordReconcileRequest(hr helmfluxv1.HelmRelease, requestedAt string) {
+	if err := status.SetLastHandledReconcileAt(chs.statusClient(hr), hr, requestedAt); err != nil {
+		chs.logger.Log("warning", "could not record the handled reconcile request", "resource", hr.ResourceID().String(), "err", err)
+	}
 }
 
 // updateObservedGeneration updates the observed generation of the
 // given HelmRelease to the generation.
 func (chs *ChartChangeSync) updateObservedGeneration(hr helmfluxv1.HelmRelease) error {
-	hrClient := chs.ifClient.HelmV1().HelmReleases(hr.Namespace)
+	hrClient := chs.statusClient(hr)
 
 	return status.SetObservedGeneration(hrClient, hr, hr.Generation)
 }
 
-func (chs *ChartChangeSync) getGitChartSource(hr helmfluxv1.HelmRelease) (string, string, bool) {
+// installOptions returns the options for installing or upgrading
+// the release of the given HelmRelease.
+func (chs *ChartChangeSync) installOptions(hr helmfluxv1.HelmRelease, dryRun bool) release.InstallOptions {
+	return release.InstallOptions{
+		Timeout:       hr.GetTimeoutOr(chs.config.ReleaseTimeout),
+		DryRun:        dryRun,
+		Force:         (hr.Spec.ForceUpgrade || hr.Spec.Upgrade.GetForce()) && !dryRun,
+		SkipCRDs:      hr.Spec.SkipCRDs,
+		PostRenderers: hr.Spec.PostRenderers,
+		CommonLabels:  hr.Spec.CommonLabels,
+		ReuseValues:   hr.Spec.Upgrade.GetReuseValues(),
+		ResetValues:   hr.Spec.ResetValues || hr.Spec.Upgrade.GetResetValues(),
+		CleanupOnFail: hr.Spec.Upgrade.GetCleanupOnFail() && !dryRun,
+		RecreatePods:  hr.Spec.Upgrade.GetRecreatePods() && !dryRun,
+
+		DisableHooks:         hr.Spec.DisableHooks,
+		DisableUpgradeHooks:  hr.Spec.Upgrade.GetDisableHooks(),
+		SkipSchemaValidation: chs.config.SkipSchemaValidation || hr.Spec.SkipSchemaValidation,
+		TrackResources:       chs.config.TrackResources,
+		EstablishCRDs:        chs.config.EstablishCRDs,
+	}
+}
+
+// fetchChart fetches the chart of the HelmRelease from its source,
+// and returns the path to it, its revision, and a func to call once
+// done with the chart. Until then the chart is held on to, so that it
+// does not get evicted or, for a chart from git, the clone swapped out
+// from under the caller. If the chart can not be fetched and report
+// is true, the reason is recorded in the conditions of the
+// HelmRelease; otherwise its status is left untouched.
+func (chs *ChartChangeSync) fetchChart(hr helmfluxv1.HelmRelease, report bool) (string, string, func(), bool) {
+	chartPath, chartRevision, ok := "", "", false
+	source := hr.Spec.ChartSource
+	switch {
+	case source.GitChartSource != nil:
+		// TODO(michael) consider having a lock per clone.
+		chs.clonesMu.Lock()
+		if chartPath, chartRevision, ok = chs.getGitChartSource(hr, report); !ok {
+			chs.clonesMu.Unlock()
+			return "", "", nil, false
+		}
+		return chartPath, chartRevision, chs.clonesMu.Unlock, true
+	case source.RepoChartSource != nil:
+		chartPath, chartRevision, ok = chs.getRepoChartSource(hr, report)
+	case source.ConfigMapChartSource != nil:
+		chartPath, chartRevision, ok = chs.getConfigMapChartSource(hr, report)
+	case source.SourceRefChartSource != nil:
+		chartPath, chartRevision, ok = chs.getSourceRefChartSource(hr, report)
+	case source.URLChartSource != nil:
+		chartPath, chartRevision, ok = chs.getURLChartSource(hr, report)
+	}
+	if !ok {
+		return "", "", nil, false
+	}
+	return chartPath, chartRevision, func() { chs.charts.release(chartPath) }, true
+}
+
+// chartFetchedMessage returns the reason and message of the
+// ChartFetched condition for the chart of the HelmRelease, fetched to
+// the given path.
+func chartFetchedMessage(hr helmfluxv1.HelmRelease, chartPath string) (string, string) {
+	source := hr.Spec.ChartSource
+	switch {
+	case source.GitChartSource != nil:
+		return ReasonCloned, "successfully cloned git repo"
+	case source.ConfigMapChartSource != nil:
+		return ReasonConfigMapChartLoaded, "chart loaded from config map " + source.ConfigMapChartSource.ConfigMap.Name
+	case source.SourceRefChartSource != nil:
+		ref := source.SourceRefChartSource.SourceRef
+		return ReasonSourceRefChartFetched, "chart fetched from " + ref.Kind + " " + ref.Name
+	case source.URLChartSource != nil:
+		return ReasonDownloaded, "chart fetched: " + urlChartName(source.URLChartSource)
+	default:
+		return ReasonDownloaded, "chart fetched: " + filepath.Base(chartPath)
+	}
+}
+
+// getGitChartSource returns the path to the chart of the HelmRelease
+// in the clone of its git repo, and the revision of the clone. Unless
+// report is true, only a clone that is already there is used, and the
+// status of the HelmRelease is left untouched.
+func (chs *ChartChangeSync) getGitChartSource(hr helmfluxv1.HelmRelease, report bool) (string, string, bool) {
 	chartPath, chartRevision := "", ""
 	chartSource := hr.Spec.GitChartSource
 	if chartSource == nil {
 		return chartPath, chartRevision, false
 	}
 
-	releaseName := hr.ReleaseName()
+	// Charts in git do not come with a provenance file, refuse
+	// rather than silently skipping the verification.
+	if hr.Spec.Verify != nil {
+		msg := "chart verification is only supported for charts from Helm repos"
+		if report {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonVerificationFailed, msg)
+		}
+		chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+		return chartPath, chartRevision, false
+	}
+
+	// Keep the credentials of the chart source in line with its
+	// secret, so that the mirror refreshes with a rotated SSH key or
+	// a renewed GitHub App installation token.
+	if report && (chartSource.SSHSecretRef != nil || chartSource.GitHubAppSecretRef != nil) {
+		if err := chs.refreshGitRemote(hr); err != nil {
+			chs.gitCredentialsFailed(hr, err)
+			return chartPath, chartRevision, false
+		}
+	}
+
+	releaseName := chs.release.ReleaseName(hr)
 	chartClone, ok := chs.clones[releaseName]
 	// Validate the clone we have for the release is the same as
 	// is being referenced in the chart source.
 	if ok {
-		ok = chartClone.remote == chartSource.GitURL && chartClone.ref == chartSource.RefOrDefault(chs.config.GitDefaultRef)
+		ok = chartClone.remote == mirrorName(hr.Namespace, chartSource) && chartClone.ref == chartSource.RefOrDefault(chs.config.GitDefaultRef) &&
+			chartClone.submodules == chartSource.RecurseSubmodules
 		if !ok {
 			if chartClone.export != nil {
 				chartClone.export.Clean()
@@ -522,27 +1536,82 @@ func (chs *ChartChangeSync) getGitChartSource(hr helmfluxv1.HelmRelease) (string
 	// before reporting what's wrong with it. But if we just use
 	// repo.Ready(), we'll force all charts through that blocking
 	// code, rather than waiting for things to sync in good time.
+	if !ok && !report {
+		return chartPath, chartRevision, false
+	}
 	if !ok {
-		repo, ok := chs.mirrors.Get(mirrorName(chartSource))
+		// Do not wait for a mirror that keeps failing, until it is
+		// refreshed again after the cooldown.
+		if b, open := chs.openMirrorBreaker(mirrorName(hr.Namespace, chartSource), time.Now()); open {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonDownloadFailed, b.message(mirrorName(hr.Namespace, chartSource)))
+			chs.logger.Log("info", "git mirror circuit breaker open", "resource", hr.ResourceID().String(), "repo", chartSource.GitURL)
+			if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil && chs.releaseQueue != nil {
+				chs.releaseQueue.AddAfter(cacheKey, time.Until(b.openUntil))
+			}
+			return chartPath, chartRevision, false
+		}
+		// Without a clone there has to be a mirror to make it from;
+		// when its credentials can not be set up, that is what the
+		// ChartFetched condition keeps saying.
+		if !chs.maybeMirror(hr) {
+			return chartPath, chartRevision, false
+		}
+		repo, ok := chs.mirrors.Get(mirrorName(hr.Namespace, chartSource))
 		if !ok {
-			chs.maybeMirror(hr)
-			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionUnknown, ReasonGitNotReady, "git repo "+chartSource.GitURL+" not mirrored yet")
+			chs.sourceNotReady(hr, "git repo "+chartSource.GitURL+" not mirrored yet")
 			chs.logger.Log("info", "chart repo not cloned yet", "resource", hr.ResourceID().String())
+		} else if status, err := repo.Status(); status != git.RepoReady {
+			if b, opened, _ := chs.recordMirrorStatus(mirrorName(hr.Namespace, chartSource), status, err, time.Now()); opened {
+				chs.mirrorBreakerChanged(mirrorName(hr.Namespace, chartSource), b, true)
+				if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil && chs.releaseQueue != nil {
+					chs.releaseQueue.AddAfter(cacheKey, time.Until(b.openUntil))
+				}
+				return chartPath, chartRevision, false
+			}
+			chs.sourceNotReady(hr, "git repo not mirrored yet: "+err.Error())
+			chs.logger.Log("info", "chart repo not ready yet", "resource", hr.ResourceID().String(), "status", string(status), "err", err)
 		} else {
-			status, err := repo.Status()
-			if status != git.RepoReady {
-				chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionUnknown, ReasonGitNotReady, "git repo not mirrored yet: "+err.Error())
-				chs.logger.Log("info", "chart repo not ready yet", "resource", hr.ResourceID().String(), "status", string(status), "err", err)
+			if _, _, closed := chs.recordMirrorStatus(mirrorName(hr.Namespace, chartSource), status, err, time.Now()); closed {
+				chs.mirrorBreakerChanged(mirrorName(hr.Namespace, chartSource), mirrorBreaker{}, false)
 			}
+			// The mirror is ready, but has not been cloned from for
+			// this release yet; that happens once it signals a change.
+			chs.sourceNotReady(hr, "git repo "+chartSource.GitURL+" mirrored, chart not cloned yet")
+			chs.logger.Log("info", "chart not cloned from repo yet", "resource", hr.ResourceID().String())
 		}
 		return chartPath, chartRevision, false
 	}
 	chartPath = filepath.Join(chartClone.export.Dir(), chartSource.Path)
 	chartRevision = chartClone.head
 
-	if chs.config.UpdateDeps && !hr.Spec.ChartSource.GitChartSource.SkipDepUpdate {
-		if err := updateDependencies(chartPath, ""); err != nil {
-			chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonDependencyFailed, err.Error())
+	if chs.config.UpdateDeps && !chartSource.SkipDepUpdate {
+		// Dependency repos with credentials are added to a Helm
+		// home of their own, so that the credentials are only used
+		// for the dependencies of charts with the same repos and
+		// credentials, of which the indexes it caches.
+		var helmhome *dependencyHelmHome
+		repos, err := dependencyRepos(chs.kubeClient.CoreV1(), hr.Namespace, chartSource, chartPath, chs.config.ChartRepoCredentialsFile, chs.config.ChartRepoCredentialsDefault)
+		if err == nil && len(repos) > 0 {
+			helmhome, err = chs.dependencyHelmHome(helmSettings().Home, repos)
+		}
+		if err != nil {
+			if report {
+				chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonDependencyFailed, err.Error())
+			}
+			chs.logger.Log("warning", "failed to set up the repos of chart dependencies", "resource", hr.ResourceID().String(), "err", err)
+			return chartPath, chartRevision, false
+		}
+
+		timeout := chartSource.GetDepUpdateTimeout(chs.config.DependencyUpdateTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		err = updateDependencies(ctx, chartPath, helmhome)
+		chs.observePhase(hr, PhaseDependencyUpdate, start, err == nil)
+		cancel()
+		if err != nil {
+			if report {
+				chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonDependencyFailed, err.Error())
+			}
 			chs.logger.Log("warning", "failed to update chart dependencies", "resource", hr.ResourceID().String(), "err", err)
 			return chartPath, chartRevision, false
 		}
@@ -551,20 +1620,84 @@ func (chs *ChartChangeSync) getGitChartSource(hr helmfluxv1.HelmRelease) (string
 	return chartPath, chartRevision, true
 }
 
-func (chs *ChartChangeSync) getRepoChartSource(hr helmfluxv1.HelmRelease) (string, string, bool) {
-	chartPath, chartRevision := "", ""
+func (chs *ChartChangeSync) getRepoChartSource(hr helmfluxv1.HelmRelease, report bool) (string, string, bool) {
 	chartSource := hr.Spec.ChartSource.RepoChartSource
 	if chartSource == nil {
+		return "", "", false
+	}
+	return chs.fetchRepoChart(hr, chartSource, report)
+}
+
+// fetchRepoChart fetches the chart of the given source from its Helm
+// repo for the given HelmRelease, and returns the path to it and its
+// version. The caller releases the chart once it is done with it.
+// Failures are recorded in the status of the HelmRelease if report is
+// true.
+func (chs *ChartChangeSync) fetchRepoChart(hr helmfluxv1.HelmRelease, chartSource *helmfluxv1.RepoChartSource, report bool) (string, string, bool) {
+	chartPath, chartRevision := "", ""
+	notFetched := func(reason, msg string) {
+		if report {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, reason, msg)
+		}
+	}
+	opts, err := chs.downloadOptions(hr, chartSource)
+	if err != nil {
+		notFetched(ReasonDownloadFailed, "chart download failed: "+err.Error())
+		chs.logger.Log("info", "chart download failed", "resource", hr.ResourceID().String(), "err", err)
+		return chartPath, chartRevision, false
+	}
+
+	// Resolve a semver range to a version, so that we always fetch
+	// (and record) the concrete version we release.
+	start := time.Now()
+	version, err := resolveChartVersion(chartSource, opts)
+	if err != nil {
+		chs.observePhase(hr, PhaseChartFetch, start, false)
+		reason, msg := downloadFailure(err)
+		notFetched(reason, msg)
+		chs.logger.Log("info", "unable to resolve chart version", "resource", hr.ResourceID().String(), "version", chartSource.Version, "err", err)
 		return chartPath, chartRevision, false
 	}
+	if version != chartSource.Version {
+		resolved := *chartSource
+		resolved.Version = version
+		chartSource = &resolved
+	}
 
-	path, err := ensureChartFetched(chs.config.ChartCache, chartSource)
+	// NB: the caller releases the chart once it is done with it
+	key, _ := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	path, err := makeChartPath(chs.config.ChartCache, chartSource)
+	if err == nil {
+		chs.charts.acquire(key, path)
+		if path, err = ensureChartFetched(chs.config.ChartCache, chartSource, opts); err != nil {
+			chs.charts.release(path)
+		}
+	}
+	chs.observePhase(hr, PhaseChartFetch, start, err == nil)
 	if err != nil {
-		chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonDownloadFailed, "chart download failed: "+err.Error())
+		reason, msg := downloadFailure(err)
+		notFetched(reason, msg)
 		chs.logger.Log("info", "chart download failed", "resource", hr.ResourceID().String(), "err", err)
 		return chartPath, chartRevision, false
 	}
 
+	if chartSource.Subpath != "" {
+		subpath, err := cleanSubpath(chartSource.Subpath)
+		if err == nil {
+			subchartPath := makeSubchartPath(path, subpath)
+			chs.charts.acquire(key, subchartPath)
+			_, err = ensureSubchartExtracted(path, subpath)
+			chs.charts.release(path)
+			path = subchartPath
+		}
+		if err != nil {
+			chs.charts.release(path)
+			notFetched(ReasonSubchartNotFound, err.Error())
+			chs.logger.Log("info", "unable to extract chart from subpath", "resource", hr.ResourceID().String(), "subpath", chartSource.Subpath, "err", err)
+			return chartPath, chartRevision, false
+		}
+	}
+
 	chartPath = path
 	chartRevision = chartSource.Version
 
@@ -577,6 +1710,39 @@ func sortStrings(ss []string) []string {
 	return ret
 }
 
+// withoutIgnoredValues returns the given values of a release without
+// the values at the given paths, so that these do not count as a
+// divergence.
+func withoutIgnoredValues(c *hapi_chart.Config, paths []string) (*hapi_chart.Config, error) {
+	vals, err := chartutil.ReadValues([]byte(c.GetRaw()))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := release.WithoutValuesPaths(vals, paths).YAML()
+	if err != nil {
+		return nil, err
+	}
+	return &hapi_chart.Config{Raw: raw}, nil
+}
+
+// comparableRelease returns the values and the (sorted) chart of the
+// given release of the HelmRelease as they are compared to determine
+// if the release should be upgraded: without the values the
+// HelmRelease ignores.
+func comparableRelease(hr helmfluxv1.HelmRelease, rel *hapi_release.Release) (*hapi_chart.Config, *hapi_chart.Chart, error) {
+	vals, chart := rel.GetConfig(), rel.GetChart()
+	if len(hr.Spec.IgnoreValues) > 0 {
+		var err error
+		if vals, err = withoutIgnoredValues(vals, hr.Spec.IgnoreValues); err != nil {
+			return nil, nil, err
+		}
+	}
+	if chart != nil {
+		chart = sortChartFields(chart)
+	}
+	return vals, chart, nil
+}
+
 func sortChartFields(c *hapi_chart.Chart) *hapi_chart.Chart {
 	nc := hapi_chart.Chart{
 		Metadata:  &(*c.Metadata),
@@ -616,39 +1782,135 @@ func sortChartFields(c *hapi_chart.Chart) *hapi_chart.Chart {
 // shouldUpgrade returns true if the current running values or chart
 // don't match what the repo says we ought to be running, based on
 // doing a dry run install from the chart in the git repo.
-func (chs *ChartChangeSync) shouldUpgrade(chartsRepo string, currRel *hapi_release.Release, hr helmfluxv1.HelmRelease) (bool, error) {
+func (chs *ChartChangeSync) shouldUpgrade(chartsRepo, chartRevision string, currRel *hapi_release.Release, hr helmfluxv1.HelmRelease) (bool, error) {
 	if currRel == nil {
 		return false, fmt.Errorf("no chart release provided for %v", hr.GetName())
 	}
 
-	currVals := currRel.GetConfig()
-	currChart := currRel.GetChart()
+	// A chart archive that differs from the one released under the
+	// same version may not differ in what the dry run compares.
+	if chs.config.UpgradeOnChartDigest && chartDigestChanged(hr, chartsRepo) {
+		chs.logger.Log("info", fmt.Sprintf("release %s: chart digest has changed", currRel.GetName()), "resource", hr.ResourceID().String(), "released", hr.Status.ChartDigest)
+		return true, nil
+	}
+
+	// Skip the (expensive) dry run if nothing that determines its
+	// outcome has changed since the last successful reconciliation.
+	inputs, err := chs.inputsFor(hr, chartsRepo, chartRevision, currRel)
+	if err != nil {
+		return false, err
+	}
+	debug := chs.debugLogger(hr)
+	if chs.unchangedSinceReconcile(hr, inputs) {
+		debug.Log("debug", "skipping dry run, nothing changed since the last reconciliation", "release", currRel.GetName())
+		return false, nil
+	}
 
 	// Get the desired release state
-	opts := release.InstallOptions{DryRun: true}
-	tempRelName := string(hr.UID)
-	desRel, _, err := chs.release.Install(chartsRepo, tempRelName, hr, release.InstallAction, opts, &chs.kubeClient)
+	opts := chs.installOptions(hr, true)
+	tempRelName := release.DryRunReleaseName(chs.config.DryRunReleasePrefix, hr)
+	ctx, cancel := context.WithTimeout(context.Background(), chs.config.DryRunTimeout)
+	defer cancel()
+	start := time.Now()
+	desRel, mode, err := chs.dryRun(ctx, chartsRepo, tempRelName, hr, opts)
+	chs.observePhase(hr, PhaseDryRun, start, err == nil)
+	if err == context.DeadlineExceeded {
+		return false, fmt.Errorf("dry run did not complete within %s", chs.config.DryRunTimeout)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := status.SetDryRunMode(chs.statusClient(hr), hr, mode); err != nil {
+		chs.logger.Log("warning", "could not update the dry run mode", "resource", hr.ResourceID().String(), "err", err)
+	}
+	redact := func(text string) string { return text }
+	if chs.logDiffs(hr) {
+		redact = chs.redactor(hr, chartsRepo)
+	}
+	debug.Log("debug", "dry run rendered the release", "release", currRel.GetName(),
+		"resources", manifestResources(currRel.GetManifest()), "desired-resources", manifestResources(desRel.GetManifest()))
+	currVals, sortedCurrChart, err := comparableRelease(hr, currRel)
+	if err != nil {
+		return false, err
+	}
+	desVals, sortedDesChart, err := comparableRelease(hr, desRel)
 	if err != nil {
 		return false, err
 	}
-	desVals := desRel.GetConfig()
-	desChart := desRel.GetChart()
 
 	// compare values
 	if diff := cmp.Diff(currVals, desVals); diff != "" {
-		if chs.config.LogDiffs {
-			chs.logger.Log("info", fmt.Sprintf("release %s: values have diverged", currRel.GetName()), "resource", hr.ResourceID().String(), "diff", diff)
+		if chs.logDiffs(hr) {
+			// Redact the values themselves, rather than the diff,
+			// which is not YAML.
+			currRedacted, desRedacted := &hapi_chart.Config{Raw: redact(currVals.GetRaw())}, &hapi_chart.Config{Raw: redact(desVals.GetRaw())}
+			diff = redact(chs.valuesDiff(currRedacted, desRedacted, cmp.Diff(currRedacted, desRedacted)))
 		}
+		chs.logDivergence(hr, currRel, "values have diverged", diff)
 		return true, nil
 	}
 
 	// compare chart
-	if diff := cmp.Diff(sortChartFields(currChart), sortChartFields(desChart)); diff != "" {
-		if chs.config.LogDiffs {
-			chs.logger.Log("info", fmt.Sprintf("release %s: chart has diverged", currRel.GetName()), "resource", hr.ResourceID().String(), "diff", diff)
+	if diff := cmp.Diff(sortedCurrChart, sortedDesChart); diff != "" {
+		if chs.logDiffs(hr) {
+			diff = redact(chs.chartDiff(sortedCurrChart, sortedDesChart, diff))
 		}
+		chs.logDivergence(hr, currRel, "chart has diverged", diff)
 		return true, nil
 	}
 
+	debug.Log("debug", "release has not diverged", "release", currRel.GetName())
+	chs.recordReconciled(hr, inputs)
 	return false, nil
 }
+
+// cleanupOutcome returns the note on the resources created by a
+// failed upgrade of the given release, which were to be deleted, for
+// the status message. They are only said to be deleted once it has
+// been verified that none of them exist anymore.
+func (chs *ChartChangeSync) cleanupOutcome(hr helmfluxv1.HelmRelease, rel *hapi_release.Release) string {
+	failed, err := chs.release.GetRelease(rel.GetName())
+	if err != nil {
+		chs.logger.Log("warning", "unable to verify the resources created by the failed upgrade were deleted", "resource", hr.ResourceID().String(), "release", rel.GetName(), "err", err)
+		return " (the deletion of resources created by the upgrade could not be verified)"
+	}
+	if failed == nil || failed.GetVersion() == rel.GetVersion() {
+		// No revision was recorded, so nothing was created
+		return ""
+	}
+	leftover, err := chs.release.LeftoverResources(rel, failed, chs.config.GitTimeout)
+	switch {
+	case len(leftover) > 0:
+		return fmt.Sprintf(" (resources created by the upgrade were not deleted: %s)", strings.Join(leftover, ", "))
+	case err != nil:
+		chs.logger.Log("warning", "unable to verify the resources created by the failed upgrade were deleted", "resource", hr.ResourceID().String(), "release", rel.GetName(), "err", err)
+		return " (the deletion of resources created by the upgrade could not be verified)"
+	}
+	return " (resources created by the upgrade were deleted)"
+}
+
+// logDivergence logs that the release of the given HelmRelease has
+// diverged, with the diff if diffs are logged, and the resources of
+// the release that lack the tracking annotation if resources are
+// tracked, as these have been changed by other means.
+func (chs *ChartChangeSync) logDivergence(hr helmfluxv1.HelmRelease, rel *hapi_release.Release, msg, diff string) {
+	var untracked []string
+	if chs.config.TrackResources {
+		var err error
+		untracked, err = chs.release.UntrackedResources(rel, hr, chs.config.GitTimeout)
+		if err != nil {
+			chs.logger.Log("warning", "unable to find all untracked resources of the release", "resource", hr.ResourceID().String(), "release", rel.GetName(), "err", err)
+		}
+	}
+	if !chs.logDiffs(hr) && len(untracked) == 0 {
+		return
+	}
+	keyvals := []interface{}{"info", fmt.Sprintf("release %s: %s", rel.GetName(), msg), "resource", hr.ResourceID().String()}
+	if len(untracked) > 0 {
+		keyvals = append(keyvals, "untracked", strings.Join(untracked, ", "))
+	}
+	if chs.logDiffs(hr) {
+		keyvals = append(keyvals, "diff", diff)
+	}
+	chs.logger.Log(keyvals...)
+}
@@ -0,0 +1,226 @@
+package chartsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/flux/pkg/git"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	k8shelm "k8s.io/helm/pkg/helm"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+	rls "k8s.io/helm/pkg/proto/hapi/services"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// stalledHelmClient is a k8shelm.FakeClient of which installs do not
+// return until unblocked.
+type stalledHelmClient struct {
+	*k8shelm.FakeClient
+	unblock chan struct{}
+}
+
+func (c *stalledHelmClient) InstallRelease(chStr, ns string, opts ...k8shelm.InstallOption) (*rls.InstallReleaseResponse, error) {
+	<-c.unblock
+	return c.FakeClient.InstallRelease(chStr, ns, opts...)
+}
+
+func TestDryRunRelease_timeout(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(chartPath)
+
+	helmClient := &stalledHelmClient{FakeClient: &k8shelm.FakeClient{}, unblock: make(chan struct{})}
+	chs := &ChartChangeSync{
+		logger:  log.NewNopLogger(),
+		release: release.New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		config:  Config{DryRunTimeout: 50 * time.Millisecond}.WithDefaults(),
+		clones:  make(map[string]clone),
+	}
+	source := &helmfluxv1.GitChartSource{GitURL: "git@github.com:fluxcd/podinfo", Path: chartPath}
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", UID: "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"},
+		Spec:       helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: source}},
+	}
+	// The chart is in the clone of the release, so that fetching it
+	// holds on to the clones for as long as the dry run takes
+	chs.clones[chs.release.ReleaseName(hr)] = clone{
+		export: &git.Export{},
+		remote: mirrorName(hr.Namespace, source),
+		ref:    source.RefOrDefault(chs.config.GitDefaultRef),
+		head:   "1.0.0",
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := chs.dryRunRelease(hr)
+		done <- err
+	}()
+
+	// The clones are let go of once the dry run is given up on
+	removed := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		chs.removeClone(hr)
+		close(removed)
+	}()
+	select {
+	case <-removed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("clones were not let go of")
+	}
+	select {
+	case err := <-done:
+		assert.EqualError(t, err, "dry run did not complete within 50ms")
+	case <-time.After(5 * time.Second):
+		t.Fatal("dry run was not given up on")
+	}
+
+	// No other dry run of the release is started until the one given
+	// up on finished
+	_, _, err = chs.release.InstallContext(context.Background(), chartPath, "default-podinfo", hr, release.InstallAction,
+		release.InstallOptions{DryRun: true}, fake.NewSimpleClientset())
+	assert.EqualError(t, err, "the dry run of release default-podinfo that was given up on earlier is still in flight")
+	close(helmClient.unblock)
+	for i := 0; i < 100; i++ {
+		if _, _, err = chs.release.InstallContext(context.Background(), chartPath, "default-podinfo", hr, release.InstallAction,
+			release.InstallOptions{DryRun: true}, fake.NewSimpleClientset()); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+}
+
+func TestWithoutIgnoredValues(t *testing.T) {
+	paths := []string{"auth.password", "timestamp"}
+	curr, err := withoutIgnoredValues(&hapi_chart.Config{Raw: "auth:\n  password: abc\n  user: admin\ntimestamp: 1\n"}, paths)
+	assert.NoError(t, err)
+	des, err := withoutIgnoredValues(&hapi_chart.Config{Raw: "timestamp: 2\nauth:\n  user: admin\n  password: xyz\n"}, paths)
+	assert.NoError(t, err)
+	assert.Equal(t, curr, des)
+	assert.Equal(t, "auth:\n  user: admin\n", des.GetRaw())
+
+	des, err = withoutIgnoredValues(&hapi_chart.Config{Raw: "auth:\n  user: root\n"}, paths)
+	assert.NoError(t, err)
+	assert.NotEqual(t, curr, des)
+}
+
+func TestUpgradeDisabled(t *testing.T) {
+	disabled := false
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec:       helmfluxv1.HelmReleaseSpec{Upgrade: helmfluxv1.Upgrade{Enable: &disabled}},
+	}
+	assert.False(t, hr.Spec.Upgrade.Enabled())
+	assert.True(t, helmfluxv1.Upgrade{}.Enabled())
+
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		release:  release.New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		ifClient: ifClient,
+	}
+
+	for code, expected := range map[hapi_release.Status_Code]v1.ConditionStatus{
+		hapi_release.Status_DEPLOYED: v1.ConditionTrue,
+		hapi_release.Status_FAILED:   v1.ConditionUnknown,
+	} {
+		rel := &hapi_release.Release{Name: "default-podinfo", Version: 3, Info: &hapi_release.Info{Status: &hapi_release.Status{Code: code}}}
+		chs.upgradeDisabled(hr, "default-podinfo", rel)
+		cond := status.GetCondition(srv.get().Status, helmfluxv1.HelmReleaseReleased)
+		if assert.NotNil(t, cond, code.String()) {
+			assert.Equal(t, expected, cond.Status, code.String())
+			assert.Equal(t, ReasonUpgradeDisabled, cond.Reason)
+			assert.Equal(t, "upgrades are disabled, release 'default-podinfo' is left at revision 3", cond.Message)
+		}
+	}
+}
+
+func TestRetryDivergedSpec(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Generation: 1},
+	}
+
+	for retries, expected := range map[int][]string{
+		0: {"1 retry=false"},
+		2: {"1 retry=true", "2 retry=true", "3 retry=false"},
+		5: {"1 retry=true", "2 retry=true", "3 retry=true", "4 retry=true"},
+	} {
+		chs := &ChartChangeSync{
+			logger: log.NewNopLogger(),
+			config: Config{DivergedSpecRetries: retries},
+		}
+		// The spec changes between each attempt until generation 4
+		var attempts []string
+		reconcile := func(hr helmfluxv1.HelmRelease, retry bool) *helmfluxv1.HelmRelease {
+			attempts = append(attempts, fmt.Sprintf("%d retry=%t", hr.Generation, retry))
+			if hr.Generation >= 4 || !retry {
+				return nil
+			}
+			next := hr
+			next.Generation++
+			return &next
+		}
+		chs.retryDivergedSpec(hr, reconcile)
+		assert.Equal(t, expected, attempts, "retries: %d", retries)
+	}
+}
+
+// failingDeleteHelmClient is a k8shelm.FakeClient of which deletes
+// fail.
+type failingDeleteHelmClient struct {
+	*k8shelm.FakeClient
+}
+
+func (c *failingDeleteHelmClient) DeleteRelease(rlsName string, opts ...k8shelm.DeleteOption) (*rls.UninstallReleaseResponse, error) {
+	return nil, errors.New("timed out waiting for the pre-delete hook")
+}
+
+func TestDeleteRelease_failure(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+	}
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+
+	helmClient := &failingDeleteHelmClient{FakeClient: &k8shelm.FakeClient{Rels: []*hapi_release.Release{
+		k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: "default-podinfo", Namespace: "default"}),
+	}}}
+	recorder := record.NewFakeRecorder(1)
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		release:  release.New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		ifClient: ifClient,
+		recorder: recorder,
+		clones:   make(map[string]clone),
+	}
+	chs.DeleteRelease(hr)
+
+	msg := "failed to delete release 'default-podinfo': timed out waiting for the pre-delete hook"
+	cond := status.GetCondition(srv.get().Status, helmfluxv1.HelmReleaseDeleted)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, v1.ConditionFalse, cond.Status)
+		assert.Equal(t, ReasonDeleteFailed, cond.Reason)
+		assert.Equal(t, msg, cond.Message)
+	}
+	assert.Equal(t, "Warning "+ReasonDeleteFailed+" "+msg, <-recorder.Events)
+}
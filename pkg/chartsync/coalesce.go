@@ -0,0 +1,63 @@
+package chartsync
+
+import (
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// reconcileCoalesced reconciles the given HelmRelease with the given
+// reconcile, unless it is being reconciled already, in which case it
+// is reconciled again by the running reconciliation once done. The
+// outcome of the reconciliation of the given HelmRelease, not of the
+// follow-ups, is collected into the given result if not nil.
+func (chs *ChartChangeSync) reconcileCoalesced(hr helmfluxv1.HelmRelease, result *ReconcileResult, reconcile func(helmfluxv1.HelmRelease)) {
+	if !chs.startReconciling(hr) {
+		chs.logger.Log("info", "release is being reconciled, reconciling again once done", "resource", hr.ResourceID().String())
+		result.skip(ReasonReconcileInProgress)
+		return
+	}
+	stopCollecting := chs.collectInto(hr, result)
+	reconcile(hr)
+	stopCollecting()
+	for {
+		next, ok := chs.finishReconciling(hr)
+		if !ok {
+			return
+		}
+		hr = next
+		reconcile(hr)
+	}
+}
+
+// startReconciling registers the given HelmRelease as being
+// reconciled, and returns true, unless it already is, in which case
+// it is kept for a follow-up reconciliation once the running one
+// finished and false is returned. Of the HelmReleases kept while the
+// reconciliation runs only the last is reconciled.
+func (chs *ChartChangeSync) startReconciling(hr helmfluxv1.HelmRelease) bool {
+	id := hr.ResourceID().String()
+	chs.reconcilingMu.Lock()
+	defer chs.reconcilingMu.Unlock()
+	if _, ok := chs.reconciling[id]; ok {
+		chs.reconciling[id] = &hr
+		return false
+	}
+	chs.reconciling[id] = nil
+	return true
+}
+
+// finishReconciling returns the HelmRelease kept for a follow-up
+// reconciliation of the given HelmRelease, and true, in which case it
+// is still registered as being reconciled. Otherwise it unregisters
+// the HelmRelease, and returns false.
+func (chs *ChartChangeSync) finishReconciling(hr helmfluxv1.HelmRelease) (helmfluxv1.HelmRelease, bool) {
+	id := hr.ResourceID().String()
+	chs.reconcilingMu.Lock()
+	defer chs.reconcilingMu.Unlock()
+	next := chs.reconciling[id]
+	if next == nil {
+		delete(chs.reconciling, id)
+		return helmfluxv1.HelmRelease{}, false
+	}
+	chs.reconciling[id] = nil
+	return *next, true
+}
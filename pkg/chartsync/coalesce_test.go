@@ -0,0 +1,75 @@
+package chartsync
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestReconcileCoalesced(t *testing.T) {
+	chs := &ChartChangeSync{
+		logger:      log.NewNopLogger(),
+		reconciling: make(map[string]*helmfluxv1.HelmRelease),
+	}
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Generation: 1},
+	}
+
+	var mu sync.Mutex
+	var running, overlaps int
+	var reconciled []string
+	started, unblock := make(chan struct{}), make(chan struct{})
+	reconcile := func(hr helmfluxv1.HelmRelease) {
+		mu.Lock()
+		running++
+		if running > 1 {
+			overlaps++
+		}
+		reconciled = append(reconciled, fmt.Sprintf("%s@%d", hr.Name, hr.Generation))
+		first := len(reconciled) == 1
+		mu.Unlock()
+		if first {
+			close(started)
+			<-unblock
+		}
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		chs.reconcileCoalesced(hr, nil, reconcile)
+		close(done)
+	}()
+	<-started
+
+	// Asked while it is being reconciled, the HelmRelease is only
+	// reconciled again once, against the last one asked for
+	for gen := int64(2); gen <= 4; gen++ {
+		next := hr
+		next.Generation = gen
+		chs.reconcileCoalesced(next, nil, reconcile)
+	}
+	// Other HelmReleases are not held up
+	other := hr
+	other.Name = "other"
+	chs.reconcileCoalesced(other, nil, reconcile)
+
+	close(unblock)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconciliation did not finish")
+	}
+	assert.Equal(t, []string{"podinfo@1", "other@1", "podinfo@4"}, reconciled)
+	assert.Equal(t, 1, overlaps, "only the other HelmRelease may overlap")
+	assert.Empty(t, chs.reconciling)
+}
@@ -0,0 +1,132 @@
+package chartsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/helm/pkg/chartutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// makeConfigMapChartPath gives the filesystem location in the chart
+// cache for the chart archive with the given checksum from the
+// ConfigMap with the given namespace and name.
+func makeConfigMapChartPath(base, namespace, name, checksum string) (string, error) {
+	dir, err := chartCacheDir(base, fmt.Sprintf("configmap://%s/%s/", namespace, name))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, checksum+".tgz"), nil
+}
+
+// configMapChart returns the chart archive under the given key of the
+// ConfigMap, taken from its binary data or, base64 encoded, from its
+// data.
+func configMapChart(cm *v1.ConfigMap, key string) ([]byte, error) {
+	if archive, ok := cm.BinaryData[key]; ok {
+		return archive, nil
+	}
+	if encoded, ok := cm.Data[key]; ok {
+		archive, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("chart archive under key '%s' of config map '%s' is not base64 encoded: %s", key, cm.Name, err)
+		}
+		return archive, nil
+	}
+	return nil, fmt.Errorf("config map '%s' has no chart archive under key '%s'", cm.Name, key)
+}
+
+// writeChartArchive writes the given chart archive to the given path,
+// unless it has been written before.
+func writeChartArchive(path string, archive []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	// Write to a temporary file first, so that a chart is never
+	// loaded from a partially written archive.
+	f, err := ioutil.TempFile(filepath.Dir(path), "chart")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(archive); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (chs *ChartChangeSync) getConfigMapChartSource(hr helmfluxv1.HelmRelease, report bool) (string, string, bool) {
+	chartPath, chartRevision := "", ""
+	chartSource := hr.Spec.ChartSource.ConfigMapChartSource
+	if chartSource == nil {
+		return chartPath, chartRevision, false
+	}
+
+	// Charts in config maps do not come with a provenance file,
+	// refuse rather than silently skipping the verification.
+	if hr.Spec.Verify != nil {
+		msg := "chart verification is only supported for charts from Helm repos"
+		if report {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonVerificationFailed, msg)
+		}
+		chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+		return chartPath, chartRevision, false
+	}
+
+	fail := func(err error) (string, string, bool) {
+		if report {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonConfigMapChartFailed, "chart load failed: "+err.Error())
+		}
+		chs.logger.Log("info", "chart load from config map failed", "resource", hr.ResourceID().String(), "err", err)
+		return chartPath, chartRevision, false
+	}
+
+	ref := chartSource.ConfigMap
+	cm, err := chs.kubeClient.CoreV1().ConfigMaps(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return fail(err)
+	}
+	archive, err := configMapChart(cm, ref.KeyOrDefault())
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := chartutil.LoadArchive(bytes.NewReader(archive)); err != nil {
+		return fail(fmt.Errorf("invalid chart archive under key '%s' of config map '%s': %s", ref.KeyOrDefault(), ref.Name, err))
+	}
+
+	// The checksum of the archive is the revision, so that a change
+	// to the chart in the config map results in an upgrade.
+	sum := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(sum[:])
+	path, err := makeConfigMapChartPath(chs.config.ChartCache, hr.Namespace, ref.Name, checksum)
+	if err != nil {
+		return fail(err)
+	}
+
+	// NB: the caller releases the chart once it is done with it
+	key, _ := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	chs.charts.acquire(key, path)
+	if err := writeChartArchive(path, archive); err != nil {
+		chs.charts.release(path)
+		return fail(err)
+	}
+
+	chartPath = path
+	chartRevision = checksum
+
+	return chartPath, chartRevision, true
+}
@@ -0,0 +1,63 @@
+package chartsync
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConfigMapChart(t *testing.T) {
+	archive := []byte("chart archive")
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo-chart"},
+		BinaryData: map[string][]byte{"chart.tgz": archive},
+		Data: map[string]string{
+			"encoded.tgz": base64.StdEncoding.EncodeToString(archive),
+			"plain.tgz":   "not base64!",
+		},
+	}
+
+	got, err := configMapChart(cm, "chart.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, archive, got)
+
+	got, err = configMapChart(cm, "encoded.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, archive, got)
+
+	_, err = configMapChart(cm, "plain.tgz")
+	assert.Error(t, err)
+
+	_, err = configMapChart(cm, "missing.tgz")
+	assert.EqualError(t, err, "config map 'podinfo-chart' has no chart archive under key 'missing.tgz'")
+}
+
+func TestWriteChartArchive(t *testing.T) {
+	base, err := ioutil.TempDir("", "chart-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	path, err := makeConfigMapChartPath(base, "default", "podinfo-chart", "0123abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, writeChartArchive(path, []byte("chart archive")))
+	// Written before, so left as is
+	assert.NoError(t, writeChartArchive(path, []byte("other archive")))
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "chart archive", string(b))
+
+	// Charts from config maps are evicted alike
+	charts, _ := cachedCharts(base)
+	if assert.Len(t, charts, 1) {
+		assert.Equal(t, path, charts[0].path)
+	}
+}
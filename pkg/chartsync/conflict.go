@@ -0,0 +1,47 @@
+package chartsync
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// releaseConflict returns a message naming the HelmRelease that
+// claims the release name of the given HelmRelease, if any. Of the
+// HelmReleases resolving to the same release name, the oldest one
+// claims it; ties are broken by namespace and name so that all of
+// them agree on the claimant.
+func (chs *ChartChangeSync) releaseConflict(hr helmfluxv1.HelmRelease) (string, error) {
+	list, err := chs.hrLister.List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+
+	releaseName := chs.release.ReleaseName(hr)
+	for _, other := range list {
+		if other.UID == hr.UID || other.DeletionTimestamp != nil {
+			continue
+		}
+		if chs.release.ReleaseName(*other) != releaseName {
+			continue
+		}
+		if claimsBefore(*other, hr) {
+			return fmt.Sprintf("release name '%s' is already claimed by HelmRelease '%s'", releaseName, other.ResourceID().String()), nil
+		}
+	}
+	return "", nil
+}
+
+// claimsBefore returns if HelmRelease a takes precedence over b in
+// claiming a release name.
+func claimsBefore(a, b helmfluxv1.HelmRelease) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
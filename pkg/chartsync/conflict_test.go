@@ -0,0 +1,63 @@
+package chartsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+func TestReleaseConflict(t *testing.T) {
+	now := time.Now()
+	newHr := func(namespace, name, releaseName string, created time.Time) *helmfluxv1.HelmRelease {
+		return &helmfluxv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         namespace,
+				Name:              name,
+				UID:               types.UID(namespace + "/" + name),
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: helmfluxv1.HelmReleaseSpec{ReleaseName: releaseName},
+		}
+	}
+	older := newHr("team-a", "frontend", "frontend", now.Add(-time.Hour))
+	newer := newHr("team-b", "frontend", "frontend", now)
+	other := newHr("team-b", "backend", "backend", now.Add(-time.Hour))
+
+	for _, tc := range []struct {
+		strategy helmfluxv1.ReleaseNameStrategy
+		conflict bool
+	}{
+		{helmfluxv1.ReleaseNameStrategyDefault, true},
+		{helmfluxv1.ReleaseNameStrategyNamespaced, false},
+	} {
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		for _, hr := range []*helmfluxv1.HelmRelease{older, newer, other} {
+			indexer.Add(hr)
+		}
+		chs := &ChartChangeSync{
+			hrLister: iflister.NewHelmReleaseLister(indexer),
+			release:  release.New(log.NewNopLogger(), nil, nil, tc.strategy),
+		}
+
+		msg, err := chs.releaseConflict(*newer)
+		assert.NoError(t, err)
+		if tc.conflict {
+			assert.Contains(t, msg, "team-a:helmrelease/frontend")
+		} else {
+			assert.Empty(t, msg)
+		}
+
+		msg, err = chs.releaseConflict(*older)
+		assert.NoError(t, err)
+		assert.Empty(t, msg)
+	}
+}
@@ -0,0 +1,42 @@
+package chartsync
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// debugLogger returns the logger for the verbose diagnostics of the
+// given HelmRelease, which discards them unless debugging is enabled
+// for the HelmRelease with `spec.debug`.
+func (chs *ChartChangeSync) debugLogger(hr helmfluxv1.HelmRelease) log.Logger {
+	if !hr.Spec.Debug {
+		return log.NewNopLogger()
+	}
+	return log.With(chs.logger, "resource", hr.ResourceID().String())
+}
+
+// logDiffs returns true if the diffs of the release of the given
+// HelmRelease are logged, either for all releases or because
+// debugging is enabled for it.
+func (chs *ChartChangeSync) logDiffs(hr helmfluxv1.HelmRelease) bool {
+	return chs.config.LogDiffs || hr.Spec.Debug
+}
+
+// manifestResources returns the kinds and names of the resources in
+// the given manifests, for the debug log; the manifests themselves
+// are not logged, as they may hold the data of secrets. Resources
+// are sorted, as Helm does not keep the order of the manifests.
+func manifestResources(manifest string) string {
+	objs := release.ManifestToUnstructured(manifest)
+	resources := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		resources = append(resources, obj.GetKind()+"/"+obj.GetName())
+	}
+	sort.Strings(resources)
+	return strings.Join(resources, ", ")
+}
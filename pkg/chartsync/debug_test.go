@@ -0,0 +1,53 @@
+package chartsync
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestLogDivergence_debug(t *testing.T) {
+	var out bytes.Buffer
+	chs := &ChartChangeSync{logger: log.NewLogfmtLogger(&out)}
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+	}
+	rel := &hapi_release.Release{Name: "default-podinfo"}
+
+	chs.logDivergence(hr, rel, "values have diverged", "-a +b")
+	assert.Empty(t, out.String())
+	chs.debugLogger(hr).Log("debug", "examining release")
+	assert.Empty(t, out.String())
+
+	hr.Spec.Debug = true
+	chs.logDivergence(hr, rel, "values have diverged", "-a +b")
+	assert.Contains(t, out.String(), `diff="-a +b"`)
+	out.Reset()
+	chs.debugLogger(hr).Log("debug", "examining release")
+	assert.Equal(t, "resource=default:helmrelease/podinfo debug=\"examining release\"\n", out.String())
+}
+
+func TestManifestResources(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: c2VjcmV0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+`
+	resources := manifestResources(manifest)
+	assert.Equal(t, "Deployment/podinfo, Secret/credentials", resources)
+	assert.NotContains(t, resources, "c2VjcmV0")
+}
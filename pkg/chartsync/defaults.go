@@ -0,0 +1,97 @@
+package chartsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// ReleaseDefaults are the settings of the spec of HelmReleases that
+// they inherit unless they set them themselves.
+type ReleaseDefaults struct {
+	// Install or upgrade timeout in seconds
+	Timeout *int64 `json:"timeout,omitempty"`
+	// Number of revisions of a release that are kept
+	MaxHistory *int64 `json:"maxHistory,omitempty"`
+	// Upgrade options
+	Upgrade helmfluxv1.Upgrade `json:"upgrade,omitempty"`
+	// Rollback options
+	Rollback helmfluxv1.Rollback `json:"rollback,omitempty"`
+}
+
+// ReadReleaseDefaults reads the release defaults from the YAML file
+// at the given path, refusing settings that can not have a default.
+func ReadReleaseDefaults(path string) (ReleaseDefaults, error) {
+	var defaults ReleaseDefaults
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return defaults, err
+	}
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return defaults, fmt.Errorf("invalid release defaults in %s: %s", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&defaults); err != nil {
+		return defaults, fmt.Errorf("invalid release defaults in %s: %s", path, err)
+	}
+	return defaults, nil
+}
+
+// WithReleaseDefaults returns the given HelmRelease with the release
+// defaults of the operator for the settings it does not set itself.
+func (chs *ChartChangeSync) WithReleaseDefaults(hr helmfluxv1.HelmRelease) helmfluxv1.HelmRelease {
+	d := chs.config.ReleaseDefaults
+	hr = *hr.DeepCopy()
+
+	spec := &hr.Spec
+	defaultInt64(&spec.Timeout, d.Timeout)
+	defaultInt64(&spec.MaxHistory, d.MaxHistory)
+
+	upgrade, du := &spec.Upgrade, d.Upgrade.DeepCopy()
+	defaultBool(&upgrade.Enable, du.Enable)
+	defaultBool(&upgrade.Force, du.Force)
+	defaultBool(&upgrade.ReuseValues, du.ReuseValues)
+	defaultBool(&upgrade.ResetValues, du.ResetValues)
+	defaultBool(&upgrade.CleanupOnFail, du.CleanupOnFail)
+	defaultBool(&upgrade.RecreatePods, du.RecreatePods)
+	defaultBool(&upgrade.DisableHooks, du.DisableHooks)
+	defaultBool(&upgrade.SkipDryRun, du.SkipDryRun)
+
+	rollback, dr := &spec.Rollback, d.Rollback.DeepCopy()
+	defaultBool(&rollback.Enable, dr.Enable)
+	defaultBool(&rollback.Force, dr.Force)
+	defaultBool(&rollback.Recreate, dr.Recreate)
+	defaultBool(&rollback.DisableHooks, dr.DisableHooks)
+	defaultBool(&rollback.Wait, dr.Wait)
+	defaultInt64(&rollback.Timeout, dr.Timeout)
+	defaultInt64(&rollback.MaxRetries, dr.MaxRetries)
+	if rollback.DisableOnReasons == nil {
+		rollback.DisableOnReasons = dr.DisableOnReasons
+	}
+	return hr
+}
+
+// defaultBool sets the setting v points at to the given default, if
+// it is not set.
+func defaultBool(v **bool, d *bool) {
+	if *v == nil && d != nil {
+		b := *d
+		*v = &b
+	}
+}
+
+// defaultInt64 sets the setting v points at to the given default, if
+// it is not set.
+func defaultInt64(v **int64, d *int64) {
+	if *v == nil && d != nil {
+		i := *d
+		*v = &i
+	}
+}
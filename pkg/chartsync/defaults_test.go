@@ -0,0 +1,77 @@
+package chartsync
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestReadReleaseDefaults(t *testing.T) {
+	f, err := ioutil.TempFile("", "defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	ioutil.WriteFile(f.Name(), []byte("timeout: 600\nrollback:\n  enable: true\n  maxRetries: 2\n"), 0600)
+	defaults, err := ReadReleaseDefaults(f.Name())
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(600), *defaults.Timeout)
+		assert.True(t, defaults.Rollback.Enabled())
+		assert.Equal(t, int64(2), defaults.Rollback.GetMaxRetries())
+	}
+
+	ioutil.WriteFile(f.Name(), []byte("maxHistory: 10\n"), 0600)
+	defaults, err = ReadReleaseDefaults(f.Name())
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(10), *defaults.MaxHistory)
+	}
+
+	// Settings that can not have a default are refused
+	ioutil.WriteFile(f.Name(), []byte("chart:\n  name: podinfo\n"), 0600)
+	_, err = ReadReleaseDefaults(f.Name())
+	assert.Error(t, err)
+}
+
+func TestWithReleaseDefaults(t *testing.T) {
+	timeout, rollbackTimeout, maxHistory := int64(600), int64(120), int64(10)
+	enabled, disabled := true, false
+	chs := &ChartChangeSync{config: Config{ReleaseDefaults: ReleaseDefaults{
+		Timeout:    &timeout,
+		MaxHistory: &maxHistory,
+		Upgrade:    helmfluxv1.Upgrade{CleanupOnFail: &enabled, Force: &enabled},
+		Rollback:   helmfluxv1.Rollback{Enable: &enabled, Wait: &enabled, Timeout: &rollbackTimeout},
+	}}}
+
+	ownTimeout := int64(60)
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec: helmfluxv1.HelmReleaseSpec{
+			Timeout: &ownTimeout,
+			Upgrade: helmfluxv1.Upgrade{Enable: &disabled, Force: &disabled},
+		},
+	}
+	defaulted := chs.WithReleaseDefaults(hr)
+	assert.Equal(t, int64(60), defaulted.GetTimeout())
+	assert.False(t, defaulted.Spec.Upgrade.Enabled())
+	assert.True(t, defaulted.Spec.Upgrade.GetCleanupOnFail())
+	// A release can turn off what is on by default
+	assert.False(t, defaulted.Spec.Upgrade.GetForce())
+	assert.True(t, defaulted.Spec.Rollback.Enabled())
+	assert.True(t, defaulted.Spec.Rollback.GetWait())
+	assert.Equal(t, int64(120), defaulted.Spec.Rollback.GetTimeout())
+	assert.Equal(t, int64(10), defaulted.GetMaxHistory())
+
+	// The HelmRelease itself is left as it is
+	assert.Nil(t, hr.Spec.Rollback.Enable)
+	assert.Nil(t, hr.Spec.MaxHistory)
+	assert.Nil(t, hr.Spec.Rollback.Timeout)
+
+	hr.Spec.Timeout = nil
+	assert.Equal(t, int64(600), chs.WithReleaseDefaults(hr).GetTimeout())
+}
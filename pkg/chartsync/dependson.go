@@ -0,0 +1,93 @@
+package chartsync
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// checkDependencies determines if all the HelmReleases the given
+// HelmRelease depends on have been released, at their current
+// generation. It returns the reason and a message explaining why not,
+// or empty strings if all dependencies are ready.
+func (chs *ChartChangeSync) checkDependencies(hr helmfluxv1.HelmRelease) (string, string) {
+	deps := hr.GetDependsOn()
+	if len(deps) == 0 {
+		return "", ""
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return ReasonDependencyNotReady, err.Error()
+	}
+	if cycle := findDependencyCycle(key, chs.dependenciesOf); cycle != nil {
+		return ReasonDependencyCycle, "dependency cycle detected: " + strings.Join(cycle, " -> ")
+	}
+
+	var notReady []string
+	for _, dep := range deps {
+		namespace, name, err := cache.SplitMetaNamespaceKey(dep)
+		if err != nil {
+			return ReasonDependencyNotReady, fmt.Sprintf("invalid dependency %q: %s", dep, err)
+		}
+		depHr, err := chs.hrLister.HelmReleases(namespace).Get(name)
+		if err != nil {
+			notReady = append(notReady, dep)
+			continue
+		}
+		// A Released condition left over from a previous generation
+		// does not tell whether the dependency has caught up with
+		// its spec.
+		released := status.GetCondition(depHr.Status, helmfluxv1.HelmReleaseReleased)
+		if released == nil || released.Status != v1.ConditionTrue || !status.HasSynced(*depHr) {
+			notReady = append(notReady, dep)
+		}
+	}
+	if len(notReady) > 0 {
+		return ReasonDependencyNotReady, "waiting for dependencies to be released: " + strings.Join(notReady, ", ")
+	}
+	return "", ""
+}
+
+// dependenciesOf returns the dependencies of the HelmRelease with the
+// given `namespace/name` key, or nil if it can not be found.
+func (chs *ChartChangeSync) dependenciesOf(key string) []string {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	hr, err := chs.hrLister.HelmReleases(namespace).Get(name)
+	if err != nil {
+		return nil
+	}
+	return hr.GetDependsOn()
+}
+
+// findDependencyCycle walks the dependency graph starting at the
+// given key, and returns the path of the first cycle leading back to
+// it, or nil if there is none.
+func findDependencyCycle(start string, dependenciesOf func(string) []string) []string {
+	visited := map[string]bool{}
+	var walk func(key string, path []string) []string
+	walk = func(key string, path []string) []string {
+		for _, dep := range dependenciesOf(key) {
+			if dep == start {
+				return append(path, dep)
+			}
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if cycle := walk(dep, append(path, dep)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return walk(start, []string{start})
+}
@@ -0,0 +1,106 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+)
+
+func Test_findDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		graph map[string][]string
+		want  []string
+	}{
+		{
+			name:  "no dependencies",
+			start: "default/a",
+			graph: map[string][]string{},
+			want:  nil,
+		},
+		{
+			name:  "acyclic",
+			start: "default/a",
+			graph: map[string][]string{
+				"default/a": {"default/b", "other/c"},
+				"default/b": {"other/c"},
+			},
+			want: nil,
+		},
+		{
+			name:  "self dependency",
+			start: "default/a",
+			graph: map[string][]string{
+				"default/a": {"default/a"},
+			},
+			want: []string{"default/a", "default/a"},
+		},
+		{
+			name:  "indirect cycle",
+			start: "default/a",
+			graph: map[string][]string{
+				"default/a": {"default/b"},
+				"default/b": {"other/c"},
+				"other/c":   {"default/a"},
+			},
+			want: []string{"default/a", "default/b", "other/c", "default/a"},
+		},
+		{
+			name:  "cycle not involving start",
+			start: "default/a",
+			graph: map[string][]string{
+				"default/a": {"default/b"},
+				"default/b": {"other/c"},
+				"other/c":   {"default/b"},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findDependencyCycle(tt.start, func(key string) []string {
+				return tt.graph[key]
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_checkDependencies(t *testing.T) {
+	dep := func(generation, observedGeneration int64, released v1.ConditionStatus) *helmfluxv1.HelmRelease {
+		return &helmfluxv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres-operator", Namespace: "default", Generation: generation},
+			Status: helmfluxv1.HelmReleaseStatus{
+				ObservedGeneration: observedGeneration,
+				Conditions: []helmfluxv1.HelmReleaseCondition{
+					{Type: helmfluxv1.HelmReleaseReleased, Status: released},
+				},
+			},
+		}
+	}
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec:       helmfluxv1.HelmReleaseSpec{DependsOn: []string{"postgres-operator"}},
+	}
+	check := func(depHr *helmfluxv1.HelmRelease) string {
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		indexer.Add(depHr)
+		indexer.Add(&hr)
+		chs := &ChartChangeSync{hrLister: iflister.NewHelmReleaseLister(indexer)}
+		reason, _ := chs.checkDependencies(hr)
+		return reason
+	}
+
+	assert.Equal(t, "", check(dep(2, 2, v1.ConditionTrue)))
+	assert.Equal(t, ReasonDependencyNotReady, check(dep(2, 2, v1.ConditionFalse)))
+	// Released is still true from the previous generation, while the
+	// edited dependency has not been upgraded yet
+	assert.Equal(t, ReasonDependencyNotReady, check(dep(3, 2, v1.ConditionTrue)))
+}
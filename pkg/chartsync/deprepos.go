@@ -0,0 +1,217 @@
+package chartsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/helm/helmpath"
+	"k8s.io/helm/pkg/repo"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+const (
+	// the keys of the secret with the credentials for a dependency repo
+	dependencyRepoUsernameKey = "username"
+	dependencyRepoPasswordKey = "password"
+
+	// dependencyRepoPrefix is the prefix of the names of the entries
+	// for dependency repos with credentials in repositories.yaml
+	dependencyRepoPrefix = "flux-dependency-"
+)
+
+// dependencyRepo is a Helm repo the dependencies of a chart are
+// fetched from, with the credentials for it.
+type dependencyRepo struct {
+	url      string
+	username string
+	password string
+	// fallback is set for credentials from the credentials file,
+	// which do not replace those in repositories.yaml
+	fallback bool
+}
+
+// dependencyRepoName returns the name of the entry for the dependency
+// repo with the given URL in repositories.yaml.
+func dependencyRepoName(url string) string {
+	sum := sha256.Sum256([]byte(strings.TrimRight(url, "/")))
+	return dependencyRepoPrefix + hex.EncodeToString(sum[:4])
+}
+
+// dependencyRepos returns the repos the dependencies of the chart in
+// the given directory are fetched from that there are credentials
+// for: in the secrets the given chart source refers to, or else in
+// the given credentials file of the operator.
+func dependencyRepos(corev1 k8sclientv1.CoreV1Interface, namespace string, source *helmfluxv1.GitChartSource,
+	chartDir, credentialsFile string, allowDefault bool) ([]dependencyRepo, error) {
+
+	data, err := ioutil.ReadFile(filepath.Join(chartDir, "requirements.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var reqs chartutil.Requirements
+	if err := yaml.Unmarshal(data, &reqs); err != nil {
+		return nil, fmt.Errorf("could not parse requirements.yaml: %s", err)
+	}
+
+	var repos []dependencyRepo
+	seen := make(map[string]bool)
+	for _, dep := range reqs.Dependencies {
+		url := dep.Repository
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+
+		var ref *helmfluxv1.DependencyRepo
+		for i := range source.DependencyRepos {
+			if urlsMatch(source.DependencyRepos[i].URL, url) {
+				ref = &source.DependencyRepos[i]
+				break
+			}
+		}
+		if ref != nil {
+			secret, err := corev1.Secrets(namespace).Get(ref.SecretRef.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get secret '%s' with credentials for dependency repository %s: %s", ref.SecretRef.Name, url, err)
+			}
+			username, password := secret.Data[dependencyRepoUsernameKey], secret.Data[dependencyRepoPasswordKey]
+			if len(username) == 0 || len(password) == 0 {
+				return nil, fmt.Errorf("secret '%s' with credentials for dependency repository %s must have both '%s' and '%s'",
+					ref.SecretRef.Name, url, dependencyRepoUsernameKey, dependencyRepoPasswordKey)
+			}
+			repos = append(repos, dependencyRepo{url: url, username: string(username), password: string(password)})
+			continue
+		}
+		if credentialsFile == "" {
+			continue
+		}
+		creds, ok, err := netrcCredentialsFor(credentialsFile, url, allowDefault)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read credentials for dependency repository %s: %s", url, err)
+		}
+		if ok {
+			repos = append(repos, dependencyRepo{url: url, username: creds.login, password: creds.password, fallback: true})
+		}
+	}
+	return repos, nil
+}
+
+// dependencyIndexMaxAge is the age of the indexes cached in a Helm
+// home for updating dependencies after which they are updated before
+// the dependencies are.
+const dependencyIndexMaxAge = 5 * time.Minute
+
+// dependencyHelmHome is a Helm home for updating the dependencies of
+// charts with, of which the repositories.yaml has the repos of the
+// Helm home of the operator and the dependency repos with
+// credentials. It is kept for as long as the operator runs, so that
+// the indexes of the repos are cached between updates; it is only
+// used for one update at a time.
+type dependencyHelmHome struct {
+	mu  sync.Mutex
+	dir string
+	// repos maps the names of the repos in repositories.yaml that
+	// have credentials to their URL.
+	repos map[string]string
+	// updated is when the indexes of the repos were last updated.
+	updated time.Time
+}
+
+// dependencyHelmHome returns the Helm home for updating dependencies
+// from the repos of the given Helm home and the given dependency
+// repos with their credentials, creating it if there is none yet for
+// them.
+func (chs *ChartChangeSync) dependencyHelmHome(base helmpath.Home, repos []dependencyRepo) (*dependencyHelmHome, error) {
+	repoFile, credentialed := dependencyRepoFile(base, repos)
+	// The file is identified by its repos alone
+	repoFile.Generated = time.Time{}
+	data, err := yaml.Marshal(repoFile)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+
+	chs.depHomesMu.Lock()
+	defer chs.depHomesMu.Unlock()
+	if home, ok := chs.depHomes[key]; ok {
+		return home, nil
+	}
+	dir, err := ioutil.TempDir("", "helm-home")
+	if err != nil {
+		return nil, err
+	}
+	home := helmpath.Home(dir)
+	if err := os.MkdirAll(home.Cache(), 0700); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := ioutil.WriteFile(home.RepositoryFile(), data, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if chs.depHomes == nil {
+		chs.depHomes = make(map[string]*dependencyHelmHome)
+	}
+	chs.depHomes[key] = &dependencyHelmHome{dir: dir, repos: credentialed}
+	return chs.depHomes[key], nil
+}
+
+// dependencyRepoFile returns the repositories.yaml with the repos of
+// the given Helm home, and the given dependency repos with their
+// credentials, and the names of the repos in it that have
+// credentials mapped to their URL. The index of each repo is cached
+// relative to the Helm home it is used in.
+func dependencyRepoFile(base helmpath.Home, repos []dependencyRepo) (*repo.RepoFile, map[string]string) {
+	repoFile := repo.NewRepoFile()
+	if baseFile, err := repo.LoadRepositoriesFile(base.RepositoryFile()); err == nil {
+		for _, entry := range baseFile.Repositories {
+			entry.Cache = entry.Name + "-index.yaml"
+			repoFile.Add(entry)
+		}
+	}
+	credentialed := make(map[string]string)
+dependencies:
+	for _, r := range repos {
+		// The credentials of a repo that is known already replace
+		// those it has for the dependency update, unless they are
+		// a fallback.
+		for _, entry := range repoFile.Repositories {
+			if urlsMatch(entry.URL, r.url) {
+				if !r.fallback || entry.Username == "" {
+					entry.Username, entry.Password = r.username, r.password
+				}
+				credentialed[entry.Name] = entry.URL
+				continue dependencies
+			}
+		}
+		name := dependencyRepoName(r.url)
+		repoFile.Add(&repo.Entry{
+			Name:     name,
+			URL:      r.url,
+			Username: r.username,
+			Password: r.password,
+			Cache:    name + "-index.yaml",
+		})
+		credentialed[name] = r.url
+	}
+	return repoFile, credentialed
+}
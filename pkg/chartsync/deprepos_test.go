@@ -0,0 +1,150 @@
+package chartsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/helm/pkg/helm/helmpath"
+	"k8s.io/helm/pkg/repo"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestDependencyRepos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deprepos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "requirements.yaml"), []byte(`dependencies:
+- name: redis
+  version: 10.0.0
+  repository: https://charts.example.com/private/
+- name: postgresql
+  version: 8.0.0
+  repository: https://other.example.com
+- name: common
+  version: 1.0.0
+  repository: "@stable"
+- name: local
+  version: 0.1.0
+  repository: file://../local
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	credentialsFile := filepath.Join(dir, ".netrc")
+	if err := ioutil.WriteFile(credentialsFile, []byte("machine other.example.com login bob password hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "private-charts", Namespace: "team"},
+		Data:       map[string][]byte{"username": []byte("alice"), "password": []byte("s3cr3t")},
+	})
+	source := &helmfluxv1.GitChartSource{DependencyRepos: []helmfluxv1.DependencyRepo{
+		{URL: "https://charts.example.com/private", SecretRef: corev1.LocalObjectReference{Name: "private-charts"}},
+	}}
+
+	repos, err := dependencyRepos(client.CoreV1(), "team", source, dir, credentialsFile, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []dependencyRepo{
+		{url: "https://charts.example.com/private/", username: "alice", password: "s3cr3t"},
+		{url: "https://other.example.com", username: "bob", password: "hunter2", fallback: true},
+	}, repos)
+
+	// Without a credentials file only the secrets are used
+	repos, err = dependencyRepos(client.CoreV1(), "team", source, dir, "", false)
+	assert.NoError(t, err)
+	assert.Len(t, repos, 1)
+
+	// A missing secret names the repo it is for
+	_, err = dependencyRepos(client.CoreV1(), "other", source, dir, "", false)
+	assert.Contains(t, err.Error(), "unable to get secret 'private-charts' with credentials for dependency repository https://charts.example.com/private/")
+
+	// A chart without requirements has no dependency repos
+	repos, err = dependencyRepos(client.CoreV1(), "team", source, filepath.Join(dir, "missing"), credentialsFile, false)
+	assert.NoError(t, err)
+	assert.Empty(t, repos)
+}
+
+func TestDependencyHelmHome(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "helm-base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+	base := helmpath.Home(baseDir)
+	if err := os.MkdirAll(base.Repository(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	baseFile := repo.NewRepoFile()
+	baseFile.Add(&repo.Entry{Name: "stable", URL: "https://kubernetes-charts.storage.googleapis.com", Cache: base.CacheIndex("stable")})
+	baseFile.Add(&repo.Entry{Name: "private", URL: "https://charts.example.com/private/", Cache: base.CacheIndex("private")})
+	baseFile.Add(&repo.Entry{Name: "shared", URL: "https://shared.example.com", Username: "carol", Password: "pa55", Cache: base.CacheIndex("shared")})
+	if err := baseFile.WriteFile(base.RepositoryFile(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chs := &ChartChangeSync{}
+	repos := []dependencyRepo{
+		{url: "https://charts.example.com/private", username: "alice", password: "s3cr3t"},
+		{url: "https://other.example.com", username: "bob", password: "hunter2", fallback: true},
+		{url: "https://shared.example.com", username: "bob", password: "hunter2", fallback: true},
+	}
+	depHome, err := chs.dependencyHelmHome(base, repos)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(depHome.dir)
+	home := helmpath.Home(depHome.dir)
+
+	repoFile, err := repo.LoadRepositoriesFile(home.RepositoryFile())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, repoFile.Repositories, 4)
+	for _, entry := range repoFile.Repositories {
+		assert.Equal(t, entry.Name+"-index.yaml", entry.Cache)
+		switch entry.Name {
+		case "stable":
+			assert.Empty(t, entry.Username)
+		case "private":
+			assert.Equal(t, "alice", entry.Username)
+		case "shared":
+			// Fallback credentials do not replace those there are
+			assert.Equal(t, "carol", entry.Username)
+		default:
+			assert.Equal(t, dependencyRepoName("https://other.example.com"), entry.Name)
+			assert.Equal(t, "bob", entry.Username)
+			assert.Equal(t, "hunter2", entry.Password)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"private": "https://charts.example.com/private/",
+		dependencyRepoName("https://other.example.com"): "https://other.example.com",
+		"shared": "https://shared.example.com",
+	}, depHome.repos)
+
+	// The Helm home, and the indexes it caches, are kept for the same
+	// repos and credentials, but not for others
+	same, err := chs.dependencyHelmHome(base, repos)
+	assert.NoError(t, err)
+	assert.True(t, same == depHome)
+	repos[0].password = "rotated"
+	other, err := chs.dependencyHelmHome(base, repos)
+	if assert.NoError(t, err) {
+		defer os.RemoveAll(other.dir)
+		assert.NotEqual(t, depHome.dir, other.dir)
+	}
+
+	// The repos of the base home are left alone
+	baseFile, err = repo.LoadRepositoriesFile(base.RepositoryFile())
+	assert.NoError(t, err)
+	assert.Len(t, baseFile.Repositories, 3)
+}
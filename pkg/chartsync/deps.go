@@ -1,15 +1,22 @@
 package chartsync
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
-// helmHome is optional; if it's "", it's left to default
-func updateDependencies(chartDir, helmhome string) error {
+// helmCommand is the Helm binary used to update dependencies.
+var helmCommand = "helm"
+
+// helmHome is optional; if it's nil, it's left to default. The update
+// is aborted when the given context is done.
+func updateDependencies(ctx context.Context, chartDir string, home *dependencyHelmHome) error {
 	var hasLockFile bool
 
 	// sanity check: does the chart directory exist
@@ -51,22 +58,78 @@ func updateDependencies(chartDir, helmhome string) error {
 		defer os.Remove(lockfilePath)
 	}
 
-	cmd := exec.Command("helm", "repo", "update")
-	if helmhome != "" {
-		cmd.Args = append(cmd.Args, "--home", helmhome)
+	// A Helm home of our own is only used for one update at a time,
+	// and the indexes it caches are only updated once they are old.
+	var helmhome string
+	var repos map[string]string
+	updateRepos := true
+	if home != nil {
+		home.mu.Lock()
+		defer home.mu.Unlock()
+		helmhome, repos = home.dir, home.repos
+		updateRepos = time.Since(home.updated) > dependencyIndexMaxAge
 	}
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("could not update repo: %s", string(out))
+
+	if updateRepos {
+		cmd := exec.CommandContext(ctx, helmCommand, "repo", "update")
+		if helmhome != "" {
+			cmd.Args = append(cmd.Args, "--home", helmhome)
+		}
+		out, err := cmd.CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("timed out updating repos for chart dependencies")
+		}
+		if err != nil {
+			return fmt.Errorf("could not update repo: %s", string(out))
+		}
+		// Helm carries on when it can not update a repo; a dependency
+		// repo we have credentials for that fails is most likely one
+		// that could not be authenticated with, so say which.
+		if msg := failedDependencyRepo(string(out), repos); msg != "" {
+			return fmt.Errorf("could not update dependency repo: %s", msg)
+		}
+		if home != nil {
+			home.updated = time.Now()
+		}
 	}
 
-	cmd = exec.Command("helm", "dep", "build", ".")
+	cmd := exec.CommandContext(ctx, helmCommand, "dep", "build", ".")
+	if helmhome != "" {
+		cmd.Args = append(cmd.Args, "--home", helmhome)
+	}
 	cmd.Dir = chartDir
 
-	out, err = cmd.CombinedOutput()
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out updating dependencies in %s", chartDir)
+	}
 	if err != nil {
+		if msg := failedDependencyRepo(string(out), repos); msg != "" {
+			return fmt.Errorf("could not update dependency repo: %s", msg)
+		}
 		return fmt.Errorf("could not update dependencies in %s: %s", chartDir, string(out))
 	}
 
 	return nil
 }
+
+// failedDependencyRepo returns the part of the given output of `helm
+// repo update` or `helm dep build` about one of the given repos with
+// credentials, of which the names in repositories.yaml are mapped to
+// their URL, that could not be updated, if any.
+func failedDependencyRepo(out string, repos map[string]string) string {
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		for name, url := range repos {
+			if !strings.Contains(line, "Unable to get an update from the \""+name+"\"") {
+				continue
+			}
+			msg := url
+			if i+1 < len(lines) {
+				msg += ": " + strings.TrimSpace(lines[i+1])
+			}
+			return msg
+		}
+	}
+	return ""
+}
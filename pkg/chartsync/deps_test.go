@@ -1,10 +1,15 @@
 package chartsync
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_updateDependencies(t *testing.T) {
@@ -43,9 +48,73 @@ func Test_updateDependencies(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := updateDependencies(tt.args.chartDir, helmhome); (err != nil) != tt.wantErr {
+			if err := updateDependencies(context.Background(), tt.args.chartDir, &dependencyHelmHome{dir: helmhome}); (err != nil) != tt.wantErr {
 				t.Errorf("updateDependencies() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func Test_updateDependencies_timeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-helm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A Helm that takes its time fetching the dependencies
+	slowHelm := filepath.Join(dir, "helm")
+	if err := ioutil.WriteFile(slowHelm, []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(cmd string) { helmCommand = cmd }(helmCommand)
+	helmCommand = slowHelm
+
+	chartDir := filepath.Join(dir, "chart")
+	if err := os.Mkdir(chartDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(chartDir, "requirements.yaml"), []byte("dependencies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = updateDependencies(ctx, chartDir, nil)
+	if err == nil {
+		t.Fatal("expected updateDependencies() to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("updateDependencies() returned after %s, expected it to be aborted on timeout", elapsed)
+	}
+}
+
+func TestFailedDependencyRepo(t *testing.T) {
+	repos := map[string]string{
+		"flux-dependency-0a1b2c3d": "https://charts.example.com/private",
+		"shared":                   "https://shared.example.com",
+	}
+	out := `Hang tight while we grab the latest from your chart repositories...
+...Skip local chart repository
+...Unable to get an update from the "flux-dependency-0a1b2c3d" chart repository (https://charts.example.com/private):
+	failed to fetch https://charts.example.com/private/index.yaml : 401 Unauthorized
+...Successfully got an update from the "stable" chart repository
+Update Complete.
+`
+	assert.Equal(t, `https://charts.example.com/private: failed to fetch https://charts.example.com/private/index.yaml : 401 Unauthorized`,
+		failedDependencyRepo(out, repos))
+
+	// Repos of the Helm home that got credentials are matched by
+	// their name as well
+	assert.Equal(t, `https://shared.example.com: failed to fetch https://shared.example.com/index.yaml : 403 Forbidden`,
+		failedDependencyRepo(`...Unable to get an update from the "shared" chart repository (https://shared.example.com):
+	failed to fetch https://shared.example.com/index.yaml : 403 Forbidden
+`, repos))
+
+	// Other repos failing to update is left to Helm
+	assert.Empty(t, failedDependencyRepo(`...Unable to get an update from the "stable" chart repository (https://kubernetes-charts.storage.googleapis.com):
+	Get https://kubernetes-charts.storage.googleapis.com/index.yaml: dial tcp: lookup failed
+Update Complete.
+`, repos))
+}
@@ -0,0 +1,185 @@
+package chartsync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pmezard/go-difflib/difflib"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	"github.com/fluxcd/helm-operator/pkg/api"
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+const (
+	// DiffFormatCmp renders diffs as go-cmp does.
+	DiffFormatCmp = "cmp"
+	// DiffFormatUnified renders diffs as unified diffs.
+	DiffFormatUnified = "unified"
+)
+
+// defaultUnifiedDiffContext is the number of lines of context around
+// the changes of a unified diff, if not configured.
+const defaultUnifiedDiffContext = 3
+
+// ValidDiffFormat returns if the given diff format is supported.
+func ValidDiffFormat(format string) bool {
+	return format == DiffFormatCmp || format == DiffFormatUnified
+}
+
+// DiffRelease returns the difference between the current release of
+// the HelmRelease with the given namespace and name and the release a
+// dry run of its upgrade renders, comparing the values and the chart
+// as is done to determine if the release should be upgraded, with its
+// sensitive values redacted. Nothing is released, and the status of
+// the HelmRelease is left untouched.
+func (chs *ChartChangeSync) DiffRelease(namespace, name string) (api.ReleaseDiff, error) {
+	var diff api.ReleaseDiff
+	// The values and manifests may contain secrets
+	if !chs.config.AllowRenderRelease {
+		return diff, api.ErrRenderReleaseDisabled
+	}
+
+	hr, err := chs.hrLister.HelmReleases(namespace).Get(name)
+	if err != nil {
+		return diff, err
+	}
+	curr, des, redact, err := chs.dryRunRelease(*hr)
+	if err != nil {
+		return diff, err
+	}
+	return releaseDiff(*hr, curr, des, chs.config.DiffContextLines, redact)
+}
+
+// releaseDiff returns the difference between the given current
+// release of the HelmRelease, which may be nil, and the given desired
+// release, of which the values, chart and manifests are redacted with
+// the given func before they are compared.
+func releaseDiff(hr helmfluxv1.HelmRelease, curr, des *hapi_release.Release, context int, redact func(string) string) (api.ReleaseDiff, error) {
+	var diff api.ReleaseDiff
+	currVals, currChart, currManifests := "", "", ""
+	if curr != nil {
+		vals, chart, err := comparableRelease(hr, curr)
+		if err != nil {
+			return diff, err
+		}
+		diff.Revision = curr.GetVersion()
+		currVals = redact(vals.GetRaw())
+		if chart != nil {
+			currChart = redact(chartText(chart))
+		}
+		currManifests = redact(renderedManifests(curr))
+	}
+	vals, chart, err := comparableRelease(hr, des)
+	if err != nil {
+		return diff, err
+	}
+	desChart := ""
+	if chart != nil {
+		desChart = redact(chartText(chart))
+	}
+	diff.Values = unifiedDiff(currVals, redact(vals.GetRaw()), context)
+	diff.Chart = unifiedDiff(currChart, desChart, context)
+	diff.Manifests = unifiedDiff(currManifests, redact(renderedManifests(des)), context)
+	return diff, nil
+}
+
+// valuesDiff renders the difference between the given values, of
+// which cmpDiff is the go-cmp diff, in the configured format.
+func (chs *ChartChangeSync) valuesDiff(curr, des *hapi_chart.Config, cmpDiff string) string {
+	if chs.config.DiffFormat != DiffFormatUnified {
+		return limitCmpContext(cmpDiff, chs.config.DiffContextLines)
+	}
+	return unifiedDiff(curr.GetRaw(), des.GetRaw(), chs.config.DiffContextLines)
+}
+
+// chartDiff renders the difference between the given (sorted) charts,
+// of which cmpDiff is the go-cmp diff, in the configured format.
+func (chs *ChartChangeSync) chartDiff(curr, des *hapi_chart.Chart, cmpDiff string) string {
+	if chs.config.DiffFormat != DiffFormatUnified {
+		return limitCmpContext(cmpDiff, chs.config.DiffContextLines)
+	}
+	return unifiedDiff(chartText(curr), chartText(des), chs.config.DiffContextLines)
+}
+
+// unifiedDiff returns the unified diff of the current and desired
+// text, with the given number of lines of context (zero for the
+// default).
+func unifiedDiff(curr, des string, context int) string {
+	if context <= 0 {
+		context = defaultUnifiedDiffContext
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(curr),
+		B:        difflib.SplitLines(des),
+		FromFile: "current",
+		ToFile:   "desired",
+		Context:  context,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return diff
+}
+
+// limitCmpContext limits the unchanged lines of a go-cmp diff to the
+// given number of lines around every changed line, eliding the rest;
+// zero keeps all lines.
+func limitCmpContext(diff string, context int) string {
+	if context <= 0 {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "+") {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+	var out []string
+	elided := false
+	for i, line := range lines {
+		if keep[i] {
+			out = append(out, line)
+			elided = false
+			continue
+		}
+		if !elided {
+			out = append(out, "  ...")
+			elided = true
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// chartText renders a chart as text for diffing: its metadata and
+// values, followed by its templates and files, and then by its
+// dependencies, each prefixed by its path.
+func chartText(c *hapi_chart.Chart) string {
+	var b strings.Builder
+	writeChartText(&b, c, "")
+	return b.String()
+}
+
+func writeChartText(b *strings.Builder, c *hapi_chart.Chart, prefix string) {
+	name := c.GetMetadata().GetName()
+	path := prefix + name
+	fmt.Fprintf(b, "# Source: %s/Chart.yaml\n%s", path, proto.MarshalTextString(c.GetMetadata()))
+	fmt.Fprintf(b, "# Source: %s/values.yaml\n%s\n", path, c.GetValues().GetRaw())
+	for _, t := range c.GetTemplates() {
+		fmt.Fprintf(b, "# Source: %s/%s\n%s\n", path, t.GetName(), t.GetData())
+	}
+	for _, f := range c.GetFiles() {
+		fmt.Fprintf(b, "# Source: %s/%s\n%s\n", path, f.GetTypeUrl(), f.GetValue())
+	}
+	for _, d := range c.GetDependencies() {
+		writeChartText(b, d, path+"/charts/")
+	}
+}
@@ -0,0 +1,114 @@
+package chartsync
+
+import (
+	"strings"
+	"testing"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/stretchr/testify/assert"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+)
+
+func TestLimitCmpContext(t *testing.T) {
+	diff := strings.Join([]string{
+		"  a",
+		"  b",
+		"  c",
+		"- d",
+		"+ D",
+		"  e",
+		"  f",
+		"  g",
+	}, "\n")
+
+	assert.Equal(t, diff, limitCmpContext(diff, 0))
+	assert.Equal(t, strings.Join([]string{
+		"  ...",
+		"  c",
+		"- d",
+		"+ D",
+		"  e",
+		"  ...",
+	}, "\n"), limitCmpContext(diff, 1))
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	curr := "a: 1\nb: 2\nc: 3\nd: 4\ne: 5\n"
+	des := "a: 1\nb: 2\nc: 30\nd: 4\ne: 5\n"
+
+	assert.Equal(t, `--- current
++++ desired
+@@ -2,3 +2,3 @@
+ b: 2
+-c: 3
++c: 30
+ d: 4
+`, unifiedDiff(curr, des, 1))
+}
+
+func TestChartText(t *testing.T) {
+	c := &hapi_chart.Chart{
+		Metadata:  &hapi_chart.Metadata{Name: "parent", Version: "1.0.0"},
+		Values:    &hapi_chart.Config{Raw: "replicas: 1"},
+		Templates: []*hapi_chart.Template{{Name: "templates/deployment.yaml", Data: []byte("kind: Deployment")}},
+		Dependencies: []*hapi_chart.Chart{{
+			Metadata: &hapi_chart.Metadata{Name: "child"},
+		}},
+	}
+
+	text := chartText(c)
+	assert.Contains(t, text, "# Source: parent/values.yaml\nreplicas: 1\n")
+	assert.Contains(t, text, "# Source: parent/templates/deployment.yaml\nkind: Deployment\n")
+	assert.Contains(t, text, "# Source: parent/charts/child/Chart.yaml\n")
+}
+
+func TestReleaseDiff(t *testing.T) {
+	chart := &hapi_chart.Chart{Metadata: &hapi_chart.Metadata{Name: "podinfo", Version: "3.2.0"}}
+	curr := &hapi_release.Release{
+		Version:  3,
+		Chart:    chart,
+		Config:   &hapi_chart.Config{Raw: "replicaCount: 1\n"},
+		Manifest: "kind: Deployment\nspec:\n  replicas: 1\n",
+	}
+	des := &hapi_release.Release{
+		Chart:    chart,
+		Config:   &hapi_chart.Config{Raw: "replicaCount: 2\n"},
+		Manifest: "kind: Deployment\nspec:\n  replicas: 2\n",
+	}
+
+	var hr helmfluxv1.HelmRelease
+	noRedact := func(text string) string { return text }
+	diff, err := releaseDiff(hr, curr, des, 0, noRedact)
+	assert.NoError(t, err)
+	assert.True(t, diff.Changed())
+	assert.Equal(t, int32(3), diff.Revision)
+	assert.Contains(t, diff.Values, "+replicaCount: 2")
+	assert.Empty(t, diff.Chart)
+	assert.Contains(t, diff.Manifests, "+  replicas: 2")
+
+	diff, err = releaseDiff(hr, curr, curr, 0, noRedact)
+	assert.NoError(t, err)
+	assert.False(t, diff.Changed())
+
+	// Ignored values do not make a difference, as when deciding to upgrade
+	hr.Spec.IgnoreValues = []string{"replicaCount"}
+	diff, err = releaseDiff(hr, curr, des, 0, noRedact)
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Values)
+	hr.Spec.IgnoreValues = nil
+
+	// Values are redacted before they are compared
+	diff, err = releaseDiff(hr, curr, des, 0, func(text string) string {
+		return strings.NewReplacer("replicaCount: 1", "replicaCount: <redacted>", "replicaCount: 2", "replicaCount: <redacted>").Replace(text)
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Values)
+
+	// Without a current release, everything is new
+	diff, err = releaseDiff(hr, nil, des, 0, noRedact)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), diff.Revision)
+	assert.Contains(t, diff.Chart, "+")
+	assert.Contains(t, diff.Values, "+replicaCount: 2")
+}
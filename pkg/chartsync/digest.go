@@ -0,0 +1,63 @@
+package chartsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// chartDigest returns the SHA256 digest of the chart archive at the
+// given path, or an empty string if the chart is not an archive (e.g.
+// a chart from git).
+func chartDigest(chartPath string) (string, error) {
+	f, err := os.Open(chartPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return "", nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recordChartDigest records the digest of the chart at the given path
+// in the status of the given HelmRelease, as the digest of the chart
+// that was released.
+func (chs *ChartChangeSync) recordChartDigest(hr helmfluxv1.HelmRelease, chartPath string) {
+	digest, err := chartDigest(chartPath)
+	if err != nil {
+		chs.logger.Log("warning", "could not determine the chart digest", "resource", hr.ResourceID().String(), "err", err)
+		return
+	}
+	if err := status.SetChartDigest(chs.statusClient(hr), hr, digest); err != nil {
+		chs.logger.Log("warning", "could not update the chart digest", "resource", hr.ResourceID().String(), "err", err)
+	}
+}
+
+// chartDigestChanged returns if the chart archive at the given path
+// differs from the chart that was last released for the given
+// HelmRelease, according to its status. It returns false when either
+// digest is unknown.
+func chartDigestChanged(hr helmfluxv1.HelmRelease, chartPath string) bool {
+	if hr.Status.ChartDigest == "" {
+		return false
+	}
+	digest, err := chartDigest(chartPath)
+	if err != nil || digest == "" {
+		return false
+	}
+	return digest != hr.Status.ChartDigest
+}
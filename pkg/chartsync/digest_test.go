@@ -0,0 +1,45 @@
+package chartsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestChartDigest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chart-digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chartPath := filepath.Join(dir, "podinfo-3.2.0.tgz")
+	if err := ioutil.WriteFile(chartPath, []byte("chart"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := chartDigest(chartPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb", digest)
+
+	// Charts that are not archives have no digest
+	digest, err = chartDigest(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, digest)
+
+	hr := helmfluxv1.HelmRelease{}
+	assert.False(t, chartDigestChanged(hr, chartPath), "without a released digest")
+	hr.Status.ChartDigest = "sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb"
+	assert.False(t, chartDigestChanged(hr, chartPath))
+	assert.False(t, chartDigestChanged(hr, dir), "for a chart that is not an archive")
+
+	// An overwritten chart of the same version is told apart
+	if err := ioutil.WriteFile(chartPath, []byte("overwritten chart"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, chartDigestChanged(hr, chartPath))
+}
@@ -0,0 +1,24 @@
+package chartsync
+
+import (
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// releaseDisappeared returns if the release with the given name,
+// which is about to be installed for the HelmRelease, was installed
+// before and has since been deleted by other means (e.g. `helm
+// delete`), rather than never installed. The given release is what
+// is left of it, if it was deleted without being purged.
+//
+// A release we installed or upgraded has its chart revision recorded
+// in the status of the HelmRelease, together with its name; when the
+// release name of the HelmRelease changes, the release with the new
+// name has not been installed before.
+func releaseDisappeared(hr helmfluxv1.HelmRelease, releaseName string, rel *hapi_release.Release) bool {
+	if rel.GetInfo().GetStatus().GetCode() == hapi_release.Status_DELETED {
+		return true
+	}
+	return hr.Status.Revision != "" && hr.Status.ReleaseName == releaseName
+}
@@ -0,0 +1,29 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8shelm "k8s.io/helm/pkg/helm"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func Test_releaseDisappeared(t *testing.T) {
+	deleted := k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: "podinfo", StatusCode: hapi_release.Status_DELETED})
+
+	// Never installed
+	hr := helmfluxv1.HelmRelease{}
+	assert.False(t, releaseDisappeared(hr, "podinfo", nil))
+
+	// Installed, and purged by other means
+	hr.Status = helmfluxv1.HelmReleaseStatus{ReleaseName: "podinfo", Revision: "1.0.0"}
+	assert.True(t, releaseDisappeared(hr, "podinfo", nil))
+
+	// Installed, and deleted without being purged
+	assert.True(t, releaseDisappeared(helmfluxv1.HelmRelease{}, "podinfo", deleted))
+
+	// Installed under another release name before
+	assert.False(t, releaseDisappeared(hr, "podinfo-renamed", nil))
+}
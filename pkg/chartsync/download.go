@@ -1,58 +1,314 @@
 package chartsync
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/Masterminds/semver"
 	"github.com/spf13/pflag"
+	"golang.org/x/oauth2/clientcredentials"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/helm/pkg/downloader"
 	"k8s.io/helm/pkg/getter"
 	helmenv "k8s.io/helm/pkg/helm/environment"
 	"k8s.io/helm/pkg/repo"
+	"k8s.io/helm/pkg/tlsutil"
+	"k8s.io/helm/pkg/version"
 
 	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
 )
 
-// makeChartPath gives the expected filesystem location for a chart,
-// without testing whether the file exists or not.
-func makeChartPath(base string, source *helmfluxv1.RepoChartSource) string {
+// chartCacheDir returns the directory in the chart cache at base for
+// the charts of the given (URL-like) key, creating it if necessary.
+// All charts are cached in a directory like this, so that they are
+// evicted alike regardless of where they came from.
+func chartCacheDir(base, key string) (string, error) {
 	// We don't need to obscure the location of the charts in the
 	// filesystem; but we do need a stable, filesystem-friendly path
-	// to them that is based on the URL.
-	repoPath := filepath.Join(base, base64.URLEncoding.EncodeToString([]byte(source.CleanRepoURL())))
-	if err := os.MkdirAll(repoPath, 00750); err != nil {
-		panic(err)
+	// to them that is based on the key.
+	dir := filepath.Join(base, base64.URLEncoding.EncodeToString([]byte(key)))
+	if err := os.MkdirAll(dir, 00750); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// makeChartPath gives the expected filesystem location for a chart,
+// without testing whether the file exists or not.
+func makeChartPath(base string, source *helmfluxv1.RepoChartSource) (string, error) {
+	repoPath, err := chartCacheDir(base, source.CleanRepoURL())
+	if err != nil {
+		return "", err
 	}
 	filename := fmt.Sprintf("%s-%s.tgz", source.Name, source.Version)
-	return filepath.Join(repoPath, filename)
+	return filepath.Join(repoPath, filename), nil
+}
+
+// downloadOptions configures the HTTP(S) transport charts are
+// downloaded with.
+type downloadOptions struct {
+	// Proxy is the URL of the proxy to download through, if empty
+	// the proxy is taken from the environment
+	Proxy string
+	// CAFile is the path to a PEM encoded CA bundle to trust in
+	// addition to the system CAs
+	CAFile string
+	// CA is a PEM encoded CA bundle to trust in addition to the
+	// system CAs
+	CA []byte
+	// ClientCert is the certificate to present to the chart repo
+	ClientCert *tls.Certificate
+	// Keyring is the keyring to verify the provenance of the chart
+	// against, if set the provenance file is downloaded along with
+	// the chart
+	Keyring []byte
+	// Token is the bearer token to present to the chart repo, in
+	// place of basic auth
+	Token *repoToken
+	// Username and Password are the basic auth credentials for the
+	// chart repo, if its entry in repositories.yaml has none
+	Username string
+	Password string
+	// MaxSize is the size in bytes beyond which a chart download is
+	// aborted, zero means no limit
+	MaxSize int64
+	// Bucket is the data of the secret with the credentials for the
+	// bucket the chart repo is kept in
+	Bucket map[string][]byte
+	// BucketSecret is the `<namespace>/<name>` of the secret Bucket
+	// is the data of
+	BucketSecret string
+	// AzureManagedIdentity allows bucket secrets to ask for the
+	// managed identity of the operator for Azure Blob Storage
+	AzureManagedIdentity bool
+	// AWSOperatorCredentials allows S3 buckets of which the secret
+	// has no credentials to be signed for with those of the operator
+	AWSOperatorCredentials bool
+	// GCPOperatorCredentials allows GCS buckets of which the secret
+	// has no key to be read with the credentials of the operator
+	GCPOperatorCredentials bool
+}
+
+// verificationError is returned when the verification of the
+// provenance of a chart fails.
+type verificationError struct {
+	err error
+}
+
+func (e verificationError) Error() string {
+	return "chart verification failed: " + e.err.Error()
+}
+
+// downloadTooLargeError is returned when a download exceeds the
+// maximum size.
+type downloadTooLargeError struct {
+	href    string
+	maxSize int64
+}
+
+func (e downloadTooLargeError) Error() string {
+	return fmt.Sprintf("%s exceeds the maximum download size of %d bytes", e.href, e.maxSize)
 }
 
 // ensureChartFetched returns the path to a downloaded chart, fetching
 // it first if necessary. It always returns the expected path to the
 // chart, and either an error or nil.
-func ensureChartFetched(base string, source *helmfluxv1.RepoChartSource) (string, error) {
-	chartPath := makeChartPath(base, source)
+func ensureChartFetched(base string, source *helmfluxv1.RepoChartSource, opts downloadOptions) (string, error) {
+	chartPath, err := makeChartPath(base, source)
+	if err != nil {
+		return chartPath, err
+	}
 	stat, err := os.Stat(chartPath)
 	switch {
 	case os.IsNotExist(err):
-		return chartPath, downloadChart(chartPath, source)
+		if err := downloadChart(chartPath, source, opts); err != nil {
+			return chartPath, err
+		}
 	case err != nil:
 		return chartPath, err
 	case stat.IsDir():
 		return chartPath, errors.New("path to chart exists but is a directory")
 	}
+	if opts.Keyring == nil {
+		return chartPath, nil
+	}
+
+	// The chart may have been downloaded before without its
+	// provenance file, e.g. by a HelmRelease that does not verify
+	if _, err := os.Stat(chartPath + ".prov"); os.IsNotExist(err) {
+		if err := downloadChart(chartPath, source, opts); err != nil {
+			return chartPath, err
+		}
+	}
+	if err := verifyChart(chartPath, opts.Keyring); err != nil {
+		// Remove the chart so that it is downloaded again on the next
+		// attempt, which may have been fixed upstream
+		os.Remove(chartPath)
+		os.Remove(chartPath + ".prov")
+		return chartPath, verificationError{err}
+	}
 	return chartPath, nil
 }
 
+// verifyChart verifies the chart at the given path against its
+// provenance file and the given keyring.
+func verifyChart(chartPath string, keyring []byte) error {
+	f, err := ioutil.TempFile("", "keyring")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(keyring); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	_, err = downloader.VerifyChart(chartPath, f.Name())
+	return err
+}
+
+// chartFetcher fetches the charts of a kind of chart repository.
+type chartFetcher interface {
+	// fetch writes the chart of the given source to the given path,
+	// along with its provenance file if the download options have a
+	// keyring to verify the chart with.
+	fetch(destFile string, source *helmfluxv1.RepoChartSource, opts downloadOptions) error
+	// resolveVersion returns the highest version of the chart of the
+	// given source that matches the given semver range.
+	resolveVersion(source *helmfluxv1.RepoChartSource, versionRange string, opts downloadOptions) (string, error)
+}
+
+// chartFetcherFor returns the chartFetcher for the repository of the
+// given chart source: an OCI registry, or else a Helm repo, of which
+// the index and charts are fetched with the getter for the scheme of
+// their URLs.
+func chartFetcherFor(source *helmfluxv1.RepoChartSource) chartFetcher {
+	if source.IsOCI() {
+		return ociFetcher{}
+	}
+	return helmRepoFetcher{}
+}
+
 // downloadChart attempts to fetch a chart tarball, given the name,
 // version and repo URL in `source`, and the path to write the file
 // to in `destFile`.
-func downloadChart(destFile string, source *helmfluxv1.RepoChartSource) error {
+func downloadChart(destFile string, source *helmfluxv1.RepoChartSource, opts downloadOptions) error {
+	return chartFetcherFor(source).fetch(destFile, source, opts)
+}
+
+// helmRepoFetcher fetches charts from Helm repos.
+type helmRepoFetcher struct{}
+
+func (helmRepoFetcher) fetch(destFile string, source *helmfluxv1.RepoChartSource, opts downloadOptions) error {
+	getters, repoEntry, err := repoGetters(source, opts)
+	if err != nil {
+		return err
+	}
+
+	// TODO(michael): could look for an existing index file here,
+	// and/or update it. Then we're _pretty_ close to just using
+	// `repo.DownloadTo(...)`.
+	chartURL, err := repo.FindChartInAuthRepoURL(source.CleanRepoURL(), repoEntry.Username, repoEntry.Password, source.Name, source.Version, repoEntry.CertFile, repoEntry.KeyFile, repoEntry.CAFile, getters)
+	if err != nil {
+		return err
+	}
+
+	// Here I'm reproducing the useful part (for us) of
+	// `k8s.io/helm/pkg/downloader.Downloader.ResolveChartVersion(...)`,
+	// stepping around `DownloadTo(...)` as it's too general. The
+	// former interacts with Helm's local caching, which would mean
+	// having to maintain the local cache. Since we already have the
+	// information we need, we can just go ahead and get the file.
+	u, err := url.Parse(chartURL)
+	if err != nil {
+		return err
+	}
+	getterConstructor, err := getters.ByScheme(u.Scheme)
+	if err != nil {
+		return err
+	}
+
+	g, err := getterConstructor(chartURL, repoEntry.CertFile, repoEntry.KeyFile, repoEntry.CAFile)
+	if err != nil {
+		return err
+	}
+	if t, ok := g.(*getter.HttpGetter); ok {
+		t.SetCredentials(repoEntry.Username, repoEntry.Password)
+	}
+
+	if err := downloadTo(g, u.String(), destFile, opts.MaxSize); err != nil {
+		return err
+	}
+
+	if opts.Keyring != nil {
+		if err := downloadTo(g, u.String()+".prov", destFile+".prov", opts.MaxSize); err != nil {
+			return verificationError{fmt.Errorf("failed to download provenance file: %s", err)}
+		}
+	}
+
+	return nil
+}
+
+// streamingGetter is a getter that writes what it gets to a writer,
+// rather than holding it in memory.
+type streamingGetter interface {
+	download(href string, w io.Writer, maxSize int64) error
+}
+
+// downloadTo fetches the file at the given URL with the getter, and
+// writes it to the given path, aborting once it exceeds the maximum
+// size if that is above zero. Our own getters stream the file to disk,
+// others buffer it. The file is written next to the path
+// first, so that an aborted download never leaves a partial file at
+// the path.
+func downloadTo(g getter.Getter, href, path string, maxSize int64) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if sg, ok := g.(streamingGetter); ok {
+		err = sg.download(href, f, maxSize)
+	} else {
+		var buf *bytes.Buffer
+		if buf, err = g.Get(href); err == nil {
+			if maxSize > 0 && int64(buf.Len()) > maxSize {
+				err = downloadTooLargeError{href, maxSize}
+			} else {
+				_, err = buf.WriteTo(f)
+			}
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// helmSettings returns the settings of the Helm client.
+func helmSettings() helmenv.EnvSettings {
 	// Helm's support libs are designed to be driven by the
 	// command-line client, so there are some inevitable CLI-isms,
 	// like getting values from flags and the environment. None of
@@ -67,6 +323,14 @@ func downloadChart(destFile string, source *helmfluxv1.RepoChartSource) error {
 	// Parse. This next bit will use any settings from the
 	// environment.
 	settings.Init(flags)
+	return settings
+}
+
+// repoGetters returns the getters to fetch from the repo of the
+// given chart source with, and the entry for the repo from the
+// repositories file.
+func repoGetters(source *helmfluxv1.RepoChartSource, opts downloadOptions) (getter.Providers, *repo.Entry, error) {
+	settings := helmSettings()
 	getters := getter.All(settings) // <-- aaaand this is the payoff
 
 	// This resolves the repo URL, chart name and chart version to a
@@ -76,7 +340,7 @@ func downloadChart(destFile string, source *helmfluxv1.RepoChartSource) error {
 	// repositories.yaml.
 	repoFile, err := repo.LoadRepositoriesFile(settings.Home.RepositoryFile())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Now find the entry for the repository, if there is one. If not,
@@ -88,46 +352,410 @@ func downloadChart(destFile string, source *helmfluxv1.RepoChartSource) error {
 			break
 		}
 	}
+	if repoEntry.Username == "" && opts.Username != "" {
+		withCredentials := *repoEntry
+		withCredentials.Username, withCredentials.Password = opts.Username, opts.Password
+		repoEntry = &withCredentials
+	}
 
-	// TODO(michael): could look for an existing index file here,
-	// and/or update it. Then we're _pretty_ close to just using
-	// `repo.DownloadTo(...)`.
-	chartURL, err := repo.FindChartInAuthRepoURL(source.CleanRepoURL(), repoEntry.Username, repoEntry.Password, source.Name, source.Version, repoEntry.CertFile, repoEntry.KeyFile, repoEntry.CAFile, getters)
+	// Swap out Helm's HTTP getter, as it does not allow us to
+	// configure the transport, and holds downloads in memory.
+	getters = opts.providers(getters, repoEntry.Username, repoEntry.Password)
+
+	return getters, repoEntry, nil
+}
+
+// resolveChartVersion resolves the version of the given chart source
+// to the highest version in the repo index that matches it, if it is
+// a semver range rather than a version.
+func resolveChartVersion(source *helmfluxv1.RepoChartSource, opts downloadOptions) (string, error) {
+	if _, err := semver.NewVersion(source.Version); err == nil {
+		return source.Version, nil
+	}
+	versionRange := normalizeVersionRange(source.Version)
+	if _, err := semver.NewConstraint(versionRange); err != nil {
+		return "", fmt.Errorf("invalid chart version or semver range %q: %s", source.Version, err)
+	}
+	return chartFetcherFor(source).resolveVersion(source, versionRange, opts)
+}
+
+// resolveVersion resolves the semver range to a version with the
+// index of the Helm repo.
+func (helmRepoFetcher) resolveVersion(source *helmfluxv1.RepoChartSource, versionRange string, opts downloadOptions) (string, error) {
+	getters, repoEntry, err := repoGetters(source, opts)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Here I'm reproducing the useful part (for us) of
-	// `k8s.io/helm/pkg/downloader.Downloader.ResolveChartVersion(...)`,
-	// stepping around `DownloadTo(...)` as it's too general. The
-	// former interacts with Helm's local caching, which would mean
-	// having to maintain the local cache. Since we already have the
-	// information we need, we can just go ahead and get the file.
-	u, err := url.Parse(chartURL)
+	tempIndexFile, err := ioutil.TempFile("", "tmp-repo-file")
 	if err != nil {
-		return err
+		return "", err
 	}
-	getterConstructor, err := getters.ByScheme(u.Scheme)
+	tempIndexFile.Close()
+	defer os.Remove(tempIndexFile.Name())
+
+	r, err := repo.NewChartRepository(&repo.Entry{
+		URL:      source.CleanRepoURL(),
+		Username: repoEntry.Username,
+		Password: repoEntry.Password,
+		CertFile: repoEntry.CertFile,
+		KeyFile:  repoEntry.KeyFile,
+		CAFile:   repoEntry.CAFile,
+	}, getters)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if err := r.DownloadIndexFile(tempIndexFile.Name()); err != nil {
+		return "", fmt.Errorf("looks like %q is not a valid chart repository or cannot be reached: %s", source.RepoURL, err)
 	}
+	index, err := repo.LoadIndexFile(tempIndexFile.Name())
+	if err != nil {
+		return "", err
+	}
+	return highestMatchingVersion(index, source.Name, versionRange)
+}
 
-	g, err := getterConstructor(chartURL, repoEntry.CertFile, repoEntry.KeyFile, repoEntry.CAFile)
-	if t, ok := g.(*getter.HttpGetter); ok {
-		t.SetCredentials(repoEntry.Username, repoEntry.Password)
+var rangeSeparator = regexp.MustCompile(`([0-9A-Za-z*])\s+([<>=!~^])`)
+
+// normalizeVersionRange separates the constraints of a semver range
+// that are only separated by whitespace (e.g. `>=1.2.0 <2.0.0`) with
+// commas, as that is what the semver library expects.
+func normalizeVersionRange(versionRange string) string {
+	return rangeSeparator.ReplaceAllString(versionRange, "$1, $2")
+}
+
+// highestMatchingVersion returns the highest version of the named
+// chart in the index that satisfies the semver range.
+func highestMatchingVersion(index *repo.IndexFile, name, versionRange string) (string, error) {
+	cv, err := index.Get(name, versionRange)
+	if err != nil {
+		return "", fmt.Errorf("no version of chart %q matches %q", name, versionRange)
+	}
+	return cv.Version, nil
+}
+
+// providers returns the given getter providers with the HTTP(S)
+// getter replaced by one that is configured with the download
+// options, and authenticates with the given credentials, and with
+// getters for the buckets of cloud storage services added.
+func (o downloadOptions) providers(providers getter.Providers, username, password string) getter.Providers {
+	httpProvider := getter.Provider{
+		Schemes: []string{"http", "https"},
+		New: func(URL, certFile, keyFile, caFile string) (getter.Getter, error) {
+			g, err := o.newHTTPGetter(URL, certFile, keyFile, caFile, username, password)
+			if err != nil {
+				return nil, err
+			}
+			return g, nil
+		},
+	}
+	s3Provider := getter.Provider{
+		Schemes: []string{"s3"},
+		New: func(URL, certFile, keyFile, caFile string) (getter.Getter, error) {
+			g, err := o.newHTTPGetter(URL, certFile, keyFile, caFile, "", "")
+			if err != nil {
+				return nil, err
+			}
+			return newS3Getter(g.client, o.Bucket, o.AWSOperatorCredentials)
+		},
+	}
+	gcsProvider := getter.Provider{
+		Schemes: []string{"gs"},
+		New: func(URL, certFile, keyFile, caFile string) (getter.Getter, error) {
+			g, err := o.newHTTPGetter(URL, certFile, keyFile, caFile, "", "")
+			if err != nil {
+				return nil, err
+			}
+			return newGCSGetter(g.client, o.Bucket, o.BucketSecret, o.GCPOperatorCredentials)
+		},
+	}
+	azblobProvider := getter.Provider{
+		Schemes: []string{"azblob"},
+		New: func(URL, certFile, keyFile, caFile string) (getter.Getter, error) {
+			g, err := o.newHTTPGetter(URL, certFile, keyFile, caFile, "", "")
+			if err != nil {
+				return nil, err
+			}
+			return newAzblobGetter(g.client, o.Bucket, o.AzureManagedIdentity)
+		},
+	}
+	result := getter.Providers{httpProvider, s3Provider, gcsProvider, azblobProvider}
+	for _, p := range providers {
+		if !p.Provides("http") && !p.Provides("https") && !p.Provides("s3") && !p.Provides("gs") && !p.Provides("azblob") {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// newHTTPGetter constructs a HTTP(S) getter the way Helm does, but
+// with the proxy and CAs from the download options.
+func (o downloadOptions) newHTTPGetter(URL, certFile, keyFile, caFile, username, password string) (*httpGetter, error) {
+	tr := &http.Transport{
+		DisableCompression: true,
+		Proxy:              http.ProxyFromEnvironment,
 	}
+	if o.Proxy != "" {
+		proxyURL, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %s", o.Proxy, err)
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConf := &tls.Config{}
+	if (certFile != "" && keyFile != "") || caFile != "" {
+		var err error
+		if tlsConf, err = tlsutil.NewTLSConfig(URL, certFile, keyFile, caFile); err != nil {
+			return nil, fmt.Errorf("can't create TLS config: %s", err.Error())
+		}
+	}
+	if o.CAFile != "" || len(o.CA) > 0 {
+		if tlsConf.RootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			tlsConf.RootCAs = pool
+		}
+		if o.CAFile != "" {
+			pem, err := ioutil.ReadFile(o.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("can't read CA file: %s", err)
+			}
+			if !tlsConf.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+			}
+		}
+		if len(o.CA) > 0 && !tlsConf.RootCAs.AppendCertsFromPEM(o.CA) {
+			return nil, errors.New("no certificates found in CA certificate of chart source")
+		}
+	}
+	if o.ClientCert != nil {
+		// NB: takes precedence over the certificate from the
+		// repositories file, as it is specific to the chart source
+		tlsConf.Certificates = []tls.Certificate{*o.ClientCert}
+	}
+	tr.TLSClientConfig = tlsConf
+
+	return &httpGetter{
+		client:   &http.Client{Transport: tr},
+		username: username,
+		password: password,
+		token:    o.Token,
+	}, nil
+}
+
+// httpGetter is the equivalent of Helm's getter.HttpGetter with a
+// transport we are able to configure.
+type httpGetter struct {
+	client   *http.Client
+	username string
+	password string
+	token    *repoToken
+}
 
-	chartBytes, err := g.Get(u.String())
+// Get performs a Get and returns the body.
+func (g *httpGetter) Get(href string) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	return buf, g.download(href, buf, 0)
+}
+
+// download performs a Get and writes the body to the given writer,
+// aborting once it exceeds the maximum size if that is above zero. A
+// bearer token refused by the chart repo is refetched once, as it may
+// have been revoked or expired early.
+func (g *httpGetter) download(href string, w io.Writer, maxSize int64) error {
+	status, err := g.get(href, w, maxSize)
+	if status == http.StatusUnauthorized && g.token != nil {
+		g.token.invalidate()
+		_, err = g.get(href, w, maxSize)
+	}
+	return err
+}
+
+func (g *httpGetter) get(href string, w io.Writer, maxSize int64) (int, error) {
+	req, err := http.NewRequest("GET", href, nil)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Helm/"+strings.TrimPrefix(version.GetVersion(), "v"))
+	if g.token != nil {
+		token, err := g.token.Token(g.client)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if g.username != "" && g.password != "" {
+		req.SetBasicAuth(g.username, g.password)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return 0, err
 	}
-	if err := ioutil.WriteFile(destFile, chartBytes.Bytes(), 0644); err != nil {
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return resp.StatusCode, fmt.Errorf("Failed to fetch %s : %s", href, resp.Status)
+	}
+	return resp.StatusCode, copyBody(w, resp, href, maxSize)
+}
+
+// copyBody copies the body of the given response for the given URL to
+// the writer, aborting once it exceeds the maximum size if that is
+// above zero.
+func copyBody(w io.Writer, resp *http.Response, href string, maxSize int64) error {
+	if maxSize <= 0 {
+		_, err := io.Copy(w, resp.Body)
 		return err
 	}
+	// Refuse early if the repo tells us the size, and count as we go
+	// otherwise, as it may not tell the truth.
+	if resp.ContentLength > maxSize {
+		return downloadTooLargeError{href, maxSize}
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxSize+1))
+	if err == nil && n > maxSize {
+		err = downloadTooLargeError{href, maxSize}
+	}
+	return err
+}
 
-	return nil
+// isTLSError returns if the given (download) error is the result of
+// a failure to establish a TLS connection. The errors returned by
+// Helm are not wrapped, so we have to resort to the message.
+func isTLSError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "x509: ") || strings.Contains(msg, "tls: ")
+}
+
+// downloadFailure returns the condition reason and message for the
+// given chart download error.
+func downloadFailure(err error) (string, string) {
+	if _, ok := err.(verificationError); ok {
+		return ReasonVerificationFailed, err.Error()
+	}
+	if isTLSError(err) {
+		return ReasonDownloadTLSFailed, "chart download failed due to a TLS error, the chart repository may use a certificate " +
+			"signed by an unknown authority; configure its CA with `caSecretRef` on the chart source " +
+			"or the --chart-repo-ca-file flag: " + err.Error()
+	}
+	return ReasonDownloadFailed, "chart download failed: " + err.Error()
 }
 
 func urlsMatch(entryURL, sourceURL string) bool {
 	return strings.TrimRight(entryURL, "/") == strings.TrimRight(sourceURL, "/")
 }
+
+// downloadOptions returns the options to download the chart of the
+// given chart source of the HelmRelease with, loading the CA and
+// client certificate from the secrets the chart source refers to.
+func (chs *ChartChangeSync) downloadOptions(hr helmfluxv1.HelmRelease, source *helmfluxv1.RepoChartSource) (downloadOptions, error) {
+	opts := downloadOptions{Proxy: chs.config.ChartRepoProxy, CAFile: chs.config.ChartRepoCAFile, MaxSize: chs.config.ChartMaxSize}
+
+	if ref := source.CASecretRef; ref != nil {
+		secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return opts, fmt.Errorf("unable to get secret '%s' with CA certificate for chart repository: %s", ref.Name, err)
+		}
+		ca, ok := secret.Data[ref.Key]
+		if !ok {
+			return opts, fmt.Errorf("key '%s' not found in secret '%s' with CA certificate for chart repository", ref.Key, ref.Name)
+		}
+		opts.CA = append(opts.CA, ca...)
+	}
+
+	if ref := source.CertSecretRef; ref != nil {
+		secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return opts, fmt.Errorf("unable to get secret '%s' with client certificate for chart repository: %s", ref.Name, err)
+		}
+		certPEM, keyPEM := secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+		if len(certPEM) == 0 || len(keyPEM) == 0 {
+			return opts, fmt.Errorf("secret '%s' with client certificate for chart repository must have both '%s' and '%s'",
+				ref.Name, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return opts, fmt.Errorf("invalid client certificate in secret '%s': %s", ref.Name, err)
+		}
+		opts.ClientCert = &cert
+		if ca, ok := secret.Data[corev1.ServiceAccountRootCAKey]; ok {
+			if len(opts.CA) > 0 {
+				opts.CA = append(opts.CA, '\n')
+			}
+			opts.CA = append(opts.CA, ca...)
+		}
+	}
+
+	if auth := source.TokenAuth; auth != nil {
+		ref := auth.ClientSecretRef
+		secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return opts, fmt.Errorf("unable to get secret '%s' with client credentials for chart repository: %s", ref.Name, err)
+		}
+		clientID, clientSecret := secret.Data[tokenClientIDKey], secret.Data[tokenClientSecretKey]
+		if len(clientID) == 0 || len(clientSecret) == 0 {
+			return opts, fmt.Errorf("secret '%s' with client credentials for chart repository must have both '%s' and '%s'",
+				ref.Name, tokenClientIDKey, tokenClientSecretKey)
+		}
+		opts.Token = chs.repoTokens.get(hr.Namespace+"/"+ref.Name, clientcredentials.Config{
+			ClientID:     string(clientID),
+			ClientSecret: string(clientSecret),
+			TokenURL:     auth.TokenURL,
+			Scopes:       auth.Scopes,
+		})
+	}
+
+	if ref := source.RegistrySecretRef; ref != nil && source.IsOCI() {
+		secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return opts, fmt.Errorf("unable to get secret '%s' with credentials for OCI registry: %s", ref.Name, err)
+		}
+		repository, err := ociRepositoryOf(source)
+		if err != nil {
+			return opts, err
+		}
+		if opts.Username, opts.Password, err = registryCredentials(secret.Data, repository.registry); err != nil {
+			return opts, fmt.Errorf("secret '%s' with credentials for OCI registry: %s", ref.Name, err)
+		}
+	}
+
+	if ref := source.BucketSecretRef; ref != nil {
+		secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return opts, fmt.Errorf("unable to get secret '%s' with credentials for bucket: %s", ref.Name, err)
+		}
+		opts.Bucket, opts.BucketSecret = secret.Data, hr.Namespace+"/"+ref.Name
+		opts.AzureManagedIdentity = chs.config.AzureManagedIdentity
+	}
+	opts.AWSOperatorCredentials = chs.config.AWSOperatorCredentials
+	opts.GCPOperatorCredentials = chs.config.GCPOperatorCredentials
+
+	// Charts repos that do not authenticate with a token may have
+	// credentials in the credentials file of the operator.
+	if source.TokenAuth == nil && opts.Username == "" && chs.config.ChartRepoCredentialsFile != "" {
+		creds, ok, err := netrcCredentialsFor(chs.config.ChartRepoCredentialsFile, source.CleanRepoURL(), chs.config.ChartRepoCredentialsDefault)
+		if err != nil {
+			return opts, fmt.Errorf("unable to read credentials for chart repository: %s", err)
+		}
+		if ok {
+			opts.Username, opts.Password = creds.login, creds.password
+		}
+	}
+
+	if verify := hr.Spec.Verify; verify != nil {
+		ref := verify.KeyringSecretRef
+		secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return opts, fmt.Errorf("unable to get secret '%s' with keyring to verify chart: %s", ref.Name, err)
+		}
+		keyring, ok := secret.Data[ref.Key]
+		if !ok {
+			return opts, fmt.Errorf("key '%s' not found in secret '%s' with keyring to verify chart", ref.Key, ref.Name)
+		}
+		opts.Keyring = keyring
+	}
+
+	return opts, nil
+}
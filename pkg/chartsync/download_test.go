@@ -0,0 +1,152 @@
+package chartsync
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/repo"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func Test_downloadOptions_newHTTPGetter(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chart"))
+	}))
+	defer srv.Close()
+
+	g, err := downloadOptions{}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	assert.NoError(t, err)
+	_, err = g.Get(srv.URL)
+	assert.Error(t, err)
+	assert.True(t, isTLSError(err))
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	g, err = downloadOptions{CA: ca}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	assert.NoError(t, err)
+	buf, err := g.Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "chart", buf.String())
+
+	_, err = downloadOptions{CA: []byte("not a certificate")}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	assert.Error(t, err)
+}
+
+func Test_downloadOptions_newHTTPGetter_clientCert(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("chart"))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	g, err := downloadOptions{CA: ca}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	assert.NoError(t, err)
+	_, err = g.Get(srv.URL)
+	assert.Error(t, err)
+
+	// Any certificate will do, as the server does not verify it
+	cert := srv.TLS.Certificates[0]
+	g, err = downloadOptions{CA: ca, ClientCert: &cert}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	assert.NoError(t, err)
+	buf, err := g.Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "chart", buf.String())
+}
+
+func Test_highestMatchingVersion(t *testing.T) {
+	index := repo.NewIndexFile()
+	for _, v := range []string{"1.1.0", "1.2.0", "1.3.1", "2.0.0"} {
+		index.Add(&chart.Metadata{Name: "podinfo", Version: v}, "podinfo-"+v+".tgz", "https://example.com/", "")
+	}
+	index.SortEntries()
+
+	assert.Equal(t, ">=1.2.0, <2.0.0", normalizeVersionRange(">=1.2.0 <2.0.0"))
+	assert.Equal(t, ">= 1.2.0, < 2.0.0 || 3.x", normalizeVersionRange(">= 1.2.0 < 2.0.0 || 3.x"))
+
+	v, err := highestMatchingVersion(index, "podinfo", normalizeVersionRange(">=1.2.0 <2.0.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.1", v)
+
+	v, err = highestMatchingVersion(index, "podinfo", "~1.2")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", v)
+
+	_, err = highestMatchingVersion(index, "podinfo", ">=3.0.0")
+	assert.Error(t, err)
+}
+
+func Test_downloadTo_maxSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// Flushing before writing leaves the length unknown
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte("chart of ten"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "podinfo-1.0.0.tgz")
+
+	g, err := downloadOptions{}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, href := range []string{srv.URL + "/sized", srv.URL + "/chunked"} {
+		err = downloadTo(g, href, path, 8)
+		assert.Equal(t, downloadTooLargeError{href, 8}, err, href)
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), href)
+	}
+
+	assert.NoError(t, downloadTo(g, srv.URL+"/chunked", path, 12))
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "chart of ten", string(b))
+
+	// Nothing is left behind of the aborted downloads
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func Test_chartCacheDir(t *testing.T) {
+	base, err := ioutil.TempDir("", "chart-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	dir, err := chartCacheDir(base, "https://charts.example.com/")
+	assert.NoError(t, err)
+	stat, err := os.Stat(dir)
+	if assert.NoError(t, err) {
+		assert.True(t, stat.IsDir())
+	}
+
+	// A directory that can not be created is an error, not a panic
+	notADir := filepath.Join(base, "file")
+	assert.NoError(t, ioutil.WriteFile(notADir, nil, 0644))
+	_, err = chartCacheDir(notADir, "https://charts.example.com/")
+	assert.Error(t, err)
+	_, err = makeChartPath(notADir, &helmfluxv1.RepoChartSource{RepoURL: "https://charts.example.com/", Name: "podinfo", Version: "3.1.0"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package chartsync
+
+import (
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// driftBackoff is the backoff of the drift correction of a release
+// of which the resources keep drifting, e.g. because another
+// controller changes them too.
+type driftBackoff struct {
+	// corrections is the number of consecutive drift corrections
+	// that corrected (or failed to correct) something.
+	corrections int
+	// until is the time before which drift is not corrected again.
+	until time.Time
+}
+
+// correctDrift applies the resources of the given release of the
+// HelmRelease that drifted from its manifests again, and records so
+// in the DriftCorrected condition. Repeated corrections are backed
+// off like failing releases, and only resumed once the delay passed.
+func (chs *ChartChangeSync) correctDrift(hr helmfluxv1.HelmRelease, rel *hapi_release.Release) {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return
+	}
+	if wait := chs.driftBackingOff(key); wait > 0 {
+		chs.debugLogger(hr).Log("debug", "backing off drift correction", "retry-in", wait.Round(time.Second))
+		return
+	}
+
+	chs.helmOps.acquire()
+	corrected, err := chs.release.CorrectDrift(rel, hr.Spec.DriftDetectionInclude, hr.Spec.DriftDetectionExclude)
+	chs.helmOps.done()
+	chs.recordDriftCorrection(key, err != nil || len(corrected) > 0)
+	if err != nil {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseDriftCorrected, v1.ConditionFalse, ReasonDriftCorrectionFailed, err.Error())
+		chs.logger.Log("warning", "failed to correct drift", "resource", hr.ResourceID().String(), "err", err)
+		return
+	}
+	if len(corrected) > 0 {
+		msg := "corrected drift of " + strings.Join(corrected, ", ")
+		chs.setCondition(hr, helmfluxv1.HelmReleaseDriftCorrected, v1.ConditionTrue, ReasonDriftCorrected, msg)
+		chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+		return
+	}
+	// Nothing was corrected, so there is nothing to record; a failure
+	// to correct drift before no longer holds.
+	if c := status.GetCondition(hr.Status, helmfluxv1.HelmReleaseDriftCorrected); c != nil && c.Status != v1.ConditionTrue {
+		if err := status.RemoveCondition(chs.statusClient(hr), hr, helmfluxv1.HelmReleaseDriftCorrected); err != nil {
+			chs.logger.Log("warning", "could not remove the DriftCorrected condition", "resource", hr.ResourceID().String(), "err", err)
+		}
+	}
+}
+
+// driftBackingOff returns the time left before the drift of the
+// release of the HelmRelease with the given key is corrected again,
+// or zero if it can be corrected now.
+func (chs *ChartChangeSync) driftBackingOff(key string) time.Duration {
+	chs.driftBackoffsMu.Lock()
+	defer chs.driftBackoffsMu.Unlock()
+	if b, ok := chs.driftBackoffs[key]; ok && time.Now().Before(b.until) {
+		return time.Until(b.until)
+	}
+	return 0
+}
+
+// recordDriftCorrection records if the drift correction of the
+// release of the HelmRelease with the given key corrected something,
+// which backs off the next one, or found nothing to correct, which
+// resets the backoff.
+func (chs *ChartChangeSync) recordDriftCorrection(key string, corrected bool) {
+	chs.driftBackoffsMu.Lock()
+	defer chs.driftBackoffsMu.Unlock()
+	if !corrected || chs.config.FailureBackoff <= 0 {
+		delete(chs.driftBackoffs, key)
+		return
+	}
+	if chs.driftBackoffs == nil {
+		chs.driftBackoffs = make(map[string]*driftBackoff)
+	}
+	b, ok := chs.driftBackoffs[key]
+	if !ok {
+		b = &driftBackoff{}
+		chs.driftBackoffs[key] = b
+	}
+	b.corrections++
+	// The first correction is not held back, as drift happens; it is
+	// the consecutive ones that signal a fight over the resources.
+	if b.corrections > 1 {
+		b.until = time.Now().Add(backoffDelay(b.corrections-1, chs.config.FailureBackoff, chs.config.FailureBackoffMax))
+	}
+}
@@ -0,0 +1,41 @@
+package chartsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftBackoff(t *testing.T) {
+	chs := &ChartChangeSync{config: Config{FailureBackoff: time.Minute, FailureBackoffMax: 3 * time.Minute}}
+	key := "default/podinfo"
+
+	// The first correction is not held back
+	chs.recordDriftCorrection(key, true)
+	assert.Zero(t, chs.driftBackingOff(key))
+
+	// Consecutive ones are, increasingly
+	chs.recordDriftCorrection(key, true)
+	wait := chs.driftBackingOff(key)
+	assert.True(t, wait > 50*time.Second && wait <= time.Minute, wait)
+	chs.recordDriftCorrection(key, true)
+	wait = chs.driftBackingOff(key)
+	assert.True(t, wait > time.Minute && wait <= 2*time.Minute, wait)
+	chs.recordDriftCorrection(key, true)
+	chs.recordDriftCorrection(key, true)
+	wait = chs.driftBackingOff(key)
+	assert.True(t, wait > 2*time.Minute && wait <= 3*time.Minute, wait)
+
+	// Finding nothing to correct resets the backoff
+	chs.recordDriftCorrection(key, false)
+	assert.Zero(t, chs.driftBackingOff(key))
+	chs.recordDriftCorrection(key, true)
+	assert.Zero(t, chs.driftBackingOff(key))
+
+	// Without a failure backoff, corrections are not backed off
+	chs.config.FailureBackoff = 0
+	chs.recordDriftCorrection(key, true)
+	chs.recordDriftCorrection(key, true)
+	assert.Zero(t, chs.driftBackingOff(key))
+}
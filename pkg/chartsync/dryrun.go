@@ -0,0 +1,64 @@
+package chartsync
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// The ways the dry run to determine if a release should be upgraded
+// can be done, as recorded in the status of the HelmRelease.
+const (
+	// DryRunModeServer is a dry run by Tiller in the target
+	// namespace of the release.
+	DryRunModeServer = "Server"
+	// DryRunModeNamespace is a dry run by Tiller in the dry run
+	// namespace of the operator.
+	DryRunModeNamespace = "Namespace"
+	// DryRunModeClientSide compares the values and the chart of the
+	// release only, without a dry run by Tiller.
+	DryRunModeClientSide = "ClientSide"
+)
+
+// dryRun does the dry run install of the given HelmRelease under the
+// given release name, and returns the release it results in and how
+// it was done. When the dry run is denied in the target namespace, it
+// is done in the dry run namespace if there is one, and when denied
+// there too, on the client side if that is allowed.
+func (chs *ChartChangeSync) dryRun(ctx context.Context, chartPath, releaseName string, hr helmfluxv1.HelmRelease,
+	opts release.InstallOptions) (*hapi_release.Release, string, error) {
+
+	rel, _, err := chs.release.InstallContext(ctx, chartPath, releaseName, hr, release.InstallAction, opts, &chs.kubeClient)
+	if !dryRunDenied(err) {
+		return rel, DryRunModeServer, err
+	}
+
+	if ns := chs.config.DryRunNamespace; ns != "" && ns != hr.GetTargetNamespace() {
+		chs.logger.Log("info", "dry run denied in target namespace, trying the dry run namespace", "resource", hr.ResourceID().String(), "namespace", ns, "err", err)
+		nsOpts := opts
+		nsOpts.Namespace = ns
+		rel, _, err = chs.release.InstallContext(ctx, chartPath, releaseName, hr, release.InstallAction, nsOpts, &chs.kubeClient)
+		if !dryRunDenied(err) {
+			return rel, DryRunModeNamespace, err
+		}
+	}
+
+	if !chs.config.DryRunClientSideFallback {
+		return nil, "", err
+	}
+	chs.logger.Log("info", "dry run denied, comparing the values and chart only", "resource", hr.ResourceID().String(), "err", err)
+	opts.ClientSide = true
+	rel, _, err = chs.release.InstallContext(ctx, chartPath, releaseName, hr, release.InstallAction, opts, &chs.kubeClient)
+	return rel, DryRunModeClientSide, err
+}
+
+// dryRunDenied returns if the given error of a dry run is the result
+// of Tiller lacking the permissions for it. Failures before the
+// release is handed to Tiller are never, as they are wrapped.
+func dryRunDenied(err error) bool {
+	return apierrors.IsForbidden(err)
+}
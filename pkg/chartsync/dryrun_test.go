@@ -0,0 +1,96 @@
+package chartsync
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8shelm "k8s.io/helm/pkg/helm"
+	rls "k8s.io/helm/pkg/proto/hapi/services"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// denyingHelmClient is a k8shelm.FakeClient that is denied to install
+// into the given namespaces.
+type denyingHelmClient struct {
+	*k8shelm.FakeClient
+	denied map[string]bool
+}
+
+func (c *denyingHelmClient) InstallRelease(chStr, ns string, opts ...k8shelm.InstallOption) (*rls.InstallReleaseResponse, error) {
+	if c.denied[ns] {
+		return nil, fmt.Errorf(`rpc error: code = Unknown desc = secrets is forbidden: User "system:serviceaccount:kube-system:tiller" cannot create resource "secrets" in the namespace "%s"`, ns)
+	}
+	return c.FakeClient.InstallRelease(chStr, ns, opts...)
+}
+
+func TestDryRun_fallbacks(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(chartPath)
+	for name, content := range map[string]string{
+		"Chart.yaml":  "name: podinfo\nversion: 1.0.0\n",
+		"values.yaml": "replicaCount: 1\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(chartPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	helmClient := &denyingHelmClient{FakeClient: &k8shelm.FakeClient{}, denied: map[string]bool{"team": true}}
+	chs := &ChartChangeSync{
+		logger:  log.NewNopLogger(),
+		release: release.New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault),
+	}
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "team"},
+		Spec: helmfluxv1.HelmReleaseSpec{HelmValues: helmfluxv1.HelmValues{
+			Values: map[string]interface{}{"replicaCount": 2},
+		}},
+	}
+	opts := release.InstallOptions{DryRun: true}
+
+	// Without fallbacks the denial is returned
+	_, _, err = chs.dryRun(context.Background(), chartPath, "dry-run", hr, opts)
+	assert.True(t, dryRunDenied(err))
+
+	// The dry run is done in the dry run namespace instead
+	chs.config.DryRunNamespace = "sandbox"
+	rel, mode, err := chs.dryRun(context.Background(), chartPath, "dry-run", hr, opts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, DryRunModeNamespace, mode)
+		assert.Equal(t, "sandbox", rel.GetNamespace())
+	}
+
+	// When denied there too, the values and chart are compared
+	helmClient.denied["sandbox"] = true
+	_, _, err = chs.dryRun(context.Background(), chartPath, "dry-run", hr, opts)
+	assert.True(t, dryRunDenied(err))
+	chs.config.DryRunClientSideFallback = true
+	rel, mode, err = chs.dryRun(context.Background(), chartPath, "dry-run", hr, opts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, DryRunModeClientSide, mode)
+		assert.Equal(t, "podinfo", rel.GetChart().GetMetadata().GetName())
+		assert.Equal(t, "replicaCount: 2\n", rel.GetConfig().GetRaw())
+		assert.Empty(t, rel.GetManifest())
+	}
+
+	// A dry run that is not denied is done by Tiller
+	rel, mode, err = chs.dryRun(context.Background(), chartPath, "dry-run", helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+	}, opts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, DryRunModeServer, mode)
+		assert.Equal(t, "default", rel.GetNamespace())
+	}
+}
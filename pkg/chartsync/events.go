@@ -0,0 +1,24 @@
+package chartsync
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	ifscheme "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/scheme"
+)
+
+// eventComponent is the component events are recorded as coming from.
+const eventComponent = "helm-operator"
+
+// newEventRecorder returns a recorder for recording events for
+// HelmReleases to the Kubernetes API.
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	// HelmReleases are only known to the scheme once added to it
+	ifscheme.AddToScheme(scheme.Scheme)
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent})
+}
@@ -0,0 +1,105 @@
+package chartsync
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// ReleaseFinalizer is the finalizer set on HelmReleases so that
+// their Helm release is deleted before they are removed, even when
+// the operator is not running at the time of deletion.
+const ReleaseFinalizer = "helm.fluxcd.io/finalizer"
+
+// ensureFinalizer adds the release finalizer to the given HelmRelease
+// if it does not have it yet.
+func (chs *ChartChangeSync) ensureFinalizer(hr helmfluxv1.HelmRelease) error {
+	if hasFinalizer(hr.ObjectMeta, ReleaseFinalizer) {
+		return nil
+	}
+	client := chs.ifClient.HelmV1().HelmReleases(hr.Namespace)
+	cHr, err := client.Get(hr.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if hasFinalizer(cHr.ObjectMeta, ReleaseFinalizer) {
+		return nil
+	}
+	cHr.Finalizers = append(cHr.Finalizers, ReleaseFinalizer)
+	_, err = client.Update(cHr)
+	return err
+}
+
+// finalizeRelease deletes the Helm release of the given HelmRelease
+// that is being deleted, and removes the release finalizer once
+// done so that the HelmRelease can be removed. A release that no
+// longer exists is considered deleted. When the deletion fails the
+// finalizer is kept, the failure is recorded in the `Deleted`
+// condition, and the deletion is retried after a delay.
+func (chs *ChartChangeSync) finalizeRelease(hr helmfluxv1.HelmRelease) {
+	if !hasFinalizer(hr.ObjectMeta, ReleaseFinalizer) {
+		return
+	}
+
+	name := chs.release.ReleaseName(hr)
+	rel, err := chs.release.GetRelease(name)
+	if err == nil && rel != nil {
+		if chs.release.OwnedByHelmRelease(rel, hr) {
+			if !chs.dryRunOnly(hr, helmfluxv1.HelmReleaseDeleted, fmt.Sprintf("delete release '%s'", name)) {
+				err = chs.deleteRelease(name, hr)
+			}
+		} else {
+			chs.logger.Log("warning", "release not deleted as it is not managed by the HelmRelease", "resource", hr.ResourceID().String(), "release", name)
+		}
+	}
+	if err != nil {
+		msg := fmt.Sprintf("failed to delete release '%s': %s", name, err)
+		chs.setCondition(hr, helmfluxv1.HelmReleaseDeleted, v1.ConditionFalse, ReasonDeleteFailed, msg)
+		chs.logger.Log("warning", "chart release not deleted, retrying later", "resource", hr.ResourceID().String(), "release", name, "err", err)
+		if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil {
+			chs.releaseQueue.AddAfter(cacheKey, deleteRetryDelay)
+		}
+		return
+	}
+
+	chs.removeClone(hr)
+	chs.forgetReconciled(hr)
+	if err := chs.removeFinalizer(hr); err != nil {
+		chs.logger.Log("warning", "unable to remove finalizer", "resource", hr.ResourceID().String(), "err", err)
+	}
+}
+
+// removeFinalizer removes the release finalizer from the given
+// HelmRelease.
+func (chs *ChartChangeSync) removeFinalizer(hr helmfluxv1.HelmRelease) error {
+	client := chs.ifClient.HelmV1().HelmReleases(hr.Namespace)
+	cHr, err := client.Get(hr.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	var finalizers []string
+	for _, f := range cHr.Finalizers {
+		if f != ReleaseFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) == len(cHr.Finalizers) {
+		return nil
+	}
+	cHr.Finalizers = finalizers
+	_, err = client.Update(cHr)
+	return err
+}
+
+func hasFinalizer(meta metav1.ObjectMeta, finalizer string) bool {
+	for _, f := range meta.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package chartsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// gcsServiceAccountKeyKey is the key of the (JSON) service account
+// key in a secret with the credentials for a GCS bucket.
+const gcsServiceAccountKeyKey = "serviceAccountKey"
+
+// gcsReadOnlyScope is the scope of the tokens to read from GCS with.
+const gcsReadOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+var (
+	// gcsEndpoint is the endpoint objects are downloaded from.
+	gcsEndpoint = "https://storage.googleapis.com"
+	// gceMetadataHost is the host of the metadata server of GCE,
+	// which hands out the tokens of workload identities; it is
+	// overridden by GCE_METADATA_HOST, as with the Google libraries.
+	gceMetadataHost = "metadata.google.internal"
+)
+
+// gcsTokens caches the token sources for GCS by the bucket secret
+// (or the operator, for its own credentials) they were created for,
+// with the checksum of the key they were created from, so that tokens
+// are reused until they expire and never outlive a change of key.
+var gcsTokens = struct {
+	mu      sync.Mutex
+	sources map[string]gcsTokenSource
+}{sources: make(map[string]gcsTokenSource)}
+
+// gcsTokenSource is a cached token source for GCS.
+type gcsTokenSource struct {
+	keySum string
+	tokens oauth2.TokenSource
+}
+
+// gcsOperatorCredentials is the key in gcsTokens of the token source
+// for the credentials of the operator; it can not clash with those of
+// secrets, which are namespaced.
+const gcsOperatorCredentials = "operator"
+
+// gcsGetter gets the objects of GCS buckets, as `gs://<bucket>/<object>`
+// URLs, with a token for the service account key of the bucket
+// secret or, if the operator allows it, of
+// GOOGLE_APPLICATION_CREDENTIALS or of the workload identity of the
+// operator, in that order. Outside of GCP, or without a key, requests
+// are anonymous, which suits public buckets.
+type gcsGetter struct {
+	client *http.Client
+	tokens oauth2.TokenSource
+}
+
+// newGCSGetter returns a gcsGetter with the given client, configured
+// with the data of the given bucket secret (as `<namespace>/<name>`),
+// if any. The credentials of the operator are only used if allowed.
+func newGCSGetter(client *http.Client, bucket map[string][]byte, secret string, allowOperatorCredentials bool) (*gcsGetter, error) {
+	key, cacheKey := bucket[gcsServiceAccountKeyKey], secret
+	if len(key) == 0 {
+		if !allowOperatorCredentials {
+			return &gcsGetter{client: client}, nil
+		}
+		cacheKey = gcsOperatorCredentials
+		if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+			var err error
+			if key, err = ioutil.ReadFile(path); err != nil {
+				return nil, fmt.Errorf("unable to read GOOGLE_APPLICATION_CREDENTIALS: %s", err)
+			}
+		}
+	}
+
+	var keySum string
+	if len(key) > 0 {
+		sum := sha256.Sum256(key)
+		keySum = hex.EncodeToString(sum[:])
+	}
+	gcsTokens.mu.Lock()
+	defer gcsTokens.mu.Unlock()
+	if cached, ok := gcsTokens.sources[cacheKey]; ok && cached.keySum == keySum {
+		return &gcsGetter{client: client, tokens: cached.tokens}, nil
+	}
+
+	var tokens oauth2.TokenSource
+	if len(key) > 0 {
+		var sa struct {
+			ClientEmail  string `json:"client_email"`
+			PrivateKey   string `json:"private_key"`
+			PrivateKeyID string `json:"private_key_id"`
+			TokenURI     string `json:"token_uri"`
+		}
+		if err := json.Unmarshal(key, &sa); err != nil || sa.ClientEmail == "" || sa.PrivateKey == "" {
+			return nil, fmt.Errorf("invalid service account key for GCS")
+		}
+		if sa.TokenURI == "" {
+			sa.TokenURI = "https://oauth2.googleapis.com/token"
+		}
+		config := &jwt.Config{
+			Email:        sa.ClientEmail,
+			PrivateKey:   []byte(sa.PrivateKey),
+			PrivateKeyID: sa.PrivateKeyID,
+			TokenURL:     sa.TokenURI,
+			Scopes:       []string{gcsReadOnlyScope},
+		}
+		tokens = config.TokenSource(context.WithValue(context.Background(), oauth2.HTTPClient, client))
+	} else {
+		tokens = oauth2.ReuseTokenSource(nil, metadataTokenSource{})
+	}
+	// Without a secret to key them by, token sources are not cached
+	if cacheKey != "" {
+		gcsTokens.sources[cacheKey] = gcsTokenSource{keySum: keySum, tokens: tokens}
+	}
+	return &gcsGetter{client: client, tokens: tokens}, nil
+}
+
+// Get gets the object at the given URL.
+func (g *gcsGetter) Get(href string) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	return buf, g.download(href, buf, 0)
+}
+
+// download gets the object at the given URL and writes it to the
+// given writer, aborting once it exceeds the maximum size if that is
+// above zero.
+func (g *gcsGetter) download(href string, w io.Writer, maxSize int64) error {
+	u, err := url.Parse(href)
+	if err != nil {
+		return err
+	}
+	object := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || object == "" {
+		return fmt.Errorf("invalid GCS URL %q, expected gs://<bucket>/<object>", href)
+	}
+	segments := strings.Split(object, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	req, err := http.NewRequest("GET", gcsEndpoint+"/"+u.Host+"/"+strings.Join(segments, "/"), nil)
+	if err != nil {
+		return err
+	}
+
+	if g.tokens != nil {
+		token, err := g.tokens.Token()
+		switch err.(type) {
+		case nil:
+			token.SetAuthHeader(req)
+		case metadataUnavailableError:
+			// Not on GCP, try anonymously
+		default:
+			return fmt.Errorf("unable to fetch token for GCS: %s", err)
+		}
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to fetch %s : %s", href, resp.Status)
+	}
+	return copyBody(w, resp, href, maxSize)
+}
+
+// metadataUnavailableError is returned by metadataTokenSource when
+// the metadata server can not be reached, i.e. outside of GCP.
+type metadataUnavailableError struct {
+	err error
+}
+
+func (e metadataUnavailableError) Error() string {
+	return "GCE metadata server unavailable: " + e.err.Error()
+}
+
+// metadataTokenSource fetches the tokens of the (workload identity)
+// service account of the operator from the GCE metadata server.
+type metadataTokenSource struct{}
+
+func (metadataTokenSource) Token() (*oauth2.Token, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = gceMetadataHost
+	}
+	req, err := http.NewRequest("GET", "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token?scopes="+url.QueryEscape(gcsReadOnlyScope), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	// The metadata server is never reached through a proxy
+	client := &http.Client{Transport: &http.Transport{}, Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, metadataUnavailableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GCE metadata server returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("unable to parse token of GCE metadata server: %s", err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
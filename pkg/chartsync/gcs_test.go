@@ -0,0 +1,164 @@
+package chartsync
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/helm/pkg/repo"
+)
+
+func Test_gcsGetter(t *testing.T) {
+	index := `apiVersion: v1
+entries:
+  podinfo:
+  - name: podinfo
+    version: 1.0.0
+    urls:
+    - gs://charts/stable/podinfo-1.0.0.tgz
+`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.Form.Get("assertion") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token": "t0k3n", "token_type": "Bearer", "expires_in": 3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/charts/stable/index.yaml":
+			w.Write([]byte(index))
+		case "/charts/stable/podinfo-1.0.0.tgz":
+			w.Write([]byte("chart"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defer func(endpoint string) { gcsEndpoint = endpoint }(gcsEndpoint)
+	gcsEndpoint = srv.URL
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	key, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "helm-operator@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})),
+		"token_uri":    srv.URL + "/token",
+	})
+	opts := downloadOptions{
+		CA:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+		Bucket: map[string][]byte{gcsServiceAccountKeyKey: key},
+	}
+	getters := opts.providers(nil, "", "")
+	chartURL, err := repo.FindChartInAuthRepoURL("gs://charts/stable/", "", "", "podinfo", "1.0.0", "", "", "", getters)
+	assert.NoError(t, err)
+	assert.Equal(t, "gs://charts/stable/podinfo-1.0.0.tgz", chartURL)
+
+	dir, err := ioutil.TempDir("", "gcs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	newGetter, err := getters.ByScheme("gs")
+	assert.NoError(t, err)
+	g, err := newGetter(chartURL, "", "", "")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "podinfo-1.0.0.tgz")
+	assert.NoError(t, downloadTo(g, chartURL, path, 0))
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "chart", string(b))
+
+	opts.Bucket = map[string][]byte{gcsServiceAccountKeyKey: []byte("{}")}
+	_, err = newGCSGetter(http.DefaultClient, opts.Bucket, "", false)
+	assert.Error(t, err)
+}
+
+func Test_gcsGetter_metadata(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" ||
+			r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "w0rkl04d", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	defer metadata.Close()
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer storage.Close()
+	defer func(endpoint string) { gcsEndpoint = endpoint }(gcsEndpoint)
+	gcsEndpoint = storage.URL
+	defer os.Unsetenv("GCE_METADATA_HOST")
+
+	get := func(metadataHost string, allow bool) string {
+		gcsTokens.mu.Lock()
+		delete(gcsTokens.sources, gcsOperatorCredentials)
+		gcsTokens.mu.Unlock()
+		os.Setenv("GCE_METADATA_HOST", metadataHost)
+		g, err := newGCSGetter(http.DefaultClient, nil, "", allow)
+		assert.NoError(t, err)
+		b, err := g.Get("gs://charts/stable/index.yaml")
+		assert.NoError(t, err)
+		return b.String()
+	}
+
+	// The token of the workload identity is used on GCP, if allowed...
+	metadataHost := strings.TrimPrefix(metadata.URL, "http://")
+	assert.Equal(t, "Bearer w0rkl04d", get(metadataHost, true))
+	assert.Equal(t, "", get(metadataHost, false))
+	// ...and requests are anonymous elsewhere
+	assert.Equal(t, "", get("127.0.0.1:1", true))
+}
+
+func Test_newGCSGetter_cachePerSecret(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	newKey := func(email string) []byte {
+		key, _ := json.Marshal(map[string]string{
+			"client_email": email,
+			"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})),
+		})
+		return key
+	}
+	defer func() {
+		gcsTokens.mu.Lock()
+		delete(gcsTokens.sources, "a/bucket")
+		delete(gcsTokens.sources, "b/bucket")
+		gcsTokens.mu.Unlock()
+	}()
+
+	key := map[string][]byte{gcsServiceAccountKeyKey: newKey("a@example.iam.gserviceaccount.com")}
+	a, err := newGCSGetter(http.DefaultClient, key, "a/bucket", false)
+	assert.NoError(t, err)
+	again, err := newGCSGetter(http.DefaultClient, key, "a/bucket", false)
+	assert.NoError(t, err)
+	assert.True(t, a.tokens == again.tokens)
+
+	// The same key in another secret gets its own token source...
+	b, err := newGCSGetter(http.DefaultClient, key, "b/bucket", false)
+	assert.NoError(t, err)
+	assert.False(t, a.tokens == b.tokens)
+
+	// ...as does a secret once its key changed
+	changed, err := newGCSGetter(http.DefaultClient, map[string][]byte{gcsServiceAccountKeyKey: newKey("c@example.iam.gserviceaccount.com")}, "a/bucket", false)
+	assert.NoError(t, err)
+	assert.False(t, a.tokens == changed.tokens)
+}
@@ -0,0 +1,284 @@
+package chartsync
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// The keys of the secret with the credentials of a GitHub App.
+const (
+	githubAppIDKey             = "appID"
+	githubAppInstallationIDKey = "installationID"
+	githubAppPrivateKeyKey     = "privateKey"
+	// githubAppAPIURLKey is the key of the (optional) URL of the
+	// API of a GitHub Enterprise Server, e.g.
+	// `https://github.example.com/api/v3`.
+	githubAppAPIURLKey = "apiURL"
+)
+
+// githubAPIURL is the URL of the GitHub API installation tokens are
+// requested from, unless the secret gives another.
+var githubAPIURL = "https://api.github.com"
+
+// githubAppTokenMinTTL is how long an installation token has to be
+// valid for still to be used. Installation tokens are valid for an
+// hour; renewing them once less than this is left makes sure the
+// token of a mirror outlives the fetches until the next time it is
+// renewed.
+const githubAppTokenMinTTL = 15 * time.Minute
+
+// githubAppUserPrefix is the prefix of the users in the URLs of the
+// mirrors of git chart sources with GitHub App credentials. The
+// credential helper of the image (`git-credential-helm-operator`)
+// only answers for users with this prefix; it authenticates to git as
+// `x-access-token`, with the installation token the operator wrote to
+// the file named after the user.
+const githubAppUserPrefix = "helm-operator-"
+
+// defaultGitCredentialsDir is the directory the installation tokens
+// of git chart sources are written to, which the gitconfig of the
+// image points the credential helper at.
+const defaultGitCredentialsDir = "/var/fluxd/git"
+
+// githubAppConfig are the credentials of a GitHub App installation.
+type githubAppConfig struct {
+	appID          string
+	installationID string
+	privateKey     []byte
+	apiURL         string
+}
+
+// githubAppConfigFrom returns the credentials in the given data of the
+// secret with the given name.
+func githubAppConfigFrom(name string, data map[string][]byte) (githubAppConfig, error) {
+	config := githubAppConfig{
+		appID:          strings.TrimSpace(string(data[githubAppIDKey])),
+		installationID: strings.TrimSpace(string(data[githubAppInstallationIDKey])),
+		privateKey:     data[githubAppPrivateKeyKey],
+		apiURL:         strings.TrimRight(strings.TrimSpace(string(data[githubAppAPIURLKey])), "/"),
+	}
+	if config.appID == "" || config.installationID == "" || len(config.privateKey) == 0 {
+		return config, fmt.Errorf("secret '%s' with GitHub App credentials must have '%s', '%s' and '%s'",
+			name, githubAppIDKey, githubAppInstallationIDKey, githubAppPrivateKeyKey)
+	}
+	if config.apiURL == "" {
+		config.apiURL = githubAPIURL
+	}
+	return config, nil
+}
+
+// githubAppTokens caches the installation tokens of GitHub Apps, so
+// that they are reused until they are about to expire rather than
+// requested for every use.
+type githubAppTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*githubAppToken
+}
+
+type githubAppToken struct {
+	config githubAppConfig
+	token  string
+	expiry time.Time
+}
+
+// get returns the installation token for the given key, requested
+// through the given client with the given credentials if there is
+// none, it is valid for less than githubAppTokenMinTTL at the given
+// time, or the credentials changed.
+func (g *githubAppTokens) get(key string, config githubAppConfig, client *http.Client, now time.Time) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.tokens == nil {
+		g.tokens = make(map[string]*githubAppToken)
+	}
+	if t, ok := g.tokens[key]; ok && reflect.DeepEqual(t.config, config) && now.Add(githubAppTokenMinTTL).Before(t.expiry) {
+		return t.token, nil
+	}
+	token, expiry, err := requestInstallationToken(client, config, now)
+	if err != nil {
+		delete(g.tokens, key)
+		return "", err
+	}
+	g.tokens[key] = &githubAppToken{config: config, token: token, expiry: expiry}
+	return token, nil
+}
+
+// fresh returns true if the installation token for the given key is
+// valid for at least githubAppTokenMinTTL at the given time, so that
+// it does not have to be renewed yet.
+func (g *githubAppTokens) fresh(key string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t, ok := g.tokens[key]
+	return ok && now.Add(githubAppTokenMinTTL).Before(t.expiry)
+}
+
+// requestInstallationToken requests an installation token from the
+// GitHub API with the given credentials, and returns it with the time
+// it expires.
+func requestInstallationToken(client *http.Client, config githubAppConfig, now time.Time) (string, time.Time, error) {
+	jwt, err := githubAppJWT(config, now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	endpoint := fmt.Sprintf("%s/app/installations/%s/access_tokens", config.apiURL, url.PathEscape(config.installationID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to request GitHub App installation token: %s", err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to request GitHub App installation token: %s", err)
+	}
+	if res.StatusCode != http.StatusCreated {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &e) != nil || e.Message == "" {
+			e.Message = http.StatusText(res.StatusCode)
+		}
+		return "", time.Time{}, fmt.Errorf("unable to request GitHub App installation token: %d %s", res.StatusCode, e.Message)
+	}
+	var t struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil || t.Token == "" {
+		return "", time.Time{}, errors.New("unable to request GitHub App installation token: no token in response")
+	}
+	return t.Token, t.ExpiresAt, nil
+}
+
+// githubAppJWT returns the JWT the GitHub App authenticates with to
+// request installation tokens, signed with its private key. It is
+// issued a minute in the past to allow for clock drift, and expires
+// within the ten minutes GitHub accepts.
+func githubAppJWT(config githubAppConfig, now time.Time) (string, error) {
+	key, err := parseRSAPrivateKey(config.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid GitHub App private key: %s", err)
+	}
+	claims, err := json.Marshal(struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}{now.Add(-time.Minute).Unix(), now.Add(9 * time.Minute).Unix(), config.appID})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded PKCS#1 (as GitHub hands
+// out) or PKCS#8 RSA private key.
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// githubAppCredentialUser returns the user in the URL of the mirror
+// of a git chart source with the GitHub App credentials in the given
+// secret, which the credential helper of the image looks up the
+// installation token for.
+func githubAppCredentialUser(namespace, secretName string) string {
+	sum := sha256.Sum256([]byte(namespace + "/" + secretName))
+	return githubAppUserPrefix + hex.EncodeToString(sum[:])[:16]
+}
+
+// githubAppRemoteURL returns the given HTTPS git URL with the given
+// user, and no password.
+func githubAppRemoteURL(gitURL, user string) (string, error) {
+	u, err := url.Parse(gitURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("GitHub App credentials can only be used with a git repo cloned over HTTPS")
+	}
+	u.User = url.User(user)
+	return u.String(), nil
+}
+
+// writeGitCredential writes the given installation token to the file
+// named after the given user in dir, for the credential helper to
+// hand to git.
+func writeGitCredential(dir, user, token string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return writeFileIfChanged(filepath.Join(dir, user), []byte(token), 0600)
+}
+
+// pruneGitCredentials removes the installation tokens from dir that
+// none of the given HelmReleases uses (anymore).
+func pruneGitCredentials(dir string, hrs []*helmfluxv1.HelmRelease) error {
+	inUse := make(map[string]bool)
+	for _, hr := range hrs {
+		source := hr.Spec.GitChartSource
+		if source == nil || source.GitHubAppSecretRef == nil || source.SSHSecretRef != nil || hr.DeletionTimestamp != nil {
+			continue
+		}
+		inUse[githubAppCredentialUser(hr.Namespace, source.GitHubAppSecretRef.Name)] = true
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), githubAppUserPrefix) || inUse[e.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
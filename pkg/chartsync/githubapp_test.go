@@ -0,0 +1,170 @@
+package chartsync
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func Test_githubAppTokens(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		// The JWT is signed with the private key of the app
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if !assert.Len(t, parts, 3) {
+			return
+		}
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"A JSON web token could not be decoded"}`))
+			return
+		}
+		var claims struct {
+			Iat int64  `json:"iat"`
+			Exp int64  `json:"exp"`
+			Iss string `json:"iss"`
+		}
+		raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		assert.NoError(t, json.Unmarshal(raw, &claims))
+		assert.Equal(t, "1234", claims.Iss)
+		assert.True(t, claims.Exp-claims.Iat <= int64((10*time.Minute).Seconds()))
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      "ghs_" + string(rune('a'+requests)),
+			"expires_at": now.Add(time.Hour).Format(time.RFC3339),
+		})
+	}))
+	defer srv.Close()
+
+	config, err := githubAppConfigFrom("github-app", map[string][]byte{
+		githubAppIDKey:             []byte("1234"),
+		githubAppInstallationIDKey: []byte("42\n"),
+		githubAppPrivateKeyKey:     pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}),
+		githubAppAPIURLKey:         []byte(srv.URL + "/"),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, srv.URL, config.apiURL)
+
+	var tokens githubAppTokens
+	token, err := tokens.get("flux/github-app", config, srv.Client(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_b", token)
+
+	// The token is reused until it is about to expire
+	token, err = tokens.get("flux/github-app", config, srv.Client(), now.Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_b", token)
+	assert.Equal(t, 1, requests)
+	assert.True(t, tokens.fresh("flux/github-app", now.Add(30*time.Minute)))
+	assert.False(t, tokens.fresh("flux/github-app", now.Add(50*time.Minute)))
+	assert.False(t, tokens.fresh("flux/other", now))
+	token, err = tokens.get("flux/github-app", config, srv.Client(), now.Add(50*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_c", token)
+
+	// Credentials that are refused do not yield a token
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	config.privateKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(otherKey)})
+	_, err = tokens.get("flux/github-app", config, srv.Client(), now.Add(50*time.Minute))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "401 A JSON web token could not be decoded")
+	}
+
+	_, err = githubAppConfigFrom("github-app", map[string][]byte{githubAppIDKey: []byte("1234")})
+	assert.Error(t, err)
+}
+
+func Test_githubAppRemoteURL(t *testing.T) {
+	user := githubAppCredentialUser("flux", "github-app")
+	assert.True(t, strings.HasPrefix(user, githubAppUserPrefix))
+	assert.NotEqual(t, user, githubAppCredentialUser("flux", "other-app"))
+
+	remoteURL, err := githubAppRemoteURL("https://github.com/fluxcd/charts.git", user)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://"+user+"@github.com/fluxcd/charts.git", remoteURL)
+
+	_, err = githubAppRemoteURL("git@github.com:fluxcd/charts.git", user)
+	assert.Error(t, err)
+
+	source := &helmfluxv1.GitChartSource{GitURL: "https://github.com/fluxcd/charts.git",
+		GitHubAppSecretRef: &corev1.LocalObjectReference{Name: "github-app"}}
+	assert.Equal(t, "https://github.com/fluxcd/charts.git#flux/github-app", mirrorName("flux", source))
+}
+
+func Test_gitCredentialHelper(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	helper, err := filepath.Abs("../../docker/git-credential-helm-operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "flux-helm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	user := githubAppCredentialUser("flux", "github-app")
+	assert.NoError(t, writeGitCredential(dir, user, "ghs_a"))
+
+	fill := func(input string) string {
+		cmd := exec.Command("git", "-c", "credential.helper=", "-c", "credential.helper="+helper+" "+dir, "credential", "fill")
+		cmd.Env = []string{"GIT_TERMINAL_PROMPT=0", "GIT_CONFIG_NOSYSTEM=1", "HOME=" + dir}
+		cmd.Stdin = strings.NewReader(input)
+		out, _ := cmd.Output()
+		return string(out)
+	}
+	assert.Contains(t, fill("protocol=https\nhost=github.com\nusername="+user+"\n\n"), "username=x-access-token\npassword=ghs_a\n")
+	// Other users are not answered for
+	assert.NotContains(t, fill("protocol=https\nhost=github.com\nusername=someone\n\n"), "ghs_a")
+	assert.NotContains(t, fill("protocol=https\nhost=github.com\nusername="+githubAppUserPrefix+"../x\n\n"), "password=")
+
+	// Tokens no HelmRelease uses are removed
+	other := githubAppCredentialUser("flux", "other-app")
+	assert.NoError(t, writeGitCredential(dir, other, "ghs_b"))
+	hr := &helmfluxv1.HelmRelease{}
+	hr.Namespace = "flux"
+	hr.Spec.GitChartSource = &helmfluxv1.GitChartSource{GitURL: "https://github.com/fluxcd/charts.git",
+		GitHubAppSecretRef: &corev1.LocalObjectReference{Name: "github-app"}}
+	assert.NoError(t, pruneGitCredentials(dir, []*helmfluxv1.HelmRelease{hr}))
+	_, err = os.Stat(filepath.Join(dir, user))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, other))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,100 @@
+package chartsync
+
+import (
+	"net/url"
+	"strings"
+)
+
+// QueueMirrorSync queues the sync of the mirrors of the given git
+// repositories, for when a push to them is announced (e.g. by a
+// webhook) rather than waiting for the next poll. It does not block:
+// the pushes are coalesced, and the mirrors of all repositories
+// pushed to since are synced by the sync loop once it gets to them.
+func (chs *ChartChangeSync) QueueMirrorSync(repoURLs []string) {
+	chs.pushedMu.Lock()
+	if chs.pushed == nil {
+		chs.pushed = make(map[string]bool)
+	}
+	for _, u := range repoURLs {
+		chs.pushed[u] = true
+	}
+	chs.pushedMu.Unlock()
+	select {
+	case chs.pushes <- struct{}{}:
+	default:
+		// a sync of the pushed repositories is pending already
+	}
+}
+
+// syncPushedMirrors syncs the mirrors of the repositories queued with
+// QueueMirrorSync.
+func (chs *ChartChangeSync) syncPushedMirrors() {
+	chs.pushedMu.Lock()
+	repoURLs := make([]string, 0, len(chs.pushed))
+	for u := range chs.pushed {
+		repoURLs = append(repoURLs, u)
+	}
+	chs.pushed = nil
+	chs.pushedMu.Unlock()
+	if len(repoURLs) > 0 {
+		chs.syncMirrorsOf(repoURLs)
+	}
+}
+
+// syncMirrorsOf instructs the mirrors of the given git repositories
+// to refresh from their upstream. As with SyncMirrors, the
+// HelmReleases using a mirror are reconciled when its refresh brings
+// in changes. Repositories are matched by their host and path, so
+// that e.g. the SSH and HTTPS URLs of a repository match the same
+// mirror.
+func (chs *ChartChangeSync) syncMirrorsOf(repoURLs []string) {
+	mirrors, err := chs.mirrorsToSync()
+	if err != nil {
+		chs.logger.Log("error", "unable to list HelmReleases", "err", err)
+		return
+	}
+	repos := make(map[string]bool)
+	for _, u := range repoURLs {
+		if key := repoKey(u); key != "" {
+			repos[key] = true
+		}
+	}
+	for name := range mirrors {
+		if !repos[repoKey(name)] {
+			delete(mirrors, name)
+		}
+	}
+	if len(mirrors) == 0 {
+		chs.logger.Log("info", "no git mirrors of pushed repository", "repos", strings.Join(repoURLs, ","))
+		return
+	}
+	for name := range mirrors {
+		chs.logger.Log("info", "syncing git mirror of pushed repository", "repo", name)
+	}
+	chs.syncMirrorSet(mirrors)
+}
+
+// repoKey returns the lower-cased host and path of the given git
+// repository URL, without any user, port or '.git' suffix, which
+// identify the repository regardless of the protocol it is cloned
+// with. It returns an empty string if the URL can not be parsed.
+func repoKey(repoURL string) string {
+	s := strings.TrimSpace(repoURL)
+	if !strings.Contains(s, "://") {
+		// scp-like syntax, e.g. git@github.com:org/repo.git
+		i := strings.Index(s, ":")
+		if i < 1 {
+			return ""
+		}
+		s = "ssh://" + s[:i] + "/" + s[i+1:]
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if path == "" {
+		return ""
+	}
+	return strings.ToLower(u.Hostname() + "/" + path)
+}
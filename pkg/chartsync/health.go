@@ -0,0 +1,72 @@
+package chartsync
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxMirrorSyncFailures is the number of consecutive failed mirror
+// syncs after which the ChartChangeSync is considered not ready.
+const maxMirrorSyncFailures = 3
+
+// Healthy returns an error if the ChartChangeSync is not making
+// progress; that is, when no HelmRelease has been reconciled within
+// the configured staleness window while there are HelmReleases to
+// reconcile. A replica that stands by for the leader is healthy.
+func (chs *ChartChangeSync) Healthy() error {
+	chs.healthMu.Lock()
+	lastReconcile, standby := chs.lastReconcile, chs.standby
+	chs.healthMu.Unlock()
+
+	if standby {
+		return nil
+	}
+
+	window := chs.config.HealthStalenessWindow
+	if window <= 0 || time.Since(lastReconcile) <= window {
+		return nil
+	}
+	hrs, err := chs.selectedReleases()
+	if err != nil {
+		return fmt.Errorf("unable to list HelmReleases: %s", err)
+	}
+	if len(hrs) > 0 {
+		return fmt.Errorf("no HelmRelease reconciled since %s", lastReconcile.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Ready returns an error if syncing the git mirrors has failed
+// repeatedly. Restarting the operator does not make an unreachable
+// upstream reachable, so unlike Healthy this does not get it killed.
+// A replica that stands by for the leader is ready.
+func (chs *ChartChangeSync) Ready() error {
+	chs.healthMu.Lock()
+	mirrorSyncFailures, mirrorSyncErr, standby := chs.mirrorSyncFailures, chs.mirrorSyncErr, chs.standby
+	chs.healthMu.Unlock()
+
+	if !standby && mirrorSyncFailures >= maxMirrorSyncFailures {
+		return fmt.Errorf("syncing git mirrors failed %d consecutive times: %s", mirrorSyncFailures, mirrorSyncErr)
+	}
+	return nil
+}
+
+// recordReconcile records a reconciliation pass was completed.
+func (chs *ChartChangeSync) recordReconcile() {
+	chs.healthMu.Lock()
+	chs.lastReconcile = time.Now()
+	chs.healthMu.Unlock()
+}
+
+// recordMirrorSync records the errors of a mirror sync, resetting
+// the consecutive failures when there are none.
+func (chs *ChartChangeSync) recordMirrorSync(errs []error) {
+	chs.healthMu.Lock()
+	defer chs.healthMu.Unlock()
+	if len(errs) == 0 {
+		chs.mirrorSyncFailures, chs.mirrorSyncErr = 0, nil
+		return
+	}
+	chs.mirrorSyncFailures++
+	chs.mirrorSyncErr = errs[0]
+}
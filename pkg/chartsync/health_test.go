@@ -0,0 +1,58 @@
+package chartsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+)
+
+func TestHealthy(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	chs := &ChartChangeSync{
+		hrLister:      iflister.NewHelmReleaseLister(indexer),
+		config:        Config{HealthStalenessWindow: time.Minute},
+		lastReconcile: time.Now().Add(-2 * time.Minute),
+	}
+
+	// Stale, but nothing to reconcile
+	assert.NoError(t, chs.Healthy())
+
+	indexer.Add(&helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"}})
+	assert.Error(t, chs.Healthy())
+
+	chs.recordReconcile()
+	assert.NoError(t, chs.Healthy())
+
+	// Failing mirror syncs do not make it unhealthy
+	for i := 0; i < maxMirrorSyncFailures; i++ {
+		chs.recordMirrorSync([]error{errors.New("fetch failed")})
+	}
+	assert.NoError(t, chs.Healthy())
+}
+
+func TestReady(t *testing.T) {
+	chs := &ChartChangeSync{}
+	assert.NoError(t, chs.Ready())
+
+	for i := 0; i < maxMirrorSyncFailures-1; i++ {
+		chs.recordMirrorSync([]error{errors.New("fetch failed")})
+	}
+	assert.NoError(t, chs.Ready())
+	chs.recordMirrorSync([]error{errors.New("fetch failed")})
+	assert.Error(t, chs.Ready())
+
+	// Standing by, no mirrors are synced
+	chs.setStandby(true)
+	assert.NoError(t, chs.Ready())
+	chs.setStandby(false)
+
+	chs.recordMirrorSync(nil)
+	assert.NoError(t, chs.Ready())
+}
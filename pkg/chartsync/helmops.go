@@ -0,0 +1,75 @@
+package chartsync
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// helmOps bounds the number of Helm operations that mutate the
+// cluster (installs, upgrades, rollbacks and deletions) running at
+// once across all releases; a nil helmOps does not bound them.
+type helmOps chan struct{}
+
+// newHelmOps returns a helmOps allowing the given number of
+// operations at once, or nil if the number is not positive.
+func newHelmOps(max int) helmOps {
+	if max <= 0 {
+		return nil
+	}
+	return make(helmOps, max)
+}
+
+// acquire blocks until another operation is allowed to run.
+func (ops helmOps) acquire() {
+	if ops != nil {
+		ops <- struct{}{}
+	}
+}
+
+// done marks an operation started with acquire as finished.
+func (ops helmOps) done() {
+	if ops != nil {
+		<-ops
+	}
+}
+
+// install installs or upgrades the release of the given HelmRelease.
+// Unless it is a dry run, it waits for its turn if the number of
+// concurrent Helm operations is bounded.
+func (chs *ChartChangeSync) install(chartPath, releaseName string, hr helmfluxv1.HelmRelease, action release.Action,
+	opts release.InstallOptions) (*hapi_release.Release, string, error) {
+
+	if !opts.DryRun {
+		chs.helmOps.acquire()
+		defer chs.helmOps.done()
+	}
+	return chs.release.Install(chartPath, releaseName, hr, action, opts, &chs.kubeClient)
+}
+
+// deleteRelease deletes the release with the given name with the
+// uninstall options of the given HelmRelease, waiting for its turn if
+// the number of concurrent Helm operations is bounded.
+func (chs *ChartChangeSync) deleteRelease(name string, hr helmfluxv1.HelmRelease) error {
+	chs.helmOps.acquire()
+	defer chs.helmOps.done()
+	return chs.release.Delete(name, hr)
+}
+
+// dryRunOnly returns if the operator only performs dry runs, in which
+// case it reports the given action it would have taken for the given
+// HelmRelease in the condition of the given type, and as an event.
+func (chs *ChartChangeSync) dryRunOnly(hr helmfluxv1.HelmRelease, typ helmfluxv1.HelmReleaseConditionType, action string) bool {
+	if !chs.config.DryRunOnly {
+		return false
+	}
+	msg := fmt.Sprintf("dry-run only: would %s", action)
+	chs.setCondition(hr, typ, v1.ConditionUnknown, ReasonDryRunOnly, msg)
+	chs.recordEvent(hr, v1.EventTypeNormal, ReasonDryRunOnly, msg)
+	chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+	return true
+}
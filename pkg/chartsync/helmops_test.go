@@ -0,0 +1,92 @@
+package chartsync
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+func TestHelmOps(t *testing.T) {
+	ops := newHelmOps(2)
+
+	var mu sync.Mutex
+	var active, maxSeen int
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ops.acquire()
+			defer ops.done()
+			mu.Lock()
+			active++
+			if active > maxSeen {
+				maxSeen = active
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, maxSeen)
+}
+
+func TestHelmOps_Unbounded(t *testing.T) {
+	ops := newHelmOps(0)
+	assert.Nil(t, ops)
+	for i := 0; i < 10; i++ {
+		ops.acquire()
+	}
+	for i := 0; i < 10; i++ {
+		ops.done()
+	}
+}
+
+func TestDryRunOnly(t *testing.T) {
+	enabled := true
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec:       helmfluxv1.HelmReleaseSpec{Rollback: helmfluxv1.Rollback{Enable: &enabled}},
+	}
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+
+	recorder := record.NewFakeRecorder(1)
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		release:  release.New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		ifClient: ifClient,
+		recorder: recorder,
+		config:   Config{DryRunOnly: true},
+	}
+
+	var rolledBack bool
+	chs.rollbackRelease(hr, "1.0.0", "checksum", func(string, helmfluxv1.HelmRelease) (*hapi_release.Release, error) {
+		rolledBack = true
+		return &hapi_release.Release{}, nil
+	})
+	assert.False(t, rolledBack)
+	cond := status.GetCondition(srv.get().Status, helmfluxv1.HelmReleaseRolledBack)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, v1.ConditionUnknown, cond.Status)
+		assert.Equal(t, ReasonDryRunOnly, cond.Reason)
+		assert.Equal(t, "dry-run only: would roll back release 'default-podinfo'", cond.Message)
+	}
+	assert.Equal(t, "Normal DryRunOnly dry-run only: would roll back release 'default-podinfo'", <-recorder.Events)
+}
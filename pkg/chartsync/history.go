@@ -0,0 +1,96 @@
+package chartsync
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// tillerRecord is a revision of a release in the storage of Tiller,
+// which is either a config map or a secret.
+type tillerRecord struct {
+	name    string
+	secret  bool
+	version int
+}
+
+// pruneHistory deletes the revisions of the release that exceed the
+// maxHistory of the HelmRelease from the storage of Tiller. Helm 2
+// only knows a history limit for all of Tiller, so the operator
+// enforces the one of the release itself.
+func (chs *ChartChangeSync) pruneHistory(hr helmfluxv1.HelmRelease, releaseName string) {
+	max := hr.GetMaxHistory()
+	if max <= 0 || chs.config.TillerNamespace == "" {
+		return
+	}
+	if err := pruneTillerRecords(&chs.kubeClient, chs.config.TillerNamespace, releaseName, int(max)); err != nil {
+		chs.logger.Log("warning", "could not delete superseded revisions of the release", "resource", hr.ResourceID().String(), "release", releaseName, "err", err)
+	}
+}
+
+// pruneTillerRecords deletes the revisions of the release in the
+// storage of Tiller in the given namespace but the newest max ones,
+// oldest first. The deployed revision is always kept, and counts
+// towards max.
+func pruneTillerRecords(client kubernetes.Interface, namespace, releaseName string, max int) error {
+	records, err := tillerRecords(client, namespace, releaseName)
+	if err != nil {
+		return err
+	}
+	if keep := max - 1; keep < len(records) {
+		records = records[keep:]
+	} else {
+		records = nil
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.secret {
+			err = client.CoreV1().Secrets(namespace).Delete(r.name, &metav1.DeleteOptions{})
+		} else {
+			err = client.CoreV1().ConfigMaps(namespace).Delete(r.name, &metav1.DeleteOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("deleting revision %d: %s", r.version, err)
+		}
+	}
+	return nil
+}
+
+// tillerRecords returns the revisions of the release in the storage
+// of Tiller in the given namespace, newest first, apart from the
+// deployed revision.
+func tillerRecords(client kubernetes.Interface, namespace, releaseName string) ([]tillerRecord, error) {
+	opts := metav1.ListOptions{LabelSelector: "OWNER=TILLER,NAME=" + releaseName}
+	var records []tillerRecord
+	add := func(meta metav1.ObjectMeta, secret bool) {
+		if meta.Labels["STATUS"] == "DEPLOYED" {
+			return
+		}
+		version, err := strconv.Atoi(meta.Labels["VERSION"])
+		if err != nil {
+			return
+		}
+		records = append(records, tillerRecord{name: meta.Name, secret: secret, version: version})
+	}
+	cms, err := client.CoreV1().ConfigMaps(namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, cm := range cms.Items {
+		add(cm.ObjectMeta, false)
+	}
+	secrets, err := client.CoreV1().Secrets(namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range secrets.Items {
+		add(s.ObjectMeta, true)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].version > records[j].version })
+	return records, nil
+}
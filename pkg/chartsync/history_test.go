@@ -0,0 +1,38 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_pruneTillerRecords(t *testing.T) {
+	record := func(name, release, version, status string) *v1.ConfigMap {
+		return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kube-system",
+			Labels:    map[string]string{"OWNER": "TILLER", "NAME": release, "VERSION": version, "STATUS": status},
+		}}
+	}
+	client := fake.NewSimpleClientset(
+		record("podinfo.v1", "podinfo", "1", "SUPERSEDED"),
+		record("podinfo.v2", "podinfo", "2", "DEPLOYED"),
+		record("podinfo.v3", "podinfo", "3", "FAILED"),
+		record("podinfo.v4", "podinfo", "4", "FAILED"),
+		record("other.v1", "other", "1", "SUPERSEDED"),
+	)
+
+	assert.NoError(t, pruneTillerRecords(client, "kube-system", "podinfo", 2))
+	cms, err := client.CoreV1().ConfigMaps("kube-system").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	var names []string
+	for _, cm := range cms.Items {
+		names = append(names, cm.Name)
+	}
+	// The deployed revision is kept even though it is not among the
+	// newest, and the revisions of other releases are left alone
+	assert.ElementsMatch(t, []string{"other.v1", "podinfo.v2", "podinfo.v4"}, names)
+}
@@ -0,0 +1,74 @@
+package chartsync
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// reconcileLagInterval is the interval at which the reconcile lag of
+// the HelmReleases is recorded.
+const reconcileLagInterval = 30 * time.Second
+
+// laggingRelease identifies the release of a HelmRelease of which the
+// reconcile lag was recorded, by the labels it was recorded with.
+type laggingRelease struct {
+	namespace, releaseName string
+}
+
+// reconcileLag returns the number of generations of the given
+// HelmRelease that have not been reconciled yet.
+func reconcileLag(hr helmfluxv1.HelmRelease) int64 {
+	if lag := hr.Generation - hr.Status.ObservedGeneration; lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+// recordReconcileLag records the number of generations each
+// HelmRelease is behind on, and the number of HelmReleases that are
+// behind, as metrics, and brings the Ready condition of those behind
+// up to date, as it is otherwise only updated once they are
+// reconciled. Only HelmReleases that match the release selector are
+// considered, of which the number is recorded as well. The lag of the releases in the given set that no
+// longer have a HelmRelease we reconcile is reset to zero; the
+// returned set is to be given on the next call.
+func (chs *ChartChangeSync) recordReconcileLag(recorded map[laggingRelease]bool) map[laggingRelease]bool {
+	hrs, err := chs.hrLister.List(labels.Everything())
+	if err != nil {
+		chs.logger.Log("warning", "unable to list HelmReleases to record their reconcile lag", "err", err)
+		return recorded
+	}
+
+	var awaiting, selected int
+	seen := make(map[laggingRelease]bool, len(hrs))
+	for _, hr := range hrs {
+		if !chs.Selected(*hr) {
+			continue
+		}
+		selected++
+		key := laggingRelease{hr.Namespace, chs.release.ReleaseName(*hr)}
+		lag := reconcileLag(*hr)
+		generationLag.With(release.LabelNamespace, key.namespace, release.LabelReleaseName, key.releaseName).Set(float64(lag))
+		seen[key] = true
+		if lag == 0 {
+			continue
+		}
+		awaiting++
+		if err := status.UpdateReady(chs.ifClient.HelmV1().HelmReleases(hr.Namespace), *hr); err != nil {
+			chs.logger.Log("warning", "could not update the Ready condition", "resource", hr.ResourceID().String(), "err", err)
+		}
+	}
+	for key := range recorded {
+		if !seen[key] {
+			generationLag.With(release.LabelNamespace, key.namespace, release.LabelReleaseName, key.releaseName).Set(0)
+		}
+	}
+	releasesAwaitingReconcile.Set(float64(awaiting))
+	releasesCount.Set(float64(selected))
+	return seen
+}
@@ -0,0 +1,67 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+func TestRecordReconcileLag(t *testing.T) {
+	released := status.NewCondition(helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, ReasonSuccess, "helm upgrade succeeded")
+	ready := status.NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionTrue, ReasonSuccess, "helm upgrade succeeded")
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Generation: 4},
+		Status: helmfluxv1.HelmReleaseStatus{
+			ObservedGeneration: 2,
+			Conditions:         []helmfluxv1.HelmReleaseCondition{released, ready},
+		},
+	}
+	assert.Equal(t, int64(2), reconcileLag(hr))
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+
+	upToDate := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "redis", Namespace: "default", Generation: 1},
+		Status:     helmfluxv1.HelmReleaseStatus{ObservedGeneration: 1},
+	}
+	assert.Equal(t, int64(0), reconcileLag(upToDate))
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&hr)
+	indexer.Add(&upToDate)
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		release:  release.New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		hrLister: iflister.NewHelmReleaseLister(indexer),
+		ifClient: ifClient,
+	}
+
+	recorded := chs.recordReconcileLag(nil)
+	assert.Equal(t, map[laggingRelease]bool{
+		{"default", "default-podinfo"}: true,
+		{"default", "default-redis"}:   true,
+	}, recorded)
+
+	// The Ready condition of the HelmRelease that is behind reflects
+	// it, rather than the outcome of its last reconciliation
+	cond := status.GetCondition(srv.get().Status, helmfluxv1.HelmReleaseReady)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, v1.ConditionUnknown, cond.Status)
+		assert.Equal(t, status.ReasonProgressing, cond.Reason)
+		assert.Equal(t, "generation 4 has not been reconciled yet", cond.Message)
+	}
+
+	indexer.Delete(&upToDate)
+	recorded = chs.recordReconcileLag(recorded)
+	assert.Equal(t, map[laggingRelease]bool{{"default", "default-podinfo"}: true}, recorded)
+}
@@ -0,0 +1,139 @@
+package chartsync
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// The defaults of the leader election, which are those of the
+// Kubernetes controller manager.
+const (
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// LeaderElection configures the election of the replica of the
+// operator that reconciles releases and syncs the git mirrors, so
+// that several replicas can be run; the others stand by until they
+// are elected.
+type LeaderElection struct {
+	// LeaseNamespace and LeaseName name the config map that holds
+	// the lease the replicas compete for.
+	LeaseNamespace string
+	LeaseName      string
+	// LeaseDuration is the duration standby replicas wait before
+	// taking over the lease when it is not renewed.
+	LeaseDuration time.Duration
+	// RenewDeadline is the duration the leader tries to renew the
+	// lease for before it gives up its leadership.
+	RenewDeadline time.Duration
+	// RetryPeriod is the interval at which the lease is acquired or
+	// renewed.
+	RetryPeriod time.Duration
+}
+
+// withDefaults returns the LeaderElection with the durations that are
+// not set defaulted.
+func (le LeaderElection) withDefaults() LeaderElection {
+	if le.LeaseDuration <= 0 {
+		le.LeaseDuration = DefaultLeaseDuration
+	}
+	if le.RenewDeadline <= 0 {
+		le.RenewDeadline = DefaultRenewDeadline
+	}
+	if le.RetryPeriod <= 0 {
+		le.RetryPeriod = DefaultRetryPeriod
+	}
+	return le
+}
+
+// leadershipLostError is the error reported when the lease of the
+// leader was taken over, or could not be renewed.
+type leadershipLostError struct {
+	lease string
+}
+
+func (e *leadershipLostError) Error() string {
+	return "lost leadership of lease " + e.lease
+}
+
+// IsLeadershipLost returns if the error reported by the leader
+// election is the loss of the leadership, after which another
+// replica may already be reconciling the releases.
+func IsLeadershipLost(err error) bool {
+	_, ok := err.(*leadershipLostError)
+	return ok
+}
+
+// RunLeaderElection campaigns for the lease configured with the
+// LeaderElection under the given identity, and calls lead once
+// elected. Until then, the replica stands by, and is healthy without
+// reconciling anything.
+//
+// As what lead starts can not be restarted, losing the leadership is
+// reported on the given error channel (see IsLeadershipLost), so that
+// the operator exits: no further reconciliations are started, and,
+// since the new leader may already be reconciling the same releases,
+// those in flight are not waited for. The lease is released once the
+// given context is done, which must not be before the in-flight
+// reconciliations finished.
+func (chs *ChartChangeSync) RunLeaderElection(ctx context.Context, identity string, lead func(), errc chan error) error {
+	le := chs.config.LeaderElection.withDefaults()
+	lease := le.LeaseNamespace + "/" + le.LeaseName
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.ConfigMapLock{
+			ConfigMapMeta: metav1.ObjectMeta{Namespace: le.LeaseNamespace, Name: le.LeaseName},
+			Client:        chs.kubeClient.CoreV1(),
+			LockConfig:    resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   le.LeaseDuration,
+		RenewDeadline:   le.RenewDeadline,
+		RetryPeriod:     le.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            lease,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				chs.logger.Log("info", "elected leader, reconciling releases", "lease", lease, "identity", identity)
+				chs.setStandby(false)
+				lead()
+			},
+			OnStoppedLeading: func() {
+				// Done on our own accord, e.g. when shutting down
+				if ctx.Err() != nil {
+					return
+				}
+				chs.stopReconciling()
+				select {
+				case errc <- &leadershipLostError{lease}:
+				case <-ctx.Done():
+				}
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					chs.logger.Log("info", "standing by", "lease", lease, "leader", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	chs.setStandby(true)
+	go elector.Run(ctx)
+	return nil
+}
+
+// setStandby records if the replica stands by for the leader.
+func (chs *ChartChangeSync) setStandby(standby bool) {
+	chs.healthMu.Lock()
+	defer chs.healthMu.Unlock()
+	chs.standby = standby
+	// Start counting from now, so the leader has a full window to
+	// get to its first reconciliation
+	chs.lastReconcile = time.Now()
+}
@@ -0,0 +1,51 @@
+package chartsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+)
+
+func TestSetStandby(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"}})
+	chs := &ChartChangeSync{
+		hrLister:      iflister.NewHelmReleaseLister(indexer),
+		config:        Config{HealthStalenessWindow: time.Minute},
+		lastReconcile: time.Now().Add(-2 * time.Minute),
+	}
+	assert.Error(t, chs.Healthy())
+
+	// Standing by, nothing is reconciled
+	chs.setStandby(true)
+	assert.NoError(t, chs.Healthy())
+
+	// Once elected, the leader has a full window to reconcile
+	chs.setStandby(false)
+	assert.NoError(t, chs.Healthy())
+	chs.lastReconcile = time.Now().Add(-2 * time.Minute)
+	assert.Error(t, chs.Healthy())
+}
+
+func TestLeaderElection_withDefaults(t *testing.T) {
+	le := LeaderElection{LeaseNamespace: "flux", LeaseName: "helm-operator-leader", RetryPeriod: time.Second}.withDefaults()
+	assert.Equal(t, LeaderElection{
+		LeaseNamespace: "flux",
+		LeaseName:      "helm-operator-leader",
+		LeaseDuration:  DefaultLeaseDuration,
+		RenewDeadline:  DefaultRenewDeadline,
+		RetryPeriod:    time.Second,
+	}, le)
+}
+
+func TestIsLeadershipLost(t *testing.T) {
+	assert.True(t, IsLeadershipLost(&leadershipLostError{"flux/helm-operator-leader"}))
+	assert.False(t, IsLeadershipLost(errors.New("lost leadership of lease flux/helm-operator-leader")))
+}
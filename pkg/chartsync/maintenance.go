@@ -0,0 +1,137 @@
+package chartsync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// deferredOutsideWindow returns if the given action on the release of
+// the HelmRelease has to wait for one of its maintenance windows to
+// open, in which case it records so in the Released condition and
+// requeues the release for when the window opens.
+func (chs *ChartChangeSync) deferredOutsideWindow(hr helmfluxv1.HelmRelease, action string) bool {
+	open, wait, err := maintenanceWindowOpen(hr.Spec.MaintenanceWindows, time.Now())
+	if err != nil {
+		msg := "invalid maintenance window: " + err.Error()
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonMaintenanceWindowInvalid, msg)
+		chs.logger.Log("warning", msg, "resource", hr.ResourceID().String())
+		return true
+	}
+	if open {
+		return false
+	}
+	msg := fmt.Sprintf("%s deferred until the next maintenance window opens in %s", action, wait.Round(time.Minute))
+	chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionUnknown, ReasonDeferredOutsideWindow, msg)
+	chs.recordAction(hr, helmfluxv1.HelmReleaseActionSkip, ReasonDeferredOutsideWindow, "")
+	chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+	if cacheKey, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta()); err == nil {
+		chs.releaseQueue.AddAfter(cacheKey, wait)
+	}
+	return true
+}
+
+// maintenanceWindowOpen returns if one of the given windows is open
+// at the given time and, if none is, the time until the first one
+// opens. Without windows, it is always open.
+func maintenanceWindowOpen(windows []helmfluxv1.MaintenanceWindow, now time.Time) (bool, time.Duration, error) {
+	if len(windows) == 0 {
+		return true, 0, nil
+	}
+	var wait time.Duration
+	for _, w := range windows {
+		open, next, err := windowOpen(w, now)
+		if err != nil {
+			return false, 0, err
+		}
+		if open {
+			return true, 0, nil
+		}
+		if d := next.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	return false, wait, nil
+}
+
+// windowOpen returns if the given window is open at the given time,
+// and the next time it opens.
+func windowOpen(w helmfluxv1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	loc := time.UTC
+	if w.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+			return false, time.Time{}, fmt.Errorf("unknown time zone %q", w.TimeZone)
+		}
+	}
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid start %q, expected HH:MM", w.Start)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid end %q, expected HH:MM", w.End)
+	}
+	days, err := parseWeekdays(w.Days)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	local := now.In(loc)
+	// The window may have opened yesterday if it spans midnight;
+	// a week ahead is as far as the next opening can be.
+	for d := -1; d <= 7; d++ {
+		day := local.AddDate(0, 0, d)
+		if days != nil && !days[day.Weekday()] {
+			continue
+		}
+		opens := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		closes := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+		if !closes.After(opens) {
+			closes = closes.AddDate(0, 0, 1)
+		}
+		if !local.Before(opens) && local.Before(closes) {
+			return true, opens, nil
+		}
+		if opens.After(local) {
+			return false, opens, nil
+		}
+	}
+	return false, time.Time{}, fmt.Errorf("window never opens")
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWeekdays parses the given names of days of the week, which
+// may be abbreviated to three letters; it returns nil for no days.
+func parseWeekdays(names []string) (map[time.Weekday]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	days := make(map[time.Weekday]bool, len(names))
+	for _, name := range names {
+		n := strings.ToLower(name)
+		if len(n) < 3 {
+			return nil, fmt.Errorf("unknown day of the week %q", name)
+		}
+		day, ok := weekdays[n[:3]]
+		if !ok || !strings.HasPrefix(strings.ToLower(day.String()), n) {
+			return nil, fmt.Errorf("unknown day of the week %q", name)
+		}
+		days[day] = true
+	}
+	return days, nil
+}
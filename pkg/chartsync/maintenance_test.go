@@ -0,0 +1,91 @@
+package chartsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestMaintenanceWindowOpen(t *testing.T) {
+	// Saturday
+	saturday := time.Date(2019, 10, 12, 0, 0, 0, 0, time.UTC)
+	at := func(days, hours, minutes int) time.Time {
+		return saturday.AddDate(0, 0, days).Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+	}
+
+	for name, tc := range map[string]struct {
+		windows []helmfluxv1.MaintenanceWindow
+		now     time.Time
+		open    bool
+		wait    time.Duration
+	}{
+		"no windows": {
+			now:  at(0, 12, 0),
+			open: true,
+		},
+		"every day, open": {
+			windows: []helmfluxv1.MaintenanceWindow{{Start: "02:00", End: "04:00"}},
+			now:     at(0, 3, 0),
+			open:    true,
+		},
+		"every day, closed": {
+			windows: []helmfluxv1.MaintenanceWindow{{Start: "02:00", End: "04:00"}},
+			now:     at(0, 4, 0),
+			wait:    22 * time.Hour,
+		},
+		"spanning midnight, open after midnight": {
+			windows: []helmfluxv1.MaintenanceWindow{{Days: []string{"Fri"}, Start: "22:00", End: "04:00"}},
+			now:     at(0, 1, 30),
+			open:    true,
+		},
+		"spanning midnight, closed": {
+			windows: []helmfluxv1.MaintenanceWindow{{Days: []string{"Fri"}, Start: "22:00", End: "04:00"}},
+			now:     at(0, 4, 30),
+			wait:    6*24*time.Hour + 17*time.Hour + 30*time.Minute,
+		},
+		"other day": {
+			windows: []helmfluxv1.MaintenanceWindow{{Days: []string{"monday", "Tue"}, Start: "02:00", End: "04:00"}},
+			now:     at(0, 3, 0),
+			wait:    47 * time.Hour,
+		},
+		"time zone": {
+			windows: []helmfluxv1.MaintenanceWindow{{Start: "02:00", End: "04:00", TimeZone: "Europe/Amsterdam"}},
+			now:     at(0, 1, 0), // 03:00 CEST
+			open:    true,
+		},
+		"first window to open": {
+			windows: []helmfluxv1.MaintenanceWindow{
+				{Start: "20:00", End: "21:00"},
+				{Days: []string{"sun"}, Start: "08:00", End: "09:00"},
+				{Days: []string{"sat"}, Start: "14:00", End: "15:00"},
+			},
+			now:  at(0, 12, 0),
+			wait: 2 * time.Hour,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			open, wait, err := maintenanceWindowOpen(tc.windows, tc.now)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.open, open)
+			assert.Equal(t, tc.wait, wait)
+		})
+	}
+}
+
+func TestMaintenanceWindowOpen_invalid(t *testing.T) {
+	for name, w := range map[string]helmfluxv1.MaintenanceWindow{
+		"day":       {Days: []string{"Caturday"}, Start: "02:00", End: "04:00"},
+		"short day": {Days: []string{"s"}, Start: "02:00", End: "04:00"},
+		"start":     {Start: "2am", End: "04:00"},
+		"end":       {Start: "02:00", End: "25:00"},
+		"time zone": {Start: "02:00", End: "04:00", TimeZone: "Mars/Olympus_Mons"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := maintenanceWindowOpen([]helmfluxv1.MaintenanceWindow{w}, time.Now())
+			assert.Error(t, err)
+		})
+	}
+}
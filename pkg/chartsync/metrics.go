@@ -0,0 +1,75 @@
+package chartsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+const (
+	LabelPhase = "phase"
+)
+
+// Phases of the reconciliation of a release of which the duration
+// is recorded.
+const (
+	PhaseChartFetch       = "chart_fetch"
+	PhaseDependencyUpdate = "dependency_update"
+	PhaseDryRun           = "dry_run"
+)
+
+var (
+	phaseDurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120}
+	phaseDuration        = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "flux",
+		Subsystem: "helm_operator",
+		Name:      "reconcile_phase_duration_seconds",
+		Help:      "Duration of the phases of a release reconciliation in seconds.",
+		Buckets:   phaseDurationBuckets,
+	}, []string{LabelPhase, release.LabelSuccess, release.LabelNamespace, release.LabelReleaseName})
+	generationLag = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "flux",
+		Subsystem: "helm_operator",
+		Name:      "release_generation_lag",
+		Help:      "Number of generations of a HelmRelease that have not been reconciled yet.",
+	}, []string{release.LabelNamespace, release.LabelReleaseName})
+	releasesAwaitingReconcile = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "flux",
+		Subsystem: "helm_operator",
+		Name:      "releases_awaiting_reconcile_count",
+		Help:      "Count of HelmReleases of which the current generation has not been reconciled yet.",
+	}, []string{})
+	releasesCount = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "flux",
+		Subsystem: "helm_operator",
+		Name:      "releases_count",
+		Help:      "Count of HelmReleases that match the release selector of this operator.",
+	}, []string{})
+	releasesUnselected = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "flux",
+		Subsystem: "helm_operator",
+		Name:      "releases_unselected_count",
+		Help:      "Count of HelmReleases that match the release selector of none of the operators sharing them.",
+	}, []string{})
+)
+
+// observePhase records the duration of a phase of the reconciliation
+// of the given HelmRelease that started at the given time, both as a
+// metric and in the log, so that slow reconciliations can be broken
+// down.
+func (chs *ChartChangeSync) observePhase(hr helmfluxv1.HelmRelease, phase string, start time.Time, success bool) {
+	duration := time.Since(start)
+	phaseDuration.With(
+		LabelPhase, phase,
+		release.LabelSuccess, fmt.Sprint(success),
+		release.LabelNamespace, hr.Namespace,
+		release.LabelReleaseName, chs.release.ReleaseName(hr),
+	).Observe(duration.Seconds())
+	chs.logger.Log("info", "reconcile phase finished", "resource", hr.ResourceID().String(), "phase", phase,
+		"duration", duration.String(), "success", success)
+}
@@ -0,0 +1,128 @@
+package chartsync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultMirrorSyncWorkers is the default number of git mirrors that
+// are refreshed concurrently.
+const defaultMirrorSyncWorkers = 4
+
+// refresher fetches from an upstream; it is implemented by the
+// git.Repo of a mirror.
+type refresher interface {
+	Refresh(ctx context.Context) error
+}
+
+// refreshMirrors refreshes the given mirrors concurrently, with at
+// most the given number of workers. Every refresh is aborted after
+// the given timeout, so that a hanging remote can not hold up the
+// others. It returns the error of every mirror that failed to
+// refresh, by mirror name.
+func refreshMirrors(mirrors map[string]refresher, workers int, timeout time.Duration) map[string]error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	errs := make(map[string]error)
+
+	names := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				err := mirrors[name].Refresh(ctx)
+				cancel()
+				if err != nil {
+					mu.Lock()
+					errs[name] = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for name := range mirrors {
+		names <- name
+	}
+	close(names)
+	wg.Wait()
+
+	return errs
+}
+
+// mirrorsToSync returns the mirrors of the git chart sources of the
+// HelmReleases we know about, by mirror name.
+func (chs *ChartChangeSync) mirrorsToSync() (map[string]refresher, error) {
+	list, err := chs.selectedReleases()
+	if err != nil {
+		return nil, err
+	}
+	mirrors := make(map[string]refresher)
+	for _, hr := range list {
+		if hr.Spec.GitChartSource == nil {
+			continue
+		}
+		name := mirrorName(hr.Namespace, hr.Spec.GitChartSource)
+		if _, ok := mirrors[name]; ok {
+			continue
+		}
+		// Renew the installation token of a GitHub App before the
+		// mirror is refreshed, if it is about to expire; the secret
+		// is only read then.
+		if ref := hr.Spec.GitChartSource.GitHubAppSecretRef; ref != nil && hr.Spec.GitChartSource.SSHSecretRef == nil &&
+			!chs.githubAppTokens.fresh(hr.Namespace+"/"+ref.Name, time.Now()) {
+			if err := chs.refreshGitRemote(*hr); err != nil {
+				chs.logger.Log("warning", "failed to renew GitHub App installation token of git mirror", "resource", hr.ResourceID().String(), "err", err)
+			}
+		}
+		if repo, ok := chs.mirrors.Get(name); ok {
+			mirrors[name] = repo
+		}
+	}
+	return mirrors, nil
+}
+
+// syncMirrors refreshes the mirrors of the git chart sources of all
+// HelmReleases, logging the failure of every mirror individually
+// rather than aborting on the first. Mirrors of which the circuit
+// breaker is open are skipped; the outcome of every refresh is
+// recorded in the breaker of the mirror.
+func (chs *ChartChangeSync) syncMirrors() []error {
+	mirrors, err := chs.mirrorsToSync()
+	if err != nil {
+		return []error{fmt.Errorf("unable to list HelmReleases: %s", err)}
+	}
+	return chs.syncMirrorSet(mirrors)
+}
+
+// syncMirrorSet refreshes the given mirrors as syncMirrors does.
+func (chs *ChartChangeSync) syncMirrorSet(mirrors map[string]refresher) []error {
+	now := time.Now()
+	for name := range mirrors {
+		if b, open := chs.openMirrorBreaker(name, now); open {
+			chs.logger.Log("info", "skipping sync of git mirror with open circuit breaker", "repo", name, "until", b.openUntil.Format(time.RFC3339))
+			delete(mirrors, name)
+		}
+	}
+
+	var errs []error
+	failed := refreshMirrors(mirrors, chs.config.MirrorSyncWorkers, chs.config.GitTimeout)
+	for name := range mirrors {
+		err := failed[name]
+		if err != nil {
+			chs.logger.Log("error", "failure while syncing mirror", "repo", name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+		}
+		if b, opened, closed := chs.recordMirrorRefresh(name, err, time.Now()); opened || closed {
+			chs.mirrorBreakerChanged(name, b, opened)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,79 @@
+package chartsync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRefresher struct {
+	err  error
+	hang bool
+
+	mu        *sync.Mutex
+	active    *int
+	maxSeen   *int
+	refreshes int
+}
+
+func (f *fakeRefresher) Refresh(ctx context.Context) error {
+	if f.mu != nil {
+		f.mu.Lock()
+		*f.active++
+		if *f.active > *f.maxSeen {
+			*f.maxSeen = *f.active
+		}
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			*f.active--
+			f.mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.refreshes++
+	if f.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.err
+}
+
+func TestRefreshMirrors(t *testing.T) {
+	ok := &fakeRefresher{}
+	failing := &fakeRefresher{err: errors.New("fetch failed")}
+	hanging := &fakeRefresher{hang: true}
+
+	start := time.Now()
+	errs := refreshMirrors(map[string]refresher{
+		"ok":      ok,
+		"failing": failing,
+		"hanging": hanging,
+	}, 2, 50*time.Millisecond)
+
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, 1, ok.refreshes)
+	assert.Equal(t, 1, failing.refreshes)
+	assert.Equal(t, 1, hanging.refreshes)
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs["failing"], "fetch failed")
+	assert.Equal(t, context.DeadlineExceeded, errs["hanging"])
+}
+
+func TestRefreshMirrors_Workers(t *testing.T) {
+	var mu sync.Mutex
+	var active, maxSeen int
+	mirrors := make(map[string]refresher)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		mirrors[name] = &fakeRefresher{mu: &mu, active: &active, maxSeen: &maxSeen}
+	}
+
+	errs := refreshMirrors(mirrors, 3, time.Second)
+	assert.Empty(t, errs)
+	assert.True(t, maxSeen <= 3, "at most 3 mirrors refreshed at once, got %d", maxSeen)
+	assert.True(t, maxSeen > 1, "mirrors refreshed concurrently")
+}
@@ -0,0 +1,127 @@
+package chartsync
+
+import (
+	"fmt"
+	"path"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// ValidNamespacePatterns returns an error if any of the given target
+// namespace patterns is malformed.
+func ValidNamespacePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern %q: %s", p, err)
+		}
+	}
+	return nil
+}
+
+// targetNamespaceDenied returns a message explaining why releasing to
+// the given namespace is not allowed, or an empty string if it is.
+// Namespaces matching any of the denied patterns are not allowed,
+// and neither are, if there are allowed patterns, the namespaces
+// matching none of them.
+func targetNamespaceDenied(namespace string, allowed, denied []string) string {
+	for _, p := range denied {
+		if ok, _ := path.Match(p, namespace); ok {
+			return fmt.Sprintf("releasing to namespace '%s' is denied (by '%s')", namespace, p)
+		}
+	}
+	if len(allowed) == 0 {
+		return ""
+	}
+	for _, p := range allowed {
+		if ok, _ := path.Match(p, namespace); ok {
+			return ""
+		}
+	}
+	return fmt.Sprintf("releasing to namespace '%s' is not allowed", namespace)
+}
+
+// manifestNamespacesAllowed returns if the resources of the release
+// of the given HelmRelease only set namespaces it may release to, and
+// records why in the Released condition if they do not. Charts can
+// set the namespace of their resources, which would otherwise take
+// them out of the allowed target namespaces.
+func (chs *ChartChangeSync) manifestNamespacesAllowed(hr helmfluxv1.HelmRelease, chartPath string) bool {
+	allowed, denied := chs.config.AllowedTargetNamespaces, chs.config.DeniedTargetNamespaces
+	if len(allowed) == 0 && len(denied) == 0 {
+		return true
+	}
+	opts := chs.installOptions(hr, true)
+	tempRelName := release.DryRunReleaseName(chs.config.DryRunReleasePrefix, hr)
+	rel, _, err := chs.release.Install(chartPath, tempRelName, hr, release.InstallAction, opts, &chs.kubeClient)
+	if err != nil {
+		err = fmt.Errorf("unable to render release to check the namespaces of its resources: %s", err)
+	} else {
+		err = manifestNamespacesDenied(release.ManifestToUnstructured(renderedManifests(rel)), hr.GetTargetNamespace(), allowed, denied)
+	}
+	if err != nil {
+		chs.setCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonNamespaceDenied, err.Error())
+		chs.logger.Log("warning", "release sets namespaces it may not release to", "resource", hr.ResourceID().String(), "err", err)
+		return false
+	}
+	return true
+}
+
+// manifestNamespacesDenied returns an error naming the first of the
+// given objects that sets a namespace other than the given release
+// namespace that may not be released to.
+func manifestNamespacesDenied(objs []unstructured.Unstructured, releaseNamespace string, allowed, denied []string) error {
+	for _, obj := range objs {
+		namespace := obj.GetNamespace()
+		if namespace == "" || namespace == releaseNamespace {
+			continue
+		}
+		if msg := targetNamespaceDenied(namespace, allowed, denied); msg != "" {
+			return fmt.Errorf("%s '%s' sets namespace '%s': %s", obj.GetKind(), obj.GetName(), namespace, msg)
+		}
+	}
+	return nil
+}
+
+// ensureTargetNamespace makes sure the target namespace of the given
+// HelmRelease can be released to, creating it if it is missing and
+// the HelmRelease asks for it. It returns the reason and a message
+// explaining why the namespace can not be released to, or empty
+// strings if it can.
+//
+// A missing namespace the HelmRelease does not ask to create is left
+// to Tiller, which creates it when installing the release.
+func ensureTargetNamespace(client corev1client.NamespaceInterface, hr helmfluxv1.HelmRelease) (string, string) {
+	name := hr.GetTargetNamespace()
+	ns, err := client.Get(name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		if !hr.Spec.CreateNamespace {
+			return "", ""
+		}
+		_, err := client.Create(&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      hr.Spec.NamespaceMetadata.Labels,
+				Annotations: hr.Spec.NamespaceMetadata.Annotations,
+			},
+		})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return ReasonNamespaceFailed, fmt.Sprintf("failed to create target namespace '%s': %s", name, err)
+		}
+		return "", ""
+	case err != nil:
+		// We may not be allowed to get namespaces; carry on and let
+		// the release tell if the namespace is a problem.
+		return "", ""
+	case ns.Status.Phase == v1.NamespaceTerminating || ns.DeletionTimestamp != nil:
+		return ReasonNamespaceTerminating, fmt.Sprintf("target namespace '%s' is terminating", name)
+	}
+	return "", ""
+}
@@ -0,0 +1,93 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func Test_ensureTargetNamespace(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux"},
+		Spec: helmfluxv1.HelmReleaseSpec{
+			TargetNamespace: "podinfo",
+			NamespaceMetadata: helmfluxv1.NamespaceMetadata{
+				Labels:      map[string]string{"team": "a"},
+				Annotations: map[string]string{"owner": "team-a@example.com"},
+			},
+		},
+	}
+
+	// Missing, and not asked to create it
+	client := fake.NewSimpleClientset().CoreV1().Namespaces()
+	reason, _ := ensureTargetNamespace(client, hr)
+	assert.Empty(t, reason)
+	_, err := client.Get("podinfo", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// Missing, and asked to create it
+	hr.Spec.CreateNamespace = true
+	reason, _ = ensureTargetNamespace(client, hr)
+	assert.Empty(t, reason)
+	ns, err := client.Get("podinfo", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", ns.Labels["team"])
+	assert.Equal(t, "team-a@example.com", ns.Annotations["owner"])
+
+	// Exists
+	reason, _ = ensureTargetNamespace(client, hr)
+	assert.Empty(t, reason)
+
+	// Terminating
+	client = fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo"},
+		Status:     v1.NamespaceStatus{Phase: v1.NamespaceTerminating},
+	}).CoreV1().Namespaces()
+	reason, msg := ensureTargetNamespace(client, hr)
+	assert.Equal(t, ReasonNamespaceTerminating, reason)
+	assert.Contains(t, msg, "podinfo")
+}
+
+func Test_targetNamespaceDenied(t *testing.T) {
+	assert.Empty(t, targetNamespaceDenied("podinfo", nil, nil))
+
+	denied := []string{"kube-system", "kube-*"}
+	assert.Equal(t, "releasing to namespace 'kube-public' is denied (by 'kube-*')", targetNamespaceDenied("kube-public", nil, denied))
+	assert.Empty(t, targetNamespaceDenied("podinfo", nil, denied))
+
+	allowed := []string{"team-*", "podinfo"}
+	assert.Empty(t, targetNamespaceDenied("team-a", allowed, denied))
+	assert.Empty(t, targetNamespaceDenied("podinfo", allowed, denied))
+	assert.Equal(t, "releasing to namespace 'default' is not allowed", targetNamespaceDenied("default", allowed, denied))
+	// Denied wins
+	assert.NotEmpty(t, targetNamespaceDenied("kube-system", []string{"*"}, denied))
+
+	assert.NoError(t, ValidNamespacePatterns(append(allowed, denied...)))
+
+	// Resources that set their own namespace are held to it too
+	obj := func(kind, name, namespace string) unstructured.Unstructured {
+		var u unstructured.Unstructured
+		u.SetKind(kind)
+		u.SetName(name)
+		u.SetNamespace(namespace)
+		return u
+	}
+	objs := []unstructured.Unstructured{
+		obj("Deployment", "podinfo", ""),
+		obj("Service", "podinfo", "team-a"),
+		obj("ClusterRole", "podinfo", ""),
+	}
+	assert.NoError(t, manifestNamespacesDenied(objs, "team-a", allowed, denied))
+	objs = append(objs, obj("ServiceMonitor", "podinfo", "kube-system"))
+	err := manifestNamespacesDenied(objs, "team-a", allowed, denied)
+	if assert.Error(t, err) {
+		assert.Equal(t, "ServiceMonitor 'podinfo' sets namespace 'kube-system': releasing to namespace 'kube-system' is denied (by 'kube-system')", err.Error())
+	}
+	assert.Error(t, ValidNamespacePatterns([]string{"team-["}))
+}
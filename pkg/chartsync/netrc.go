@@ -0,0 +1,114 @@
+package chartsync
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// netrcCredentials are the credentials of a machine in a netrc file.
+type netrcCredentials struct {
+	login    string
+	password string
+}
+
+// parseNetrc parses the given netrc file, and returns the credentials
+// by machine name; the credentials of the default entry, if any, have
+// an empty machine name. Macro definitions are skipped, as are
+// accounts.
+func parseNetrc(data []byte) map[string]netrcCredentials {
+	machines := make(map[string]netrcCredentials)
+	var machine string
+	var creds netrcCredentials
+	inEntry := false
+	flush := func() {
+		if inEntry {
+			if _, ok := machines[machine]; !ok {
+				machines[machine] = creds
+			}
+		}
+		machine, creds, inEntry = "", netrcCredentials{}, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		// A macro definition ends at the first empty line
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				break
+			}
+			next := func() string {
+				if i+1 < len(fields) {
+					i++
+					return fields[i]
+				}
+				return ""
+			}
+			switch fields[i] {
+			case "machine":
+				flush()
+				machine, inEntry = strings.ToLower(next()), true
+			case "default":
+				flush()
+				inEntry = true
+			case "login":
+				creds.login = next()
+			case "password":
+				creds.password = next()
+			case "account":
+				next()
+			case "macdef":
+				flush()
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	flush()
+	return machines
+}
+
+// netrcCredentialsFor returns the credentials in the netrc file at
+// the given path for the host of the given repo URL, and true, or
+// false if there are none. Only the `machine` entry for the host is
+// matched, unless allowDefault is true, in which case the `default`
+// entry is used for hosts without one. Credentials are only handed
+// out for URLs with the `https` or `oci` scheme, as they would be sent
+// in the clear otherwise.
+func netrcCredentialsFor(path, repoURL string, allowDefault bool) (netrcCredentials, bool, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return netrcCredentials{}, false, err
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "https" && scheme != "oci" {
+		return netrcCredentials{}, false, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return netrcCredentials{}, false, nil
+		}
+		return netrcCredentials{}, false, err
+	}
+	machines := parseNetrc(data)
+	if host := strings.ToLower(u.Hostname()); host != "" {
+		if creds, ok := machines[host]; ok {
+			return creds, true, nil
+		}
+	}
+	if !allowDefault {
+		return netrcCredentials{}, false, nil
+	}
+	creds, ok := machines[""]
+	return creds, ok, nil
+}
@@ -0,0 +1,66 @@
+package chartsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetrcCredentialsFor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".netrc")
+	netrc := `# chart repositories
+machine charts.example.com login alice password s3cr3t
+machine other.example.com
+  login bob
+  account ops
+  password hunter2
+
+macdef init
+machine macro.example.com login mallory password nope
+
+default login anonymous password guest
+`
+	if err := ioutil.WriteFile(path, []byte(netrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for repoURL, expected := range map[string]netrcCredentials{
+		"https://charts.example.com/stable/":    {login: "alice", password: "s3cr3t"},
+		"https://Charts.Example.com/stable/":    {login: "alice", password: "s3cr3t"},
+		"https://other.example.com:8443/charts": {login: "bob", password: "hunter2"},
+		"oci://other.example.com/charts":        {login: "bob", password: "hunter2"},
+	} {
+		creds, ok, err := netrcCredentialsFor(path, repoURL, false)
+		assert.NoError(t, err)
+		assert.True(t, ok, repoURL)
+		assert.Equal(t, expected, creds, repoURL)
+	}
+
+	// The default entry is only used when allowed
+	_, ok, err := netrcCredentialsFor(path, "https://macro.example.com/", false)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	creds, ok, err := netrcCredentialsFor(path, "https://macro.example.com/", true)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, netrcCredentials{login: "anonymous", password: "guest"}, creds)
+
+	// Credentials are not sent in the clear
+	for _, repoURL := range []string{"http://charts.example.com/stable/", "s3://charts.example.com/"} {
+		_, ok, err = netrcCredentialsFor(path, repoURL, true)
+		assert.NoError(t, err)
+		assert.False(t, ok, repoURL)
+	}
+
+	_, ok, err = netrcCredentialsFor(filepath.Join(dir, "missing"), "https://charts.example.com/", false)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
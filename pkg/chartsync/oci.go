@@ -0,0 +1,472 @@
+package chartsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	corev1 "k8s.io/api/core/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// The media types of the manifests and layers of Helm charts in OCI
+// registries.
+const (
+	ociManifestMediaType   = "application/vnd.oci.image.manifest.v1+json"
+	ociChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ociProvLayerMediaType  = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+	// ociLegacyChartLayerMediaType is the media type of the chart
+	// layers pushed by the experimental OCI support of Helm before
+	// v3.0.0.
+	ociLegacyChartLayerMediaType = "application/tar+gzip"
+)
+
+// ociMetadataMaxSize is the size above which manifests and pages of
+// tags are refused, whatever the maximum size of charts; it is the
+// size of the manifests registries are required to accept.
+const ociMetadataMaxSize = 4 << 20
+
+// ociRepository is the repository of a chart in an OCI registry.
+type ociRepository struct {
+	// registry is the host (and port) of the registry
+	registry string
+	// name is the name of the repository in the registry, e.g.
+	// 'stefanprodan/charts/podinfo'
+	name string
+}
+
+// ociRepositoryOf returns the OCI repository of the given chart
+// source, which is named after the chart under the path of the
+// RepoURL.
+func ociRepositoryOf(source *helmfluxv1.RepoChartSource) (ociRepository, error) {
+	u, err := url.Parse(source.RepoURL)
+	if err != nil {
+		return ociRepository{}, err
+	}
+	if u.Host == "" {
+		return ociRepository{}, fmt.Errorf("no registry in OCI repository URL %q", source.RepoURL)
+	}
+	name := source.Name
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		name = path + "/" + name
+	}
+	return ociRepository{registry: u.Host, name: name}, nil
+}
+
+// ociTag returns the tag of the given chart version. Tags can not
+// contain a '+', so Helm replaces it with an '_'.
+func ociTag(version string) string {
+	return strings.Replace(version, "+", "_", -1)
+}
+
+// ociClient pulls from a repository in an OCI registry, logging in
+// to the registry as it asks for.
+type ociClient struct {
+	client     *http.Client
+	repository ociRepository
+	username   string
+	password   string
+	// basic is set when the registry asked for basic auth, token
+	// once a bearer token was fetched from its token endpoint
+	basic bool
+	token string
+}
+
+// newOCIClient returns an ociClient for the repository of the given
+// chart source, configured with the download options.
+func newOCIClient(source *helmfluxv1.RepoChartSource, opts downloadOptions) (*ociClient, error) {
+	repository, err := ociRepositoryOf(source)
+	if err != nil {
+		return nil, err
+	}
+	g, err := opts.newHTTPGetter("https://"+repository.registry, "", "", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	return &ociClient{
+		client:     g.client,
+		repository: repository,
+		username:   opts.Username,
+		password:   opts.Password,
+	}, nil
+}
+
+// ociFetcher pulls charts from OCI registries.
+type ociFetcher struct{}
+
+// fetch pulls the chart of the given source from its OCI registry to
+// the given path.
+func (ociFetcher) fetch(destFile string, source *helmfluxv1.RepoChartSource, opts downloadOptions) error {
+	c, err := newOCIClient(source, opts)
+	if err != nil {
+		return err
+	}
+
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+			Size      int64  `json:"size"`
+		} `json:"layers"`
+	}
+	buf := bytes.NewBuffer(nil)
+	if _, err := c.pull("manifests/"+ociTag(source.Version), ociManifestMediaType, buf, ociMetadataMaxSize); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(buf.Bytes(), &manifest); err != nil {
+		return fmt.Errorf("unable to parse manifest of %s:%s: %s", c.repository.name, ociTag(source.Version), err)
+	}
+	var chartDigest, provDigest string
+	for _, layer := range manifest.Layers {
+		switch layer.MediaType {
+		case ociChartLayerMediaType, ociLegacyChartLayerMediaType:
+			if opts.MaxSize > 0 && layer.Size > opts.MaxSize {
+				return downloadTooLargeError{c.repository.name + "@" + layer.Digest, opts.MaxSize}
+			}
+			chartDigest = layer.Digest
+		case ociProvLayerMediaType:
+			provDigest = layer.Digest
+		}
+	}
+	if chartDigest == "" {
+		return fmt.Errorf("%s:%s is not a Helm chart", c.repository.name, ociTag(source.Version))
+	}
+
+	if err := downloadTo(c, "blobs/"+chartDigest, destFile, opts.MaxSize); err != nil {
+		return err
+	}
+	if opts.Keyring != nil {
+		if provDigest == "" {
+			return verificationError{fmt.Errorf("no provenance file in %s:%s", c.repository.name, ociTag(source.Version))}
+		}
+		if err := downloadTo(c, "blobs/"+provDigest, destFile+".prov", opts.MaxSize); err != nil {
+			return verificationError{fmt.Errorf("failed to download provenance file: %s", err)}
+		}
+	}
+	return nil
+}
+
+// resolveVersion resolves the semver range to a version among the
+// tags of the repository of the chart.
+func (ociFetcher) resolveVersion(source *helmfluxv1.RepoChartSource, versionRange string, opts downloadOptions) (string, error) {
+	constraint, err := semver.NewConstraint(versionRange)
+	if err != nil {
+		return "", err
+	}
+	c, err := newOCIClient(source, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var highest *semver.Version
+	path := "tags/list"
+	for path != "" {
+		buf := bytes.NewBuffer(nil)
+		header, err := c.pull(path, "", buf, ociMetadataMaxSize)
+		if err != nil {
+			return "", err
+		}
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &list); err != nil {
+			return "", fmt.Errorf("unable to parse tags of %s: %s", c.repository.name, err)
+		}
+		for _, tag := range list.Tags {
+			v, err := semver.NewVersion(strings.Replace(tag, "_", "+", -1))
+			if err != nil || !constraint.Check(v) {
+				continue
+			}
+			if highest == nil || v.GreaterThan(highest) {
+				highest = v
+			}
+		}
+		path = nextPage(header.Get("Link"), c.repository.name)
+	}
+	if highest == nil {
+		return "", fmt.Errorf("no version of chart %q matches %q", source.Name, versionRange)
+	}
+	return highest.Original(), nil
+}
+
+// nextPage returns the path, relative to the repository with the
+// given name, of the next page of a paginated list, as given in its
+// Link header, or an empty string if there is none.
+func nextPage(link, name string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	u, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return ""
+	}
+	prefix := "/v2/" + name + "/"
+	if !strings.HasPrefix(u.Path, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(u.RequestURI(), prefix)
+}
+
+// Get fetches the given path of the repository, for downloadTo.
+func (c *ociClient) Get(path string) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	_, err := c.pull(path, "", buf, 0)
+	return buf, err
+}
+
+// download fetches the given path of the repository, for downloadTo.
+func (c *ociClient) download(path string, w io.Writer, maxSize int64) error {
+	_, err := c.pull(path, "", w, maxSize)
+	return err
+}
+
+// pull fetches the given path of the repository and writes the body
+// to the given writer, aborting once it exceeds the maximum size if
+// that is above zero. Blobs are verified against their digest.
+func (c *ociClient) pull(path, accept string, w io.Writer, maxSize int64) (http.Header, error) {
+	href := "https://" + c.repository.registry + "/v2/" + c.repository.name + "/" + path
+	resp, err := c.get(href, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := io.Reader(resp.Body)
+	if maxSize > 0 {
+		if resp.ContentLength > maxSize {
+			return nil, downloadTooLargeError{href, maxSize}
+		}
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	var h hash.Hash
+	digest := strings.TrimPrefix(path, "blobs/")
+	if digest != path {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("unsupported digest %q", digest)
+		}
+		h = sha256.New()
+		w = io.MultiWriter(w, h)
+	}
+	n, err := io.Copy(w, body)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && n > maxSize {
+		return nil, downloadTooLargeError{href, maxSize}
+	}
+	if h != nil && "sha256:"+hex.EncodeToString(h.Sum(nil)) != digest {
+		return nil, fmt.Errorf("digest of %s does not match", href)
+	}
+	return resp.Header, nil
+}
+
+// get requests the given URL, logging in to the registry and trying
+// again once if it asks for it.
+func (c *ociClient) get(href, accept string) (*http.Response, error) {
+	resp, err := c.do(href, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.login(challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(href, accept); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s : %s", href, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *ociClient) do(href, accept string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", href, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.basic:
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.client.Do(req)
+}
+
+// login logs in to the registry as the given challenge of it asks
+// for: with basic auth, or with a bearer token fetched from its
+// token endpoint, with the credentials if we have them, anonymously
+// otherwise.
+func (c *ociClient) login(challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.username == "" {
+			return fmt.Errorf("registry %s requires credentials", c.repository.registry)
+		}
+		c.basic = true
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("registry %s asks for unsupported authentication %q", c.repository.registry, scheme)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("registry %s asks for a token from invalid realm %q", c.repository.registry, params["realm"])
+	}
+	q := realm.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.repository.name + ":pull"
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to log in to registry %s: %s", c.repository.registry, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("unable to parse token of registry %s: %s", c.repository.registry, err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("registry %s returned no token", c.repository.registry)
+	}
+	return nil
+}
+
+// parseChallenge parses the scheme and the parameters of the given
+// WWW-Authenticate header, e.g.
+// 'Bearer realm="https://ghcr.io/token",service="ghcr.io"'.
+func parseChallenge(challenge string) (string, map[string]string) {
+	challenge = strings.TrimSpace(challenge)
+	params := make(map[string]string)
+	i := strings.Index(challenge, " ")
+	if i < 0 {
+		return challenge, params
+	}
+	scheme, rest := challenge[:i], challenge[i+1:]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return scheme, params
+}
+
+// registryCredentials returns the username and password for the
+// given registry from the given secret, which holds either a
+// `.dockerconfigjson` (as image pull secrets do) or a `username` and
+// `password`.
+func registryCredentials(data map[string][]byte, registry string) (string, string, error) {
+	config, ok := data[corev1.DockerConfigJsonKey]
+	if !ok {
+		username, password := string(data["username"]), string(data["password"])
+		if username == "" || password == "" {
+			return "", "", fmt.Errorf("must have either '%s', or both 'username' and 'password'", corev1.DockerConfigJsonKey)
+		}
+		return username, password, nil
+	}
+
+	var dockerConfig struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(config, &dockerConfig); err != nil {
+		return "", "", fmt.Errorf("invalid '%s': %s", corev1.DockerConfigJsonKey, err)
+	}
+	for server, auth := range dockerConfig.Auths {
+		// Servers may be given as URLs, e.g. https://index.docker.io/v1/
+		host := server
+		if u, err := url.Parse(server); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if host != registry {
+			continue
+		}
+		if auth.Username == "" && auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid auth for registry %s: %s", registry, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return "", "", fmt.Errorf("invalid auth for registry %s", registry)
+			}
+			auth.Username, auth.Password = parts[0], parts[1]
+		}
+		return auth.Username, auth.Password, nil
+	}
+	return "", "", errors.New("no credentials for registry " + registry)
+}
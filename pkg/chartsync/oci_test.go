@@ -0,0 +1,144 @@
+package chartsync
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func Test_pullOCIChart(t *testing.T) {
+	chart := []byte("podinfo chart archive")
+	sum := sha256.Sum256(chart)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if u, p, _ := r.BasicAuth(); u != "flux" || p != "s3cr3t" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:charts/podinfo:pull", r.URL.Query().Get("scope"))
+			json.NewEncoder(w).Encode(map[string]string{"token": "t0k3n"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="registry",scope="repository:charts/podinfo:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.RequestURI() {
+		case "/v2/charts/podinfo/tags/list":
+			w.Header().Set("Link", `</v2/charts/podinfo/tags/list?last=1.0.0&n=2>; rel="next"`)
+			json.NewEncoder(w).Encode(map[string][]string{"tags": {"0.9.0", "1.0.0"}})
+		case "/v2/charts/podinfo/tags/list?last=1.0.0&n=2":
+			json.NewEncoder(w).Encode(map[string][]string{"tags": {"1.1.0_build.1", "2.0.0", "latest"}})
+		case "/v2/charts/podinfo/manifests/1.1.0_build.1":
+			assert.Equal(t, ociManifestMediaType, r.Header.Get("Accept"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"layers": []map[string]interface{}{{"mediaType": ociChartLayerMediaType, "digest": digest, "size": len(chart)}},
+			})
+		case "/v2/charts/podinfo/blobs/" + digest:
+			w.Write(chart)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	source := &helmfluxv1.RepoChartSource{RepoURL: "oci://" + strings.TrimPrefix(srv.URL, "https://") + "/charts", Name: "podinfo", Version: ">=1.0.0 <2.0.0"}
+	assert.True(t, source.IsOCI())
+	opts := downloadOptions{
+		CA:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+		Username: "flux",
+		Password: "s3cr3t",
+	}
+
+	version, err := resolveChartVersion(source, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1.0+build.1", version)
+	source.Version = version
+
+	base, err := ioutil.TempDir("", "oci")
+	assert.NoError(t, err)
+	defer os.RemoveAll(base)
+	path, err := ensureChartFetched(base, source, opts)
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, chart, b)
+	assert.Equal(t, "podinfo-1.1.0+build.1.tgz", filepath.Base(path))
+
+	// Verifying the chart needs a provenance file
+	opts.Keyring = []byte("keyring")
+	err = downloadChart(filepath.Join(base, "verified.tgz"), source, opts)
+	assert.IsType(t, verificationError{}, err)
+
+	// Without credentials, no token is handed out
+	opts.Username, opts.Password, opts.Keyring = "", "", nil
+	assert.Error(t, downloadChart(filepath.Join(base, "anonymous.tgz"), source, opts))
+}
+
+func Test_pullOCIChart_tooLarge(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A manifest without a content length that never ends
+		w.Write([]byte(`{"layers": [`))
+		for i := 0; i < ociMetadataMaxSize; i += 1024 {
+			w.Write([]byte(strings.Repeat(" ", 1024)))
+		}
+	}))
+	defer srv.Close()
+
+	source := &helmfluxv1.RepoChartSource{RepoURL: "oci://" + strings.TrimPrefix(srv.URL, "https://") + "/charts", Name: "podinfo", Version: "1.0.0"}
+	opts := downloadOptions{CA: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})}
+	base, err := ioutil.TempDir("", "oci")
+	assert.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	// The manifest is refused, even without a maximum size of charts
+	err = downloadChart(filepath.Join(base, "podinfo.tgz"), source, opts)
+	assert.IsType(t, downloadTooLargeError{}, err)
+}
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:org/a,b:pull"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{"realm": "https://ghcr.io/token", "service": "ghcr.io", "scope": "repository:org/a,b:pull"}, params)
+
+	scheme, params = parseChallenge(`Basic realm=registry`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, map[string]string{"realm": "registry"}, params)
+}
+
+func Test_registryCredentials(t *testing.T) {
+	config := `{"auths":{"https://ghcr.io":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("flux:s3cr3t")) + `"},` +
+		`"123456789.dkr.ecr.eu-west-1.amazonaws.com":{"username":"AWS","password":"t0k3n"}}}`
+	data := map[string][]byte{".dockerconfigjson": []byte(config)}
+
+	username, password, err := registryCredentials(data, "ghcr.io")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"flux", "s3cr3t"}, []string{username, password})
+	username, password, err = registryCredentials(data, "123456789.dkr.ecr.eu-west-1.amazonaws.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AWS", "t0k3n"}, []string{username, password})
+	_, _, err = registryCredentials(data, "harbor.example.com")
+	assert.Error(t, err)
+
+	username, password, err = registryCredentials(map[string][]byte{"username": []byte("flux"), "password": []byte("s3cr3t")}, "harbor.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"flux", "s3cr3t"}, []string{username, password})
+	_, _, err = registryCredentials(map[string][]byte{"username": []byte("flux")}, "harbor.example.com")
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package chartsync
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+const (
+	// PendingRecoveryNone leaves releases stuck in a pending state
+	// to be recovered by hand.
+	PendingRecoveryNone = "none"
+	// PendingRecoveryRollback rolls releases stuck in a pending state
+	// back to their last deployed revision.
+	PendingRecoveryRollback = "rollback"
+	// PendingRecoveryDelete deletes releases stuck in a pending
+	// state, so that they are installed again.
+	PendingRecoveryDelete = "delete"
+)
+
+// ValidPendingRecovery returns if the given recovery of releases stuck
+// in a pending state is supported.
+func ValidPendingRecovery(recovery string) bool {
+	return recovery == PendingRecoveryNone || recovery == PendingRecoveryRollback || recovery == PendingRecoveryDelete
+}
+
+// stuckPending returns if the given error is the result of the
+// release of the HelmRelease having an install or upgrade pending for
+// longer than the release timeout, i.e. that will not complete, and
+// the status of the release if so.
+func stuckPending(err error, timeout time.Duration, now time.Time) (hapi_release.Status_Code, bool) {
+	pending, ok := err.(*release.PendingError)
+	if !ok {
+		return 0, false
+	}
+	code := pending.Release.GetInfo().GetStatus().GetCode()
+	if code != hapi_release.Status_PENDING_INSTALL && code != hapi_release.Status_PENDING_UPGRADE {
+		return code, false
+	}
+	// NB: the release is deployed (as pending) before it is applied
+	lastDeployed := pending.Release.GetInfo().GetLastDeployed()
+	since := time.Unix(lastDeployed.GetSeconds(), int64(lastDeployed.GetNanos()))
+	return code, now.Sub(since) > timeout
+}
+
+// recoverPending recovers the release of the given HelmRelease if the
+// given error tells it is stuck in a pending state and recovery is
+// enabled, and records the outcome in the PendingRecovered condition.
+// It returns true if the release was recovered, and it can be
+// released again.
+func (chs *ChartChangeSync) recoverPending(hr helmfluxv1.HelmRelease, releaseName string, err error) bool {
+	if chs.config.PendingRecovery == "" || chs.config.PendingRecovery == PendingRecoveryNone {
+		return false
+	}
+	code, stuck := stuckPending(err, hr.GetTimeoutOr(chs.config.ReleaseTimeout), time.Now())
+	if !stuck {
+		return false
+	}
+	action := fmt.Sprintf("recover release '%s' stuck in %s", releaseName, code.String())
+	if chs.dryRunOnly(hr, helmfluxv1.HelmReleasePendingRecovered, action) {
+		return false
+	}
+
+	chs.helmOps.acquire()
+	done, err := chs.release.RecoverPending(releaseName, hr, chs.config.PendingRecovery == PendingRecoveryRollback)
+	chs.helmOps.done()
+	if err != nil {
+		msg := fmt.Sprintf("failed to recover release '%s' stuck in %s: %s", releaseName, code.String(), err)
+		chs.setCondition(hr, helmfluxv1.HelmReleasePendingRecovered, v1.ConditionFalse, ReasonPendingRecoveryFailed, msg)
+		chs.recordEvent(hr, v1.EventTypeWarning, ReasonPendingRecoveryFailed, msg)
+		chs.logger.Log("warning", "failed to recover pending release", "resource", hr.ResourceID().String(), "release", releaseName, "err", err)
+		return false
+	}
+	msg := fmt.Sprintf("release '%s' was stuck in %s, %s", releaseName, code.String(), done)
+	chs.setCondition(hr, helmfluxv1.HelmReleasePendingRecovered, v1.ConditionTrue, ReasonPendingRecovered, msg)
+	chs.recordEvent(hr, v1.EventTypeWarning, ReasonPendingRecovered, msg)
+	chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+	return true
+}
@@ -0,0 +1,38 @@
+package chartsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/stretchr/testify/assert"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+func TestStuckPending(t *testing.T) {
+	now := time.Unix(1571212800, 0)
+	pending := func(code hapi_release.Status_Code, since time.Duration) error {
+		return &release.PendingError{Release: &hapi_release.Release{Info: &hapi_release.Info{
+			Status:       &hapi_release.Status{Code: code},
+			LastDeployed: &timestamp.Timestamp{Seconds: now.Add(-since).Unix()},
+		}}}
+	}
+
+	code, stuck := stuckPending(pending(hapi_release.Status_PENDING_UPGRADE, 10*time.Minute), 5*time.Minute, now)
+	assert.True(t, stuck)
+	assert.Equal(t, hapi_release.Status_PENDING_UPGRADE, code)
+	_, stuck = stuckPending(pending(hapi_release.Status_PENDING_INSTALL, 10*time.Minute), 5*time.Minute, now)
+	assert.True(t, stuck)
+
+	// An install or upgrade may still complete within the timeout
+	_, stuck = stuckPending(pending(hapi_release.Status_PENDING_UPGRADE, time.Minute), 5*time.Minute, now)
+	assert.False(t, stuck)
+	// Pending rollbacks are left be
+	_, stuck = stuckPending(pending(hapi_release.Status_PENDING_ROLLBACK, 10*time.Minute), 5*time.Minute, now)
+	assert.False(t, stuck)
+	_, stuck = stuckPending(errors.New("release not found"), 5*time.Minute, now)
+	assert.False(t, stuck)
+}
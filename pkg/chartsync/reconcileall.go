@@ -0,0 +1,217 @@
+package chartsync
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// The outcomes of the reconciliation of a HelmRelease by ReconcileAll.
+const (
+	OutcomeInstalled = "Installed"
+	OutcomeUpgraded  = "Upgraded"
+	OutcomeDeleted   = "Deleted"
+	// OutcomeFailed means the release failed, including when it
+	// was rolled back after a failed upgrade or failed tests.
+	OutcomeFailed  = "Failed"
+	OutcomeSkipped = "Skipped"
+	// OutcomeUnchanged means the release was up to date.
+	OutcomeUnchanged = "Unchanged"
+)
+
+// The reasons of skipped reconciliations that do not set a condition.
+const (
+	ReasonReconcileInProgress = "ReconcileInProgress"
+	ReasonBackingOff          = "BackingOff"
+	ReasonShuttingDown        = "ShuttingDown"
+	ReasonNotSelected         = "NotSelected"
+)
+
+// ReconcileResult is the outcome of the reconciliation of a single
+// HelmRelease by ReconcileAll.
+type ReconcileResult struct {
+	// Resource is the ID of the HelmRelease, as
+	// '<namespace>:helmrelease/<name>'.
+	Resource string
+	Outcome  string
+	// Reason and Message are those of the last action or condition
+	// that determined the outcome, if any.
+	Reason  string
+	Message string
+}
+
+// ReconcileSummary is the outcome of ReconcileAll.
+type ReconcileSummary struct {
+	// Results has the result of every HelmRelease, ordered by
+	// resource ID.
+	Results []ReconcileResult
+	// Counts has the number of results by outcome.
+	Counts map[string]int
+}
+
+// ReconcileAll reconciles all HelmReleases we know about that match
+// the release selector, as ReconcileReleaseDef does, with at most the
+// given number of reconciliations running at once, and returns a
+// summary of the outcomes once all are done. The bound on concurrent
+// Helm operations applies as it does to any reconciliation, and a
+// HelmRelease that is being reconciled already is skipped.
+func (chs *ChartChangeSync) ReconcileAll(workers int) (ReconcileSummary, error) {
+	list, err := chs.selectedReleases()
+	if err != nil {
+		return ReconcileSummary{}, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	summary := ReconcileSummary{Counts: make(map[string]int)}
+	var mu sync.Mutex
+
+	hrs := make(chan helmfluxv1.HelmRelease)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hr := range hrs {
+				result := chs.reconcileCollected(hr)
+				mu.Lock()
+				summary.Results = append(summary.Results, result)
+				summary.Counts[result.Outcome]++
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, hr := range list {
+		hrs <- *hr
+	}
+	close(hrs)
+	wg.Wait()
+
+	sort.Slice(summary.Results, func(i, j int) bool {
+		return summary.Results[i].Resource < summary.Results[j].Resource
+	})
+	return summary, nil
+}
+
+// reconcileCollected reconciles the given HelmRelease as
+// ReconcileReleaseDef does, and returns the outcome collected from the
+// actions and conditions this reconciliation recorded.
+func (chs *ChartChangeSync) reconcileCollected(hr helmfluxv1.HelmRelease) ReconcileResult {
+	result := &ReconcileResult{Resource: hr.ResourceID().String()}
+	chs.reconcileInto(hr, result)
+	if result.Outcome == "" {
+		result.Outcome = OutcomeUnchanged
+	}
+	return *result
+}
+
+// collectInto collects the actions and conditions recorded for the
+// given HelmRelease into the given result, if not nil, until the
+// returned func is called. It is only called by the reconciliation
+// that holds the HelmRelease (see startReconciling), so that nothing
+// is collected from another one.
+func (chs *ChartChangeSync) collectInto(hr helmfluxv1.HelmRelease, result *ReconcileResult) func() {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if result == nil || err != nil {
+		return func() {}
+	}
+	chs.resultsMu.Lock()
+	if chs.results == nil {
+		chs.results = make(map[string]*ReconcileResult)
+	}
+	chs.results[key] = result
+	chs.resultsMu.Unlock()
+	return func() {
+		chs.resultsMu.Lock()
+		delete(chs.results, key)
+		chs.resultsMu.Unlock()
+	}
+}
+
+// skip records that the reconciliation was skipped for the given
+// reason, unless the result has an outcome already or is nil.
+func (r *ReconcileResult) skip(reason string) {
+	if r != nil && r.Outcome == "" {
+		r.Outcome, r.Reason = OutcomeSkipped, reason
+	}
+}
+
+// collectResult applies the given change to the result collected for
+// the given HelmRelease, if ReconcileAll is collecting one.
+func (chs *ChartChangeSync) collectResult(hr helmfluxv1.HelmRelease, change func(*ReconcileResult)) {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return
+	}
+	chs.resultsMu.Lock()
+	defer chs.resultsMu.Unlock()
+	if result, ok := chs.results[key]; ok {
+		change(result)
+	}
+}
+
+// collectAction collects the outcome of the given action recorded
+// for the given HelmRelease. Every action determines the outcome, as
+// it follows those recorded before it; a rollback, even a successful
+// one, means the release failed.
+func (chs *ChartChangeSync) collectAction(hr helmfluxv1.HelmRelease, action helmfluxv1.HelmReleaseAction, reason string) {
+	chs.collectResult(hr, func(result *ReconcileResult) {
+		result.Reason, result.Message = reason, ""
+		switch {
+		case action == helmfluxv1.HelmReleaseActionSkip:
+			result.Outcome = OutcomeSkipped
+		case action == helmfluxv1.HelmReleaseActionRollback:
+			result.Outcome = OutcomeFailed
+			if reason == ReasonSuccess {
+				result.Reason, result.Message = status.ReasonRolledBack, "release was rolled back"
+			}
+		case reason != ReasonSuccess:
+			result.Outcome = OutcomeFailed
+		case action == helmfluxv1.HelmReleaseActionInstall:
+			result.Outcome = OutcomeInstalled
+		case action == helmfluxv1.HelmReleaseActionUpgrade:
+			result.Outcome = OutcomeUpgraded
+		}
+	})
+}
+
+// collectCondition collects the outcome signalled by the given
+// condition set for the given HelmRelease: a failure, or a release
+// that is skipped because it waits for something, or only does dry
+// runs. Conditions signalling success leave the outcome to the
+// actions.
+func (chs *ChartChangeSync) collectCondition(hr helmfluxv1.HelmRelease, typ helmfluxv1.HelmReleaseConditionType, st v1.ConditionStatus, reason, message string) {
+	chs.collectResult(hr, func(result *ReconcileResult) {
+		switch {
+		case typ == helmfluxv1.HelmReleaseDeleted && st == v1.ConditionTrue:
+			result.Outcome = OutcomeDeleted
+		case st == v1.ConditionFalse && waitReasons[reason], st == v1.ConditionUnknown && reason == ReasonDryRunOnly:
+			if result.Outcome != "" {
+				return
+			}
+			result.Outcome = OutcomeSkipped
+		case st == v1.ConditionFalse && typ != helmfluxv1.HelmReleaseDriftCorrected:
+			result.Outcome = OutcomeFailed
+		default:
+			return
+		}
+		result.Reason, result.Message = reason, message
+	})
+}
+
+// collectSkip collects that the reconciliation of the given
+// HelmRelease was skipped for the given reason, unless it has an
+// outcome already.
+func (chs *ChartChangeSync) collectSkip(hr helmfluxv1.HelmRelease, reason string) {
+	chs.collectResult(hr, func(result *ReconcileResult) {
+		if result.Outcome == "" {
+			result.Outcome, result.Reason = OutcomeSkipped, reason
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+func TestReconcileAll(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, name := range []string{"podinfo", "nginx"} {
+		indexer.Add(&helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+	}
+	chs := &ChartChangeSync{
+		logger:       log.NewNopLogger(),
+		hrLister:     iflister.NewHelmReleaseLister(indexer),
+		reconciling:  make(map[string]*helmfluxv1.HelmRelease),
+		shuttingDown: true,
+	}
+
+	summary, err := chs.ReconcileAll(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []ReconcileResult{
+		{Resource: "default:helmrelease/nginx", Outcome: OutcomeSkipped, Reason: ReasonShuttingDown},
+		{Resource: "default:helmrelease/podinfo", Outcome: OutcomeSkipped, Reason: ReasonShuttingDown},
+	}, summary.Results)
+	assert.Equal(t, map[string]int{OutcomeSkipped: 2}, summary.Counts)
+	assert.Empty(t, chs.results)
+}
+
+func TestReconcileCollected_inProgress(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"}}
+	chs := &ChartChangeSync{
+		logger:      log.NewNopLogger(),
+		reconciling: make(map[string]*helmfluxv1.HelmRelease),
+	}
+
+	// What the reconciliation that is in progress records is not
+	// collected for the one that is skipped
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		chs.reconcileCoalesced(hr, nil, func(hr helmfluxv1.HelmRelease) {
+			select {
+			case <-started:
+			default:
+				close(started)
+				<-finish
+			}
+			chs.collectAction(hr, helmfluxv1.HelmReleaseActionUpgrade, ReasonSuccess)
+		})
+		close(done)
+	}()
+	<-started
+	result := chs.reconcileCollected(hr)
+	close(finish)
+	<-done
+	assert.Equal(t, ReconcileResult{Resource: "default:helmrelease/podinfo", Outcome: OutcomeSkipped, Reason: ReasonReconcileInProgress}, result)
+	assert.Empty(t, chs.results)
+}
+
+func TestCollectResult(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"}}
+	chs := &ChartChangeSync{}
+	collect := func(record func()) ReconcileResult {
+		result := &ReconcileResult{}
+		chs.results = map[string]*ReconcileResult{"default/podinfo": result}
+		record()
+		return *result
+	}
+
+	// Nothing is collected unless a result is being collected
+	chs.collectAction(hr, helmfluxv1.HelmReleaseActionInstall, ReasonSuccess)
+
+	assert.Equal(t, ReconcileResult{Outcome: OutcomeInstalled, Reason: ReasonSuccess}, collect(func() {
+		chs.collectCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, ReasonSuccess, "release was successful")
+		chs.collectAction(hr, helmfluxv1.HelmReleaseActionInstall, ReasonSuccess)
+	}))
+	// A rolled back release failed
+	assert.Equal(t, ReconcileResult{Outcome: OutcomeFailed, Reason: status.ReasonRolledBack, Message: "release was rolled back"}, collect(func() {
+		chs.collectAction(hr, helmfluxv1.HelmReleaseActionUpgrade, ReasonSuccess)
+		chs.collectCondition(hr, helmfluxv1.HelmReleaseTested, v1.ConditionFalse, ReasonTestFailed, "test failed")
+		chs.collectAction(hr, helmfluxv1.HelmReleaseActionRollback, ReasonSuccess)
+	}))
+	assert.Equal(t, ReconcileResult{Outcome: OutcomeFailed, Reason: ReasonUpgradeFailed, Message: "timed out"}, collect(func() {
+		chs.collectCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonUpgradeFailed, "timed out")
+	}))
+	assert.Equal(t, ReconcileResult{Outcome: OutcomeSkipped, Reason: ReasonDependencyNotReady, Message: "waiting"}, collect(func() {
+		chs.collectCondition(hr, helmfluxv1.HelmReleaseReleased, v1.ConditionFalse, ReasonDependencyNotReady, "waiting")
+		chs.collectSkip(hr, ReasonBackingOff)
+	}))
+	assert.Equal(t, ReconcileResult{}, collect(func() {
+		chs.collectCondition(hr, helmfluxv1.HelmReleaseDriftCorrected, v1.ConditionFalse, ReasonDriftCorrectionFailed, "forbidden")
+	}))
+}
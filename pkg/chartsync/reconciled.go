@@ -0,0 +1,163 @@
+package chartsync
+
+import (
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/helm/pkg/chartutil"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// reconcileInputs are the inputs that determine the outcome of the
+// dry run we do to determine if a release should be upgraded. As
+// long as none of them change, the outcome will not either.
+type reconcileInputs struct {
+	generation     int64
+	chartRevision  string
+	valuesChecksum string
+	// releaseVersion is the version of the Helm release, which
+	// changes when the release is upgraded or rolled back by
+	// other means
+	releaseVersion int32
+}
+
+// inputsFor returns the reconcile inputs for the given HelmRelease,
+// chart and release.
+func (chs *ChartChangeSync) inputsFor(hr helmfluxv1.HelmRelease, chartPath, chartRevision string, rel *hapi_release.Release) (reconcileInputs, error) {
+	checksum, err := chs.valuesChecksum(hr, chartPath)
+	if err != nil {
+		return reconcileInputs{}, err
+	}
+	return reconcileInputs{
+		generation:     hr.Generation,
+		chartRevision:  chartRevision,
+		valuesChecksum: checksum,
+		releaseVersion: rel.GetVersion(),
+	}, nil
+}
+
+// unchangedSinceReconcile returns if the given inputs are the same
+// as the inputs recorded for the last successful reconciliation of
+// the HelmRelease. A HelmRelease of which a reconciliation was
+// requested has always changed, so that the dry run is done.
+func (chs *ChartChangeSync) unchangedSinceReconcile(hr helmfluxv1.HelmRelease, inputs reconcileInputs) bool {
+	if _, requested := hr.ReconcileRequested(); requested {
+		return false
+	}
+	last, ok := chs.lastReconciled(hr)
+	return ok && last == inputs
+}
+
+// lastReconciled returns the inputs recorded for the last successful
+// reconciliation of the HelmRelease, if any.
+func (chs *ChartChangeSync) lastReconciled(hr helmfluxv1.HelmRelease) (reconcileInputs, bool) {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return reconcileInputs{}, false
+	}
+	chs.reconciledMu.Lock()
+	defer chs.reconciledMu.Unlock()
+	last, ok := chs.reconciled[key]
+	return last, ok
+}
+
+// changedSinceReconcile returns true if the generation of the given
+// HelmRelease, the chart revision, or the checksum of the values have
+// changed since the last successful reconciliation, without doing a
+// dry run. Changes made to the release by other means are therefore
+// not detected.
+//
+// Without a record of the last reconciliation (e.g. after a restart),
+// the chart revision and the values checksum in the status of the
+// HelmRelease are used, and the current generation is taken as the
+// one released. A HelmRelease of which a reconciliation was requested
+// has always changed, as nothing else would make it upgrade.
+func (chs *ChartChangeSync) changedSinceReconcile(hr helmfluxv1.HelmRelease, chartPath, chartRevision string, rel *hapi_release.Release) (bool, error) {
+	if _, requested := hr.ReconcileRequested(); requested {
+		return true, nil
+	}
+	inputs, err := chs.inputsFor(hr, chartPath, chartRevision, rel)
+	if err != nil {
+		return false, err
+	}
+	if last, ok := chs.lastReconciled(hr); ok {
+		return last.generation != inputs.generation ||
+			last.chartRevision != inputs.chartRevision ||
+			last.valuesChecksum != inputs.valuesChecksum, nil
+	}
+	if hr.Status.Revision != inputs.chartRevision || !chs.matchesRecordedChecksum(hr, chartPath, inputs.valuesChecksum) {
+		return true, nil
+	}
+	chs.recordReconciled(hr, inputs)
+	return false, nil
+}
+
+// recordReconciled records the inputs of a successful reconciliation
+// of the HelmRelease.
+func (chs *ChartChangeSync) recordReconciled(hr helmfluxv1.HelmRelease, inputs reconcileInputs) {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return
+	}
+	chs.reconciledMu.Lock()
+	chs.reconciled[key] = inputs
+	chs.reconciledMu.Unlock()
+}
+
+// forgetReconciled forgets the inputs recorded for the HelmRelease.
+func (chs *ChartChangeSync) forgetReconciled(hr helmfluxv1.HelmRelease) {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return
+	}
+	chs.reconciledMu.Lock()
+	delete(chs.reconciled, key)
+	chs.reconciledMu.Unlock()
+}
+
+// valuesChecksum composes the values for the release of the given
+// HelmRelease and returns their checksum.
+func (chs *ChartChangeSync) valuesChecksum(hr helmfluxv1.HelmRelease, chartPath string) (string, error) {
+	values, sensitive, err := chs.composeValues(hr, chartPath)
+	if err != nil {
+		return "", err
+	}
+	return sensitive.Checksum(values)
+}
+
+// matchesRecordedChecksum returns true if the values checksum in the
+// status of the HelmRelease is the given checksum of the values for
+// its release, or their checksum as calculated by earlier versions,
+// in which case the status is brought up to date with the given one.
+func (chs *ChartChangeSync) matchesRecordedChecksum(hr helmfluxv1.HelmRelease, chartPath, checksum string) bool {
+	if hr.Status.ValuesChecksum == checksum {
+		return true
+	}
+	values, _, err := chs.composeValues(hr, chartPath)
+	if err != nil {
+		return false
+	}
+	if legacy, err := release.LegacyValuesChecksum(values); err != nil || hr.Status.ValuesChecksum != legacy {
+		return false
+	}
+	if err := status.SetValuesChecksum(chs.statusClient(hr), hr, checksum); err != nil {
+		chs.logger.Log("warning", "failed to update values checksum of HelmRelease", "resource", hr.ResourceID().String(), "err", err)
+	}
+	return true
+}
+
+// composeValues composes the values for the release of the given
+// HelmRelease, and returns them with the sensitive values among them.
+func (chs *ChartChangeSync) composeValues(hr helmfluxv1.HelmRelease, chartPath string) (chartutil.Values, release.SensitiveValues, error) {
+	specValues, err := chs.release.SpecValues(chs.kubeClient.CoreV1(), hr)
+	if err != nil {
+		return nil, release.SensitiveValues{}, err
+	}
+	valuesFrom, err := hr.GetValuesFromSources()
+	if err != nil {
+		return nil, release.SensitiveValues{}, err
+	}
+	return release.ComposeValues(chs.kubeClient.CoreV1(), hr.Namespace, chartPath, valuesFrom, specValues, hr.Spec.ValuesOverrides)
+}
@@ -0,0 +1,97 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/helm/pkg/chartutil"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+func TestChangedSinceReconcile(t *testing.T) {
+	chs := &ChartChangeSync{reconciled: make(map[string]reconcileInputs)}
+	rel := &hapi_release.Release{Version: 1}
+
+	values := chartutil.Values{"replicas": 1}
+	str, _ := values.YAML()
+	checksum := release.ValuesChecksum([]byte(str))
+
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Generation: 1},
+		Spec:       helmfluxv1.HelmReleaseSpec{HelmValues: helmfluxv1.HelmValues{Values: values}},
+		Status:     helmfluxv1.HelmReleaseStatus{Revision: "1.0.0", ValuesChecksum: checksum},
+	}
+
+	// Without a record, the status is compared
+	changed, err := chs.changedSinceReconcile(hr, "", "1.1.0", rel)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = chs.changedSinceReconcile(hr, "", "1.0.0", rel)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// A release upgraded by other means is not detected
+	changed, err = chs.changedSinceReconcile(hr, "", "1.0.0", &hapi_release.Release{Version: 2})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// A new generation is detected against the record
+	hr.Generation = 2
+	changed, err = chs.changedSinceReconcile(hr, "", "1.0.0", rel)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	// As are changed values
+	hr.Generation = 1
+	hr.Spec.Values = chartutil.Values{"replicas": 2}
+	changed, err = chs.changedSinceReconcile(hr, "", "1.0.0", rel)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	// A requested reconciliation always upgrades, until handled
+	hr.Spec.Values = values
+	inputs, err := chs.inputsFor(hr, "", "1.0.0", rel)
+	assert.NoError(t, err)
+	chs.recordReconciled(hr, inputs)
+	assert.True(t, chs.unchangedSinceReconcile(hr, inputs))
+	hr.Annotations = map[string]string{helmfluxv1.ReconcileRequestAnnotation: "1602720000"}
+	assert.False(t, chs.unchangedSinceReconcile(hr, inputs))
+	changed, err = chs.changedSinceReconcile(hr, "", "1.0.0", rel)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	hr.Status.LastHandledReconcileAt = "1602720000"
+	assert.True(t, chs.unchangedSinceReconcile(hr, inputs))
+	changed, err = chs.changedSinceReconcile(hr, "", "1.0.0", rel)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestMatchesRecordedChecksum_legacy(t *testing.T) {
+	values := chartutil.Values{"replicas": 1}
+	legacy, err := release.LegacyValuesChecksum(values)
+	assert.NoError(t, err)
+
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Generation: 1},
+		Spec:       helmfluxv1.HelmReleaseSpec{HelmValues: helmfluxv1.HelmValues{Values: values}},
+		Status:     helmfluxv1.HelmReleaseStatus{Revision: "1.0.0", ValuesChecksum: legacy},
+	}
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+	chs := &ChartChangeSync{logger: log.NewNopLogger(), ifClient: ifClient}
+
+	// The checksum recorded by an earlier version is recognised, and
+	// replaced by the current one (e.g. that of values with secrets)
+	assert.True(t, chs.matchesRecordedChecksum(hr, "", "current"))
+	assert.Equal(t, "current", srv.get().Status.ValuesChecksum)
+
+	hr.Spec.Values = chartutil.Values{"replicas": 2}
+	assert.False(t, chs.matchesRecordedChecksum(hr, "", "current"))
+}
@@ -0,0 +1,90 @@
+package chartsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	"github.com/fluxcd/helm-operator/pkg/api"
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// RenderRelease returns the manifests (including those of the hooks)
+// the release of the HelmRelease with the given namespace and name
+// would apply, as rendered by a dry run of the release, with its
+// sensitive values redacted. Nothing is released, and the status of
+// the HelmRelease is left untouched.
+func (chs *ChartChangeSync) RenderRelease(namespace, name string) (string, error) {
+	// The manifests may contain secrets
+	if !chs.config.AllowRenderRelease {
+		return "", api.ErrRenderReleaseDisabled
+	}
+
+	hr, err := chs.hrLister.HelmReleases(namespace).Get(name)
+	if err != nil {
+		return "", err
+	}
+	_, rel, redact, err := chs.dryRunRelease(*hr)
+	if err != nil {
+		return "", err
+	}
+	return redact(renderedManifests(rel)), nil
+}
+
+// dryRunRelease returns the current release of the given HelmRelease,
+// if any, and the release that releasing the HelmRelease now would
+// result in, as rendered by a dry run of the upgrade (or install) of
+// the release under its own name, with a func that redacts the
+// sensitive values of the HelmRelease from text. Nothing is released,
+// and the status of the HelmRelease is left untouched.
+func (chs *ChartChangeSync) dryRunRelease(hr helmfluxv1.HelmRelease) (*hapi_release.Release, *hapi_release.Release, func(string) string, error) {
+	chartPath, _, done, ok := chs.fetchChart(hr, false)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("chart of HelmRelease %s is not available, see its conditions for why", hr.ResourceID().String())
+	}
+	defer done()
+
+	hr = chs.WithReleaseDefaults(hr)
+	releaseName := chs.release.ReleaseName(hr)
+	curr, err := chs.release.GetUpgradableRelease(releaseName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	opts := chs.installOptions(hr, true)
+	action := release.UpgradeAction
+	if curr == nil {
+		// The release may have been deleted without being purged
+		action, opts.ReuseName = release.InstallAction, true
+	}
+	// The chart is held on to for the dry run, so do not wait for
+	// it longer than for the dry run of a reconciliation.
+	ctx, cancel := context.WithTimeout(context.Background(), chs.config.DryRunTimeout)
+	defer cancel()
+	des, _, err := chs.release.InstallContext(ctx, chartPath, releaseName, hr, action, opts, &chs.kubeClient)
+	if err == context.DeadlineExceeded {
+		return nil, nil, nil, fmt.Errorf("dry run did not complete within %s", chs.config.DryRunTimeout)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return curr, des, chs.redactor(hr, chartPath), nil
+}
+
+// renderedManifests returns the manifests of the release, followed by
+// the manifests of its hooks, sorted by their path in the chart.
+func renderedManifests(rel *hapi_release.Release) string {
+	var b strings.Builder
+	if manifest := strings.TrimSpace(rel.GetManifest()); manifest != "" {
+		b.WriteString(manifest + "\n")
+	}
+	hooks := append([]*hapi_release.Hook(nil), rel.GetHooks()...)
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].Path < hooks[j].Path })
+	for _, hook := range hooks {
+		fmt.Fprintf(&b, "---\n# Source: %s\n%s\n", hook.Path, strings.TrimSpace(hook.Manifest))
+	}
+	return b.String()
+}
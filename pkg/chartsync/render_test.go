@@ -0,0 +1,30 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+)
+
+func Test_renderedManifests(t *testing.T) {
+	rel := &hapi_release.Release{
+		Manifest: "\n---\n# Source: podinfo/templates/service.yaml\nkind: Service\n",
+		Hooks: []*hapi_release.Hook{
+			{Path: "podinfo/templates/tests/test.yaml", Manifest: "kind: Pod\n"},
+			{Path: "podinfo/templates/crds.yaml", Manifest: "kind: CustomResourceDefinition\n"},
+		},
+	}
+	assert.Equal(t, `---
+# Source: podinfo/templates/service.yaml
+kind: Service
+---
+# Source: podinfo/templates/crds.yaml
+kind: CustomResourceDefinition
+---
+# Source: podinfo/templates/tests/test.yaml
+kind: Pod
+`, renderedManifests(rel))
+
+	assert.Equal(t, "", renderedManifests(&hapi_release.Release{}))
+}
@@ -0,0 +1,26 @@
+package chartsync
+
+import (
+	"fmt"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// rollbackSkipped returns why the failed upgrade of the given
+// HelmRelease, which failed with the given error and reason, is not
+// to be rolled back, or an empty string if it is (when rollbacks are
+// enabled). Upgrades that failed before anything was applied are not
+// rolled back, as there is nothing to roll back.
+func rollbackSkipped(hr helmfluxv1.HelmRelease, err error, reason string) string {
+	if !hr.Spec.Rollback.Enabled() {
+		return ""
+	}
+	if release.IsPreApply(err) {
+		return "the upgrade failed before anything was applied"
+	}
+	if hr.Spec.Rollback.DisabledFor(reason) {
+		return fmt.Sprintf("rollbacks are disabled for upgrades that failed with reason '%s'", reason)
+	}
+	return ""
+}
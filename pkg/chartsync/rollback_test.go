@@ -0,0 +1,189 @@
+package chartsync
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8shelm "k8s.io/helm/pkg/helm"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	ifclientset "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+func Test_rollbackSkipped(t *testing.T) {
+	enabled, disabled := true, false
+	hr := helmfluxv1.HelmRelease{
+		Spec: helmfluxv1.HelmReleaseSpec{
+			Rollback: helmfluxv1.Rollback{Enable: &enabled, DisableOnReasons: []string{ReasonTimeout}},
+		},
+	}
+	applyErr := errors.New("upgrade failed")
+
+	assert.Empty(t, rollbackSkipped(hr, applyErr, ReasonUpgradeFailed))
+	assert.Equal(t, "rollbacks are disabled for upgrades that failed with reason 'HelmTimeout'",
+		rollbackSkipped(hr, applyErr, ReasonTimeout))
+
+	rel := release.New(log.NewNopLogger(), &k8shelm.FakeClient{}, nil, helmfluxv1.ReleaseNameStrategyDefault)
+	_, _, preApplyErr := rel.Install("", "podinfo", hr, release.UpgradeAction, release.InstallOptions{}, fake.NewSimpleClientset())
+	assert.Error(t, preApplyErr)
+	assert.Equal(t, "the upgrade failed before anything was applied", rollbackSkipped(hr, preApplyErr, ReasonUpgradeFailed))
+
+	hr.Spec.Rollback.Enable = &disabled
+	assert.Empty(t, rollbackSkipped(hr, preApplyErr, ReasonUpgradeFailed))
+}
+
+// helmReleaseServer serves a single HelmRelease, and keeps the
+// updates made to its status, for a (non-fake) clientset to talk to.
+type helmReleaseServer struct {
+	mu sync.Mutex
+	hr helmfluxv1.HelmRelease
+}
+
+func (s *helmReleaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/status") {
+		var hr helmfluxv1.HelmRelease
+		if err := json.NewDecoder(r.Body).Decode(&hr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.hr.Status = hr.Status
+	}
+	json.NewEncoder(w).Encode(s.hr)
+}
+
+// newHelmReleaseServer starts a helmReleaseServer serving the given
+// HelmRelease, and returns it with a clientset talking to it, and a
+// func to stop it.
+func newHelmReleaseServer(t *testing.T, hr helmfluxv1.HelmRelease) (*helmReleaseServer, ifclientset.Clientset, func()) {
+	srv := &helmReleaseServer{hr: hr}
+	httpSrv := httptest.NewServer(srv)
+	ifClient, err := ifclientset.NewForConfig(&rest.Config{Host: httpSrv.URL})
+	if err != nil {
+		httpSrv.Close()
+		t.Fatal(err)
+	}
+	return srv, *ifClient, httpSrv.Close
+}
+
+func (s *helmReleaseServer) get() helmfluxv1.HelmRelease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hr
+}
+
+func Test_rollbackRelease(t *testing.T) {
+	enabled := true
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+		Spec:       helmfluxv1.HelmReleaseSpec{Rollback: helmfluxv1.Rollback{Enable: &enabled}},
+	}
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		release:  release.New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		ifClient: ifClient,
+	}
+
+	// A failed rollback is not reported as successful
+	chs.rollbackRelease(hr, "1.0.0", "checksum", func(string, helmfluxv1.HelmRelease) (*hapi_release.Release, error) {
+		return nil, errors.New("rollback failed")
+	})
+	cond := status.GetCondition(srv.get().Status, helmfluxv1.HelmReleaseRolledBack)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, v1.ConditionFalse, cond.Status)
+		assert.Equal(t, ReasonRollbackFailed, cond.Reason)
+		assert.Equal(t, "rollback failed", cond.Message)
+	}
+
+	chs.rollbackRelease(hr, "1.0.0", "checksum", func(string, helmfluxv1.HelmRelease) (*hapi_release.Release, error) {
+		return &hapi_release.Release{}, nil
+	})
+	cond = status.GetCondition(srv.get().Status, helmfluxv1.HelmReleaseRolledBack)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, v1.ConditionTrue, cond.Status)
+		assert.Equal(t, ReasonSuccess, cond.Reason)
+	}
+}
+
+func TestUpgradeRetriesExhausted(t *testing.T) {
+	maxRetries, enabled := int64(1), true
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Generation: 2},
+		Spec:       helmfluxv1.HelmReleaseSpec{Rollback: helmfluxv1.Rollback{Enable: &enabled, MaxRetries: &maxRetries}},
+	}
+	srv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		release:  release.New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		ifClient: ifClient,
+		config:   Config{StalledThreshold: 10},
+	}
+	rollback := func(string, helmfluxv1.HelmRelease) (*hapi_release.Release, error) {
+		return &hapi_release.Release{}, nil
+	}
+
+	checksum, err := chs.valuesChecksum(hr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed upgrade is retried once
+	chs.rollbackRelease(hr, "1.0.0", checksum, rollback)
+	assert.Equal(t, int64(1), srv.get().Status.RollbackCount)
+	assert.False(t, chs.upgradeRetriesExhausted(srv.get(), "default-podinfo", "", "1.0.0"))
+
+	chs.rollbackRelease(srv.get(), "1.0.0", checksum, rollback)
+	assert.Equal(t, int64(2), srv.get().Status.RollbackCount)
+	assert.True(t, chs.upgradeRetriesExhausted(srv.get(), "default-podinfo", "", "1.0.0"))
+	for _, conditionType := range []helmfluxv1.HelmReleaseConditionType{helmfluxv1.HelmReleaseReleased, helmfluxv1.HelmReleaseStalled} {
+		cond := status.GetCondition(srv.get().Status, conditionType)
+		if assert.NotNil(t, cond, string(conditionType)) {
+			assert.Equal(t, ReasonUpgradeRetriesExhausted, cond.Reason)
+			assert.Equal(t, "upgrade of release 'default-podinfo' failed and was rolled back 2 times, not attempting it again until the HelmRelease, its chart or its values change", cond.Message)
+		}
+	}
+
+	// A new chart revision is upgraded again, and its rollbacks are
+	// counted from scratch
+	assert.False(t, chs.upgradeRetriesExhausted(srv.get(), "default-podinfo", "", "1.0.1"))
+	chs.rollbackRelease(srv.get(), "1.0.1", checksum, rollback)
+	assert.Equal(t, int64(1), srv.get().Status.RollbackCount)
+	assert.Equal(t, "1.0.1", srv.get().Status.RollbackRevision)
+
+	// So are new values
+	chs.rollbackRelease(srv.get(), "1.0.1", checksum, rollback)
+	changed := srv.get()
+	changed.Spec.Values = map[string]interface{}{"replicaCount": 2}
+	assert.False(t, chs.upgradeRetriesExhausted(changed, "default-podinfo", "", "1.0.1"))
+
+	// And a new generation
+	changed = srv.get()
+	changed.Generation = 3
+	assert.False(t, chs.upgradeRetriesExhausted(changed, "default-podinfo", "", "1.0.1"))
+	chs.rollbackRelease(changed, "1.0.1", checksum, rollback)
+	assert.Equal(t, int64(1), srv.get().Status.RollbackCount)
+	assert.Equal(t, int64(3), srv.get().Status.RollbackGeneration)
+}
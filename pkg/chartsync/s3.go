@@ -0,0 +1,338 @@
+package chartsync
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The keys of a secret with the credentials for an S3 bucket.
+const (
+	s3AccessKeyIDKey     = "accessKeyID"
+	s3SecretAccessKeyKey = "secretAccessKey"
+	s3SessionTokenKey    = "sessionToken"
+	s3RegionKey          = "region"
+	// s3EndpointKey is the key of the URL of an S3 compatible
+	// service (e.g. MinIO) to use instead of AWS.
+	s3EndpointKey = "endpoint"
+)
+
+// s3DefaultRegion is the region of buckets for which none is
+// configured; buckets in other regions redirect us to theirs.
+const s3DefaultRegion = "us-east-1"
+
+// stsEndpoint is the endpoint of the AWS Security Token Service that
+// web identity tokens are exchanged at.
+var stsEndpoint = "https://sts.amazonaws.com/"
+
+// awsCredentials are the credentials to sign AWS requests with.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	// expires is when temporary credentials expire
+	expires time.Time
+}
+
+// s3Getter gets the objects of S3 buckets, as `s3://<bucket>/<key>`
+// URLs, signing its requests with the credentials of the bucket
+// secret or, if the operator allows it, from the environment or of
+// the IAM role of the service account of the operator (IRSA), in that
+// order. Without credentials, requests are not signed, which suits
+// public buckets.
+type s3Getter struct {
+	client   *http.Client
+	creds    func() (*awsCredentials, error)
+	region   string
+	endpoint *url.URL
+}
+
+// newS3Getter returns an s3Getter with the given client, configured
+// with the given bucket secret data, if any. The credentials of the
+// operator are only used if allowed, and never with a custom
+// endpoint, as the requests would be signed for whoever runs it.
+func newS3Getter(client *http.Client, bucket map[string][]byte, allowOperatorCredentials bool) (*s3Getter, error) {
+	g := &s3Getter{client: client, region: string(bucket[s3RegionKey])}
+	if g.region == "" {
+		g.region = os.Getenv("AWS_REGION")
+	}
+	if g.region == "" {
+		g.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if g.region == "" {
+		g.region = s3DefaultRegion
+	}
+	if endpoint := string(bucket[s3EndpointKey]); endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+		}
+		g.endpoint = u
+	}
+
+	switch accessKeyID, secretAccessKey := string(bucket[s3AccessKeyIDKey]), string(bucket[s3SecretAccessKeyKey]); {
+	case accessKeyID != "" || secretAccessKey != "":
+		if accessKeyID == "" || secretAccessKey == "" {
+			return nil, fmt.Errorf("bucket secret must have both '%s' and '%s'", s3AccessKeyIDKey, s3SecretAccessKeyKey)
+		}
+		creds := &awsCredentials{accessKeyID: accessKeyID, secretAccessKey: secretAccessKey, sessionToken: string(bucket[s3SessionTokenKey])}
+		g.creds = func() (*awsCredentials, error) { return creds, nil }
+	case !allowOperatorCredentials || g.endpoint != nil:
+		g.creds = func() (*awsCredentials, error) { return nil, nil }
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		creds := &awsCredentials{
+			accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		g.creds = func() (*awsCredentials, error) { return creds, nil }
+	case os.Getenv("AWS_ROLE_ARN") != "" && os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "":
+		roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		g.creds = func() (*awsCredentials, error) {
+			return webIdentity.get(client, roleARN, tokenFile)
+		}
+	default:
+		g.creds = func() (*awsCredentials, error) { return nil, nil }
+	}
+	return g, nil
+}
+
+// Get gets the object at the given URL.
+func (g *s3Getter) Get(href string) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	return buf, g.download(href, buf, 0)
+}
+
+// download gets the object at the given URL and writes it to the
+// given writer, aborting once it exceeds the maximum size if that is
+// above zero.
+func (g *s3Getter) download(href string, w io.Writer, maxSize int64) error {
+	u, err := url.Parse(href)
+	if err != nil {
+		return err
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return fmt.Errorf("invalid S3 URL %q, expected s3://<bucket>/<key>", href)
+	}
+
+	resp, err := g.get(bucket, key, g.region)
+	if err != nil {
+		return err
+	}
+	// Buckets in another region tell us theirs
+	if region := resp.Header.Get("X-Amz-Bucket-Region"); resp.StatusCode != http.StatusOK && region != "" && region != g.region {
+		resp.Body.Close()
+		if resp, err = g.get(bucket, key, region); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to fetch %s : %s", href, resp.Status)
+	}
+	return copyBody(w, resp, href, maxSize)
+}
+
+// get requests the given object of the given bucket in the given
+// region.
+func (g *s3Getter) get(bucket, key, region string) (*http.Response, error) {
+	var u string
+	switch {
+	case g.endpoint != nil:
+		u = strings.TrimSuffix(g.endpoint.String(), "/") + "/" + bucket + "/" + awsURIEncode(key, false)
+	case strings.Contains(bucket, "."):
+		// The certificate of virtual hosted buckets does not cover
+		// bucket names with dots
+		u = "https://s3." + region + ".amazonaws.com/" + bucket + "/" + awsURIEncode(key, false)
+	default:
+		u = "https://" + bucket + ".s3." + region + ".amazonaws.com/" + awsURIEncode(key, false)
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := g.creds()
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		signV4(req, creds, region, "s3", time.Now())
+	}
+	return g.client.Do(req)
+}
+
+// signV4 signs the given (bodyless) request for the given service in
+// the given region with AWS Signature Version 4, as of the given
+// time. The host and the headers already set on the request are
+// signed.
+func signV4(req *http.Request, creds *awsCredentials, region, service string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + region + "/" + service + "/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	}
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + creds.secretAccessKey)
+	for _, part := range []string{amzDate[:8], region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode encodes the given string as AWS expects in signed
+// requests: everything but unreserved characters is percent-encoded,
+// and so are slashes if asked for.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// webIdentityCache holds the credentials of the IAM role of the
+// service account of the operator, until shortly before they expire.
+type webIdentityCache struct {
+	mu    sync.Mutex
+	creds *awsCredentials
+}
+
+var webIdentity = &webIdentityCache{}
+
+// get returns the cached credentials of the given role, exchanging
+// the web identity token in the given file for new ones when they
+// are about to expire.
+func (c *webIdentityCache) get(client *http.Client, roleARN, tokenFile string) (*awsCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.creds != nil && time.Now().Add(5*time.Minute).Before(c.creds.expires) {
+		return c.creds, nil
+	}
+	creds, err := assumeRoleWithWebIdentity(client, roleARN, tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	c.creds = creds
+	return creds, nil
+}
+
+// assumeRoleWithWebIdentity exchanges the web identity token in the
+// given file for temporary credentials of the given role.
+func assumeRoleWithWebIdentity(client *http.Client, roleARN, tokenFile string) (*awsCredentials, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read web identity token: %s", err)
+	}
+	q := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {"helm-operator"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	resp, err := client.Get(stsEndpoint + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to assume role %s with web identity: %s", roleARN, resp.Status)
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unable to parse credentials of role %s: %s", roleARN, err)
+	}
+	if result.Credentials.AccessKeyID == "" {
+		return nil, errors.New("no credentials for role " + roleARN)
+	}
+	return &awsCredentials{
+		accessKeyID:     result.Credentials.AccessKeyID,
+		secretAccessKey: result.Credentials.SecretAccessKey,
+		sessionToken:    result.Credentials.SessionToken,
+		expires:         result.Credentials.Expiration,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package chartsync
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// Selected returns if the given HelmRelease is reconciled by us: if
+// it matches the release selector, or there is none.
+func (chs *ChartChangeSync) Selected(hr helmfluxv1.HelmRelease) bool {
+	return chs.config.ReleaseSelector == nil || chs.config.ReleaseSelector.Matches(labels.Set(hr.GetLabels()))
+}
+
+// Deselected returns if the given HelmRelease, which is gone from the
+// informer, still exists. With a release selector the informer only
+// holds the HelmReleases that match it, so that those of which the
+// labels no longer match it are gone from it too; their release is
+// left to the operator they match now. When in doubt, the HelmRelease
+// is considered to exist.
+func (chs *ChartChangeSync) Deselected(hr helmfluxv1.HelmRelease) bool {
+	if chs.config.ReleaseSelector == nil {
+		return false
+	}
+	_, err := chs.ifClient.HelmV1().HelmReleases(hr.Namespace).Get(hr.Name, metav1.GetOptions{})
+	return !errors.IsNotFound(err)
+}
+
+// selectedReleases lists the HelmReleases we know about that are
+// reconciled by us.
+func (chs *ChartChangeSync) selectedReleases() ([]*helmfluxv1.HelmRelease, error) {
+	list, err := chs.hrLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var selected []*helmfluxv1.HelmRelease
+	for _, hr := range list {
+		if chs.Selected(*hr) {
+			selected = append(selected, hr)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,50 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iflister "github.com/fluxcd/helm-operator/pkg/client/listers/helm.fluxcd.io/v1"
+)
+
+func TestSelected(t *testing.T) {
+	shardA := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default", Labels: map[string]string{"shard": "a"}}}
+	shardB := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "redis", Namespace: "default", Labels: map[string]string{"shard": "b"}}}
+	unlabelled := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, hr := range []helmfluxv1.HelmRelease{shardA, shardB, unlabelled} {
+		hr := hr
+		indexer.Add(&hr)
+	}
+	chs := &ChartChangeSync{
+		logger:   log.NewNopLogger(),
+		hrLister: iflister.NewHelmReleaseLister(indexer),
+	}
+
+	// Without a selector, all HelmReleases are selected
+	list, err := chs.selectedReleases()
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+	assert.True(t, chs.Selected(unlabelled))
+
+	selector, err := labels.Parse("shard=a")
+	assert.NoError(t, err)
+	chs.config.ReleaseSelector = selector
+	list, err = chs.selectedReleases()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "podinfo", list[0].Name)
+	}
+	assert.False(t, chs.Selected(shardB))
+	assert.False(t, chs.Selected(unlabelled))
+
+	// A HelmRelease that is not selected is not reconciled
+	result := chs.reconcileCollected(shardB)
+	assert.Equal(t, ReconcileResult{Resource: "default:helmrelease/redis", Outcome: OutcomeSkipped, Reason: ReasonNotSelected}, result)
+}
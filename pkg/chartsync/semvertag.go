@@ -0,0 +1,60 @@
+package chartsync
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// resolveSemverTag returns the highest tag in the git repo in the
+// given directory (e.g. a mirror) that matches the semver range of
+// tags the given chart source follows, or an empty string if it does
+// not follow one.
+func resolveSemverTag(ctx context.Context, dir string, source *helmfluxv1.GitChartSource) (string, error) {
+	versionRange, ok := source.SemverRange()
+	if !ok {
+		return "", nil
+	}
+	constraint, err := semver.NewConstraint(normalizeVersionRange(versionRange))
+	if err != nil {
+		return "", fmt.Errorf("invalid semver range %q: %s", versionRange, err)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "for-each-ref", "--format=%(refname)", "refs/tags/")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("timed out listing git tags")
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to list git tags: %s", err)
+	}
+	return highestMatchingTag(strings.Fields(string(out)), constraint, versionRange)
+}
+
+// highestMatchingTag returns the tag (without `refs/tags/`) with the
+// highest semver version of the given tag refs that satisfies the
+// constraint. Tags that are not semver versions are ignored.
+func highestMatchingTag(refs []string, constraint *semver.Constraints, versionRange string) (string, error) {
+	var highest *semver.Version
+	tag := ""
+	for _, ref := range refs {
+		name := strings.TrimPrefix(ref, "refs/tags/")
+		v, err := semver.NewVersion(name)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		if highest == nil || v.GreaterThan(highest) {
+			highest, tag = v, name
+		}
+	}
+	if highest == nil {
+		return "", fmt.Errorf("no git tag matches semver range %q", versionRange)
+	}
+	return tag, nil
+}
@@ -0,0 +1,64 @@
+package chartsync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func Test_resolveSemverTag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir, err := ioutil.TempDir("", "flux-helm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, out)
+		}
+	}
+	run("init")
+	run("commit", "--allow-empty", "-m", "chart")
+	for _, tag := range []string{"v1.1.0", "v1.2.0", "1.2.3", "v1.3.0-rc.1", "v2.0.0", "latest"} {
+		run("tag", tag)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for ref, want := range map[string]string{
+		"semver:~1.2":            "1.2.3",
+		"semver: >=1.0.0 <2.0.0": "1.2.3",
+		"semver:^1.3.0-0":        "v1.3.0-rc.1",
+		"semver:*":               "v2.0.0",
+		"master":                 "",
+	} {
+		tag, err := resolveSemverTag(ctx, dir, &helmfluxv1.GitChartSource{Ref: ref})
+		assert.NoError(t, err, ref)
+		assert.Equal(t, want, tag, ref)
+	}
+
+	// A pinned tag takes precedence over the range
+	tag, err := resolveSemverTag(ctx, dir, &helmfluxv1.GitChartSource{Ref: "semver:~1.2", Tag: "v1.1.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", tag)
+
+	_, err = resolveSemverTag(ctx, dir, &helmfluxv1.GitChartSource{Ref: "semver:~3.0"})
+	assert.EqualError(t, err, `no git tag matches semver range "~3.0"`)
+	_, err = resolveSemverTag(ctx, dir, &helmfluxv1.GitChartSource{Ref: "semver:latest"})
+	assert.Error(t, err)
+}
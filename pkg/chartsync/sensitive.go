@@ -0,0 +1,32 @@
+package chartsync
+
+import (
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// redactor returns the function that redacts the sensitive values of
+// the given HelmRelease from the values and manifests of its release
+// that are logged or served by the API. When the values cannot be
+// composed, everything is redacted, as there is no telling what to
+// leave out.
+func (chs *ChartChangeSync) redactor(hr helmfluxv1.HelmRelease, chartPath string) func(string) string {
+	if valuesFrom, err := hr.GetValuesFromSources(); err == nil && !release.HasSensitiveValues(valuesFrom) {
+		return func(text string) string { return text }
+	}
+	_, sensitive, err := chs.composeValues(hr, chartPath)
+	if err != nil {
+		return func(string) string { return release.RedactedValue }
+	}
+	return sensitive.RedactYAML
+}
+
+// debugManifest returns the given manifest of the release of the
+// given HelmRelease as it is logged when debugging it, with the
+// sensitive values redacted.
+func (chs *ChartChangeSync) debugManifest(hr helmfluxv1.HelmRelease, chartPath, manifest string) string {
+	if !hr.Spec.Debug {
+		return ""
+	}
+	return chs.redactor(hr, chartPath)(manifest)
+}
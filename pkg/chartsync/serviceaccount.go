@@ -0,0 +1,111 @@
+package chartsync
+
+import (
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// releaseVerbs are the verbs Tiller uses to apply the resources of
+// a release; it creates the resources that do not exist, and patches
+// those that do.
+var releaseVerbs = []string{"create", "patch"}
+
+// authorizeRelease renders the release of the given HelmRelease, and
+// returns an error if its service account is not allowed to apply
+// any of the rendered resources (including those of the hooks).
+//
+// Tiller applies the resources of a release with its own
+// credentials, and can not be made to impersonate the service
+// account, so this is an advisory review of what is about to be
+// applied rather than a confinement: what Tiller applies is rendered
+// again, and hooks may create resources of their own once they run.
+func (chs *ChartChangeSync) authorizeRelease(hr helmfluxv1.HelmRelease, chartPath string) error {
+	opts := chs.installOptions(hr, true)
+	tempRelName := release.DryRunReleaseName(chs.config.DryRunReleasePrefix, hr)
+	rel, _, err := chs.release.Install(chartPath, tempRelName, hr, release.InstallAction, opts, &chs.kubeClient)
+	if err != nil {
+		return fmt.Errorf("unable to render release to authorize it: %s", err)
+	}
+	objs := release.ManifestToUnstructured(renderedManifests(rel))
+	return authorizeObjects(&chs.kubeClient, hr.Namespace, hr.Spec.ServiceAccountName, hr.GetTargetNamespace(), objs)
+}
+
+// authorizeObjects returns an error listing the objects the service
+// account with the given namespace and name is not allowed to create
+// or patch. Objects without a namespace that are namespaced are
+// taken to be in the given release namespace.
+func authorizeObjects(client kubernetes.Interface, saNamespace, saName, releaseNamespace string, objs []unstructured.Unstructured) error {
+	if _, err := client.CoreV1().ServiceAccounts(saNamespace).Get(saName, metav1.GetOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("service account '%s' does not exist in namespace '%s'", saName, saNamespace)
+		}
+		return err
+	}
+
+	groupResources, err := restmapper.GetAPIGroupResources(client.Discovery())
+	if err != nil {
+		return fmt.Errorf("unable to discover API resources: %s", err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	user := fmt.Sprintf("system:serviceaccount:%s:%s", saNamespace, saName)
+	groups := []string{"system:serviceaccounts", "system:serviceaccounts:" + saNamespace, "system:authenticated"}
+
+	var denied []string
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return fmt.Errorf("unable to map %s '%s': %s", gvk.Kind, obj.GetName(), err)
+		}
+		namespace := ""
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			namespace = obj.GetNamespace()
+			if namespace == "" {
+				namespace = releaseNamespace
+			}
+		}
+		for _, verb := range releaseVerbs {
+			sar := &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					User:   user,
+					Groups: groups,
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: namespace,
+						Verb:      verb,
+						Group:     mapping.Resource.Group,
+						Version:   mapping.Resource.Version,
+						Resource:  mapping.Resource.Resource,
+						Name:      obj.GetName(),
+					},
+				},
+			}
+			res, err := client.AuthorizationV1().SubjectAccessReviews().Create(sar)
+			if err != nil {
+				return fmt.Errorf("unable to review access of service account: %s", err)
+			}
+			if !res.Status.Allowed {
+				resource := mapping.Resource.Resource + "/" + obj.GetName()
+				if namespace != "" {
+					resource = namespace + "/" + resource
+				}
+				denied = append(denied, verb+" "+resource)
+			}
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("service account '%s' is not allowed to %s", saName, strings.Join(denied, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_authorizeObjects(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "team-a"},
+	})
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+				{Name: "namespaces", Kind: "Namespace"},
+			},
+		},
+	}
+	var reviewed []authorizationv1.ResourceAttributes
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		assert.Equal(t, "system:serviceaccount:team-a:tenant", sar.Spec.User)
+		attrs := *sar.Spec.ResourceAttributes
+		reviewed = append(reviewed, attrs)
+		// Only namespaced resources in the tenant namespace are allowed
+		sar.Status.Allowed = attrs.Namespace == "team-a"
+		return true, sar, nil
+	})
+
+	configMap := unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("config")
+
+	assert.NoError(t, authorizeObjects(client, "team-a", "tenant", "team-a", []unstructured.Unstructured{configMap}))
+	assert.Len(t, reviewed, 2)
+	assert.Equal(t, "create", reviewed[0].Verb)
+	assert.Equal(t, "patch", reviewed[1].Verb)
+	assert.Equal(t, "configmaps", reviewed[0].Resource)
+	assert.Equal(t, "team-a", reviewed[0].Namespace)
+
+	namespace := unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("team-b")
+	configMap.SetNamespace("team-b")
+
+	err := authorizeObjects(client, "team-a", "tenant", "team-a", []unstructured.Unstructured{configMap, namespace})
+	assert.EqualError(t, err, "service account 'tenant' is not allowed to create team-b/configmaps/config, patch team-b/configmaps/config, create namespaces/team-b, patch namespaces/team-b")
+
+	err = authorizeObjects(client, "team-a", "missing", "team-a", nil)
+	assert.EqualError(t, err, "service account 'missing' does not exist in namespace 'team-a'")
+}
@@ -0,0 +1,142 @@
+package chartsync
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	ifclientset "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned"
+)
+
+// Operators sharing the HelmReleases of a cluster publish their
+// release selector in an annotation on the config map of their
+// lease, which is labelled so that they can find each other's.
+const (
+	ShardLabel              = "helm.fluxcd.io/release-shard"
+	ShardSelectorAnnotation = "helm.fluxcd.io/release-selector"
+)
+
+// unselectedReleasesInterval is the interval at which the
+// HelmReleases no operator selects are looked for. As they are not
+// in the informer, they are listed from the API server.
+const unselectedReleasesInterval = 5 * time.Minute
+
+// publishSelector records the release selector on the config map of
+// the lease, creating it if it does not exist yet (i.e. without
+// leader election), so that the other operators know it.
+func (chs *ChartChangeSync) publishSelector() {
+	le := chs.config.LeaderElection
+	if chs.config.ReleaseSelector == nil || le.LeaseNamespace == "" {
+		return
+	}
+	if err := publishShardSelector(&chs.kubeClient, le.LeaseNamespace, le.LeaseName, chs.config.ReleaseSelector); err != nil {
+		chs.logger.Log("warning", "could not publish the release selector, HelmReleases it selects may be reported as selected by no operator", "lease", le.LeaseNamespace+"/"+le.LeaseName, "err", err)
+	}
+}
+
+func publishShardSelector(client kubernetes.Interface, namespace, name string, selector labels.Selector) error {
+	cms := client.CoreV1().ConfigMaps(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := cms.Get(name, metav1.GetOptions{})
+		create := errors.IsNotFound(err)
+		if create {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Labels == nil {
+			cm.Labels = map[string]string{}
+		}
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Labels[ShardLabel] = "true"
+		cm.Annotations[ShardSelectorAnnotation] = selector.String()
+		if create {
+			_, err = cms.Create(cm)
+		} else {
+			_, err = cms.Update(cm)
+		}
+		return err
+	})
+}
+
+// shardSelectors returns the release selectors published by the
+// operators sharing the HelmReleases, of which the leases are in the
+// given namespace.
+func shardSelectors(client kubernetes.Interface, namespace string) ([]labels.Selector, error) {
+	cms, err := client.CoreV1().ConfigMaps(namespace).List(metav1.ListOptions{LabelSelector: ShardLabel})
+	if err != nil {
+		return nil, err
+	}
+	var selectors []labels.Selector
+	for _, cm := range cms.Items {
+		selector, err := labels.Parse(cm.Annotations[ShardSelectorAnnotation])
+		if err != nil {
+			continue
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// unselectedReleases returns the HelmReleases in the given namespace
+// (all with an empty one) that none of the given selectors match.
+func unselectedReleases(client ifclientset.Interface, namespace string, selectors []labels.Selector) ([]helmfluxv1.HelmRelease, error) {
+	var unselected []helmfluxv1.HelmRelease
+	opts := metav1.ListOptions{Limit: 500}
+	for {
+		list, err := client.HelmV1().HelmReleases(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+	next:
+		for _, hr := range list.Items {
+			for _, selector := range selectors {
+				if selector.Matches(labels.Set(hr.Labels)) {
+					continue next
+				}
+			}
+			unselected = append(unselected, hr)
+		}
+		if opts.Continue = list.Continue; opts.Continue == "" {
+			return unselected, nil
+		}
+	}
+}
+
+// reportUnselectedReleases reports the HelmReleases that match the
+// release selector of none of the operators sharing them, and are
+// therefore not released by any. Those that are being deleted are
+// finalized, as no other operator will.
+func (chs *ChartChangeSync) reportUnselectedReleases() {
+	if chs.config.ReleaseSelector == nil || chs.config.LeaderElection.LeaseNamespace == "" {
+		return
+	}
+	selectors, err := shardSelectors(&chs.kubeClient, chs.config.LeaderElection.LeaseNamespace)
+	if err != nil {
+		chs.logger.Log("warning", "unable to list the release selectors of the operators", "err", err)
+		return
+	}
+	unselected, err := unselectedReleases(&chs.ifClient, chs.namespace, append(selectors, chs.config.ReleaseSelector))
+	if err != nil {
+		chs.logger.Log("warning", "unable to list HelmReleases to look for those no operator selects", "err", err)
+		return
+	}
+	for _, hr := range unselected {
+		if hr.DeletionTimestamp != nil {
+			chs.finalizeRelease(hr)
+			continue
+		}
+		chs.logger.Log("warning", "HelmRelease matches the release selector of no operator, it is not released", "resource", hr.ResourceID().String())
+	}
+	releasesUnselected.Set(float64(len(unselected)))
+}
@@ -0,0 +1,47 @@
+package chartsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	iffake "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/fake"
+)
+
+func TestUnselectedReleases(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	for lease, selector := range map[string]string{"helm-operator-a": "shard=a", "helm-operator-b": "shard in (b,c)"} {
+		s, err := labels.Parse(selector)
+		assert.NoError(t, err)
+		assert.NoError(t, publishShardSelector(kubeClient, "flux", lease, s))
+	}
+	// Publishing again updates the config map of the lease
+	s, err := labels.Parse("shard in (b,c)")
+	assert.NoError(t, err)
+	assert.NoError(t, publishShardSelector(kubeClient, "flux", "helm-operator-b", s))
+
+	selectors, err := shardSelectors(kubeClient, "flux")
+	assert.NoError(t, err)
+	assert.Len(t, selectors, 2)
+
+	hr := func(name string, labels map[string]string) *helmfluxv1.HelmRelease {
+		return &helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+	}
+	ifClient := iffake.NewSimpleClientset(
+		hr("podinfo", map[string]string{"shard": "a"}),
+		hr("redis", map[string]string{"shard": "c"}),
+		hr("nginx", map[string]string{"shard": "d"}),
+		hr("mongodb", nil),
+	)
+	unselected, err := unselectedReleases(ifClient, "", selectors)
+	assert.NoError(t, err)
+	var names []string
+	for _, hr := range unselected {
+		names = append(names, hr.Name)
+	}
+	assert.ElementsMatch(t, []string{"nginx", "mongodb"}, names)
+}
@@ -0,0 +1,55 @@
+package chartsync
+
+import (
+	"context"
+)
+
+// beginReconcile registers a reconciliation as in-flight, unless we
+// are shutting down, in which case it returns false and the
+// reconciliation should not be started.
+func (chs *ChartChangeSync) beginReconcile() bool {
+	chs.shutdownMu.Lock()
+	defer chs.shutdownMu.Unlock()
+	if chs.shuttingDown {
+		return false
+	}
+	chs.inflight.Add(1)
+	return true
+}
+
+// endReconcile unregisters an in-flight reconciliation.
+func (chs *ChartChangeSync) endReconcile() {
+	chs.inflight.Done()
+}
+
+// stopReconciling stops the ChartChangeSync from starting new
+// reconciliations.
+func (chs *ChartChangeSync) stopReconciling() {
+	chs.shutdownMu.Lock()
+	chs.shuttingDown = true
+	chs.shutdownMu.Unlock()
+}
+
+// Shutdown stops the ChartChangeSync from starting new
+// reconciliations, and waits for the in-flight reconciliations (and
+// the installs, upgrades and rollbacks they perform) to finish, so
+// that releases are not left pending. It returns the error of the
+// given context if it is done before they finished; the in-flight
+// reconciliations are not interrupted, and still release the locks
+// they hold once they finish.
+func (chs *ChartChangeSync) Shutdown(ctx context.Context) error {
+	chs.stopReconciling()
+
+	done := make(chan struct{})
+	go func() {
+		chs.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,36 @@
+package chartsync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	chs := &ChartChangeSync{}
+
+	assert.True(t, chs.beginReconcile())
+
+	// The in-flight reconciliation does not finish in time
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, chs.Shutdown(ctx))
+
+	// No new reconciliations are started once shutting down
+	assert.False(t, chs.beginReconcile())
+
+	// The in-flight reconciliation finishes within the grace period
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		chs.endReconcile()
+	}()
+	assert.NoError(t, chs.Shutdown(context.Background()))
+}
+
+func TestShutdown_Idle(t *testing.T) {
+	chs := &ChartChangeSync{}
+	assert.NoError(t, chs.Shutdown(context.Background()))
+	assert.False(t, chs.beginReconcile())
+}
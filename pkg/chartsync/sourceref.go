@@ -0,0 +1,179 @@
+package chartsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// artifactFetchTimeout is the duration after which fetching the
+// artifact of a source object is aborted.
+const artifactFetchTimeout = 2 * time.Minute
+
+// sourceResource returns the resource of the source object the given
+// reference refers to.
+func sourceResource(ref helmfluxv1.ChartSourceReference) (schema.GroupVersionResource, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersionOrDefault())
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+	switch ref.Kind {
+	case helmfluxv1.SourceKindGitRepository:
+		return gv.WithResource("gitrepositories"), nil
+	case helmfluxv1.SourceKindHelmRepository:
+		return gv.WithResource("helmrepositories"), nil
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("unsupported kind of source object '%s'", ref.Kind)
+}
+
+// makeSourceRefChartPath gives the location of the chart at the given
+// path of the artifact with the given revision and checksum of the
+// source object with the given kind, namespace and name.
+func makeSourceRefChartPath(base, kind, namespace, name, revision, checksum, path string) (string, error) {
+	dir, err := chartCacheDir(base, fmt.Sprintf("%s://%s/%s/", strings.ToLower(kind), namespace, name))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(revision + "\x00" + checksum + "\x00" + path))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".tgz"), nil
+}
+
+// fetchArtifact returns the contents of the artifact at the given URL,
+// aborting once it exceeds the maximum size of the options. If a
+// checksum is given, an artifact with another SHA256 checksum is
+// refused with a verificationError.
+func fetchArtifact(url, checksum string, opts downloadOptions) ([]byte, error) {
+	g, err := opts.newHTTPGetter(url, "", "", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	g.client.Timeout = artifactFetchTimeout
+	var buf bytes.Buffer
+	h := sha256.New()
+	if err := g.download(url, io.MultiWriter(&buf, h), opts.MaxSize); err != nil {
+		return nil, err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); checksum != "" && !strings.EqualFold(sum, checksum) {
+		return nil, verificationError{fmt.Errorf("artifact has SHA256 checksum %s, expected %s", sum, strings.ToLower(checksum))}
+	}
+	return buf.Bytes(), nil
+}
+
+// getSourceRefChartSource resolves the chart of the HelmRelease from
+// the source object it refers to, and returns the path to it and its
+// revision. The chart of a HelmRepository is fetched from the URL of
+// the repository as any chart from a Helm repo is, the chart of a
+// GitRepository is taken from its latest artifact. The caller releases
+// the chart once it is done with it. Failures are recorded in the
+// status of the HelmRelease if report is true.
+func (chs *ChartChangeSync) getSourceRefChartSource(hr helmfluxv1.HelmRelease, report bool) (string, string, bool) {
+	chartPath, chartRevision := "", ""
+	chartSource := hr.Spec.ChartSource.SourceRefChartSource
+	if chartSource == nil {
+		return chartPath, chartRevision, false
+	}
+	ref := chartSource.SourceRef
+	namespace := ref.NamespaceOrDefault(hr.Namespace)
+
+	fail := func(err error) (string, string, bool) {
+		reason := ReasonSourceRefFailed
+		if _, ok := err.(verificationError); ok {
+			reason = ReasonVerificationFailed
+		}
+		if report {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, reason, "chart fetch from source object failed: "+err.Error())
+		}
+		chs.logger.Log("info", "chart fetch from source object failed", "resource", hr.ResourceID().String(), "source", ref.Kind+"/"+namespace+"/"+ref.Name, "err", err)
+		return chartPath, chartRevision, false
+	}
+
+	if chs.dynamicClient == nil {
+		return fail(fmt.Errorf("source objects are not supported by this operator"))
+	}
+	if namespace != hr.Namespace && !chs.config.AllowCrossNamespaceSourceRefs {
+		return fail(fmt.Errorf("source objects in other namespaces than the HelmRelease are not allowed by this operator"))
+	}
+	resource, err := sourceResource(ref)
+	if err != nil {
+		return fail(err)
+	}
+	obj, err := chs.dynamicClient.Resource(resource).Namespace(namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return fail(err)
+	}
+
+	if ref.Kind == helmfluxv1.SourceKindHelmRepository {
+		url, _, _ := unstructured.NestedString(obj.Object, "spec", "url")
+		if url == "" {
+			return fail(fmt.Errorf("%s '%s' has no URL", ref.Kind, ref.Name))
+		}
+		return chs.fetchRepoChart(hr, &helmfluxv1.RepoChartSource{RepoURL: url, Name: ref.Chart, Version: ref.Version}, report)
+	}
+
+	// Charts from git do not come with a provenance file, refuse
+	// rather than silently skipping the verification.
+	if hr.Spec.Verify != nil {
+		msg := "chart verification is only supported for charts from Helm repos"
+		if report {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonVerificationFailed, msg)
+		}
+		chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+		return chartPath, chartRevision, false
+	}
+	subpath, err := cleanSubpath(ref.Path)
+	if err != nil {
+		return fail(err)
+	}
+	url, _, _ := unstructured.NestedString(obj.Object, "status", "artifact", "url")
+	revision, _, _ := unstructured.NestedString(obj.Object, "status", "artifact", "revision")
+	checksum, _, _ := unstructured.NestedString(obj.Object, "status", "artifact", "checksum")
+	if url == "" {
+		if report {
+			chs.sourceNotReady(hr, fmt.Sprintf("%s '%s' has no artifact yet", ref.Kind, ref.Name))
+		}
+		chs.logger.Log("info", "source object has no artifact yet", "resource", hr.ResourceID().String(), "source", ref.Kind+"/"+namespace+"/"+ref.Name)
+		return chartPath, chartRevision, false
+	}
+
+	path, err := makeSourceRefChartPath(chs.config.ChartCache, ref.Kind, namespace, ref.Name, revision, checksum, subpath)
+	if err != nil {
+		return fail(err)
+	}
+	// NB: the caller releases the chart once it is done with it
+	key, _ := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	chs.charts.acquire(key, path)
+	if _, err := os.Stat(path); err != nil {
+		start := time.Now()
+		artifact, err := fetchArtifact(url, checksum, downloadOptions{MaxSize: chs.config.ChartMaxSize})
+		var archive []byte
+		if err == nil {
+			archive, err = extractSubchart(bytes.NewReader(artifact), subpath, false)
+		}
+		if err == nil {
+			err = writeChartArchive(path, archive)
+		}
+		chs.observePhase(hr, PhaseChartFetch, start, err == nil)
+		if err != nil {
+			chs.charts.release(path)
+			return fail(err)
+		}
+	}
+
+	chartPath = path
+	chartRevision = revision
+	return chartPath, chartRevision, true
+}
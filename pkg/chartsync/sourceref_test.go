@@ -0,0 +1,133 @@
+package chartsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/helm/pkg/chartutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/release"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+func TestGetSourceRefChartSource(t *testing.T) {
+	artifact := makeArchive(t, map[string]string{
+		"./README.md":                       "# podinfo\n",
+		"./charts/podinfo/Chart.yaml":       "name: podinfo\nversion: 3.2.0\n",
+		"./charts/podinfo/values.yaml":      "replicaCount: 1\n",
+		"./charts/podinfo/templates/a.yaml": "kind: Service\n",
+	})
+	sum := sha256.Sum256(artifact)
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(artifact)
+	}))
+	defer srv.Close()
+
+	source := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": helmfluxv1.DefaultSourceAPIVersion,
+		"kind":       helmfluxv1.SourceKindGitRepository,
+		"metadata":   map[string]interface{}{"namespace": "flux-system", "name": "podinfo"},
+		"status": map[string]interface{}{"artifact": map[string]interface{}{
+			"url":      srv.URL + "/gitrepository/flux-system/podinfo/abc123.tar.gz",
+			"revision": "master/abc123",
+			"checksum": hex.EncodeToString(sum[:]),
+		}},
+	}}
+
+	base, err := ioutil.TempDir("", "chart-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	hr := helmfluxv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "helm.fluxcd.io/v1", Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "podinfo"},
+		Spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{SourceRefChartSource: &helmfluxv1.SourceRefChartSource{
+			SourceRef: helmfluxv1.ChartSourceReference{
+				Kind:      helmfluxv1.SourceKindGitRepository,
+				Name:      "podinfo",
+				Namespace: "flux-system",
+				Path:      "./charts/podinfo/",
+			},
+		}}},
+	}
+	hrSrv, ifClient, stop := newHelmReleaseServer(t, hr)
+	defer stop()
+	chs := &ChartChangeSync{
+		logger:        log.NewNopLogger(),
+		release:       release.New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault),
+		ifClient:      ifClient,
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), source),
+		config:        Config{ChartCache: base},
+	}
+
+	// Source objects in other namespaces are only used when allowed
+	_, _, ok := chs.getSourceRefChartSource(hr, true)
+	assert.False(t, ok)
+	cond := status.GetCondition(hrSrv.get().Status, helmfluxv1.HelmReleaseChartFetched)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, ReasonSourceRefFailed, cond.Reason)
+	}
+	assert.Equal(t, 0, fetches)
+	chs.config.AllowCrossNamespaceSourceRefs = true
+
+	// Artifacts beyond the maximum size are not fetched
+	chs.config.ChartMaxSize = int64(len(artifact) - 1)
+	_, _, ok = chs.getSourceRefChartSource(hr, true)
+	assert.False(t, ok)
+	chs.config.ChartMaxSize = 0
+	fetches = 0
+
+	chartPath, revision, ok := chs.getSourceRefChartSource(hr, true)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "master/abc123", revision)
+	b, err := ioutil.ReadFile(chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := chartutil.LoadArchive(bytes.NewReader(b))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "podinfo", c.GetMetadata().GetName())
+		assert.Len(t, c.GetTemplates(), 1)
+	}
+	chs.charts.release(chartPath)
+
+	// The chart of the same artifact is taken from the cache
+	again, _, ok := chs.getSourceRefChartSource(hr, true)
+	assert.True(t, ok)
+	assert.Equal(t, chartPath, again)
+	assert.Equal(t, 1, fetches)
+	chs.charts.release(again)
+
+	// An artifact that does not match its checksum is refused, and
+	// not cached
+	unstructured.SetNestedField(source.Object, "master/def456", "status", "artifact", "revision")
+	unstructured.SetNestedField(source.Object, hex.EncodeToString(make([]byte, 32)), "status", "artifact", "checksum")
+	chs.dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), source)
+	_, _, ok = chs.getSourceRefChartSource(hr, true)
+	assert.False(t, ok)
+	cond = status.GetCondition(hrSrv.get().Status, helmfluxv1.HelmReleaseChartFetched)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, ReasonVerificationFailed, cond.Reason)
+	}
+	charts, _ := cachedCharts(base)
+	assert.Len(t, charts, 1)
+}
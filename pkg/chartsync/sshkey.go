@@ -0,0 +1,313 @@
+package chartsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/flux/pkg/git"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// The keys of the secret with the SSH key of a git chart source.
+const (
+	sshIdentityKey   = "identity"
+	sshKnownHostsKey = "known_hosts"
+)
+
+// defaultGitSSHConfigDir is the directory the SSH keys of git chart
+// sources are written to, of which the `*.conf` files are included by
+// the SSH config of the image.
+const defaultGitSSHConfigDir = "/var/fluxd/ssh"
+
+// sshHostAliasPrefix is the prefix of the host aliases the SSH keys
+// of git chart sources are configured for.
+const sshHostAliasPrefix = "helm-operator-"
+
+// mirrorName returns the name of the mirror of the given git chart
+// source of a HelmRelease in the given namespace. A chart source with
+// credentials of its own (an SSH key or a GitHub App) gets a mirror of
+// its own for every secret, so that a repo is never served to a
+// HelmRelease from a mirror made with credentials other than the ones
+// it refers to.
+func mirrorName(namespace string, chartSource *helmfluxv1.GitChartSource) string {
+	ref := chartSource.SSHSecretRef
+	if ref == nil {
+		ref = chartSource.GitHubAppSecretRef
+	}
+	if ref == nil {
+		return chartSource.GitURL
+	}
+	return chartSource.GitURL + "#" + namespace + "/" + ref.Name
+}
+
+// sshHostAlias returns the host alias the SSH key in the given secret
+// is configured for, for the given host.
+func sshHostAlias(namespace, secretName, host string) string {
+	sum := sha256.Sum256([]byte(namespace + "/" + secretName + "\x00" + host))
+	return sshHostAliasPrefix + hex.EncodeToString(sum[:])[:16]
+}
+
+// splitSSHURL returns the host of the given SSH git URL, and a
+// function that returns the URL with the host replaced, keeping the
+// user, port and path of it. URLs of which the host is not a plain
+// hostname or IP address are refused, as the host is written to the
+// SSH config.
+func splitSSHURL(gitURL string) (string, func(host string) string, error) {
+	sshHost, err := helmfluxv1.GitChartSource{GitURL: gitURL}.SSHHost()
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.Contains(gitURL, "://") {
+		u, err := url.Parse(gitURL)
+		if err != nil {
+			return "", nil, err
+		}
+		return sshHost, func(host string) string {
+			r := *u
+			r.Host = host
+			if port := u.Port(); port != "" {
+				r.Host += ":" + port
+			}
+			return r.String()
+		}, nil
+	}
+	// scp-like syntax, e.g. git@github.com:org/repo.git
+	i := strings.Index(gitURL, ":")
+	user := ""
+	if j := strings.LastIndex(gitURL[:i], "@"); j >= 0 {
+		user = gitURL[:j+1]
+	}
+	return sshHost, func(alias string) string {
+		return user + alias + gitURL[i:]
+	}, nil
+}
+
+// gitRemote returns the remote to mirror the git chart source of the
+// given HelmRelease from. If the chart source refers to a secret with
+// an SSH key, the key is set up for it with sshKeyRemoteURL; if it
+// refers to one with the credentials of a GitHub App, a current
+// installation token is written for the credential helper to hand to
+// git for the URL, which has no credentials in it.
+func (chs *ChartChangeSync) gitRemote(hr helmfluxv1.HelmRelease) (git.Remote, error) {
+	source := hr.Spec.GitChartSource
+	if ref := source.GitHubAppSecretRef; ref != nil && source.SSHSecretRef == nil {
+		secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return git.Remote{}, fmt.Errorf("unable to get secret '%s' with GitHub App credentials: %s", ref.Name, err)
+		}
+		config, err := githubAppConfigFrom(ref.Name, secret.Data)
+		if err != nil {
+			return git.Remote{}, err
+		}
+		client := &http.Client{Timeout: chs.config.GitTimeout}
+		token, err := chs.githubAppTokens.get(hr.Namespace+"/"+ref.Name, config, client, time.Now())
+		if err != nil {
+			return git.Remote{}, err
+		}
+		user := githubAppCredentialUser(hr.Namespace, ref.Name)
+		remoteURL, err := githubAppRemoteURL(source.GitURL, user)
+		if err != nil {
+			return git.Remote{}, err
+		}
+		if err := writeGitCredential(chs.config.GitCredentialsDir, user, token); err != nil {
+			return git.Remote{}, fmt.Errorf("unable to write GitHub App installation token: %s", err)
+		}
+		return git.Remote{URL: remoteURL}, nil
+	}
+	ref := source.SSHSecretRef
+	if ref == nil {
+		return git.Remote{URL: source.GitURL}, nil
+	}
+	secret, err := chs.kubeClient.CoreV1().Secrets(hr.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		// The key of a secret that is gone is not left behind
+		if errors.IsNotFound(err) {
+			if host, _, err := splitSSHURL(source.GitURL); err == nil {
+				removeSSHConfig(chs.config.GitSSHConfigDir, sshHostAlias(hr.Namespace, ref.Name, host))
+			}
+		}
+		return git.Remote{}, fmt.Errorf("unable to get secret '%s' with SSH key for git repo: %s", ref.Name, err)
+	}
+	remoteURL, err := sshKeyRemoteURL(chs.config.GitSSHConfigDir, hr.Namespace, source, secret.Data)
+	if err != nil {
+		return git.Remote{}, err
+	}
+	return git.Remote{URL: remoteURL}, nil
+}
+
+// refreshGitRemote brings the credentials of the git chart source of
+// the given HelmRelease up to date with its secret: it rewrites the
+// SSH key, or the installation token of its GitHub App once that is
+// renewed, so that the next fetch of the mirror uses it. The URL of
+// the mirror stays the same.
+func (chs *ChartChangeSync) refreshGitRemote(hr helmfluxv1.HelmRelease) error {
+	_, err := chs.gitRemote(hr)
+	return err
+}
+
+// gitCredentialsFailed marks the chart of the given HelmRelease as not
+// fetched, because the credentials of its git chart source could not
+// be set up.
+func (chs *ChartChangeSync) gitCredentialsFailed(hr helmfluxv1.HelmRelease, err error) {
+	reason := ReasonGitSSHKeyFailed
+	if hr.Spec.GitChartSource.GitHubAppSecretRef != nil {
+		reason = ReasonGitHubAppFailed
+	}
+	chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, reason, err.Error())
+	chs.logger.Log("warning", "failed to set up credentials for git repo", "resource", hr.ResourceID().String(), "repo", hr.Spec.GitChartSource.GitURL, "err", err)
+}
+
+// sshKeyRemoteURL writes the SSH key in the given data of the secret
+// the given git chart source refers to to dir, together with the SSH
+// config for a host alias that uses it, and returns the git URL of
+// the chart source with the host replaced by the alias.
+func sshKeyRemoteURL(dir, namespace string, source *helmfluxv1.GitChartSource, data map[string][]byte) (string, error) {
+	ref := source.SSHSecretRef
+	if !source.IsSSH() {
+		return "", fmt.Errorf("SSH key in secret '%s' can only be used with a git repo cloned over SSH", ref.Name)
+	}
+	host, withHost, err := splitSSHURL(source.GitURL)
+	if err != nil {
+		return "", err
+	}
+	identity := data[sshIdentityKey]
+	if len(identity) == 0 {
+		return "", fmt.Errorf("key '%s' not found in secret '%s' with SSH key for git repo", sshIdentityKey, ref.Name)
+	}
+	alias := sshHostAlias(namespace, ref.Name, host)
+	if err := writeSSHConfig(dir, alias, host, identity, data[sshKnownHostsKey]); err != nil {
+		return "", fmt.Errorf("unable to write SSH key for git repo: %s", err)
+	}
+	return withHost(alias), nil
+}
+
+// writeSSHConfig writes the given SSH key, and known hosts if any, to
+// a directory named after the given alias in dir, and the SSH config
+// that makes SSH use them to connect to the given host as the alias
+// to `<alias>.conf` in dir. Files are only written if they changed,
+// so that a key is never seen half written.
+func writeSSHConfig(dir, alias, host string, identity, knownHosts []byte) error {
+	keyDir := filepath.Join(dir, alias)
+	if err := os.MkdirAll(keyDir, 0700); err != nil {
+		return err
+	}
+	// OpenSSH refuses keys without a trailing newline, which are
+	// easily put in a secret.
+	if !bytes.HasSuffix(identity, []byte("\n")) {
+		identity = append(append([]byte{}, identity...), '\n')
+	}
+	identityFile := filepath.Join(keyDir, "identity")
+	if err := writeFileIfChanged(identityFile, identity, 0600); err != nil {
+		return err
+	}
+
+	config := fmt.Sprintf("Host %s\n  HostName %s\n  IdentityFile %s\n  IdentitiesOnly yes\n", alias, host, identityFile)
+	knownHostsFile := filepath.Join(keyDir, "known_hosts")
+	if len(knownHosts) > 0 {
+		if err := writeFileIfChanged(knownHostsFile, knownHosts, 0644); err != nil {
+			return err
+		}
+		config += fmt.Sprintf("  UserKnownHostsFile %s\n", knownHostsFile)
+	} else if err := os.Remove(knownHostsFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return writeFileIfChanged(filepath.Join(dir, alias+".conf"), []byte(config), 0644)
+}
+
+// removeSSHConfig removes the SSH config and the key for the given
+// alias from dir, the config first so that it never refers to a key
+// that is gone.
+func removeSSHConfig(dir, alias string) error {
+	if err := os.Remove(filepath.Join(dir, alias+".conf")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(dir, alias))
+}
+
+// pruneSSHConfigs removes the SSH configs and keys from dir that none
+// of the given HelmReleases uses (anymore), e.g. because it has been
+// deleted or refers to another secret now.
+func pruneSSHConfigs(dir string, hrs []*helmfluxv1.HelmRelease) error {
+	inUse := make(map[string]bool)
+	for _, hr := range hrs {
+		source := hr.Spec.GitChartSource
+		if source == nil || source.SSHSecretRef == nil || hr.DeletionTimestamp != nil {
+			continue
+		}
+		if host, _, err := splitSSHURL(source.GitURL); err == nil {
+			inUse[sshHostAlias(hr.Namespace, source.SSHSecretRef.Name, host)] = true
+		}
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		alias := strings.TrimSuffix(e.Name(), ".conf")
+		if !strings.HasPrefix(alias, sshHostAliasPrefix) || inUse[alias] {
+			continue
+		}
+		if err := removeSSHConfig(dir, alias); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneUnusedSSHConfigs removes the SSH configs and keys, and the
+// GitHub App installation tokens, of the HelmReleases that are gone
+// or no longer use them.
+func (chs *ChartChangeSync) pruneUnusedSSHConfigs() {
+	list, err := chs.selectedReleases()
+	if err == nil {
+		err = pruneSSHConfigs(chs.config.GitSSHConfigDir, list)
+	}
+	if err != nil {
+		chs.logger.Log("warning", "failed to remove SSH keys of git repos no longer in use", "err", err)
+	}
+	if list != nil {
+		if err := pruneGitCredentials(chs.config.GitCredentialsDir, list); err != nil {
+			chs.logger.Log("warning", "failed to remove GitHub App installation tokens no longer in use", "err", err)
+		}
+	}
+}
+
+// writeFileIfChanged atomically replaces the file at path with the
+// given data and mode, unless it has that data already.
+func writeFileIfChanged(path string, data []byte, mode os.FileMode) error {
+	if existing, err := ioutil.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
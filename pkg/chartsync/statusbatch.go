@@ -0,0 +1,55 @@
+package chartsync
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	v1client "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/typed/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/status"
+)
+
+// statusClient returns the client to update the status of the given
+// HelmRelease with: the batch of the reconciliation of the HelmRelease
+// while one runs, and the client for its namespace otherwise.
+func (chs *ChartChangeSync) statusClient(hr helmfluxv1.HelmRelease) v1client.HelmReleaseInterface {
+	client := chs.ifClient.HelmV1().HelmReleases(hr.Namespace)
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return client
+	}
+	chs.statusBatchesMu.Lock()
+	defer chs.statusBatchesMu.Unlock()
+	if batch, ok := chs.statusBatches[key]; ok {
+		return batch
+	}
+	return client
+}
+
+// batchStatusUpdates collects the status updates of the given
+// HelmRelease from here on, so that a reconciliation writes its status
+// once rather than with every change. It returns the func that writes
+// the collected updates and stops collecting them.
+func (chs *ChartChangeSync) batchStatusUpdates(hr helmfluxv1.HelmRelease) func() {
+	key, err := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	if err != nil {
+		return func() {}
+	}
+	batch := status.NewBatch(chs.ifClient.HelmV1().HelmReleases(hr.Namespace), hr)
+	chs.statusBatchesMu.Lock()
+	if chs.statusBatches == nil {
+		chs.statusBatches = make(map[string]*status.Batch)
+	}
+	chs.statusBatches[key] = batch
+	chs.statusBatchesMu.Unlock()
+
+	return func() {
+		chs.statusBatchesMu.Lock()
+		delete(chs.statusBatches, key)
+		chs.statusBatchesMu.Unlock()
+		// A HelmRelease that was deleted has no status to update
+		if err := batch.Flush(); err != nil && !apierrors.IsNotFound(err) {
+			chs.logger.Log("warning", "could not update the status", "resource", hr.ResourceID().String(), "err", err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package chartsync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// subchartNotFoundError is returned when the subpath of a chart from
+// a Helm repo does not hold a chart.
+type subchartNotFoundError struct {
+	subpath string
+}
+
+func (e subchartNotFoundError) Error() string {
+	return fmt.Sprintf("no Chart.yaml found at subpath '%s' of the chart", e.subpath)
+}
+
+// cleanSubpath returns the given subpath of a chart cleaned, or an
+// error if it does not stay within the chart.
+func cleanSubpath(subpath string) (string, error) {
+	clean := path.Clean(subpath)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("subpath '%s' must be a relative path within the chart", subpath)
+	}
+	return clean, nil
+}
+
+// makeSubchartPath gives the location of the archive of the subchart
+// at the given subpath of the chart archive at the given path, next
+// to it in the chart cache, so that it is evicted alike.
+func makeSubchartPath(chartPath, subpath string) string {
+	return fmt.Sprintf("%s_%s.tgz", strings.TrimSuffix(chartPath, ".tgz"),
+		base64.RawURLEncoding.EncodeToString([]byte(subpath)))
+}
+
+// ensureSubchartExtracted returns the path to the archive of the
+// subchart at the given (cleaned) subpath of the chart archive at the
+// given path, repackaging it first if necessary. It always returns
+// the expected path to the archive, and either an error or nil.
+func ensureSubchartExtracted(chartPath, subpath string) (string, error) {
+	subchartPath := makeSubchartPath(chartPath, subpath)
+	if _, err := os.Stat(subchartPath); err == nil {
+		return subchartPath, nil
+	}
+	f, err := os.Open(chartPath)
+	if err != nil {
+		return subchartPath, err
+	}
+	defer f.Close()
+	archive, err := extractSubchart(f, subpath, true)
+	if err != nil {
+		return subchartPath, err
+	}
+	return subchartPath, writeChartArchive(subchartPath, archive)
+}
+
+// extractSubchart reads the archive from the given reader, and
+// returns an archive of the chart at the given (cleaned) subpath of
+// it. The subpath is relative to the directory the entries of the
+// archive are in if rooted, as those of a chart archive are, and to
+// the root of the archive otherwise. The subchart is rooted at a
+// directory named after the last element of the subpath, as Helm
+// expects of a chart archive.
+func extractSubchart(r io.Reader, subpath string, rooted bool) ([]byte, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+
+	root := path.Base(subpath)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rel := path.Clean(hdr.Name)
+		if rooted {
+			i := strings.Index(rel, "/")
+			if i < 0 {
+				continue
+			}
+			rel = rel[i+1:]
+		}
+		if !strings.HasPrefix(rel, subpath+"/") {
+			continue
+		}
+		rel = strings.TrimPrefix(rel, subpath+"/")
+		if rel == "Chart.yaml" {
+			found = true
+		}
+		hdr.Name = root + "/" + rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, subchartNotFoundError{subpath}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
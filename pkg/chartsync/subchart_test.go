@@ -0,0 +1,69 @@
+package chartsync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+func makeArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractSubchart(t *testing.T) {
+	archive := makeArchive(t, map[string]string{
+		"platform/Chart.yaml":                                "name: platform\nversion: 1.4.0\n",
+		"platform/charts/frontend/Chart.yaml":                "name: frontend\nversion: 0.2.0\n",
+		"platform/charts/frontend/values.yaml":               "replicas: 2\n",
+		"platform/charts/frontend/templates/deployment.yaml": "kind: Deployment\n",
+		"platform/charts/backend/Chart.yaml":                 "name: backend\nversion: 0.3.0\n",
+	})
+
+	sub, err := extractSubchart(bytes.NewReader(archive), "charts/frontend", true)
+	assert.NoError(t, err)
+	c, err := chartutil.LoadArchive(bytes.NewReader(sub))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "frontend", c.GetMetadata().GetName())
+		assert.Len(t, c.GetTemplates(), 1)
+		assert.Equal(t, "replicas: 2\n", c.GetValues().GetRaw())
+	}
+
+	_, err = extractSubchart(bytes.NewReader(archive), "charts/missing", true)
+	assert.Equal(t, subchartNotFoundError{"charts/missing"}, err)
+}
+
+func TestCleanSubpath(t *testing.T) {
+	for subpath, expected := range map[string]string{
+		"charts/frontend":    "charts/frontend",
+		"./charts/frontend/": "charts/frontend",
+		"charts/../frontend": "frontend",
+		"../frontend":        "",
+		"/charts/frontend":   "",
+		".":                  "",
+	} {
+		clean, err := cleanSubpath(subpath)
+		assert.Equal(t, expected, clean, subpath)
+		assert.Equal(t, expected == "", err != nil, subpath)
+	}
+}
@@ -0,0 +1,72 @@
+package chartsync
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// updateSubmodules initialises and updates the submodules of the
+// clone in dir, recursively.
+//
+// The clone is made from the local mirror, so relative submodule URLs
+// would resolve against the mirror; the origin of the clone is pointed
+// at the upstream URL first so they resolve against that instead.
+// Submodules hosted on the same host as the upstream are fetched with
+// the credentials in the upstream URL, if any. SSH URLs use the same
+// keys as the mirror already.
+func updateSubmodules(ctx context.Context, dir, upstream string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".gitmodules")); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := gitSubmoduleCmd(ctx, dir, upstream, "config", "remote.origin.url", upstream); err != nil {
+		return err
+	}
+	return gitSubmoduleCmd(ctx, dir, upstream, "submodule", "update", "--init", "--recursive")
+}
+
+func gitSubmoduleCmd(ctx context.Context, dir, upstream string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	// The configuration is passed through the environment rather than
+	// as arguments, so that it is inherited by the git processes
+	// spawned for nested submodules, and the credentials do not end up
+	// in the process list.
+	if params := credentialConfig(upstream); params != "" {
+		cmd.Env = append(cmd.Env, "GIT_CONFIG_PARAMETERS="+params)
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out running git %s", args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("git %s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// credentialConfig returns the git config parameters (in the format
+// of `GIT_CONFIG_PARAMETERS`) that make git use the credentials of the
+// given HTTP(S) URL for all other URLs on the same host, or an empty
+// string if the URL has no credentials.
+func credentialConfig(upstream string) string {
+	u, err := url.Parse(upstream)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	withCreds := url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, Path: "/"}
+	withoutCreds := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+	return shellQuote(fmt.Sprintf("url.%s.insteadOf=%s", withCreds.String(), withoutCreds.String()))
+}
+
+// shellQuote quotes s the way git expects the entries of
+// `GIT_CONFIG_PARAMETERS` to be quoted.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
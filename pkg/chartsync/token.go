@@ -0,0 +1,80 @@
+package chartsync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// tokenClientIDKey is the key of the client ID in the secret
+	// with the client credentials of a chart repo.
+	tokenClientIDKey = "clientID"
+	// tokenClientSecretKey is the key of the client secret in the
+	// secret with the client credentials of a chart repo.
+	tokenClientSecretKey = "clientSecret"
+)
+
+// repoTokens caches the bearer tokens of the chart repos that
+// require token authentication, so that they are reused until they
+// expire rather than fetched for every download.
+type repoTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*repoToken
+}
+
+// get returns the token for the given key, fetched with the given
+// client credentials; the cached token is discarded when the
+// credentials changed.
+func (r *repoTokens) get(key string, config clientcredentials.Config) *repoToken {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tokens == nil {
+		r.tokens = make(map[string]*repoToken)
+	}
+	if t, ok := r.tokens[key]; ok && reflect.DeepEqual(t.config, config) {
+		return t
+	}
+	t := &repoToken{config: config}
+	r.tokens[key] = t
+	return t
+}
+
+// repoToken is a bearer token for a chart repo, fetched from an
+// OAuth2 token endpoint with the client credentials grant.
+type repoToken struct {
+	config clientcredentials.Config
+
+	mu     sync.Mutex
+	source oauth2.TokenSource
+}
+
+// Token returns the current token, fetching a new one through the
+// given client if there is none, or it expired.
+func (t *repoToken) Token(client *http.Client) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.source == nil {
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+		t.source = t.config.TokenSource(ctx)
+	}
+	tok, err := t.source.Token()
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch token for chart repository: %s", err)
+	}
+	return tok.AccessToken, nil
+}
+
+// invalidate discards the current token, so that a new one is
+// fetched the next time, e.g. because the chart repo refused it
+// before it expired.
+func (t *repoToken) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.source = nil
+}
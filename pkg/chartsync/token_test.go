@@ -0,0 +1,71 @@
+package chartsync
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+func Test_downloadOptions_newHTTPGetter_token(t *testing.T) {
+	var issued int
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "operator" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		issued++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, issued)
+	}))
+	defer tokenSrv.Close()
+
+	// Only accepts the latest token, as if the earlier ones were
+	// revoked
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", issued) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("chart"))
+	}))
+	defer srv.Close()
+
+	var tokens repoTokens
+	token := tokens.get("default/repo-credentials", clientcredentials.Config{
+		ClientID:     "operator",
+		ClientSecret: "s3cr3t",
+		TokenURL:     tokenSrv.URL,
+	})
+	g, err := downloadOptions{Token: token}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	assert.NoError(t, err)
+
+	buf, err := g.Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "chart", buf.String())
+	_, err = g.Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, issued)
+
+	// A token that is refused is refetched
+	issued++
+	buf, err = g.Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "chart", buf.String())
+	assert.Equal(t, 3, issued)
+
+	// Changed credentials are not served the cached token
+	other := tokens.get("default/repo-credentials", clientcredentials.Config{
+		ClientID:     "operator",
+		ClientSecret: "wrong",
+		TokenURL:     tokenSrv.URL,
+	})
+	assert.False(t, token == other)
+	g, err = downloadOptions{Token: other}.newHTTPGetter(srv.URL, "", "", "", "", "")
+	assert.NoError(t, err)
+	_, err = g.Get(srv.URL)
+	assert.Error(t, err)
+}
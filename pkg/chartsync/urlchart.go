@@ -0,0 +1,173 @@
+package chartsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/helm/pkg/chartutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// makeURLChartPath gives the location in the chart cache of the
+// chart archive of the given URL chart source.
+func makeURLChartPath(base string, source *helmfluxv1.URLChartSource) (string, error) {
+	// The directory is named after the host only, as URLs can be
+	// longer than file names.
+	host := source.URL
+	if u, err := url.Parse(source.URL); err == nil {
+		host = u.Scheme + "://" + u.Host + "/"
+	}
+	dir, err := chartCacheDir(base, host)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(source.URL + "\x00" + strings.ToLower(source.SHA256)))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".tgz"), nil
+}
+
+// fileChecksum returns the (hex encoded) SHA256 checksum of the file
+// at the given path.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// urlChartStale returns if the chart archive of the given URL chart
+// source, downloaded at the given time, is to be downloaded again:
+// without a checksum to pin it, the archive at the URL may have
+// changed once the refresh interval passed. A zero interval never
+// downloads an archive again.
+func urlChartStale(source *helmfluxv1.URLChartSource, downloaded time.Time, interval time.Duration, now time.Time) bool {
+	return source.SHA256 == "" && interval > 0 && now.Sub(downloaded) >= interval
+}
+
+// fetchURLChart downloads the chart archive of the given URL chart
+// source to the given path, replacing the one there may be. The
+// archive is only moved into place once it has the checksum of the
+// chart source, if given, and holds a chart.
+func fetchURLChart(chartPath string, source *helmfluxv1.URLChartSource, opts downloadOptions) error {
+	g, err := opts.newHTTPGetter(source.URL, "", "", "", opts.Username, opts.Password)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(chartPath), filepath.Base(chartPath)+".*.part")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := downloadTo(g, source.URL, f.Name(), opts.MaxSize); err != nil {
+		return err
+	}
+
+	if source.SHA256 != "" {
+		checksum, err := fileChecksum(f.Name())
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(checksum, source.SHA256) {
+			return verificationError{fmt.Errorf("chart archive has SHA256 checksum %s, expected %s", checksum, strings.ToLower(source.SHA256))}
+		}
+	}
+	if _, err := chartutil.Load(f.Name()); err != nil {
+		return fmt.Errorf("invalid chart archive: %s", err)
+	}
+	return os.Rename(f.Name(), chartPath)
+}
+
+// getURLChartSource fetches the chart archive at the URL of the chart
+// source of the HelmRelease, and returns the path to it and its SHA256
+// checksum as its revision. An archive is fetched once per URL and
+// checksum; without a checksum, it is fetched again once the refresh
+// interval passed, so that a changed archive is upgraded to. The caller releases the chart once it is
+// done with it. Failures are recorded in the status of the
+// HelmRelease if report is true.
+func (chs *ChartChangeSync) getURLChartSource(hr helmfluxv1.HelmRelease, report bool) (string, string, bool) {
+	chartPath, chartRevision := "", ""
+	chartSource := hr.Spec.ChartSource.URLChartSource
+	if chartSource == nil {
+		return chartPath, chartRevision, false
+	}
+
+	// Chart archives at a URL do not come with a provenance file,
+	// refuse rather than silently skipping the verification.
+	if hr.Spec.Verify != nil {
+		msg := "chart verification is only supported for charts from Helm repos"
+		if report {
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, ReasonVerificationFailed, msg)
+		}
+		chs.logger.Log("info", msg, "resource", hr.ResourceID().String())
+		return chartPath, chartRevision, false
+	}
+
+	fail := func(err error) (string, string, bool) {
+		if report {
+			reason, msg := downloadFailure(err)
+			chs.setCondition(hr, helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, reason, msg)
+		}
+		chs.logger.Log("info", "chart download failed", "resource", hr.ResourceID().String(), "err", err)
+		return chartPath, chartRevision, false
+	}
+
+	// The proxy, CAs and credentials for the host are those of
+	// charts from Helm repos.
+	opts, err := chs.downloadOptions(hr, &helmfluxv1.RepoChartSource{RepoURL: chartSource.URL})
+	if err != nil {
+		return fail(err)
+	}
+
+	path, err := makeURLChartPath(chs.config.ChartCache, chartSource)
+	if err != nil {
+		return fail(err)
+	}
+	// NB: the caller releases the chart once it is done with it
+	key, _ := cache.MetaNamespaceKeyFunc(hr.GetObjectMeta())
+	chs.charts.acquire(key, path)
+	if info, err := os.Stat(path); err != nil || urlChartStale(chartSource, info.ModTime(), chs.config.URLChartRefreshInterval, time.Now()) {
+		start := time.Now()
+		err = fetchURLChart(path, chartSource, opts)
+		chs.observePhase(hr, PhaseChartFetch, start, err == nil)
+		if err != nil {
+			chs.charts.release(path)
+			return fail(err)
+		}
+	}
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		chs.charts.release(path)
+		return fail(err)
+	}
+
+	chartPath = path
+	chartRevision = checksum
+	return chartPath, chartRevision, true
+}
+
+// urlChartName returns the name of the chart archive at the URL of
+// the given chart source, as shown in conditions.
+func urlChartName(source *helmfluxv1.URLChartSource) string {
+	if u, err := url.Parse(source.URL); err == nil {
+		return path.Base(u.Path)
+	}
+	return source.URL
+}
@@ -0,0 +1,118 @@
+package chartsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestFetchURLChart(t *testing.T) {
+	archive := makeArchive(t, map[string]string{
+		"podinfo/Chart.yaml": "name: podinfo\nversion: 3.1.0\n",
+	})
+	sum := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(sum[:])
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/charts/podinfo-3.1.0.tgz":
+			w.Write(archive)
+		case "/charts/broken.tgz":
+			w.Write([]byte("not a chart"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	base, err := ioutil.TempDir("", "chart-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	source := &helmfluxv1.URLChartSource{URL: srv.URL + "/charts/podinfo-3.1.0.tgz", SHA256: checksum}
+	path, err := makeURLChartPath(base, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, fetchURLChart(path, source, downloadOptions{}))
+	got, err := fileChecksum(path)
+	assert.NoError(t, err)
+	assert.Equal(t, checksum, got)
+	assert.Equal(t, "podinfo-3.1.0.tgz", urlChartName(source))
+
+	// Charts from URLs are evicted alike
+	charts, _ := cachedCharts(base)
+	if assert.Len(t, charts, 1) {
+		assert.Equal(t, path, charts[0].path)
+	}
+
+	// An archive with another checksum is refused, and not cached
+	other := &helmfluxv1.URLChartSource{URL: source.URL, SHA256: hex.EncodeToString(make([]byte, 32))}
+	otherPath, _ := makeURLChartPath(base, other)
+	err = fetchURLChart(otherPath, other, downloadOptions{})
+	if assert.Error(t, err) {
+		assert.IsType(t, verificationError{}, err)
+	}
+	_, err = os.Stat(otherPath)
+	assert.True(t, os.IsNotExist(err))
+
+	broken := &helmfluxv1.URLChartSource{URL: srv.URL + "/charts/broken.tgz"}
+	brokenPath, _ := makeURLChartPath(base, broken)
+	assert.Error(t, fetchURLChart(brokenPath, broken, downloadOptions{}))
+	missing := &helmfluxv1.URLChartSource{URL: srv.URL + "/charts/missing.tgz"}
+	missingPath, _ := makeURLChartPath(base, missing)
+	assert.Error(t, fetchURLChart(missingPath, missing, downloadOptions{}))
+	charts, _ = cachedCharts(base)
+	assert.Len(t, charts, 1)
+}
+
+func TestURLChartStale(t *testing.T) {
+	now := time.Now()
+	pinned := &helmfluxv1.URLChartSource{URL: "https://artifacts.example.com/charts/podinfo.tgz", SHA256: "1d4a"}
+	unpinned := &helmfluxv1.URLChartSource{URL: "https://artifacts.example.com/charts/podinfo.tgz"}
+
+	assert.False(t, urlChartStale(pinned, now.Add(-time.Hour), 10*time.Minute, now))
+	assert.False(t, urlChartStale(unpinned, now.Add(-time.Minute), 10*time.Minute, now))
+	assert.True(t, urlChartStale(unpinned, now.Add(-time.Hour), 10*time.Minute, now))
+	assert.False(t, urlChartStale(unpinned, now.Add(-time.Hour), 0, now))
+}
+
+func TestFetchURLChart_changed(t *testing.T) {
+	var mu sync.Mutex
+	archive := makeArchive(t, map[string]string{"podinfo/Chart.yaml": "name: podinfo\nversion: 3.1.0\n"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(archive)
+	}))
+	defer srv.Close()
+	base, err := ioutil.TempDir("", "chart-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	source := &helmfluxv1.URLChartSource{URL: srv.URL + "/charts/podinfo.tgz"}
+	path, _ := makeURLChartPath(base, source)
+	assert.NoError(t, fetchURLChart(path, source, downloadOptions{}))
+	first, _ := fileChecksum(path)
+
+	// The archive downloaded again replaces the one there was
+	mu.Lock()
+	archive = makeArchive(t, map[string]string{"podinfo/Chart.yaml": "name: podinfo\nversion: 3.1.0\ndescription: changed\n"})
+	mu.Unlock()
+	assert.NoError(t, fetchURLChart(path, source, downloadOptions{}))
+	second, _ := fileChecksum(path)
+	assert.NotEqual(t, first, second)
+}
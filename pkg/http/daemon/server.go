@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
@@ -13,6 +14,7 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // ListenAndServe starts a HTTP server instrumented with Prometheus metrics,
@@ -22,10 +24,8 @@ func ListenAndServe(listenAddr string, apiServer api.Server, logger log.Logger,
 
 	// setup metrics and health endpoints
 	mux.Handle("/metrics", promhttp.Handler())
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	mux.HandleFunc("/healthz", HealthzHandler(apiServer))
+	mux.HandleFunc("/readyz", ReadyzHandler(apiServer))
 
 	// setup api endpoints
 	handler := NewHandler(apiServer, transport.NewRouter())
@@ -60,10 +60,42 @@ func ListenAndServe(listenAddr string, apiServer api.Server, logger log.Logger,
 	}
 }
 
+// HealthzHandler returns a handler that writes back a HTTP 200 status
+// header and 'OK' body if the given server is healthy, and a HTTP 503
+// status header and the reason it is not healthy otherwise.
+func HealthzHandler(s api.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Healthy(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// ReadyzHandler returns a handler that writes back a HTTP 200 status
+// header and 'OK' body if the given server is ready, and a HTTP 503
+// status header and the reason it is not ready otherwise.
+func ReadyzHandler(s api.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Ready(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
 // NewHandler registers handlers on the given router.
 func NewHandler(s api.Server, r *mux.Router) http.Handler {
 	handle := &APIServer{server: s}
 	r.Get(transport.SyncGit).HandlerFunc(handle.SyncGit)
+	r.Get(transport.RenderRelease).HandlerFunc(handle.RenderRelease)
+	r.Get(transport.DiffRelease).HandlerFunc(handle.DiffRelease)
 	return r
 }
 
@@ -90,3 +122,63 @@ func (s *APIServer) SyncGit(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
+
+// RenderRelease writes back the manifests the release of the
+// HelmRelease in the request would apply, without releasing
+// anything. It writes back a HTTP 403 status header if rendering
+// releases is not allowed, a HTTP 404 status header if there is no
+// such HelmRelease, and a HTTP 500 status header and the error if
+// the manifests could not be rendered.
+func (s *APIServer) RenderRelease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	manifests, err := s.server.RenderRelease(vars["namespace"], vars["name"])
+	switch {
+	case err == api.ErrRenderReleaseDisabled:
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	case k8serrors.IsNotFound(err):
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(manifests))
+}
+
+// DiffRelease writes back, as JSON, the difference between the
+// current release of the HelmRelease in the request and the release
+// it would result in, without releasing anything. It writes back the
+// same HTTP status headers on failure as RenderRelease.
+func (s *APIServer) DiffRelease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	diff, err := s.server.DiffRelease(vars["namespace"], vars["name"])
+	switch {
+	case err == api.ErrRenderReleaseDisabled:
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	case k8serrors.IsNotFound(err):
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	b, err := json.Marshal(diff)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
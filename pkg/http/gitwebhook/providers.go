@@ -0,0 +1,158 @@
+package gitwebhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// The git providers of which the webhooks are understood.
+const (
+	providerGitHub    = "github"
+	providerGitLab    = "gitlab"
+	providerBitbucket = "bitbucket"
+)
+
+var (
+	errUnauthorized    = errors.New("webhook payload is not signed with the secret")
+	errUnknownProvider = errors.New("request is not a webhook of GitHub, GitLab or Bitbucket")
+)
+
+// push is a webhook event of a git provider.
+type push struct {
+	provider string
+	event    string
+	// repoURLs are the URLs of the repository pushed to; nil if the
+	// event is not a push.
+	repoURLs []string
+}
+
+// parsePush identifies the git provider the webhook with the given
+// headers and body comes from, verifies that it is signed with the
+// given secret, and returns the event. GitHub and Bitbucket sign the
+// body with an HMAC of the secret; GitLab sends the secret as a
+// token.
+func parsePush(h http.Header, body, secret []byte) (push, error) {
+	var p push
+	switch {
+	case h.Get("X-GitHub-Event") != "":
+		p = push{provider: providerGitHub, event: h.Get("X-GitHub-Event")}
+		sig := h.Get("X-Hub-Signature-256")
+		if sig == "" {
+			sig = h.Get("X-Hub-Signature")
+		}
+		if !validSignature(sig, body, secret) {
+			return p, errUnauthorized
+		}
+		if p.event != "push" {
+			return p, nil
+		}
+	case h.Get("X-Gitlab-Event") != "":
+		p = push{provider: providerGitLab, event: h.Get("X-Gitlab-Event")}
+		if len(secret) == 0 || !hmac.Equal([]byte(h.Get("X-Gitlab-Token")), secret) {
+			return p, errUnauthorized
+		}
+		if p.event != "Push Hook" && p.event != "Tag Push Hook" {
+			return p, nil
+		}
+	case h.Get("X-Event-Key") != "":
+		p = push{provider: providerBitbucket, event: h.Get("X-Event-Key")}
+		if !validSignature(h.Get("X-Hub-Signature"), body, secret) {
+			return p, errUnauthorized
+		}
+		// repo:push is sent by Bitbucket Cloud, repo:refs_changed by
+		// Bitbucket Server
+		if p.event != "repo:push" && p.event != "repo:refs_changed" {
+			return p, nil
+		}
+	default:
+		return p, errUnknownProvider
+	}
+
+	var payload struct {
+		Repository struct {
+			// GitHub
+			CloneURL string `json:"clone_url"`
+			SSHURL   string `json:"ssh_url"`
+			GitURL   string `json:"git_url"`
+			HTMLURL  string `json:"html_url"`
+			// GitLab
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+			// Bitbucket
+			Links struct {
+				HTML  json.RawMessage `json:"html"`
+				Clone []struct {
+					Href string `json:"href"`
+				} `json:"clone"`
+			} `json:"links"`
+		} `json:"repository"`
+		Project struct {
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return p, fmt.Errorf("unable to parse %s webhook payload: %s", p.provider, err)
+	}
+	repo := payload.Repository
+	urls := []string{repo.CloneURL, repo.SSHURL, repo.GitURL, repo.HTMLURL, repo.GitHTTPURL, repo.GitSSHURL,
+		payload.Project.GitHTTPURL, payload.Project.GitSSHURL}
+	// The HTML link of Bitbucket Cloud is an object
+	var html struct {
+		Href string `json:"href"`
+	}
+	if json.Unmarshal(repo.Links.HTML, &html) == nil {
+		urls = append(urls, html.Href)
+	}
+	for _, c := range repo.Links.Clone {
+		urls = append(urls, c.Href)
+	}
+
+	p.repoURLs = []string{}
+	for _, u := range urls {
+		if u != "" {
+			p.repoURLs = append(p.repoURLs, u)
+		}
+	}
+	if len(p.repoURLs) == 0 {
+		return p, fmt.Errorf("%s webhook payload names no repository", p.provider)
+	}
+	return p, nil
+}
+
+// validSignature returns if the given signature, as
+// '<algorithm>=<hex digest>', is the HMAC of the body with the
+// given secret. The algorithm is either sha256 or sha1.
+func validSignature(signature string, body, secret []byte) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	var h func() hash.Hash
+	switch parts[0] {
+	case "sha256":
+		h = sha256.New
+	case "sha1":
+		h = sha1.New
+	default:
+		return false
+	}
+	digest, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, secret)
+	mac.Write(body)
+	return hmac.Equal(digest, mac.Sum(nil))
+}
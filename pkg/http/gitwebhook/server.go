@@ -0,0 +1,112 @@
+package gitwebhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// PushPath is the path the receiver of git push webhooks is served
+// on.
+const PushPath = "/git-push"
+
+// maxPayloadSize is the size above which payloads are refused; it is
+// the largest payload GitHub sends.
+const maxPayloadSize = 25 << 20
+
+// Syncer syncs the mirrors of the git repositories that were pushed
+// to; it is implemented by the ChartChangeSync. QueueMirrorSync must
+// not block, as it is called for every push received.
+type Syncer interface {
+	QueueMirrorSync(repoURLs []string)
+}
+
+// ListenAndServe starts a HTTP server receiving the push webhooks of
+// git providers, signed with the given secret, on the specified
+// address.
+func ListenAndServe(listenAddr string, secret []byte, syncer Syncer, logger log.Logger, stopCh <-chan struct{}) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PushPath, PushHandler(secret, syncer, logger))
+
+	srv := &http.Server{
+		Addr:         listenAddr,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  15 * time.Second,
+	}
+
+	logger.Log("info", fmt.Sprintf("starting git webhook server on %s", listenAddr))
+
+	// run server in background
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Log("error", fmt.Sprintf("git webhook server crashed %v", err))
+		}
+	}()
+
+	// wait for close signal and attempt graceful shutdown
+	<-stopCh
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Log("warn", fmt.Sprintf("git webhook server graceful shutdown failed %v", err))
+	} else {
+		logger.Log("info", "git webhook server stopped")
+	}
+}
+
+// PushHandler returns a handler that verifies the webhook payload of
+// the git provider in the request with the given secret, and queues
+// the sync of the mirrors of the repository pushed to. It
+// writes back a HTTP 202 status header for pushes, a HTTP 200 status
+// header for other events (e.g. pings), which are ignored, a HTTP 401
+// status header if the payload is not signed with the secret, and a
+// HTTP 400 status header if it can not be parsed.
+func PushHandler(secret []byte, syncer Syncer, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPayloadSize+1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if len(body) > maxPayloadSize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		p, err := parsePush(r.Header, body, secret)
+		switch {
+		case err == errUnauthorized:
+			logger.Log("warning", "refused git webhook with invalid signature", "remote", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error()))
+			return
+		case err != nil:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		case p.repoURLs == nil:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("ignored " + p.event + " event"))
+			return
+		}
+
+		logger.Log("info", "received git push", "provider", p.provider, "repos", strings.Join(p.repoURLs, ","))
+		syncer.QueueMirrorSync(p.repoURLs)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("OK"))
+	}
+}
@@ -0,0 +1,147 @@
+package gitwebhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSyncer chan []string
+
+func (s fakeSyncer) QueueMirrorSync(repoURLs []string) {
+	select {
+	case s <- repoURLs:
+	default:
+		panic("QueueMirrorSync blocked")
+	}
+}
+
+func sign(h func() hash.Hash, secret, body string) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestPushHandler(t *testing.T) {
+	const secret = "s3cr3t"
+	github := `{"ref":"refs/heads/master","repository":{"clone_url":"https://github.com/fluxcd/podinfo.git","ssh_url":"git@github.com:fluxcd/podinfo.git"}}`
+	gitlab := `{"project":{"git_http_url":"https://gitlab.com/fluxcd/podinfo.git","git_ssh_url":"git@gitlab.com:fluxcd/podinfo.git"}}`
+	bitbucketCloud := `{"repository":{"links":{"html":{"href":"https://bitbucket.org/fluxcd/podinfo"}}}}`
+	bitbucketServer := `{"repository":{"links":{"clone":[{"href":"ssh://git@bitbucket.example.com:7999/flux/podinfo.git","name":"ssh"}]}}}`
+
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		body    string
+		status  int
+		repos   []string
+	}{
+		{
+			name:    "github push",
+			headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, github)},
+			body:    github,
+			status:  http.StatusAccepted,
+			repos:   []string{"https://github.com/fluxcd/podinfo.git", "git@github.com:fluxcd/podinfo.git"},
+		},
+		{
+			name:    "github push signed with sha1",
+			headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature": "sha1=" + sign(sha1.New, secret, github)},
+			body:    github,
+			status:  http.StatusAccepted,
+			repos:   []string{"https://github.com/fluxcd/podinfo.git", "git@github.com:fluxcd/podinfo.git"},
+		},
+		{
+			name:    "github push signed with another secret",
+			headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign(sha256.New, "other", github)},
+			body:    github,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "github push without signature",
+			headers: map[string]string{"X-GitHub-Event": "push"},
+			body:    github,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "github ping",
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, `{}`)},
+			body:    `{}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "gitlab push",
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": secret},
+			body:    gitlab,
+			status:  http.StatusAccepted,
+			repos:   []string{"https://gitlab.com/fluxcd/podinfo.git", "git@gitlab.com:fluxcd/podinfo.git"},
+		},
+		{
+			name:    "gitlab push with another token",
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "other"},
+			body:    gitlab,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "bitbucket cloud push",
+			headers: map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": "sha256=" + sign(sha256.New, secret, bitbucketCloud)},
+			body:    bitbucketCloud,
+			status:  http.StatusAccepted,
+			repos:   []string{"https://bitbucket.org/fluxcd/podinfo"},
+		},
+		{
+			name:    "bitbucket server push",
+			headers: map[string]string{"X-Event-Key": "repo:refs_changed", "X-Hub-Signature": "sha256=" + sign(sha256.New, secret, bitbucketServer)},
+			body:    bitbucketServer,
+			status:  http.StatusAccepted,
+			repos:   []string{"ssh://git@bitbucket.example.com:7999/flux/podinfo.git"},
+		},
+		{
+			name:   "unknown provider",
+			body:   github,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "invalid payload",
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": secret},
+			body:    `{"project":`,
+			status:  http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			syncer := make(fakeSyncer, 1)
+			req := httptest.NewRequest(http.MethodPost, PushPath, bytes.NewBufferString(tc.body))
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			PushHandler([]byte(secret), syncer, log.NewNopLogger())(rec, req)
+
+			assert.Equal(t, tc.status, rec.Code)
+			select {
+			case repos := <-syncer:
+				assert.Equal(t, tc.repos, repos)
+			case <-time.After(100 * time.Millisecond):
+				assert.Nil(t, tc.repos, "mirrors not synced")
+			}
+		})
+	}
+}
+
+func TestPushHandler_noSecret(t *testing.T) {
+	syncer := make(fakeSyncer, 1)
+	req := httptest.NewRequest(http.MethodPost, PushPath, bytes.NewBufferString(`{}`))
+	req.Header.Set("X-Gitlab-Event", "Push Hook")
+	rec := httptest.NewRecorder()
+	PushHandler(nil, syncer, log.NewNopLogger())(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -1,5 +1,7 @@
 package http
 
 const (
-	SyncGit = "SyncGit"
+	SyncGit       = "SyncGit"
+	RenderRelease = "RenderRelease"
+	DiffRelease   = "DiffRelease"
 )
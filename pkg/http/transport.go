@@ -9,5 +9,7 @@ import (
 func NewRouter() *mux.Router {
 	r := mux.NewRouter()
 	r.NewRoute().Name(SyncGit).Methods("POST").Path("/v1/sync-git")
+	r.NewRoute().Name(RenderRelease).Methods("GET").Path("/v1/render/{namespace}/{name}")
+	r.NewRoute().Name(DiffRelease).Methods("GET").Path("/v1/diff/{namespace}/{name}")
 	return r
 }
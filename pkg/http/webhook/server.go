@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/api/admission/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// ValidatePath is the path the validating admission webhook for
+// HelmReleases is served on.
+const ValidatePath = "/validate-helmrelease"
+
+// maxReviewSize is the size above which AdmissionReviews are refused;
+// a review holds at most the object and the old object, both of which
+// etcd limits to 1.5MiB by default.
+const maxReviewSize = 4 << 20
+
+// ListenAndServeTLS starts a HTTPS server serving the validating
+// admission webhook on the specified address, with the certificate
+// and private key in the given files.
+func ListenAndServeTLS(listenAddr, certFile, keyFile string, logger log.Logger, stopCh <-chan struct{}) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ValidatePath, ValidateHandler(logger))
+
+	srv := &http.Server{
+		Addr:         listenAddr,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  15 * time.Second,
+	}
+
+	logger.Log("info", fmt.Sprintf("starting admission webhook server on %s", listenAddr))
+
+	// run server in background
+	go func() {
+		if err := srv.ListenAndServeTLS(certFile, keyFile); err != http.ErrServerClosed {
+			logger.Log("error", fmt.Sprintf("admission webhook server crashed %v", err))
+		}
+	}()
+
+	// wait for close signal and attempt graceful shutdown
+	<-stopCh
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Log("warn", fmt.Sprintf("admission webhook server graceful shutdown failed %v", err))
+	} else {
+		logger.Log("info", "admission webhook server stopped")
+	}
+}
+
+// ValidateHandler returns a handler that reviews the HelmRelease in
+// the AdmissionReview of the request, and writes back an
+// AdmissionReview that denies it if it is invalid. It writes back a
+// HTTP 400 status header if the request holds no AdmissionReview, and
+// a HTTP 413 status header if it is too large to be one.
+func ValidateHandler(logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxReviewSize+1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if len(body) > maxReviewSize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		var review v1beta1.AdmissionReview
+		if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("expected an AdmissionReview request"))
+			return
+		}
+
+		review.Response = admit(review.Request)
+		review.Response.UID = review.Request.UID
+		if !review.Response.Allowed {
+			logger.Log("info", "denied HelmRelease", "resource", review.Request.Namespace+"/"+review.Request.Name, "reason", review.Response.Result.Message)
+		}
+		review.Request = nil
+
+		b, err := json.Marshal(review)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+// admit reviews the HelmRelease in the given admission request.
+func admit(req *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	// Nothing is left to validate of a HelmRelease being deleted
+	if req.Operation == v1beta1.Delete || len(req.Object.Raw) == 0 {
+		return &v1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	var hr helmfluxv1.HelmRelease
+	if err := json.Unmarshal(req.Object.Raw, &hr); err != nil {
+		return deny(fmt.Sprintf("invalid HelmRelease: %s", err))
+	}
+	// Only a new spec is validated, so that the finalizers, labels,
+	// annotations and status of a HelmRelease that was admitted before
+	// (or without) validation can still be updated, and a HelmRelease
+	// that is being deleted can always be let go of.
+	if req.Operation == v1beta1.Update {
+		if hr.DeletionTimestamp != nil {
+			return &v1beta1.AdmissionResponse{Allowed: true}
+		}
+		var old helmfluxv1.HelmRelease
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err == nil && apiequality.Semantic.DeepEqual(old.Spec, hr.Spec) {
+			return &v1beta1.AdmissionResponse{Allowed: true}
+		}
+	}
+	// The namespace of a HelmRelease may only be given by the request
+	if hr.Namespace == "" {
+		hr.Namespace = req.Namespace
+	}
+	if errs := Validate(hr); len(errs) > 0 {
+		return deny(fmt.Sprintf("invalid HelmRelease: %s", strings.Join(errs, "; ")))
+	}
+	return &v1beta1.AdmissionResponse{Allowed: true}
+}
+
+// deny returns an admission response that denies the request with
+// the given message.
+func deny(msg string) *v1beta1.AdmissionResponse {
+	return &v1beta1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: msg,
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		},
+	}
+}
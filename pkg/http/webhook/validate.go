@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/helm/pkg/strvals"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// releaseNameMaxLen is the maximum length of a release name Tiller
+// accepts.
+const releaseNameMaxLen = 53
+
+// Validate returns the reasons the given HelmRelease is invalid, as
+// '<field>: <reason>', or nil if it is valid. Only what can be
+// determined from the HelmRelease itself is validated; e.g. the
+// existence of the chart is left to the reconciliation.
+func Validate(hr helmfluxv1.HelmRelease) []string {
+	var errs []string
+	invalid := func(field, format string, a ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, a...))
+	}
+
+	spec := hr.Spec
+	var sources []string
+	if spec.GitChartSource != nil {
+		sources = append(sources, "git")
+		if spec.GitChartSource.GitURL == "" {
+			invalid("spec.chart.git", "required for a chart from a Git repo")
+		}
+		if spec.GitChartSource.Path == "" {
+			invalid("spec.chart.path", "required for a chart from a Git repo")
+		}
+		if r, ok := spec.GitChartSource.SemverRange(); ok && r == "" {
+			invalid("spec.chart.ref", "must give a semver range of tags after '%s'", helmfluxv1.SemverRefPrefix)
+		}
+		if spec.GitChartSource.IsSSH() {
+			if _, err := spec.GitChartSource.SSHHost(); err != nil {
+				invalid("spec.chart.git", "%s", err)
+			}
+		}
+		if spec.GitChartSource.SSHSecretRef != nil && !spec.GitChartSource.IsSSH() {
+			invalid("spec.chart.sshSecretRef", "only supported for a Git repo cloned over SSH")
+		}
+		if spec.GitChartSource.GitHubAppSecretRef != nil && !spec.GitChartSource.IsHTTPS() {
+			invalid("spec.chart.githubAppSecretRef", "only supported for a Git repo cloned over HTTPS")
+		}
+		for i, file := range spec.GitChartSource.ValuesFiles {
+			if _, err := spec.GitChartSource.ValuesFilePath(file); err != nil {
+				invalid(fmt.Sprintf("spec.chart.valuesFiles[%d]", i), "%s", err)
+			}
+		}
+	}
+	if spec.RepoChartSource != nil {
+		sources = append(sources, "repository")
+		if spec.RepoChartSource.RepoURL == "" {
+			invalid("spec.chart.repository", "required for a chart from a Helm repo")
+		}
+		if spec.RepoChartSource.Name == "" {
+			invalid("spec.chart.name", "required for a chart from a Helm repo")
+		}
+		if spec.RepoChartSource.Version == "" {
+			invalid("spec.chart.version", "required for a chart from a Helm repo")
+		}
+		if sub := spec.RepoChartSource.Subpath; sub != "" {
+			if clean := path.Clean(sub); path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+				invalid("spec.chart.subpath", "must be a relative path within the chart")
+			}
+		}
+		if spec.RepoChartSource.IsOCI() {
+			if spec.RepoChartSource.TokenAuth != nil {
+				invalid("spec.chart.tokenAuth", "not supported for a chart from an OCI registry")
+			}
+		} else if spec.RepoChartSource.RegistrySecretRef != nil {
+			invalid("spec.chart.registrySecretRef", "only supported for a chart from an OCI registry")
+		}
+		if spec.RepoChartSource.BucketSecretRef != nil && !spec.RepoChartSource.IsBucket() {
+			invalid("spec.chart.bucketSecretRef", "only supported for a chart repo in a bucket")
+		}
+	}
+	if spec.ConfigMapChartSource != nil {
+		sources = append(sources, "configMap")
+		if spec.ConfigMapChartSource.ConfigMap.Name == "" {
+			invalid("spec.chart.configMap.name", "required for a chart from a config map")
+		}
+	}
+	if spec.SourceRefChartSource != nil {
+		sources = append(sources, "sourceRef")
+		ref := spec.SourceRefChartSource.SourceRef
+		if ref.Name == "" {
+			invalid("spec.chart.sourceRef.name", "required for a chart from a source object")
+		}
+		switch ref.Kind {
+		case helmfluxv1.SourceKindGitRepository:
+			if ref.Path == "" {
+				invalid("spec.chart.sourceRef.path", "required for a chart from a GitRepository")
+			}
+		case helmfluxv1.SourceKindHelmRepository:
+			if ref.Chart == "" {
+				invalid("spec.chart.sourceRef.chart", "required for a chart from a HelmRepository")
+			}
+			if ref.Version == "" {
+				invalid("spec.chart.sourceRef.version", "required for a chart from a HelmRepository")
+			}
+		default:
+			invalid("spec.chart.sourceRef.kind", "must be one of GitRepository or HelmRepository")
+		}
+	}
+	if spec.URLChartSource != nil {
+		sources = append(sources, "url")
+		if u, err := url.Parse(spec.URLChartSource.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("spec.chart.url", "must be a HTTP(S) URL of a chart archive")
+		}
+		if sum := spec.URLChartSource.SHA256; sum != "" {
+			if b, err := hex.DecodeString(sum); err != nil || len(b) != 32 {
+				invalid("spec.chart.sha256", "must be a hex encoded SHA256 checksum")
+			}
+		}
+	}
+	switch len(sources) {
+	case 0:
+		invalid("spec.chart", "exactly one of git, repository, configMap, sourceRef or url must be set")
+	case 1:
+	default:
+		invalid("spec.chart", "exactly one of git, repository, configMap, sourceRef or url must be set, got %s", strings.Join(sources, ", "))
+	}
+	repoChart := spec.RepoChartSource != nil ||
+		(spec.SourceRefChartSource != nil && spec.SourceRefChartSource.SourceRef.Kind == helmfluxv1.SourceKindHelmRepository)
+	if spec.Verify != nil && !repoChart {
+		invalid("spec.verify", "only supported for charts from Helm repos")
+	}
+
+	for i, source := range spec.ValuesFrom {
+		var n int
+		for _, set := range []bool{source.ConfigMapKeyRef != nil, source.SecretKeyRef != nil,
+			source.ConfigMapKeysRef != nil, source.SecretKeysRef != nil,
+			source.ExternalSourceRef != nil, source.ChartFileRef != nil} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			invalid(fmt.Sprintf("spec.valuesFrom[%d]", i), "exactly one of configMapKeyRef, secretKeyRef, configMapKeysRef, secretKeysRef, externalSourceRef or chartFileRef must be set")
+		}
+		sel, field := source.ConfigMapKeysRef, "configMapKeysRef"
+		if sel == nil {
+			sel, field = source.SecretKeysRef, "secretKeysRef"
+		}
+		if sel != nil {
+			seen := make(map[string]bool)
+			for j, k := range sel.Keys {
+				field := fmt.Sprintf("spec.valuesFrom[%d].%s.keys[%d].key", i, field, j)
+				if k.Key == "" {
+					invalid(field, "must be set")
+				} else if seen[k.Key] {
+					invalid(field, "duplicate key '%s'", k.Key)
+				}
+				seen[k.Key] = true
+			}
+		}
+	}
+
+	for i, o := range spec.ValuesOverrides {
+		field := fmt.Sprintf("spec.valuesOverrides[%d]", i)
+		var err error
+		switch {
+		case o.Set != "" && o.SetString != "":
+			invalid(field, "only one of set or setString may be set")
+			continue
+		case o.Set != "":
+			err = strvals.ParseInto(o.Set, map[string]interface{}{})
+		case o.SetString != "":
+			err = strvals.ParseIntoString(o.SetString, map[string]interface{}{})
+		default:
+			invalid(field, "one of set or setString must be set")
+			continue
+		}
+		if err != nil {
+			invalid(field, "%s", err)
+		}
+	}
+
+	switch spec.ConflictStrategy {
+	case "", helmfluxv1.ConflictStrategySkip, helmfluxv1.ConflictStrategyAdopt, helmfluxv1.ConflictStrategyFail:
+	default:
+		invalid("spec.conflictStrategy", "must be one of skip, adopt or fail")
+	}
+
+	if name := spec.ReleaseName; name != "" {
+		if len(name) > releaseNameMaxLen {
+			invalid("spec.releaseName", "must be no more than %d characters", releaseNameMaxLen)
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			invalid("spec.releaseName", "%s", msg)
+		}
+	}
+	if ns := spec.TargetNamespace; ns != "" {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			invalid("spec.targetNamespace", "%s", msg)
+		}
+	}
+	if sa := spec.ServiceAccountName; sa != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(sa) {
+			invalid("spec.serviceAccountName", "%s", msg)
+		}
+	}
+
+	self := hr.GetDefaultedNamespace() + "/" + hr.Name
+	for i, dep := range hr.GetDependsOn() {
+		field := fmt.Sprintf("spec.dependsOn[%d]", i)
+		namespace, name, err := cache.SplitMetaNamespaceKey(dep)
+		switch {
+		case err != nil:
+			invalid(field, "%s", err)
+		case namespace == "" || name == "":
+			invalid(field, "must be a name or a namespace/name")
+		case dep == self:
+			invalid(field, "a HelmRelease can not depend on itself")
+		}
+	}
+
+	return errs
+}
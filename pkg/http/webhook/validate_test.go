@@ -0,0 +1,275 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestValidate(t *testing.T) {
+	repoChart := helmfluxv1.ChartSource{RepoChartSource: &helmfluxv1.RepoChartSource{
+		RepoURL: "https://stefanprodan.github.io/podinfo", Name: "podinfo", Version: "3.2.0"}}
+	gitChart := helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+		GitURL: "git@github.com:stefanprodan/podinfo", Path: "charts/podinfo"}}
+
+	for _, tc := range []struct {
+		name string
+		spec helmfluxv1.HelmReleaseSpec
+		errs []string
+	}{
+		{
+			name: "valid",
+			spec: helmfluxv1.HelmReleaseSpec{
+				ChartSource:     repoChart,
+				ReleaseName:     "podinfo",
+				ValuesOverrides: []helmfluxv1.ValuesOverride{{Set: "image.tag=3.2.0"}},
+				DependsOn:       []string{"redis", "infra/ingress"},
+			},
+		},
+		{
+			name: "bucket secret for Helm repo in a GCS bucket",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{RepoChartSource: &helmfluxv1.RepoChartSource{
+				RepoURL: "gs://example-charts/stable", Name: "podinfo", Version: "3.2.0",
+				BucketSecretRef: &corev1.LocalObjectReference{Name: "charts-bucket"}}}},
+		},
+		{
+			name: "bucket secret for Helm repo in an Azure Blob Storage container",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{RepoChartSource: &helmfluxv1.RepoChartSource{
+				RepoURL: "azblob://examplecharts/charts/stable", Name: "podinfo", Version: "3.2.0",
+				BucketSecretRef: &corev1.LocalObjectReference{Name: "charts-container"}}}},
+		},
+		{
+			name: "no chart source",
+			errs: []string{"spec.chart: exactly one of git, repository, configMap, sourceRef or url must be set"},
+		},
+		{
+			name: "conflicting chart sources",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{
+				GitChartSource:  gitChart.GitChartSource,
+				RepoChartSource: repoChart.RepoChartSource,
+			}},
+			errs: []string{"spec.chart: exactly one of git, repository, configMap, sourceRef or url must be set, got git, repository"},
+		},
+		{
+			name: "incomplete chart source",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{
+				GitChartSource: &helmfluxv1.GitChartSource{GitURL: "git@github.com:stefanprodan/podinfo"},
+			}},
+			errs: []string{"spec.chart.path: required for a chart from a Git repo"},
+		},
+		{
+			name: "verify git chart",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: gitChart, Verify: &helmfluxv1.Verify{}},
+			errs: []string{"spec.verify: only supported for charts from Helm repos"},
+		},
+		{
+			name: "invalid values",
+			spec: helmfluxv1.HelmReleaseSpec{
+				ChartSource: repoChart,
+				ValuesFrom: []helmfluxv1.ValuesFromSource{{}, {SecretKeysRef: &helmfluxv1.ObjectKeysSelector{
+					Name: "values", Keys: []helmfluxv1.KeySelector{{Key: "a.yaml"}, {}, {Key: "a.yaml"}}}}},
+				ValuesOverrides: []helmfluxv1.ValuesOverride{{Set: "a=b", SetString: "c=d"}, {}},
+			},
+			errs: []string{
+				"spec.valuesFrom[0]: exactly one of configMapKeyRef, secretKeyRef, configMapKeysRef, secretKeysRef, externalSourceRef or chartFileRef must be set",
+				"spec.valuesFrom[1].secretKeysRef.keys[1].key: must be set",
+				"spec.valuesFrom[1].secretKeysRef.keys[2].key: duplicate key 'a.yaml'",
+				"spec.valuesOverrides[0]: only one of set or setString may be set",
+				"spec.valuesOverrides[1]: one of set or setString must be set",
+			},
+		},
+		{
+			name: "incomplete source refs",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{SourceRefChartSource: &helmfluxv1.SourceRefChartSource{
+				SourceRef: helmfluxv1.ChartSourceReference{Kind: helmfluxv1.SourceKindGitRepository, Name: "podinfo"}}}},
+			errs: []string{"spec.chart.sourceRef.path: required for a chart from a GitRepository"},
+		},
+		{
+			name: "verify git repository source ref",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{SourceRefChartSource: &helmfluxv1.SourceRefChartSource{
+				SourceRef: helmfluxv1.ChartSourceReference{Kind: helmfluxv1.SourceKindGitRepository, Name: "podinfo", Path: "charts/podinfo"}}},
+				Verify: &helmfluxv1.Verify{}},
+			errs: []string{"spec.verify: only supported for charts from Helm repos"},
+		},
+		{
+			name: "subpath outside chart",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{RepoChartSource: &helmfluxv1.RepoChartSource{
+				RepoURL: "https://stefanprodan.github.io/podinfo", Name: "podinfo", Version: "3.2.0", Subpath: "../other"}}},
+			errs: []string{"spec.chart.subpath: must be a relative path within the chart"},
+		},
+		{
+			name: "registry secret for Helm repo",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{RepoChartSource: &helmfluxv1.RepoChartSource{
+				RepoURL: "https://stefanprodan.github.io/podinfo", Name: "podinfo", Version: "3.2.0",
+				RegistrySecretRef: &corev1.LocalObjectReference{Name: "ghcr"}}}},
+			errs: []string{"spec.chart.registrySecretRef: only supported for a chart from an OCI registry"},
+		},
+		{
+			name: "bucket secret for Helm repo",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{RepoChartSource: &helmfluxv1.RepoChartSource{
+				RepoURL: "https://stefanprodan.github.io/podinfo", Name: "podinfo", Version: "3.2.0",
+				BucketSecretRef: &corev1.LocalObjectReference{Name: "charts-bucket"}}}},
+			errs: []string{"spec.chart.bucketSecretRef: only supported for a chart repo in a bucket"},
+		},
+		{
+			name: "empty semver range of tags",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+				GitURL: "https://github.com/stefanprodan/podinfo", Ref: "semver:", Path: "charts/podinfo"}}},
+			errs: []string{"spec.chart.ref: must give a semver range of tags after 'semver:'"},
+		},
+		{
+			name: "SSH key for Git repo cloned over SSH",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+				GitURL: "ssh://git@github.com/stefanprodan/podinfo", Path: "charts/podinfo",
+				SSHSecretRef: &corev1.LocalObjectReference{Name: "podinfo-deploy-key"}}}},
+		},
+		{
+			name: "SSH key for Git repo cloned over HTTPS",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+				GitURL: "https://github.com/stefanprodan/podinfo", Path: "charts/podinfo",
+				SSHSecretRef: &corev1.LocalObjectReference{Name: "podinfo-deploy-key"}}}},
+			errs: []string{"spec.chart.sshSecretRef: only supported for a Git repo cloned over SSH"},
+		},
+		{
+			name: "Git repo cloned over SSH from an invalid host",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+				GitURL: "git@evil\nProxyCommand sh\nUser x:org/repo", Path: "charts/podinfo"}}},
+			errs: []string{`spec.chart.git: git URL "git@evil\nProxyCommand sh\nUser x:org/repo" contains whitespace or control characters`},
+		},
+		{
+			name: "GitHub App for Git repo cloned over HTTPS",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+				GitURL: "https://github.com/stefanprodan/podinfo", Path: "charts/podinfo",
+				GitHubAppSecretRef: &corev1.LocalObjectReference{Name: "podinfo-github-app"}}}},
+		},
+		{
+			name: "GitHub App for Git repo cloned over SSH",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+				GitURL: "git@github.com:stefanprodan/podinfo", Path: "charts/podinfo",
+				GitHubAppSecretRef: &corev1.LocalObjectReference{Name: "podinfo-github-app"}}}},
+			errs: []string{"spec.chart.githubAppSecretRef: only supported for a Git repo cloned over HTTPS"},
+		},
+		{
+			name: "values file that can not be read relative to the chart",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{GitChartSource: &helmfluxv1.GitChartSource{
+				GitURL: "https://github.com/stefanprodan/podinfo", Path: "../charts/podinfo",
+				ValuesFiles: []string{"values/prod.yaml"}}}},
+			errs: []string{"spec.chart.valuesFiles[0]: values file values/prod.yaml can not be read relative to the chart: Rel: can't make values/prod.yaml relative to ../charts/podinfo"},
+		},
+		{
+			name: "invalid chart archive URL",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{URLChartSource: &helmfluxv1.URLChartSource{
+				URL: "ftp://artifacts.example.com/podinfo-3.2.0.tgz", SHA256: "0123abcd"}}},
+			errs: []string{
+				"spec.chart.url: must be a HTTP(S) URL of a chart archive",
+				"spec.chart.sha256: must be a hex encoded SHA256 checksum",
+			},
+		},
+		{
+			name: "token auth for OCI registry",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: helmfluxv1.ChartSource{RepoChartSource: &helmfluxv1.RepoChartSource{
+				RepoURL: "oci://ghcr.io/stefanprodan/charts", Name: "podinfo", Version: "6.0.0",
+				TokenAuth: &helmfluxv1.RepoTokenAuth{TokenURL: "https://auth.example.com/token"}}}},
+			errs: []string{"spec.chart.tokenAuth: not supported for a chart from an OCI registry"},
+		},
+		{
+			name: "unknown conflict strategy",
+			spec: helmfluxv1.HelmReleaseSpec{ChartSource: repoChart, ConflictStrategy: "overwrite"},
+			errs: []string{"spec.conflictStrategy: must be one of skip, adopt or fail"},
+		},
+		{
+			name: "invalid names",
+			spec: helmfluxv1.HelmReleaseSpec{
+				ChartSource:     repoChart,
+				ReleaseName:     "a-very-long-release-name-that-tiller-will-never-accept",
+				TargetNamespace: "Not_A_Namespace",
+				DependsOn:       []string{"podinfo", "a/b/c"},
+			},
+			errs: []string{
+				"spec.releaseName: must be no more than 53 characters",
+				"spec.targetNamespace: a DNS-1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')",
+				"spec.dependsOn[0]: a HelmRelease can not depend on itself",
+				"spec.dependsOn[1]: unexpected key format: \"a/b/c\"",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hr := helmfluxv1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "default"},
+				Spec:       tc.spec,
+			}
+			assert.Equal(t, tc.errs, Validate(hr))
+		})
+	}
+}
+
+func TestValidateHandler(t *testing.T) {
+	update := func(old, raw string) v1beta1.AdmissionReview {
+		req := &v1beta1.AdmissionRequest{
+			UID:       types.UID("uid"),
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(raw)},
+		}
+		if old != "" {
+			req.Operation, req.OldObject = v1beta1.Update, runtime.RawExtension{Raw: []byte(old)}
+		}
+		b, err := json.Marshal(v1beta1.AdmissionReview{Request: req})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		ValidateHandler(log.NewNopLogger())(rec, httptest.NewRequest(http.MethodPost, ValidatePath, bytes.NewReader(b)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res v1beta1.AdmissionReview
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, types.UID("uid"), res.Response.UID)
+		return res
+	}
+	review := func(raw string) v1beta1.AdmissionReview {
+		return update("", raw)
+	}
+
+	res := review(`{"metadata":{"name":"podinfo"},"spec":{"chart":{"repository":"https://stefanprodan.github.io/podinfo","name":"podinfo","version":"3.2.0"},"values":{"replicaCount":2}}}`)
+	assert.True(t, res.Response.Allowed)
+
+	res = review(`{"metadata":{"name":"podinfo"},"spec":{"chart":{"repository":"https://stefanprodan.github.io/podinfo","name":"podinfo","version":"3.2.0"},"values":"replicaCount: 2"}}`)
+	assert.False(t, res.Response.Allowed)
+	assert.Contains(t, res.Response.Result.Message, "invalid HelmRelease")
+
+	res = review(`{"metadata":{"name":"podinfo"},"spec":{"chart":{}}}`)
+	assert.False(t, res.Response.Allowed)
+	assert.Equal(t, "invalid HelmRelease: spec.chart: exactly one of git, repository, configMap, sourceRef or url must be set", res.Response.Result.Message)
+
+	// Updates that leave an (invalid) spec as is are admitted
+	invalid := `{"metadata":{"name":"podinfo"},"spec":{"chart":{}}}`
+	res = update(invalid, `{"metadata":{"name":"podinfo","finalizers":[]},"spec":{"chart":{}}}`)
+	assert.True(t, res.Response.Allowed)
+	res = update(invalid, `{"metadata":{"name":"podinfo","deletionTimestamp":"2020-06-01T12:00:00Z"},"spec":{"chart":{"version":"1.0.0"}}}`)
+	assert.True(t, res.Response.Allowed)
+	res = update(invalid, `{"metadata":{"name":"podinfo"},"spec":{"chart":{"version":"1.0.0"}}}`)
+	assert.False(t, res.Response.Allowed)
+
+	rec := httptest.NewRecorder()
+	ValidateHandler(log.NewNopLogger())(rec, httptest.NewRequest(http.MethodPost, ValidatePath, bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	ValidateHandler(log.NewNopLogger())(rec, httptest.NewRequest(http.MethodPost, ValidatePath, bytes.NewReader(make([]byte, maxReviewSize+1))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
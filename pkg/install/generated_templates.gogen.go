@@ -26,28 +26,28 @@ var templates = func() http.FileSystem {
 			modTime:          time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 			uncompressedSize: 948,
 
-			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x92\x39\x6f\xdc\x30\x10\x85\x7b\xfe\x8a\x01\x5c\x38\x09\x2c\x05\xee\x02\x75\xb6\x8b\x14\x09\x52\x28\x47\x13\xa4\x18\x92\x4f\x59\xc6\x5c\x8e\x30\x24\x37\x87\xb0\xff\x3d\x90\xb4\x06\xbc\x8e\xed\x34\xdb\x8d\xe6\xd2\x9b\xc7\xaf\x69\x1a\x73\x46\x9f\x36\xa0\x0c\xdd\x05\x07\x62\xe7\xa4\xa6\x72\x41\x2e\xd6\x5c\xa0\xa4\x12\x91\x2f\x88\x93\x3f\x4a\x91\x0d\xc9\x87\xf4\x9d\x58\x61\xce\x48\x52\xfc\x4d\x09\xf0\xf0\x34\x88\xd2\xbb\x6a\xa1\x09\x05\x99\x7e\x86\xb2\x59\x46\x1a\xcb\x19\x7e\xfe\x03\x72\x26\x27\xa9\xa8\x44\x7a\xd1\x5f\x5f\xdd\xbc\x6c\x0d\x8f\xe1\x0b\x34\x07\x49\x1d\xed\x2e\xcd\x6d\x48\xbe\xa3\x8f\xab\xaa\xab\x55\x94\xd9\xa2\xb0\xe7\xc2\x9d\x21\x8a\x6c\x11\xf3\x1c\x11\x25\xde\xa2\xa3\x21\xd6\x5f\xcd\x06\x71\xdb\xc8\x08\xe5\x22\x6a\x9e\x2e\x4d\x13\x85\x81\xda\x0f\xbc\x45\x1e\xd9\x81\xf6\xfb\x43\xf7\xf2\xd9\xd1\x34\x1d\x57\xa7\x89\x90\xfc\x7e\x6f\x66\xcf\xee\x8b\x55\xcb\xae\xe5\x5a\x36\xa2\xe1\x0f\x97\x20\xa9\xbd\x7d\x93\xdb\x20\xaf\x77\x97\x16\x85\xef\x6e\xb9\x59\xdd\xeb\x25\xe2\x94\x87\x18\xad\x11\xcb\x78\x43\x3c\x86\xb7\x2a\x75\xcc\x1d\x7d\x3d\x7f\x75\xfe\x6d\xd9\xa9\xc8\x52\xd5\xe1\x28\xb9\x83\xda\x7b\x89\x86\x92\xa4\xfe\xd0\xf8\xb9\x7f\xff\x74\xef\x09\xae\xbf\x5e\xc9\x39\xad\x09\x12\xd1\x63\x98\x17\xdc\x99\xf0\x8c\x36\x43\xf4\xef\x9b\x3c\xb3\x3d\x57\xfb\x03\xae\x1c\x5c\x7e\x14\xcd\xff\x08\x7f\x88\xd6\x43\xf6\x1e\xa3\x2d\xe6\x39\xf2\x18\xb8\xc6\xb2\xe2\x37\x53\xfa\x37\x00\x00\xff\xff\xad\xec\xff\x2b\xb4\x03\x00\x00"),
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x92\x3b\x6f\xdb\x30\x14\x85\x77\xfe\x8a\x03\x64\x48\x5b\x44\x2e\xb2\x15\xda\x92\x0c\x1d\x5a\x74\x50\x1f\x4b\xd1\xe1\x8a\x3c\xaa\xd9\xd0\xa4\x70\x49\xba\x0f\x41\xff\xbd\x90\xed\x00\x71\x9a\xa4\x8b\xb7\xab\xfb\xd2\xb9\x1f\x4f\xd3\x34\xe6\x0c\x9f\xd6\x44\xa6\x6e\xbd\x25\xc4\xda\x54\x63\xb9\x80\x0d\x35\x17\x2a\x34\x05\xe6\x0b\x48\x74\x47\x29\xf4\x3e\x3a\x1f\xbf\x43\x94\xe6\x0c\x29\x86\xdf\x88\xa4\xa3\xc3\x90\x14\xef\x6a\x4f\x8d\x2c\xcc\xf8\xe9\xcb\x7a\x37\xd2\xf4\x92\xe9\x96\x3f\x30\x67\xd8\x14\x8b\xa6\x80\x17\xdd\xf5\xd5\xcd\xcb\x95\x91\xd1\x7f\xa1\x66\x9f\x62\x8b\xed\xa5\xb9\xf5\xd1\xb5\xf8\xb8\x57\x75\xb5\x17\x65\x36\x2c\xe2\xa4\x48\x6b\x80\x20\x3d\x43\x5e\x22\x20\xca\x86\x2d\x86\x50\x7f\x35\x6b\x86\x4d\x93\x46\xaa\x94\xa4\xe6\xe9\xd2\x34\xc1\x0f\x58\x7d\x90\x0d\xf3\x28\x96\x98\xe7\x43\xf7\xee\xb3\xc5\x34\x1d\x57\xa7\x09\x8c\x6e\x9e\xcd\xc2\xec\xbe\x58\xed\xc5\xae\xa4\x96\x75\x52\xff\x47\x8a\x4f\x71\x75\xfb\x26\xaf\x7c\x7a\xbd\xbd\xec\x59\xe4\xee\x96\x9b\x3d\xbd\x2e\x05\x9e\xf2\x10\xa3\x35\x70\x37\xde\x40\x46\xff\x56\x53\x1d\x73\x8b\xaf\xe7\xaf\xce\xbf\xed\x76\x2a\x73\xaa\x6a\x79\x94\xdc\x52\xfb\x7b\x89\x06\x31\xc5\xee\xd0\xf8\xb9\x7b\xff\x74\xef\x09\xae\xbf\xde\x3b\xe7\xb4\x10\x52\x60\xc7\x61\xc1\x70\x07\xe1\x19\x6d\x06\xf8\xf7\x4d\x9e\xd9\x9e\x6b\xff\x83\xb6\x1c\x28\x3f\x6a\xcd\xff\x08\x7f\x68\xad\x87\xde\x7b\xcc\x6d\x21\x2f\x91\xe3\x20\x35\x94\x69\x02\xa3\xc3\x3c\x9b\xbf\x03\x00\xad\xec\xff\x2b\xb4\x03\x00\x00"),
 		},
 		"/flux-helm-release-crd.yaml.tmpl": &vfsgen۰CompressedFileInfo{
 			name:             "flux-helm-release-crd.yaml.tmpl",
 			modTime:          time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
-			uncompressedSize: 7426,
+			uncompressedSize: 30307,
 
-			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xec\x59\x5f\x6f\xdb\x38\x12\x7f\xf7\xa7\x98\xcb\x1d\x90\xe4\x10\xab\x2d\x7a\x38\xdc\x19\x28\xda\xa2\x41\xaf\xbd\xf4\x4f\x90\x34\x7d\x09\xba\xc0\x58\x1a\x49\xdc\x50\xa4\x76\x48\x3a\xf1\x2e\xf6\xbb\x2f\x48\xfd\xb1\xa5\xc8\xb2\xdd\xa6\x0f\x8b\xae\x5e\x5a\x93\x9c\xe1\x6f\x66\x7e\x33\x43\x32\xd3\xe9\x74\x82\xa5\xf8\x4c\x6c\x84\x56\x33\xc0\x52\xd0\x9d\x25\xe5\x7f\x99\xe8\xe6\x3f\x26\x12\xfa\xd1\xe2\xc9\x9c\x2c\x3e\x99\xdc\x08\x95\xcc\xe0\x95\x33\x56\x17\x17\x64\xb4\xe3\x98\x4e\x29\x15\x4a\x58\xa1\xd5\xa4\x20\x8b\x09\x5a\x9c\x4d\x00\x14\x16\x34\x83\x9c\x64\xc1\x24\x09\x0d\x99\xc8\xff\x88\x52\xe9\xee\xe2\x24\x12\x7a\x62\x4a\x8a\xfd\xca\x8c\xb5\x2b\xab\xa5\x6b\xb3\x95\x06\xe3\x17\x00\x54\xfb\xbe\x21\x59\x5c\x54\xca\xc2\xa8\x14\xc6\x9e\xf5\x67\xde\x09\x63\xc3\x6c\x29\x1d\xa3\xec\x42\x08\x13\x26\xd7\x6c\x3f\xac\x94\x4f\x21\xe7\x09\x80\x89\x75\x49\x33\x08\x13\x25\xc6\x94\x4c\x00\x30\x49\x82\x65\x28\xcf\x59\x28\x4b\xfc\x4a\x4b\x57\xa8\x56\xf0\xff\x97\x1f\x3f\x9c\xa3\xcd\x67\x10\x19\x8b\xd6\x99\xa8\xde\xc9\x6b\x09\x6b\x1a\x47\xac\xe3\x06\xb0\x4b\xbf\x95\xb1\x2c\x54\xb6\x4d\xd5\x65\xf8\xd5\x51\xd6\x19\xda\x49\x57\xac\x55\x65\x89\xb9\x7e\x7e\xf4\x22\xf2\x32\xcf\x9e\x1d\xd4\xa0\x92\x83\xe3\x2f\x51\x41\xc6\x60\xd6\x05\xfd\xbe\x33\x36\xbe\x51\x13\xfb\x28\x66\x42\xbf\xd3\x27\x51\x90\xb1\x58\x94\x1d\x95\x2f\x7b\xea\x12\xb4\x7e\xc0\xb8\x39\xd7\x7c\xaa\x9d\x5b\x01\x9f\xc1\x6f\xbf\x4f\x00\x16\x0d\x3b\x17\x4f\x56\xbf\xda\x28\x54\x9a\xc3\x54\x90\x24\x5e\x50\x32\x03\xcb\xae\xd9\xcb\x58\xcd\x98\x51\x3b\xb6\x40\x29\x92\x80\xb2\xd2\xa1\x4b\x52\x2f\xcf\xdf\x7e\x7e\x7a\x19\xe7\x54\xe0\xac\x16\x2b\x59\x97\xc4\x56\x34\x98\x82\xaa\x9a\xb5\xcd\xc7\xf4\x8b\x13\xec\xf7\xbb\x3e\x8c\x73\x64\x7b\xf8\x65\x6d\x76\x48\x43\x25\xd5\xd2\xa4\x3b\x01\x90\x90\x89\x59\x94\x01\x1c\x7c\xca\x29\x90\xbb\x11\x08\xb6\x46\xf0\x36\x05\xa5\x2d\x18\x57\x96\x52\x50\x72\x02\xc2\xc2\xad\x90\x12\xe6\x04\x19\x29\x62\xb4\x94\xc0\x7c\x09\x98\xa6\xe2\x4e\xa8\x0c\x6c\x4e\xbd\x7d\xea\x04\xf3\x54\x07\xab\xfd\x02\x68\x42\x50\xed\xd2\x5b\x7f\x2f\xfc\x6b\x56\xa2\xb5\xc4\x6a\x06\x07\x3f\x5d\xe3\xf4\xd7\xc7\xd3\xff\x7e\x39\xba\x9e\xd6\xff\xfb\x67\x33\x74\xfc\xfc\x1f\x07\x1d\x41\x8b\x9c\x91\x6d\x13\x6e\x7f\x47\x04\xb1\x01\x6f\x78\x5b\x56\xc6\x35\x8e\xf1\xa3\x66\x95\x97\xab\x0f\xcd\x7d\xeb\x2b\xd5\xdf\xdf\x05\xa2\x20\xed\xec\xa8\xe9\xc1\x6c\xa1\x8c\x45\x29\x41\x33\xb8\x32\x63\x4c\xa8\x91\x05\xa1\xc0\x90\x4f\x70\x33\x08\xd7\xd7\xad\x8c\xb8\x37\x97\x6a\x2e\xd0\x86\xd9\x7f\xff\xab\x47\x4d\x43\xf6\x33\x4a\xd7\xe7\x6c\x0f\xd6\xdb\xb4\xf5\x78\xe5\xe2\x20\xe8\x53\xcb\x91\x01\xad\x42\xe1\x6d\xc0\x0e\x22\x9b\x6b\x2d\x09\xd5\xa4\x87\x2b\xa6\xab\x4a\x68\xbf\xed\x83\x64\xe5\xac\xd6\x43\x39\x6b\x97\xe5\x90\x90\x24\x4b\x8f\x98\x42\x6d\xda\x1d\x0c\x6b\x29\xe7\x18\xdf\xf4\x81\x54\x12\x7a\xfe\x33\xc5\xb6\xcf\x83\x0d\x09\xef\x3f\x52\x38\x97\xf7\xac\x1a\xb4\x8c\xec\x49\x65\x55\x49\xec\x43\xd5\x42\x31\xde\x4e\xb0\xb9\x30\x6d\x26\x68\xd5\x1a\x9c\xa2\x90\x8e\xa9\x4f\x84\x71\x2b\x5b\xb7\xef\x87\xac\xf2\x77\x9b\x34\xae\xf4\xa5\x7c\x93\xcb\x41\xa4\xa0\x88\x92\xd0\x55\xf7\x83\xd6\xa8\x18\x42\x37\x2e\x99\x08\xe3\x1d\xfe\x46\xeb\x9b\x81\x68\x8c\x7a\x9d\x69\x41\xca\x42\xee\x45\x21\x65\x5d\x00\x3b\xa5\x7c\x21\x4d\x9c\xcf\xfe\x36\x1e\x7b\x83\xda\x90\xf1\xf7\xf0\xf8\xf6\xb9\x96\xda\xbe\x44\xdf\xa2\xb0\x21\xfc\xa8\x96\x20\x54\x22\x16\x22\x71\x28\xe1\xcc\xcd\x89\x15\x59\x9f\x76\xa5\xaf\xfd\x42\xab\x93\x01\xfd\x7e\x87\x14\x9d\xb4\x41\xdb\xd3\xc7\x8f\x37\xd4\x0d\xd8\x52\x3b\x60\xb4\x7e\xf8\xcf\x23\xdd\xcf\xe3\xc1\x36\xa7\xac\x90\xa1\x16\x17\x42\x89\xc2\x15\xa0\x5c\x31\x27\x06\x9d\xc2\xb9\x4e\x8c\xff\x17\xe1\x94\x4a\xa9\x97\x05\xa9\x7e\xee\x55\x1f\x72\xf0\x1b\x02\x13\x26\xcb\x70\x8e\x20\x98\x53\xaa\x99\xa0\x40\xbe\xa9\xbb\x61\x9b\x3e\x68\xc0\xb8\x38\x26\x63\x52\x27\xf7\x0a\x67\x28\x75\xaf\x85\xa4\x4b\x4f\x51\x3b\x5e\x2e\x4f\xa9\x64\x8a\x7d\x5f\xfe\x1b\x5c\x19\xaa\xeb\xe4\x6b\xd6\x45\x64\x82\xf8\x19\x2d\x2f\x28\x0d\x85\x9e\xb0\x9f\x26\x15\x08\x64\xc6\x65\x6f\x46\x58\x2a\x06\xd8\x3d\x52\xa2\xba\x67\x16\xdf\xec\x3a\x47\x96\xea\x1b\xab\x63\xf5\x59\x6e\x03\xc3\xd6\x6c\xf6\xad\xdd\xc7\x2c\x74\xde\x60\xe4\x09\x14\xce\x58\xdf\x8d\x85\x6a\x1b\xf2\x5a\xaf\xae\x5b\x71\xff\xa4\x3f\x6c\xdd\x40\x23\x5e\x79\x75\xb8\x66\x3f\x94\x07\xc7\xdd\x13\x6b\x95\x8a\xec\x3d\x96\x55\x4c\x87\x3d\x35\xaa\x1f\x76\x8b\xd2\x76\x28\x30\x1a\x2d\x18\x8b\x58\x65\x45\x81\xe5\x03\x05\x0d\xc6\x4f\x50\xcd\x77\x43\xcb\x1d\xc1\x9e\xd1\xb2\x41\xd4\x62\xf5\x95\x2d\x23\x1b\x06\xeb\xa3\x88\x2f\xdf\x27\x9d\xd2\x57\x4d\x44\x4b\x2c\x86\x12\x7e\x67\xa4\xba\xac\xee\x86\x3b\xc2\x6d\xea\xdd\xaa\xda\x00\x93\x65\x41\x0b\x94\x8d\xcf\x1b\xc8\x42\x12\x08\x03\x4a\x83\xd4\x2a\x23\x86\x02\x55\x82\x56\x73\x9f\xbc\x7d\xc0\x9b\xda\x0e\xc0\x7a\x95\xf9\x93\x32\xf2\x41\x6b\x08\x7c\x47\x3a\x56\x40\xff\xe2\xe2\x26\x2e\xd2\x9d\xbf\x2c\xa1\xbc\x0c\x67\xc7\x87\x21\xa4\x63\xf9\xd5\x7c\x74\xbc\xab\xe3\xae\x2e\xde\x75\xfd\xf3\x83\x45\x2e\x3c\x71\xf8\x33\xcf\xc3\x04\xad\x44\x9b\x7f\x75\xd4\xbc\xf0\x8e\x5e\xf3\x4b\xe1\x56\xd8\xbc\x4e\xd0\x70\x37\x0d\xb6\xc0\x51\x38\xde\x67\xc2\x02\x53\xa9\x8f\xe1\x36\x27\xee\x04\xd7\xbb\x50\xea\x70\x74\xfb\x51\xe2\xac\x15\x7d\x1c\x08\xef\xb4\xfb\xda\xd5\x3d\xe5\x0c\x84\xb1\xbb\x7e\xbd\x01\x6d\x5d\x7c\xaf\x42\x6c\x95\x58\x67\x66\x6f\xf1\x62\xfb\x6b\x46\xac\x95\xf5\x37\x3e\x9d\x8e\xe4\xf5\x46\x6a\x87\xbd\xfb\xfa\x07\x9d\xd8\xc5\x9c\x09\x7b\x78\x02\x9b\xb2\x60\x3c\x03\xb2\xe1\x2b\x56\xcf\xae\xff\x09\x1b\x6a\x16\x45\x59\xe4\x45\x5e\x64\xc2\xe6\x6e\x1e\xc5\xba\x98\x69\xce\x1e\x79\xce\xef\x77\xc4\x6e\xbe\xe6\x06\xe8\x33\xe7\xef\xe1\x01\x2e\xa1\x54\xa8\xea\xdd\xf1\xe3\xcb\xcb\x01\xa1\xcd\x09\xdb\xc1\x7c\xee\x93\x55\x28\x23\x92\xea\xd1\xae\xc9\x4d\x23\x3c\xa5\xeb\x04\x6d\x5a\x7c\x9d\xc5\x62\xe8\x12\xbb\xd5\x0a\xde\x54\xc4\xee\xf9\x70\xce\xa8\xe2\xbc\xdb\xba\x0b\x34\x76\xf0\x72\xbc\x75\x5f\x73\x23\xca\x53\x2a\xaf\xc2\x9b\xc9\x0e\x08\x9a\x62\x90\x68\x32\xc1\xd5\xec\x14\x1c\x26\x54\x1e\x36\xef\x2e\x47\x68\x8c\x2b\xa8\x61\x97\xbf\x1d\xaf\xaa\x17\xca\xea\x2e\x9c\x3a\x99\x0a\x29\x29\x39\x1e\x01\x3d\x5c\x13\xba\xbc\x5d\x45\xc3\xd3\x37\x1c\x05\x4f\xe0\xb0\x7e\x97\xdf\x9b\xc9\x2b\x6d\x3b\xb8\xa2\x7e\x04\x6e\xd9\x70\x75\xf1\xee\xdb\xf8\xeb\x58\xee\xca\xdf\x1d\xaf\xbd\x6b\xb4\x54\x43\xef\xcd\x3b\xc0\x6b\xfe\xe0\xb1\xd7\x66\xb5\xd0\xb7\xb9\xc3\x50\xb1\x20\xde\xd5\x23\x61\xe3\x73\x27\x65\xf5\x04\x32\x8c\xf7\x41\xef\x03\xfd\xf8\xcf\xd1\x88\x18\xd0\xd9\x1c\x8e\x3c\x64\x51\x94\x32\xd0\x7f\x13\xcb\xef\x79\xe3\x8f\x00\x00\x00\xff\xff\x4f\x4e\x49\x9d\x02\x1d\x00\x00"),
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xec\x7d\xeb\x72\x1b\x47\x76\xf0\x7f\x3c\xc5\xf9\xfc\xb9\x8a\x64\x0a\x80\x64\x7b\xed\xca\xc2\x71\x76\x59\x92\xb5\x52\x24\x59\x2a\x52\xf2\x56\xa2\xe2\xa6\x1a\x33\x07\x40\x2f\x66\xba\x27\xdd\x3d\x20\xe1\xcd\xbe\x7b\xea\xf4\x65\x30\x33\x98\x2b\x48\x46\x71\x62\x52\x3f\x44\xcc\x74\xf7\xb9\xdf\xfa\x74\x63\x36\x9b\x4d\x58\xc6\x7f\x46\xa5\xb9\x14\x0b\x60\x19\xc7\x3b\x83\x82\xfe\xd2\xf3\xed\x3f\xea\x39\x97\x4f\x76\x5f\x2d\xd1\xb0\xaf\x26\x5b\x2e\xe2\x05\x3c\xcb\xb5\x91\xe9\x15\x6a\x99\xab\x08\x9f\xe3\x8a\x0b\x6e\xb8\x14\x93\x14\x0d\x8b\x99\x61\x8b\x09\x80\x60\x29\x2e\x60\x83\x49\xaa\x30\x41\xa6\x51\xcf\xe9\x8f\xf9\x2a\xc9\xef\xa2\x78\xce\xe5\x44\x67\x18\xd1\x9b\x6b\x25\xf3\x6c\x01\xb5\xa7\x6e\x06\x4d\x2f\x00\xb8\x75\x5f\x62\x92\x5e\xb9\xc9\xec\xa7\x09\xd7\xe6\x75\xfd\xc9\x1b\xae\x8d\x7d\x9a\x25\xb9\x62\x49\x15\x04\xfb\x40\x6f\xa4\x32\x3f\x1d\x26\x9f\xc1\x46\x4d\x00\x74\x24\x33\x5c\x80\x7d\x90\xb1\x08\xe3\x09\x00\x8b\x63\x8b\x19\x4b\xde\x2b\x2e\x0c\xaa\x67\x32\xc9\x53\x51\x0c\xfc\x97\xeb\x77\x3f\xbd\x67\x66\xb3\x80\xb9\x36\xcc\xe4\x7a\xee\x57\xa2\x59\xec\x3b\x81\x10\x65\xb8\x01\xcc\x9e\x96\xd2\x46\x71\xb1\xee\x9b\xea\xda\x4e\x5c\x99\xac\xf2\xd1\xa0\xb9\x22\x29\x1c\x26\xfa\xd3\x1f\xce\xff\x38\xa7\x31\x3f\xfc\xf0\x85\x07\x2a\xfe\xe2\xe2\x66\x9e\xa2\xd6\x6c\x5d\x05\xfa\x6d\xe5\xb3\xee\x85\x02\xef\xe7\x91\x42\x46\x2b\x7d\xe0\x29\x6a\xc3\xd2\xac\x32\xe5\x65\x6d\xba\x98\x19\xfa\x40\xe7\x4b\xe5\xe5\xc9\x13\xd7\x01\xbe\x80\xbf\xfd\x7d\x02\xb0\x0b\xd2\xb9\xfb\xea\xf0\x57\xc1\x05\x07\xac\x7d\x44\x33\x6b\x54\x3b\x8c\x17\x60\x54\x1e\xd6\xd2\x46\x2a\xb6\xc6\xe2\xb3\x1d\x4b\x78\x6c\xa1\x74\x73\xc8\x0c\xc5\xe5\xfb\x57\x3f\x7f\x73\x1d\x6d\x30\xb5\xf2\x4b\x1f\x67\x4a\x66\xa8\x0c\x0f\x92\x42\xbf\x41\x6a\xc3\x8f\xc2\xff\xc8\xb9\xa2\xf5\x3e\x9d\x45\x1b\xa6\xcc\xd9\x4d\xe9\x69\xd3\x0c\xf4\x5b\x12\x93\xea\x03\x80\x18\x75\xa4\x78\x66\x81\x83\x0f\x1b\xb4\xc2\x1d\x06\x58\x2a\xce\xe1\xd5\x0a\x84\x34\xa0\xf3\x2c\x4b\x38\xc6\x53\xe0\x06\x6e\x79\x92\xc0\x12\x61\x8d\x02\x15\x33\x18\xc3\x72\x0f\x6c\xb5\xe2\x77\x5c\xac\xc1\x6c\x70\x52\x59\xc6\x73\xc4\x8a\x3a\x18\x49\x2f\x40\x60\x81\x7d\x32\xaf\xbd\x7f\xc4\xfe\xc3\x6f\xc6\x8c\x41\x25\x16\xf0\xc5\x5f\x3e\xb1\xd9\x2f\x4f\x67\xbf\xbf\x39\xff\x34\xf3\xff\xfb\x87\xf0\xd1\xc5\x1f\xbe\xfc\xa2\x32\xd0\x30\xb5\x46\x53\x28\xdc\x78\x42\x58\xe0\x1b\xa8\x61\x36\xa5\xe7\x05\x61\xe8\x53\x7d\xd0\xcb\xc3\x0f\xd3\xc7\xd8\xdb\xa1\x83\x49\x40\x22\xc7\x23\xbc\x8c\x22\x99\x0b\x33\x88\xab\x7e\x08\x30\x37\x06\xce\xb9\x68\x81\xe2\xc2\x7f\x2e\x62\x54\x18\x17\x2f\x68\x90\x2b\xff\xc4\x51\x84\xa9\x63\xd4\x14\xee\x38\xde\x62\x0c\x6c\xcd\xb8\xd0\x06\x96\xb8\x92\x0a\x49\x5e\xb8\x06\xfa\x84\x25\x09\xc6\x20\x15\xe4\xd9\x5a\xb1\x18\xe3\x39\x7c\xd8\xd0\x33\x0d\x4c\x00\x8b\x77\x5c\x4b\xb5\x87\x68\x83\xd1\x76\x6a\xe9\xcc\x40\x33\x11\x2f\xe5\xdd\xf7\xf0\x81\x27\x09\xaa\xa3\x55\x99\x15\xcb\x40\x55\x07\xdd\x2d\x37\x1b\xe0\x46\x83\xbc\x15\x10\x29\x8c\x51\x18\xce\x12\xdd\xc2\xbe\x30\x8c\x6b\xfb\x30\xe0\xf1\xf8\x42\x69\xcd\x17\x0e\x13\xca\x67\xf6\x5d\x0b\xaf\x93\xe5\x03\xd3\x80\xaf\x80\x1b\x88\x25\x3a\x04\xf0\x2e\xf8\xa5\xc3\x8f\x03\x7e\x29\x65\x82\x4c\x54\x9e\x15\xd3\xbc\x2d\x79\xd4\x56\x30\xde\xb0\x25\x26\xc4\xae\x18\x98\x10\xd2\x58\xc3\xa6\x61\x25\x55\x23\x68\x53\xb8\xdd\xa0\xf0\x22\xe0\xd0\x8d\x6b\xd3\x3b\xc8\xe4\xf2\xaf\x18\xd5\x81\x6e\xb3\x68\xf4\x9b\x58\x40\x8e\x3f\xef\x9c\x10\xa0\xea\x67\xdb\xa7\xef\x61\x38\x94\xb1\xff\x3c\x40\x18\x9e\xa2\xcc\x4d\x27\xb7\xac\x39\xf7\x9a\x57\xd2\xbb\x30\x16\xb8\x00\x8d\xe4\xaf\xf5\xa4\x32\x89\x5f\x95\xc2\x90\xf5\x91\xce\xad\xa4\x4a\x99\x59\x00\x17\xe6\xbb\xdf\x55\x9e\xa5\xec\xee\x25\x27\xff\xb7\xef\x84\xea\xa7\x3c\x5d\xa2\x22\x93\x42\xaa\x66\xdd\x6b\xdd\xbe\x98\x0d\x33\x8d\x46\x66\x8b\x99\x01\x6f\xbe\xbc\xa7\xa5\xb1\xce\x38\x4c\xad\x0c\xc6\x98\x25\x72\x4f\x86\x46\x20\x70\x11\x25\x79\x8c\xf1\xf7\x47\x33\x3d\x85\x2d\x62\xa6\xc1\x92\xc6\xae\x9e\x3e\x08\x11\x00\x52\x2e\x78\x9a\xa7\x0b\x78\x5a\x79\xa0\x50\xa3\xf9\x99\x25\x39\xea\x4e\xf2\x3c\xc7\x4c\x61\x44\x5e\xf5\xff\xc1\x47\x8d\x81\x69\xf3\xd2\x78\x6b\x4d\x91\xc5\x83\x95\x7c\x25\x55\x84\x1f\xdd\x44\x27\x2d\x6e\x27\x18\xbd\x6c\xcc\x35\x5b\x26\xf8\x52\xca\x6d\x37\xce\xaf\x56\x85\x4d\x76\x3e\x94\xec\x98\xca\x1d\x9f\x37\x34\x3c\x48\x88\x8d\x7b\x40\x8a\xe0\x50\xac\x29\xf2\x50\x0e\x86\x4b\x6f\x79\xf6\xec\xea\xf9\x48\x98\x68\x94\x05\xc8\x2f\x6d\xb5\x3f\xc0\x45\xd3\x55\x61\x3c\x8f\x54\x3c\x0b\x50\x5a\x1c\x2e\x46\x01\xe8\xe2\xc3\x9f\x6b\xe1\xe3\x50\x60\x89\x80\x3e\xf4\x24\x75\x42\xd8\x39\xc9\x09\xce\x99\x3e\xa2\x94\x02\xb4\x5d\x06\xce\xdd\xf3\xb9\xfb\x73\xfe\x57\x2d\x45\x1d\x5c\xa8\xe0\x37\x18\x97\x1d\x2a\xbe\xda\x8f\x83\xde\x8d\xb1\x70\x67\x4a\xee\x50\x30\x11\x61\x8d\xbc\x2b\x25\x53\x60\x36\x52\xab\xcd\x4d\xb1\x48\x26\x35\x27\x4b\x74\x31\x20\x0c\x99\x0c\xb6\xdd\xe5\x08\x7c\x8b\x7b\xf2\x0c\xd7\x18\x29\x34\x57\xb8\x3a\xbb\x19\xe1\xbe\xea\x83\x8f\xdf\xa8\x91\xc8\x2d\x03\x5b\xdc\xc3\x46\x26\xb1\x8f\xb3\xc3\x3c\x14\x55\x97\x68\xe6\x28\xe4\x59\x3d\xde\x3b\x95\xb1\x24\x57\x7e\x36\x85\xb3\x2d\xee\x8f\x10\xec\x43\xb2\x48\xc5\x1a\x9f\x74\xf8\xb6\xf0\xbb\xc5\x23\xb9\xe9\x1d\x1b\x2b\xbe\x32\xcf\xd1\x60\x34\x5e\x69\x28\x98\xdc\x7b\x47\xd4\x16\xf9\x12\x51\x5d\x54\x63\x36\xb8\xaf\x4d\xef\x97\xc7\x18\xac\x74\x52\x0c\x9a\x32\xc1\x57\xa8\x8d\x0e\x6e\x2b\x4a\x72\x6d\x50\x0d\xd6\x9f\x2a\x42\xaf\x9c\x43\xeb\xc4\xeb\x43\x05\x01\x3b\x1e\xe2\x30\x01\x44\x52\x68\x1e\xa3\xd2\x53\xd0\x98\x60\x44\xe9\x1b\xa1\x7c\xcb\xf6\xa5\x18\x8e\x10\xaf\xad\x61\x93\xaf\x68\x83\x9a\x3c\xf3\xf7\x36\xca\x96\xb9\x01\x26\xf6\x36\x29\x2c\xe6\x25\x97\x5a\x1b\xea\xb8\xc5\x94\x62\x75\x8a\x71\x83\x69\x83\xe8\x74\x4a\x68\xb7\xcc\xb9\x1a\x4f\xc3\x83\x0e\xa1\x29\x2c\x95\x6e\x90\x99\x41\x63\x6d\xd9\xe8\x94\x81\xed\x0a\x32\x60\x60\x63\xde\x30\x4a\x41\x7e\xbc\xbb\xaf\x3c\x25\xc8\x76\x24\x13\x89\x14\x38\x05\x9c\xaf\xe7\xb0\xc4\x88\xe5\x1a\x41\x9a\x0d\x2a\x12\x38\xa3\x24\x05\x68\xf5\x30\x13\x20\xda\x30\xb1\xc6\xf6\xe8\xeb\x37\x91\xf9\x9f\x20\x32\x29\xa3\x54\xc0\x3a\xe1\x3f\x73\x11\xcb\x5b\xdd\x29\x2f\xfe\x1d\x32\x78\xb7\x1b\x1e\x6d\x2a\x06\x34\x65\x7b\x2a\x1d\x05\xdf\x7b\x6c\x47\xd2\x23\x86\x43\x79\x00\x50\x66\x20\xf6\x36\x87\x79\x54\x91\x29\x7b\x41\x6d\xa8\xda\x36\x85\x33\x14\xf1\xd9\xcd\x48\xe9\x8a\xd9\xbe\xf1\xf3\x1a\xd5\x9e\xb3\x7d\xe1\x6d\x6e\x11\xb7\xee\x3f\x96\x94\xb6\x68\xa8\x41\x8a\x29\xc4\xb8\x62\x79\x62\x34\x79\x7c\xdc\xa1\xda\x43\xdc\x40\xaf\x6e\x6a\x74\xd2\xa4\x47\x14\x7c\x7c\x4a\xf4\x18\x80\x13\x15\x66\x09\xa7\x98\xed\x8f\xd0\x99\x02\xd3\xf0\xf2\xe5\xe2\xed\xdb\xc9\x09\x10\x94\x8a\x2e\x67\x7f\x39\xff\xf4\xf4\xab\x9b\x4f\x54\x6c\xf9\xcf\xaf\x3f\x3d\x9d\x7d\x73\x73\xb1\xf8\xf4\x74\xf6\xad\xfb\xe8\xcb\xb3\x86\xe1\x28\xe2\xd3\xc1\x8f\x12\xa9\xf1\xf3\xc2\x4f\xd2\xff\x6f\x52\xe0\x50\x24\x7e\x91\xa2\x88\x9f\xad\x30\xdb\xbc\x09\x45\x6c\xf5\x48\x57\xe5\xea\xe3\x87\x67\xe3\x50\xf2\x51\xf5\xbb\xdc\x50\x64\xf1\x76\x9c\xb5\x38\x8a\xc2\xfc\x6c\x15\xab\x11\x8c\xc4\x2d\xe3\x86\x62\x5f\x2a\x38\xb1\xb2\x5d\xaa\xad\x00\x81\x57\x46\x5a\xe5\x19\x1c\x6d\x79\x33\xb3\x98\x0c\xb6\x14\x5d\xca\x8f\x82\xf2\xdf\xc5\xa4\x87\x45\x44\x02\x34\x44\xfa\x15\x4b\x34\xb6\x92\x61\x0a\xcb\xdc\x80\x20\xbd\x0f\xf6\x10\xf8\xb1\xe5\xa2\xdf\xf3\x32\x43\x69\x2b\xe2\x38\x9b\xeb\x22\x43\x51\x34\x18\x04\x7b\x85\x7d\x76\x98\x4d\xcb\x0a\x18\xcd\x46\xc9\x7c\xbd\x81\x18\x13\x34\xf8\x44\x51\x2e\xe3\x36\x63\x8e\x7f\xe4\xaa\x14\x6b\xd8\x3a\x50\xc4\x84\xad\x6b\x5a\x27\x40\xf9\x6c\x4c\x9e\x25\x4b\x58\x84\xa3\x71\x52\x98\x6b\x6c\x2e\xc2\xf4\x63\x96\xa2\x5a\x57\x92\x69\x29\x8c\xac\xfc\xed\x13\xd4\x5c\x29\x14\x26\x70\xad\x61\x1d\xa0\x0a\xc6\xa6\x44\xa2\x29\x28\x66\x83\x25\xb3\x61\x82\xd2\xd7\x84\x45\x3e\xc7\x4b\x4f\x40\xb2\xb5\xd2\xd4\x8f\xa4\x1d\x7c\x40\xb0\x06\xa5\x61\x5b\xd4\x40\x05\x2a\xaa\xaa\x53\x4e\x4e\xb2\x58\xa2\xea\x68\x60\x23\xfa\x34\xcf\xde\x89\x17\x8c\x27\xe3\xc1\x75\x22\x05\xa6\x12\xa2\x0a\xbc\x4d\xf6\xbe\xbc\xee\xf6\xa6\x60\xc5\x38\x65\xfd\x65\x6c\x46\x83\x1a\xe4\xf6\xbd\x8c\x4f\x22\x6c\x74\xa8\xe1\x67\x32\x2e\xc4\xc5\x8b\x49\x9d\xd8\xa3\xc1\xeb\xaa\xb6\x3d\x44\xc5\xed\x54\xb8\xa8\x6e\xf6\x5c\xed\xaf\x72\x31\x1e\xaa\x18\x23\x4e\x06\x44\x86\xd5\x09\x10\x97\x34\xe8\xb0\x95\x58\xda\x91\x9f\x1e\x20\x6e\x58\x0a\x8a\x82\xb3\xf5\x7e\x25\xc5\xf5\xc9\x4b\x59\x07\xa5\x23\x85\xcc\x4d\x24\x5d\x10\xc3\x20\x56\x7b\x50\xb9\x18\x45\x01\xca\x7c\x96\x2c\xda\x7e\x0e\x8f\x32\x75\xac\xcd\x50\x51\xd1\xbe\x00\x25\xec\xd7\x70\x1d\x4c\x54\x89\xbd\x56\x53\x72\x85\xfa\x11\xfd\x45\x01\x99\xf3\x15\x41\x71\xbd\x79\x6f\x73\x17\xb4\xcf\x25\x10\x8f\x0b\x76\xfd\xa0\x85\x29\x16\xa3\x47\x8e\x56\xaa\x03\xd5\x15\xee\xc8\x0b\x38\x65\xb2\xf5\x20\x95\x0b\x41\x56\x3d\xce\x29\xae\x2e\xf8\x31\x1a\xa8\x96\xbd\x9f\x23\x78\x6c\xe8\x77\xd8\xe4\x21\x85\xa1\x00\x8a\x38\x65\x73\x28\x2e\x62\xbe\xe3\x71\xce\x12\x78\x9d\x2f\x51\x09\x34\xa8\x29\x5e\x52\xb6\xe2\x3c\x6d\x98\x1f\x2a\x91\xe2\x37\x4f\x9f\xb6\xec\x20\xf5\x6d\xa0\xf4\x6d\xa2\x80\x85\x74\x1c\xc5\x69\x04\xe4\xc2\x70\x17\x34\xf9\x7d\x18\x10\xc5\x7e\xd3\x7b\x6f\x75\x19\xed\x73\x24\x72\x9f\xa2\x68\x8e\x9e\x18\xed\x5b\x0b\x60\xa0\x90\xc5\x7b\xdb\x20\x82\xa1\x90\x9c\x32\xb5\x0d\xe5\xd7\xa0\x3e\x4c\x83\xce\xa3\x08\xb5\x5e\xe5\x49\x2b\x25\x7a\x64\xec\x9d\xb8\x42\xa6\x5b\x36\x14\x2b\x58\xfb\xf7\x08\x15\xef\xd7\xbc\xf2\x6a\x38\x27\x50\xd0\x04\xf3\x15\xda\x6e\xa0\xe8\xca\xb9\xb0\xdc\xb7\x79\x79\xc3\x32\x00\x42\x16\x72\x49\x05\x73\x6f\x3b\x3a\x74\xae\x2d\xc3\xec\xc8\x2f\x3b\x73\xa3\x94\xdd\x5d\xa1\x51\x1c\xfb\xe9\x40\x85\xa9\x03\x77\x49\x2b\x34\xb0\x1a\x49\x82\x1b\xa3\x76\x8c\xd0\xb0\x42\x65\x2b\xde\x24\xb1\x00\xb4\x93\x9f\x66\x26\xb4\x31\x00\x5b\x19\x54\xb6\xe7\x85\x69\x4b\x18\x6a\x76\x61\xb6\x3f\x01\xd7\xcc\xf0\x1d\x5a\x7a\x0a\x09\x09\x4f\xb9\xa9\xc6\xdd\xdf\x5e\x3c\xa8\x56\x18\xd4\xe6\xbf\xdf\x8d\x54\xfc\x71\x88\x10\x08\x94\x5a\x84\xe0\x28\xc5\x4a\x8a\xd0\xb0\xf5\x3c\x5a\x37\xf8\x5a\x48\x85\x2f\xbc\x4f\x1a\x0f\xb0\x0d\x6b\x24\xb5\x2b\x91\x40\x97\x75\x36\xd4\xf0\x3d\x2e\xa4\x48\xa3\xa1\x1b\x63\x88\xab\x1b\xee\x87\x96\x09\xbb\xba\x91\x10\xc9\x34\x23\x7f\xf7\xa0\x22\x93\x0b\xcf\x83\x07\x92\x1b\xda\x33\x6f\xd9\xe3\xef\xe7\x05\x0d\xb6\x38\x6f\xdc\x0c\x41\x7e\x0a\x2b\x1a\x74\x8d\x6b\xef\xfb\xe3\x53\x6d\xe9\xa3\x05\xc1\x0e\xae\xd1\x60\x3d\x80\xa0\xd8\x95\xc9\x72\x9d\x53\xf0\x4a\x5b\x4b\x36\xb2\xb8\x78\x34\xd9\x89\x31\x43\x11\xeb\x77\x47\x61\x7b\x05\xe2\x52\xf4\xed\xbc\x4f\x51\x64\x7e\x42\xff\x9b\x92\xf2\xd3\x7f\x0a\x3c\xac\x21\x2e\x5e\xa2\x8e\x33\x66\x20\xcd\x6d\xc3\x58\x6d\xa1\xa2\x79\x31\x0e\xce\xb7\x12\xb4\x8e\xda\xcf\x6d\x72\x53\x2d\x2e\xaa\xd5\x3d\x45\x52\xac\x12\x1e\x99\x6b\x43\x5d\x8f\xeb\x7d\x27\x61\xfe\x4c\xb5\x0b\x23\x21\x96\x07\x53\x13\x20\x5f\x62\x22\xc5\xda\x66\x30\x5a\xa6\x68\x36\x14\x50\x20\x95\x7e\x6c\xfe\x6f\xb1\x2c\x11\x76\x32\x10\x3e\xb2\xeb\x79\x5a\x87\x6a\x66\xdb\x09\x8e\x3e\x64\xb1\xcc\xea\xaa\x3f\x3b\x36\x83\x99\xd4\xe6\xca\x35\x04\x2a\xdd\x89\xf0\x7b\xa9\xcd\xcc\xf7\x0e\x2a\xed\x3b\xf3\xe2\x43\xc3\xa7\x6f\x2a\x3c\x6c\x88\x96\x75\xab\x36\x31\x1c\x18\x8e\x7b\xda\x6f\x0c\xa4\x7b\x20\xe6\x36\x1a\xbe\x6e\xd3\x07\xb0\xb5\xcd\xe8\xfc\x97\x46\xbf\xd9\x33\x73\xff\xec\xbe\x26\x1c\x6d\xda\x1f\xd7\x08\xee\xc5\x90\x47\xbe\x32\x25\x95\x6b\xe9\xf8\xee\xf7\x4f\xbf\x3e\xec\xd5\x56\xd8\xd0\x3a\x31\x1c\xf8\xd2\xfa\x4e\x3b\xad\x7b\xa9\x3e\x82\x4a\xc7\xbb\x2f\x16\x95\xb3\x9b\x8e\xb7\xfb\x29\x5b\xa2\x6f\xf7\x2b\x35\x1a\x53\x88\x69\x47\xd9\x72\xff\xbf\x5e\xbe\x7d\xf3\x3d\x30\x7b\x1c\x80\xa2\x63\xe3\x4b\x4c\x4c\x4f\x3a\x26\xb4\xff\x58\x9d\x37\x3d\x23\x3a\x94\xbc\xfa\xeb\xf6\xeb\x47\x23\x75\xa8\x96\x99\x80\xa2\x97\x15\x32\x4b\xdf\x17\x0c\xe8\x99\xd7\xc6\xab\xc7\x62\xd7\x33\x6a\xa0\x10\x8c\x61\x6d\xcf\x3e\xee\x89\xc4\xed\xdd\xe3\xbd\xc7\xbc\xed\xfb\xbf\xf7\x98\xb4\x7d\x6f\xf8\x9e\x93\x76\xec\x1b\x0f\x9c\x39\x92\x69\x2a\xc5\x9b\xc6\xc6\xdd\xa6\x26\x63\x23\xa9\x4f\x96\x0c\x17\x6d\x83\x1c\xe4\x55\xae\xca\xbe\x74\x32\x58\xb2\x86\x35\xdd\xb6\x82\x1f\xe3\x32\x5f\x77\xc3\x2d\x43\x55\x20\x92\x22\xe2\x09\x2f\x75\x08\x56\x1d\x3a\x9d\x2b\x59\x4a\x8d\xc9\x9e\x8a\x45\x66\xd3\x68\x9a\x1b\x3c\x66\x28\x5b\xc6\x7c\xb5\x1a\x44\x88\xa6\x78\xd4\xd6\x3c\x5f\xf0\x04\x5d\x4f\x99\x1e\xd5\x10\x6a\x07\xeb\x17\x4a\xa6\x73\x6d\x87\xbf\xc6\xfd\x15\xae\x3a\x5b\x43\x1f\xca\x3b\x97\x7d\x02\xc9\xf9\xe8\x9d\xf8\x76\xe5\xa8\xe0\x4c\x1d\xf9\x81\xb8\x0e\xc9\x69\x08\x50\x8b\xde\xe3\x4a\x10\x1b\x8e\x75\xb4\x87\x6c\x3d\xb2\x75\xa0\xea\xe2\x51\x29\xd8\x4d\x1e\x0a\x6f\xf9\xfa\x2d\xcb\x1c\x4f\x9b\x5e\xe9\x99\x7f\x20\x97\xfa\x41\xe9\xe6\x56\x27\xc7\x1c\x16\x29\xcb\x1e\x88\x69\x9d\x8c\x1b\xd4\xab\x58\x03\xf6\x35\xee\x03\x44\x05\xac\x64\xe5\xe8\xe4\x44\x69\x4f\x82\x2a\xc6\xd5\x7d\x79\xdf\xa2\xbb\x67\x69\x72\x1f\x48\xa5\x85\x83\x25\x03\xc1\x0d\x25\xd6\x52\x5d\x47\xd9\xfa\xdc\x8e\x85\xce\xf9\x02\x64\x9e\xf8\x63\x34\x40\xf9\x0d\x2a\x32\x5d\x31\xa3\x5c\xbf\x75\xad\xee\xbc\x19\xbc\x02\xfe\xaa\x25\xf2\x41\x6d\xc8\x40\x26\x9f\x24\x8e\x0e\xd0\xdf\x64\xb1\x4d\x16\xcb\x06\x52\xb7\xca\x63\x05\xe2\x6b\xdb\x62\x4b\x35\xf9\x1d\x2a\x46\x35\xb0\xbd\xdf\x82\x28\xd9\x29\xb9\x2a\x35\xca\x79\xf8\x29\xe7\xb5\x69\x1d\x75\x3b\x34\xae\x03\xf6\x75\xa9\x62\x5f\xfe\xde\xa0\x9d\xdd\x56\x5c\x28\x62\xa2\x3f\xc8\xd0\x24\x78\xc7\x23\xd2\x55\xfb\x26\x6d\xa2\x51\x33\x10\xcd\xbf\xe6\xbb\xa3\xd6\x98\x5f\x89\x4e\x7d\x3e\x2b\xaf\x17\x3d\x13\x34\x39\xeb\xc3\x4f\x8b\xdb\x1e\x41\xfa\x63\x06\xb4\x35\xe7\x0f\xe7\xc2\x00\xa3\xd1\x6d\x3a\x8e\x3c\x59\x50\x43\x25\xd3\x49\xfb\x74\x03\xc9\x3e\xd4\x58\x74\x98\x0c\x7f\x5a\x22\xf4\x9f\xa6\x5c\xeb\xbe\xf5\xfa\x0d\xc2\x3d\x4c\x58\x95\x68\x03\xa1\x1a\xec\x2c\xef\x6d\x9d\x82\xcf\xfa\xcd\x34\x8d\x36\x4d\x9f\xc9\xdd\xff\x66\x97\x9a\xec\x52\x35\xa4\xf9\xcd\x28\xf5\x1b\x25\x4f\xb1\x07\xb2\x48\x74\xc1\x8b\x12\x2c\xb9\xb6\xad\x79\xad\x56\x69\x94\x52\xe7\x2a\x39\x59\xa7\x73\x35\x94\x26\x1f\xaf\xde\x04\x8d\xfe\xbf\x19\xec\xd2\xb6\x0c\x95\x89\x1e\x86\x69\x19\x33\x9b\x93\xb9\x46\x83\x07\x52\x8d\x5e\xb5\x35\x35\x6f\x00\x6c\x4b\x65\xf9\xc8\xe8\x9a\x53\x67\x6e\x26\x2f\x68\x5b\x4e\x55\x98\x4b\xf9\x42\x22\xa3\x86\x5b\x0a\xfe\x17\xf3\xd9\x95\x55\xdf\xb7\x52\xb8\x02\xeb\x73\x69\x66\x1a\x33\x46\x1b\x4f\x31\x15\xfb\x37\x35\x08\x69\xb7\x8f\x6d\xd1\x9a\x58\x4b\x7f\x37\xbd\x3f\x18\x76\x46\xd7\xf6\x2c\x99\xc6\xb3\x49\x3b\xa8\xad\xb4\x75\x3b\x1d\xa7\x43\x6a\xa4\xeb\x1a\xf7\xc7\x1c\xb7\x28\x02\xd4\xac\xb8\x54\xc0\xc7\x35\xbb\xb6\x66\xe6\x5e\x20\x35\xdd\x67\x45\x7d\x41\x03\x60\x7c\x1d\x1a\x22\x3c\x18\x9e\x94\xc5\xb1\x4c\x7f\xe8\x97\x1a\x02\xaa\x54\xb6\x57\xa4\xe8\x3c\xb5\x7d\xac\x0a\x63\x16\xd9\x74\x3d\x3d\x90\x3d\x91\xeb\x35\xc6\xae\x50\x3c\x19\x2f\x16\x52\xe0\xbb\x06\xad\x9f\x55\x54\xba\x56\x2f\x3c\xbb\xe9\x79\xbf\x5c\xca\x39\xbb\x19\x31\xb9\x1e\x35\xfb\xa0\xb7\x8f\xfc\x52\xef\x88\xb2\x3d\xac\xbd\xbc\x6b\xec\xb5\xaf\xb0\x9a\x0e\x41\x52\x37\xa8\x5c\x75\x78\x93\x56\x83\xea\xba\xa0\x7e\xee\x5f\xe6\x58\xea\xf5\x61\xc9\xe2\x5a\x0d\x48\x70\xe5\xc4\xca\x76\x26\x50\xf7\x08\x6b\x14\xe7\x63\xd9\xf4\x9b\x0d\xc5\xae\x05\x99\x32\x32\xf1\xfe\xb5\xc9\xd0\xc0\xb3\x25\x0d\x6e\x55\x2e\x37\xfd\x07\x4c\xb3\xa4\xa1\x8f\xb7\x42\x83\xab\x5c\x94\x01\x0f\xdd\xc4\x0c\xfe\x24\xc1\xf8\x09\x8e\xda\x0b\x9c\xde\x1f\x77\xbc\x16\x78\x86\x4b\xc7\x02\x21\xda\x03\xf7\x76\xcd\x72\x48\xbc\xdb\xa1\x52\x3c\xee\xe1\x64\xf1\x16\x2d\x48\x21\x58\x12\x30\x9a\x86\xe4\xcb\x77\xdc\x51\x83\x9d\xed\x5b\x0f\x8f\x99\xb6\xec\xa9\xcd\x0e\x70\x66\x7d\xe1\x6c\xa6\xd1\x9c\xc1\xb9\x46\x73\x41\xf9\x58\xe9\xd3\x99\x23\xbc\x7b\x78\x6d\xff\x7f\xf1\x30\x1c\x6d\x09\x12\xba\x3d\xbf\x6e\xdb\xd6\xae\x10\xea\x92\xd2\x83\x1f\x2c\xee\x80\xc2\xa8\x7d\x53\xd2\x4a\x9e\x3d\x92\xa8\xa2\xa2\x13\xc2\x02\x46\xcd\x66\x09\x75\x51\x41\xc2\xb7\x78\x9a\xb9\xf7\x84\x7a\x48\x48\x59\x72\xcb\xf6\xd4\xca\xda\xba\x6c\x0f\x5c\x83\xcc\x37\x89\x41\x9f\xd1\x2b\xd0\xab\xbd\x69\x8d\xe1\x62\x32\x60\xd5\xea\x7c\x6b\x6e\x8f\xd1\xb6\x04\x83\xdd\xe2\xb0\xe6\x66\x00\x91\xff\xc4\x8d\x0d\xdd\x6d\xbc\xb1\xe6\xe6\x8f\x6b\x6e\x36\xf9\x72\x1e\xc9\x74\x21\xd5\xfa\x09\x85\x7e\xe3\x09\x5a\xee\x94\xa3\x00\xf2\xff\xdb\x56\xc1\x98\xee\xa3\xa4\x86\xe0\x3d\xbc\xbb\xbc\x9e\x8c\x89\x5b\x2b\x30\x53\x48\x43\x3b\xa8\xf6\xa8\xcb\x06\x8b\x10\xd5\x5d\x61\xe2\xe3\xd4\x60\x74\x7c\x2f\x22\xd7\xa7\x60\xa1\x70\x35\x00\x1e\xa2\xe1\x52\x31\x11\x6d\xaa\x45\xff\x94\xd1\xcd\x15\xb6\xc0\xac\x31\xb5\x27\xbf\xe8\x24\x0e\xe9\x9b\x61\xae\x9d\x6d\x25\x93\x44\xde\xfa\x2e\xcf\xf5\x06\x35\x1d\xe8\x36\x91\x6d\x70\x33\x6c\x0d\x72\x65\xed\x93\x1b\xbe\xf8\x27\x3b\xfe\x9f\x4f\xc1\xc4\xb0\xf5\x40\x4c\x68\x59\x0a\xfd\x7c\x84\xe7\xc8\x67\x64\xdb\x61\x36\x02\x5d\xe1\xea\x14\x98\xa8\xc3\x61\xb0\x90\xba\x97\x4f\x80\xcc\x06\x7d\x86\xad\x4f\x81\x30\xc6\xec\xa3\x3d\x64\xe3\x1b\x4d\x07\xc0\xda\xd2\x92\x6a\xcf\xea\x84\x73\x10\x0e\x72\xd7\x30\x8a\x22\xe2\xf5\x13\xc5\xf4\x0e\xa9\x36\x6d\xbf\x9c\x69\x98\xcd\xec\x68\x9c\xd9\x71\xb3\x18\x33\x3d\xf3\x1d\xb2\x8d\xf0\xf4\xf5\xb0\x76\x75\xb1\x16\x78\x53\x37\x85\x88\xf6\x57\x98\x49\x3d\x00\xed\x67\x87\x7b\x03\x8b\xd6\x55\x7f\x2b\x64\x26\x75\x0b\xd6\x36\x94\x58\xa1\x89\x36\xfe\x0a\x98\xc6\x75\xda\x7d\x68\xa7\x27\x1d\xe0\x4f\x83\x92\x1f\x0c\x2e\xd5\x4a\xa6\xe0\x83\xe3\xe6\x30\x77\x88\xed\x1d\x50\x3c\xe9\x95\xbd\x3a\xaf\x72\x95\x0c\xb5\xa3\xc1\xd7\x76\xdc\x96\xd4\xc8\x44\x9f\x40\x95\x6f\x4c\xca\x35\x2a\xaa\xc2\x5a\x2d\xca\x98\xd6\xb7\x52\xc5\x05\x87\x5b\xbd\xc3\x60\xe2\x8f\xa8\x40\x0f\x27\x7c\x7f\x35\x7a\x10\x07\x14\x46\xb9\xd2\x78\x9d\x2f\x53\x19\xe7\x09\x0e\xd1\x83\x50\x9d\xb0\x17\x2f\xb3\x84\x6b\x92\x72\x7b\xe7\x1a\x69\xb0\x53\x04\x5d\x4c\x18\xa2\xe3\xe0\xc1\x4e\x48\x3d\x01\xb4\xde\x74\x5e\x8c\xd5\xcf\xe4\xeb\xeb\x97\x90\x29\xbe\x63\xc6\xd5\x57\xcf\xb9\xd5\x65\xb3\xbf\x20\xd8\xa7\x45\x85\x86\xfa\xaf\xe8\xfd\xad\x90\xb7\xe2\xdf\x37\x92\xba\x92\x4d\x9b\x00\x44\x74\x48\xb4\x82\x9d\x0d\xb3\xa7\xa1\xf7\x29\xe0\x4e\x8b\xd3\xa2\x72\x55\xb1\x7b\x93\x93\x04\x6a\xa0\x28\xdd\x77\x33\xa3\x47\x70\x5c\x0c\x75\x99\x65\xf7\xe4\x0b\xcb\xb2\x57\xcf\xa7\xa1\x6b\xdf\xb6\xc7\xbd\x7a\x4e\x2c\x09\xdc\xa2\x66\x84\xf3\x46\x16\xb1\x8c\xfb\x8a\x2c\x6b\x5c\x1a\xc8\xa9\xbe\xcc\x97\xf0\x23\x5d\x9e\x9d\x29\xae\x11\xae\xe9\x7a\x66\x75\x41\x8c\x61\xe1\xf1\x65\x66\xb7\x28\x1b\x98\x69\x7d\xec\xcb\x0f\x1f\xde\x5f\x37\xa7\x4f\xbf\x22\x7e\xf9\x7e\x32\x3e\x4c\xc5\xfd\xa5\x92\xd4\x31\xa1\xa7\xd4\x4e\xe8\xce\xb5\x79\xbf\xad\xa4\x2c\xca\x4f\x81\x56\xd3\x43\x29\xc1\xe7\xa2\x5c\xb8\xfe\x82\xc6\xd5\xca\xdd\xfc\x95\x5c\xf5\xb1\xdc\x63\x2b\x61\xe8\x24\xc4\xf3\x10\x03\x8d\xb0\x7e\xc5\xe5\xba\x74\x00\xee\x2c\xc6\xec\x2c\x1c\x56\x3e\x67\x5a\xe7\x29\x06\xc6\xd3\x91\xd2\x43\x31\x99\x25\xee\x00\xe9\x2a\x4f\x56\x74\x45\x69\x7c\x31\x69\x07\xba\xd9\x20\x56\xf3\xa7\x43\x56\x40\x69\x54\xb8\x9c\xcf\x77\x28\x37\xc8\x5a\xb7\x9c\x1d\x66\x1b\x40\x8a\x43\xf0\xe3\xb2\x92\x8f\x57\x6f\xa6\xa0\xbf\x59\x3c\x79\x32\x85\xb5\x5e\x3c\x79\x42\x69\x01\xfb\x65\x99\xc8\x25\xfd\x11\x94\x15\x96\x79\xb4\x2d\x99\x01\x7b\xd0\x5e\x2a\x90\x11\x2f\xbf\x26\xe0\xdd\xb3\x57\xa0\x70\xcd\xb5\x69\x29\x66\xf7\xb0\xf6\xa4\xc0\xa2\x5d\xcb\x8e\x71\x77\xe1\x9e\x68\xba\xd6\x7b\x00\x78\x9d\x5d\xe4\x6d\x8b\xf9\x41\xc7\x19\x97\x91\xf6\xba\x8b\xe4\xa0\xa6\x47\xd9\x96\x1f\x7b\x0a\xac\x3a\x5f\x8e\xc9\x5c\xbd\x71\x70\x20\x97\xb6\x5e\x42\x20\x1c\xf2\x9a\xa2\x78\xe8\x1d\xa6\xdf\x14\xa0\x23\xa3\xcb\xe6\x43\x40\x03\x60\x8d\xd8\x09\x3e\xa9\x7e\x8d\xe6\xfb\x1f\xdf\x02\x8a\x48\xc6\x18\xc3\xb3\x4b\x88\x48\x65\x56\x9c\x36\x82\xce\xf5\x85\xbf\x41\xa7\xf1\x26\x4d\x2f\x76\xb5\xec\x80\xb7\x6e\xc8\x8c\xf7\x1f\x1d\x77\x6f\x3e\xba\x1b\xe9\xdd\x7b\xef\xe3\x0d\x2a\x73\xcf\x88\x21\x4a\x38\x95\xcd\x4b\x1c\x81\x73\x93\xe8\x79\xa4\xa8\x87\x26\xd1\x73\x62\x25\x13\x6d\xfb\x75\x87\x28\x02\x22\x46\x83\x2c\x37\x33\xba\xe4\x46\x98\xa0\x3a\x8f\xc3\xb8\xcf\xc2\x31\x23\xb7\x28\x2e\xf3\x41\xba\x4b\xaf\x51\x54\x4c\x62\xde\x42\x0a\x1b\x08\x91\x0d\x47\xa6\xe8\x62\x14\x9a\xbd\x92\xdf\x5a\xc3\x4d\x13\x7d\xdd\xb8\x9e\x07\x08\x50\xc4\x99\xe4\xc2\x1c\xca\xe9\x81\xaf\xa5\x2c\x7b\xad\x98\x30\xf7\x27\xbe\x5d\xf1\xe3\xd5\x1b\x72\x8c\x6e\x95\x42\x04\x4f\xe6\x49\x98\xb3\xed\x79\x8d\xb2\xde\xa9\x11\x41\xab\xf8\x9f\xca\xd6\x93\xfd\x1b\xfd\xab\x11\x61\x20\x0e\xad\xba\xe8\x03\xf6\xf2\xac\x24\x3d\x14\xa5\xa0\xbf\x58\xda\x21\xdd\x1a\x44\x0f\x62\xe9\x08\x9d\x1a\xc6\xc3\x7e\xdd\x1a\xc8\x08\xfb\xed\x3d\x7a\x28\x19\xed\xcb\xcd\x04\x5a\x49\xd5\x43\x9f\xf6\x20\xb8\x37\x10\x1e\x80\x4c\x88\xb6\x7a\x44\xa3\x57\x2c\x1a\x2a\x65\xe5\x60\xce\x16\x7c\x19\xcc\x63\x19\x6d\x51\xb9\x4d\x63\xba\x5f\xbc\x71\x31\xa0\x50\x87\x35\x57\x69\x26\x27\x09\xd2\x40\x21\x7a\x64\xc3\xec\xa2\xe0\x47\xa0\xb4\x0f\xaf\x9b\x8c\x37\xd7\xe1\xfb\x12\x7e\xcd\x94\xb3\x91\xe1\xfb\x3c\x49\x1c\x41\x16\x8f\x02\x43\x85\xf2\x75\x3a\x2e\x99\xe6\x11\xb0\xdc\x6c\xe0\x9c\xac\x2e\xa7\xcb\x16\x28\xd1\x6b\xcb\xe7\x7a\xb0\x6a\x69\x74\x68\x20\x6f\x37\x5a\xc5\xc8\xc5\xa4\x17\xa7\x67\xf6\x5d\xa0\x5e\xe3\xf0\xf5\x3f\x87\x5e\xd4\xe3\x2d\xed\x8b\xaa\xdc\x11\x0b\x80\xa9\x68\xc3\x77\xf8\x6b\x96\xa5\xde\x80\xb6\x42\xb3\xd7\xb8\xaf\x5d\x71\xe3\x28\x10\x7a\x85\xa2\x82\xa6\xd5\xdd\x0e\x2b\xb1\x73\xb3\xfe\xe5\x14\x30\xab\xc2\xa1\x3b\xda\x53\xba\x69\x55\x8c\x5c\x4c\x7a\x11\x75\x4d\x30\x9e\x7d\x01\xe5\x17\x49\x7e\xe7\x67\x99\x1d\xae\xf0\x26\x6d\xdf\xf1\xaa\x64\xdc\x5f\x22\xe8\x28\x74\x51\xd0\x38\x55\x32\x4a\x5f\x2d\x38\x8c\xbf\x97\xef\x5f\x85\x0c\x3b\x20\xed\x2f\xa2\x73\x94\xa8\x72\xd5\x3d\x9a\x1b\x29\x93\x2d\x37\x87\xaf\x0f\x2c\xbe\xb1\x30\xac\x31\x82\xd9\xc3\x8e\x82\x0f\x98\xa0\xf9\xe6\x8f\xc3\xcf\x8c\xca\x9f\x57\x85\x49\xeb\x78\x8f\x8c\x5f\xef\x8b\xf7\x56\xc2\xc2\xf6\x0c\x64\x55\xf1\x25\x59\x03\x18\xd5\x67\xdb\xee\x03\x77\x7b\x59\x64\x40\x69\xc4\x9b\x0d\x46\xa9\x2c\x75\xec\x85\xb2\xf4\x00\xbe\x0c\x00\xad\xb1\x25\xa3\x05\xb6\xca\x49\xaa\x00\x1b\x1b\xc6\xfb\x41\xc0\x78\xb5\x1a\x08\x8e\x57\xdb\x5e\x88\x1e\xbc\x0e\x36\xca\x16\x37\xb7\x9d\x77\xdb\xa5\xd6\x1d\xd3\x0a\xfe\xb4\xe9\x70\x7e\x7d\x71\xa8\x99\x66\x2c\xda\xb2\x35\xc6\x35\xbf\x73\x4e\x4e\xe5\x62\x32\x12\x8b\x93\x93\x47\xbd\x61\x5f\x7f\xfb\xdd\x10\xf8\xf1\xae\x28\x9f\x5d\xbf\xbc\xfc\xfa\xdb\xef\x0e\x1d\xaa\xc7\xde\xd3\x9e\x8b\xd9\xb0\x1d\x9e\x86\x48\xe9\xde\x74\xba\x32\x9d\xcd\x56\x97\xb3\x17\x37\x7f\xfb\xee\x77\x7f\xff\xf2\x6c\xf2\x5f\x03\x00\x31\x9c\x25\x4b\x63\x76\x00\x00"),
 		},
 		"/helm-operator-deployment.yaml.tmpl": &vfsgen۰CompressedFileInfo{
 			name:             "helm-operator-deployment.yaml.tmpl",
 			modTime:          time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
-			uncompressedSize: 6000,
+			uncompressedSize: 5999,
 
-			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xcc\x58\x4f\x73\xdb\xb8\x15\xbf\xfb\x53\xbc\xb1\x0f\xb9\x58\xa4\x33\xc9\xee\x81\x99\x1c\xda\x4d\x37\xc9\x4c\x92\x7a\xea\x4c\x67\x7a\xda\x7d\x02\x9f\x44\x54\x20\xc0\x02\x8f\x52\x59\xcf\xf6\xb3\x77\x1e\x40\x51\xa4\x28\xc9\xf6\xf6\xd0\xf2\x62\x19\xc0\xfb\xff\x7b\x7f\x80\xc5\x62\x71\x85\x8d\xfe\x2b\xf9\xa0\x9d\x2d\x00\x9b\x26\xe4\xdb\xd7\x57\x1b\x6d\xcb\x02\x3e\x50\x63\x5c\x57\x93\xe5\xab\x9a\x18\x4b\x64\x2c\xae\x00\x2c\xd6\x54\xc0\xca\xb4\xff\x5c\x54\x64\xea\x85\x6b\xc8\x23\x3b\xff\xf8\x08\x7a\x05\xd9\x37\xac\x29\x34\xa8\x08\x7e\xfb\xad\x3f\x1d\xff\x2d\xe0\xf1\x71\xba\xfb\xf8\x08\x64\x4b\x39\x16\x1a\x52\xc2\xda\x53\x63\xb4\xc2\x50\xc0\xeb\x2b\x80\x40\x86\x14\x3b\x2f\x3b\x00\x35\xb2\xaa\xbe\xe0\x92\x4c\x48\x0b\xe7\x35\x11\x5a\xf6\xc8\xb4\xee\xd2\x51\xee\x1a\x2a\xe0\x2f\xa4\x3c\x21\xd3\x15\x00\x53\xdd\x18\x64\xea\x59\x8f\xac\x93\xcf\x4c\xa4\x5c\x94\x23\x1f\x5a\xeb\x18\x59\x3b\x3b\xa2\x69\xbc\xab\x89\x2b\x6a\x43\xa6\x5d\x1e\x94\x47\x51\xe1\x9a\x7d\x4b\xd7\xf1\xd0\xde\xe6\xf8\x9b\xfc\x56\x2b\xfa\x83\x52\xae\xb5\xfc\xed\xb2\xb8\xad\x33\x6d\x4d\xa1\xe8\xfd\xfd\x27\x8b\x4b\x43\xdf\xb5\x31\xe4\xbf\x7f\x79\x48\x5e\x4f\xdf\xa2\xd7\x3c\x72\x61\x13\x16\x0a\x87\x3d\x00\xe5\xec\x4a\xaf\xbf\x62\x53\x8c\x16\xe7\xc6\x26\xba\x45\x3a\x3d\x39\x59\xd2\x0a\x5b\xc3\x5f\x5d\x49\x05\xdc\xfd\x78\x77\x77\x41\x30\x79\x0e\x23\xe2\x20\xa1\xe0\xa9\xe0\xb4\x96\x8c\xef\x6d\x1b\xac\xfa\x89\x3c\x3f\x0c\xfb\x09\x3b\x97\x77\xc9\x04\xf9\x75\x64\x07\x79\x3e\xa0\xee\xbc\x2d\x6f\xef\xee\x0e\x2c\xfa\x73\x37\xfb\xbf\xf0\x37\xd7\xc2\x4e\x1b\x03\x96\xa8\x04\xae\x28\x10\xf0\xce\xed\x03\x23\x9a\x77\x72\x04\x2d\x03\x3b\xa0\xc0\xb8\x34\x3a\x54\xb0\x45\xa3\x4b\x64\x2a\xe1\xfb\x97\x87\x81\x9d\x72\xd6\x92\x8a\xf0\x01\x5c\xa3\xb6\x81\x21\x99\x36\x93\x7c\x3e\xa0\x37\xa7\x02\x7a\xf3\xec\x80\xde\x5c\x0c\xe8\x0d\x24\xef\xc6\x3c\x82\x4d\xbb\x24\x6f\x89\x29\x22\x9b\x4d\x98\xa9\x37\x77\xfa\x48\xcc\x34\xf4\x37\xff\xcb\xd0\x9f\xb2\xfa\xed\xc1\xea\xc7\x47\xb2\xe5\xe8\xf0\xf7\x8a\x60\xe5\x8c\x71\x3b\x6d\xd7\x7d\xb4\x41\x07\x58\x39\x0f\x6d\x90\x35\x04\xd5\x06\x76\xb5\x0e\x54\xc2\xc6\xba\x9d\xfd\xa5\x72\x81\x03\xac\xb4\xa1\xdb\x81\xd1\xae\xd2\xaa\x4a\x18\x39\xc0\xc8\x41\xe9\xf6\xd0\x11\x22\xf9\xe1\xc1\xed\x2c\xac\x35\x4b\x65\x74\xe0\x91\xab\x03\x2a\x80\x2b\xb4\xbd\xe0\xb5\xe6\xaa\x5d\x82\xf3\x02\x47\x30\x7a\x43\x99\xc0\xf4\x95\x31\x80\x26\xb8\x41\x44\x2d\xf5\x05\xf4\x21\x1e\xda\xb2\x8b\x34\xca\x59\x46\x6d\xc9\xdf\xc2\x92\x8c\xdb\x65\x27\x61\x5f\x63\x97\x18\xee\x04\xcf\xec\xa4\xcc\x6d\x75\x49\x80\x16\x42\xa8\x7e\x49\xa0\x3a\x32\x57\x3a\x88\x76\x56\xf4\xac\x9d\xa7\xa4\xb7\xb3\x04\xbf\x7e\x2e\x65\x8b\xbb\x9f\xb5\xa1\x5f\xdf\x45\x47\x0a\xfc\xd1\x2a\xba\xed\x7d\xf1\xca\xd3\xc0\x28\xd9\x3a\xe5\xf1\x51\xf3\xa7\x76\x19\xfd\x93\xc1\xb7\x3f\x46\x5b\xc8\xb2\xef\x60\x43\x1d\x84\xca\xb5\xa6\x84\xe5\x81\xc7\x75\x52\xf1\xba\x77\x66\x62\x74\x7d\xd0\xfd\x5a\xe4\x46\x37\x51\x09\xda\xc2\xbf\xf3\x2c\x84\x2a\x9f\xbb\x63\x0f\xf6\x10\xaa\x52\xfb\x17\xa5\x61\x08\xd5\xd3\xe9\x97\x6a\x90\xa4\xc2\xc3\xc3\xa7\x09\xc4\xaf\x0e\x69\xf9\xf0\x29\x9a\xc9\x0e\x50\x29\x0a\x21\x9a\xff\xb1\xc7\x4b\xd0\xec\x7c\x37\x2b\xca\x6b\xcd\x8b\x0d\x75\x2f\xab\xc6\x73\x25\xc6\x87\x67\x9a\x47\x90\x93\x1d\x1c\xe9\x09\xcb\x85\xb3\xa6\xbb\x85\x1d\xc1\xce\xd9\x57\x0c\x4b\x02\xe9\x5c\xa2\xbc\xaa\x6a\x57\x5e\xbd\xa0\xe4\xea\x30\xe4\xdf\x1e\x25\x43\x0a\x0e\xe9\xc2\x15\x1e\x80\x2e\x84\x41\x60\xba\xf7\x99\x80\x2d\x39\xed\x1d\x50\xb6\xce\x6e\x01\xf7\x60\x2a\xe3\xe0\x23\xa7\x32\xf8\xbc\x1a\x58\x4c\xe4\xfc\xbd\x0d\x1c\x01\x18\x5a\x55\x45\x79\xb7\xd1\xf9\xbd\x2b\x46\xd9\x30\xd0\xa3\x11\x37\x74\xd0\x38\x6d\x39\x00\x32\xe4\xc4\x2a\x17\x48\x94\xb9\x80\x4c\xf7\xe9\x00\x18\x00\xf7\xe2\x45\xec\xa1\x72\xf4\x3d\xa5\x0d\x74\x94\x07\x1b\xea\x6e\xa3\x86\xa3\x82\xb2\x4f\xce\x7d\x25\x19\xd8\x8c\x52\x15\x97\x6e\x4b\xb7\xb0\xd3\x5c\x89\x77\xa6\x29\xd9\x67\x52\x1c\xbd\xc4\x68\x42\x55\x0d\x4c\xc4\x89\xda\x46\xa3\x13\x58\xf6\x89\x4e\x25\x54\xe4\xe9\x7c\xca\x4c\x11\xf8\x9c\xa6\x10\xd3\x46\xc8\x52\x68\x2e\xa7\xcd\xef\x02\xdf\xf9\x9a\x7f\xd4\xdd\xd1\x53\xc4\x4e\xe4\x9e\x10\x37\x24\x9b\xa6\x90\x75\x58\x9b\xa3\x02\x88\xb6\xec\x63\xd1\x37\x09\x54\x82\x14\xed\xe3\x78\xdb\x65\xb1\xb3\x18\x64\x26\x2f\xfd\x44\xc2\x47\x52\xb4\x14\xb6\xe1\x50\xb9\x06\x81\x1c\xfb\x90\xaf\xc9\xa7\x9c\xa8\x71\x43\xa9\x86\x0b\xdf\xfc\xc0\xf8\x60\xf9\xf9\x58\x8c\x75\x5f\x88\xee\x2f\x8d\x4a\xec\xb0\x63\x2e\x97\x45\x44\x1d\x47\x1c\xa9\x6e\xb8\xfb\xa0\x7d\x01\x8f\x43\x61\x1b\x7a\xd1\x30\x4f\xcf\x27\x8b\xa3\xa1\xb8\x8f\x95\xa7\x18\x1f\xeb\xe0\xba\x90\xf9\x3e\xf0\x35\xe8\x1a\xd7\x94\xba\xf4\x84\x32\x83\x9f\xb5\x8d\xf3\x1b\xd4\xd2\x6f\x3d\x29\xb9\xea\x1c\xf8\x79\x32\x84\x81\xa4\xab\x46\x1e\xb0\x4d\xf7\x24\xc9\xdc\x8a\xb9\x09\x45\x9e\x57\xed\x32\x2b\x9d\xda\x90\xcf\x94\xab\x73\x9f\xef\x08\xb7\xb4\x73\x7e\x13\xf2\x89\xb4\x9c\x71\x1d\x46\xcc\x05\x13\x72\xdd\x91\xab\x90\xa8\xc0\xb8\x9e\x64\x0d\x24\x99\x05\xf4\xdc\xb5\x8b\x85\x42\x95\x53\xb6\xc5\xeb\xec\x2e\xbb\x5b\x78\xf5\x66\x4a\x77\xdf\x1a\x73\xef\x8c\x56\x5d\x01\x9f\x57\xdf\x1c\xdf\x7b\x0a\x63\xf3\x1a\xe7\x79\x74\x5d\x19\x26\x4b\xe6\x66\x7a\x47\x48\x91\xb8\x77\x9e\x0b\x78\x73\xf7\xe6\x30\xe4\x1b\xbd\x25\x4b\x21\xdc\x7b\xb7\xa4\x71\xf3\x10\x1e\x1f\x8f\xfb\x49\x33\x67\x10\x97\x91\xab\x02\xf2\x8a\xd0\x70\xf5\xaf\xd1\x96\xb6\x9a\x35\x9a\x0f\x64\xb0\x7b\x20\xe5\x6c\xd9\xdf\x0a\xf7\x1f\xeb\x9a\x5c\xcb\xc3\xde\x0f\xc3\x9e\xa0\x5e\xff\x9f\x6a\x16\x5c\xeb\x15\x85\xb1\x06\x9e\xfe\xd1\x52\xe0\x30\xd5\x4a\x35\x6d\x01\x3f\xdc\xd5\x93\xc5\x9a\x6a\xe7\xbb\x02\x7e\x7c\xfb\x55\x0f\x1b\xa9\x30\x7d\x95\xea\x30\xe2\x71\x03\x9f\xad\x32\x6d\x49\xa9\x5b\xf6\x83\xe5\x74\x0e\x3c\x3b\xae\x3a\x3f\xef\x5f\xc2\x52\xca\xda\xbb\x7d\x97\x19\x0d\x96\x15\xed\xdb\x71\x49\xca\xa0\xa7\x32\xf5\x95\x6c\x44\x7b\x72\x5e\x4a\x35\x20\x6a\x73\x9f\xfc\xed\x9d\xe3\x38\x72\x4d\x4e\x48\x48\xff\x6c\x4d\x57\x80\x5c\xa0\x9f\x98\x8b\xe0\xe2\xb0\x33\x15\x37\x69\xc0\xf3\x09\xe4\x7c\xd7\x9a\x6b\x3e\x61\x35\xbf\x65\x3e\x5d\x72\xe7\x2c\xb7\xe8\x7b\x96\x92\xf4\xf9\x89\xa1\xee\x19\x55\xf6\x05\x6c\xf3\x48\x77\xfe\x61\xe1\x84\x83\xcf\x5e\xf1\xcf\x39\x47\x08\x26\xc7\xa6\xb1\xbd\x28\x02\x9f\xc9\xff\xf8\xe4\x91\x88\x8b\x61\x3e\x63\xd0\xf9\x68\x4f\x0c\x9a\x83\xf5\xa2\x08\x7c\x26\xff\xe3\x93\xb3\x7c\x38\x02\x1b\xfa\xf5\xa4\x16\x7c\x72\x3b\x49\xfb\x95\x34\xbc\xc9\xdb\x82\x78\x79\xb1\xe0\xb8\xb4\x18\xde\xea\xde\x4f\x2e\xe1\x47\x6f\x76\xa7\x57\x7b\x87\x6e\xda\x25\x2d\x42\x17\x98\xea\x41\xab\xa7\x1f\xaa\x6e\x40\x16\x52\xb9\x69\x7d\x7c\x4a\x3b\xa5\xdf\xfe\xe5\x82\x3c\x2f\xa4\x3c\xbf\x9f\x7b\x29\x57\x98\x29\xcf\xe7\xa8\x29\xea\xf0\x7e\x12\x97\xa3\x23\x1b\xea\x4e\x32\xcf\xd9\x84\x6c\x9c\xff\xc7\x8a\x9d\xd3\x2a\x12\x5e\xd0\x69\x4b\x5e\xaf\xba\x8b\x3a\x3d\xcf\xe8\x93\xb0\xfe\xc9\xd5\x32\x96\x83\x6b\xe3\xc5\xe1\xfc\x7b\xd4\xd3\xef\x50\x09\xc2\xff\x4d\x34\x66\xf4\xa7\xe2\x31\x3b\xf4\xec\x88\xcc\xd5\x7b\x76\x4c\x66\xa4\xa7\xa2\xf2\x3b\xcd\x3f\xa4\xe7\x7f\x02\x00\x00\xff\xff\x74\xfc\xcd\xad\x70\x17\x00\x00"),
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xc4\x58\xcd\x8f\xdb\xba\x11\xbf\xfb\xaf\x18\xac\x0f\xb9\xac\x24\x07\xc9\x7b\x07\x05\x39\xb4\x2f\x7d\x49\x80\x24\x5d\xd4\x41\x81\x9e\x5e\x68\x6a\x6c\xb1\xa6\x48\x95\x1c\xd9\x55\x8d\xf4\x6f\x2f\x86\xfa\xb6\x2d\xc7\x09\x02\x14\xbb\x40\xb2\xe2\x7c\xcf\x6f\x3e\xc8\x28\x8a\x16\xa2\x54\x7f\x47\xe7\x95\x35\x29\x88\xb2\xf4\xc9\xe1\xf9\x62\xaf\x4c\x96\xc2\x1b\x2c\xb5\xad\x0b\x34\xb4\x28\x90\x44\x26\x48\xa4\x0b\x00\x23\x0a\x4c\x61\xab\xab\x7f\x47\x39\xea\x22\xb2\x25\x3a\x41\xd6\x9d\x4e\xa0\xb6\x10\x7f\x12\x05\xfa\x52\x48\x84\xaf\x5f\x5b\xea\xf0\x67\x0a\xa7\xd3\xf4\xf4\x74\x02\x34\x19\x93\xf9\x12\x25\x8b\x76\x58\x6a\x25\x85\x4f\xe1\xf9\x02\xc0\xa3\x46\x49\xd6\xf1\x09\x40\x21\x48\xe6\x1f\xc4\x06\xb5\x6f\x3e\xcc\x5b\xc2\xbc\xe4\x04\xe1\xae\x6e\x48\xa9\x2e\x31\x85\xbf\xa1\x74\x28\x08\x17\x00\x84\x45\xa9\x05\x61\x2b\x7a\xe4\x1d\xff\xad\x27\x5a\x6e\xea\xe1\x73\x61\x8c\x25\x41\xca\x9a\x11\x4f\xe9\x6c\x81\x94\x63\xe5\x63\x65\x13\x2f\x9d\x60\x13\x1e\xc8\x55\xf8\x10\x88\x3a\x9f\xf9\xc7\xa3\x3b\x28\x89\x7f\x92\xd2\x56\x86\x3e\xdd\x56\x77\xb0\xba\x2a\xd0\xa7\x6d\xbc\xff\x62\xc4\x46\xe3\x67\xa5\x35\xba\xcf\x1f\xd6\x1c\xce\xce\x86\xa8\xb5\x3c\xa4\x89\xb4\x8f\xa4\xe8\xcf\x00\xa4\x35\x5b\xb5\xfb\x28\xca\xc1\xe8\x6b\xce\x36\x7c\x51\x43\x3d\xa1\xcc\x70\x2b\x2a\x4d\x1f\x6d\x86\x29\xac\x7e\x5d\xad\x6e\x28\x46\x47\x7e\xc4\xec\x39\x15\x34\x55\xdc\x7c\x6b\x9c\x6f\x7d\xeb\xbd\xfa\x0d\x1d\xad\xfb\xf3\x06\x3b\xb7\x4f\x51\x7b\xfe\xdf\x99\x1f\xe8\x68\x40\xdd\xbc\x2f\x2f\x57\xab\x41\x44\x4b\xb7\xec\xfe\x85\x7f\xd8\x0a\x8e\x4a\x6b\x30\x88\x19\x50\x8e\x1e\x81\x8e\xb6\x4b\x0c\x5b\x5e\x33\x89\x30\x04\x64\x01\x3d\x89\x8d\x56\x3e\x87\x83\xd0\x2a\x13\x84\x19\x7c\xfe\xb0\xee\xc5\x49\x6b\x0c\xca\x00\x1f\x10\x3b\xa1\x8c\x27\x68\x5c\xeb\x48\x7a\xd2\xf9\x84\x2e\xaf\x25\x74\x79\x77\x42\x97\x37\x13\xba\x84\x26\xf6\xa1\x8e\x60\x5f\x6d\xd0\x19\x24\x0c\xc8\x26\xed\x2f\xcc\xbb\x0c\x7a\x4f\x72\x9e\xfa\xe5\xff\x33\xf5\xd7\xbc\x7e\x39\x78\x7d\x3a\xa1\xc9\x46\xc4\x9f\x73\x84\xad\xd5\xda\x1e\x95\xd9\xb5\xd9\x06\xe5\x61\x6b\x1d\x54\x9e\xbf\x09\x90\x95\x27\x5b\x28\x8f\x19\xec\x8d\x3d\x9a\x3f\x72\xeb\xc9\xc3\x56\x69\x7c\xec\x05\x1d\x73\x25\x73\xa8\xa7\x30\xb2\x90\xd9\x0e\x3a\xcc\xc4\xe7\x0e\xec\xd1\xc0\x4e\x11\x38\x2c\x2d\x38\x41\xf9\x80\x0a\xa0\x5c\x98\x56\xf1\x4e\x51\x5e\x6d\xc0\x3a\x86\x23\x68\xb5\xc7\x98\x61\xfa\x4c\x6b\x10\xda\xdb\x5e\x45\xc1\xfd\x05\xd4\x90\x0f\x65\xc8\x06\x1e\x69\x0d\x09\x65\xd0\x3d\xc2\x06\xb5\x3d\xc6\x1d\x49\x4f\xca\xb0\x2f\x44\xdd\x08\x3c\x32\x9e\xc9\x42\xe9\xec\x41\x65\x08\xc2\x80\xf7\xf9\x1f\x0d\x04\xcf\xdc\xe5\x09\xa2\xac\xe1\x00\x15\xd6\x61\x63\xb7\x35\x08\x5f\xde\x67\x7c\x44\xf5\xef\x4a\xe3\x97\x57\x21\x90\x0c\x7f\x61\x24\x3e\xb6\xb1\x78\xe6\xb0\x17\x54\xf9\x4b\x19\x6f\x15\xbd\xab\x36\x21\x3e\x31\x7c\xfa\x73\xf0\x05\x0d\xb9\x1a\xf6\x58\x83\xcf\x6d\xa5\x33\xd8\x0c\x32\x1e\x1a\x13\x1f\xda\x60\x36\x82\x1e\x06\xdb\x1f\x58\x6f\x08\x13\x66\xa0\x0c\xfc\x37\x89\xbd\xcf\x93\x78\xb6\x16\xbd\xcf\x33\xe5\xbe\xab\x0c\xbd\xcf\xbf\x5d\x7e\x4d\x0f\xe2\x89\xba\x5e\xbf\x9b\x40\x7c\xd1\x73\xad\xd7\xef\x82\x9b\x64\x41\x48\x89\xde\x07\xf7\xdf\xb6\x78\xf1\x8a\xac\xab\x2f\x9a\xf2\x4e\x51\xb4\xc7\xe1\xfb\x5d\xdd\xf8\xd2\x88\x8e\xf0\xaa\xe5\x01\xe4\x68\xfa\x40\x3a\x14\x59\x64\x8d\xae\x1f\xe1\x88\x70\xb4\xe6\x19\xc1\x06\x81\x27\x17\xb7\x48\x99\x17\x36\x5b\x7c\x47\xcb\x55\xbe\xaf\xbf\x0e\x25\x2d\x3a\xc4\x50\x2e\x94\x8b\x01\xe8\xcc\xe8\x19\xa6\x5d\xcc\x18\x6c\x4d\xd0\x5e\x01\xc6\xbb\xf8\x11\x44\x07\xa6\x2c\x2c\x3e\x1c\xd9\x18\xde\x6f\x7b\x11\x13\x3d\xff\xac\x3c\x05\x00\xfa\x4a\xe6\x41\xdf\x63\x08\x7e\x1b\x8a\x51\x35\xf4\xfc\x42\x73\x18\x6a\x28\xad\x32\xe4\x41\x10\x24\x48\x32\xe1\x6e\x99\x25\x0c\x32\xd5\x96\x03\x08\x0f\xa2\x53\xcf\xee\xf5\x22\xba\x99\x52\x79\x3c\xab\x83\x3d\xd6\x8f\x4c\xfa\x6c\xd4\x50\xba\xe2\xec\x3a\x49\x2f\x66\x30\x0e\xc4\xc6\x1e\xf0\x11\x8e\x8a\x72\x8e\xce\xb4\x24\xdb\x4a\x0a\xab\x17\x97\x1e\x0a\x99\xf7\x42\x38\x88\xca\x04\xa7\x1b\xb0\x74\x85\x8e\x19\xe4\xe8\x70\xbe\x64\xa6\x08\xbc\x67\x28\x70\x8c\x22\x66\x6b\x52\x33\xa1\xfb\x39\xe0\x9b\xef\xf9\x67\xd3\x5d\x38\x9e\x02\xae\x91\xce\x41\x11\x43\xb1\x29\xf4\x71\x2d\x0a\x7d\xd6\x00\x85\xc9\xda\x5c\xb4\x43\x42\x48\x46\x8a\x72\x61\xbd\xad\x63\xd6\x02\x5a\x10\xa1\xe3\x79\xc2\xe9\x43\x6e\x5a\x52\x54\x7e\xe8\x5c\xbd\x42\x8e\xf8\xd6\xba\x02\x5d\x53\x13\x85\xd8\x33\x10\xb0\x91\x9b\x0c\x82\x07\xcf\xe7\x73\x31\xb6\x3d\x62\xdb\xbf\x37\x2b\x61\xc2\x8e\xa5\xdc\x56\x11\x6c\x1c\x49\xc4\xa2\xa4\xfa\x8d\x72\x29\x9c\xfa\xc6\xd6\xcf\xa2\x7e\x9f\xee\x44\xcd\x2e\xc5\x6d\xae\x1c\x86\xfc\x18\x0b\x0f\x29\xef\xf7\x9e\x1e\x40\x15\x62\x87\xcd\x94\x9e\x70\xc6\xf0\xbb\x32\x61\x7f\x83\x82\xe7\xad\x43\xc9\x57\x9d\x41\x9e\x43\x8d\xc2\x23\x4f\xd5\x20\x03\x0e\xcd\x3d\x89\x2b\x37\x27\x2a\x7d\x9a\x24\x79\xb5\x89\x33\x2b\xf7\xe8\x62\x69\x8b\xc4\x25\x47\x14\x07\x3c\x5a\xb7\xf7\xc9\x44\x5b\x42\x62\xd7\x85\xa6\xc3\x04\x5f\x77\xf8\x2a\xc4\x26\x90\xd8\x4d\xaa\x06\x1a\xbb\x53\x68\xa5\x2b\x1b\x1a\x85\xcc\xa6\x62\xd3\xe7\xf1\x2a\x5e\x45\x4e\xbe\x98\xf2\x3d\x55\x5a\x3f\x59\xad\x64\x9d\xc2\xfb\xed\x27\x4b\x4f\x0e\xfd\xd8\xbd\xd2\x3a\x1a\x5d\x57\xba\x00\xb3\x5f\xfd\xc7\x51\x26\x9e\xac\xa3\x14\x5e\xac\x5e\x74\xdb\x11\x80\x56\x07\x34\xe8\xfd\x93\xb3\x9b\xf6\x22\xd5\xfc\xb2\x8c\xb7\x03\x6e\x06\x7d\x67\x02\xf8\xb7\x14\x94\xa7\x90\xe4\x28\x34\xe5\xff\x19\x1d\x29\xa3\x48\x09\xfd\x06\xb5\xa8\xd7\x28\xad\xc9\xda\x5b\x61\xf7\x43\xaa\x40\x5b\x51\x7f\xf6\x4b\x7f\xc6\xa8\x57\x3f\xcb\x32\x16\x56\xff\x34\xc3\xbc\xad\x9c\xc4\x51\xdc\xf9\xce\xfb\xaf\x0a\xfd\x38\x17\xfc\x23\xcb\x2a\x85\x5f\x56\xc5\xe4\x63\x81\x85\x75\x75\x0a\xbf\xbe\xfc\xa8\xfa\x83\x66\x0e\x7e\xe4\xe6\x30\x92\xb1\x84\xf7\x46\xea\x2a\x63\x70\x29\xdf\x4e\xc9\x7e\x30\x84\x56\x32\xbf\xad\x5a\x77\x39\xbe\x18\xb3\xdc\xd5\x5e\x41\x7d\xb1\x57\xe6\xd8\x4d\xe3\x0c\xa5\x16\x0e\xb3\x66\xac\x0c\x58\x9e\x59\x97\xf8\x00\x9a\x4e\xfa\xd4\x86\xdb\x5a\x0a\x1b\xd7\x84\x82\x93\xf0\x57\xa3\xeb\x14\xf8\xfe\xfc\x8d\xb5\xe8\xf6\xae\x33\x55\x37\x99\xbf\x97\x0b\xc8\xfc\xd0\xba\xb4\x7c\x22\xea\xf2\x92\x39\xd3\x0e\x47\x1d\xf7\x52\xe4\x41\xb8\x56\x24\xd7\x7c\xd2\x33\xd6\xdf\x92\x3a\x6e\xb2\xdf\x21\x36\x09\x7c\xf3\xef\x0a\x57\x02\x3c\xb9\x5f\xf9\x3b\xe2\xcc\x0c\x13\xb2\x69\x6e\x6f\xaa\x10\x77\xca\x3f\xa7\x3c\x53\x71\x33\xcd\x33\x0e\x2d\x6f\x2a\x9c\x90\xcd\x38\xb4\xbc\xe9\xd0\xf2\x6e\x87\xae\xd6\xc3\x19\xd8\x84\xdb\x4d\x7a\xc1\x3b\x7b\xe4\x4d\x67\xcb\xf3\x6e\xf2\xb4\xc0\x95\x12\x45\x14\x3e\x45\xfd\x53\xdd\xeb\x16\x02\x0d\xe9\xd9\x93\xdd\xf5\xaf\x6d\x40\xf9\x6d\x20\xf2\xb5\x27\x2c\x7a\xab\xe6\xf1\xd4\xd9\xb0\xe4\xf7\x90\xf6\xde\x54\xb9\xf0\x92\x76\xcd\xbe\xee\xe1\x02\x1d\x45\x3c\x37\x5e\x5f\x46\x29\x91\x22\x96\x8e\xe6\xb8\x31\xd8\xf0\x7a\x92\x97\x33\x92\x3d\xd6\x57\x85\x27\xa4\x7d\x3c\xae\xff\x33\xbe\x59\xab\x02\xe3\x0d\x9b\x0e\xe8\xd4\xb6\xbe\x69\xd3\x7d\x4e\x5f\x85\xf5\x6f\xb6\xe0\xad\x1c\x6c\x15\xde\xa2\xe6\x9f\xa3\xbe\xfd\x0c\xc5\x79\xfa\x21\xc3\xe6\xf9\xaf\xe5\x63\xf9\xc3\x19\xb9\xe0\xbc\x3f\x27\xcb\x7b\xb2\xf2\x83\xee\x9f\x4e\x80\x26\x83\xaf\x5f\x17\xff\x1b\x00\x1a\x9f\x06\xe1\x6f\x17\x00\x00"),
 		},
 		"/tiller-ca-cert-configmap.yaml.tmpl": &vfsgen۰CompressedFileInfo{
 			name:             "tiller-ca-cert-configmap.yaml.tmpl",
 			modTime:          time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 			uncompressedSize: 226,
 
-			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x54\x8e\xc1\x0a\xc2\x30\x0c\x40\xef\xfd\x8a\xfc\x40\x07\x82\xa7\xde\xa4\x78\x53\x2f\x0e\xef\xb1\xcd\xb4\xd8\x66\xa3\x8b\x22\xd4\xfe\xbb\x6c\x4c\xd1\x63\x78\x2f\x2f\x29\x05\x42\x07\xcd\x96\xf1\x1c\xa9\x0d\x31\x52\x6e\x77\x47\xa8\x55\x6b\xad\x70\x08\x27\xca\x63\xe8\xd9\xc0\x63\xa5\x6e\x81\xbd\x01\xdb\x73\x17\x2e\x7b\x1c\x54\x22\x41\x8f\x82\x46\x01\x30\x26\x32\xd0\xc5\xfb\x53\x5f\x29\x26\x2d\x71\xd4\x0e\xb5\x9b\xe5\xe5\xc8\x01\x13\x8d\x03\x3a\x82\x5a\x97\x95\x79\x34\x50\xca\x3f\x2d\x05\x88\xfd\xa4\x7d\xfa\x0e\x1b\x97\xc5\xc0\x4b\x4d\x31\xf6\xc4\x02\x6b\x68\xbe\x1f\xdb\x8d\xa5\x2c\xb6\x67\x99\xc8\x6f\xe1\x1d\x00\x00\xff\xff\x60\xd7\x1c\xac\xe2\x00\x00\x00"),
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x54\x8e\xc1\x0a\xc2\x30\x0c\x40\xef\xfd\x8a\xfc\x40\x07\x82\xa7\xde\xa4\x78\x53\x2f\x0e\xef\xb1\xcd\xb4\xd8\x66\xa3\x8b\x22\xd4\xfe\xbb\x6c\x4c\xd1\x63\x78\x2f\x2f\x29\x05\x42\x07\xcd\x96\xf1\x1c\xa9\x0d\x31\x52\x6e\x77\x47\xa8\x55\x6b\xad\x70\x08\x27\xca\x63\xe8\xd9\xc0\x63\xa5\x6e\x81\xbd\x01\xdb\x73\x17\x2e\x7b\x1c\x54\x22\x41\x8f\x82\x46\x01\x30\x26\x32\xd0\xc5\xfb\x53\x5f\x29\x26\x2d\x71\xd4\x0e\xb5\x9b\xe5\xe5\xc8\x01\x13\x8d\x03\x3a\x82\x5a\x97\x95\x79\x34\x50\xca\x3f\x2d\x05\x88\xfd\xa4\x7d\xfa\x0e\x1b\x97\xc5\xc0\x4b\x4d\x31\xf6\xc4\x02\x6b\x68\xbe\x1f\xdb\x8d\xa5\x2c\xb6\x67\x99\xc8\x6f\xe1\x3d\x00\x60\xd7\x1c\xac\xe2\x00\x00\x00"),
 		},
 	}
 	fs["/"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
@@ -51,6 +51,9 @@ type Controller struct {
 
 	hrLister iflister.HelmReleaseLister
 	hrSynced cache.InformerSynced
+	// hrIndexer indexes HelmReleases by their values sources, if
+	// these are watched
+	hrIndexer cache.Indexer
 
 	sync *chartsync.ChartChangeSync
 
@@ -98,7 +101,8 @@ func New(
 	hrInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(new interface{}) {
 			hr, ok := checkCustomResourceType(controller.logger, new)
-			if ok && !status.HasRolledBack(hr) {
+			_, requested := hr.ReconcileRequested()
+			if ok && (hr.DeletionTimestamp != nil || controller.sync.Selected(hr) && (!status.HasRolledBack(hr) || requested)) {
 				controller.enqueueJob(new)
 			}
 		},
@@ -275,6 +279,23 @@ func (c *Controller) enqueueUpdateJob(old, new interface{}) {
 		return
 	}
 
+	// Handle a HelmRelease that is being deleted regardless of
+	// whether it was rolled back or is selected, so that its release
+	// is deleted and the finalizer is removed. Status updates are ignored, as they
+	// are the result of an earlier (failed) attempt which will be
+	// retried.
+	if newHr.DeletionTimestamp != nil {
+		if cmp.Diff(oldHr.Status, newHr.Status) != "" {
+			return
+		}
+		c.logger.Log("info", "enqueuing release deletion", "resource", newHr.ResourceID().String())
+		c.enqueueJob(new)
+		return
+	}
+	if !c.sync.Selected(newHr) {
+		return
+	}
+
 	diff := cmp.Diff(oldHr.Spec, newHr.Spec)
 
 	// Filter out any update notifications that are due to status
@@ -289,14 +310,16 @@ func (c *Controller) enqueueUpdateJob(old, new interface{}) {
 	// Skip if the current HelmRelease generation has been rolled
 	// back, as otherwise we will end up in a loop of failure, but
 	// continue if the checksum of the values differs, as the failure
-	// may have been the result of the values contents.
-	if newHr.Spec.Rollback.Enable && status.HasRolledBack(newHr) && c.sync.CompareValuesChecksum(newHr) {
+	// may have been the result of the values contents, or if a
+	// reconciliation was requested.
+	_, requested := newHr.ReconcileRequested()
+	if c.sync.WithReleaseDefaults(newHr).Spec.Rollback.Enabled() && !requested && status.HasRolledBack(newHr) && c.sync.CompareValuesChecksum(newHr) {
 		c.logger.Log("warning", "release has been rolled back, skipping", "resource", newHr.ResourceID().String())
 		return
 	}
 
 	log := []string{"info", "enqueuing release"}
-	if diff != "" && c.logDiffs {
+	if diff != "" && (c.logDiffs || newHr.Spec.Debug) {
 		log = append(log, "diff", diff)
 	}
 	log = append(log, "resource", newHr.ResourceID().String())
@@ -312,6 +335,17 @@ func (c *Controller) enqueueUpdateJob(old, new interface{}) {
 }
 
 func (c *Controller) deleteRelease(hr helmfluxv1.HelmRelease) {
+	// A HelmRelease with a deletion timestamp has been finalized,
+	// which took care of deleting the release. The release of a
+	// HelmRelease that no longer matches the release selector is left
+	// to the operator it does match.
+	if hr.DeletionTimestamp != nil || !c.sync.Selected(hr) {
+		return
+	}
+	if c.sync.Deselected(hr) {
+		c.logger.Log("info", "HelmRelease no longer matches the release selector, leaving its release", "resource", hr.ResourceID().String())
+		return
+	}
 	c.logger.Log("info", "deleting release", "resource", hr.ResourceID().String())
 	c.sync.DeleteRelease(hr)
 }
@@ -0,0 +1,124 @@
+package operator
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// valuesSourceIndex is the name of the index of HelmReleases by the
+// ConfigMaps and Secrets they take values from.
+const valuesSourceIndex = "valuesSource"
+
+// valuesSourceKey returns the key in the values source index of the
+// ConfigMap or Secret (the kind) with the given namespace and name.
+func valuesSourceKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// valuesSourceIndexFunc indexes a HelmRelease by the ConfigMaps and
+// Secrets it takes values from. These are always in the namespace of
+// the HelmRelease.
+func valuesSourceIndexFunc(obj interface{}) ([]string, error) {
+	hr, ok := obj.(*helmfluxv1.HelmRelease)
+	if !ok {
+		return nil, nil
+	}
+	// The values files of the chart are not indexed, so an error
+	// resolving one does not matter here.
+	sources, _ := hr.GetValuesFromSources()
+	var keys []string
+	for _, source := range sources {
+		switch {
+		case source.ConfigMapKeyRef != nil:
+			keys = append(keys, valuesSourceKey("ConfigMap", hr.Namespace, source.ConfigMapKeyRef.Name))
+		case source.SecretKeyRef != nil:
+			keys = append(keys, valuesSourceKey("Secret", hr.Namespace, source.SecretKeyRef.Name))
+		case source.ConfigMapKeysRef != nil:
+			keys = append(keys, valuesSourceKey("ConfigMap", hr.Namespace, source.ConfigMapKeysRef.Name))
+		case source.SecretKeysRef != nil:
+			keys = append(keys, valuesSourceKey("Secret", hr.Namespace, source.SecretKeysRef.Name))
+		}
+	}
+	if source := hr.Spec.ConfigMapChartSource; source != nil {
+		keys = append(keys, valuesSourceKey("ConfigMap", hr.Namespace, source.ConfigMap.Name))
+	}
+	return keys, nil
+}
+
+// WatchValuesSources makes the operator watch the ConfigMaps and
+// Secrets HelmReleases take values from, and schedule a release for
+// the HelmReleases whose values have changed as a result of a change
+// to them. It must be called before the informers are started.
+func (c *Controller) WatchValuesSources(hrInformer cache.SharedIndexInformer, cmInformer coreinformers.ConfigMapInformer, secretInformer coreinformers.SecretInformer) error {
+	if err := hrInformer.AddIndexers(cache.Indexers{valuesSourceIndex: valuesSourceIndexFunc}); err != nil {
+		return err
+	}
+	c.hrIndexer = hrInformer.GetIndexer()
+
+	handler := func(kind string) cache.ResourceEventHandler {
+		return cache.ResourceEventHandlerFuncs{
+			AddFunc: func(new interface{}) {
+				c.enqueueValuesSourceJobs(kind, new)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				oldMeta, err := meta.Accessor(old)
+				if err != nil {
+					return
+				}
+				newMeta, err := meta.Accessor(new)
+				if err != nil {
+					return
+				}
+				// Periodic resyncs do not change anything
+				if oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+					return
+				}
+				c.enqueueValuesSourceJobs(kind, new)
+			},
+			DeleteFunc: func(old interface{}) {
+				if tombstone, ok := old.(cache.DeletedFinalStateUnknown); ok {
+					old = tombstone.Obj
+				}
+				c.enqueueValuesSourceJobs(kind, old)
+			},
+		}
+	}
+	cmInformer.Informer().AddEventHandler(handler("ConfigMap"))
+	secretInformer.Informer().AddEventHandler(handler("Secret"))
+	return nil
+}
+
+// enqueueValuesSourceJobs schedules a release for the HelmReleases
+// taking values from the given ConfigMap or Secret (the kind), if the
+// values they are released with are no longer up-to-date.
+func (c *Controller) enqueueValuesSourceJobs(kind string, obj interface{}) {
+	objMeta, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	key := valuesSourceKey(kind, objMeta.GetNamespace(), objMeta.GetName())
+	hrs, err := c.hrIndexer.ByIndex(valuesSourceIndex, key)
+	if err != nil {
+		c.logger.Log("error", err.Error())
+		return
+	}
+	for _, obj := range hrs {
+		hr, ok := checkCustomResourceType(c.logger, obj)
+		if !ok || hr.DeletionTimestamp != nil || !c.sync.Selected(hr) {
+			continue
+		}
+		// A change to the config map holding the chart may change
+		// the chart rather than the values
+		chartSource := hr.Spec.ConfigMapChartSource != nil && kind == "ConfigMap" && hr.Spec.ConfigMapChartSource.ConfigMap.Name == objMeta.GetName()
+		if !chartSource && c.sync.CompareValuesChecksum(hr) {
+			continue
+		}
+		c.logger.Log("info", "enqueuing release due to change in values source", "resource", hr.ResourceID().String(), "source", key)
+		c.enqueueJob(obj)
+	}
+}
@@ -0,0 +1,50 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func Test_valuesSourceIndexFunc(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{valuesSourceIndex: valuesSourceIndexFunc})
+	hr := func(namespace, name string, valuesFrom ...helmfluxv1.ValuesFromSource) *helmfluxv1.HelmRelease {
+		return &helmfluxv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       helmfluxv1.HelmReleaseSpec{ValuesFrom: valuesFrom},
+		}
+	}
+	configMap := helmfluxv1.ValuesFromSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "shared"}}}
+	secret := helmfluxv1.ValuesFromSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "shared"}}}
+
+	indexer.Add(hr("team-a", "podinfo", configMap, secret))
+	indexer.Add(hr("team-a", "redis", configMap))
+	indexer.Add(hr("team-b", "podinfo", configMap))
+	legacy := hr("team-b", "legacy")
+	legacy.Spec.ValueFileSecrets = []v1.LocalObjectReference{{Name: "shared"}}
+	indexer.Add(legacy)
+	embedded := hr("team-b", "embedded")
+	embedded.Spec.ChartSource.ConfigMapChartSource = &helmfluxv1.ConfigMapChartSource{ConfigMap: helmfluxv1.ConfigMapChartRef{Name: "shared"}}
+	indexer.Add(embedded)
+
+	keys := func(indexKey string) []string {
+		objs, err := indexer.ByIndex(valuesSourceIndex, indexKey)
+		assert.NoError(t, err)
+		var keys []string
+		for _, obj := range objs {
+			key, _ := cache.MetaNamespaceKeyFunc(obj)
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	assert.ElementsMatch(t, []string{"team-a/podinfo", "team-a/redis"}, keys(valuesSourceKey("ConfigMap", "team-a", "shared")))
+	assert.ElementsMatch(t, []string{"team-b/podinfo", "team-b/embedded"}, keys(valuesSourceKey("ConfigMap", "team-b", "shared")))
+	assert.ElementsMatch(t, []string{"team-a/podinfo"}, keys(valuesSourceKey("Secret", "team-a", "shared")))
+	assert.ElementsMatch(t, []string{"team-b/legacy"}, keys(valuesSourceKey("Secret", "team-b", "shared")))
+	assert.Empty(t, keys(valuesSourceKey("Secret", "team-c", "shared")))
+}
@@ -0,0 +1,120 @@
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+// CanonicalValuesChecksum calculates the SHA256 checksum of the
+// canonical YAML of the given values, so that the same values always
+// have the same checksum, no matter how they were composed.
+func CanonicalValuesChecksum(vals chartutil.Values) (string, error) {
+	raw, err := canonicalValuesYAML(vals)
+	if err != nil {
+		return "", err
+	}
+	return ValuesChecksum(raw), nil
+}
+
+// LegacyValuesChecksum calculates the checksum of the given values
+// the way it was calculated before it was over their canonical YAML,
+// so that the checksums recorded by earlier versions in the status of
+// HelmReleases can still be recognised.
+func LegacyValuesChecksum(vals chartutil.Values) (string, error) {
+	raw, err := vals.YAML()
+	if err != nil {
+		return "", err
+	}
+	return ValuesChecksum([]byte(raw)), nil
+}
+
+// canonicalValuesYAML returns the given values as YAML, with the keys
+// of all maps sorted and all numbers formatted the same way, whatever
+// their type.
+func canonicalValuesYAML(vals chartutil.Values) ([]byte, error) {
+	return yaml.Marshal(canonicalValue(map[string]interface{}(vals)))
+}
+
+// canonicalValue returns the given value with all maps as maps with
+// string keys, and all numbers as int64 if they are whole and as
+// float64 otherwise.
+func canonicalValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case chartutil.Values:
+		return canonicalValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = canonicalValue(e)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = canonicalValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = canonicalValue(e)
+		}
+		return l
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return canonicalFloat(f)
+		}
+		return v.String()
+	case float32:
+		// Take the shortest representation of the float32 rather
+		// than that of its (inexact) float64 conversion.
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return canonicalFloat(f)
+	case float64:
+		return canonicalFloat(v)
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return canonicalUint(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return canonicalUint(v)
+	}
+	return v
+}
+
+// canonicalFloat returns the given float as int64 if it is whole and
+// can be represented exactly.
+func canonicalFloat(f float64) interface{} {
+	if f == math.Trunc(f) && math.Abs(f) <= 1<<53 {
+		return int64(f)
+	}
+	return f
+}
+
+// canonicalUint returns the given uint as int64 if it fits.
+func canonicalUint(u uint64) interface{} {
+	if u <= math.MaxInt64 {
+		return int64(u)
+	}
+	return u
+}
@@ -0,0 +1,52 @@
+package release
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+func TestCanonicalValuesChecksum(t *testing.T) {
+	fromYAML, err := chartutil.ReadValues([]byte("replicas: 3\nimage:\n  tag: 1.0.0\n  pullPolicy: Always\nratio: 0.5\nports: [80, 443]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	composed := chartutil.Values{
+		"ports": []interface{}{json.Number("80"), uint16(443)},
+		"ratio": float32(0.5),
+		"image": map[interface{}]interface{}{
+			"pullPolicy": "Always",
+			"tag":        "1.0.0",
+		},
+		"replicas": int64(3),
+	}
+
+	expected, err := CanonicalValuesChecksum(fromYAML)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		checksum, err := CanonicalValuesChecksum(composed)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, checksum)
+	}
+
+	raw, err := canonicalValuesYAML(composed)
+	assert.NoError(t, err)
+	assert.Equal(t, "image:\n  pullPolicy: Always\n  tag: 1.0.0\nports:\n- 80\n- 443\nratio: 0.5\nreplicas: 3\n", string(raw))
+
+	composed["ratio"] = 0.25
+	checksum, err := CanonicalValuesChecksum(composed)
+	assert.NoError(t, err)
+	assert.NotEqual(t, expected, checksum)
+}
+
+func TestCanonicalValue_numbers(t *testing.T) {
+	for _, v := range []interface{}{3, int32(3), uint(3), float32(3), 3.0, json.Number("3"), json.Number("3.0")} {
+		assert.Equal(t, int64(3), canonicalValue(v), "%T", v)
+	}
+	assert.Equal(t, 0.1, canonicalValue(float32(0.1)))
+	assert.Equal(t, int64(0), canonicalValue(math.Copysign(0, -1)))
+	assert.Equal(t, 1e300, canonicalValue(1e300))
+}
@@ -0,0 +1,149 @@
+package release
+
+import (
+	"net/http"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	storageerrors "k8s.io/helm/pkg/storage/errors"
+)
+
+// ErrorCategory is the category of the error an install or upgrade
+// failed with, for automation to act on (e.g. to alert on template
+// errors, but not on transient network errors).
+type ErrorCategory string
+
+const (
+	// ErrorCategoryNetwork is a failure to reach Tiller or the
+	// Kubernetes API, which is likely transient.
+	ErrorCategoryNetwork ErrorCategory = "network"
+	// ErrorCategoryAuth is a failure to authenticate, or a lack of
+	// permissions to release the resources.
+	ErrorCategoryAuth ErrorCategory = "auth"
+	// ErrorCategoryValidation is a failure of the values or of the
+	// rendered resources to validate.
+	ErrorCategoryValidation ErrorCategory = "validation"
+	// ErrorCategoryTemplate is a failure to render the templates of
+	// the chart.
+	ErrorCategoryTemplate ErrorCategory = "template"
+	// ErrorCategoryTimeout is the release (or one of its hooks) not
+	// becoming ready within the timeout.
+	ErrorCategoryTimeout ErrorCategory = "timeout"
+	// ErrorCategoryUnknown is any other failure.
+	ErrorCategoryUnknown ErrorCategory = "unknown"
+)
+
+// The errors of Tiller reach us as (gRPC) strings, so they are told
+// apart by the messages Helm and Kubernetes are known to give.
+var (
+	templateErrors = []string{
+		"render error in",
+		"parse error in",
+		"YAML parse error on",
+		"error converting YAML to JSON",
+	}
+	validationErrors = []string{
+		"error validating",
+		"unable to recognize",
+		"is invalid:",
+		"unable to build kubernetes objects",
+	}
+	authErrors = []string{
+		"code = PermissionDenied",
+		"code = Unauthenticated",
+		"is forbidden:",
+		"Unauthorized",
+		"x509:",
+	}
+	networkErrors = []string{
+		"code = Unavailable",
+		"transport is closing",
+		"connection refused",
+		"connection reset by peer",
+		"no such host",
+		"i/o timeout",
+		"TLS handshake timeout",
+		"unexpected EOF",
+	}
+)
+
+// Classify returns the category of the error returned by an install
+// or upgrade.
+func Classify(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	if IsTimeout(err) {
+		return ErrorCategoryTimeout
+	}
+	if IsValuesInvalid(err) || IsValuesPath(err) || IsValuesTemplate(err) {
+		return ErrorCategoryValidation
+	}
+	msg := err.Error()
+	for _, c := range []struct {
+		category ErrorCategory
+		messages []string
+	}{
+		{ErrorCategoryTemplate, templateErrors},
+		{ErrorCategoryValidation, validationErrors},
+		{ErrorCategoryAuth, authErrors},
+		{ErrorCategoryNetwork, networkErrors},
+	} {
+		for _, m := range c.messages {
+			if strings.Contains(msg, m) {
+				return c.category
+			}
+		}
+	}
+	return ErrorCategoryUnknown
+}
+
+// timeoutError is an error of Tiller that is the result of the
+// release (or one of its hooks) not becoming ready within the
+// timeout, or of the call to Tiller exceeding its deadline. It is a
+// net.Error, so that IsTimeout tells it apart like other timeouts.
+type timeoutError struct {
+	msg string
+}
+
+func (e *timeoutError) Error() string   { return e.msg }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return false }
+
+// tillerError returns the given error of Tiller as the Forbidden
+// StatusError it was in Tiller if it is a lack of permissions, so
+// that it can be told apart with apierrors.IsForbidden like those of
+// the Kubernetes API, and as a timeoutError if it is a timeout.
+// Other errors are returned as they are.
+func tillerError(err error) error {
+	if err == nil || apierrors.IsForbidden(err) || IsTimeout(err) {
+		return err
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "is forbidden:") || strings.Contains(msg, "code = PermissionDenied"):
+		return &apierrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: msg,
+		}}
+	case strings.Contains(msg, wait.ErrWaitTimeout.Error()) || strings.Contains(msg, "code = DeadlineExceeded"):
+		return &timeoutError{msg}
+	}
+	return err
+}
+
+// releaseError returns the given error of Tiller for the release
+// with the given name as a NotFound StatusError if it is Helm's error
+// for the release not existing, so that it can be told apart with
+// apierrors.IsNotFound, and as tillerError does otherwise.
+func releaseError(err error, name string) error {
+	if err != nil && strings.HasSuffix(err.Error(), storageerrors.ErrReleaseNotFound(name).Error()) {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "releases"}, name)
+	}
+	return tillerError(err)
+}
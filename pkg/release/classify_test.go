@@ -0,0 +1,55 @@
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	storageerrors "k8s.io/helm/pkg/storage/errors"
+)
+
+func TestClassify(t *testing.T) {
+	for msg, category := range map[string]ErrorCategory{
+		"release podinfo failed: timed out waiting for the condition":                                                        ErrorCategoryTimeout,
+		"rpc error: code = Unknown desc = render error in \"podinfo/templates/deployment.yaml\": template: nil pointer":      ErrorCategoryTemplate,
+		"rpc error: code = Unknown desc = YAML parse error on podinfo/templates/service.yaml: error converting YAML to JSON": ErrorCategoryTemplate,
+		"rpc error: code = Unknown desc = error validating \"\": error validating data: unknown field \"replica\"":           ErrorCategoryValidation,
+		"release podinfo failed: deployments.apps \"podinfo\" is forbidden: User \"system:serviceaccount\" cannot create":    ErrorCategoryAuth,
+		"rpc error: code = Unavailable desc = transport is closing":                                                          ErrorCategoryNetwork,
+		"dial tcp 10.0.0.1:44134: connect: connection refused":                                                               ErrorCategoryNetwork,
+		"release podinfo failed: something unexpected":                                                                       ErrorCategoryUnknown,
+	} {
+		assert.Equal(t, category, Classify(tillerError(errors.New(msg))), msg)
+	}
+	assert.Equal(t, ErrorCategoryValidation, Classify(&preApplyError{&ValuesInvalidError{}}))
+	assert.Equal(t, ErrorCategory(""), Classify(nil))
+}
+
+func TestTillerError(t *testing.T) {
+	denied := errors.New(`rpc error: code = Unknown desc = secrets is forbidden: User "system:serviceaccount:kube-system:tiller" cannot create resource "secrets" in the namespace "team"`)
+	err := tillerError(denied)
+	assert.True(t, apierrors.IsForbidden(err))
+	assert.Equal(t, denied.Error(), err.Error())
+	assert.Equal(t, ErrorCategoryAuth, Classify(err))
+
+	other := errors.New("rpc error: code = Unknown desc = render error in \"podinfo/templates/deployment.yaml\"")
+	assert.Equal(t, other, tillerError(other))
+	assert.NoError(t, tillerError(nil))
+	assert.False(t, apierrors.IsForbidden(&preApplyError{apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "values", denied)}))
+}
+
+func TestReleaseError(t *testing.T) {
+	assert.True(t, apierrors.IsNotFound(releaseError(errors.New(`rpc error: code = Unknown desc = release: "podinfo" not found`), "podinfo")))
+	assert.True(t, apierrors.IsNotFound(releaseError(storageerrors.ErrReleaseNotFound("podinfo"), "podinfo")))
+
+	// Anything else that is not found is not the release missing
+	for _, err := range []error{
+		errors.New(`rpc error: code = Unknown desc = release: "podinfo-canary" not found`),
+		errors.New(`rpc error: code = Unknown desc = configmaps "podinfo" not found`),
+	} {
+		assert.False(t, apierrors.IsNotFound(releaseError(err, "podinfo")), err.Error())
+	}
+	assert.NoError(t, releaseError(nil, "podinfo"))
+}
@@ -0,0 +1,49 @@
+package release
+
+import (
+	"github.com/golang/protobuf/proto"
+	"k8s.io/helm/pkg/chartutil"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+)
+
+// clientSideRelease returns the release a dry run install of the
+// given chart (loaded from the given path if nil) with the given
+// values results in, without asking Tiller. It has the chart and the
+// values Tiller would record, so that they can be compared with those
+// of the current release, but no manifests, and nothing is validated
+// against the cluster.
+func clientSideRelease(chartPath string, c *hapi_chart.Chart, releaseName, namespace string, rawVals []byte) (*hapi_release.Release, error) {
+	var err error
+	if c == nil {
+		if c, err = chartutil.Load(chartPath); err != nil {
+			return nil, err
+		}
+	}
+	vals := &hapi_chart.Config{Raw: string(rawVals)}
+
+	// Process the chart as Helm's client does before handing it to
+	// Tiller, and give it the shape it has once it made the round
+	// trip, so that it compares equal to the chart of a release.
+	if err = chartutil.ProcessRequirementsEnabled(c, vals); err != nil {
+		return nil, err
+	}
+	if err = chartutil.ProcessRequirementsImportValues(c); err != nil {
+		return nil, err
+	}
+	b, err := proto.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var sent hapi_chart.Chart
+	if err = proto.Unmarshal(b, &sent); err != nil {
+		return nil, err
+	}
+
+	return &hapi_release.Release{
+		Name:      releaseName,
+		Namespace: namespace,
+		Chart:     &sent,
+		Config:    vals,
+	}, nil
+}
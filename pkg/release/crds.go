@@ -0,0 +1,133 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/helm/pkg/chartutil"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+// crdsDir is the directory of a chart (and of each of its subcharts)
+// with the CRDs it needs. Helm 2 leaves the files in it alone, as it
+// only renders templates.
+const crdsDir = "crds/"
+
+// crdsNotEstablishedError is the error of an install or upgrade of
+// which the CRDs did not become established.
+type crdsNotEstablishedError struct {
+	err error
+}
+
+func (e *crdsNotEstablishedError) Error() string {
+	return "CRDs did not become established: " + e.err.Error()
+}
+
+// IsCRDsNotEstablished returns if the error returned by an install or
+// upgrade is the result of the CRDs of the chart, created before the
+// release, not becoming established.
+func IsCRDsNotEstablished(err error) bool {
+	if e, ok := err.(*preApplyError); ok {
+		err = e.err
+	}
+	_, ok := err.(*crdsNotEstablishedError)
+	return ok
+}
+
+// isCRD returns if the given object is a CustomResourceDefinition.
+func isCRD(obj unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition"
+}
+
+// chartCRDs returns the CRDs in the crds/ directories of the chart
+// at the given path and of its subcharts.
+func chartCRDs(chartPath string) ([]unstructured.Unstructured, error) {
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	return collectCRDs(c)
+}
+
+func collectCRDs(c *hapi_chart.Chart) ([]unstructured.Unstructured, error) {
+	var crds []unstructured.Unstructured
+	for _, f := range c.GetFiles() {
+		if !strings.HasPrefix(f.TypeUrl, crdsDir) {
+			continue
+		}
+		switch path.Ext(f.TypeUrl) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		for _, manifest := range manifestSeparator.Split(string(f.Value), -1) {
+			if strings.TrimSpace(manifest) == "" {
+				continue
+			}
+			bytes, err := yaml.YAMLToJSON([]byte(manifest))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %s", f.TypeUrl, err)
+			}
+			var obj unstructured.Unstructured
+			if err := obj.UnmarshalJSON(bytes); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %s", f.TypeUrl, err)
+			}
+			if !isCRD(obj) {
+				return nil, fmt.Errorf("%s contains a %s, only CRDs are allowed", f.TypeUrl, obj.GetKind())
+			}
+			crds = append(crds, obj)
+		}
+	}
+	for _, dep := range c.GetDependencies() {
+		depCRDs, err := collectCRDs(dep)
+		if err != nil {
+			return nil, err
+		}
+		crds = append(crds, depCRDs...)
+	}
+	return crds, nil
+}
+
+// establishCRDs creates the given CRDs that do not exist yet, and
+// waits for all of them to become established within the given
+// timeout. CRDs that exist are left as they are.
+func establishCRDs(crds []unstructured.Unstructured, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+5*time.Second)
+	defer cancel()
+
+	names := make([]string, 0, len(crds))
+	for _, crd := range crds {
+		name := "crd/" + crd.GetName()
+		names = append(names, name)
+		output, err := exec.CommandContext(ctx, "kubectl", "get", name, "--ignore-not-found", "-o", "name").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to get CRD %s: %s: %s", crd.GetName(), err, strings.TrimSpace(string(output)))
+		}
+		if len(bytes.TrimSpace(output)) > 0 {
+			continue
+		}
+		crdJSON, err := crd.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, "kubectl", "create", "-f", "-")
+		cmd.Stdin = bytes.NewReader(crdJSON)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create CRD %s: %s: %s", crd.GetName(), err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	args := append([]string{"wait", "--for", "condition=established", "--timeout", timeout.String()}, names...)
+	if output, err := exec.CommandContext(ctx, "kubectl", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package release
+
+import (
+	"testing"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/any"
+	"github.com/stretchr/testify/assert"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+const crdManifests = `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: canaries.flagger.app
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: alerts.flagger.app
+`
+
+func TestCollectCRDs(t *testing.T) {
+	c := &hapi_chart.Chart{
+		Files: []*google_protobuf.Any{
+			{TypeUrl: "crds/crds.yaml", Value: []byte(crdManifests)},
+			{TypeUrl: "crds/README.md", Value: []byte("kind: CustomResourceDefinition")},
+			{TypeUrl: "README.md", Value: []byte("kind: CustomResourceDefinition")},
+		},
+		Templates: []*hapi_chart.Template{
+			{Name: "templates/crd.yaml", Data: []byte("kind: CustomResourceDefinition\nmetadata:\n  name: templated\n")},
+		},
+		Dependencies: []*hapi_chart.Chart{{Files: []*google_protobuf.Any{
+			{TypeUrl: "crds/metrics.yaml", Value: []byte("apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\nmetadata:\n  name: metrics.flagger.app\n")},
+		}}},
+	}
+
+	crds, err := collectCRDs(c)
+	assert.NoError(t, err)
+	var names []string
+	for _, crd := range crds {
+		names = append(names, crd.GetName())
+	}
+	// Templates are left to Tiller, and the chart is not changed
+	assert.Equal(t, []string{"canaries.flagger.app", "alerts.flagger.app", "metrics.flagger.app"}, names)
+	assert.Equal(t, crdManifests, string(c.Files[0].Value))
+
+	// Only CRDs belong in crds/
+	c.Files = append(c.Files, &google_protobuf.Any{TypeUrl: "crds/canary.yaml", Value: []byte("apiVersion: flagger.app/v1alpha3\nkind: Canary\nmetadata:\n  name: podinfo\n")})
+	_, err = collectCRDs(c)
+	assert.Error(t, err)
+}
+
+func TestIsCRDsNotEstablished(t *testing.T) {
+	err := &crdsNotEstablishedError{assert.AnError}
+	assert.True(t, IsCRDsNotEstablished(err))
+	assert.True(t, IsCRDsNotEstablished(&preApplyError{err}))
+	assert.False(t, IsCRDsNotEstablished(assert.AnError))
+}
@@ -0,0 +1,186 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// CorrectDrift compares the resources of the given release with
+// their live state in the cluster, and applies the manifests of those
+// that drifted from them (or no longer exist) again. Only resources
+// selected by one of the given include targets (if any) and by none
+// of the exclude targets are considered. It returns the corrected
+// resources, as '<namespace>:<kind>/<name>'.
+func (r *Release) CorrectDrift(release *hapi_release.Release, include, exclude []helmfluxv1.PatchTarget) ([]string, error) {
+	var corrected, failed []string
+	for _, obj := range releaseManifestToUnstructured(release.Manifest, log.NewNopLogger()) {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = release.Namespace
+		}
+		if !driftConsidered(obj, namespace, include, exclude) {
+			continue
+		}
+		resource := namespace + ":" + obj.GetKind() + "/" + obj.GetName()
+		// The status is not applied, but reported by the cluster
+		delete(obj.Object, "status")
+
+		live, found, err := getLive(obj, namespace, getLiveTimeout)
+		if err != nil {
+			r.logger.Log("warning", "unable to get resource to detect drift", "resource", resource, "err", err)
+			continue
+		}
+		if found && !drifted(obj.Object, live.Object) {
+			continue
+		}
+
+		if err := apply(obj, namespace); err != nil {
+			r.logger.Log("warning", "unable to correct drift", "resource", resource, "err", err)
+			failed = append(failed, resource)
+			continue
+		}
+		corrected = append(corrected, resource)
+	}
+	sort.Strings(corrected)
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return corrected, fmt.Errorf("failed to correct the drift of %s", strings.Join(failed, ", "))
+	}
+	return corrected, nil
+}
+
+// driftConsidered returns if drift detection considers the given
+// object, in the given namespace if it does not name one: it must be
+// selected by one of the include targets, if there are any, and by
+// none of the exclude targets.
+func driftConsidered(obj unstructured.Unstructured, namespace string, include, exclude []helmfluxv1.PatchTarget) bool {
+	included := len(include) == 0
+	for _, target := range include {
+		if targets(target, obj, namespace) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, target := range exclude {
+		if targets(target, obj, namespace) {
+			return false
+		}
+	}
+	return true
+}
+
+// getLiveTimeout is the timeout of getting the live state of a
+// resource to detect drift.
+const getLiveTimeout = 5 * time.Second
+
+// getLive returns the live state of the given object, in the given
+// namespace if it does not name one, and if it exists. The lookup is
+// aborted after the given timeout.
+func getLive(obj unstructured.Unstructured, namespace string, timeout time.Duration) (unstructured.Unstructured, bool, error) {
+	var live unstructured.Unstructured
+	args := []string{"get", "--namespace", namespace, obj.GetKind() + "/" + obj.GetName(), "-o", "json"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "kubectl", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && strings.Contains(string(exitErr.Stderr), "NotFound") {
+			return live, false, nil
+		}
+		return live, false, err
+	}
+	if err := live.UnmarshalJSON(out); err != nil {
+		return live, false, err
+	}
+	return live, true, nil
+}
+
+// apply applies the given object to the cluster, in the given
+// namespace if it does not name one.
+func apply(obj unstructured.Unstructured, namespace string) error {
+	objJSON, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "kubectl", "apply", "--namespace", namespace, "-f", "-")
+	cmd.Stdin = bytes.NewReader(objJSON)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// drifted returns if the given live state of an object differs from
+// the given desired state. Only what is desired is compared: fields
+// the cluster adds (e.g. defaults) are not drift.
+func drifted(desired, live interface{}) bool {
+	switch d := desired.(type) {
+	case nil:
+		return false
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return len(d) > 0
+		}
+		for k, v := range d {
+			if drifted(v, l[k]) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return len(d) > 0
+		}
+		if len(d) != len(l) {
+			return true
+		}
+		for i := range d {
+			if drifted(d[i], l[i]) {
+				return true
+			}
+		}
+		return false
+	case int64, float64:
+		return !sameNumber(d, live)
+	default:
+		return desired != live
+	}
+}
+
+// sameNumber returns if the given JSON numbers are equal, regardless
+// of them being decoded as integers or floats.
+func sameNumber(a, b interface{}) bool {
+	toFloat := func(n interface{}) (float64, bool) {
+		switch v := n.(type) {
+		case int64:
+			return float64(v), true
+		case float64:
+			return v, true
+		}
+		return 0, false
+	}
+	x, ok := toFloat(a)
+	y, ok2 := toFloat(b)
+	return ok && ok2 && x == y
+}
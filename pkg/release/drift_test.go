@@ -0,0 +1,83 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestDrifted(t *testing.T) {
+	desired := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "podinfo"},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "podinfo", "image": "stefanprodan/podinfo:3.1.0"},
+					},
+				},
+			},
+		},
+	}
+	live := func(replicas interface{}, image string) map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "podinfo", "uid": "1234"},
+			"spec": map[string]interface{}{
+				"replicas":             replicas,
+				"revisionHistoryLimit": int64(10),
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "podinfo", "image": image, "imagePullPolicy": "IfNotPresent"},
+						},
+					},
+				},
+			},
+			"status": map[string]interface{}{"replicas": int64(2)},
+		}
+	}
+
+	assert.False(t, drifted(desired, live(int64(2), "stefanprodan/podinfo:3.1.0")))
+	assert.False(t, drifted(desired, live(float64(2), "stefanprodan/podinfo:3.1.0")))
+	assert.True(t, drifted(desired, live(int64(3), "stefanprodan/podinfo:3.1.0")))
+	assert.True(t, drifted(desired, live(int64(2), "stefanprodan/podinfo:3.0.0")))
+	assert.True(t, drifted(desired, map[string]interface{}{"metadata": map[string]interface{}{"name": "podinfo"}}))
+	assert.True(t, drifted([]interface{}{"a", "b"}, []interface{}{"a"}))
+	assert.False(t, drifted(map[string]interface{}{"labels": map[string]interface{}{}}, map[string]interface{}{}))
+}
+
+func TestDriftConsidered(t *testing.T) {
+	obj := func(apiVersion, kind, name string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name},
+		}}
+	}
+	deployment := obj("apps/v1", "Deployment", "podinfo")
+	hpa := obj("autoscaling/v2beta2", "HorizontalPodAutoscaler", "podinfo")
+	secret := obj("v1", "Secret", "podinfo-tls")
+
+	// Everything is considered by default
+	for _, o := range []unstructured.Unstructured{deployment, hpa, secret} {
+		assert.True(t, driftConsidered(o, "default", nil, nil), o.GetKind())
+	}
+
+	exclude := []helmfluxv1.PatchTarget{
+		{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"},
+		{Kind: "Secret", Name: "podinfo-tls"},
+	}
+	assert.True(t, driftConsidered(deployment, "default", nil, exclude))
+	assert.False(t, driftConsidered(hpa, "default", nil, exclude))
+	assert.False(t, driftConsidered(secret, "default", nil, exclude))
+
+	include := []helmfluxv1.PatchTarget{{Group: "apps"}, {Kind: "Secret"}}
+	assert.True(t, driftConsidered(deployment, "default", include, nil))
+	assert.False(t, driftConsidered(hpa, "default", include, nil))
+	assert.False(t, driftConsidered(secret, "default", include, exclude))
+	assert.False(t, driftConsidered(deployment, "default", []helmfluxv1.PatchTarget{{Group: "apps", Namespace: "team"}}, nil))
+}
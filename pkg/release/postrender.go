@@ -0,0 +1,304 @@
+package release
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	google_protobuf "github.com/golang/protobuf/ptypes/any"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/engine"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/renderutil"
+	tversion "k8s.io/helm/pkg/version"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// postRenderedDir is the directory in the post-rendered chart the
+// rendered manifests are stored in.
+const postRenderedDir = "postrendered"
+
+var manifestSeparator = regexp.MustCompile("(?:^|\\s*\n)---\\s*")
+
+// postRenderChart renders the chart at the given path with the given
+// values, passes the manifests through the post-renderers, adds the
+// common labels and the annotations to them, and returns a chart that
+// renders to the result.
+//
+// As Tiller renders charts server side, there is no way to intercept
+// the manifests before they are applied; the returned chart stores
+// the post-rendered manifests as files and has a template for each
+// of them that outputs it as is. The chart is rendered with the name
+// of the release rather than the name given to Tiller, so that a dry
+// run results in the same chart as the actual release would. It is
+// rendered with the given capabilities, so that templates that depend
+// on the version or the API versions of the cluster render as they
+// would in Tiller.
+func postRenderChart(chartPath, releaseName, namespace string, rawVals []byte, caps *chartutil.Capabilities, labels, annotations map[string]string, renderers []helmfluxv1.PostRenderer) (*hapi_chart.Chart, error) {
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart for post-rendering: %s", err)
+	}
+
+	rendered, err := renderChart(c, &hapi_chart.Config{Raw: string(rawVals)}, chartutil.ReleaseOptions{Name: releaseName, Namespace: namespace}, caps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart for post-rendering: %s", err)
+	}
+
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pc := &hapi_chart.Chart{Metadata: c.Metadata}
+	for i, name := range names {
+		content := rendered[name]
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		if path.Base(name) != chartutil.NotesName {
+			if content, err = postRenderManifests(content, namespace, labels, annotations, renderers); err != nil {
+				return nil, fmt.Errorf("failed to post-render %s: %s", name, err)
+			}
+		}
+		file := path.Join(postRenderedDir, name)
+		pc.Files = append(pc.Files, &google_protobuf.Any{TypeUrl: file, Value: []byte(content)})
+		tplName := path.Join("templates", fmt.Sprintf("%03d-%s", i, path.Base(name)))
+		if path.Base(name) == chartutil.NotesName {
+			tplName = path.Join("templates", chartutil.NotesName)
+		}
+		pc.Templates = append(pc.Templates, &hapi_chart.Template{
+			Name: tplName,
+			Data: []byte(fmt.Sprintf("{{ .Files.Get %q }}", file)),
+		})
+	}
+	return pc, nil
+}
+
+// capabilitiesMaxAge is the duration for which the discovered
+// capabilities of the cluster are used for rendering, before they
+// are discovered again to take in API versions added since (e.g. by
+// the CRDs of a release).
+const capabilitiesMaxAge = 5 * time.Minute
+
+// capabilities returns the capabilities of the cluster, discovering
+// them if they were not yet or are older than capabilitiesMaxAge.
+func (r *Release) capabilities() (*chartutil.Capabilities, error) {
+	r.capsMu.Lock()
+	defer r.capsMu.Unlock()
+	if r.caps != nil && time.Since(r.capsUpdated) < capabilitiesMaxAge {
+		return r.caps, nil
+	}
+	caps, err := capabilities(r.discovery)
+	if err != nil {
+		return nil, err
+	}
+	r.caps, r.capsUpdated = caps, time.Now()
+	return caps, nil
+}
+
+// capabilities returns the capabilities of the cluster the given
+// discovery client talks to, determined the way Tiller does. Without
+// a discovery client, Helm's defaults are returned.
+func capabilities(disc discovery.DiscoveryInterface) (*chartutil.Capabilities, error) {
+	caps := &chartutil.Capabilities{
+		APIVersions:   chartutil.DefaultVersionSet,
+		KubeVersion:   chartutil.DefaultKubeVersion,
+		TillerVersion: tversion.GetVersionProto(),
+	}
+	if disc == nil {
+		return caps, nil
+	}
+	sv, err := disc.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the version of the cluster: %s", err)
+	}
+	// Some providers report minor versions like "15+", so the
+	// major and minor version are taken from the git version.
+	v, err := utilversion.ParseGeneric(sv.GitVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the version of the cluster: %s", err)
+	}
+	kv := *sv
+	kv.Major, kv.Minor = strconv.FormatUint(uint64(v.Major()), 10), strconv.FormatUint(uint64(v.Minor()), 10)
+	caps.KubeVersion = &kv
+	groups, err := disc.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the API versions of the cluster: %s", err)
+	}
+	if groups.Size() > 0 {
+		caps.APIVersions = chartutil.NewVersionSet(metav1.ExtractGroupVersions(groups)...)
+	}
+	return caps, nil
+}
+
+// renderChart renders the given chart with the given values like
+// renderutil.Render does, but with the given capabilities rather than
+// Helm's defaults.
+func renderChart(c *hapi_chart.Chart, config *hapi_chart.Config, opts chartutil.ReleaseOptions, caps *chartutil.Capabilities) (map[string]string, error) {
+	if req, err := chartutil.LoadRequirements(c); err == nil {
+		if err := renderutil.CheckDependencies(c, req); err != nil {
+			return nil, err
+		}
+	} else if err != chartutil.ErrRequirementsNotFound {
+		return nil, fmt.Errorf("cannot load requirements: %v", err)
+	}
+	if err := chartutil.ProcessRequirementsEnabled(c, config); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ProcessRequirementsImportValues(c); err != nil {
+		return nil, err
+	}
+	vals, err := chartutil.ToRenderValuesCaps(c, config, opts, caps)
+	if err != nil {
+		return nil, err
+	}
+	return engine.New().Render(c, vals)
+}
+
+// postRenderManifests passes the (multi-document) YAML manifests
+// through the post-renderers, and adds the labels and annotations to
+// the objects they describe. Hooks are left untouched.
+func postRenderManifests(manifests, namespace string, labels, annotations map[string]string, renderers []helmfluxv1.PostRenderer) (string, error) {
+	var out []string
+	for _, manifest := range manifestSeparator.Split(manifests, -1) {
+		if strings.TrimSpace(manifest) == "" {
+			continue
+		}
+		bytes, err := yaml.YAMLToJSON([]byte(manifest))
+		if err != nil {
+			return "", err
+		}
+		var obj unstructured.Unstructured
+		if err := obj.UnmarshalJSON(bytes); err != nil {
+			// Not something we can patch, e.g. only comments
+			out = append(out, manifest)
+			continue
+		}
+		if _, ok := obj.GetAnnotations()["helm.sh/hook"]; ok {
+			out = append(out, manifest)
+			continue
+		}
+		for _, renderer := range renderers {
+			if renderer.Kustomize == nil {
+				continue
+			}
+			for _, patch := range renderer.Kustomize.Patches {
+				if bytes, err = kustomizePatch(bytes, obj, namespace, patch); err != nil {
+					return "", err
+				}
+			}
+		}
+		if len(labels) > 0 || len(annotations) > 0 {
+			if bytes, err = addMetadata(bytes, labels, annotations); err != nil {
+				return "", err
+			}
+		}
+		bytes, err = yaml.JSONToYAML(bytes)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, string(bytes))
+	}
+	return strings.Join(out, "---\n"), nil
+}
+
+// addMetadata adds the labels and annotations to the metadata of the
+// object in the given JSON, overriding those by the same name.
+func addMetadata(objJSON []byte, labels, annotations map[string]string) ([]byte, error) {
+	var obj unstructured.Unstructured
+	if err := obj.UnmarshalJSON(objJSON); err != nil {
+		return nil, err
+	}
+	if len(labels) > 0 {
+		obj.SetLabels(mergeStrings(obj.GetLabels(), labels))
+	}
+	if len(annotations) > 0 {
+		obj.SetAnnotations(mergeStrings(obj.GetAnnotations(), annotations))
+	}
+	return obj.MarshalJSON()
+}
+
+// mergeStrings sets the entries of src in dst, which is created if
+// nil, and returns dst.
+func mergeStrings(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// kustomizePatch applies the patch to the JSON of the given object
+// if the object is targeted by it.
+func kustomizePatch(objJSON []byte, obj unstructured.Unstructured, namespace string, patch helmfluxv1.KustomizePatch) ([]byte, error) {
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch.Patch))
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %s", err)
+	}
+	isJSON6902 := strings.HasPrefix(strings.TrimSpace(string(patchJSON)), "[")
+
+	target := patch.Target
+	if target == nil {
+		if isJSON6902 {
+			return nil, fmt.Errorf("a target is required for JSON 6902 patches")
+		}
+		var p unstructured.Unstructured
+		if err := p.UnmarshalJSON(patchJSON); err != nil {
+			return nil, fmt.Errorf("invalid strategic merge patch: %s", err)
+		}
+		gvk := p.GroupVersionKind()
+		target = &helmfluxv1.PatchTarget{
+			Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind,
+			Name: p.GetName(), Namespace: p.GetNamespace(),
+		}
+	}
+	if !targets(*target, obj, namespace) {
+		return objJSON, nil
+	}
+
+	if isJSON6902 {
+		ops, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON 6902 patch: %s", err)
+		}
+		return ops.Apply(objJSON)
+	}
+	if typed, err := scheme.Scheme.New(obj.GroupVersionKind()); err == nil {
+		return strategicpatch.StrategicMergePatch(objJSON, patchJSON, typed)
+	}
+	// Not a type we know the patch strategy of (e.g. a custom
+	// resource), fall back to a JSON merge patch, as kustomize does.
+	return jsonpatch.MergePatch(objJSON, patchJSON)
+}
+
+// targets returns if the given object is selected by the target.
+func targets(target helmfluxv1.PatchTarget, obj unstructured.Unstructured, namespace string) bool {
+	gvk := obj.GroupVersionKind()
+	objNamespace := obj.GetNamespace()
+	if objNamespace == "" {
+		objNamespace = namespace
+	}
+	match := func(want, got string) bool { return want == "" || want == got }
+	return match(target.Group, gvk.Group) &&
+		match(target.Version, gvk.Version) &&
+		match(target.Kind, gvk.Kind) &&
+		match(target.Name, obj.GetName()) &&
+		match(target.Namespace, objNamespace)
+}
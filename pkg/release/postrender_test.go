@@ -0,0 +1,190 @@
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fluxk8s "github.com/fluxcd/flux/pkg/cluster/kubernetes"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/helm/pkg/chartutil"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/renderutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+const postRenderDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: podinfo
+        image: stefanprodan/podinfo:3.1.0
+`
+
+const postRenderInput = postRenderDeployment + `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: podinfo
+`
+
+func TestPostRenderManifests(t *testing.T) {
+	renderers := []helmfluxv1.PostRenderer{{
+		Kustomize: &helmfluxv1.KustomizePostRenderer{
+			Patches: []helmfluxv1.KustomizePatch{
+				{
+					Patch: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+spec:
+  template:
+    spec:
+      containers:
+      - name: podinfo
+        image: stefanprodan/podinfo:3.2.0
+`,
+				},
+				{
+					Patch:  `[{"op": "add", "path": "/metadata/labels", "value": {"patched": "true"}}]`,
+					Target: &helmfluxv1.PatchTarget{Kind: "Service", Name: "podinfo", Namespace: "default"},
+				},
+			},
+		},
+	}}
+
+	out, err := postRenderManifests(postRenderInput, "default", nil, nil, renderers)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "image: stefanprodan/podinfo:3.2.0")
+	assert.Contains(t, out, "replicas: 1")
+	assert.Contains(t, out, "patched: \"true\"")
+
+	_, err = postRenderManifests(postRenderInput, "default", nil, nil, []helmfluxv1.PostRenderer{{
+		Kustomize: &helmfluxv1.KustomizePostRenderer{
+			Patches: []helmfluxv1.KustomizePatch{{Patch: `[{"op": "remove", "path": "/spec"}]`}},
+		},
+	}})
+	assert.Error(t, err)
+}
+
+func TestPostRenderManifestsLabels(t *testing.T) {
+	input := postRenderInput + `  labels:
+    app: podinfo
+    team: a
+`
+	out, err := postRenderManifests(input, "default", map[string]string{"team": "b", "env": "prod"}, nil, nil)
+	assert.NoError(t, err)
+	docs := manifestSeparator.Split(out, -1)
+	assert.Len(t, docs, 2)
+	for _, doc := range docs {
+		assert.Contains(t, doc, "env: prod")
+		assert.Contains(t, doc, "team: b")
+		assert.NotContains(t, doc, "team: a")
+	}
+	assert.Contains(t, docs[1], "app: podinfo")
+}
+
+func TestPostRenderManifestsAnnotations(t *testing.T) {
+	out, err := postRenderManifests(postRenderInput, "default", nil, map[string]string{fluxk8s.AntecedentAnnotation: "default:helmrelease/podinfo"}, nil)
+	assert.NoError(t, err)
+	for _, doc := range manifestSeparator.Split(out, -1) {
+		assert.Contains(t, doc, fluxk8s.AntecedentAnnotation+": default:helmrelease/podinfo")
+	}
+}
+
+func TestPostRenderChart(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(chartPath)
+
+	files := map[string]string{
+		"Chart.yaml":                "name: podinfo\nversion: 3.1.0\n",
+		"values.yaml":               "replicas: 1\n",
+		"templates/NOTES.txt":       "Installed {{ .Release.Name }} on {{ .Capabilities.KubeVersion.GitVersion }}\n",
+		"templates/hpa.yaml":        "{{- if .Capabilities.APIVersions.Has \"autoscaling/v2beta2\" }}\napiVersion: autoscaling/v2beta2\nkind: HorizontalPodAutoscaler\nmetadata:\n  name: podinfo\n{{- end }}\n",
+		"templates/_helpers.tpl":    "{{- define \"name\" }}{{ .Release.Name }}{{ end }}\n",
+		"templates/deployment.yaml": postRenderDeployment,
+	}
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(chartPath, name)), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(chartPath, name), []byte(content), 0644))
+	}
+
+	renderers := []helmfluxv1.PostRenderer{{
+		Kustomize: &helmfluxv1.KustomizePostRenderer{
+			Patches: []helmfluxv1.KustomizePatch{{
+				Patch:  `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+				Target: &helmfluxv1.PatchTarget{Kind: "Deployment"},
+			}},
+		},
+	}}
+	// The chart is rendered with the capabilities of the cluster
+	disc := &fakediscovery.FakeDiscovery{
+		Fake: &kubetesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "apps/v1"},
+			{GroupVersion: "autoscaling/v2beta2"},
+		}},
+		FakedServerVersion: &version.Info{Major: "1", Minor: "15", GitVersion: "v1.15.3"},
+	}
+	caps, err := capabilities(disc)
+	if !assert.NoError(t, err) {
+		return
+	}
+	c, err := postRenderChart(chartPath, "podinfo", "default", []byte("replicas: 2\n"), caps, nil, nil, renderers)
+	assert.NoError(t, err)
+	assert.Equal(t, "podinfo", c.Metadata.Name)
+
+	out, err := renderutil.Render(c, &hapi_chart.Config{}, renderutil.Options{
+		ReleaseOptions: chartutil.ReleaseOptions{Name: "podinfo", Namespace: "default"},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, out["podinfo/templates/NOTES.txt"], "Installed podinfo on v1.15.3")
+	var manifests string
+	for name, content := range out {
+		if filepath.Base(name) != "NOTES.txt" {
+			manifests += content
+		}
+	}
+	assert.Contains(t, manifests, "replicas: 3")
+	assert.Contains(t, manifests, "kind: HorizontalPodAutoscaler")
+
+	// Minor versions with a suffix are taken from the git version
+	disc.FakedServerVersion = &version.Info{Major: "1", Minor: "15+", GitVersion: "v1.15.3-eks-b8860f"}
+	caps, err = capabilities(disc)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "15", caps.KubeVersion.Minor)
+		assert.Equal(t, "v1.15.3-eks-b8860f", caps.KubeVersion.GitVersion)
+	}
+
+	// The capabilities are discovered once for all releases
+	r := New(log.NewNopLogger(), nil, disc, helmfluxv1.ReleaseNameStrategyDefault)
+	disc.ClearActions()
+	caps, err = r.capabilities()
+	assert.NoError(t, err)
+	discovered := len(disc.Actions())
+	assert.NotZero(t, discovered)
+	cached, err := r.capabilities()
+	assert.NoError(t, err)
+	assert.True(t, caps == cached)
+	assert.Len(t, disc.Actions(), discovered)
+
+	// Without a discovery client, Helm's defaults are used
+	caps, err = capabilities(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, chartutil.DefaultKubeVersion, caps.KubeVersion)
+	assert.False(t, caps.APIVersions.Has("autoscaling/v2beta2"))
+}
@@ -6,27 +6,35 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/go-kit/kit/log"
 	"github.com/spf13/pflag"
+	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes"
 	k8sclientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/helm/pkg/chartutil"
 	"k8s.io/helm/pkg/getter"
 	k8shelm "k8s.io/helm/pkg/helm"
 	helmenv "k8s.io/helm/pkg/helm/environment"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
 	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+	hapi_services "k8s.io/helm/pkg/proto/hapi/services"
 	helmutil "k8s.io/helm/pkg/releaseutil"
+	"k8s.io/helm/pkg/strvals"
 
 	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
 	fluxk8s "github.com/fluxcd/flux/pkg/cluster/kubernetes"
@@ -39,10 +47,31 @@ const (
 	UpgradeAction Action = "UPDATE"
 )
 
+// DefaultDryRunReleasePrefix is the default prefix of the release
+// names used for dry runs.
+const DefaultDryRunReleasePrefix = "helm-operator-dryrun-"
+
+// maxReleaseNameLength is the maximum length of a release name as
+// accepted by Tiller.
+const maxReleaseNameLength = 53
+
 // Release contains clients needed to provide functionality related to helm releases
 type Release struct {
-	logger     log.Logger
-	HelmClient *k8shelm.Client
+	logger       log.Logger
+	HelmClient   k8shelm.Interface
+	discovery    discovery.DiscoveryInterface
+	nameStrategy helmfluxv1.ReleaseNameStrategy
+
+	// caps are the capabilities of the cluster releases are
+	// rendered with, as last discovered
+	capsMu      sync.Mutex
+	caps        *chartutil.Capabilities
+	capsUpdated time.Time
+
+	// abandoned are the names of the releases of which a dry run was
+	// given up on by InstallContext, but is still in flight
+	abandonedMu sync.Mutex
+	abandoned   map[string]bool
 }
 
 type Releaser interface {
@@ -57,24 +86,111 @@ type DeployInfo struct {
 type InstallOptions struct {
 	DryRun    bool
 	ReuseName bool
+	// Force replaces resources that can not be updated on upgrade,
+	// it is never applied to dry runs.
+	Force bool
+	// SkipCRDs disables the `crd-install` hooks of the chart.
+	SkipCRDs bool
+	// DisableHooks disables the hooks of the chart on install and
+	// upgrade. Tiller never runs the hooks for dry runs, so their
+	// outcome is the same either way.
+	DisableHooks bool
+	// DisableUpgradeHooks disables the hooks of the chart on upgrade
+	// only.
+	DisableUpgradeHooks bool
+	// PostRenderers are applied to the rendered manifests before
+	// they are handed to Tiller.
+	PostRenderers []helmfluxv1.PostRenderer
+	// CommonLabels are added to all resources of the release.
+	CommonLabels map[string]string
+	// TrackResources adds the antecedent annotation naming the
+	// HelmRelease to the resources of the release as rendered, so
+	// that Tiller applies it along with them.
+	TrackResources bool
+	// ReuseValues merges the values onto the values of the current
+	// release on upgrade, rather than replacing them.
+	ReuseValues bool
+	// ResetValues resets the values to the values of the chart on
+	// upgrade; it takes precedence over ReuseValues.
+	ResetValues bool
+	// CleanupOnFail deletes the resources newly created by an
+	// upgrade when it fails, it is never applied to dry runs.
+	CleanupOnFail bool
+	// RecreatePods restarts the pods of the release on upgrade by
+	// recreating them, it is never applied to dry runs.
+	RecreatePods bool
+	// SkipSchemaValidation skips the validation of the values
+	// against the JSON schema of the chart.
+	SkipSchemaValidation bool
+	// Timeout is the install or upgrade timeout, if zero the timeout
+	// of the HelmRelease is used.
+	Timeout time.Duration
+	// EstablishCRDs creates the CRDs in the crds/ directories of the
+	// chart and its subcharts that do not exist yet before the
+	// release, and waits for them to become established; the chart
+	// itself is released as it is. Dry runs leave them be.
+	EstablishCRDs bool
+	// Namespace is the namespace to install into instead of the
+	// target namespace of the HelmRelease; it is only allowed for
+	// dry runs.
+	Namespace string
+	// ClientSide makes a dry run install without Tiller, see
+	// clientSideRelease.
+	ClientSide bool
 }
 
-// New creates a new Release instance.
-func New(logger log.Logger, helmClient *k8shelm.Client) *Release {
+// New creates a new Release instance, which names the releases of
+// HelmReleases following the given strategy, and renders charts
+// client side with the capabilities of the cluster the given
+// discovery client talks to.
+func New(logger log.Logger, helmClient k8shelm.Interface, discoveryClient discovery.DiscoveryInterface, nameStrategy helmfluxv1.ReleaseNameStrategy) *Release {
 	r := &Release{
-		logger:     logger,
-		HelmClient: helmClient,
+		logger:       logger,
+		HelmClient:   helmClient,
+		discovery:    discoveryClient,
+		nameStrategy: nameStrategy,
 	}
 	return r
 }
 
+// HelmVersion returns the version of Helm (Tiller) that performs
+// the releases.
+func (r *Release) HelmVersion() (string, error) {
+	res, err := r.HelmClient.GetVersion()
+	if err != nil {
+		return "", err
+	}
+	return res.GetVersion().GetSemVer(), nil
+}
+
+// ReleaseName returns the name of the release of the given
+// HelmRelease.
+func (r *Release) ReleaseName(hr helmfluxv1.HelmRelease) string {
+	return hr.ReleaseNameWith(r.nameStrategy)
+}
+
+// DryRunReleaseName returns the name of the release used to perform
+// dry runs for the given HelmRelease. It is made from the given
+// prefix and the UID of the HelmRelease, so that dry runs of
+// HelmReleases with the same release name do not collide, and is
+// shortened to fit the maximum length of a release name while
+// keeping the UID intact.
+func DryRunReleaseName(prefix string, hr helmfluxv1.HelmRelease) string {
+	uid := strings.Replace(string(hr.UID), "-", "", -1)
+	if max := maxReleaseNameLength - len(uid); len(prefix) > max {
+		prefix = prefix[:max]
+	}
+	return prefix + uid
+}
+
 // GetUpgradableRelease returns a release if the current state of it
 // allows an upgrade, a descriptive error if it is not allowed, or
-// nil if the release does not exist.
+// nil if the release does not exist or has been deleted (without
+// being purged), in which case it should be installed.
 func (r *Release) GetUpgradableRelease(name string) (*hapi_release.Release, error) {
 	rls, err := r.HelmClient.ReleaseContent(name)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+	if err = releaseError(err, name); err != nil {
+		if errors.IsNotFound(err) {
 			return nil, nil
 		}
 		return nil, err
@@ -88,15 +204,81 @@ func (r *Release) GetUpgradableRelease(name string) (*hapi_release.Release, erro
 		return release, nil
 	case hapi_release.Status_FAILED:
 		return nil, fmt.Errorf("release requires a rollback before it can be upgraded (%s)", status.GetCode().String())
+	case hapi_release.Status_DELETED:
+		return nil, nil
 	case hapi_release.Status_PENDING_INSTALL,
 		hapi_release.Status_PENDING_UPGRADE,
 		hapi_release.Status_PENDING_ROLLBACK:
-		return nil, fmt.Errorf("operation pending for release (%s)", status.GetCode().String())
+		return nil, &PendingError{release}
 	default:
 		return nil, fmt.Errorf("current state prevents it from being upgraded (%s)", status.GetCode().String())
 	}
 }
 
+// PendingError is returned by GetUpgradableRelease for a release of
+// which an install, upgrade or rollback is pending.
+type PendingError struct {
+	// Release is the pending release
+	Release *hapi_release.Release
+}
+
+func (e *PendingError) Error() string {
+	return fmt.Sprintf("operation pending for release (%s)", e.Release.GetInfo().GetStatus().GetCode().String())
+}
+
+// maxRecoverHistory is the number of revisions of a pending release
+// that are searched for its last deployed revision.
+const maxRecoverHistory = 256
+
+// RecoverPending recovers the given release, of which an install or
+// upgrade is pending but will not complete (e.g. because the
+// operator was stopped while performing it), so that it can be
+// released again. If rollback is true, it rolls the release back to
+// its last deployed revision, and returns an error if it has none;
+// it never deletes the release then. If rollback is false, the
+// release is deleted (and purged). It returns a description of what
+// it did.
+func (r *Release) RecoverPending(name string, hr helmfluxv1.HelmRelease, rollback bool) (string, error) {
+	if rollback {
+		history, err := r.HelmClient.ReleaseHistory(name, k8shelm.WithMaxHistory(maxRecoverHistory))
+		if err != nil {
+			return "", err
+		}
+		var deployed int32
+		for _, rel := range history.GetReleases() {
+			if rel.GetInfo().GetStatus().GetCode() == hapi_release.Status_DEPLOYED && rel.GetVersion() > deployed {
+				deployed = rel.GetVersion()
+			}
+		}
+		if deployed > 0 {
+			r.logger.Log("info", "rolling back pending release", "release", name, "version", deployed)
+			if _, err := r.rollback(name, hr, deployed); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("rolled back to revision %d", deployed), nil
+		}
+		return "", fmt.Errorf("release has no deployed revision to roll back to, it has to be recovered by hand")
+	}
+	r.logger.Log("info", "deleting pending release", "release", name)
+	if _, err := r.HelmClient.DeleteRelease(name, k8shelm.DeletePurge(true)); err != nil {
+		return "", err
+	}
+	return "deleted", nil
+}
+
+// GetRelease returns the release with the given name, or nil if it
+// does not exist.
+func (r *Release) GetRelease(name string) (*hapi_release.Release, error) {
+	rls, err := r.HelmClient.ReleaseContent(name)
+	if err = releaseError(err, name); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rls.GetRelease(), nil
+}
+
 // shouldRollback determines if a release should be rolled back
 // based on the status of the Helm release.
 func (r *Release) shouldRollback(name string) (bool, error) {
@@ -120,8 +302,11 @@ func (r *Release) shouldRollback(name string) (bool, error) {
 
 func (r *Release) canDelete(name string) (bool, error) {
 	rls, err := r.HelmClient.ReleaseStatus(name)
-
-	if err != nil {
+	if err = releaseError(err, name); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Log("info", fmt.Sprintf("Release %s does not exist", name))
+			return false, nil
+		}
 		return false, err
 	}
 	/*
@@ -157,7 +342,7 @@ func (r *Release) canDelete(name string) (bool, error) {
 // either split this procedure into two varieties, or make it more
 // general and calculate the path to the chart in the caller.
 func (r *Release) Install(chartPath, releaseName string, hr helmfluxv1.HelmRelease, action Action, opts InstallOptions,
-	kubeClient *kubernetes.Clientset) (release *hapi_release.Release, checksum string, err error) {
+	kubeClient kubernetes.Interface) (release *hapi_release.Release, checksum string, err error) {
 
 	defer func(start time.Time) {
 		ObserveRelease(
@@ -166,13 +351,25 @@ func (r *Release) Install(chartPath, releaseName string, hr helmfluxv1.HelmRelea
 			opts.DryRun,
 			err == nil,
 			hr.Namespace,
-			hr.ReleaseName(),
+			r.ReleaseName(hr),
 		)
 	}(time.Now())
 
+	// Failures before the release is handed to Tiller leave the
+	// cluster untouched.
+	applying := false
+	defer func() {
+		if err != nil && !applying {
+			err = &preApplyError{err}
+		}
+	}()
+
 	if chartPath == "" {
 		return nil, "", fmt.Errorf("empty path to chart supplied for resource %q", hr.ResourceID().String())
 	}
+	if opts.Namespace != "" && !opts.DryRun {
+		return nil, "", fmt.Errorf("install of release %s into namespace %s instead of its target namespace is not a dry run", releaseName, opts.Namespace)
+	}
 	_, err = os.Stat(chartPath)
 	switch {
 	case os.IsNotExist(err):
@@ -181,12 +378,22 @@ func (r *Release) Install(chartPath, releaseName string, hr helmfluxv1.HelmRelea
 		return nil, "", fmt.Errorf("error statting path given for chart %s: %s", chartPath, err.Error())
 	}
 
-	r.logger.Log("info", fmt.Sprintf("processing release %s (as %s)", hr.ReleaseName(), releaseName),
+	r.logger.Log("info", fmt.Sprintf("processing release %s (as %s)", r.ReleaseName(hr), releaseName),
 		"action", fmt.Sprintf("%v", action),
 		"options", fmt.Sprintf("%+v", opts),
-		"timeout", fmt.Sprintf("%vs", hr.GetTimeout()))
+		"timeout", fmt.Sprintf("%vs", timeout(hr, opts)))
 
-	vals, err := Values(kubeClient.CoreV1(), hr.Namespace, chartPath, hr.GetValuesFromSources(), hr.Spec.Values)
+	specVals, err := r.SpecValues(kubeClient.CoreV1(), hr)
+	if err != nil {
+		r.logger.Log("error", fmt.Sprintf("Failed to resolve the values template for Chart release [%s]: %v", hr.Spec.ReleaseName, err))
+		return nil, "", err
+	}
+	valuesFrom, err := hr.GetValuesFromSources()
+	if err != nil {
+		r.logger.Log("error", fmt.Sprintf("Failed to resolve the values files for Chart release [%s]: %v", hr.Spec.ReleaseName, err))
+		return nil, "", err
+	}
+	vals, sensitive, err := ComposeValues(kubeClient.CoreV1(), hr.Namespace, chartPath, valuesFrom, specVals, hr.Spec.ValuesOverrides)
 	if err != nil {
 		r.logger.Log("error", fmt.Sprintf("Failed to compose values for Chart release [%s]: %v", hr.Spec.ReleaseName, err))
 		return nil, "", err
@@ -198,22 +405,102 @@ func (r *Release) Install(chartPath, releaseName string, hr helmfluxv1.HelmRelea
 		return nil, "", err
 	}
 	rawVals := []byte(strVals)
-	checksum = ValuesChecksum(rawVals)
+	checksum, err = sensitive.Checksum(vals)
+	if err != nil {
+		r.logger.Log("error", fmt.Sprintf("Problem with supplied customizations for Chart release [%s]: %v", hr.Spec.ReleaseName, err))
+		return nil, "", err
+	}
+
+	// Dry runs determine the outcome of an upgrade, so they take the
+	// values of the current release into account as the upgrade will.
+	if action == UpgradeAction || opts.DryRun {
+		upgradeVals, err := r.upgradeValues(r.ReleaseName(hr), vals, opts)
+		if err != nil {
+			r.logger.Log("error", fmt.Sprintf("Failed to compose values with the current values for Chart release [%s]: %v", hr.Spec.ReleaseName, err))
+			return nil, checksum, err
+		}
+		strVals, err = upgradeVals.YAML()
+		if err != nil {
+			return nil, checksum, err
+		}
+		rawVals = []byte(strVals)
+	}
+
+	if !opts.SkipSchemaValidation {
+		if err = validateValues(chartPath, rawVals); err != nil {
+			r.logger.Log("error", fmt.Sprintf("Invalid values for Chart release [%s]: %v", hr.Spec.ReleaseName, err))
+			return nil, checksum, err
+		}
+	}
+
+	var postRendered *hapi_chart.Chart
+	var caps *chartutil.Capabilities
+	if len(opts.PostRenderers) > 0 || len(opts.CommonLabels) > 0 || opts.TrackResources {
+		if caps, err = r.capabilities(); err != nil {
+			r.logger.Log("error", fmt.Sprintf("Failed to get the capabilities of the cluster for Chart release [%s]: %v", hr.Spec.ReleaseName, err))
+			return nil, checksum, err
+		}
+	}
+	if len(opts.PostRenderers) > 0 || len(opts.CommonLabels) > 0 || opts.TrackResources {
+		var annotations map[string]string
+		if opts.TrackResources {
+			annotations = map[string]string{fluxk8s.AntecedentAnnotation: hr.ResourceID().String()}
+		}
+		postRendered, err = postRenderChart(chartPath, r.ReleaseName(hr), hr.GetTargetNamespace(), rawVals, caps, opts.CommonLabels, annotations, opts.PostRenderers)
+		if err != nil {
+			r.logger.Log("error", fmt.Sprintf("Failed to post-render Chart release [%s]: %v", hr.Spec.ReleaseName, err))
+			return nil, checksum, err
+		}
+	}
+
+	if opts.EstablishCRDs && !opts.DryRun {
+		crds, err := chartCRDs(chartPath)
+		if err != nil {
+			return nil, checksum, fmt.Errorf("failed to read the CRDs of the chart: %s", err)
+		}
+		if len(crds) > 0 {
+			r.logger.Log("info", fmt.Sprintf("establishing %d CRDs before release [%s]", len(crds), hr.Spec.ReleaseName))
+			if err = establishCRDs(crds, time.Duration(timeout(hr, opts))*time.Second); err != nil {
+				return nil, checksum, &crdsNotEstablishedError{err}
+			}
+		}
+	}
+
+	namespace := hr.GetTargetNamespace()
+	if opts.Namespace != "" {
+		namespace = opts.Namespace
+	}
+	if opts.DryRun && opts.ClientSide && action == InstallAction {
+		rel, err := clientSideRelease(chartPath, postRendered, releaseName, namespace, rawVals)
+		return rel, checksum, err
+	}
 
+	applying = true
 	switch action {
 	case InstallAction:
-		res, err := r.HelmClient.InstallRelease(
-			chartPath,
-			hr.GetTargetNamespace(),
+		installOpts := []k8shelm.InstallOption{
 			k8shelm.ValueOverrides(rawVals),
 			k8shelm.ReleaseName(releaseName),
 			k8shelm.InstallDryRun(opts.DryRun),
 			k8shelm.InstallReuseName(opts.ReuseName),
-			k8shelm.InstallTimeout(hr.GetTimeout()),
-		)
+			k8shelm.InstallTimeout(timeout(hr, opts)),
+			k8shelm.InstallDisableCRDHook(opts.SkipCRDs),
+			k8shelm.InstallDisableHooks(opts.DisableHooks),
+		}
+		var res *hapi_services.InstallReleaseResponse
+		if postRendered != nil {
+			res, err = r.HelmClient.InstallReleaseFromChart(postRendered, namespace, installOpts...)
+		} else {
+			res, err = r.HelmClient.InstallRelease(chartPath, namespace, installOpts...)
+		}
+		err = tillerError(err)
 
 		if err != nil {
 			r.logger.Log("error", fmt.Sprintf("Chart release failed: %s: %#v", hr.Spec.ReleaseName, err))
+			if opts.DryRun {
+				r.purgeDryRun(releaseName)
+				return nil, checksum, err
+			}
 			// purge the release if the install failed but only if this is the first revision
 			history, err := r.HelmClient.ReleaseHistory(releaseName, k8shelm.WithMaxHistory(2))
 			if err == nil && len(history.Releases) == 1 && history.Releases[0].Info.Status.Code == hapi_release.Status_FAILED {
@@ -231,16 +518,24 @@ func (r *Release) Install(chartPath, releaseName string, hr helmfluxv1.HelmRelea
 		}
 		return res.Release, checksum, err
 	case UpgradeAction:
-		res, err := r.HelmClient.UpdateRelease(
-			releaseName,
-			chartPath,
+		updateOpts := []k8shelm.UpdateOption{
 			k8shelm.UpdateValueOverrides(rawVals),
 			k8shelm.UpgradeDryRun(opts.DryRun),
-			k8shelm.UpgradeTimeout(hr.GetTimeout()),
-			k8shelm.ResetValues(hr.Spec.ResetValues),
-			k8shelm.UpgradeForce(hr.Spec.ForceUpgrade),
-			k8shelm.UpgradeWait(hr.Spec.Rollback.Enable),
-		)
+			k8shelm.UpgradeTimeout(timeout(hr, opts)),
+			k8shelm.ResetValues(opts.ResetValues),
+			k8shelm.UpgradeForce(opts.Force && !opts.DryRun),
+			k8shelm.UpgradeCleanupOnFail(opts.CleanupOnFail && !opts.DryRun),
+			k8shelm.UpgradeRecreate(opts.RecreatePods && !opts.DryRun),
+			k8shelm.UpgradeWait(hr.Spec.Rollback.Enabled()),
+			k8shelm.UpgradeDisableHooks(opts.DisableHooks || opts.DisableUpgradeHooks),
+		}
+		var res *hapi_services.UpdateReleaseResponse
+		if postRendered != nil {
+			res, err = r.HelmClient.UpdateReleaseFromChart(releaseName, postRendered, updateOpts...)
+		} else {
+			res, err = r.HelmClient.UpdateRelease(releaseName, chartPath, updateOpts...)
+		}
+		err = tillerError(err)
 
 		if err != nil {
 			r.logger.Log("error", fmt.Sprintf("Chart upgrade release failed: %s: %#v", hr.Spec.ReleaseName, err))
@@ -257,6 +552,141 @@ func (r *Release) Install(chartPath, releaseName string, hr helmfluxv1.HelmRelea
 	}
 }
 
+// InstallContext is Install, but gives up on waiting for the install
+// or upgrade once the given context is done, and returns the error of
+// the context. Helm's client does not take a context, so the call to
+// Tiller is not aborted itself: it is left to finish in the
+// background, and its outcome is discarded. This makes it only fit
+// for dry runs, which leave the release unchanged either way. Until
+// an abandoned dry run finished, no other dry run under the same
+// release name is started, so that those that keep stalling do not
+// pile up.
+func (r *Release) InstallContext(ctx context.Context, chartPath, releaseName string, hr helmfluxv1.HelmRelease, action Action,
+	opts InstallOptions, kubeClient kubernetes.Interface) (*hapi_release.Release, string, error) {
+
+	if !opts.DryRun {
+		return nil, "", fmt.Errorf("install of release %s with a context is not a dry run", releaseName)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	r.abandonedMu.Lock()
+	inFlight := r.abandoned[releaseName]
+	r.abandonedMu.Unlock()
+	if inFlight {
+		return nil, "", fmt.Errorf("the dry run of release %s that was given up on earlier is still in flight", releaseName)
+	}
+
+	type result struct {
+		release  *hapi_release.Release
+		checksum string
+		err      error
+	}
+	// NB: buffered, so an abandoned install does not block on it
+	done := make(chan result, 1)
+	go func() {
+		rel, checksum, err := r.Install(chartPath, releaseName, hr, action, opts, kubeClient)
+		done <- result{rel, checksum, err}
+	}()
+	select {
+	case res := <-done:
+		return res.release, res.checksum, res.err
+	case <-ctx.Done():
+	}
+
+	r.logger.Log("warning", fmt.Sprintf("Gave up waiting for Chart release [%s]: %s", hr.Spec.ReleaseName, ctx.Err()))
+	r.abandonedMu.Lock()
+	if r.abandoned == nil {
+		r.abandoned = make(map[string]bool)
+	}
+	r.abandoned[releaseName] = true
+	r.abandonedMu.Unlock()
+	go func() {
+		<-done
+		r.abandonedMu.Lock()
+		delete(r.abandoned, releaseName)
+		r.abandonedMu.Unlock()
+	}()
+	return nil, "", ctx.Err()
+}
+
+// timeout returns the install or upgrade timeout in seconds.
+func timeout(hr helmfluxv1.HelmRelease, opts InstallOptions) int64 {
+	if opts.Timeout > 0 {
+		return int64(opts.Timeout / time.Second)
+	}
+	return hr.GetTimeout()
+}
+
+// IsTimeout returns if the error returned by an install or upgrade
+// is the result of the release (or one of its hooks) not becoming
+// ready within the timeout, or of a deadline being exceeded.
+func IsTimeout(err error) bool {
+	if e, ok := err.(*preApplyError); ok {
+		err = e.err
+	}
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	e, ok := err.(net.Error)
+	return ok && e.Timeout()
+}
+
+// preApplyError is the error of an install or upgrade that failed
+// before the release was handed to Tiller.
+type preApplyError struct {
+	err error
+}
+
+func (e *preApplyError) Error() string {
+	return e.err.Error()
+}
+
+// IsPreApply returns if the error returned by an install or upgrade
+// occurred before anything was applied to the cluster, e.g. while
+// composing or validating the values.
+func IsPreApply(err error) bool {
+	_, ok := err.(*preApplyError)
+	return ok
+}
+
+// upgradeValues returns the values to upgrade the release with, given
+// the composed values. It takes the values of the current release into
+// account the way Tiller does on upgrade: unless they are reset, the
+// values are merged onto them when reusing values, and they are kept
+// when no values are given.
+//
+// The merge is done here rather than by Tiller, so that the values
+// recorded for the release are the same as the values a dry run
+// results in, whether it is run against the release or not.
+func (r *Release) upgradeValues(releaseName string, vals chartutil.Values, opts InstallOptions) (chartutil.Values, error) {
+	if opts.ResetValues || (!opts.ReuseValues && len(vals) > 0) {
+		return vals, nil
+	}
+	rel, err := r.GetRelease(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	if rel == nil || rel.GetConfig().GetRaw() == "" {
+		return vals, nil
+	}
+	currVals, err := chartutil.ReadValues([]byte(rel.GetConfig().GetRaw()))
+	if err != nil {
+		return nil, err
+	}
+	return mergeValues(currVals, vals), nil
+}
+
+// purgeDryRun purges any release left behind under the name that was
+// used for a failed dry run. A dry run should not record anything,
+// but residue of an earlier run would otherwise make every following
+// dry run with the same name fail.
+func (r *Release) purgeDryRun(releaseName string) {
+	if _, err := r.HelmClient.DeleteRelease(releaseName, k8shelm.DeletePurge(true)); err == nil {
+		r.logger.Log("info", "purged release left behind by dry run", "release", releaseName)
+	}
+}
+
 // Rollback rolls back a Chart release if required
 func (r *Release) Rollback(releaseName string, hr helmfluxv1.HelmRelease) (*hapi_release.Release, error) {
 	ok, err := r.shouldRollback(releaseName)
@@ -266,17 +696,44 @@ func (r *Release) Rollback(releaseName string, hr helmfluxv1.HelmRelease) (*hapi
 		}
 		return nil, nil
 	}
+	return r.rollback(releaseName, hr, 0) // '0' makes Helm fetch the latest deployed release
+}
+
+// RollbackFailedTests rolls back a deployed Chart release whose tests
+// failed to the revision before it.
+func (r *Release) RollbackFailedTests(releaseName string, hr helmfluxv1.HelmRelease) (*hapi_release.Release, error) {
+	rls, err := r.HelmClient.ReleaseStatus(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	if code := rls.GetInfo().GetStatus().GetCode(); code != hapi_release.Status_DEPLOYED {
+		return nil, fmt.Errorf("release with status %s cannot be rolled back after failed tests", code.String())
+	}
+	res, err := r.HelmClient.ReleaseContent(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	version := res.GetRelease().GetVersion()
+	if version < 2 {
+		return nil, fmt.Errorf("release has no revision before %d to roll back to", version)
+	}
+	r.logger.Log("info", "rolling back release after failed tests", "release", releaseName, "version", version-1)
+	return r.rollback(releaseName, hr, version-1)
+}
 
+// rollback rolls back a Chart release to the given revision.
+func (r *Release) rollback(releaseName string, hr helmfluxv1.HelmRelease, version int32) (*hapi_release.Release, error) {
 	res, err := r.HelmClient.RollbackRelease(
 		releaseName,
-		k8shelm.RollbackVersion(0), // '0' makes Helm fetch the latest deployed release
+		k8shelm.RollbackVersion(version),
 		k8shelm.RollbackTimeout(hr.Spec.Rollback.GetTimeout()),
-		k8shelm.RollbackForce(hr.Spec.Rollback.Force),
-		k8shelm.RollbackRecreate(hr.Spec.Rollback.Recreate),
-		k8shelm.RollbackDisableHooks(hr.Spec.Rollback.DisableHooks),
-		k8shelm.RollbackWait(hr.Spec.Rollback.Wait),
+		k8shelm.RollbackForce(hr.Spec.Rollback.GetForce()),
+		k8shelm.RollbackRecreate(hr.Spec.Rollback.GetRecreate()),
+		k8shelm.RollbackDisableHooks(hr.Spec.Rollback.GetDisableHooks()),
+		k8shelm.RollbackWait(hr.Spec.Rollback.GetWait()),
 		k8shelm.RollbackDescription("Automated rollback by Helm operator"),
 	)
+	err = tillerError(err)
 	if err != nil {
 		r.logger.Log("error", fmt.Sprintf("failed to rollback release: %#v", err))
 		return nil, err
@@ -288,8 +745,33 @@ func (r *Release) Rollback(releaseName string, hr helmfluxv1.HelmRelease) (*hapi
 	return res.Release, err
 }
 
-// Delete purges a Chart release
-func (r *Release) Delete(name string) error {
+// Test runs the tests of a Chart release, and returns an error
+// listing the tests that failed, if any.
+func (r *Release) Test(releaseName string, hr helmfluxv1.HelmRelease) error {
+	results, errc := r.HelmClient.RunReleaseTest(
+		releaseName,
+		k8shelm.ReleaseTestTimeout(hr.Spec.Test.GetTimeout()),
+		k8shelm.ReleaseTestCleanup(true),
+	)
+	var failed []string
+	for res := range results {
+		r.logger.Log("info", res.GetMsg(), "release", releaseName)
+		if res.GetStatus() == hapi_release.TestRun_FAILURE {
+			failed = append(failed, res.GetMsg())
+		}
+	}
+	if err := <-errc; err != nil {
+		return tillerError(err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d test(s) failed: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Delete deletes a Chart release with the uninstall options of the
+// given HelmRelease, purging it unless its history is to be kept.
+func (r *Release) Delete(name string, hr helmfluxv1.HelmRelease) error {
 	ok, err := r.canDelete(name)
 	if !ok {
 		if err != nil {
@@ -298,7 +780,12 @@ func (r *Release) Delete(name string) error {
 		return nil
 	}
 
-	_, err = r.HelmClient.DeleteRelease(name, k8shelm.DeletePurge(true))
+	opts := hr.Spec.Uninstall
+	_, err = r.HelmClient.DeleteRelease(name,
+		k8shelm.DeletePurge(!opts.KeepHistory),
+		k8shelm.DeleteDisableHooks(opts.DisableHooks),
+		k8shelm.DeleteTimeout(opts.GetTimeout()),
+	)
 	if err != nil {
 		r.logger.Log("error", fmt.Sprintf("Release deletion error: %#v", err))
 		return err
@@ -347,9 +834,97 @@ func (r *Release) OwnedByHelmRelease(release *hapi_release.Release, hr helmfluxv
 	return true
 }
 
+// Adopt takes ownership of the given release for the given
+// HelmRelease, by annotating its resources with the HelmRelease, so
+// that OwnedByHelmRelease holds from then on. It returns an error if
+// not all of the resources could be annotated, in which case the
+// release does not belong to the HelmRelease yet.
+func (r *Release) Adopt(release *hapi_release.Release, hr helmfluxv1.HelmRelease) error {
+	return r.annotateResources(release, hr)
+}
+
+// UntrackedResources returns the resources of the given release, as
+// '<namespace>:<kind>/<name>', of which the antecedent annotation
+// does not name the given HelmRelease, i.e. that have been modified
+// or (re)created by other means than the operator, or do not exist.
+// Each resource is looked up within the given timeout. The resources
+// that could not be looked up are named in the returned error.
+func (r *Release) UntrackedResources(release *hapi_release.Release, hr helmfluxv1.HelmRelease, timeout time.Duration) ([]string, error) {
+	id := hr.ResourceID().String()
+
+	var untracked, failed []string
+	for _, obj := range releaseManifestToUnstructured(release.Manifest, log.NewNopLogger()) {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = release.Namespace
+		}
+		resource := namespace + ":" + obj.GetKind() + "/" + obj.GetName()
+
+		live, found, err := getLive(obj, namespace, timeout)
+		switch {
+		case err != nil:
+			failed = append(failed, resource)
+		case !found:
+			untracked = append(untracked, resource+" (missing)")
+		case live.GetAnnotations()[fluxk8s.AntecedentAnnotation] != id:
+			untracked = append(untracked, resource)
+		}
+	}
+	sort.Strings(untracked)
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return untracked, fmt.Errorf("failed to get %s", strings.Join(failed, ", "))
+	}
+	return untracked, nil
+}
+
+// LeftoverResources returns the resources of the failed revision of
+// a release that are not in the given previous revision of it, as
+// '<namespace>:<kind>/<name>', and still exist, i.e. the resources
+// created by a failed upgrade that were not cleaned up. Each resource
+// is looked up within the given timeout. The resources that could not
+// be looked up are named in the returned error.
+func (r *Release) LeftoverResources(prev, failed *hapi_release.Release, timeout time.Duration) ([]string, error) {
+	resource := func(obj unstructured.Unstructured, rel *hapi_release.Release) (string, string) {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = rel.Namespace
+		}
+		return namespace, namespace + ":" + obj.GetKind() + "/" + obj.GetName()
+	}
+	existed := map[string]bool{}
+	for _, obj := range releaseManifestToUnstructured(prev.GetManifest(), log.NewNopLogger()) {
+		_, res := resource(obj, prev)
+		existed[res] = true
+	}
+
+	var leftover, failedLookups []string
+	for _, obj := range releaseManifestToUnstructured(failed.GetManifest(), log.NewNopLogger()) {
+		namespace, res := resource(obj, failed)
+		if existed[res] {
+			continue
+		}
+		_, found, err := getLive(obj, namespace, timeout)
+		switch {
+		case err != nil:
+			failedLookups = append(failedLookups, res)
+		case found:
+			leftover = append(leftover, res)
+		}
+	}
+	sort.Strings(leftover)
+	if len(failedLookups) > 0 {
+		sort.Strings(failedLookups)
+		return leftover, fmt.Errorf("failed to get %s", strings.Join(failedLookups, ", "))
+	}
+	return leftover, nil
+}
+
 // annotateResources annotates each of the resources created (or updated)
-// by the release so that we can spot them.
-func (r *Release) annotateResources(release *hapi_release.Release, hr helmfluxv1.HelmRelease) {
+// by the release so that we can spot them. It returns an error naming
+// the namespaces of which the resources could not be annotated.
+func (r *Release) annotateResources(release *hapi_release.Release, hr helmfluxv1.HelmRelease) error {
+	var errs []string
 	objs := releaseManifestToUnstructured(release.Manifest, r.logger)
 	for namespace, res := range namespacedResourceMap(objs, release.Namespace) {
 		args := []string{"annotate", "--overwrite"}
@@ -366,17 +941,32 @@ func (r *Release) annotateResources(release *hapi_release.Release, hr helmfluxv1
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			r.logger.Log("output", string(output), "err", err)
+			errs = append(errs, fmt.Sprintf("namespace '%s': %s", namespace, err))
 		}
 	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to annotate resources of release '%s' in %s", release.Name, strings.Join(errs, "; "))
+	}
+	return nil
 }
 
 // Values tries to resolve all given value file sources and merges
-// them into one Values struct. It returns the merged Values.
-func Values(corev1 k8sclientv1.CoreV1Interface, ns string, chartPath string, valuesFromSource []helmfluxv1.ValuesFromSource, values chartutil.Values) (chartutil.Values, error) {
+// them into one Values struct, followed by the given values and the
+// overrides. It returns the merged Values.
+func Values(corev1 k8sclientv1.CoreV1Interface, ns string, chartPath string, valuesFromSource []helmfluxv1.ValuesFromSource, values chartutil.Values, overrides []helmfluxv1.ValuesOverride) (chartutil.Values, error) {
+	result, _, err := ComposeValues(corev1, ns, chartPath, valuesFromSource, values, overrides)
+	return result, err
+}
+
+// ComposeValues merges the values like Values does, and returns the
+// sensitive values among them as well.
+func ComposeValues(corev1 k8sclientv1.CoreV1Interface, ns string, chartPath string, valuesFromSource []helmfluxv1.ValuesFromSource, values chartutil.Values, overrides []helmfluxv1.ValuesOverride) (chartutil.Values, SensitiveValues, error) {
 	result := chartutil.Values{}
+	var sensitive SensitiveValues
 
 	for _, v := range valuesFromSource {
 		var valueFile chartutil.Values
+		var secret *v1.Secret
 
 		switch {
 		case v.ConfigMapKeyRef != nil:
@@ -392,20 +982,20 @@ func Values(corev1 k8sclientv1.CoreV1Interface, ns string, chartPath string, val
 				if errors.IsNotFound(err) && optional {
 					continue
 				}
-				return result, err
+				return result, sensitive, err
 			}
 			d, ok := configMap.Data[key]
 			if !ok {
 				if optional {
 					continue
 				}
-				return result, fmt.Errorf("could not find key %v in ConfigMap %s/%s", key, ns, name)
+				return result, sensitive, fmt.Errorf("could not find key %v in ConfigMap %s/%s", key, ns, name)
 			}
 			if err := yaml.Unmarshal([]byte(d), &valueFile); err != nil {
 				if optional {
 					continue
 				}
-				return result, fmt.Errorf("unable to yaml.Unmarshal %v from %s in ConfigMap %s/%s", d, key, ns, name)
+				return result, sensitive, fmt.Errorf("unable to yaml.Unmarshal %v from %s in ConfigMap %s/%s", d, key, ns, name)
 			}
 		case v.SecretKeyRef != nil:
 			s := v.SecretKeyRef
@@ -415,22 +1005,54 @@ func Values(corev1 k8sclientv1.CoreV1Interface, ns string, chartPath string, val
 				key = "values.yaml"
 			}
 			optional := s.Optional != nil && *s.Optional
-			secret, err := corev1.Secrets(ns).Get(name, metav1.GetOptions{})
+			var err error
+			secret, err = corev1.Secrets(ns).Get(name, metav1.GetOptions{})
 			if err != nil {
 				if errors.IsNotFound(err) && optional {
 					continue
 				}
-				return result, err
+				return result, sensitive, err
 			}
 			d, ok := secret.Data[key]
 			if !ok {
 				if optional {
 					continue
 				}
-				return result, fmt.Errorf("could not find key %s in Secret %s/%s", key, ns, name)
+				return result, sensitive, fmt.Errorf("could not find key %s in Secret %s/%s", key, ns, name)
 			}
+			// The data of the secret is left out of the error, as
+			// it ends up in the logs and the status.
 			if err := yaml.Unmarshal(d, &valueFile); err != nil {
-				return result, fmt.Errorf("unable to yaml.Unmarshal %v from %s in Secret %s/%s", d, key, ns, name)
+				return result, sensitive, fmt.Errorf("unable to yaml.Unmarshal %s in Secret %s/%s: %s", key, ns, name, err)
+			}
+		case v.ConfigMapKeysRef != nil:
+			sel := v.ConfigMapKeysRef
+			configMap, err := corev1.ConfigMaps(ns).Get(sel.Name, metav1.GetOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) && sel.Optional != nil && *sel.Optional {
+					continue
+				}
+				return result, sensitive, err
+			}
+			data := make(map[string][]byte, len(configMap.Data))
+			for k, d := range configMap.Data {
+				data[k] = []byte(d)
+			}
+			if valueFile, err = valuesFromKeys(data, sel.Keys, fmt.Sprintf("ConfigMap %s/%s", ns, sel.Name)); err != nil {
+				return result, sensitive, err
+			}
+		case v.SecretKeysRef != nil:
+			sel := v.SecretKeysRef
+			var err error
+			secret, err = corev1.Secrets(ns).Get(sel.Name, metav1.GetOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) && sel.Optional != nil && *sel.Optional {
+					continue
+				}
+				return result, sensitive, err
+			}
+			if valueFile, err = valuesFromKeys(secret.Data, sel.Keys, fmt.Sprintf("Secret %s/%s", ns, sel.Name)); err != nil {
+				return result, sensitive, err
 			}
 		case v.ExternalSourceRef != nil:
 			es := v.ExternalSourceRef
@@ -441,13 +1063,13 @@ func Values(corev1 k8sclientv1.CoreV1Interface, ns string, chartPath string, val
 				if optional {
 					continue
 				}
-				return result, fmt.Errorf("unable to read value file from URL %s", url)
+				return result, sensitive, fmt.Errorf("unable to read value file from URL %s", url)
 			}
 			if err := yaml.Unmarshal(b, &valueFile); err != nil {
 				if optional {
 					continue
 				}
-				return result, fmt.Errorf("unable to yaml.Unmarshal %v from URL %s", b, url)
+				return result, sensitive, fmt.Errorf("unable to yaml.Unmarshal %v from URL %s", b, url)
 			}
 		case v.ChartFileRef != nil:
 			cf := v.ChartFileRef
@@ -458,21 +1080,75 @@ func Values(corev1 k8sclientv1.CoreV1Interface, ns string, chartPath string, val
 				if optional {
 					continue
 				}
-				return result, fmt.Errorf("unable to read value file from path %s", filePath)
+				return result, sensitive, fmt.Errorf("unable to read value file from path %s", filePath)
 			}
 			if err := yaml.Unmarshal(f, &valueFile); err != nil {
 				if optional {
 					continue
 				}
-				return result, fmt.Errorf("unable to yaml.Unmarshal %v from URL %s", f, filePath)
+				return result, sensitive, fmt.Errorf("unable to yaml.Unmarshal %v from URL %s", f, filePath)
+			}
+		}
+
+		if v.SourcePath != "" || v.TargetPath != "" {
+			fragment, err := valuesFromPaths(valueFile, v.SourcePath, v.TargetPath)
+			if err != nil {
+				return result, sensitive, &ValuesPathError{Source: describeValuesFromSource(ns, v), Reason: err.Error()}
 			}
+			valueFile = fragment
 		}
 
+		if secret != nil && sensitiveSource(v) {
+			sensitive.add(secret, valueFile)
+		}
 		result = mergeValues(result, valueFile)
 	}
 
 	result = mergeValues(result, values)
 
+	for _, o := range overrides {
+		override := map[string]interface{}{}
+		var err error
+		switch {
+		case o.Set != "":
+			err = strvals.ParseInto(o.Set, override)
+		case o.SetString != "":
+			err = strvals.ParseIntoString(o.SetString, override)
+		}
+		if err != nil {
+			return result, sensitive, fmt.Errorf("invalid values override: %s", err)
+		}
+		result = mergeValues(result, override)
+	}
+
+	return result, sensitive, nil
+}
+
+// valuesFromKeys merges the values in the given keys of the data of
+// the described object, in the order of the keys or, without keys, of
+// all its keys in lexical order, so that the outcome is stable.
+func valuesFromKeys(data map[string][]byte, keys []helmfluxv1.KeySelector, object string) (chartutil.Values, error) {
+	if len(keys) == 0 {
+		for k := range data {
+			keys = append(keys, helmfluxv1.KeySelector{Key: k})
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	}
+	result := chartutil.Values{}
+	for _, k := range keys {
+		d, ok := data[k.Key]
+		if !ok {
+			if k.Optional != nil && *k.Optional {
+				continue
+			}
+			return result, fmt.Errorf("could not find key %s in %s", k.Key, object)
+		}
+		var vals chartutil.Values
+		if err := yaml.Unmarshal(d, &vals); err != nil {
+			return result, fmt.Errorf("unable to yaml.Unmarshal %s in %s: %s", k.Key, object, err)
+		}
+		result = mergeValues(result, vals)
+	}
 	return result, nil
 }
 
@@ -547,6 +1223,13 @@ func readLocalChartFile(filePath string) ([]byte, error) {
 	return f, nil
 }
 
+// ManifestToUnstructured turns a string containing the YAML
+// manifests of a release into an array of Unstructured objects,
+// skipping the manifests that can not be parsed.
+func ManifestToUnstructured(manifest string) []unstructured.Unstructured {
+	return releaseManifestToUnstructured(manifest, log.NewNopLogger())
+}
+
 // releaseManifestToUnstructured turns a string containing YAML
 // manifests into an array of Unstructured objects.
 func releaseManifestToUnstructured(manifest string, logger log.Logger) []unstructured.Unstructured {
@@ -1,13 +1,27 @@
 package release
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/helm/pkg/chartutil"
+	k8shelm "k8s.io/helm/pkg/helm"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+	rls "k8s.io/helm/pkg/proto/hapi/services"
 
 	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
 )
@@ -69,10 +83,632 @@ valuesDict:
 			ChartFileRef:      nil,
 		}}
 
-	values, err := Values(client.CoreV1(), "flux", "", valuesFromSource, chartValues)
+	values, err := Values(client.CoreV1(), "flux", "", valuesFromSource, chartValues, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "1.1.1", values["image"].(map[string]interface{})["tag"])
 	assert.NotNil(t, values["valuesDict"].(map[string]interface{})["chart"])
 	assert.NotNil(t, values["valuesDict"].(map[string]interface{})["configmap"])
 	assert.NotNil(t, values["valuesDict"].(map[string]interface{})["secret"])
 }
+
+func TestValues_overrides(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	values, _ := chartutil.ReadValues([]byte(`image:
+  repository: stefanprodan/podinfo
+  tag: 1.0.0
+replicas: 1
+`))
+	overrides := []helmfluxv1.ValuesOverride{
+		{Set: "image.tag=1.2.3"},
+		{Set: "replicas=2"},
+		{Set: "hosts={a.example.com,b.example.com}"},
+		{SetString: "build=1234"},
+	}
+
+	values, err := Values(client.CoreV1(), "flux", "", nil, values, overrides)
+	assert.NoError(t, err)
+	image := values["image"].(map[string]interface{})
+	assert.Equal(t, "stefanprodan/podinfo", image["repository"])
+	assert.Equal(t, "1.2.3", image["tag"])
+	assert.Equal(t, int64(2), values["replicas"])
+	assert.Equal(t, []interface{}{"a.example.com", "b.example.com"}, values["hosts"])
+	assert.Equal(t, "1234", values["build"])
+
+	_, err = Values(client.CoreV1(), "flux", "", nil, values, []helmfluxv1.ValuesOverride{{Set: "image.tag"}})
+	assert.Error(t, err)
+}
+
+func TestValues_paths(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared-config", Namespace: "flux"},
+		Data: map[string]string{"values.yaml": `environments:
+  prod:
+    database:
+      host: db.example.com
+      port: 5432
+`},
+	})
+	source := func(sourcePath, targetPath string) []helmfluxv1.ValuesFromSource {
+		return []helmfluxv1.ValuesFromSource{{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "shared-config"}},
+			SourcePath:      sourcePath,
+			TargetPath:      targetPath,
+		}}
+	}
+
+	values, err := Values(client.CoreV1(), "flux", "", source("environments.prod.database", "postgresql.external"), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, chartutil.Values{"postgresql": map[string]interface{}{"external": map[string]interface{}{
+		"host": "db.example.com", "port": float64(5432)}}}, values)
+
+	values, err = Values(client.CoreV1(), "flux", "", source("environments.prod.database", ""), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "db.example.com", values["host"])
+
+	values, err = Values(client.CoreV1(), "flux", "", source("environments.prod.database.host", "db"), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "db.example.com", values["db"])
+
+	for _, paths := range [][2]string{
+		{"environments.staging", ""},
+		{"environments.prod.database.host.name", "db"},
+		{"environments.prod.database.host", ""},
+	} {
+		_, err = Values(client.CoreV1(), "flux", "", source(paths[0], paths[1]), nil, nil)
+		assert.True(t, IsValuesPath(err), "%v", paths)
+	}
+}
+
+func TestWithoutValuesPaths(t *testing.T) {
+	vals := chartutil.Values{
+		"image":  map[string]interface{}{"tag": "1.0.0", "pullPolicy": "Always"},
+		"secret": "r4nd0m",
+		"tls":    "yes",
+	}
+	assert.Equal(t, chartutil.Values{
+		"image": map[string]interface{}{"pullPolicy": "Always"},
+		"tls":   "yes",
+	}, WithoutValuesPaths(vals, []string{"image.tag", "secret", "missing.path", "tls.crt"}))
+	// The given values are left alone
+	assert.Equal(t, "1.0.0", vals["image"].(map[string]interface{})["tag"])
+	assert.Equal(t, "r4nd0m", vals["secret"])
+}
+
+func TestValues_keys(t *testing.T) {
+	trueVal := true
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-config", Namespace: "flux"},
+		Data: map[string]string{
+			"base.yaml":    "replicas: 1\nimage:\n  tag: 1.0.0\n",
+			"prod.yaml":    "replicas: 3\n",
+			"ingress.yaml": "ingress:\n  enabled: true\n",
+		},
+	}, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-secrets", Namespace: "flux"},
+		Data:       map[string][]byte{"db.yaml": []byte("db:\n  password: s3cr3t\n")},
+	})
+
+	// Keys are merged in the given order, missing optional keys and
+	// objects are skipped
+	values, err := Values(client.CoreV1(), "flux", "", []helmfluxv1.ValuesFromSource{
+		{ConfigMapKeysRef: &helmfluxv1.ObjectKeysSelector{Name: "team-config", Keys: []helmfluxv1.KeySelector{
+			{Key: "prod.yaml"}, {Key: "base.yaml"}, {Key: "staging.yaml", Optional: &trueVal}}}},
+		{SecretKeysRef: &helmfluxv1.ObjectKeysSelector{Name: "team-secrets"}},
+		{SecretKeysRef: &helmfluxv1.ObjectKeysSelector{Name: "other-secrets", Optional: &trueVal}},
+	}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, chartutil.Values{
+		"replicas": float64(1),
+		"image":    map[string]interface{}{"tag": "1.0.0"},
+		"db":       map[string]interface{}{"password": "s3cr3t"},
+	}, values)
+
+	// Without keys, all keys are merged in lexical order
+	values, err = Values(client.CoreV1(), "flux", "", []helmfluxv1.ValuesFromSource{
+		{ConfigMapKeysRef: &helmfluxv1.ObjectKeysSelector{Name: "team-config"}},
+	}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), values["replicas"])
+	assert.Equal(t, map[string]interface{}{"enabled": true}, values["ingress"])
+
+	_, err = Values(client.CoreV1(), "flux", "", []helmfluxv1.ValuesFromSource{
+		{ConfigMapKeysRef: &helmfluxv1.ObjectKeysSelector{Name: "team-config", Keys: []helmfluxv1.KeySelector{{Key: "staging.yaml"}}}},
+	}, nil, nil)
+	assert.EqualError(t, err, "could not find key staging.yaml in ConfigMap flux/team-config")
+}
+
+func TestSpecValues_template(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-info", Namespace: "flux"},
+		Data:       map[string]string{"domain": "example.com"},
+	})
+	r := New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault)
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux", Labels: map[string]string{"team": "platform"}},
+		Spec: helmfluxv1.HelmReleaseSpec{
+			HelmValues: helmfluxv1.HelmValues{Values: chartutil.Values{
+				"fullnameOverride": "{{ .ReleaseName }}",
+				"ingress":          map[string]interface{}{"host": "{{ .Name }}.{{ configMapValue \"cluster-info\" \"domain\" }}"},
+				"team":             "{{ index .Labels \"team\" }}",
+				"hosts":            []interface{}{"{{ .Namespace }}.example.com", 42},
+				"replicaCount":     2,
+			}},
+		},
+	}
+
+	// Without opting in, the values are taken as they are
+	values, err := r.SpecValues(client.CoreV1(), hr)
+	assert.NoError(t, err)
+	assert.Equal(t, "{{ .ReleaseName }}", values["fullnameOverride"])
+
+	hr.Spec.ValuesTemplate = true
+	values, err = r.SpecValues(client.CoreV1(), hr)
+	assert.NoError(t, err)
+	assert.Equal(t, chartutil.Values{
+		"fullnameOverride": "flux-podinfo",
+		"ingress":          map[string]interface{}{"host": "podinfo.example.com"},
+		"team":             "platform",
+		"hosts":            []interface{}{"flux.example.com", 42},
+		"replicaCount":     2,
+	}, values)
+
+	// A value looked up by the template stays a string, rather than
+	// becoming part of the structure of the values
+	client.CoreV1().ConfigMaps("flux").Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "injected", Namespace: "flux"},
+		Data:       map[string]string{"domain": "example.com\nadmin: true"},
+	})
+	hr.Spec.Values = chartutil.Values{"host": "{{ configMapValue \"injected\" \"domain\" }}"}
+	values, err = r.SpecValues(client.CoreV1(), hr)
+	assert.NoError(t, err)
+	assert.Equal(t, chartutil.Values{"host": "example.com\nadmin: true"}, values)
+
+	hr.Spec.Values = chartutil.Values{"host": "{{ configMapValue \"cluster-info\" \"region\" }}"}
+	_, err = r.SpecValues(client.CoreV1(), hr)
+	assert.True(t, IsValuesTemplate(err))
+	assert.Contains(t, err.Error(), "could not find key region in ConfigMap flux/cluster-info")
+
+	hr.Spec.Values = chartutil.Values{"ingress": map[string]interface{}{"host": "{{ .Cluster }}"}}
+	_, err = r.SpecValues(client.CoreV1(), hr)
+	assert.True(t, IsValuesTemplate(err))
+	assert.Contains(t, err.Error(), "values.ingress.host")
+}
+
+// syncFakeClient serialises calls to a k8shelm.FakeClient, which
+// is not safe for concurrent use, and resets its options in between
+// calls so they do not leak from one call into the next.
+type syncFakeClient struct {
+	mu sync.Mutex
+	*k8shelm.FakeClient
+}
+
+func (c *syncFakeClient) resetOpts() {
+	var zero k8shelm.FakeClient
+	c.Opts = zero.Opts
+}
+
+func (c *syncFakeClient) InstallRelease(chStr, ns string, opts ...k8shelm.InstallOption) (*rls.InstallReleaseResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetOpts()
+	return c.FakeClient.InstallRelease(chStr, ns, opts...)
+}
+
+func (c *syncFakeClient) DeleteRelease(rlsName string, opts ...k8shelm.DeleteOption) (*rls.UninstallReleaseResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetOpts()
+	return c.FakeClient.DeleteRelease(rlsName, opts...)
+}
+
+func (c *syncFakeClient) ReleaseHistory(rlsName string, opts ...k8shelm.HistoryOption) (*rls.GetHistoryResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetOpts()
+	return c.FakeClient.ReleaseHistory(rlsName, opts...)
+}
+
+func TestDryRunReleaseName(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux", UID: "5e5e5e5e-4d4d-3c3c-2b2b-1a1a1a1a1a1a"},
+	}
+	assert.Equal(t, "helm-operator-dryrun-5e5e5e5e4d4d3c3c2b2b1a1a1a1a1a1a", DryRunReleaseName(DefaultDryRunReleasePrefix, hr))
+	assert.Len(t, DryRunReleaseName(DefaultDryRunReleasePrefix, hr), maxReleaseNameLength)
+
+	long := DryRunReleaseName("a-very-long-prefix-that-does-not-fit-in-a-release-name-", hr)
+	assert.Len(t, long, maxReleaseNameLength)
+	assert.True(t, strings.HasSuffix(long, "5e5e5e5e4d4d3c3c2b2b1a1a1a1a1a1a"))
+}
+
+func TestInstallConcurrentDryRuns(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(chartPath)
+
+	helmClient := &syncFakeClient{FakeClient: &k8shelm.FakeClient{}}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+	kubeClient := fake.NewSimpleClientset()
+
+	hrs := []helmfluxv1.HelmRelease{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "team-a", UID: "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"},
+			Spec:       helmfluxv1.HelmReleaseSpec{ReleaseName: "podinfo"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "team-b", UID: "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"},
+			Spec:       helmfluxv1.HelmReleaseSpec{ReleaseName: "podinfo"},
+		},
+	}
+
+	var wg sync.WaitGroup
+	names := make([]string, len(hrs))
+	errs := make([]error, len(hrs))
+	for i, hr := range hrs {
+		wg.Add(1)
+		go func(i int, hr helmfluxv1.HelmRelease) {
+			defer wg.Done()
+			rel, _, err := r.Install(chartPath, DryRunReleaseName(DefaultDryRunReleasePrefix, hr), hr, InstallAction, InstallOptions{DryRun: true}, kubeClient)
+			errs[i] = err
+			if rel != nil {
+				names[i] = rel.Name
+			}
+		}(i, hr)
+	}
+	wg.Wait()
+
+	for i := range hrs {
+		assert.NoError(t, errs[i])
+		assert.NotEqual(t, hrs[i].ReleaseName(), names[i])
+	}
+	assert.NotEqual(t, names[0], names[1])
+	assert.Empty(t, helmClient.Rels, "dry runs left releases behind")
+}
+
+func TestInstallDryRunPurgesResidue(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(chartPath)
+
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux", UID: "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"},
+	}
+	name := DryRunReleaseName(DefaultDryRunReleasePrefix, hr)
+
+	// Residue of an earlier dry run
+	helmClient := &syncFakeClient{FakeClient: &k8shelm.FakeClient{
+		Rels: []*hapi_release.Release{k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: name})},
+	}}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+	kubeClient := fake.NewSimpleClientset()
+
+	_, _, err = r.Install(chartPath, name, hr, InstallAction, InstallOptions{DryRun: true}, kubeClient)
+	assert.Error(t, err)
+	assert.Empty(t, helmClient.Rels, "residue of dry run was not purged")
+
+	_, _, err = r.Install(chartPath, name, hr, InstallAction, InstallOptions{DryRun: true}, kubeClient)
+	assert.NoError(t, err)
+}
+
+func TestInstallNamespaceOnlyForDryRuns(t *testing.T) {
+	helmClient := &k8shelm.FakeClient{}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux"}}
+
+	_, _, err := r.Install("chart", "podinfo", hr, InstallAction, InstallOptions{Namespace: "sandbox"}, fake.NewSimpleClientset())
+	assert.Error(t, err)
+	assert.True(t, IsPreApply(err))
+	assert.Empty(t, helmClient.Rels)
+}
+
+func TestUpgradeValues(t *testing.T) {
+	helmClient := &k8shelm.FakeClient{
+		Rels: []*hapi_release.Release{k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{
+			Name:   "podinfo",
+			Config: &hapi_chart.Config{Raw: "image:\n  tag: 1.0.0\nreplicas: 2\n"},
+		})},
+	}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+
+	vals := func() chartutil.Values {
+		return chartutil.Values{"image": map[string]interface{}{"tag": "1.1.0"}}
+	}
+
+	// Values replace the current values by default
+	got, err := r.upgradeValues("podinfo", vals(), InstallOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, vals(), got)
+
+	// Unless they are reused
+	got, err = r.upgradeValues("podinfo", vals(), InstallOptions{ReuseValues: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1.0", got["image"].(map[string]interface{})["tag"])
+	assert.EqualValues(t, 2, got["replicas"])
+
+	// Or reset
+	got, err = r.upgradeValues("podinfo", vals(), InstallOptions{ReuseValues: true, ResetValues: true})
+	assert.NoError(t, err)
+	assert.Equal(t, vals(), got)
+
+	// Without values, the current values are kept, unless reset
+	got, err = r.upgradeValues("podinfo", chartutil.Values{}, InstallOptions{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, got["replicas"])
+	got, err = r.upgradeValues("podinfo", chartutil.Values{}, InstallOptions{ResetValues: true})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	// There is nothing to reuse without a release
+	got, err = r.upgradeValues("other", vals(), InstallOptions{ReuseValues: true})
+	assert.NoError(t, err)
+	assert.Equal(t, vals(), got)
+}
+
+func TestTimeout(t *testing.T) {
+	var timeout120 int64 = 120
+	hr := helmfluxv1.HelmRelease{}
+	assert.Equal(t, int64(300), timeout(hr, InstallOptions{}))
+	assert.Equal(t, int64(600), timeout(hr, InstallOptions{Timeout: 10 * time.Minute}))
+	hr.Spec.Timeout = &timeout120
+	assert.Equal(t, int64(120), timeout(hr, InstallOptions{}))
+
+	assert.True(t, IsTimeout(tillerError(errors.New("release podinfo failed: timed out waiting for the condition"))))
+	assert.True(t, IsTimeout(tillerError(errors.New("rpc error: code = DeadlineExceeded desc = context deadline exceeded"))))
+	assert.False(t, IsTimeout(tillerError(errors.New("release podinfo failed: deployments.apps \"podinfo\" is forbidden"))))
+	assert.False(t, IsTimeout(errors.New("release podinfo failed: timed out waiting for the condition")))
+	assert.True(t, IsTimeout(context.DeadlineExceeded))
+	assert.True(t, IsTimeout(&preApplyError{&url.Error{Op: "Get", URL: "https://charts.example.com", Err: &timeoutError{"i/o timeout"}}}))
+	assert.False(t, IsTimeout(nil))
+}
+
+func TestTest(t *testing.T) {
+	helmClient := &k8shelm.FakeClient{
+		Responses: map[string]hapi_release.TestRun_Status{
+			"PASSED: podinfo-test-connection": hapi_release.TestRun_SUCCESS,
+		},
+	}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+	hr := helmfluxv1.HelmRelease{Spec: helmfluxv1.HelmReleaseSpec{Test: helmfluxv1.Test{Enable: true}}}
+	assert.NoError(t, r.Test("podinfo", hr))
+
+	helmClient.Responses["FAILED: podinfo-test-api"] = hapi_release.TestRun_FAILURE
+	err := r.Test("podinfo", hr)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "podinfo-test-api")
+	assert.NotContains(t, err.Error(), "podinfo-test-connection")
+}
+
+func TestRollbackFailedTests(t *testing.T) {
+	helmClient := &k8shelm.FakeClient{
+		Rels: []*hapi_release.Release{
+			k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: "first", Version: 1}),
+			k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: "failed", Version: 2, StatusCode: hapi_release.Status_FAILED}),
+		},
+	}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+
+	// There is nothing before the first revision
+	_, err := r.RollbackFailedTests("first", helmfluxv1.HelmRelease{})
+	assert.Error(t, err)
+
+	// Failed releases are rolled back as failed releases
+	_, err = r.RollbackFailedTests("failed", helmfluxv1.HelmRelease{})
+	assert.Error(t, err)
+}
+
+func TestGetUpgradableRelease(t *testing.T) {
+	helmClient := &k8shelm.FakeClient{
+		Rels: []*hapi_release.Release{
+			k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: "deployed", StatusCode: hapi_release.Status_DEPLOYED}),
+			k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: "deleted", StatusCode: hapi_release.Status_DELETED}),
+			k8shelm.ReleaseMock(&k8shelm.MockReleaseOptions{Name: "failed", StatusCode: hapi_release.Status_FAILED}),
+		},
+	}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+
+	rel, err := r.GetUpgradableRelease("deployed")
+	assert.NoError(t, err)
+	assert.NotNil(t, rel)
+
+	// A deleted release is installed again, as is a missing one
+	rel, err = r.GetUpgradableRelease("deleted")
+	assert.NoError(t, err)
+	assert.Nil(t, rel)
+	rel, err = r.GetUpgradableRelease("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, rel)
+
+	_, err = r.GetUpgradableRelease("failed")
+	assert.Error(t, err)
+}
+
+// rollbackFakeClient is a k8shelm.FakeClient that counts the
+// rollbacks, which the FakeClient does not support.
+type rollbackFakeClient struct {
+	*k8shelm.FakeClient
+	rollbacks int
+}
+
+func (c *rollbackFakeClient) RollbackRelease(rlsName string, opts ...k8shelm.RollbackOption) (*rls.RollbackReleaseResponse, error) {
+	c.rollbacks++
+	return &rls.RollbackReleaseResponse{Release: &hapi_release.Release{Name: rlsName}}, nil
+}
+
+func TestRecoverPending(t *testing.T) {
+	revision := func(version int32, code hapi_release.Status_Code) *hapi_release.Release {
+		return &hapi_release.Release{
+			Name:    "podinfo",
+			Version: version,
+			Info:    &hapi_release.Info{Status: &hapi_release.Status{Code: code}},
+		}
+	}
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux"}}
+
+	// A pending upgrade is rolled back to the last deployed revision
+	helmClient := &rollbackFakeClient{FakeClient: &k8shelm.FakeClient{Rels: []*hapi_release.Release{
+		revision(3, hapi_release.Status_PENDING_UPGRADE),
+		revision(2, hapi_release.Status_DEPLOYED),
+		revision(1, hapi_release.Status_SUPERSEDED),
+	}}}
+	r := New(log.NewNopLogger(), helmClient, nil, helmfluxv1.ReleaseNameStrategyDefault)
+	_, err := r.GetUpgradableRelease("podinfo")
+	if assert.IsType(t, &PendingError{}, err) {
+		assert.Equal(t, "operation pending for release (PENDING_UPGRADE)", err.Error())
+	}
+	done, err := r.RecoverPending("podinfo", hr, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "rolled back to revision 2", done)
+	assert.Equal(t, 1, helmClient.rollbacks)
+
+	// Or deleted, when asked to
+	helmClient.Rels = []*hapi_release.Release{revision(3, hapi_release.Status_PENDING_UPGRADE)}
+	done, err = r.RecoverPending("podinfo", hr, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "deleted", done)
+	assert.Empty(t, helmClient.Rels)
+	assert.Equal(t, 1, helmClient.rollbacks)
+
+	// A pending install has no revision to roll back to, and is
+	// not deleted instead
+	helmClient.Rels = []*hapi_release.Release{revision(1, hapi_release.Status_PENDING_INSTALL)}
+	_, err = r.RecoverPending("podinfo", hr, true)
+	assert.Error(t, err)
+	assert.Len(t, helmClient.Rels, 1)
+	assert.Equal(t, 1, helmClient.rollbacks)
+}
+
+func TestAdopt(t *testing.T) {
+	bin, err := ioutil.TempDir("", "kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", bin)
+
+	kubectl := func(script string) {
+		if err := ioutil.WriteFile(filepath.Join(bin, "kubectl"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rel := &hapi_release.Release{
+		Name:      "podinfo",
+		Namespace: "default",
+		Manifest:  "apiVersion: v1\nkind: Service\nmetadata:\n  name: podinfo\n",
+	}
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux"}}
+	r := New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault)
+
+	kubectl("exit 0")
+	assert.NoError(t, r.Adopt(rel, hr))
+
+	// A release of which the resources could not be annotated is not
+	// adopted
+	kubectl("echo 'forbidden' >&2; exit 1")
+	err = r.Adopt(rel, hr)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "namespace 'default'")
+	}
+}
+
+func TestUntrackedResources(t *testing.T) {
+	bin, err := ioutil.TempDir("", "kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", bin)
+
+	kubectl := func(script string) {
+		if err := ioutil.WriteFile(filepath.Join(bin, "kubectl"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rel := &hapi_release.Release{
+		Name:      "podinfo",
+		Namespace: "default",
+		Manifest:  "apiVersion: v1\nkind: Service\nmetadata:\n  name: podinfo\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: podinfo\n  namespace: other\n",
+	}
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "flux"}}
+	r := New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault)
+
+	// The Service is tracked, the ConfigMap is not
+	kubectl(`case "$4" in
+Service/*) echo '{"apiVersion":"v1","kind":"Service","metadata":{"name":"podinfo","annotations":{"flux.weave.works/antecedent":"flux:helmrelease/podinfo"}}}' ;;
+*) echo '{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"podinfo"}}' ;;
+esac`)
+	untracked, err := r.UntrackedResources(rel, hr, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other:ConfigMap/podinfo"}, untracked)
+
+	kubectl(`echo 'Error from server (NotFound): not found' >&2; exit 1`)
+	untracked, err = r.UntrackedResources(rel, hr, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default:Service/podinfo (missing)", "other:ConfigMap/podinfo (missing)"}, untracked)
+
+	// Resources that can not be looked up are reported
+	kubectl(`echo 'forbidden' >&2; exit 1`)
+	untracked, err = r.UntrackedResources(rel, hr, time.Second)
+	assert.Empty(t, untracked)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "default:Service/podinfo")
+	}
+}
+
+func TestLeftoverResources(t *testing.T) {
+	bin, err := ioutil.TempDir("", "kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", bin)
+
+	kubectl := func(script string) {
+		if err := ioutil.WriteFile(filepath.Join(bin, "kubectl"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prev := &hapi_release.Release{
+		Name:      "podinfo",
+		Namespace: "default",
+		Version:   1,
+		Manifest:  "apiVersion: v1\nkind: Service\nmetadata:\n  name: podinfo\n",
+	}
+	failed := &hapi_release.Release{
+		Name:      "podinfo",
+		Namespace: "default",
+		Version:   2,
+		Manifest:  "apiVersion: v1\nkind: Service\nmetadata:\n  name: podinfo\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: podinfo\n  namespace: other\n",
+	}
+	r := New(log.NewNopLogger(), nil, nil, helmfluxv1.ReleaseNameStrategyDefault)
+
+	// Only the resources created by the failed revision are looked up
+	kubectl(`case "$4" in
+Service/*) exit 1 ;;
+*) echo '{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"podinfo"}}' ;;
+esac`)
+	leftover, err := r.LeftoverResources(prev, failed, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other:ConfigMap/podinfo"}, leftover)
+
+	kubectl(`echo 'Error from server (NotFound): not found' >&2; exit 1`)
+	leftover, err = r.LeftoverResources(prev, failed, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, leftover)
+
+	// Resources that can not be looked up are reported
+	kubectl(`echo 'forbidden' >&2; exit 1`)
+	leftover, err = r.LeftoverResources(prev, failed, time.Second)
+	assert.Empty(t, leftover)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "other:ConfigMap/podinfo")
+	}
+}
@@ -0,0 +1,99 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"k8s.io/helm/pkg/chartutil"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+// valuesSchemaFile is the file of a chart holding the JSON schema of
+// its values.
+const valuesSchemaFile = "values.schema.json"
+
+// ValuesInvalidError is returned when the values of a release do not
+// meet the schema of its chart, or of one of its dependencies.
+type ValuesInvalidError struct {
+	// Problems lists the offending fields and what is wrong with
+	// them, prefixed by the chart whose schema they violate.
+	Problems []string
+}
+
+func (e *ValuesInvalidError) Error() string {
+	return "values do not meet the schema of the chart: " + strings.Join(e.Problems, "; ")
+}
+
+// IsValuesInvalid returns if the error returned by an install or
+// upgrade is the result of the values not meeting the schema of the
+// chart.
+func IsValuesInvalid(err error) bool {
+	if e, ok := err.(*preApplyError); ok {
+		err = e.err
+	}
+	_, ok := err.(*ValuesInvalidError)
+	return ok
+}
+
+// validateValues validates the given values, merged with the values
+// of the chart at the given path, against the `values.schema.json`
+// of the chart and of each of its enabled dependencies, as Helm 3
+// does. Charts without a schema are not validated, nor are charts
+// that fail to load, as they fail to install with a more telling
+// error.
+func validateValues(chartPath string, rawVals []byte) error {
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil
+	}
+	config := &hapi_chart.Config{Raw: string(rawVals)}
+	if err := chartutil.ProcessRequirementsEnabled(c, config); err != nil {
+		return fmt.Errorf("failed to process chart requirements to validate values: %s", err)
+	}
+	vals, err := chartutil.CoalesceValues(c, config)
+	if err != nil {
+		return fmt.Errorf("failed to merge values to validate them: %s", err)
+	}
+
+	var problems []string
+	if err := validateChartValues(c, vals, "", &problems); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &ValuesInvalidError{Problems: problems}
+	}
+	return nil
+}
+
+// validateChartValues validates the given values against the schema
+// of the given chart, and the values of its dependencies against
+// theirs, appending what is wrong to the given problems.
+func validateChartValues(c *hapi_chart.Chart, vals map[string]interface{}, prefix string, problems *[]string) error {
+	path := prefix + c.GetMetadata().GetName()
+	for _, f := range c.GetFiles() {
+		if f.GetTypeUrl() != valuesSchemaFile {
+			continue
+		}
+		res, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(f.GetValue()), gojsonschema.NewGoLoader(vals))
+		if err != nil {
+			return fmt.Errorf("failed to validate values against the schema of chart '%s': %s", path, err)
+		}
+		for _, e := range res.Errors() {
+			*problems = append(*problems, fmt.Sprintf("%s: %s: %s", path, e.Field(), e.Description()))
+		}
+	}
+	for _, d := range c.GetDependencies() {
+		depVals := map[string]interface{}{}
+		switch v := vals[d.GetMetadata().GetName()].(type) {
+		case map[string]interface{}:
+			depVals = v
+		case chartutil.Values:
+			depVals = v
+		}
+		if err := validateChartValues(d, depVals, path+"/charts/", problems); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const valuesSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["replicas"],
+  "properties": {
+    "replicas": {"type": "integer", "minimum": 1}
+  }
+}`
+
+func TestValidateValues(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(chartPath)
+
+	files := map[string]string{
+		"Chart.yaml":                      "name: parent\nversion: 1.0.0\n",
+		"values.yaml":                     "replicas: 1\n",
+		"values.schema.json":              valuesSchema,
+		"charts/child/Chart.yaml":         "name: child\nversion: 1.0.0\n",
+		"charts/child/values.yaml":        "replicas: 1\n",
+		"charts/child/values.schema.json": valuesSchema,
+	}
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(chartPath, name)), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(chartPath, name), []byte(content), 0644))
+	}
+
+	assert.NoError(t, validateValues(chartPath, []byte("replicas: 2\n")))
+
+	err = validateValues(chartPath, []byte("replicas: 0\nchild:\n  replicas: two\n"))
+	assert.True(t, IsValuesInvalid(err))
+	assert.Len(t, err.(*ValuesInvalidError).Problems, 2)
+	assert.Contains(t, err.Error(), "parent: replicas: Must be greater than or equal to 1")
+	assert.Contains(t, err.Error(), "parent/charts/child: replicas: Invalid type. Expected: integer, given: string")
+}
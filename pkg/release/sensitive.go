@@ -0,0 +1,210 @@
+package release
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/helm/pkg/chartutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// RedactedValue replaces sensitive values in what is logged.
+const RedactedValue = "<redacted>"
+
+// minRedactedLength is the length below which sensitive values are
+// not redacted from within other text, as they would make it
+// unreadable; as whole YAML scalars, they are redacted whatever their
+// length.
+const minRedactedLength = 4
+
+// yamlDocumentSeparator splits a stream of YAML documents, e.g. the
+// manifest of a release.
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// SensitiveValues are the values taken from the Secrets that are
+// marked as sensitive in the values sources of a HelmRelease. They
+// are passed on to Tiller, but are kept out of the values checksum
+// and redacted from what is logged.
+type SensitiveValues struct {
+	// secrets are the '<namespace>/<name>@<resourceVersion>' of the
+	// Secrets the values are taken from
+	secrets []string
+	// values are the (scalar) values taken from them, as text
+	values map[string]bool
+}
+
+// HasSensitiveValues returns if any of the given values sources is
+// marked as sensitive.
+func HasSensitiveValues(valuesFromSource []helmfluxv1.ValuesFromSource) bool {
+	for _, v := range valuesFromSource {
+		if sensitiveSource(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveSource returns if the given values source is a Secret
+// marked as sensitive; other sources cannot be.
+func sensitiveSource(v helmfluxv1.ValuesFromSource) bool {
+	return v.Sensitive && (v.SecretKeyRef != nil || v.SecretKeysRef != nil)
+}
+
+// add records the given values as taken from the given Secret.
+func (s *SensitiveValues) add(secret *corev1.Secret, vals chartutil.Values) {
+	s.secrets = append(s.secrets, fmt.Sprintf("%s/%s@%s", secret.Namespace, secret.Name, secret.ResourceVersion))
+	if s.values == nil {
+		s.values = make(map[string]bool)
+	}
+	collectScalars(canonicalValue(map[string]interface{}(vals)), s.values)
+}
+
+// collectScalars adds the scalars in the given (canonical) value to
+// the given set, except for booleans and nulls, which are not secret.
+func collectScalars(v interface{}, set map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, e := range v {
+			collectScalars(e, set)
+		}
+	case []interface{}:
+		for _, e := range v {
+			collectScalars(e, set)
+		}
+	case nil, bool:
+	default:
+		set[fmt.Sprint(v)] = true
+	}
+}
+
+// redactScalars returns the given (canonical) value with the scalars
+// in the given set replaced by RedactedValue.
+func redactScalars(v interface{}, set map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = redactScalars(e, set)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = redactScalars(e, set)
+		}
+		return l
+	case nil, bool:
+		return v
+	}
+	if set[fmt.Sprint(v)] {
+		return RedactedValue
+	}
+	return v
+}
+
+// Checksum calculates the checksum of the given values like
+// CanonicalValuesChecksum, but with the sensitive values redacted
+// and the resource versions of the Secrets they are taken from
+// included instead, so that it changes when a Secret does without
+// revealing anything about its values.
+func (s SensitiveValues) Checksum(vals chartutil.Values) (string, error) {
+	if len(s.secrets) == 0 {
+		return CanonicalValuesChecksum(vals)
+	}
+	raw, err := yaml.Marshal(redactScalars(canonicalValue(map[string]interface{}(vals)), s.values))
+	if err != nil {
+		return "", err
+	}
+	for _, secret := range s.secrets {
+		raw = append(raw, "# "+secret+"\n"...)
+	}
+	return ValuesChecksum(raw), nil
+}
+
+// RedactYAML returns the given YAML text, e.g. values or the manifest
+// of a release, with every scalar that is a sensitive value, or its
+// base64 encoding as found in the data of rendered Secrets, replaced
+// by RedactedValue, whatever its length; the documents are written
+// out again with their keys sorted, keeping the comments before them.
+// Sensitive values within other scalars, and in documents that are
+// not valid YAML, are redacted as Redact does.
+func (s SensitiveValues) RedactYAML(text string) string {
+	if len(s.values) == 0 {
+		return text
+	}
+	set := make(map[string]bool, 2*len(s.values))
+	for v := range s.values {
+		set[v], set[base64.StdEncoding.EncodeToString([]byte(v))] = true, true
+	}
+	docs := yamlDocumentSeparator.Split(text, -1)
+	for i, doc := range docs {
+		// Keep the comments (e.g. the `# Source:` of a template)
+		// before the document, which would not survive a round trip.
+		var head []string
+		lines := strings.Split(doc, "\n")
+		for len(lines) > 0 {
+			if l := strings.TrimSpace(lines[0]); l != "" && !strings.HasPrefix(l, "#") {
+				break
+			}
+			head, lines = append(head, lines[0]), lines[1:]
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		var v interface{}
+		if err := yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &v); err != nil || v == nil {
+			continue
+		}
+		out, err := yaml.Marshal(redactScalars(canonicalValue(v), set))
+		if err != nil {
+			continue
+		}
+		docs[i] = strings.Join(append(head, string(out)), "\n")
+	}
+	return s.Redact(strings.Join(docs, "---"))
+}
+
+// Redact returns the given text with the sensitive values, and their
+// base64 encodings as found in the data of rendered Secrets, replaced
+// by RedactedValue wherever they are found. Values shorter than four
+// characters are left in place; use RedactYAML for YAML.
+func (s SensitiveValues) Redact(text string) string {
+	var values []string
+	for v := range s.values {
+		if len(v) < minRedactedLength {
+			continue
+		}
+		values = append(values, v, base64.StdEncoding.EncodeToString([]byte(v)))
+		// The lines of multi-line values are indented when they
+		// are rendered as YAML.
+		if strings.Contains(v, "\n") {
+			for _, line := range strings.Split(v, "\n") {
+				if line = strings.TrimSpace(line); len(line) >= minRedactedLength {
+					values = append(values, line)
+				}
+			}
+		}
+	}
+	if len(values) == 0 {
+		return text
+	}
+	// Replace longer values first, so that values that are part of
+	// another one do not leave the rest of it in place.
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, RedactedValue)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
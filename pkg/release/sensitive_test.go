@@ -0,0 +1,120 @@
+package release
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/helm/pkg/chartutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+func TestComposeValues_sensitive(t *testing.T) {
+	secret := func(password, resourceVersion string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "flux", ResourceVersion: resourceVersion},
+			Data:       map[string][]byte{"values.yaml": []byte("password: " + password + "\nenabled: true\n")},
+		}
+	}
+	sources := func(sensitive bool) []helmfluxv1.ValuesFromSource {
+		return []helmfluxv1.ValuesFromSource{{
+			SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}},
+			TargetPath:   "database",
+			Sensitive:    sensitive,
+		}}
+	}
+	specValues := chartutil.Values{"replicas": 2}
+	checksum := func(s *corev1.Secret, sensitive bool) string {
+		client := fake.NewSimpleClientset(s)
+		vals, sv, err := ComposeValues(client.CoreV1(), "flux", "", sources(sensitive), specValues, nil)
+		assert.NoError(t, err)
+		// The sensitive values are composed all the same
+		assert.Contains(t, string(s.Data["values.yaml"]), "password: "+vals["database"].(map[string]interface{})["password"].(string)+"\n")
+		sum, err := sv.Checksum(vals)
+		assert.NoError(t, err)
+		return sum
+	}
+
+	// Without sensitive sources, the checksum is the canonical one
+	client := fake.NewSimpleClientset(secret("s3cr3", "1"))
+	vals, sv, err := ComposeValues(client.CoreV1(), "flux", "", sources(false), specValues, nil)
+	assert.NoError(t, err)
+	canonical, _ := CanonicalValuesChecksum(vals)
+	sum, _ := sv.Checksum(vals)
+	assert.Equal(t, canonical, sum)
+	assert.NotEqual(t, checksum(secret("s3cr3", "1"), false), checksum(secret("0ther", "1"), false))
+
+	// With them, it depends on the version of the secret rather than
+	// on its values
+	assert.NotEqual(t, canonical, checksum(secret("s3cr3", "1"), true))
+	assert.Equal(t, checksum(secret("s3cr3", "1"), true), checksum(secret("0ther", "1"), true))
+	assert.NotEqual(t, checksum(secret("s3cr3", "1"), true), checksum(secret("0ther", "2"), true))
+
+	_, sv, err = ComposeValues(client.CoreV1(), "flux", "", sources(true), specValues, nil)
+	assert.NoError(t, err)
+	assert.True(t, HasSensitiveValues(sources(true)))
+	assert.False(t, HasSensitiveValues(sources(false)))
+	assert.False(t, HasSensitiveValues([]helmfluxv1.ValuesFromSource{{ExternalSourceRef: &helmfluxv1.ExternalSourceSelector{}, Sensitive: true}}))
+
+	text := "password: s3cr3\nencoded: " + base64.StdEncoding.EncodeToString([]byte("s3cr3")) + "\nenabled: true\nreplicas: 2\n"
+	assert.Equal(t, "password: <redacted>\nencoded: <redacted>\nenabled: true\nreplicas: 2\n", sv.Redact(text))
+	assert.Equal(t, text, SensitiveValues{}.Redact(text))
+}
+
+func TestComposeValues_invalidSecret(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "flux"},
+		Data:       map[string][]byte{"values.yaml": []byte("password: [s3cr3")},
+	})
+	_, _, err := ComposeValues(client.CoreV1(), "flux", "", []helmfluxv1.ValuesFromSource{{
+		SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}},
+	}}, nil, nil)
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "s3cr3")
+	}
+}
+
+func TestSensitiveValues_RedactYAML(t *testing.T) {
+	var sv SensitiveValues
+	sv.add(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "flux"}},
+		chartutil.Values{"pin": 42, "user": "bob", "password": "s3cr3t"})
+
+	manifest := `---
+# Source: app/templates/secret.yaml
+kind: Secret
+data:
+  pin: ` + base64.StdEncoding.EncodeToString([]byte("42")) + `
+stringData:
+  user: bob
+  url: postgres://bob:s3cr3t@db
+---
+# Source: app/templates/deployment.yaml
+kind: Deployment
+spec:
+  replicas: 2
+  template: {{ not yaml
+`
+	// Short values are redacted as whole scalars, and only then
+	assert.Equal(t, `---
+# Source: app/templates/secret.yaml
+data:
+  pin: <redacted>
+kind: Secret
+stringData:
+  url: postgres://bob:<redacted>@db
+  user: <redacted>
+---
+# Source: app/templates/deployment.yaml
+kind: Deployment
+spec:
+  replicas: 2
+  template: {{ not yaml
+`, sv.RedactYAML(manifest))
+
+	assert.Equal(t, "database:\n  pin: <redacted>\nreplicas: 42x\n", sv.RedactYAML("database:\n  pin: 42\nreplicas: 42x\n"))
+	assert.Equal(t, manifest, SensitiveValues{}.RedactYAML(manifest))
+}
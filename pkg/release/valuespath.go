@@ -0,0 +1,134 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/helm/pkg/chartutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// ValuesPathError is returned when the values at the source path of
+// a values source can not be extracted, or not be placed at its
+// target path.
+type ValuesPathError struct {
+	// Source describes the values source
+	Source string
+	// Reason is what went wrong
+	Reason string
+}
+
+func (e *ValuesPathError) Error() string {
+	return fmt.Sprintf("values from %s: %s", e.Source, e.Reason)
+}
+
+// IsValuesPath returns if the error returned by an install or upgrade
+// is the result of a values source of which the values at the source
+// path could not be extracted.
+func IsValuesPath(err error) bool {
+	if e, ok := err.(*preApplyError); ok {
+		err = e.err
+	}
+	_, ok := err.(*ValuesPathError)
+	return ok
+}
+
+// splitValuesPath splits the given dot-separated path to a value into
+// its keys.
+func splitValuesPath(path string) []string {
+	path = strings.Trim(path, ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// valuesFromPaths returns the values at the source path of the given
+// values, placed at the target path. Without a source path all values
+// are taken, without a target path they are placed at the root, in
+// which case the values at the source path must be a map.
+func valuesFromPaths(vals chartutil.Values, sourcePath, targetPath string) (chartutil.Values, error) {
+	var fragment interface{} = map[string]interface{}(vals)
+	for i, key := range splitValuesPath(sourcePath) {
+		m, ok := fragment.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("value at '%s' is not a map", strings.Join(splitValuesPath(sourcePath)[:i], "."))
+		}
+		if fragment, ok = m[key]; !ok {
+			return nil, fmt.Errorf("no value at source path '%s'", sourcePath)
+		}
+	}
+
+	keys := splitValuesPath(targetPath)
+	if len(keys) == 0 {
+		m, ok := fragment.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("value at source path '%s' is not a map, and can only be placed at a target path", sourcePath)
+		}
+		return m, nil
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		fragment = map[string]interface{}{keys[i]: fragment}
+	}
+	return fragment.(map[string]interface{}), nil
+}
+
+// WithoutValuesPaths returns the given values without the values at
+// the given dot-separated paths. The given values are not modified;
+// paths that do not exist in them are skipped.
+func WithoutValuesPaths(vals chartutil.Values, paths []string) chartutil.Values {
+	out := vals
+	for _, path := range paths {
+		out = withoutValuesPath(out, splitValuesPath(path))
+	}
+	return out
+}
+
+// withoutValuesPath returns a copy of the given map without the value
+// at the given keys, copying only the maps along the way.
+func withoutValuesPath(vals map[string]interface{}, keys []string) map[string]interface{} {
+	if len(keys) == 0 {
+		return vals
+	}
+	v, ok := vals[keys[0]]
+	if !ok {
+		return vals
+	}
+	var nested map[string]interface{}
+	if len(keys) > 1 {
+		if nested, ok = v.(map[string]interface{}); !ok {
+			return vals
+		}
+	}
+	out := make(map[string]interface{}, len(vals))
+	for k, v := range vals {
+		out[k] = v
+	}
+	if len(keys) == 1 {
+		delete(out, keys[0])
+	} else {
+		out[keys[0]] = withoutValuesPath(nested, keys[1:])
+	}
+	return out
+}
+
+// describeValuesFromSource returns a description of the given values
+// source, for errors.
+func describeValuesFromSource(ns string, v helmfluxv1.ValuesFromSource) string {
+	switch {
+	case v.ConfigMapKeyRef != nil:
+		return fmt.Sprintf("ConfigMap %s/%s", ns, v.ConfigMapKeyRef.Name)
+	case v.SecretKeyRef != nil:
+		return fmt.Sprintf("Secret %s/%s", ns, v.SecretKeyRef.Name)
+	case v.ConfigMapKeysRef != nil:
+		return fmt.Sprintf("ConfigMap %s/%s", ns, v.ConfigMapKeysRef.Name)
+	case v.SecretKeysRef != nil:
+		return fmt.Sprintf("Secret %s/%s", ns, v.SecretKeysRef.Name)
+	case v.ExternalSourceRef != nil:
+		return "URL " + v.ExternalSourceRef.URL
+	case v.ChartFileRef != nil:
+		return "chart file " + v.ChartFileRef.Path
+	}
+	return "unknown source"
+}
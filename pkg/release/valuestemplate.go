@@ -0,0 +1,132 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/helm/pkg/chartutil"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+)
+
+// ValuesTemplateError is returned when the values of a HelmRelease
+// can not be run through the values template.
+type ValuesTemplateError struct {
+	Reason string
+}
+
+func (e *ValuesTemplateError) Error() string {
+	return "values template: " + e.Reason
+}
+
+// IsValuesTemplate returns if the error returned by an install or
+// upgrade is the result of values that could not be run through the
+// values template.
+func IsValuesTemplate(err error) bool {
+	if e, ok := err.(*preApplyError); ok {
+		err = e.err
+	}
+	_, ok := err.(*ValuesTemplateError)
+	return ok
+}
+
+// valuesTemplateData is what the values of a HelmRelease are run
+// through the values template with; it is limited to the metadata of
+// the HelmRelease, other cluster facts are looked up with functions.
+type valuesTemplateData struct {
+	Name            string
+	Namespace       string
+	ReleaseName     string
+	TargetNamespace string
+	Labels          map[string]string
+	Annotations     map[string]string
+}
+
+// SpecValues returns the values of the spec of the given HelmRelease,
+// with every string in them run through the values template first
+// when it asks for that. Only strings are templated, one at a time,
+// and the result is always a string, so that a value looked up by the
+// template can never change the structure of the values.
+func (r *Release) SpecValues(corev1 k8sclientv1.CoreV1Interface, hr helmfluxv1.HelmRelease) (chartutil.Values, error) {
+	if !hr.Spec.ValuesTemplate || len(hr.Spec.Values) == 0 {
+		return hr.Spec.Values, nil
+	}
+
+	funcs := template.FuncMap{
+		// Only config maps in the namespace of the HelmRelease can
+		// be looked up, as for the values from sources.
+		"configMapValue": func(name, key string) (string, error) {
+			cm, err := corev1.ConfigMaps(hr.Namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			v, ok := cm.Data[key]
+			if !ok {
+				return "", fmt.Errorf("could not find key %s in ConfigMap %s/%s", key, hr.Namespace, name)
+			}
+			return v, nil
+		},
+	}
+	data := valuesTemplateData{
+		Name:            hr.Name,
+		Namespace:       hr.Namespace,
+		ReleaseName:     r.ReleaseName(hr),
+		TargetNamespace: hr.GetTargetNamespace(),
+		Labels:          hr.Labels,
+		Annotations:     hr.Annotations,
+	}
+	execute := func(path, text string) (string, error) {
+		tmpl, err := template.New(path).Option("missingkey=error").Funcs(funcs).Parse(text)
+		if err != nil {
+			return "", &ValuesTemplateError{Reason: err.Error()}
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", &ValuesTemplateError{Reason: err.Error()}
+		}
+		return buf.String(), nil
+	}
+
+	values, err := templateValues("values", map[string]interface{}(hr.Spec.Values), execute)
+	if err != nil {
+		return nil, err
+	}
+	return chartutil.Values(values.(map[string]interface{})), nil
+}
+
+// templateValues returns a copy of the given values tree at the given
+// path, with every string leaf replaced by the outcome of execute.
+// Keys are left as they are.
+func templateValues(path string, v interface{}, execute func(path, text string) (string, error)) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return execute(path, v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			t, err := templateValues(path+"."+k, e, execute)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = t
+		}
+		return out, nil
+	case chartutil.Values:
+		return templateValues(path, map[string]interface{}(v), execute)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			t, err := templateValues(fmt.Sprintf("%s[%d]", path, i), e, execute)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = t
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
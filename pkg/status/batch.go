@@ -0,0 +1,86 @@
+package status
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	v1client "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/typed/helm.fluxcd.io/v1"
+)
+
+// Batch is a client for the HelmReleases of a namespace that collects
+// the status updates of the functions of this package for a single
+// HelmRelease, and writes them with one update once flushed. Anything
+// else is passed on to the client it wraps.
+type Batch struct {
+	v1client.HelmReleaseInterface
+	name string
+
+	mu      sync.Mutex
+	pending *helmfluxv1.HelmRelease
+	changes []func(*helmfluxv1.HelmRelease) bool
+}
+
+// NewBatch returns a Batch for the status updates of the given
+// HelmRelease, on top of the given client.
+func NewBatch(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease) *Batch {
+	return &Batch{HelmReleaseInterface: client, name: hr.Name}
+}
+
+// add applies the given change to the pending version of the
+// HelmRelease, fetching it first if there is none, and records the
+// change if it made one.
+func (b *Batch) add(change func(*helmfluxv1.HelmRelease) bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending == nil {
+		cHr, err := b.HelmReleaseInterface.Get(b.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		b.pending = cHr
+	}
+	if change(b.pending) {
+		b.changes = append(b.changes, change)
+	}
+	return nil
+}
+
+// Flush writes the status updates collected since the last flush, if
+// any. When the HelmRelease was modified in the meantime, the changes
+// are applied again to its latest version, until they are written or
+// the retries run out.
+func (b *Batch) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.changes) == 0 {
+		b.pending = nil
+		return nil
+	}
+	cHr := b.pending
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if cHr == nil {
+			latest, err := b.HelmReleaseInterface.Get(b.name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			changed := false
+			for _, change := range b.changes {
+				changed = change(latest) || changed
+			}
+			if !changed {
+				return nil
+			}
+			cHr = latest
+		}
+		_, err := b.HelmReleaseInterface.UpdateStatus(cHr)
+		cHr = nil
+		return err
+	})
+	b.pending, b.changes = nil, nil
+	return err
+}
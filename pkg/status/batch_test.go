@@ -0,0 +1,76 @@
+package status
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/fake"
+)
+
+func TestBatch(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "hr", Namespace: "ns", Generation: 2}}
+	clientset := fake.NewSimpleClientset(&hr)
+	client := clientset.HelmV1().HelmReleases("ns")
+
+	updates := func() int {
+		n := 0
+		for _, a := range clientset.Actions() {
+			if a.GetVerb() == "update" && a.GetSubresource() == "status" {
+				n++
+			}
+		}
+		return n
+	}
+	get := func() helmfluxv1.HelmReleaseStatus {
+		cHr, err := client.Get("hr", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return cHr.Status
+	}
+
+	batch := NewBatch(client, hr)
+	assert.NoError(t, SetCondition(batch, hr, NewCondition(helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, "Success", "installed"), 0))
+	assert.NoError(t, SetReleaseRevision(batch, hr, "1.0.0"))
+	assert.NoError(t, SetValuesChecksum(batch, hr, "abc"))
+	assert.NoError(t, SetObservedGeneration(batch, hr, hr.Generation))
+	assert.Equal(t, 0, updates())
+	assert.Empty(t, get().Revision)
+
+	assert.NoError(t, batch.Flush())
+	assert.Equal(t, 1, updates())
+	st := get()
+	assert.Equal(t, "1.0.0", st.Revision)
+	assert.Equal(t, "abc", st.ValuesChecksum)
+	assert.Equal(t, int64(2), st.ObservedGeneration)
+	if ready := GetCondition(st, helmfluxv1.HelmReleaseReady); assert.NotNil(t, ready) {
+		assert.Equal(t, v1.ConditionTrue, ready.Status)
+	}
+
+	// Flushing again without changes does not write anything
+	assert.NoError(t, batch.Flush())
+	assert.Equal(t, 1, updates())
+
+	// Changes are applied again to the latest version on a conflict,
+	// keeping what was changed in the meantime
+	assert.NoError(t, SetReleaseRevision(batch, hr, "1.1.0"))
+	assert.NoError(t, SetReleaseStatus(client, hr, "ns-hr", "DEPLOYED"))
+	conflicts := 1
+	clientset.PrependReactor("update", "helmreleases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts == 0 {
+			return false, nil, nil
+		}
+		conflicts--
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Group: "helm.fluxcd.io", Resource: "helmreleases"}, "hr", nil)
+	})
+	assert.NoError(t, batch.Flush())
+	st = get()
+	assert.Equal(t, "1.1.0", st.Revision)
+	assert.Equal(t, "DEPLOYED", st.ReleaseStatus)
+}
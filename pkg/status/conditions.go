@@ -1,6 +1,8 @@
 package status
 
 import (
+	"fmt"
+
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -8,6 +10,19 @@ import (
 	v1client "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/typed/helm.fluxcd.io/v1"
 )
 
+// Reasons of the aggregate conditions that are not taken over from
+// the conditions they aggregate.
+const (
+	ReasonProgressing = "Progressing"
+	ReasonRolledBack  = "RolledBack"
+	ReasonNotStalled  = "NotStalled"
+)
+
+// ReasonUpgradeRetriesExhausted is the reason of the Released
+// condition of a HelmRelease of which the upgrades are not attempted
+// again, as they failed and were rolled back too often.
+const ReasonUpgradeRetriesExhausted = "UpgradeRetriesExhausted"
+
 // NewCondition creates a new HelmReleaseCondition.
 func NewCondition(conditionType helmfluxv1.HelmReleaseConditionType, status v1.ConditionStatus,
 	reason, message string) helmfluxv1.HelmReleaseCondition {
@@ -22,25 +37,147 @@ func NewCondition(conditionType helmfluxv1.HelmReleaseConditionType, status v1.C
 	}
 }
 
-// SetCondition updates the HelmRelease to include the given condition.
+// SetCondition updates the HelmRelease to include the given
+// condition, and updates the aggregate Ready and Stalled conditions
+// accordingly. The release is considered stalled once it failed with
+// the same reason stalledThreshold consecutive times; zero disables
+// the Stalled condition.
 func SetCondition(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease,
-	condition helmfluxv1.HelmReleaseCondition) error {
+	condition helmfluxv1.HelmReleaseCondition, stalledThreshold int64) error {
 
-	cHr, err := client.Get(hr.Name, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if condition.Type == helmfluxv1.HelmReleaseReleased {
+			cHr.Status.Failures = consecutiveFailures(cHr.Status, condition)
+		}
+		setCondition(&cHr.Status, condition)
+		if stalledThreshold > 0 {
+			// The rollback settings are those of the given
+			// HelmRelease, which may have defaults applied.
+			current := *cHr
+			current.Spec.Rollback = hr.Spec.Rollback
+			if stalled := stalledCondition(current, stalledThreshold); stalled != nil {
+				setAggregateCondition(&cHr.Status, *stalled)
+			}
+		}
+		setAggregateCondition(&cHr.Status, readyCondition(*cHr))
+		return true
+	})
+}
 
-	currCondition := GetCondition(cHr.Status, condition.Type)
+// setCondition replaces the condition of the same type in the given
+// status with the given condition.
+func setCondition(status *helmfluxv1.HelmReleaseStatus, condition helmfluxv1.HelmReleaseCondition) {
+	currCondition := GetCondition(*status, condition.Type)
 	if currCondition != nil && currCondition.Status == condition.Status {
 		condition.LastTransitionTime = currCondition.LastTransitionTime
 	}
 
-	newConditions := filterOutCondition(cHr.Status.Conditions, condition.Type)
-	cHr.Status.Conditions = append(newConditions, condition)
+	newConditions := filterOutCondition(status.Conditions, condition.Type)
+	status.Conditions = append(newConditions, condition)
+}
+
+// setAggregateCondition is setCondition for conditions derived from
+// the others, which are left untouched (including their update time)
+// if they did not change.
+func setAggregateCondition(status *helmfluxv1.HelmReleaseStatus, condition helmfluxv1.HelmReleaseCondition) {
+	if !conditionChanged(*status, condition) {
+		return
+	}
+	setCondition(status, condition)
+}
+
+// conditionChanged returns if the given condition differs from the
+// one of the same type in the given status, disregarding its times.
+func conditionChanged(status helmfluxv1.HelmReleaseStatus, condition helmfluxv1.HelmReleaseCondition) bool {
+	currCondition := GetCondition(status, condition.Type)
+	return currCondition == nil || currCondition.Status != condition.Status ||
+		currCondition.Reason != condition.Reason || currCondition.Message != condition.Message
+}
+
+// consecutiveFailures returns the number of consecutive failures of
+// the release, once the given Released condition is set.
+func consecutiveFailures(status helmfluxv1.HelmReleaseStatus, released helmfluxv1.HelmReleaseCondition) int64 {
+	if released.Status != v1.ConditionFalse {
+		return 0
+	}
+	currCondition := GetCondition(status, helmfluxv1.HelmReleaseReleased)
+	if currCondition != nil && currCondition.Status == v1.ConditionFalse && currCondition.Reason == released.Reason {
+		return status.Failures + 1
+	}
+	return 1
+}
 
-	_, err = client.UpdateStatus(cHr)
-	return err
+// stalledCondition returns the Stalled condition for the given
+// HelmRelease, or nil if the release has not been attempted yet. A
+// release of which the upgrade retries are exhausted is stalled too.
+func stalledCondition(hr helmfluxv1.HelmRelease, threshold int64) *helmfluxv1.HelmReleaseCondition {
+	status := hr.Status
+	released := GetCondition(status, helmfluxv1.HelmReleaseReleased)
+	if released == nil {
+		return nil
+	}
+	if released.Reason == ReasonUpgradeRetriesExhausted && released.Status == v1.ConditionFalse {
+		condition := NewCondition(helmfluxv1.HelmReleaseStalled, v1.ConditionTrue, released.Reason, released.Message)
+		return &condition
+	}
+	if status.Failures < threshold {
+		condition := NewCondition(helmfluxv1.HelmReleaseStalled, v1.ConditionFalse, ReasonNotStalled, "")
+		return &condition
+	}
+	condition := NewCondition(helmfluxv1.HelmReleaseStalled, v1.ConditionTrue, released.Reason,
+		fmt.Sprintf("release failed %d consecutive times: %s", status.Failures, released.Message))
+	return &condition
+}
+
+// readyCondition returns the Ready condition for the given
+// HelmRelease, which aggregates the ChartFetched, Released and
+// RolledBack conditions, and the observed generation.
+func readyCondition(hr helmfluxv1.HelmRelease) helmfluxv1.HelmReleaseCondition {
+	chartFetched := GetCondition(hr.Status, helmfluxv1.HelmReleaseChartFetched)
+	released := GetCondition(hr.Status, helmfluxv1.HelmReleaseReleased)
+	rolledBack := GetCondition(hr.Status, helmfluxv1.HelmReleaseRolledBack)
+
+	for _, c := range []*helmfluxv1.HelmReleaseCondition{chartFetched, released} {
+		if c != nil && c.Status == v1.ConditionFalse {
+			ready := NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionFalse, c.Reason, c.Message)
+			ready.ErrorCategory = c.ErrorCategory
+			return ready
+		}
+	}
+	// NB: a rollback is followed by a new Released condition once
+	// the release is upgraded again.
+	if rolledBack != nil && rolledBack.Status == v1.ConditionTrue &&
+		(released == nil || !rolledBack.LastUpdateTime.Before(&released.LastUpdateTime)) {
+		return NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionFalse, ReasonRolledBack, "release was rolled back")
+	}
+	if hr.Status.ObservedGeneration < hr.Generation {
+		return NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionUnknown, ReasonProgressing,
+			fmt.Sprintf("generation %d has not been reconciled yet", hr.Generation))
+	}
+	if chartFetched != nil && chartFetched.Status == v1.ConditionUnknown {
+		return NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionUnknown, chartFetched.Reason, chartFetched.Message)
+	}
+	if released != nil && released.Status == v1.ConditionUnknown {
+		return NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionUnknown, released.Reason, released.Message)
+	}
+	if released == nil || released.Status != v1.ConditionTrue {
+		return NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionUnknown, ReasonProgressing, "release has not been released yet")
+	}
+	return NewCondition(helmfluxv1.HelmReleaseReady, v1.ConditionTrue, released.Reason, released.Message)
+}
+
+// RemoveCondition removes the condition of the given type from the
+// status of the HelmRelease, if it has one.
+func RemoveCondition(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease,
+	conditionType helmfluxv1.HelmReleaseConditionType) error {
+
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if GetCondition(cHr.Status, conditionType) == nil {
+			return false
+		}
+		cHr.Status.Conditions = filterOutCondition(cHr.Status.Conditions, conditionType)
+		return true
+	})
 }
 
 // GetCondition returns the condition with the given type.
@@ -0,0 +1,129 @@
+package status
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/fake"
+)
+
+func TestSetCondition_Stalled(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "hr", Namespace: "ns", Generation: 1},
+		Status:     helmfluxv1.HelmReleaseStatus{ObservedGeneration: 1},
+	}
+	client := fake.NewSimpleClientset(&hr).HelmV1().HelmReleases("ns")
+
+	get := func() helmfluxv1.HelmReleaseStatus {
+		cHr, err := client.Get("hr", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return cHr.Status
+	}
+	set := func(st v1.ConditionStatus, reason string) {
+		err := SetCondition(client, hr, NewCondition(helmfluxv1.HelmReleaseReleased, st, reason, "message"), 2)
+		assert.NoError(t, err)
+	}
+
+	set(v1.ConditionFalse, "HelmUpgradeFailed")
+	assert.Equal(t, int64(1), get().Failures)
+	assert.Equal(t, v1.ConditionFalse, GetCondition(get(), helmfluxv1.HelmReleaseStalled).Status)
+	assert.Equal(t, v1.ConditionFalse, GetCondition(get(), helmfluxv1.HelmReleaseReady).Status)
+
+	set(v1.ConditionFalse, "HelmUpgradeFailed")
+	stalled := GetCondition(get(), helmfluxv1.HelmReleaseStalled)
+	assert.Equal(t, v1.ConditionTrue, stalled.Status)
+	assert.Equal(t, "HelmUpgradeFailed", stalled.Reason)
+	assert.Equal(t, "release failed 2 consecutive times: message", stalled.Message)
+
+	set(v1.ConditionFalse, "HelmTimeout")
+	assert.Equal(t, int64(1), get().Failures)
+	assert.Equal(t, v1.ConditionFalse, GetCondition(get(), helmfluxv1.HelmReleaseStalled).Status)
+
+	set(v1.ConditionTrue, "HelmSuccess")
+	assert.Equal(t, int64(0), get().Failures)
+	assert.Equal(t, v1.ConditionFalse, GetCondition(get(), helmfluxv1.HelmReleaseStalled).Status)
+	assert.Equal(t, v1.ConditionTrue, GetCondition(get(), helmfluxv1.HelmReleaseReady).Status)
+}
+
+func TestReadyCondition(t *testing.T) {
+	earlier := metav1.Unix(100, 0)
+	later := metav1.Unix(200, 0)
+	condition := func(typ helmfluxv1.HelmReleaseConditionType, st v1.ConditionStatus, reason string, updated metav1.Time) helmfluxv1.HelmReleaseCondition {
+		return helmfluxv1.HelmReleaseCondition{Type: typ, Status: st, Reason: reason, LastUpdateTime: updated}
+	}
+
+	for name, tc := range map[string]struct {
+		generation int64
+		conditions []helmfluxv1.HelmReleaseCondition
+		status     v1.ConditionStatus
+		reason     string
+	}{
+		"released": {
+			generation: 1,
+			conditions: []helmfluxv1.HelmReleaseCondition{
+				condition(helmfluxv1.HelmReleaseChartFetched, v1.ConditionTrue, "RepoChartInCache", earlier),
+				condition(helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, "HelmSuccess", earlier),
+			},
+			status: v1.ConditionTrue,
+			reason: "HelmSuccess",
+		},
+		"new generation": {
+			generation: 2,
+			conditions: []helmfluxv1.HelmReleaseCondition{
+				condition(helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, "HelmSuccess", earlier),
+			},
+			status: v1.ConditionUnknown,
+			reason: ReasonProgressing,
+		},
+		"fetch failed": {
+			generation: 1,
+			conditions: []helmfluxv1.HelmReleaseCondition{
+				condition(helmfluxv1.HelmReleaseChartFetched, v1.ConditionFalse, "RepoFetchFailed", later),
+				condition(helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, "HelmSuccess", earlier),
+			},
+			status: v1.ConditionFalse,
+			reason: "RepoFetchFailed",
+		},
+		"release deferred": {
+			generation: 1,
+			conditions: []helmfluxv1.HelmReleaseCondition{
+				condition(helmfluxv1.HelmReleaseChartFetched, v1.ConditionTrue, "RepoChartInCache", earlier),
+				condition(helmfluxv1.HelmReleaseReleased, v1.ConditionUnknown, "DeferredOutsideWindow", later),
+			},
+			status: v1.ConditionUnknown,
+			reason: "DeferredOutsideWindow",
+		},
+		"rolled back": {
+			generation: 1,
+			conditions: []helmfluxv1.HelmReleaseCondition{
+				condition(helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, "HelmSuccess", earlier),
+				condition(helmfluxv1.HelmReleaseRolledBack, v1.ConditionTrue, "HelmSuccess", later),
+			},
+			status: v1.ConditionFalse,
+			reason: ReasonRolledBack,
+		},
+		"released after rollback": {
+			generation: 1,
+			conditions: []helmfluxv1.HelmReleaseCondition{
+				condition(helmfluxv1.HelmReleaseReleased, v1.ConditionTrue, "HelmSuccess", later),
+				condition(helmfluxv1.HelmReleaseRolledBack, v1.ConditionTrue, "HelmSuccess", earlier),
+			},
+			status: v1.ConditionTrue,
+			reason: "HelmSuccess",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			hr := helmfluxv1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Generation: tc.generation},
+				Status:     helmfluxv1.HelmReleaseStatus{ObservedGeneration: 1, Conditions: tc.conditions},
+			}
+			ready := readyCondition(hr)
+			assert.Equal(t, tc.status, ready.Status)
+			assert.Equal(t, tc.reason, ready.Reason)
+		})
+	}
+}
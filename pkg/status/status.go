@@ -30,16 +30,18 @@ import (
 )
 
 type Updater struct {
-	hrClient   ifclientset.Interface
-	hrLister   iflister.HelmReleaseLister
-	helmClient *helm.Client
+	hrClient     ifclientset.Interface
+	hrLister     iflister.HelmReleaseLister
+	helmClient   *helm.Client
+	nameStrategy helmfluxv1.ReleaseNameStrategy
 }
 
-func New(hrClient ifclientset.Interface, hrLister iflister.HelmReleaseLister, helmClient *helm.Client) *Updater {
+func New(hrClient ifclientset.Interface, hrLister iflister.HelmReleaseLister, helmClient *helm.Client, nameStrategy helmfluxv1.ReleaseNameStrategy) *Updater {
 	return &Updater{
-		hrClient:   hrClient,
-		hrLister:   hrLister,
-		helmClient: helmClient,
+		hrClient:     hrClient,
+		hrLister:     hrLister,
+		helmClient:   helmClient,
+		nameStrategy: nameStrategy,
 	}
 }
 
@@ -61,7 +63,7 @@ bail:
 		}
 		for _, hr := range list {
 			nsHrClient := u.hrClient.HelmV1().HelmReleases(hr.Namespace)
-			releaseName := hr.ReleaseName()
+			releaseName := hr.ReleaseNameWith(u.nameStrategy)
 			releaseStatus, _ := u.helmClient.ReleaseStatus(releaseName)
 			// If we are unable to get the status, we do not care why
 			if releaseStatus == nil {
@@ -84,72 +86,257 @@ bail:
 func SetReleaseStatus(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease,
 	releaseName, releaseStatus string) error {
 
-	cHr, err := client.Get(hr.Name, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-
-	if cHr.Status.ReleaseName == releaseName && cHr.Status.ReleaseStatus == releaseStatus {
-		return nil
-	}
-
-	cHr.Status.ReleaseName = releaseName
-	cHr.Status.ReleaseStatus = releaseStatus
-
-	_, err = client.UpdateStatus(cHr)
-	return err
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.ReleaseName == releaseName && cHr.Status.ReleaseStatus == releaseStatus {
+			return false
+		}
+		cHr.Status.ReleaseName = releaseName
+		cHr.Status.ReleaseStatus = releaseStatus
+		return true
+	})
 }
 
 // SetReleaseRevision updates the status of the HelmRelease to the
 // given revision.
 func SetReleaseRevision(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, revision string) error {
-	cHr, err := client.Get(hr.Name, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.Revision == revision {
+			return false
+		}
+		cHr.Status.Revision = revision
+		return true
+	})
+}
 
-	if cHr.Status.Revision == revision {
-		return nil
-	}
+// SetHelmVersion updates the status of the HelmRelease to the given
+// version of Helm.
+func SetHelmVersion(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, version string) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.HelmVersion == version {
+			return false
+		}
+		cHr.Status.HelmVersion = version
+		return true
+	})
+}
 
-	cHr.Status.Revision = revision
+// SetChartDigest updates the chart digest of the HelmRelease to the
+// given digest; an empty digest clears it.
+func SetChartDigest(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, digest string) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.ChartDigest == digest {
+			return false
+		}
+		cHr.Status.ChartDigest = digest
+		return true
+	})
+}
 
-	_, err = client.UpdateStatus(cHr)
-	return err
+// SetDryRunMode updates the dry run mode status of the HelmRelease to
+// the given mode.
+func SetDryRunMode(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, mode string) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.DryRunMode == mode {
+			return false
+		}
+		cHr.Status.DryRunMode = mode
+		return true
+	})
+}
+
+// SetLastHandledReconcileAt records the given value of the reconcile
+// request annotation as handled in the status of the HelmRelease.
+func SetLastHandledReconcileAt(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, requestedAt string) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.LastHandledReconcileAt == requestedAt {
+			return false
+		}
+		cHr.Status.LastHandledReconcileAt = requestedAt
+		return true
+	})
+}
+
+// SetLastSuccessfulRevision records the given revision of the Helm
+// release as the last successfully installed or upgraded one, at the
+// current time; the revision it replaces becomes the previous
+// revision.
+func SetLastSuccessfulRevision(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, revision int32) error {
+	now := metav1.Now()
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.LastSuccessfulRevision != revision {
+			cHr.Status.PreviousRevision = cHr.Status.LastSuccessfulRevision
+			cHr.Status.LastSuccessfulRevision = revision
+		}
+		cHr.Status.LastReconcileTime = &now
+		return true
+	})
+}
+
+// HistoryLimit is the number of entries kept in the history of a
+// HelmRelease.
+const HistoryLimit = 10
+
+// AppendHistory appends an entry for the given action to the history
+// of the HelmRelease, dropping the oldest entries beyond the limit.
+// An entry equal to the last one only updates its time, so that an
+// action repeated on every sync does not push out the others.
+func AppendHistory(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease,
+	action helmfluxv1.HelmReleaseAction, reason, revision string) error {
+
+	entry := helmfluxv1.HelmReleaseHistoryEntry{
+		Action:   action,
+		Reason:   reason,
+		Revision: revision,
+		Time:     metav1.Now(),
+	}
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		cHr.Status.History = appendHistory(cHr.Status.History, entry)
+		return true
+	})
+}
+
+// appendHistory appends the given entry to the given history, as
+// described for AppendHistory.
+func appendHistory(history []helmfluxv1.HelmReleaseHistoryEntry, entry helmfluxv1.HelmReleaseHistoryEntry) []helmfluxv1.HelmReleaseHistoryEntry {
+	if n := len(history); n > 0 {
+		last := history[n-1]
+		if last.Action == entry.Action && last.Reason == entry.Reason && last.Revision == entry.Revision {
+			history[n-1].Time = entry.Time
+			return history
+		}
+	}
+	history = append(history, entry)
+	if len(history) > HistoryLimit {
+		history = history[len(history)-HistoryLimit:]
+	}
+	return history
 }
 
 // SetValuesChecksum updates the values checksum of the HelmRelease to
 // the given checksum.
 func SetValuesChecksum(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, valuesChecksum string) error {
-	cHr, err := client.Get(hr.Name, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-
-	if valuesChecksum == "" || cHr.Status.ValuesChecksum == valuesChecksum {
+	if valuesChecksum == "" {
 		return nil
 	}
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.ValuesChecksum == valuesChecksum {
+			return false
+		}
+		cHr.Status.ValuesChecksum = valuesChecksum
+		return true
+	})
+}
 
-	cHr.Status.ValuesChecksum = valuesChecksum
+// SetBackoffDelay updates the backoff delay of the HelmRelease to
+// the given delay; zero clears it.
+func SetBackoffDelay(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, delay time.Duration) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		curr := time.Duration(0)
+		if cHr.Status.BackoffDelay != nil {
+			curr = cHr.Status.BackoffDelay.Duration
+		}
+		if curr == delay {
+			return false
+		}
+		cHr.Status.BackoffDelay = nil
+		if delay > 0 {
+			cHr.Status.BackoffDelay = &metav1.Duration{Duration: delay}
+		}
+		return true
+	})
+}
 
-	_, err = client.UpdateStatus(cHr)
-	return err
+// IncrementRollbackCount counts a rolled back upgrade of the current
+// generation of the HelmRelease to the given chart revision and
+// values checksum, starting over if those counted so far were of
+// another generation, chart revision or values.
+func IncrementRollbackCount(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, chartRevision, valuesChecksum string) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if !rollbacksOf(*cHr, hr.Generation, chartRevision, valuesChecksum) {
+			cHr.Status.RollbackCount = 0
+			cHr.Status.RollbackGeneration = hr.Generation
+			cHr.Status.RollbackRevision = chartRevision
+			cHr.Status.RollbackValuesChecksum = valuesChecksum
+		}
+		cHr.Status.RollbackCount++
+		return true
+	})
+}
+
+// ResetRollbackCount clears the count of rolled back upgrades of the
+// HelmRelease.
+func ResetRollbackCount(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.RollbackCount == 0 && cHr.Status.RollbackGeneration == 0 &&
+			cHr.Status.RollbackRevision == "" && cHr.Status.RollbackValuesChecksum == "" {
+			return false
+		}
+		cHr.Status.RollbackCount = 0
+		cHr.Status.RollbackGeneration = 0
+		cHr.Status.RollbackRevision = ""
+		cHr.Status.RollbackValuesChecksum = ""
+		return true
+	})
+}
+
+// rollbacksOf returns if the rolled back upgrades counted in the
+// status of the HelmRelease are of the given generation, chart
+// revision and values checksum.
+func rollbacksOf(hr helmfluxv1.HelmRelease, generation int64, chartRevision, valuesChecksum string) bool {
+	return hr.Status.RollbackGeneration == generation &&
+		hr.Status.RollbackRevision == chartRevision &&
+		hr.Status.RollbackValuesChecksum == valuesChecksum
 }
 
 // SetObservedGeneration updates the observed generation status of the
-// HelmRelease to the given generation.
+// HelmRelease to the given generation, and its Ready condition
+// accordingly.
 func SetObservedGeneration(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, generation int64) error {
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		if cHr.Status.ObservedGeneration >= generation {
+			return false
+		}
+		cHr.Status.ObservedGeneration = generation
+		setAggregateCondition(&cHr.Status, readyCondition(*cHr))
+		return true
+	})
+}
+
+// UpdateReady updates the Ready condition of the HelmRelease to
+// reflect its other conditions and its observed generation, if it
+// does not already.
+func UpdateReady(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease) error {
+	if !conditionChanged(hr.Status, readyCondition(hr)) {
+		return nil
+	}
+
+	return update(client, hr, func(cHr *helmfluxv1.HelmRelease) bool {
+		ready := readyCondition(*cHr)
+		if !conditionChanged(cHr.Status, ready) {
+			return false
+		}
+		setAggregateCondition(&cHr.Status, ready)
+		return true
+	})
+}
+
+// update applies the given change to the latest version of the
+// HelmRelease, and updates its status if the change reports it made
+// one. Through a Batch for the HelmRelease, the status is only
+// updated once the batch is flushed.
+func update(client v1client.HelmReleaseInterface, hr helmfluxv1.HelmRelease, change func(*helmfluxv1.HelmRelease) bool) error {
+	if b, ok := client.(*Batch); ok && b.name == hr.Name {
+		return b.add(change)
+	}
+
 	cHr, err := client.Get(hr.Name, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
-
-	if cHr.Status.ObservedGeneration >= generation {
+	if !change(cHr) {
 		return nil
 	}
 
-	cHr.Status.ObservedGeneration = generation
-
 	_, err = client.UpdateStatus(cHr)
 	return err
 }
@@ -165,6 +352,16 @@ func HasSynced(hr helmfluxv1.HelmRelease) bool {
 	return hr.Status.ObservedGeneration >= hr.Generation
 }
 
+// UpgradeRetriesExhausted returns if the upgrades of the current
+// generation of the HelmRelease to the given chart revision and
+// values checksum failed and were rolled back more often than they
+// may be attempted again.
+func UpgradeRetriesExhausted(hr helmfluxv1.HelmRelease, chartRevision, valuesChecksum string) bool {
+	maxRetries := hr.Spec.Rollback.GetMaxRetries()
+	return maxRetries >= 0 && rollbacksOf(hr, hr.Generation, chartRevision, valuesChecksum) &&
+		hr.Status.RollbackCount > maxRetries
+}
+
 // HasRolledBack returns if the current generation of the HelmRelease
 // has been rolled back.
 func HasRolledBack(hr helmfluxv1.HelmRelease) bool {
@@ -0,0 +1,58 @@
+package status
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	helmfluxv1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/client/clientset/versioned/fake"
+)
+
+func TestSetLastSuccessfulRevision(t *testing.T) {
+	hr := helmfluxv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "hr", Namespace: "ns"}}
+	client := fake.NewSimpleClientset(&hr).HelmV1().HelmReleases("ns")
+
+	get := func() helmfluxv1.HelmReleaseStatus {
+		cHr, err := client.Get("hr", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return cHr.Status
+	}
+
+	assert.NoError(t, SetLastSuccessfulRevision(client, hr, 1))
+	assert.Equal(t, int32(1), get().LastSuccessfulRevision)
+	assert.Equal(t, int32(0), get().PreviousRevision)
+	assert.NotNil(t, get().LastReconcileTime)
+
+	assert.NoError(t, SetLastSuccessfulRevision(client, hr, 3))
+	assert.Equal(t, int32(3), get().LastSuccessfulRevision)
+	assert.Equal(t, int32(1), get().PreviousRevision)
+
+	// Recording the same revision again keeps the previous one
+	assert.NoError(t, SetLastSuccessfulRevision(client, hr, 3))
+	assert.Equal(t, int32(3), get().LastSuccessfulRevision)
+	assert.Equal(t, int32(1), get().PreviousRevision)
+}
+
+func TestAppendHistory(t *testing.T) {
+	entry := func(action helmfluxv1.HelmReleaseAction, reason string, sec int64) helmfluxv1.HelmReleaseHistoryEntry {
+		return helmfluxv1.HelmReleaseHistoryEntry{Action: action, Reason: reason, Revision: "1.0.0", Time: metav1.Unix(sec, 0)}
+	}
+
+	var history []helmfluxv1.HelmReleaseHistoryEntry
+	history = appendHistory(history, entry(helmfluxv1.HelmReleaseActionInstall, "HelmSuccess", 1))
+	history = appendHistory(history, entry(helmfluxv1.HelmReleaseActionSkip, "DeferredOutsideWindow", 2))
+	// Repeating the last entry only updates its time
+	history = appendHistory(history, entry(helmfluxv1.HelmReleaseActionSkip, "DeferredOutsideWindow", 3))
+	assert.Len(t, history, 2)
+	assert.Equal(t, metav1.Unix(3, 0), history[1].Time)
+
+	for i := int64(0); i < HistoryLimit; i++ {
+		history = appendHistory(history, entry(helmfluxv1.HelmReleaseActionUpgrade, fmt.Sprintf("reason-%d", i), 10+i))
+	}
+	assert.Len(t, history, HistoryLimit)
+	assert.Equal(t, "reason-0", history[0].Reason)
+	assert.Equal(t, fmt.Sprintf("reason-%d", HistoryLimit-1), history[HistoryLimit-1].Reason)
+}